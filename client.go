@@ -10,14 +10,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"syscall"
 
@@ -29,8 +30,11 @@ import (
 	"github.com/jmoiron/sqlx"
 	termbox "github.com/nsf/termbox-go"
 	"github.com/signal18/replication-manager/cluster"
+	"github.com/signal18/replication-manager/regtest"
+	"github.com/signal18/replication-manager/repmanv3"
 	"github.com/signal18/replication-manager/server"
 	"github.com/signal18/replication-manager/utils/s18log"
+	sstchannel "github.com/signal18/replication-manager/utils/sst"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -42,6 +46,7 @@ var (
 	cliPassword                  string
 	cliHost                      string
 	cliPort                      string
+	cliGrpcPort                  string
 	cliCert                      string
 	cliNoCheckCert               bool
 	cliToken                     string
@@ -49,9 +54,10 @@ var (
 	cliClusterIndex              int
 	cliTlog                      s18log.TermLog
 	cliTermlength                int
-	cliServers                   []cluster.ServerMonitor
-	cliMaster                    cluster.ServerMonitor
-	cliSettings                  cluster.Cluster
+	cliGrpc                      *repmanv3.Client
+	cliServers                   []*repmanv3.Server
+	cliMaster                    *repmanv3.Server
+	cliSettings                  = new(cluster.Cluster)
 	cliMonitor                   server.ReplicationManager
 	cliUrl                       string
 	cliTTestRun                  string
@@ -62,6 +68,7 @@ var (
 	cliTestConvertFile           string
 	cliTestResultDBCredential    string
 	cliTestResultDBServer        string
+	cliTestReport                string
 	cliBootstrapTopology         string
 	cliBootstrapCleanall         bool
 	cliBootstrapWithProvisioning bool
@@ -75,6 +82,16 @@ var (
 	cliConsoleServerIndex        int
 	cliShowObjects               string
 	cliConfirm                   string
+	cliSSTTask                   string
+	cliSSTToken                  string
+	cliSSTAddress                string
+	cliSSTListen                 string
+	cliSSTFile                   string
+	cliSSTSealedToken            string
+	cliSSTSpoolDir               string
+	cliSSTSslDir                 string
+	cliSSTBandwidth              int
+	cliSSTReceive                bool
 )
 
 type RequetParam struct {
@@ -97,6 +114,10 @@ func cliGetpasswd() string {
 func cliInit(needcluster bool) {
 	var err error
 
+	cliGrpc, err = repmanv3.Dial(cliHost+":"+cliGrpcPort, &tls.Config{InsecureSkipVerify: cliNoCheckCert})
+	if err != nil {
+		log.WithError(err).Fatal()
+	}
 	cliToken, err = cliLogin()
 	if err != nil {
 		cliPassword = cliGetpasswd()
@@ -121,7 +142,7 @@ func cliInit(needcluster bool) {
 		fmt.Println("Cluster not found")
 		os.Exit(10)
 	}
-	cliServers, err = cliGetServers()
+	cliServers, err = cliGetServers(cliClusters[cliClusterIndex])
 	if err != nil {
 		log.WithError(err).Fatal()
 		return
@@ -156,6 +177,7 @@ func initCliCommonFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&cliUser, "user", "admin", "User of replication-manager")
 	cmd.Flags().StringVar(&cliPassword, "password", "repman", "Paswword of replication-manager")
 	cmd.Flags().StringVar(&cliPort, "port", "10005", "TLS port of  replication-manager")
+	cmd.Flags().StringVar(&cliGrpcPort, "grpc-port", "10006", "gRPC port of replication-manager")
 	cmd.Flags().StringVar(&cliHost, "host", "127.0.0.1", "Host of replication-manager")
 	cmd.Flags().StringVar(&cliCert, "cert", "", "Public certificate")
 	cmd.Flags().BoolVar(&cliNoCheckCert, "insecure", true, "Don't check certificate")
@@ -185,6 +207,7 @@ func init() {
 	initCliCommonFlags(serverCmd)
 	rootCmd.AddCommand(showCmd)
 	initCliCommonFlags(showCmd)
+	rootCmd.AddCommand(sstCmd)
 
 	serverCmd.Flags().StringVar(&cliServerID, "id", "", "server id")
 	serverCmd.Flags().BoolVar(&cliServerMaintenance, "maintenance", false, "Toggle maintenance")
@@ -202,6 +225,7 @@ func init() {
 	testCmd.Flags().BoolVar(&cliTeststartcluster, "test-provision-cluster", true, "start the cluster between tests")
 	testCmd.Flags().BoolVar(&cliTeststopcluster, "test-unprovision-cluster", true, "stop the cluster between tests")
 	testCmd.Flags().BoolVar(&cliTestConvert, "convert", false, "convert test result to html")
+	testCmd.Flags().StringVar(&cliTestReport, "report", "", "directory where to write regtest.json and regtest.xml JUnit reports")
 
 	testCmd.Flags().StringVar(&cliTestConvertFile, "file", "", "test result.json")
 
@@ -213,6 +237,17 @@ func init() {
 
 	showCmd.Flags().StringVar(&cliShowObjects, "get", "settings,clusters,servers,master,slaves,crashes,alerts", "get the following objects")
 
+	sstCmd.Flags().StringVar(&cliSSTTask, "task", "", "Job task of the transfer")
+	sstCmd.Flags().StringVar(&cliSSTToken, "token", "", "One-time token of the job")
+	sstCmd.Flags().StringVar(&cliSSTSealedToken, "sealed-token", "", "One-time token of the job as stored in the jobs table, opened with the client key")
+	sstCmd.Flags().StringVar(&cliSSTAddress, "address", "", "Send to host:port of replication-manager")
+	sstCmd.Flags().BoolVar(&cliSSTReceive, "receive", false, "Receive on the listen address and write to stdout once verified")
+	sstCmd.Flags().StringVar(&cliSSTListen, "listen", ":4444", "Listen address when receiving")
+	sstCmd.Flags().StringVar(&cliSSTFile, "file", "", "File to send, stdin when empty, or file receiving the verified transfer instead of stdout")
+	sstCmd.Flags().StringVar(&cliSSTSpoolDir, "spool-dir", "", "Directory staging a received transfer until it is verified, temporary directory when empty")
+	sstCmd.Flags().StringVar(&cliSSTSslDir, "ssl-dir", "/etc/mysql/ssl", "Directory of the cluster ca-cert.pem, server and client certificates")
+	sstCmd.Flags().IntVar(&cliSSTBandwidth, "bandwidth", 0, "Bandwidth limit in KB/s when sending, 0 for unlimited")
+
 }
 
 var serverCmd = &cobra.Command{
@@ -222,8 +257,14 @@ var serverCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		log.SetFormatter(&log.TextFormatter{})
 		cliInit(true)
-		urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/servers/" + cliServerID + "/actions/maintenance"
-		_, err := cliAPICmd(urlpost, nil)
+		defer cliGrpc.Close()
+		action := repmanv3.ServerActionRequest_MAINTENANCE
+		if cliServerStart {
+			action = repmanv3.ServerActionRequest_START
+		} else if cliServerStop {
+			action = repmanv3.ServerActionRequest_STOP
+		}
+		_, err := cliGrpc.ServerAction(context.Background(), &repmanv3.ServerActionRequest{Cluster: cliClusters[cliClusterIndex], Server: cliServerID, Action: action})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s", err)
 			os.Exit(1)
@@ -309,34 +350,23 @@ var statusCmd = &cobra.Command{
 			}
 		}
 		if cfgGroup != "" {
-			urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/status"
-			res, err := cliAPICmd(urlpost, nil)
+			cl, err := cliGrpc.GetCluster(context.Background(), &repmanv3.ClusterRequest{Cluster: cliClusters[cliClusterIndex]})
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "API call %s", err)
 				os.Exit(1)
-			} else {
-				if res != "" {
-					err = json.Unmarshal([]byte(res), &ret)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "API call %s", err)
-						os.Exit(2)
-					} else {
-						if cliStatusErrors {
-							urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/topology/alerts"
-							res, err := cliAPICmd(urlpost, nil)
-							if err != nil {
-								fmt.Fprintf(os.Stderr, "API call %s", err)
-								os.Exit(3)
-							} else {
-								fmt.Fprintf(os.Stdout, "%s\n", res)
-							}
-						} else {
-							fmt.Fprintf(os.Stdout, "%s\n", ret.Alive)
-						}
-						os.Exit(0)
-					}
+			}
+			if cliStatusErrors {
+				alerts, err := cliGrpc.ListAlerts(context.Background(), &repmanv3.ClusterRequest{Cluster: cliClusters[cliClusterIndex]})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "API call %s", err)
+					os.Exit(3)
 				}
+				res, _ := json.MarshalIndent(alerts, "", "\t")
+				fmt.Fprintf(os.Stdout, "%s\n", res)
+			} else {
+				fmt.Fprintf(os.Stdout, "%s\n", cl.Status)
 			}
+			os.Exit(0)
 		}
 	},
 }
@@ -346,14 +376,18 @@ var failoverCmd = &cobra.Command{
 	Short: "Failover a dead master",
 	Long:  `Trigger failover on a dead master by promoting a slave.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var slogs []string
 		cliInit(true)
 		cliGetTopology()
-		cliClusterCmd("actions/failover", nil)
-		slogs, _ = cliGetLogs()
-		cliPrintLog(slogs)
-		cliServers, _ = cliGetServers()
+		err := cliRunWithLogs(func() error {
+			_, err := cliGrpc.Failover(context.Background(), &repmanv3.ClusterRequest{Cluster: cliClusters[cliClusterIndex]})
+			return err
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		cliServers, _ = cliGetServers(cliClusters[cliClusterIndex])
 		cliGetTopology()
+		cliGrpc.Close()
 	},
 	PostRun: func(cmd *cobra.Command, args []string) {
 
@@ -366,24 +400,18 @@ var switchoverCmd = &cobra.Command{
 	Long: `Performs an online master switch by promoting a slave to master
 and demoting the old master to slave`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var slogs []string
-		var prefMasterParam RequetParam
-		var params []RequetParam
-
 		cliInit(true)
 		cliGetTopology()
-		if cliPrefMaster != "" {
-			prefMasterParam.key = "prefmaster"
-			prefMasterParam.value = cliPrefMaster
-			params = append(params, prefMasterParam)
-			cliClusterCmd("actions/switchover", params)
-		} else {
-			cliClusterCmd("actions/switchover", nil)
+		err := cliRunWithLogs(func() error {
+			_, err := cliGrpc.Switchover(context.Background(), &repmanv3.SwitchoverRequest{Cluster: cliClusters[cliClusterIndex], PreferedMaster: cliPrefMaster})
+			return err
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
 		}
-		slogs, _ = cliGetLogs()
-		cliPrintLog(slogs)
-		cliServers, _ = cliGetServers()
+		cliServers, _ = cliGetServers(cliClusters[cliClusterIndex])
 		cliGetTopology()
+		cliGrpc.Close()
 
 	},
 	PostRun: func(cmd *cobra.Command, args []string) {
@@ -430,10 +458,7 @@ var testCmd = &cobra.Command{
 
 		if cliTestConvert {
 
-			type TestResults struct {
-				Results []cluster.Test `json:"results"`
-			}
-			var cltests TestResults
+			var cltests regtest.TestResults
 			file, err := ioutil.ReadFile(cliTestConvertFile)
 			if err != nil {
 				fmt.Printf("File error: %v\n", err)
@@ -467,6 +492,20 @@ var testCmd = &cobra.Command{
 		if cliTestShowTests == false {
 
 			todotests := strings.Split(cliTTestRun, ",")
+			var results []cluster.Test
+			// the report is written once, on return or before a fatal error
+			reported := false
+			writeReport := func() {
+				if cliTestReport == "" || reported {
+					return
+				}
+				reported = true
+				err := regtest.WriteReport(cliTestReport, "regtest", cliClusters[cliClusterIndex], results)
+				if err != nil {
+					fmt.Printf("Could not write test report: %s\n", err)
+				}
+			}
+			defer writeReport()
 
 			for _, test := range todotests {
 				var thistest cluster.Test
@@ -489,6 +528,8 @@ var testCmd = &cobra.Command{
 				res, err := cliAPICmd(urlpost, params)
 				if err != nil {
 					fmt.Printf(string(data))
+					results = append(results, thistest)
+					writeReport()
 					log.Fatal("Error in API call")
 				} else {
 					if res != "" {
@@ -499,6 +540,7 @@ var testCmd = &cobra.Command{
 							fmt.Printf("No valid json in test result: %v\n", err)
 							return
 						}
+						results = append(results, thistest)
 						// post result in database
 						if cliTestResultDBServer != "" {
 							params := fmt.Sprintf("?timeout=2s")
@@ -522,6 +564,7 @@ var testCmd = &cobra.Command{
 
 					} else {
 						fmt.Printf(string(data))
+						results = append(results, thistest)
 					}
 				}
 			}
@@ -617,133 +660,107 @@ var showCmd = &cobra.Command{
 	},
 }
 
-var clientCmd = &cobra.Command{
-	Use:   "console",
-	Short: "Starts the interactive replication-manager console",
-	Long:  "Connect to replication-manager in stateful TLS JWT mode.",
+var sstCmd = &cobra.Command{
+	Use:   "sst",
+	Short: "Transfer a backup or a log of a database job",
+	Long:  `The sst command is used by the database jobs to send backups and logs to replication-manager or to receive a reseed backup, the transfer is authenticated with the cluster certificates and the token of the job. A file resumes from any offset when the connection drops, stdin only within the last bytes kept in memory`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cliInit(false)
+		var err error
+		if cliSSTSealedToken != "" {
+			cliSSTToken, err = sstchannel.OpenToken(cliSSTSslDir+"/client-key.pem", cliSSTSealedToken)
+		}
+		if err == nil && cliSSTReceive {
+			err = cliSSTRunReceiver()
+		} else if err == nil {
+			err = cliSSTRunSender()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	},
+}
 
-		err := termbox.Init()
+// cliSSTRunReceiver stages the transfer in a file, nothing is written to the output
+// before the checksum of the whole transfer is verified
+func cliSSTRunReceiver() error {
+	tlsConfig, err := sstchannel.ServerConfig(cliSSTSslDir+"/ca-cert.pem", cliSSTSslDir+"/server-cert.pem", cliSSTSslDir+"/server-key.pem")
+	if err != nil {
+		return err
+	}
+	stage, err := ioutil.TempFile(cliSSTSpoolDir, "sst-"+cliSSTTask+"-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		stage.Close()
+		os.Remove(stage.Name())
+	}()
+	l, err := tls.Listen("tcp", cliSSTListen, tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	transfer := sstchannel.NewTransfer(cliSSTTask, cliSSTToken, stage)
+	for {
+		conn, err := l.Accept()
 		if err != nil {
-			log.WithError(err).Fatal("Termbox initialization error")
+			return err
 		}
-		_, cliTermlength = termbox.Size()
-		if cliTermlength == 0 {
-			cliTermlength = 120
-		} else if cliTermlength < 18 {
-			log.Fatal("Terminal too small, please increase window size")
+		done, err := transfer.Receive(conn)
+		conn.Close()
+		if done {
+			break
 		}
-		loglen := cliTermlength - 9 - (len(strings.Split(conf.Hosts, ",")) * 3)
-		termboxChan := cliNewTbChan()
-		interval := time.Second
-		ticker := time.NewTicker(interval * time.Duration(2))
-
-		for cliExit == false {
-			select {
-			case <-ticker.C:
-				cliSettings, _ = cliGetSettings()
-				cliServers, _ = cliGetServers()
-				cliMaster, _ = cliGetMaster()
-				dlogs, _ := cliGetLogs()
-				cliTlog = s18log.NewTermLog(loglen)
-				cliAddTlog(dlogs)
-				cliDisplay()
-			case event := <-termboxChan:
-				switch event.Type {
-				case termbox.EventKey:
-					if event.Key == termbox.KeyCtrlS {
-						//	fmt.Println("Confirm switchover ? [Y,y]")
-						cliConfirm = "Confirm switchover ? [Y,y]"
-
-						cliDisplay()
-					confirmloop:
-						for {
-							select {
-							case ev := <-termboxChan:
-								switch ev.Type {
-								case termbox.EventKey:
-
-									if ev.Ch == 89 || ev.Ch == 121 {
-										cliClusterCmd("actions/switchover", nil)
-									}
-									cliConfirm = ""
-									break confirmloop
-
-								}
-							}
-						}
-					}
-					if event.Key == termbox.KeyCtrlF {
-						if cliMaster.State == "Failed" {
-							cliClusterCmd("actions/failover", nil)
-						}
-					}
-					if event.Key == termbox.KeyCtrlM {
-						cliClusterCmd("servers/"+cliServers[cliConsoleServerIndex].Id+"/actions/maintenance", nil)
-					}
-
-					if event.Key == termbox.KeyArrowUp {
-						cliConsoleServerIndex--
-						if cliConsoleServerIndex < 0 {
-							cliConsoleServerIndex = len(cliServers) - 1
-						}
-					}
-					if event.Key == termbox.KeyArrowDown {
-						cliConsoleServerIndex++
-						if cliConsoleServerIndex >= len(cliServers) {
-							cliConsoleServerIndex = 0
-						}
-
-					}
-					if event.Key == termbox.KeyCtrlD {
+		fmt.Fprintf(os.Stderr, "Transfer from %s interrupted after %d bytes: %s\n", conn.RemoteAddr(), transfer.Received(), err)
+	}
+	if cliSSTFile != "" {
+		stage.Close()
+		return os.Rename(stage.Name(), cliSSTFile)
+	}
+	if _, err := stage.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, stage)
+	return err
+}
 
-						//call topology
-					}
-					if event.Key == termbox.KeyCtrlN {
-						cliClusterIndex++
-						if cliClusterIndex >= len(cliClusters) {
-							cliClusterIndex = 0
-						}
-					}
-					if event.Key == termbox.KeyCtrlP {
-						cliClusterIndex--
-						if cliClusterIndex < 0 {
-							cliClusterIndex = len(cliClusters) - 1
-						}
-					}
-					if event.Key == termbox.KeyCtrlR {
-						cliClusterCmd("settings/actions/switch/failover-readonly-state", nil)
-					}
-					if event.Key == termbox.KeyCtrlW {
-						cliClusterCmd("settings/actions/switch/failover-readonly-state", nil)
-					}
-					if event.Key == termbox.KeyCtrlI {
-						cliClusterCmd("settings/actions/switch/failover-mode", nil)
-					}
-					if event.Key == termbox.KeyCtrlV {
-						cliClusterCmd("settings/actions/switch/verbosity", nil)
-					}
-					if event.Key == termbox.KeyCtrlE {
-						cliClusterCmd("actions/reset-failover-control", nil)
-					}
-					if event.Key == termbox.KeyCtrlH {
-						cliDisplayHelp()
-					}
-					if event.Key == termbox.KeyCtrlQ {
-						cliExit = true
-					}
-					if event.Key == termbox.KeyCtrlC {
-						cliExit = true
-					}
-				}
-				switch event.Ch {
-				case 's':
-					termbox.Sync()
-				}
-			}
+func cliSSTRunSender() error {
+	tlsConfig, err := sstchannel.ClientConfig(cliSSTSslDir+"/ca-cert.pem", cliSSTSslDir+"/client-cert.pem", cliSSTSslDir+"/client-key.pem")
+	if err != nil {
+		return err
+	}
+	in := os.Stdin
+	if cliSSTFile != "" {
+		in, err = os.Open(cliSSTFile)
+		if err != nil {
+			return err
 		}
+		defer in.Close()
+	}
+	sender := &sstchannel.Sender{
+		Task:      cliSSTTask,
+		Token:     cliSSTToken,
+		Bandwidth: int64(cliSSTBandwidth) * 1024,
+		Retries:   3,
+		Dial: func() (io.ReadWriteCloser, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", cliSSTAddress, tlsConfig)
+		},
+	}
+	if cliSSTFile == "" {
+		// stdin only resumes within the window of its last bytes
+		return sender.Send(struct{ io.Reader }{in})
+	}
+	return sender.Send(in)
+}
 
+var clientCmd = &cobra.Command{
+	Use:   "console",
+	Short: "Starts the interactive replication-manager console",
+	Long:  "Connect to replication-manager in stateful TLS JWT mode.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cliInit(false)
+		cliConsole()
 	},
 	PostRun: func(cmd *cobra.Command, args []string) {
 		// Close connections on exit.
@@ -798,136 +815,56 @@ func cliGetTopology() {
 	headstr += fmt.Sprintf("\n%19s %15s %6s %15s %10s %12s %20s %20s %30s %6s %3s", "Id", "Host", "Port", "Status", "Failures", "Using GTID", "Current GTID", "Slave GTID", "Replication Health", "Delay", "RO")
 
 	for _, server := range cliServers {
-		var gtidCurr string
-		var gtidSlave string
-		if server.CurrentGtid != nil {
-			gtidCurr = server.CurrentGtid.Sprint()
-		} else {
-			gtidCurr = ""
-		}
-		if server.SlaveGtid != nil {
-			gtidSlave = server.SlaveGtid.Sprint()
-		} else {
-			gtidSlave = ""
-		}
-
-		headstr += fmt.Sprintf("\n%19s %15s %6s %15s %10d %12s %20s %20s %30s %6d %3s", server.Id, server.Host, server.Port, server.State, server.FailCount, server.GetReplicationUsingGtid(), gtidCurr, gtidSlave, "", server.GetReplicationDelay(), server.ReadOnly)
+		headstr += fmt.Sprintf("\n%19s %15s %6s %15s %10d %12s %20s %20s %30s %6d %3s", server.Id, server.Host, server.Port, server.State, server.FailCount, cliGetUsingGtid(server), server.CurrentGtid, server.SlaveGtid, "", server.ReplicationDelay, cliOnOff(server.ReadOnly))
 
 	}
 	fmt.Printf(headstr)
 	fmt.Printf("\n")
 }
 
-func cliDisplay() {
-
-	termbox.Clear(termbox.ColorWhite, termbox.ColorBlack)
-	headstr := fmt.Sprintf(" Replication Manager Client ")
-
-	if cliClusters[cliClusterIndex] != "" {
-		headstr += fmt.Sprintf("| Group: %s", cliClusters[cliClusterIndex])
-	}
-	if cliSettings.Conf.Interactive == false {
-		headstr += " |  Mode: Automatic "
-	} else {
-		headstr += " |  Mode: Manual "
-	}
-	cliPrintfTb(0, 0, termbox.ColorWhite, termbox.ColorBlack|termbox.AttrReverse|termbox.AttrBold, headstr)
-	cliPrintfTb(0, 1, termbox.ColorRed, termbox.ColorBlack|termbox.AttrReverse|termbox.AttrBold, cliConfirm)
-	cliPrintfTb(0, 2, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, "%1s%15s %6s %15s %10s %12s %20s %20s %30s %6s %3s", " ", "Host", "Port", "Status", "Failures", "Using GTID", "Current GTID", "Slave GTID", "Replication Health", "Delay", "RO")
-	cliTlog.Line = 3
-	for i, server := range cliServers {
-		var gtidCurr string
-		var gtidSlave string
-		if server.CurrentGtid != nil {
-			gtidCurr = server.CurrentGtid.Sprint()
-		} else {
-			gtidCurr = ""
-		}
-		if server.SlaveGtid != nil {
-			gtidSlave = server.SlaveGtid.Sprint()
-		} else {
-			gtidSlave = ""
-		}
-
-		var fgCol termbox.Attribute
-		switch server.State {
-		case "Master":
-			fgCol = termbox.ColorGreen
-		case "Failed":
-			fgCol = termbox.ColorRed
-		case "Unconnected":
-			fgCol = termbox.ColorBlue
-		case "Suspect":
-			fgCol = termbox.ColorMagenta
-		case "SlaveErr":
-			fgCol = termbox.ColorMagenta
-		case "SlaveLate":
-			fgCol = termbox.ColorYellow
-		default:
-			fgCol = termbox.ColorWhite
-		}
-		mystatus := server.State
-		if server.IsVirtualMaster {
-			mystatus = mystatus + "*VM"
-		}
-		myServerPointer := " "
-		if i == cliConsoleServerIndex {
-			myServerPointer = ">"
-		}
-		cliPrintfTb(1, cliTlog.Line, fgCol, termbox.ColorBlack, "%1s%15s %6s %15s %10d %12s %20s %20s %30s %6d %3s", myServerPointer, server.Host, server.Port, mystatus, server.FailCount, server.GetReplicationUsingGtid(), gtidCurr, gtidSlave, server.ReplicationHealth, server.GetReplicationDelay(), server.ReadOnly)
-		cliTlog.Line++
-	}
-	cliTlog.Line++
-	if cliMaster.State != "Failed" {
-		cliPrintTb(0, cliTlog.Line, termbox.ColorWhite, termbox.ColorBlack, " Ctrl-Q to quit, Ctrl-S to switchover, Ctrl-(N|P) to change Cluster,Ctrl-H to help")
-	} else {
-		cliPrintTb(0, cliTlog.Line, termbox.ColorWhite, termbox.ColorBlack, " Ctrl-Q to quit, Ctrl-F to failover, Ctrl-(N|P) to change Cluster,Ctrl-H to help")
-	}
-	cliTlog.Line = cliTlog.Line + 3
-	cliTlog.Print()
-
-	termbox.Flush()
-	_, newlen := termbox.Size()
-	if newlen == 0 {
-		// pass
-	} else if newlen > cliTermlength {
-		cliTermlength = newlen
-		cliTlog.Len = cliTermlength - 9 - (len(cliClusters) * 3)
-		cliTlog.Extend()
-	} else if newlen < cliTermlength {
-		cliTermlength = newlen
-		cliTlog.Len = cliTermlength - 9 - (len(cliClusters) * 3)
-		cliTlog.Shrink()
+// cliGetUsingGtid returns the GTID mode of the first replication source
+func cliGetUsingGtid(server *repmanv3.Server) string {
+	if len(server.Replications) == 0 {
+		return "No"
 	}
+	return server.Replications[0].UsingGtid
 }
 
-func cliAddTlog(dlogs []string) {
-	cliTlog.Shrink()
-	for _, dl := range dlogs {
-		cliTlog.Add(dl)
+func cliOnOff(b bool) string {
+	if b {
+		return "ON"
 	}
+	return "OFF"
 }
 
-func cliDisplayHelp() {
-	cliLogPrint("HELP : Ctrl-D  Print debug information")
-	cliLogPrint("HELP : Ctrl-F  Failover")
-	cliLogPrint("HELP : Ctrl-S  Switchover")
-	cliLogPrint("HELP : Ctrl-M  Maintenance")
-	cliLogPrint("HELP : Ctrl-N  Next Cluster")
-	cliLogPrint("HELP : Ctrl-P  Previous Cluster")
-	cliLogPrint("HELP : Ctrl-Q  Quit")
-	cliLogPrint("HELP : Ctrl-C  Quit")
-	cliLogPrint("HELP : Ctrl-I  Switch failover automatic/manual")
-	cliLogPrint("HELP : Ctrl-R  Switch slaves read-only/read-write")
-	cliLogPrint("HELP : Ctrl-V  Switch verbosity")
-	cliLogPrint("HELP : Ctrl-E  Erase failover control")
-
-}
-
-func cliPrintLog(msg []string) {
-	for _, c := range msg {
-		log.Printf(c)
+// cliRunWithLogs prints the log lines of the cluster while the action runs
+func cliRunWithLogs(action func() error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := cliGrpc.StreamLogs(ctx, &repmanv3.StreamLogsRequest{Cluster: cliClusters[cliClusterIndex], FollowOnly: true})
+	if err != nil {
+		return err
 	}
+	// the header is sent once the server follows the logs
+	if _, err := stream.Header(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			l, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			log.Printf("%s : %s", l.Level, l.Text)
+		}
+	}()
+	err = action()
+	// let the last lines of the action arrive
+	time.AfterFunc(500*time.Millisecond, cancel)
+	<-done
+	return err
 }
 
 func cliPrintTb(x, y int, fg, bg termbox.Attribute, msg string) {
@@ -954,75 +891,25 @@ func cliLogPrint(msg ...interface{}) {
 }
 
 func cliLogin() (string, error) {
-
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/login"
-	var jsonStr = []byte(`{"username":"` + cliUser + `", "password":"` + cliPassword + `"}`)
-	req, err := http.NewRequest("POST", urlpost, bytes.NewBuffer(jsonStr))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := cliConn.Do(req)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return "", err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return "", err
-	}
-	if resp.StatusCode == http.StatusForbidden {
-		return "", errors.New("Wrong credentential")
-	}
-
-	type Result struct {
-		Token string `json:"token"`
-	}
-	var r Result
-	err = json.Unmarshal(body, &r)
+	res, err := cliGrpc.Login(context.Background(), &repmanv3.LoginRequest{Username: cliUser, Password: cliPassword})
 	if err != nil {
-		log.Println("ERROR in login", err)
 		return "", err
 	}
-	return r.Token, nil
+	cliGrpc.SetToken(res.Token)
+	return res.Token, nil
 }
 
 func cliGetAllClusters() ([]string, error) {
-	var r server.ReplicationManager
-	var res []string
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/monitor"
-	var bearer = "Bearer " + cliToken
-	req, err := http.NewRequest("GET", urlpost, nil)
-	if err != nil {
-		return res, err
-	}
-	req.Header.Set("Authorization", bearer)
-	resp, err := cliConn.Do(req)
+	res, err := cliGrpc.ListClusters(context.Background(), &repmanv3.ListClustersRequest{})
 	if err != nil {
-		log.Println("ERROR", err)
-		return res, err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("ERROR", err)
-		return res, err
-	}
-	//	log.Printf("%s", body)
-	err = json.Unmarshal(body, &r)
-	if err != nil {
-		log.Println("ERROR in cluster list", err)
-
-		return res, err
-	}
-	return r.ClusterList, nil
+	return res.Clusters, nil
 }
 
-func cliGetSettings() (cluster.Cluster, error) {
-	var r cluster.Cluster
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + ""
+func cliGetSettings(name string) (*cluster.Cluster, error) {
+	r := new(cluster.Cluster)
+	urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + name
 	var bearer = "Bearer " + cliToken
 	req, err := http.NewRequest("GET", urlpost, nil)
 	if err != nil {
@@ -1044,7 +931,7 @@ func cliGetSettings() (cluster.Cluster, error) {
 		return r, errors.New("Wrong credentential")
 	}
 
-	err = json.Unmarshal(body, &r)
+	err = json.Unmarshal(body, r)
 	if err != nil {
 		log.Println("ERROR in settings", err)
 		return r, err
@@ -1084,139 +971,16 @@ func cliGetMonitor() (server.ReplicationManager, error) {
 	return r, nil
 }
 
-func cliGetServers() ([]cluster.ServerMonitor, error) {
-	var r []cluster.ServerMonitor
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/topology/servers"
-	//log.Println("INFO ", urlpost)
-	var bearer = "Bearer " + cliToken
-	req, err := http.NewRequest("GET", urlpost, nil)
-	if err != nil {
-		return r, err
-	}
-	req.Header.Set("Authorization", bearer)
-
-	resp, err := cliConn.Do(req)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return r, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return r, err
-	}
-	if resp.StatusCode == http.StatusForbidden {
-		return r, errors.New("Wrong credentential")
-	}
-	err = json.Unmarshal(body, &r)
-	if err != nil {
-		log.Println("ERROR in getting servers", err)
-		return r, err
-	}
-	resp.Body.Close()
-	return r, nil
-}
-
-func cliGetMaster() (cluster.ServerMonitor, error) {
-	var r cluster.ServerMonitor
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/topology/master"
-	var bearer = "Bearer " + cliToken
-	req, err := http.NewRequest("GET", urlpost, nil)
-	if err != nil {
-		return r, err
-	}
-	req.Header.Set("Authorization", bearer)
-	resp, err := cliConn.Do(req)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return r, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return r, err
-	}
-	err = json.Unmarshal(body, &r)
+func cliGetServers(name string) ([]*repmanv3.Server, error) {
+	res, err := cliGrpc.ListServers(context.Background(), &repmanv3.ClusterRequest{Cluster: name})
 	if err != nil {
-		log.Println("ERROR in getting master", err)
-		return r, err
+		return nil, err
 	}
-	resp.Body.Close()
-	return r, nil
+	return res.Servers, nil
 }
 
-func cliGetLogs() ([]string, error) {
-	var r []string
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/topology/logs"
-	var bearer = "Bearer " + cliToken
-	req, err := http.NewRequest("GET", urlpost, nil)
-	if err != nil {
-		return r, err
-	}
-	req.Header.Set("Authorization", bearer)
-	resp, err := cliConn.Do(req)
-	if err != nil {
-		log.Println("ERROR on getting logs ", err)
-		return r, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("ERROR on getting logs", err)
-		return r, err
-	}
-	if resp.StatusCode == http.StatusForbidden {
-		return r, errors.New("Wrong credentential")
-	}
-	err = json.Unmarshal(body, &r)
-	if err != nil {
-		log.Println("ERROR on getting logs", err)
-		return r, err
-	}
-	resp.Body.Close()
-	return r, nil
-}
-
-func cliClusterCmd(command string, params []RequetParam) error {
-	//var r string
-	urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/" + command
-	var bearer = "Bearer " + cliToken
-
-	data := url.Values{}
-	data.Add("customer_name", "value")
-	if params != nil {
-		for _, param := range params {
-			data.Add(param.key, param.value)
-		}
-	}
-	b := bytes.NewBuffer([]byte(data.Encode()))
-
-	req, err := http.NewRequest("POST", urlpost, b)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Authorization", bearer)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := cliConn.Do(req)
-	if err != nil {
-		log.Println("ERROR", err)
-		return err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("ERROR", err)
-		return err
-	}
-	cliTlog.Add(string(body))
-	/*err = json.Unmarshal(body, &r)
-	if err != nil {
-		log.Println("ERROR ", err)
-		return err
-	}*/
-	return nil
+func cliGetMaster(name string) (*repmanv3.Server, error) {
+	return cliGrpc.GetMaster(context.Background(), &repmanv3.ClusterRequest{Cluster: name})
 }
 
 func cliAPICmd(urlpost string, params []RequetParam) (string, error) {
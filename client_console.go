@@ -0,0 +1,773 @@
+//go:build clients
+// +build clients
+
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/signal18/replication-manager/cluster"
+	"github.com/signal18/replication-manager/repmanv3"
+	"github.com/signal18/replication-manager/utils/s18log"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	cliViewServers = iota
+	cliViewProxies
+	cliViewAlerts
+	cliViewJobs
+	cliViewLogs
+)
+
+// width of the cluster list pane
+const cliClusterPaneWidth = 24
+
+// number of lag samples kept per server for the sparkline
+const cliLagSamples = 40
+
+// number of log lines kept for the logs view
+const cliLogLines = 500
+
+var cliViewNames = []string{"Servers", "Proxies", "Alerts", "Jobs", "Logs"}
+
+var cliSparks = []rune("▁▂▃▄▅▆▇█")
+
+var cliHelpLines = []string{
+	"q, Esc, Ctrl-Q          Quit",
+	"Tab, 1-5                Change view servers, proxies, alerts, jobs, logs",
+	"n, p, Left, Right       Change cluster, also Ctrl-N, Ctrl-P",
+	"Up, Down                Select server",
+	"Ctrl-S                  Switchover",
+	"Ctrl-F                  Failover",
+	"m                       Maintenance toggle of the selected server",
+	"t, x                    Start, stop slave on the selected server",
+	"/                       Filter logs, Enter to apply, Esc to clear",
+	"Ctrl-A                  Switch failover automatic/manual",
+	"Ctrl-R                  Switch slaves read-only/read-write",
+	"Ctrl-V                  Switch verbosity",
+	"Ctrl-E                  Erase failover control",
+	"?, h, F1                Show this help, any key to close",
+}
+
+// cliConsoleData is the state of a cluster fetched in the background
+type cliConsoleData struct {
+	cluster       string
+	settings      *cluster.Cluster
+	servers       []*repmanv3.Server
+	master        *repmanv3.Server
+	proxies       []*repmanv3.Proxy
+	alerts        *repmanv3.ListAlertsResponse
+	clusterAlerts map[string]int
+	time          time.Time
+}
+
+var (
+	cliConsoleView       int
+	cliConsoleFilter     string
+	cliConsoleFilterEdit bool
+	cliConsoleHelp       bool
+	cliConsoleAction     func()
+	cliConsoleFetched    = make(chan cliConsoleData)
+	cliConsoleFetching   bool
+	cliConsoleFetchAgain bool
+	cliConsoleRefresh    time.Time
+	cliProxies           []*repmanv3.Proxy
+	cliAlerts            *repmanv3.ListAlertsResponse
+	cliLogs              []string
+	cliLogsMu            sync.Mutex
+	cliLogsCancel        context.CancelFunc
+	cliClusterAlerts     = make(map[string]int)
+	cliLag               = make(map[string][]int64)
+)
+
+func cliConsole() {
+	err := termbox.Init()
+	if err != nil {
+		log.WithError(err).Fatal("Termbox initialization error")
+	}
+	_, cliTermlength = termbox.Size()
+	if cliTermlength == 0 {
+		cliTermlength = 120
+	} else if cliTermlength < 18 {
+		log.Fatal("Terminal too small, please increase window size")
+	}
+	cliTlog = s18log.NewTermLog(20)
+	termboxChan := cliNewTbChan()
+	ticker := time.NewTicker(2 * time.Second)
+	cliConsoleFollowLogs()
+	cliConsoleFetch()
+	cliDisplay()
+
+	for cliExit == false {
+		select {
+		case <-ticker.C:
+			cliConsoleFetch()
+		case data := <-cliConsoleFetched:
+			cliConsoleApply(data)
+			cliDisplay()
+		case event := <-termboxChan:
+			switch event.Type {
+			case termbox.EventKey:
+				cliConsoleKey(event)
+			case termbox.EventResize:
+				termbox.Sync()
+			}
+			cliDisplay()
+		}
+	}
+}
+
+// cliConsoleFetch refreshes the selected cluster and the alert count of every cluster in
+// the background, a refresh asked while one runs is done once it ends
+func cliConsoleFetch() {
+	if cliConsoleFetching {
+		cliConsoleFetchAgain = true
+		return
+	}
+	cliConsoleFetching = true
+	go func(cl string, clusters []string) {
+		cliConsoleFetched <- cliConsoleFetchCluster(cl, clusters)
+	}(cliClusters[cliClusterIndex], cliClusters)
+}
+
+func cliConsoleFetchCluster(cl string, clusters []string) cliConsoleData {
+	data := cliConsoleData{cluster: cl, clusterAlerts: make(map[string]int)}
+	data.settings, _ = cliGetSettings(cl)
+	data.servers, _ = cliGetServers(cl)
+	data.master, _ = cliGetMaster(cl)
+	if res, err := cliGrpc.ListProxies(context.Background(), &repmanv3.ClusterRequest{Cluster: cl}); err == nil {
+		data.proxies = res.Proxies
+	}
+	for _, c := range clusters {
+		alerts, err := cliGrpc.ListAlerts(context.Background(), &repmanv3.ClusterRequest{Cluster: c})
+		if err == nil {
+			data.clusterAlerts[c] = len(alerts.Errors)
+			if c == cl {
+				data.alerts = alerts
+			}
+		}
+	}
+	data.time = time.Now()
+	return data
+}
+
+// cliConsoleApply shows the fetched state, the state of a cluster no more selected is dropped
+func cliConsoleApply(data cliConsoleData) {
+	cliConsoleFetching = false
+	if cliConsoleFetchAgain {
+		cliConsoleFetchAgain = false
+		cliConsoleFetch()
+	}
+	for cl, n := range data.clusterAlerts {
+		cliClusterAlerts[cl] = n
+	}
+	if data.cluster != cliClusters[cliClusterIndex] {
+		return
+	}
+	if data.settings != nil {
+		cliSettings = data.settings
+	}
+	cliServers, cliMaster = data.servers, data.master
+	cliProxies, cliAlerts = data.proxies, data.alerts
+	for _, s := range cliServers {
+		lag := append(cliLag[s.Id], s.ReplicationDelay)
+		if len(lag) > cliLagSamples {
+			lag = lag[len(lag)-cliLagSamples:]
+		}
+		cliLag[s.Id] = lag
+	}
+	cliConsoleRefresh = data.time
+}
+
+// cliConsoleFollowLogs streams the log lines of the selected cluster in the background,
+// the stream is resumed from new lines when the server drops it
+func cliConsoleFollowLogs() {
+	if cliLogsCancel != nil {
+		cliLogsCancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cliLogsCancel = cancel
+	cliLogsMu.Lock()
+	cliLogs = nil
+	cliLogsMu.Unlock()
+	go func(cl string) {
+		followOnly := false
+		for ctx.Err() == nil {
+			stream, err := cliGrpc.StreamLogs(ctx, &repmanv3.StreamLogsRequest{Cluster: cl, FollowOnly: followOnly})
+			for err == nil {
+				var l *repmanv3.LogLine
+				l, err = stream.Recv()
+				if err == nil {
+					cliAddLog(fmt.Sprintf("%s %s : %s", l.Timestamp, l.Level, l.Text))
+				}
+			}
+			followOnly = true
+			select {
+			case <-ctx.Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}(cliClusters[cliClusterIndex])
+}
+
+func cliAddLog(line string) {
+	cliLogsMu.Lock()
+	defer cliLogsMu.Unlock()
+	cliLogs = append(cliLogs, line)
+	if len(cliLogs) > cliLogLines {
+		cliLogs = cliLogs[len(cliLogs)-cliLogLines:]
+	}
+}
+
+func cliGetLogs() []string {
+	cliLogsMu.Lock()
+	defer cliLogsMu.Unlock()
+	return cliLogs
+}
+
+// cliConsoleAnswer shows the answer of the API to an action in the status line
+func cliConsoleAnswer(name string, res *repmanv3.ActionResponse, err error) {
+	switch {
+	case err != nil:
+		cliTlog.Add(name + " failed: " + status.Convert(err).Message())
+	case !res.Done:
+		cliTlog.Add(name + " not done " + res.Message)
+	default:
+		cliTlog.Add(name + " done")
+	}
+}
+
+func cliConsoleSwitchSetting(setting string) {
+	res, err := cliGrpc.SwitchSetting(context.Background(), &repmanv3.SettingRequest{Cluster: cliClusters[cliClusterIndex], Setting: setting})
+	cliConsoleAnswer("Switch "+setting, res, err)
+}
+
+func cliConsoleServerAction(id string, action repmanv3.ServerActionRequest_Action) {
+	res, err := cliGrpc.ServerAction(context.Background(), &repmanv3.ServerActionRequest{Cluster: cliClusters[cliClusterIndex], Server: id, Action: action})
+	cliConsoleAnswer(strings.ToLower(action.String()), res, err)
+}
+
+// cliConfirmAction asks for confirmation before calling the API
+func cliConfirmAction(msg string, action func()) {
+	cliConfirm = msg + " ? [y/N]"
+	cliConsoleAction = action
+}
+
+func cliConsoleKey(event termbox.Event) {
+	if cliConsoleHelp {
+		cliConsoleHelp = false
+		return
+	}
+	if cliConsoleAction != nil {
+		if event.Ch == 'y' || event.Ch == 'Y' {
+			cliConsoleAction()
+			cliConsoleFetch()
+		}
+		cliConfirm = ""
+		cliConsoleAction = nil
+		return
+	}
+	if cliConsoleFilterEdit {
+		switch {
+		case event.Key == termbox.KeyEnter:
+			cliConsoleFilterEdit = false
+		case event.Key == termbox.KeyEsc:
+			cliConsoleFilterEdit = false
+			cliConsoleFilter = ""
+		case event.Key == termbox.KeyBackspace || event.Key == termbox.KeyBackspace2:
+			if len(cliConsoleFilter) > 0 {
+				r := []rune(cliConsoleFilter)
+				cliConsoleFilter = string(r[:len(r)-1])
+			}
+		case event.Key == termbox.KeySpace:
+			cliConsoleFilter += " "
+		case event.Ch != 0:
+			cliConsoleFilter += string(event.Ch)
+		}
+		return
+	}
+	var server *repmanv3.Server
+	if cliConsoleServerIndex >= 0 && cliConsoleServerIndex < len(cliServers) {
+		server = cliServers[cliConsoleServerIndex]
+	}
+	switch event.Key {
+	case termbox.KeyCtrlQ, termbox.KeyCtrlC, termbox.KeyEsc:
+		cliExit = true
+	case termbox.KeyTab:
+		cliConsoleView = (cliConsoleView + 1) % len(cliViewNames)
+	case termbox.KeyArrowUp:
+		cliConsoleServerIndex--
+		if cliConsoleServerIndex < 0 {
+			cliConsoleServerIndex = len(cliServers) - 1
+		}
+	case termbox.KeyArrowDown:
+		cliConsoleServerIndex++
+		if cliConsoleServerIndex >= len(cliServers) {
+			cliConsoleServerIndex = 0
+		}
+	case termbox.KeyArrowRight, termbox.KeyCtrlN:
+		cliConsoleSelectCluster(cliClusterIndex + 1)
+	case termbox.KeyArrowLeft, termbox.KeyCtrlP:
+		cliConsoleSelectCluster(cliClusterIndex - 1)
+	case termbox.KeyCtrlS:
+		cliConfirmAction("Confirm switchover on "+cliClusters[cliClusterIndex], func() {
+			res, err := cliGrpc.Switchover(context.Background(), &repmanv3.SwitchoverRequest{Cluster: cliClusters[cliClusterIndex]})
+			cliConsoleAnswer("Switchover", res, err)
+		})
+	case termbox.KeyCtrlF:
+		if cliMaster.GetState() == "Failed" {
+			cliConfirmAction("Confirm failover on "+cliClusters[cliClusterIndex], func() {
+				res, err := cliGrpc.Failover(context.Background(), &repmanv3.ClusterRequest{Cluster: cliClusters[cliClusterIndex]})
+				cliConsoleAnswer("Failover", res, err)
+			})
+		}
+	case termbox.KeyCtrlA:
+		cliConsoleSwitchSetting("failover-mode")
+	case termbox.KeyCtrlR, termbox.KeyCtrlW:
+		cliConsoleSwitchSetting("failover-readonly-state")
+	case termbox.KeyCtrlV:
+		cliConsoleSwitchSetting("verbosity")
+	case termbox.KeyCtrlE:
+		res, err := cliGrpc.ResetFailoverControl(context.Background(), &repmanv3.ClusterRequest{Cluster: cliClusters[cliClusterIndex]})
+		cliConsoleAnswer("Reset failover control", res, err)
+	case termbox.KeyF1:
+		cliConsoleHelp = true
+	}
+	switch event.Ch {
+	case 'q':
+		cliExit = true
+	case '1', '2', '3', '4', '5':
+		cliConsoleView = int(event.Ch - '1')
+	case 'n':
+		cliConsoleSelectCluster(cliClusterIndex + 1)
+	case 'p':
+		cliConsoleSelectCluster(cliClusterIndex - 1)
+	case '/':
+		cliConsoleView = cliViewLogs
+		cliConsoleFilterEdit = true
+		cliConsoleFilter = ""
+	case 'm':
+		if server != nil {
+			id, url := server.Id, server.Url
+			cliConfirmAction("Confirm maintenance toggle on "+url, func() {
+				cliConsoleServerAction(id, repmanv3.ServerActionRequest_MAINTENANCE)
+			})
+		}
+	case 't':
+		if server != nil {
+			id, url := server.Id, server.Url
+			cliConfirmAction("Confirm start slave on "+url, func() {
+				cliConsoleServerAction(id, repmanv3.ServerActionRequest_START_SLAVE)
+			})
+		}
+	case 'x':
+		if server != nil {
+			id, url := server.Id, server.Url
+			cliConfirmAction("Confirm stop slave on "+url, func() {
+				cliConsoleServerAction(id, repmanv3.ServerActionRequest_STOP_SLAVE)
+			})
+		}
+	case 's':
+		termbox.Sync()
+	case '?', 'h':
+		cliConsoleHelp = true
+	}
+}
+
+func cliConsoleSelectCluster(index int) {
+	if index >= len(cliClusters) {
+		index = 0
+	}
+	if index < 0 {
+		index = len(cliClusters) - 1
+	}
+	if index == cliClusterIndex {
+		return
+	}
+	cliClusterIndex = index
+	cliConsoleServerIndex = 0
+	// the previous cluster is not shown while the new one is fetched
+	cliServers, cliMaster, cliProxies, cliAlerts = nil, nil, nil, nil
+	cliLag = make(map[string][]int64)
+	cliConsoleFollowLogs()
+	cliConsoleFetch()
+}
+
+func cliDisplay() {
+	termbox.Clear(termbox.ColorWhite, termbox.ColorBlack)
+	width, height := termbox.Size()
+	if width == 0 {
+		width, height = 160, cliTermlength
+	}
+	headstr := " Replication Manager Client "
+	if cliClusters[cliClusterIndex] != "" {
+		headstr += fmt.Sprintf("| Group: %s", cliClusters[cliClusterIndex])
+	}
+	if cliSettings.Conf.Interactive == false {
+		headstr += " | Mode: Automatic "
+	} else {
+		headstr += " | Mode: Manual "
+	}
+	headstr += "| " + cliConsoleRefresh.Format("15:04:05") + " "
+	cliPrintTbw(0, 0, width, termbox.ColorWhite, termbox.ColorBlack|termbox.AttrReverse|termbox.AttrBold, headstr+strings.Repeat(" ", width))
+
+	x := 0
+	for i, name := range cliViewNames {
+		label := fmt.Sprintf(" %d:%s ", i+1, name)
+		if i == cliViewAlerts && len(cliAlerts.GetErrors())+len(cliAlerts.GetWarnings()) > 0 {
+			label = fmt.Sprintf(" %d:%s(%d) ", i+1, name, len(cliAlerts.GetErrors())+len(cliAlerts.GetWarnings()))
+		}
+		bg := termbox.ColorBlack
+		if i == cliConsoleView {
+			bg = termbox.ColorBlue
+		}
+		cliPrintTb(cliClusterPaneWidth+x, 1, termbox.ColorWhite|termbox.AttrBold, bg, label)
+		x += len(label) + 1
+	}
+
+	cliDisplayClusters(height - 3)
+	left := cliClusterPaneWidth + 1
+	right := width - left
+	switch {
+	case cliConsoleHelp:
+		cliDisplayHelp(left, 3, right, height-5)
+	case cliConsoleView == cliViewServers:
+		cliDisplayServers(left, 3, right, height-5)
+	case cliConsoleView == cliViewProxies:
+		cliDisplayProxies(left, 3, right, height-5)
+	case cliConsoleView == cliViewAlerts:
+		cliDisplayAlerts(left, 3, right, height-5)
+	case cliConsoleView == cliViewJobs:
+		cliDisplayJobs(left, 3, right, height-5)
+	case cliConsoleView == cliViewLogs:
+		cliDisplayLogs(left, 3, right, height-5)
+	}
+
+	if cliConfirm != "" {
+		cliPrintTbw(0, height-2, width, termbox.ColorRed, termbox.ColorBlack|termbox.AttrReverse|termbox.AttrBold, " "+cliConfirm+strings.Repeat(" ", width))
+	} else if cliConsoleFilterEdit {
+		cliPrintTbw(0, height-2, width, termbox.ColorYellow, termbox.ColorBlack, " Filter: "+cliConsoleFilter+"_")
+	} else if len(cliTlog.Buffer) > 0 {
+		// last answer of the API to an action
+		cliPrintTbw(0, height-2, width, termbox.ColorCyan, termbox.ColorBlack, strings.TrimSpace(cliTlog.Buffer[0]))
+	}
+	help := " q quit, Tab/1-5 view, n/p cluster, Up/Down server, Ctrl-S switchover, m maintenance, t/x start/stop slave, / filter, ? help"
+	if cliMaster.GetState() == "Failed" {
+		help = " q quit, Ctrl-F failover, Tab/1-5 view, n/p cluster, Up/Down server, m maintenance, t/x start/stop slave, / filter, ? help"
+	}
+	cliPrintTbw(0, height-1, width, termbox.ColorWhite, termbox.ColorBlack, help)
+	termbox.Flush()
+}
+
+func cliDisplayClusters(height int) {
+	cliPrintTbw(0, 1, cliClusterPaneWidth, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, " Clusters")
+	for i, cl := range cliClusters {
+		if i+3 >= height {
+			break
+		}
+		fg := termbox.ColorGreen
+		if cliClusterAlerts[cl] > 0 {
+			fg = termbox.ColorRed
+		}
+		bg := termbox.ColorBlack
+		pointer := " "
+		if i == cliClusterIndex {
+			pointer = ">"
+			bg = termbox.ColorBlue
+		}
+		label := fmt.Sprintf("%s%-17s", pointer, cl)
+		if cliClusterAlerts[cl] > 0 {
+			label += fmt.Sprintf(" E%d", cliClusterAlerts[cl])
+		}
+		cliPrintTbw(0, i+3, cliClusterPaneWidth-1, fg, bg, label)
+	}
+	for y := 1; y < height+2; y++ {
+		termbox.SetCell(cliClusterPaneWidth-1, y, '│', termbox.ColorWhite, termbox.ColorBlack)
+	}
+}
+
+func cliGetServerColor(state string) termbox.Attribute {
+	switch state {
+	case "Master":
+		return termbox.ColorGreen
+	case "Failed":
+		return termbox.ColorRed
+	case "Unconnected":
+		return termbox.ColorBlue
+	case "Suspect", "SlaveErr":
+		return termbox.ColorMagenta
+	case "SlaveLate":
+		return termbox.ColorYellow
+	}
+	return termbox.ColorWhite
+}
+
+func cliDisplayServers(x, y, width, height int) {
+	cliPrintfTbw(x, y, width, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, "%1s%15s %6s %15s %8s %6s %-24s %6s %3s", " ", "Host", "Port", "Status", "Failures", "GTID", "Current GTID", "Delay", "RO")
+	line := y + 1
+	for i, server := range cliServers {
+		mystatus := server.State
+		if server.VirtualMaster {
+			mystatus = mystatus + "*VM"
+		}
+		if server.Maintenance {
+			mystatus = mystatus + "*MT"
+		}
+		pointer := " "
+		if i == cliConsoleServerIndex {
+			pointer = ">"
+		}
+		cliPrintfTbw(x, line, width, cliGetServerColor(server.State), termbox.ColorBlack, "%1s%15s %6s %15s %8d %6s %-24s %6d %3s", pointer, server.Host, server.Port, mystatus, server.FailCount, cliGetUsingGtid(server), server.CurrentGtid, server.ReplicationDelay, cliOnOff(server.ReadOnly))
+		line++
+	}
+	if cliConsoleServerIndex < 0 || cliConsoleServerIndex >= len(cliServers) {
+		return
+	}
+	server := cliServers[cliConsoleServerIndex]
+	line++
+	cliPrintTbw(x, line, width, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, " Server "+server.Url+" "+server.State)
+	line++
+	var details []string
+	if server.CurrentGtid != "" {
+		details = append(details, "Current GTID  : "+server.CurrentGtid)
+	}
+	if server.SlaveGtid != "" {
+		details = append(details, "Slave GTID    : "+server.SlaveGtid)
+	}
+	details = append(details, "Health        : "+server.ReplicationHealth)
+	for _, ss := range server.Replications {
+		details = append(details, fmt.Sprintf("Source %-7s: %s:%s IO %s SQL %s Using GTID %s Behind %s", ss.ConnectionName, ss.MasterHost, ss.MasterPort, cliYesNo(ss.IoRunning), cliYesNo(ss.SqlRunning), ss.UsingGtid, cliNullInt(ss.SecondsBehindMaster >= 0, ss.SecondsBehindMaster)))
+		if ss.LastIoError != "" {
+			details = append(details, "  IO error    : "+ss.LastIoError)
+		}
+		if ss.LastSqlError != "" {
+			details = append(details, "  SQL error   : "+ss.LastSqlError)
+		}
+	}
+	lag := cliLag[server.Id]
+	if len(lag) > 0 {
+		details = append(details, fmt.Sprintf("Lag %4ds     : %s", lag[len(lag)-1], cliSparkline(lag)))
+	}
+	for _, d := range details {
+		if line >= y+height {
+			break
+		}
+		cliPrintTbw(x+1, line, width-1, termbox.ColorWhite, termbox.ColorBlack, d)
+		line++
+	}
+}
+
+func cliYesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+func cliNullInt(valid bool, v int64) string {
+	if !valid {
+		return "NULL"
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+// cliSparkline draws values scaled to the maximum of the serie
+func cliSparkline(values []int64) string {
+	var max int64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	spark := make([]rune, len(values))
+	for i, v := range values {
+		idx := 0
+		if max > 0 && v > 0 {
+			idx = int(v * int64(len(cliSparks)-1) / max)
+		}
+		spark[i] = cliSparks[idx]
+	}
+	return string(spark)
+}
+
+func cliDisplayProxies(x, y, width, height int) {
+	cliPrintfTbw(x, y, width, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, "%-12s %-22s %-10s %-3s %-24s %-12s %s", "Type", "Proxy", "State", "RW", "Backend", "Status", "Connections")
+	line := y + 1
+	if len(cliProxies) == 0 {
+		cliPrintTbw(x, line, width, termbox.ColorWhite, termbox.ColorBlack, "No proxy")
+	}
+	for _, prx := range cliProxies {
+		if line >= y+height {
+			break
+		}
+		fg := termbox.ColorGreen
+		if prx.State != "ProxyRunning" {
+			fg = termbox.ColorRed
+		}
+		cliPrintfTbw(x, line, width, fg, termbox.ColorBlack, "%-12s %-22s %-10s", prx.Type, prx.Host+":"+prx.Port, prx.State)
+		line++
+		for _, backends := range []struct {
+			rw string
+			b  []*repmanv3.Backend
+		}{{"W", prx.BackendsWrite}, {"R", prx.BackendsRead}} {
+			for _, b := range backends.b {
+				if line >= y+height {
+					break
+				}
+				fg := termbox.ColorWhite
+				if b.Maintenance {
+					fg = termbox.ColorYellow
+				}
+				cliPrintfTbw(x, line, width, fg, termbox.ColorBlack, "%-12s %-22s %-10s %-3s %-24s %-12s %s", "", "", "", backends.rw, b.Host+":"+b.Port, b.Status, b.Connections)
+				line++
+			}
+		}
+	}
+}
+
+func cliDisplayAlerts(x, y, width, height int) {
+	line := y
+	if len(cliAlerts.GetErrors())+len(cliAlerts.GetWarnings()) == 0 {
+		cliPrintTbw(x, line, width, termbox.ColorGreen, termbox.ColorBlack, "No open alert")
+		return
+	}
+	for _, a := range cliAlerts.GetErrors() {
+		if line >= y+height {
+			return
+		}
+		cliPrintfTbw(x, line, width, termbox.ColorRed, termbox.ColorBlack, "%-9s %-6s %s", a.Number, a.From, a.Desc)
+		line++
+	}
+	for _, a := range cliAlerts.GetWarnings() {
+		if line >= y+height {
+			return
+		}
+		cliPrintfTbw(x, line, width, termbox.ColorYellow, termbox.ColorBlack, "%-9s %-6s %s", a.Number, a.From, a.Desc)
+		line++
+	}
+}
+
+func cliDisplayJobs(x, y, width, height int) {
+	cliPrintfTbw(x, y, width, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, "%-30s %s", "Server", "Running jobs")
+	line := y + 1
+	var urls []string
+	for url := range cliSettings.JobResults {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		if line >= y+height {
+			return
+		}
+		jobs := cliGetRunningJobs(cliSettings.JobResults[url])
+		fg := termbox.ColorWhite
+		if jobs == "" {
+			jobs = "none"
+		} else {
+			fg = termbox.ColorYellow
+		}
+		cliPrintfTbw(x, line, width, fg, termbox.ColorBlack, "%-30s %s", url, jobs)
+		line++
+	}
+	if len(urls) == 0 {
+		cliPrintTbw(x, line, width, termbox.ColorWhite, termbox.ColorBlack, "No job reported")
+	}
+}
+
+// cliGetRunningJobs lists the jobs flagged in a job result
+func cliGetRunningJobs(res *cluster.JobResult) string {
+	if res == nil {
+		return ""
+	}
+	var flags map[string]bool
+	data, _ := json.Marshal(res)
+	json.Unmarshal(data, &flags)
+	var jobs []string
+	for name, running := range flags {
+		if running {
+			jobs = append(jobs, name)
+		}
+	}
+	sort.Strings(jobs)
+	return strings.Join(jobs, ", ")
+}
+
+func cliDisplayLogs(x, y, width, height int) {
+	logs := cliFilterLogs(cliGetLogs(), cliConsoleFilter)
+	title := " Logs"
+	if cliConsoleFilter != "" {
+		title += fmt.Sprintf(" matching %q (%d)", cliConsoleFilter, len(logs))
+	}
+	cliPrintTbw(x, y, width, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, title)
+	if len(logs) > height-1 {
+		logs = logs[len(logs)-height+1:]
+	}
+	for i, l := range logs {
+		fg := termbox.ColorWhite
+		if strings.Contains(l, "ERROR") {
+			fg = termbox.ColorRed
+		} else if strings.Contains(l, "WARN") {
+			fg = termbox.ColorYellow
+		}
+		cliPrintTbw(x, y+1+i, width, fg, termbox.ColorBlack, l)
+	}
+}
+
+// cliFilterLogs returns the lines containing filter case insensitively
+func cliFilterLogs(logs []string, filter string) []string {
+	if filter == "" {
+		return logs
+	}
+	var res []string
+	lfilter := strings.ToLower(filter)
+	for _, l := range logs {
+		if strings.Contains(strings.ToLower(l), lfilter) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func cliDisplayHelp(x, y, width, height int) {
+	cliPrintTbw(x, y, width, termbox.ColorWhite|termbox.AttrBold, termbox.ColorBlack, " Help")
+	for i, line := range cliHelpLines {
+		if i+1 >= height {
+			return
+		}
+		cliPrintTbw(x+1, y+1+i, width-1, termbox.ColorWhite, termbox.ColorBlack, line)
+	}
+}
+
+// cliPrintTbw prints msg truncated to width cells
+func cliPrintTbw(x, y, width int, fg, bg termbox.Attribute, msg string) {
+	for _, c := range msg {
+		if width <= 0 {
+			return
+		}
+		termbox.SetCell(x, y, c, fg, bg)
+		x++
+		width--
+	}
+}
+
+func cliPrintfTbw(x, y, width int, fg, bg termbox.Attribute, format string, args ...interface{}) {
+	cliPrintTbw(x, y, width, fg, bg, fmt.Sprintf(format, args...))
+}
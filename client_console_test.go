@@ -0,0 +1,60 @@
+//go:build clients
+// +build clients
+
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCliFilterLogs(t *testing.T) {
+	logs := []string{"2023/01/01 INFO : Master db1 is up", "2023/01/01 ERROR : Master db1 failed", "2023/01/01 WARN : Slave db2 delayed"}
+	for filter, want := range map[string]string{
+		"":       strings.Join(logs, "|"),
+		"master": logs[0] + "|" + logs[1],
+		"ERROR":  logs[1],
+		"Db2":    logs[2],
+		"db3":    "",
+	} {
+		if got := strings.Join(cliFilterLogs(logs, filter), "|"); got != want {
+			t.Errorf("Filter %q expected %q got %q", filter, want, got)
+		}
+	}
+}
+
+func TestCliSparkline(t *testing.T) {
+	for _, c := range []struct {
+		values []int64
+		spark  string
+	}{
+		{nil, ""},
+		{[]int64{0, 0, 0}, "▁▁▁"},
+		{[]int64{0, 7, 14}, "▁▄█"},
+		{[]int64{1, 2, 3, 4, 5, 6, 7}, "▂▃▄▅▆▇█"},
+		{[]int64{-1, 0, 10}, "▁▁█"},
+	} {
+		if s := cliSparkline(c.values); s != c.spark {
+			t.Errorf("Sparkline of %v expected %s got %s", c.values, c.spark, s)
+		}
+	}
+}
+
+func TestCliConsoleApply(t *testing.T) {
+	cliClusters = []string{"c1", "c2"}
+	cliClusterIndex = 0
+	cliConsoleFetching = true
+	cliConsoleApply(cliConsoleData{cluster: "c2", clusterAlerts: map[string]int{"c1": 1, "c2": 2}})
+	if cliConsoleFetching || cliClusterAlerts["c2"] != 2 || cliConsoleRefresh.Unix() > 0 {
+		t.Errorf("Expected the alert counts only from the state of another cluster")
+	}
+}
@@ -23,8 +23,11 @@ import (
 	"github.com/signal18/replication-manager/cluster/nbc"
 	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/router/maxscale"
+	"github.com/signal18/replication-manager/utils/backupstore"
+	"github.com/signal18/replication-manager/utils/binlogserver"
 	"github.com/signal18/replication-manager/utils/cron"
 	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/hooks"
 	"github.com/signal18/replication-manager/utils/s18log"
 	"github.com/signal18/replication-manager/utils/state"
 	log "github.com/sirupsen/logrus"
@@ -115,6 +118,8 @@ type Cluster struct {
 	exitMsg                       string                      `json:"-"`
 	exit                          bool                        `json:"-"`
 	canFlashBack                  bool                        `json:"-"`
+	inWsrepBootstrap              bool                        `json:"-"`
+	wsrepBootstrapMutex           sync.Mutex                  `json:"-"`
 	failoverCond                  *nbc.NonBlockingChan        `json:"-"`
 	switchoverCond                *nbc.NonBlockingChan        `json:"-"`
 	rejoinCond                    *nbc.NonBlockingChan        `json:"-"`
@@ -142,6 +147,7 @@ type Cluster struct {
 	Schedule                      map[string]cron.Entry       `json:"-"`
 	scheduler                     *cron.Cron                  `json:"-"`
 	idSchedulerPhysicalBackup     cron.EntryID                `json:"-"`
+	idSchedulerIncrementalBackup  cron.EntryID                `json:"-"`
 	idSchedulerLogicalBackup      cron.EntryID                `json:"-"`
 	idSchedulerOptimize           cron.EntryID                `json:"-"`
 	idSchedulerErrorLogs          cron.EntryID                `json:"-"`
@@ -150,6 +156,24 @@ type Cluster struct {
 	idSchedulerRollingRestart     cron.EntryID                `json:"-"`
 	idSchedulerDbsjobsSsh         cron.EntryID                `json:"-"`
 	idSchedulerRollingReprov      cron.EntryID                `json:"-"`
+	idSchedulerChaos              cron.EntryID                `json:"-"`
+	chaosReports                  []ChaosReport               `json:"-"`
+	chaosRunning                  bool                        `json:"-"`
+	chaosLastId                   int64                       `json:"-"`
+	chaosMutex                    sync.Mutex                  `json:"-"`
+	idSchedulerChecksum           cron.EntryID                `json:"-"`
+	checksumReports               []ChecksumReport            `json:"-"`
+	checksumRunning               bool                        `json:"-"`
+	checksumMutex                 sync.Mutex                  `json:"-"`
+	backupStore                   backupstore.Store           `json:"-"`
+	backupStoreMutex              sync.Mutex                  `json:"-"`
+	backupChainMutex              sync.Mutex                  `json:"-"`
+	testLogs                      []string                    `json:"-"`
+	testLogMutex                  sync.Mutex                  `json:"-"`
+	events                        eventStream                 `json:"-"`
+	hooks                         *hooks.Manager              `json:"-"`
+	binlogServer                  *binlogserver.BinlogServer  `json:"-"`
+	binlogServerMutex             sync.Mutex                  `json:"-"`
 	WaitingRejoin                 int                         `json:"waitingRejoin"`
 	WaitingSwitchover             int                         `json:"waitingSwitchover"`
 	WaitingFailover               int                         `json:"waitingFailover"`
@@ -234,6 +258,10 @@ const (
 	ConstMonitorActif   string = "A"
 	ConstMonitorStandby string = "S"
 )
+const (
+	maxErrantTrxEvents int    = 100
+	maxErrantTrxInject uint64 = 10000
+)
 
 // Init initial cluster definition
 func (cluster *Cluster) Init(conf config.Config, cfgGroup string, tlog *s18log.TermLog, log *s18log.HttpLog, termlength int, runUUID string, repmgrVersion string, repmgrHostname string, key []byte) error {
@@ -279,6 +307,7 @@ func (cluster *Cluster) Init(conf config.Config, cfgGroup string, tlog *s18log.T
 	cluster.sme = new(state.StateMachine)
 	cluster.sme.Init()
 	cluster.Conf = conf
+	cluster.initHooks()
 	if cluster.Conf.Interactive {
 		cluster.LogPrintf(LvlInfo, "Failover in interactive mode")
 	} else {
@@ -325,6 +354,7 @@ func (cluster *Cluster) Init(conf config.Config, cfgGroup string, tlog *s18log.T
 	// createKeys do nothing yet
 	cluster.createKeys()
 	cluster.GetPersitentState()
+	cluster.loadChecksumReports()
 
 	cluster.newServerList()
 	err = cluster.newProxyList()
@@ -372,6 +402,7 @@ func (cluster *Cluster) initScheduler() {
 		cluster.SetSchedulerBackupLogical()
 		cluster.SetSchedulerLogsTableRotate()
 		cluster.SetSchedulerBackupPhysical()
+		cluster.SetSchedulerBackupIncremental()
 		cluster.SetSchedulerBackupLogs()
 		cluster.SetSchedulerOptimize()
 		cluster.SetSchedulerRollingRestart()
@@ -379,6 +410,8 @@ func (cluster *Cluster) initScheduler() {
 		cluster.SetSchedulerSlaRotate()
 		cluster.SetSchedulerRollingRestart()
 		cluster.SetSchedulerDbJobsSsh()
+		cluster.SetSchedulerChaos()
+		cluster.SetSchedulerChecksum()
 		cluster.scheduler.Start()
 	}
 
@@ -443,11 +476,14 @@ func (cluster *Cluster) Run() {
 						cluster.initOrchetratorNodes()
 						cluster.MonitorQueryRules()
 						cluster.MonitorVariablesDiff()
+						cluster.MonitorConfigDrift()
 						cluster.ResticFetchRepo()
-
+						cluster.CheckChecksumDivergence()
 					} else {
+						cluster.sme.PreserveState("WARN0104")
 						cluster.sme.PreserveState("WARN0093")
 						cluster.sme.PreserveState("WARN0084")
+						cluster.sme.PreserveState("WARN0108")
 						cluster.sme.PreserveState("WARN0095")
 						cluster.sme.PreserveState("ERR00082")
 					}
@@ -459,6 +495,7 @@ func (cluster *Cluster) Run() {
 				}
 
 				wg.Wait()
+				cluster.MonitorBinlogServer()
 
 				cluster.IsFailable = cluster.GetStatus()
 				// CheckFailed trigger failover code if passing all false positiv and constraints
@@ -487,9 +524,9 @@ func (cluster *Cluster) StateProcessing() {
 				cluster.LogPrintf(LvlInfo, "Sending master physical backup to reseed %s", s.ServerUrl)
 				if master != nil {
 					if mybcksrv != nil {
-						go cluster.SSTRunSender(mybcksrv.GetMyBackupDirectory()+cluster.Conf.BackupPhysicalType+".xbtream", servertoreseed)
+						go cluster.SSTRunSenderChain(mybcksrv, servertoreseed)
 					} else {
-						go cluster.SSTRunSender(master.GetMasterBackupDirectory()+cluster.Conf.BackupPhysicalType+".xbtream", servertoreseed)
+						go cluster.SSTRunSenderChain(master, servertoreseed)
 					}
 				} else {
 					cluster.LogPrintf(LvlErr, "No master cancel backup reseeding %s", s.ServerUrl)
@@ -499,9 +536,9 @@ func (cluster *Cluster) StateProcessing() {
 				cluster.LogPrintf(LvlInfo, "Sending master logical backup to reseed %s", s.ServerUrl)
 				if master != nil {
 					if mybcksrv != nil {
-						go cluster.SSTRunSender(mybcksrv.GetMyBackupDirectory()+"mysqldump.sql.gz", servertoreseed)
+						go cluster.SSTRunSender(mybcksrv.GetMyBackupKey("mysqldump.sql.gz"), servertoreseed)
 					} else {
-						go cluster.SSTRunSender(master.GetMasterBackupDirectory()+"mysqldump.sql.gz", servertoreseed)
+						go cluster.SSTRunSender(master.GetMasterBackupKey("mysqldump.sql.gz"), servertoreseed)
 					}
 				} else {
 					cluster.LogPrintf(LvlErr, "No master cancel backup reseeding %s", s.ServerUrl)
@@ -510,18 +547,18 @@ func (cluster *Cluster) StateProcessing() {
 			if s.ErrKey == "WARN0076" {
 				cluster.LogPrintf(LvlInfo, "Sending server physical backup to flashback reseed %s", s.ServerUrl)
 				if mybcksrv != nil {
-					go cluster.SSTRunSender(mybcksrv.GetMyBackupDirectory()+cluster.Conf.BackupPhysicalType+".xbtream", servertoreseed)
+					go cluster.SSTRunSenderChain(mybcksrv, servertoreseed)
 				} else {
-					go cluster.SSTRunSender(servertoreseed.GetMyBackupDirectory()+cluster.Conf.BackupPhysicalType+".xbtream", servertoreseed)
+					go cluster.SSTRunSenderChain(servertoreseed, servertoreseed)
 				}
 			}
 			if s.ErrKey == "WARN0077" {
 
 				cluster.LogPrintf(LvlInfo, "Sending logical backup to flashback reseed %s", s.ServerUrl)
 				if mybcksrv != nil {
-					go cluster.SSTRunSender(mybcksrv.GetMyBackupDirectory()+"mysqldump.sql.gz", servertoreseed)
+					go cluster.SSTRunSender(mybcksrv.GetMyBackupKey("mysqldump.sql.gz"), servertoreseed)
 				} else {
-					go cluster.SSTRunSender(servertoreseed.GetMyBackupDirectory()+"mysqldump.sql.gz", servertoreseed)
+					go cluster.SSTRunSender(servertoreseed.GetMyBackupKey("mysqldump.sql.gz"), servertoreseed)
 				}
 			}
 			//		cluster.statecloseChan <- s
@@ -530,6 +567,12 @@ func (cluster *Cluster) StateProcessing() {
 		for i := range states {
 			cluster.LogPrintf("STATE", states[i])
 		}
+		for _, s := range cstates {
+			cluster.publishStateEvent(EventAlertResolve, s)
+		}
+		for _, s := range cluster.sme.GetNewStates() {
+			cluster.publishStateEvent(EventAlertOpen, s)
+		}
 		// trigger action on resolving states
 		ostates := cluster.sme.GetOpenStates()
 		for _, s := range ostates {
@@ -546,6 +589,7 @@ func (cluster *Cluster) StateProcessing() {
 func (cluster *Cluster) Stop() {
 	//	cluster.scheduler.Stop()
 	cluster.Save()
+	cluster.StopBinlogServer()
 	cluster.exit = true
 
 }
@@ -616,6 +660,7 @@ func (cluster *Cluster) InitAgent(conf config.Config) {
 
 func (cluster *Cluster) ReloadConfig(conf config.Config) {
 	cluster.Conf = conf
+	cluster.initHooks()
 	cluster.sme.SetFailoverState()
 	cluster.newServerList()
 	cluster.newProxyList()
@@ -180,6 +180,9 @@ func (cluster *Cluster) IsURLPassDatabasesACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "actions/skip-replication-event") {
 			return true
 		}
+		if strings.Contains(URL, "actions/delayed/") || strings.Contains(URL, "/delayed-stop") {
+			return true
+		}
 		if strings.Contains(URL, "actions/reset-master") {
 			return true
 		}
@@ -244,6 +247,12 @@ func (cluster *Cluster) IsURLPassDatabasesACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/digest-statements-slow") {
 			return true
 		}
+		if strings.Contains(URL, "/digest-history/") {
+			return true
+		}
+		if strings.Contains(URL, "/index-advisor") {
+			return true
+		}
 		if strings.Contains(URL, "/actions/toogle-sql-error-log") {
 			return true
 		}
@@ -288,6 +297,9 @@ func (cluster *Cluster) IsURLPassDatabasesACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/actions/toogle-slow-query-capture") {
 			return true
 		}
+		if strings.Contains(URL, "/actions/workload-") {
+			return true
+		}
 	}
 	if cluster.APIUsers[strUser].Grants[config.GrantDBMaintenance] {
 		if strings.Contains(URL, "/actions/optimize") {
@@ -319,6 +331,14 @@ func (cluster *Cluster) IsURLPassDatabasesACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/variables") {
 			return true
 		}
+		if strings.HasSuffix(URL, "/config-drift") {
+			return true
+		}
+	}
+	if cluster.APIUsers[strUser].Grants[config.GrantDBConfigFlag] {
+		if strings.Contains(URL, "/actions/config-drift-remediate") {
+			return true
+		}
 	}
 	if cluster.APIUsers[strUser].Grants[config.GrantDBShowSchema] {
 		if strings.Contains(URL, "/tables") {
@@ -341,6 +361,9 @@ func (cluster *Cluster) IsURLPassDatabasesACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/status-delta") {
 			return true
 		}
+		if strings.Contains(URL, "/capacity") {
+			return true
+		}
 	}
 	cluster.LogPrintf(LvlInfo, "ACL check failed for user %s : %s ", strUser, URL)
 	return false
@@ -385,6 +408,8 @@ func (cluster *Cluster) IsURLPassACL(strUser string, URL string) bool {
 		return true
 	case "/api/clusters/" + cluster.Name:
 		return true
+	case "/api/clusters/" + cluster.Name + "/stream":
+		return true
 	}
 	if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/servers") {
 		return cluster.IsURLPassDatabasesACL(strUser, URL)
@@ -404,6 +429,19 @@ func (cluster *Cluster) IsURLPassACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/backups") {
 			return true
 		}
+		if URL == "/api/clusters/"+cluster.Name+"/binlog-server" {
+			return true
+		}
+	}
+	if cluster.APIUsers[strUser].Grants[config.GrantDBShowVariables] {
+		if URL == "/api/clusters/"+cluster.Name+"/config-drift" {
+			return true
+		}
+	}
+	if cluster.APIUsers[strUser].Grants[config.GrantDBCapture] {
+		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/workloads") {
+			return true
+		}
 	}
 	if cluster.APIUsers[strUser].Grants[config.GrantClusterShowRoutes] {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/queryrules") {
@@ -461,6 +499,9 @@ func (cluster *Cluster) IsURLPassACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/sysbench") {
 			return true
 		}
+		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/tests/") {
+			return true
+		}
 	}
 	if cluster.APIUsers[strUser].Grants[config.GrantClusterFailover] {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/failover") {
@@ -475,6 +516,9 @@ func (cluster *Cluster) IsURLPassACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/replication/cleanup") {
 			return true
 		}
+		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/replication/wsrep") {
+			return true
+		}
 	}
 	if cluster.APIUsers[strUser].Grants[config.GrantClusterRolling] {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/optimize") {
@@ -526,11 +570,17 @@ func (cluster *Cluster) IsURLPassACL(strUser string, URL string) bool {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/reset-failover-control") {
 			return true
 		}
+		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/reset-failover-control") {
+			return true
+		}
 	}
 	if cluster.APIUsers[strUser].Grants[config.GrantClusterChecksum] {
 		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/checksum-all-tables") {
 			return true
 		}
+		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/checksums") {
+			return true
+		}
 	}
 
 	if cluster.APIUsers[strUser].Grants[config.GrantProvCluster] {
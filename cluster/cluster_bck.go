@@ -19,6 +19,7 @@ import (
 	"sync"
 
 	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/backupstore"
 	"github.com/signal18/replication-manager/utils/state"
 )
 
@@ -181,3 +182,52 @@ func (cluster *Cluster) ResticFetchRepo() error {
 
 	return nil
 }
+
+// GetBackupStore returns the store of the backups and archived binlogs, the bucket
+// of backup streaming or the backups directory of the working directory
+func (cluster *Cluster) GetBackupStore() (backupstore.Store, error) {
+	cluster.backupStoreMutex.Lock()
+	defer cluster.backupStoreMutex.Unlock()
+	if cluster.backupStore != nil {
+		return cluster.backupStore, nil
+	}
+	conf := backupstore.Config{
+		Type: backupstore.ConstStoreLocal,
+		Dir:  cluster.Conf.WorkingDir + "/" + config.ConstStreamingSubDir,
+	}
+	if cluster.Conf.BackupStreaming {
+		conf = backupstore.Config{
+			Type:         cluster.Conf.BackupStreamingType,
+			Bucket:       cluster.Conf.BackupStreamingBucket,
+			Prefix:       cluster.Conf.BackupStreamingPrefix,
+			Endpoint:     cluster.Conf.BackupStreamingEndpoint,
+			Region:       cluster.Conf.BackupStreamingRegion,
+			AccessKey:    cluster.Conf.BackupStreamingAwsAccessKeyId,
+			SecretKey:    cluster.Conf.BackupStreamingAwsAccessSecret,
+			Encryption:   cluster.Conf.BackupStreamingEncryption,
+			KMSKeyID:     cluster.Conf.BackupStreamingKmsKeyId,
+			SseCKey:      cluster.Conf.BackupStreamingSseCKey,
+			StorageClass: cluster.Conf.BackupStreamingStorageClass,
+			PartSize:     int64(cluster.Conf.BackupStreamingPartSize) * 1024 * 1024,
+			Debug:        cluster.Conf.BackupStreamingDebug,
+		}
+	}
+	store, err := backupstore.New(conf)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Backup store %s %s failed: %s", conf.Type, conf.Bucket, err)
+		return nil, err
+	}
+	// multipart uploads of a previous run that did not complete are still billed
+	if err := store.Expire(); err != nil {
+		cluster.LogPrintf(LvlWarn, "Backup store %s expiring uploads failed: %s", store.Name(), err)
+	}
+	cluster.LogPrintf(LvlInfo, "Backup store %s", store.Name())
+	cluster.backupStore = store
+	return store, nil
+}
+
+// IsBackupStoreLocal is true when the backups directory is the store, otherwise it
+// only stages the files of the tools that can not stream
+func (cluster *Cluster) IsBackupStoreLocal() bool {
+	return !cluster.Conf.BackupStreaming
+}
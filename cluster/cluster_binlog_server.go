@@ -0,0 +1,184 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/signal18/replication-manager/utils/binlogserver"
+	"github.com/signal18/replication-manager/utils/hooks"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// GetBinlogServerStatus returns the state of the native binlog server, nil when
+// it is not running
+func (cluster *Cluster) GetBinlogServerStatus() *binlogserver.Status {
+	cluster.binlogServerMutex.Lock()
+	defer cluster.binlogServerMutex.Unlock()
+	if cluster.binlogServer == nil {
+		return nil
+	}
+	st := cluster.binlogServer.Status()
+	return &st
+}
+
+// startBinlogServer listens on binlog-server-port shifted by clusterPortStride when
+// another cluster already uses it
+func (cluster *Cluster) startBinlogServer() error {
+	conf := binlogserver.Config{
+		Dir:            cluster.Conf.WorkingDir + "/" + cluster.Name + "/binlog-server",
+		Bind:           cluster.Conf.BinlogServerBind,
+		Port:           cluster.reservePorts("binlog-server", cluster.Conf.BinlogServerPort)[0],
+		ServerID:       uint32(cluster.Conf.BinlogServerId),
+		User:           cluster.rplUser,
+		Password:       cluster.rplPass,
+		KeepDays:       cluster.Conf.BinlogServerKeepDays,
+		LocalKeepFiles: cluster.Conf.BinlogServerLocalKeepFiles,
+	}
+	if cluster.Conf.BinlogServerArchive {
+		store, err := cluster.GetBackupStore()
+		if err != nil {
+			return err
+		}
+		conf.Archive = store
+	}
+	b, err := binlogserver.New(conf)
+	if err != nil {
+		return err
+	}
+	if err := b.Start(); err != nil {
+		b.Close()
+		return err
+	}
+	cluster.binlogServer = b
+	cluster.LogPrintf(LvlInfo, "Binlog server listening on %s", b.Addr())
+	return nil
+}
+
+// StopBinlogServer stops the native binlog server, the binary logs are kept
+func (cluster *Cluster) StopBinlogServer() {
+	cluster.binlogServerMutex.Lock()
+	defer cluster.binlogServerMutex.Unlock()
+	if cluster.binlogServer != nil {
+		cluster.binlogServer.Close()
+		cluster.binlogServer = nil
+		cluster.LogPrintf(LvlInfo, "Binlog server stopped")
+	}
+}
+
+// MonitorBinlogServer starts or stops the native binlog server on the
+// configuration and follows the master when it changes outside of a failover
+func (cluster *Cluster) MonitorBinlogServer() {
+	if !cluster.Conf.BinlogServerNative {
+		cluster.StopBinlogServer()
+		return
+	}
+	cluster.binlogServerMutex.Lock()
+	if cluster.binlogServer == nil {
+		if err := cluster.startBinlogServer(); err != nil {
+			cluster.binlogServerMutex.Unlock()
+			cluster.LogPrintf(LvlErr, "Could not start binlog server: %s", err)
+			return
+		}
+	}
+	if cluster.sme.GetHeartbeats()%3600 == 0 {
+		if err := cluster.binlogServer.Purge(); err != nil {
+			cluster.LogPrintf(LvlErr, "Could not purge binlog server: %s", err)
+		}
+	}
+	cluster.binlogServerMutex.Unlock()
+	master := cluster.GetMaster()
+	if cluster.IsInFailover() || master == nil {
+		return
+	}
+	if err := cluster.followBinlogServer(master); err != nil {
+		cluster.SetState("WARN0109", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0109"], master.URL, err), ErrFrom: "MON", ServerUrl: master.URL})
+	}
+}
+
+// followBinlogServer points the relay of the binlog server to the master, it
+// returns the error of the relay
+func (cluster *Cluster) followBinlogServer(master *ServerMonitor) error {
+	cluster.binlogServerMutex.Lock()
+	defer cluster.binlogServerMutex.Unlock()
+	if cluster.binlogServer == nil {
+		return nil
+	}
+	if master == nil || master.IsDown() {
+		return errors.New("Master is down")
+	}
+	port, _ := strconv.Atoi(master.Port)
+	src, ok := cluster.binlogServer.Source()
+	if !ok || src.Host != master.Host || int(src.Port) != port {
+		src = binlogserver.Source{Host: master.Host, Port: uint16(port), User: cluster.rplUser, Password: cluster.rplPass, Flavor: mysql.MySQLFlavor}
+		if master.DBVersion != nil && master.DBVersion.IsMariaDB() {
+			src.Flavor = mysql.MariaDBFlavor
+		}
+		src.StartGTID = cluster.getBinlogServerStartGTID(master, src.Flavor)
+		cluster.LogPrintf(LvlInfo, "Binlog server following master %s from %s, %s when empty", master.URL, cluster.binlogServer.Executed(), src.StartGTID)
+		cluster.binlogServer.Follow(src)
+		return nil
+	}
+	if st := cluster.binlogServer.Status(); st.Error != "" {
+		return errors.New(st.Error)
+	}
+	return nil
+}
+
+// getBinlogServerStartGTID returns the position the relay starts from when its store is
+// empty. The replicas connect to the binlog server with their own position, so it is the
+// position of the replica the most behind, the master binary logs must still hold it.
+// Without replica position or when the replicas diverged no position is contained by all
+// of them and the relay starts from the current position of the master.
+func (cluster *Cluster) getBinlogServerStartGTID(master *ServerMonitor, flavor string) string {
+	start, variable := master.Variables["GTID_EXECUTED"], "GTID_EXECUTED"
+	if flavor == mysql.MariaDBFlavor {
+		start, variable = master.Variables["GTID_BINLOG_POS"], "GTID_SLAVE_POS"
+	}
+	var sets []mysql.GTIDSet
+	for _, s := range cluster.slaves {
+		if s.IsDown() {
+			continue
+		}
+		set, err := mysql.ParseGTIDSet(flavor, s.Variables[variable])
+		if err != nil || set.String() == "" {
+			continue
+		}
+		sets = append(sets, set)
+	}
+	for _, set := range sets {
+		oldest := true
+		for _, other := range sets {
+			if !other.Contain(set) {
+				oldest = false
+				break
+			}
+		}
+		if oldest {
+			return set.String()
+		}
+	}
+	if len(sets) > 0 {
+		cluster.LogPrintf(LvlWarn, "Binlog server replicas positions diverged, starting from master position %s", start)
+	}
+	return start
+}
+
+// binlogServerHook follows the new master as soon as it is promoted
+func (cluster *Cluster) binlogServerHook(ctx context.Context, p *hooks.Payload) error {
+	if p.NewMaster == nil {
+		return nil
+	}
+	return cluster.followBinlogServer(cluster.GetServerFromURL(p.NewMaster.URL))
+}
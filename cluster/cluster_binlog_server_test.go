@@ -0,0 +1,66 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/signal18/replication-manager/utils/mysqlsim"
+)
+
+func TestBinlogServerStartGTID(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMariaDB, 2, nil)
+	master, s1, s2 := sc.topo.Servers[0], sc.topo.Servers[1], sc.topo.Servers[2]
+	master.Write(3)
+	sc.topo.Partition(master, s2)
+	master.Write(2)
+	sc.ticks(2)
+	m := sc.cluster.GetMaster()
+	if m == nil {
+		t.Fatal("No master found")
+	}
+	// the relay starts from the replica the most behind
+	if g := sc.cluster.getBinlogServerStartGTID(m, mysql.MariaDBFlavor); g != s2.GetVariable("GTID_SLAVE_POS") || g == master.GetVariable("GTID_BINLOG_POS") {
+		t.Errorf("Expected start from %s got %s", s2.GetVariable("GTID_SLAVE_POS"), g)
+	}
+	// diverged replicas start from the master
+	sc.getServer(s1).Variables["GTID_SLAVE_POS"] = "1-1-5"
+	if g := sc.cluster.getBinlogServerStartGTID(m, mysql.MariaDBFlavor); g != master.GetVariable("GTID_BINLOG_POS") {
+		t.Errorf("Expected start from the master %s got %s", master.GetVariable("GTID_BINLOG_POS"), g)
+	}
+	for _, s := range sc.cluster.slaves {
+		s.Variables["GTID_SLAVE_POS"] = ""
+	}
+	if g := sc.cluster.getBinlogServerStartGTID(m, mysql.MariaDBFlavor); g != master.GetVariable("GTID_BINLOG_POS") {
+		t.Errorf("Expected start from the master without replica position got %s", g)
+	}
+}
+
+func TestBinlogServerPort(t *testing.T) {
+	dir := t.TempDir()
+	var addrs []string
+	for _, name := range []string{"bs1", "bs2"} {
+		c := &Cluster{Name: name}
+		c.Conf.WorkingDir = dir
+		c.Conf.BinlogServerBind = "127.0.0.1"
+		c.Conf.BinlogServerPort = 23310
+		c.Conf.BinlogServerId = 10000
+		if err := c.startBinlogServer(); err != nil {
+			t.Fatalf("Could not start binlog server of %s: %s", name, err)
+		}
+		defer c.StopBinlogServer()
+		addrs = append(addrs, c.binlogServer.Addr())
+	}
+	// the second cluster shifts the port of the default configuration
+	if addrs[0] != "127.0.0.1:23310" || addrs[1] != "127.0.0.1:23320" {
+		t.Errorf("Expected ports 23310 and 23320 got %v", addrs)
+	}
+}
@@ -0,0 +1,481 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/router/haproxy"
+	"github.com/signal18/replication-manager/router/maxscale"
+	"github.com/signal18/replication-manager/utils/misc"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+const (
+	chaosKillMaster        = "kill-master"
+	chaosStopIOThread      = "stop-io-thread"
+	chaosReplicationDelay  = "replication-delay"
+	chaosBlockProxyBackend = "block-proxy-backend"
+	chaosFillDisk          = "fill-disk"
+
+	chaosInvariantSingleMaster = "single-writable-master"
+	chaosInvariantNoDataLoss   = "no-data-loss"
+	chaosInvariantProxies      = "proxies-on-master"
+
+	chaosBenchTable = "replication_manager_schema.bench"
+	chaosFillFile   = "replication-manager-chaos-fill"
+)
+
+type ChaosInvariant struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	Desc   string `json:"desc"`
+}
+
+type ChaosFault struct {
+	Name       string           `json:"name"`
+	Target     string           `json:"target"`
+	Start      time.Time        `json:"start"`
+	End        time.Time        `json:"end"`
+	Result     string           `json:"result"`
+	Error      string           `json:"error"`
+	Invariants []ChaosInvariant `json:"invariants"`
+}
+
+// ChaosReport is the result of a chaos run, the run pass when every fault pass all invariants,
+// its result is RUNNING until the last fault is checked
+type ChaosReport struct {
+	Id     int64        `json:"id"`
+	Start  time.Time    `json:"start"`
+	End    time.Time    `json:"end"`
+	Result string       `json:"result"`
+	Error  string       `json:"error"`
+	Faults []ChaosFault `json:"faults"`
+}
+
+func (cluster *Cluster) SetSchedulerChaos() {
+	if cluster.HasSchedulerEntry("chaos") {
+		cluster.LogPrintf(LvlInfo, "Disable chaos runs")
+		cluster.scheduler.Remove(cluster.idSchedulerChaos)
+	}
+	if cluster.Conf.SchedulerChaos && cluster.Conf.Test {
+		var err error
+		cluster.LogPrintf(LvlInfo, "Schedule chaos run at: %s", cluster.Conf.SchedulerChaosCron)
+		cluster.idSchedulerChaos, err = cluster.scheduler.AddFunc(cluster.Conf.SchedulerChaosCron, func() {
+			cluster.RunChaos()
+		})
+		if err == nil {
+			cluster.Schedule["chaos"] = cluster.scheduler.Entry(cluster.idSchedulerChaos)
+		}
+	}
+}
+
+func (cluster *Cluster) GetChaosReports() []ChaosReport {
+	cluster.chaosMutex.Lock()
+	defer cluster.chaosMutex.Unlock()
+	reports := make([]ChaosReport, len(cluster.chaosReports))
+	copy(reports, cluster.chaosReports)
+	return reports
+}
+
+func (cluster *Cluster) GetChaosReport(id int64) (ChaosReport, bool) {
+	cluster.chaosMutex.Lock()
+	defer cluster.chaosMutex.Unlock()
+	for _, r := range cluster.chaosReports {
+		if r.Id == id {
+			return r, true
+		}
+	}
+	return ChaosReport{}, false
+}
+
+func (cluster *Cluster) IsChaosRunning() bool {
+	cluster.chaosMutex.Lock()
+	defer cluster.chaosMutex.Unlock()
+	return cluster.chaosRunning
+}
+
+// beginChaos records a running report, a single run is allowed at a time
+func (cluster *Cluster) beginChaos() (ChaosReport, error) {
+	report := ChaosReport{Start: time.Now(), Result: "FAIL"}
+	if !cluster.Conf.Test {
+		return report, errors.New("Chaos runs are only allowed on clusters flagged with test")
+	}
+	cluster.chaosMutex.Lock()
+	defer cluster.chaosMutex.Unlock()
+	if cluster.chaosRunning {
+		return report, errors.New("A chaos run is already in progress")
+	}
+	cluster.chaosRunning = true
+	cluster.chaosLastId++
+	report.Id = cluster.chaosLastId
+	report.Result = "RUNNING"
+	cluster.chaosReports = append(cluster.chaosReports, report)
+	if cluster.Conf.ChaosReportKeep > 0 && len(cluster.chaosReports) > cluster.Conf.ChaosReportKeep {
+		cluster.chaosReports = cluster.chaosReports[len(cluster.chaosReports)-cluster.Conf.ChaosReportKeep:]
+	}
+	return report, nil
+}
+
+func (cluster *Cluster) endChaos(report ChaosReport) {
+	cluster.chaosMutex.Lock()
+	defer cluster.chaosMutex.Unlock()
+	for i := range cluster.chaosReports {
+		if cluster.chaosReports[i].Id == report.Id {
+			cluster.chaosReports[i] = report
+		}
+	}
+	cluster.chaosRunning = false
+}
+
+// StartChaos runs the faults of chaos-faults in the background and returns the running report
+// to poll with GetChaosReport
+func (cluster *Cluster) StartChaos() (ChaosReport, error) {
+	report, err := cluster.beginChaos()
+	if err != nil {
+		return report, err
+	}
+	go cluster.runChaos(report)
+	return report, nil
+}
+
+// RunChaos injects the faults of chaos-faults one after the other and checks the cluster
+// invariants once the cluster recovered from each of them
+func (cluster *Cluster) RunChaos() (ChaosReport, error) {
+	report, err := cluster.beginChaos()
+	if err != nil {
+		return report, err
+	}
+	return cluster.runChaos(report), nil
+}
+
+func (cluster *Cluster) runChaos(report ChaosReport) ChaosReport {
+	report.Result = "FAIL"
+	defer func() {
+		cluster.endChaos(report)
+	}()
+
+	cluster.LogPrintf(LvlInfo, "Starting chaos run %d with faults %s", report.Id, cluster.Conf.ChaosFaults)
+	savedBenchmarkType := cluster.benchmarkType
+	savedInteractive := cluster.Conf.Interactive
+	defer func() {
+		cluster.SetBenchMethod(savedBenchmarkType)
+		cluster.SetInteractive(savedInteractive)
+	}()
+	cluster.SetBenchMethod("table")
+
+	if cluster.GetMaster() == nil || cluster.sme.IsInFailover() {
+		report.Error = "Cluster has no master or is in failover"
+	} else if err := cluster.InitBenchTable(); err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Result = "PASS"
+		for _, name := range strings.Split(cluster.Conf.ChaosFaults, ",") {
+			fault := cluster.runChaosFault(strings.TrimSpace(name))
+			if fault.Result != "PASS" {
+				report.Result = "FAIL"
+			}
+			report.Faults = append(report.Faults, fault)
+		}
+	}
+	report.End = time.Now()
+	cluster.LogPrintf(LvlInfo, "Chaos run %d %s in %s", report.Id, report.Result, report.End.Sub(report.Start))
+	return report
+}
+
+func (cluster *Cluster) runChaosFault(name string) ChaosFault {
+	fault := ChaosFault{Name: name, Start: time.Now(), Result: "PASS"}
+	master := cluster.GetMaster()
+	var err error
+	if master == nil {
+		err = errors.New("No master before fault")
+	} else {
+		cluster.LogPrintf(LvlInfo, "Chaos injecting fault %s", name)
+		switch name {
+		case chaosKillMaster:
+			fault.Target = master.URL
+			err = cluster.chaosKillMaster(master)
+		case chaosStopIOThread:
+			err = cluster.chaosStopIOThread(&fault)
+		case chaosReplicationDelay:
+			err = cluster.DelayAllSlaves()
+		case chaosBlockProxyBackend:
+			err = cluster.chaosBlockProxyBackend(&fault)
+		case chaosFillDisk:
+			fault.Target = master.URL
+			err = cluster.chaosFillDisk(master)
+		default:
+			err = fmt.Errorf("Unknown chaos fault %s", name)
+		}
+	}
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Chaos fault %s: %s", name, err)
+		fault.Error = err.Error()
+		fault.Result = "FAIL"
+	}
+	// give the monitor time to recover before checking
+	cluster.WaitFailoverEndState()
+	fault.Invariants = cluster.checkChaosInvariants()
+	for _, inv := range fault.Invariants {
+		if inv.Result != "PASS" {
+			fault.Result = "FAIL"
+			cluster.SetState("WARN0103", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0103"], name, inv.Name, inv.Desc), ErrFrom: "TEST"})
+		}
+	}
+	fault.End = time.Now()
+	return fault
+}
+
+func (cluster *Cluster) chaosKillMaster(master *ServerMonitor) error {
+	cluster.SetInteractive(false)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go cluster.WaitFailover(wg)
+	if err := cluster.StopDatabaseService(master); err != nil {
+		return err
+	}
+	wg.Wait()
+	if cluster.GetMaster() == nil || cluster.GetMaster().URL == master.URL {
+		return errors.New("No failover after killing master")
+	}
+	return cluster.StartDatabaseWaitRejoin(master)
+}
+
+func (cluster *Cluster) getChaosSlave() *ServerMonitor {
+	for _, s := range cluster.slaves {
+		if !s.IsDown() && !s.IsMaintenance {
+			return s
+		}
+	}
+	return nil
+}
+
+func (cluster *Cluster) chaosStopIOThread(fault *ChaosFault) error {
+	slave := cluster.getChaosSlave()
+	if slave == nil {
+		return errors.New("No slave to stop")
+	}
+	fault.Target = slave.URL
+	if _, err := slave.StopSlaveIOThread(); err != nil {
+		return err
+	}
+	if err := cluster.InitBenchTable(); err != nil {
+		return err
+	}
+	time.Sleep(recoverTime * time.Second)
+	_, err := slave.StartSlave()
+	return err
+}
+
+// chaosBlockProxyBackend takes a slave out of the backends of the proxies behind the back of
+// the monitor, the server is not put in maintenance so that the proxy routing is what is tested
+func (cluster *Cluster) chaosBlockProxyBackend(fault *ChaosFault) error {
+	slave := cluster.getChaosSlave()
+	if slave == nil {
+		return errors.New("No slave to block")
+	}
+	fault.Target = slave.URL
+	var blocked []*Proxy
+	for _, pr := range cluster.Proxies {
+		if pr == nil {
+			continue
+		}
+		if err := cluster.setChaosProxyBackend(pr, slave, true); err != nil {
+			cluster.LogPrintf(LvlErr, "Chaos can't block %s on proxy %s: %s", slave.URL, pr.Name, err)
+			continue
+		}
+		blocked = append(blocked, pr)
+	}
+	if len(blocked) == 0 {
+		return errors.New("No proxy to block the backend")
+	}
+	defer func() {
+		for _, pr := range blocked {
+			if err := cluster.setChaosProxyBackend(pr, slave, false); err != nil {
+				cluster.LogPrintf(LvlErr, "Chaos can't unblock %s on proxy %s: %s", slave.URL, pr.Name, err)
+			}
+		}
+	}()
+	// the backends are refreshed from the proxies by the monitor loop
+	time.Sleep(time.Duration(2*cluster.Conf.MonitoringTicker) * time.Second)
+	for _, pr := range blocked {
+		for _, b := range pr.BackendsRead {
+			if b.Host == slave.Host && b.Port == slave.Port && isChaosBackendOnline(b) {
+				return fmt.Errorf("Proxy %s still routes to %s", pr.Name, slave.URL)
+			}
+		}
+	}
+	err := cluster.InitBenchTable()
+	time.Sleep(recoverTime * time.Second)
+	return err
+}
+
+// setChaosProxyBackend blocks or unblocks a backend in the proxy itself, ProxySQL uses
+// OFFLINE_SOFT as the monitor brings OFFLINE_HARD slaves back
+func (cluster *Cluster) setChaosProxyBackend(pr *Proxy, server *ServerMonitor, block bool) error {
+	switch {
+	case pr.Type == config.ConstProxyHaproxy && cluster.Conf.HaproxyMode == "runtimeapi":
+		haRuntime := haproxy.Runtime{
+			Binary:   cluster.Conf.HaproxyBinaryPath,
+			SockFile: filepath.Join(pr.Datadir+"/var", "/haproxy.stats.sock"),
+			Port:     pr.Port,
+			Host:     pr.Host,
+		}
+		var err error
+		if block {
+			_, err = haRuntime.SetMaintenance(server.Id, cluster.Conf.HaproxyAPIReadBackend)
+		} else {
+			_, err = haRuntime.SetReady(server.Id, cluster.Conf.HaproxyAPIReadBackend)
+		}
+		return err
+	case pr.Type == config.ConstProxyMaxscale:
+		m := maxscale.MaxScale{Host: pr.Host, Port: pr.Port, User: pr.User, Pass: pr.Pass}
+		if err := m.Connect(); err != nil {
+			return err
+		}
+		defer m.Close()
+		if block {
+			return m.SetServer(server.MxsServerName, "maintenance")
+		}
+		return m.ClearServer(server.MxsServerName, "maintenance")
+	case pr.Type == config.ConstProxySqlproxy:
+		psql, err := connectProxysql(pr)
+		if err != nil {
+			return err
+		}
+		defer psql.Connection.Close()
+		if block {
+			err = psql.SetOfflineSoft(misc.Unbracket(server.Host), server.Port)
+		} else {
+			err = psql.SetOnline(misc.Unbracket(server.Host), server.Port)
+		}
+		if err != nil {
+			return err
+		}
+		return psql.LoadServersToRuntime()
+	}
+	return fmt.Errorf("Proxy type %s can't block a backend", pr.Type)
+}
+
+// chaosFillDisk writes chaos-fill-disk-size MB in a temporary file of the master datadir over ssh
+func (cluster *Cluster) chaosFillDisk(master *ServerMonitor) error {
+	datadir := master.Variables["DATADIR"]
+	if datadir == "" {
+		return errors.New("Unknown datadir of the master")
+	}
+	file := filepath.Join(datadir, chaosFillFile)
+	client, err := cluster.OnPremiseConnect(master)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	out, err := client.Cmd("dd if=/dev/zero of=" + file + " bs=1M count=" + strconv.Itoa(cluster.Conf.ChaosFillDiskSize)).SmartOutput()
+	if err != nil {
+		// dd fails when the disk is full, the file is kept until the writes are checked
+		cluster.LogPrintf(LvlInfo, "Chaos fill disk on %s: %s %s", master.URL, err, strings.TrimSpace(string(out)))
+	}
+	if errbench := cluster.InitBenchTable(); errbench != nil {
+		cluster.LogPrintf(LvlErr, "Chaos write with disk filled on %s: %s", master.URL, errbench)
+	}
+	if _, err := client.Cmd("rm -f " + file).SmartOutput(); err != nil {
+		return fmt.Errorf("Can't remove %s on %s: %s", file, master.Host, err)
+	}
+	// a full disk is expected to break writes, the invariants tell if the cluster recovered
+	return nil
+}
+
+func (cluster *Cluster) checkChaosInvariants() []ChaosInvariant {
+	return []ChaosInvariant{
+		cluster.checkChaosSingleMaster(),
+		cluster.checkChaosNoDataLoss(),
+		cluster.checkChaosProxies(),
+	}
+}
+
+func (cluster *Cluster) checkChaosSingleMaster() ChaosInvariant {
+	inv := ChaosInvariant{Name: chaosInvariantSingleMaster, Result: "FAIL"}
+	master := cluster.GetMaster()
+	if master == nil || master.IsDown() {
+		inv.Desc = "No master"
+		return inv
+	}
+	if master.IsReadOnly() {
+		inv.Desc = fmt.Sprintf("Master %s is read only", master.URL)
+		return inv
+	}
+	var writables []string
+	for _, s := range cluster.Servers {
+		if s.URL != master.URL && !s.IsDown() && s.IsReadWrite() {
+			writables = append(writables, s.URL)
+		}
+	}
+	if cluster.Conf.ReadOnly && len(writables) > 0 {
+		inv.Desc = fmt.Sprintf("Servers %s are writable with master %s", strings.Join(writables, ","), master.URL)
+		return inv
+	}
+	inv.Result = "PASS"
+	return inv
+}
+
+func (cluster *Cluster) checkChaosNoDataLoss() ChaosInvariant {
+	inv := ChaosInvariant{Name: chaosInvariantNoDataLoss, Result: "FAIL"}
+	if cluster.GetMaster() == nil {
+		inv.Desc = "No master"
+		return inv
+	}
+	if !cluster.CheckSlavesRunning() {
+		inv.Desc = "Replication is not running on all slaves"
+		return inv
+	}
+	if !cluster.CheckTableConsistency(chaosBenchTable) {
+		inv.Desc = fmt.Sprintf("Table %s differs between master and slaves", chaosBenchTable)
+		return inv
+	}
+	inv.Result = "PASS"
+	return inv
+}
+
+func (cluster *Cluster) checkChaosProxies() ChaosInvariant {
+	inv := ChaosInvariant{Name: chaosInvariantProxies, Result: "FAIL"}
+	master := cluster.GetMaster()
+	if master == nil {
+		inv.Desc = "No master"
+		return inv
+	}
+	var wrong []string
+	for _, prx := range cluster.Proxies {
+		if prx == nil {
+			continue
+		}
+		for _, b := range prx.BackendsWrite {
+			if isChaosBackendOnline(b) && (b.Host != master.Host || b.Port != master.Port) {
+				wrong = append(wrong, prx.Type+" "+prx.Host+":"+prx.Port+" -> "+b.Host+":"+b.Port)
+			}
+		}
+	}
+	if len(wrong) > 0 {
+		inv.Desc = fmt.Sprintf("Proxies route writes to %s", strings.Join(wrong, ","))
+		return inv
+	}
+	inv.Result = "PASS"
+	return inv
+}
+
+func isChaosBackendOnline(b Backend) bool {
+	status := strings.ToUpper(b.PrxStatus)
+	return strings.HasPrefix(status, "UP") || status == "ONLINE" || strings.Contains(status, "RUNNING")
+}
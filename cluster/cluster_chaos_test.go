@@ -0,0 +1,96 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/mysqlsim"
+)
+
+func TestChaosInvariants(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMariaDB, 2, nil)
+	sc.ticks(2)
+	if _, err := sc.cluster.RunChaos(); err == nil {
+		t.Fatal("Expected chaos run to be refused on a cluster not flagged with test")
+	}
+	if inv := sc.cluster.checkChaosSingleMaster(); inv.Result != "PASS" {
+		t.Fatalf("Expected single writable master got %s", inv.Desc)
+	}
+	sc.topo.Servers[1].SetVariable("read_only", "OFF")
+	sc.tick()
+	if inv := sc.cluster.checkChaosSingleMaster(); inv.Result != "FAIL" {
+		t.Fatal("Expected a writable slave to break the single master invariant")
+	}
+
+	master := sc.getServer(sc.topo.Servers[0])
+	slave := sc.getServer(sc.topo.Servers[1])
+	prx := new(Proxy)
+	prx.BackendsWrite = []Backend{{Host: master.Host, Port: master.Port, PrxStatus: "UP"}, {Host: slave.Host, Port: slave.Port, PrxStatus: "MAINT"}}
+	sc.cluster.Proxies = []*Proxy{prx}
+	if inv := sc.cluster.checkChaosProxies(); inv.Result != "PASS" {
+		t.Fatalf("Expected proxies on master got %s", inv.Desc)
+	}
+	prx.BackendsWrite[1].PrxStatus = "ONLINE"
+	if inv := sc.cluster.checkChaosProxies(); inv.Result != "FAIL" {
+		t.Fatal("Expected a proxy writing to a slave to break the proxy invariant")
+	}
+}
+
+func TestChaosBackgroundRun(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMariaDB, 2, func(conf *config.Config) {
+		conf.Test = true
+	})
+	// the cluster is not monitored yet, the runs end without master
+	running, err := sc.cluster.StartChaos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running.Id != 1 || running.Result != "RUNNING" {
+		t.Fatalf("Expected running report 1 got %d %s", running.Id, running.Result)
+	}
+	for i := 0; i < 100 && sc.cluster.IsChaosRunning(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	report, ok := sc.cluster.GetChaosReport(running.Id)
+	if !ok || report.Result != "FAIL" || report.Error == "" {
+		t.Fatalf("Expected run %d to fail without master, got %+v", running.Id, report)
+	}
+	if _, ok := sc.cluster.GetChaosReport(2); ok {
+		t.Error("Expected no report for a run not started")
+	}
+	sc.cluster.chaosMutex.Lock()
+	sc.cluster.chaosRunning = true
+	sc.cluster.chaosMutex.Unlock()
+	if _, err := sc.cluster.StartChaos(); err == nil {
+		t.Error("Expected a second chaos run to be refused")
+	}
+	sc.cluster.endChaos(ChaosReport{})
+	if report, err = sc.cluster.RunChaos(); err != nil || report.Id != 2 || len(sc.cluster.GetChaosReports()) != 2 {
+		t.Fatalf("Expected run 2 got %d %v", report.Id, err)
+	}
+
+	// a slave is blocked in the proxies only
+	sc.ticks(2)
+	slave := sc.getServer(sc.topo.Servers[1])
+	if err := sc.cluster.setChaosProxyBackend(&Proxy{Type: config.ConstProxySpider}, slave, true); err == nil {
+		t.Error("Expected a proxy type without backend control to be refused")
+	}
+	sc.cluster.Proxies = []*Proxy{{Type: config.ConstProxySpider}}
+	var fault ChaosFault
+	if err := sc.cluster.chaosBlockProxyBackend(&fault); err == nil || fault.Target != slave.URL {
+		t.Errorf("Expected blocking %s to fail without a proxy able to block, got %v", slave.URL, err)
+	}
+	if slave.IsMaintenance {
+		t.Error("Expected the blocked slave not to be put in maintenance")
+	}
+}
@@ -0,0 +1,842 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// The consistency checker works as pt-table-checksum: chunks of the primary key are
+// checksummed on the master with statement based binlog so that the same statement
+// replays on the slaves, the master result is then replicated in the same row and
+// the chunks where the slave result differs are diverging.
+
+const (
+	checksumTable = "replication_manager_schema.checksums"
+	// maximum time to wait for a slave to replicate the checksum of a table
+	checksumSyncTimeout = 10 * time.Minute
+	// maximum seconds the rows of a diverging chunk stay locked on the master while
+	// the slave catches up
+	checksumChunkLockTimeout = 10
+)
+
+var checksumSkipSchemas = map[string]bool{
+	"mysql":                      true,
+	"information_schema":         true,
+	"performance_schema":         true,
+	"sys":                        true,
+	"replication_manager_schema": true,
+}
+
+// ChecksumDiff is a chunk of a table diverging on a slave with the statements
+// repairing the slave from the master rows
+type ChecksumDiff struct {
+	Server    string   `json:"server"`
+	Chunk     int      `json:"chunk"`
+	Lower     string   `json:"lower"`
+	Upper     string   `json:"upper"`
+	LowerKey  []string `json:"lowerKey"`
+	UpperKey  []string `json:"upperKey"`
+	MasterCrc uint64   `json:"masterCrc,string"`
+	MasterCnt int64    `json:"masterCnt"`
+	SlaveCrc  uint64   `json:"slaveCrc,string"`
+	SlaveCnt  int64    `json:"slaveCnt"`
+	Repair    []string `json:"repair"`
+	Repaired  bool     `json:"repaired"`
+	Error     string   `json:"error"`
+}
+
+// ChecksumTable is the result of a table, OK when all slaves are consistent, ER on
+// divergence and NA when the table could not be checked
+type ChecksumTable struct {
+	Schema string         `json:"schema"`
+	Table  string         `json:"table"`
+	Chunks int            `json:"chunks"`
+	Result string         `json:"result"`
+	Error  string         `json:"error"`
+	Diffs  []ChecksumDiff `json:"diffs"`
+}
+
+type ChecksumReport struct {
+	Start  time.Time       `json:"start"`
+	End    time.Time       `json:"end"`
+	Result string          `json:"result"`
+	Error  string          `json:"error"`
+	Tables []ChecksumTable `json:"tables"`
+}
+
+type checksumChunk struct {
+	lower []string
+	upper []string
+}
+
+type checksumRow struct {
+	key    string
+	pk     []string
+	values []string
+	crc    string
+}
+
+// checksumTableDef holds the columns of a checked table, the key columns are the
+// primary key in index order
+type checksumTableDef struct {
+	schema  string
+	table   string
+	columns []dbhelper.TableColumn
+	pk      []dbhelper.TableColumn
+}
+
+func checksumQuoteName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// checksumLiteral returns the SQL literal of a value read with the text protocol,
+// nil is NULL
+func checksumLiteral(value []byte, dataType string) string {
+	if value == nil {
+		return "NULL"
+	}
+	switch strings.ToLower(dataType) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "decimal", "numeric", "float", "double", "real", "year":
+		return string(value)
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit", "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection":
+		if len(value) == 0 {
+			return "''"
+		}
+		return "0x" + hex.EncodeToString(value)
+	}
+	r := strings.NewReplacer("\\", "\\\\", "'", "''", "\x00", "\\0", "\n", "\\n", "\r", "\\r", "\x1a", "\\Z")
+	return "'" + r.Replace(string(value)) + "'"
+}
+
+// checksumPredicate compares the key with a boundary in index order, op is > or <=.
+// The comparison is expanded as the optimizer does not always range scan on row
+// constructors.
+func checksumPredicate(cols []string, values []string, op string) string {
+	var ors []string
+	for i := range cols {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, cols[j]+" = "+values[j])
+		}
+		o := strings.TrimSuffix(op, "=")
+		if i == len(cols)-1 {
+			o = op
+		}
+		ands = append(ands, cols[i]+" "+o+" "+values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+func (t *checksumTableDef) name() string {
+	return checksumQuoteName(t.schema) + "." + checksumQuoteName(t.table)
+}
+
+func (t *checksumTableDef) quote(cols []dbhelper.TableColumn) []string {
+	var names []string
+	for _, c := range cols {
+		names = append(names, checksumQuoteName(c.Name))
+	}
+	return names
+}
+
+// rowCrc is the CRC of all columns, the NULL flags make NULL and empty values differ
+func (t *checksumTableDef) rowCrc() string {
+	cols := t.quote(t.columns)
+	var nulls []string
+	for _, c := range cols {
+		nulls = append(nulls, "ISNULL("+c+")")
+	}
+	return "CRC32(CONCAT_WS('#'," + strings.Join(cols, ",") + ",CONCAT(" + strings.Join(nulls, ",") + ")))"
+}
+
+// where selects the rows of the key range (lower, upper], a nil bound is open
+func (t *checksumTableDef) where(lower []string, upper []string) string {
+	var conds []string
+	if lower != nil {
+		conds = append(conds, checksumPredicate(t.quote(t.pk), lower, ">"))
+	}
+	if upper != nil {
+		conds = append(conds, checksumPredicate(t.quote(t.pk), upper, "<="))
+	}
+	if conds == nil {
+		return "1=1"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func (t *checksumTableDef) chunkQuery(n int, c checksumChunk) string {
+	return fmt.Sprintf("REPLACE INTO %s(db, tbl, chunk, lower_boundary, upper_boundary, this_crc, this_cnt) SELECT %s, %s, %d, %s, %s, COALESCE(BIT_XOR(%s), 0), COUNT(*) FROM %s FORCE INDEX(PRIMARY) WHERE %s",
+		checksumTable, checksumLiteral([]byte(t.schema), "varchar"), checksumLiteral([]byte(t.table), "varchar"), n,
+		checksumLiteral([]byte(strings.Join(c.lower, ",")), "varchar"), checksumLiteral([]byte(strings.Join(c.upper, ",")), "varchar"),
+		t.rowCrc(), t.name(), t.where(c.lower, c.upper))
+}
+
+// repairStatements returns the statements making the slave rows of a chunk identical
+// to the master rows
+func (t *checksumTableDef) repairStatements(master []checksumRow, slave []checksumRow) []string {
+	var stmts []string
+	slaveRows := make(map[string]checksumRow)
+	for _, r := range slave {
+		slaveRows[r.key] = r
+	}
+	masterRows := make(map[string]bool)
+	for _, r := range master {
+		masterRows[r.key] = true
+		if s, ok := slaveRows[r.key]; ok && s.crc == r.crc {
+			continue
+		}
+		stmts = append(stmts, "REPLACE INTO "+t.name()+"("+strings.Join(t.quote(t.columns), ",")+") VALUES("+strings.Join(r.values, ",")+")")
+	}
+	for _, r := range slave {
+		if masterRows[r.key] {
+			continue
+		}
+		var conds []string
+		for i, c := range t.quote(t.pk) {
+			conds = append(conds, c+" = "+r.pk[i])
+		}
+		stmts = append(stmts, "DELETE FROM "+t.name()+" WHERE "+strings.Join(conds, " AND ")+" LIMIT 1")
+	}
+	return stmts
+}
+
+func (t *checksumTableDef) scanRow(rows *sqlx.Rows) (checksumRow, error) {
+	var r checksumRow
+	values := make([][]byte, len(t.columns)+1)
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return r, err
+	}
+	for i, c := range t.columns {
+		r.values = append(r.values, checksumLiteral(values[i], c.DataType))
+	}
+	for _, k := range t.pk {
+		for i, c := range t.columns {
+			if c.Name == k.Name {
+				r.pk = append(r.pk, r.values[i])
+			}
+		}
+	}
+	r.key = strings.Join(r.pk, ",")
+	r.crc = string(values[len(t.columns)])
+	return r, nil
+}
+
+// chunkRows reads the rows of a chunk with their CRC, lock reads them for update
+func (t *checksumTableDef) chunkRows(q sqlx.Queryer, c checksumChunk, lock bool) ([]checksumRow, error) {
+	var res []checksumRow
+	query := "SELECT " + strings.Join(t.quote(t.columns), ",") + "," + t.rowCrc() + " FROM " + t.name() + " FORCE INDEX(PRIMARY) WHERE " + t.where(c.lower, c.upper)
+	if lock {
+		query += " FOR UPDATE"
+	}
+	rows, err := q.Queryx(query)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := t.scanRow(rows)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// nextBoundary returns the upper key of the chunk starting after lower, false when
+// the remaining rows fit in the last chunk
+func (t *checksumTableDef) nextBoundary(conn *sql.Conn, lower []string, size int) ([]string, bool, error) {
+	cols := t.quote(t.pk)
+	query := fmt.Sprintf("SELECT %s FROM %s FORCE INDEX(PRIMARY) WHERE %s ORDER BY %s LIMIT %d,1", strings.Join(cols, ","), t.name(), t.where(lower, nil), strings.Join(cols, ","), size-1)
+	rows, err := conn.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, false, rows.Err()
+	}
+	values := make([][]byte, len(cols))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, false, err
+	}
+	var upper []string
+	for i, c := range t.pk {
+		upper = append(upper, checksumLiteral(values[i], c.DataType))
+	}
+	return upper, true, nil
+}
+
+func (cluster *Cluster) SetSchedulerChecksum() {
+	if cluster.HasSchedulerEntry("checksum") {
+		cluster.LogPrintf(LvlInfo, "Disable scheduler consistency check")
+		cluster.scheduler.Remove(cluster.idSchedulerChecksum)
+	}
+	if cluster.Conf.SchedulerChecksum {
+		var err error
+		cluster.LogPrintf(LvlInfo, "Schedule consistency check at: %s", cluster.Conf.SchedulerChecksumCron)
+		cluster.idSchedulerChecksum, err = cluster.scheduler.AddFunc(cluster.Conf.SchedulerChecksumCron, func() {
+			cluster.RunChecksum()
+		})
+		if err == nil {
+			cluster.Schedule["checksum"] = cluster.scheduler.Entry(cluster.idSchedulerChecksum)
+		}
+	}
+}
+
+func (cluster *Cluster) GetChecksumReports() []ChecksumReport {
+	cluster.checksumMutex.Lock()
+	defer cluster.checksumMutex.Unlock()
+	reports := make([]ChecksumReport, len(cluster.checksumReports))
+	copy(reports, cluster.checksumReports)
+	return reports
+}
+
+func (cluster *Cluster) IsChecksumRunning() bool {
+	cluster.checksumMutex.Lock()
+	defer cluster.checksumMutex.Unlock()
+	return cluster.checksumRunning
+}
+
+func (cluster *Cluster) addChecksumReport(report ChecksumReport) {
+	cluster.checksumMutex.Lock()
+	cluster.checksumReports = append(cluster.checksumReports, report)
+	if cluster.Conf.ChecksumHistoryKeep > 0 && len(cluster.checksumReports) > cluster.Conf.ChecksumHistoryKeep {
+		cluster.checksumReports = cluster.checksumReports[len(cluster.checksumReports)-cluster.Conf.ChecksumHistoryKeep:]
+	}
+	cluster.checksumMutex.Unlock()
+	if err := cluster.saveChecksumReports(); err != nil {
+		cluster.LogPrintf(LvlErr, "Could not save consistency check history: %s", err)
+	}
+}
+
+func (cluster *Cluster) saveChecksumReports() error {
+	saveJson, _ := json.MarshalIndent(cluster.GetChecksumReports(), "", "\t")
+	return ioutil.WriteFile(cluster.WorkingDir+"/checksums.json", saveJson, 0644)
+}
+
+func (cluster *Cluster) loadChecksumReports() {
+	file, err := ioutil.ReadFile(cluster.WorkingDir + "/checksums.json")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			cluster.LogPrintf(LvlErr, "Could not read consistency check history: %s", err)
+		}
+		return
+	}
+	var reports []ChecksumReport
+	if err := json.Unmarshal(file, &reports); err != nil {
+		cluster.LogPrintf(LvlErr, "Could not read consistency check history: %s", err)
+		return
+	}
+	cluster.checksumMutex.Lock()
+	cluster.checksumReports = reports
+	cluster.checksumMutex.Unlock()
+}
+
+// CheckChecksumDivergence raises a warning for the slaves not repaired since the
+// last consistency check
+func (cluster *Cluster) CheckChecksumDivergence() {
+	reports := cluster.GetChecksumReports()
+	if len(reports) == 0 {
+		return
+	}
+	for _, t := range reports[len(reports)-1].Tables {
+		diverging := make(map[string]int)
+		for _, d := range t.Diffs {
+			if !d.Repaired {
+				diverging[d.Server]++
+			}
+		}
+		for url, chunks := range diverging {
+			cluster.SetState("WARN0104", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0104"], t.Schema+"."+t.Table, url, chunks), ErrFrom: "CHECK", ServerUrl: url})
+		}
+	}
+}
+
+// RunChecksum checks the consistency of all tables of the master on the slaves
+func (cluster *Cluster) RunChecksum() (ChecksumReport, error) {
+	master := cluster.GetMaster()
+	if master == nil {
+		return ChecksumReport{}, errors.New("Cluster has no master")
+	}
+	var tables []dbhelper.Table
+	for _, t := range master.Tables {
+		if !checksumSkipSchemas[t.Table_schema] {
+			tables = append(tables, t)
+		}
+	}
+	return cluster.runChecksum(tables)
+}
+
+// RunChecksumTable checks the consistency of one table on the slaves
+func (cluster *Cluster) RunChecksumTable(schema string, table string) (ChecksumReport, error) {
+	return cluster.runChecksum([]dbhelper.Table{{Table_schema: schema, Table_name: table}})
+}
+
+func (cluster *Cluster) runChecksum(tables []dbhelper.Table) (ChecksumReport, error) {
+	report := ChecksumReport{Start: time.Now(), Result: "ER"}
+	cluster.checksumMutex.Lock()
+	if cluster.checksumRunning {
+		cluster.checksumMutex.Unlock()
+		return report, errors.New("A consistency check is already in progress")
+	}
+	cluster.checksumRunning = true
+	cluster.checksumMutex.Unlock()
+	defer func() {
+		cluster.checksumMutex.Lock()
+		cluster.checksumRunning = false
+		cluster.checksumMutex.Unlock()
+	}()
+
+	master := cluster.GetMaster()
+	if master == nil || cluster.sme.IsInFailover() {
+		return report, errors.New("Cluster has no master or is in failover")
+	}
+	cluster.LogPrintf(LvlInfo, "Starting consistency check of %d tables on master %s", len(tables), master.URL)
+	report.Result = "OK"
+	conn, err := cluster.initChecksumSession(master)
+	if err != nil {
+		report.Result = "ER"
+		report.Error = err.Error()
+	} else {
+		defer conn.Close()
+		for _, t := range tables {
+			res := cluster.checksumTable(master, conn, t.Table_schema, t.Table_name)
+			if res.Result == "ER" {
+				report.Result = "ER"
+			}
+			report.Tables = append(report.Tables, res)
+			if cluster.GetMaster() != master || cluster.sme.IsInFailover() {
+				report.Result = "ER"
+				report.Error = "Master changed during the consistency check"
+				break
+			}
+		}
+	}
+	report.End = time.Now()
+	cluster.LogPrintf(LvlInfo, "Consistency check %s in %s", report.Result, report.End.Sub(report.Start))
+	cluster.addChecksumReport(report)
+	return report, nil
+}
+
+// initChecksumSession returns a master session replicating the checksum statements,
+// closing the session closes its pool
+func (cluster *Cluster) initChecksumSession(master *ServerMonitor) (*sql.Conn, error) {
+	db, err := master.GetNewDBConn()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.SetMaxIdleConns(0)
+	defer db.Close()
+	for _, query := range []string{
+		"CREATE DATABASE IF NOT EXISTS replication_manager_schema",
+		"CREATE TABLE IF NOT EXISTS " + checksumTable + "(db CHAR(64) NOT NULL, tbl CHAR(64) NOT NULL, chunk INT NOT NULL, lower_boundary TEXT, upper_boundary TEXT, this_crc BIGINT UNSIGNED NOT NULL, this_cnt BIGINT NOT NULL, master_crc BIGINT UNSIGNED, master_cnt BIGINT, ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, PRIMARY KEY (db, tbl, chunk)) ENGINE=InnoDB",
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"SET SESSION binlog_format = 'STATEMENT'",
+		"SET SESSION innodb_lock_wait_timeout = 1",
+	} {
+		_, err := conn.ExecContext(context.Background(), query)
+		cluster.LogSQL(query, err, master.URL, "Checksum", LvlDbg, "Init checksum session")
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// throttleChecksum sleeps between chunks and while a slave is delayed
+func (cluster *Cluster) throttleChecksum() {
+	time.Sleep(time.Duration(cluster.Conf.ChecksumChunkSleep) * time.Millisecond)
+	for cluster.Conf.ChecksumMaxDelay > 0 && !cluster.exit {
+		delayed := false
+		for _, s := range cluster.slaves {
+			if !s.IsFailed() && s.GetReplicationDelay() > int64(cluster.Conf.ChecksumMaxDelay) {
+				delayed = true
+			}
+		}
+		if !delayed {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (cluster *Cluster) getChecksumTableDef(master *ServerMonitor, schema string, table string) (*checksumTableDef, error) {
+	t := &checksumTableDef{schema: schema, table: table}
+	columns, logs, err := dbhelper.GetTableColumns(master.Conn, schema, table)
+	cluster.LogSQL(logs, err, master.URL, "Checksum", LvlDbg, "GetTableColumns")
+	if err != nil {
+		return nil, err
+	}
+	pk, logs, err := dbhelper.GetTablePrimaryKey(master.Conn, schema, table)
+	cluster.LogSQL(logs, err, master.URL, "Checksum", LvlDbg, "GetTablePrimaryKey")
+	if err != nil {
+		return nil, err
+	}
+	if len(pk) == 0 {
+		return nil, errors.New("No primary key")
+	}
+	t.columns = columns
+	for _, k := range pk {
+		for _, c := range columns {
+			if c.Name == k {
+				t.pk = append(t.pk, c)
+			}
+		}
+	}
+	return t, nil
+}
+
+func (cluster *Cluster) setTableSync(master *ServerMonitor, schema string, table string, sync string) {
+	if t, ok := master.DictTables[schema+"."+table]; ok {
+		t.Table_sync = sync
+		master.DictTables[schema+"."+table] = t
+	}
+}
+
+func (cluster *Cluster) checksumTable(master *ServerMonitor, conn *sql.Conn, schema string, table string) ChecksumTable {
+	res := ChecksumTable{Schema: schema, Table: table, Result: "NA"}
+	cluster.LogPrintf(LvlInfo, "Checksum table %s.%s on master %s", schema, table, master.URL)
+	t, err := cluster.getChecksumTableDef(master, schema, table)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Checksum, could not check table %s.%s: %s", schema, table, err)
+		res.Error = err.Error()
+		cluster.setTableSync(master, schema, table, res.Result)
+		return res
+	}
+	ctx := context.Background()
+	size := cluster.Conf.ChecksumChunkSize
+	if size < 1 {
+		size = 1000
+	}
+	query := "DELETE FROM " + checksumTable + " WHERE db=" + checksumLiteral([]byte(schema), "varchar") + " AND tbl=" + checksumLiteral([]byte(table), "varchar")
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	var chunks []checksumChunk
+	var lower []string
+	for {
+		cluster.throttleChecksum()
+		if cluster.exit || cluster.GetMaster() != master {
+			res.Error = "Checksum interrupted"
+			return res
+		}
+		upper, more, err := t.nextBoundary(conn, lower, size)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		c := checksumChunk{lower: lower, upper: upper}
+		n := len(chunks)
+		query := t.chunkQuery(n, c)
+		_, err = conn.ExecContext(ctx, query)
+		cluster.LogSQL(query, err, master.URL, "Checksum", LvlDbg, "Checksum chunk")
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		var crc uint64
+		var cnt int64
+		query = fmt.Sprintf("SELECT this_crc, this_cnt FROM %s WHERE db=? AND tbl=? AND chunk=?", checksumTable)
+		if err := conn.QueryRowContext(ctx, query, schema, table, n).Scan(&crc, &cnt); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		query = fmt.Sprintf("UPDATE %s SET master_crc=%d, master_cnt=%d WHERE db=%s AND tbl=%s AND chunk=%d", checksumTable, crc, cnt, checksumLiteral([]byte(schema), "varchar"), checksumLiteral([]byte(table), "varchar"), n)
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		chunks = append(chunks, c)
+		if !more {
+			break
+		}
+		lower = upper
+	}
+	res.Chunks = len(chunks)
+	res.Result = "OK"
+	for _, s := range cluster.slaves {
+		if s.IsFailed() || s.IsReplicationBroken() {
+			continue
+		}
+		diffs, err := cluster.checksumSlave(master, s, t, chunks)
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Checksum table %s.%s could not be checked on %s: %s", schema, table, s.URL, err)
+			res.Error = err.Error()
+			if res.Result == "OK" {
+				res.Result = "NA"
+			}
+			continue
+		}
+		if len(diffs) > 0 {
+			cluster.LogPrintf(LvlWarn, "Checksum table %s.%s failed in %d chunks on %s", schema, table, len(diffs), s.URL)
+			res.Result = "ER"
+			res.Diffs = append(res.Diffs, diffs...)
+		} else {
+			cluster.LogPrintf(LvlInfo, "Checksum table succeed %s.%s %s", schema, table, s.URL)
+		}
+	}
+	cluster.setTableSync(master, schema, table, res.Result)
+	return res
+}
+
+// checksumSlave waits for the slave to replicate the checksum of the table and
+// returns its diverging chunks
+func (cluster *Cluster) checksumSlave(master *ServerMonitor, s *ServerMonitor, t *checksumTableDef, chunks []checksumChunk) ([]ChecksumDiff, error) {
+	var diffs []ChecksumDiff
+	start := time.Now()
+	for {
+		var done int
+		err := s.Conn.QueryRowx("SELECT COUNT(*) FROM "+checksumTable+" WHERE db=? AND tbl=? AND master_crc IS NOT NULL", t.schema, t.table).Scan(&done)
+		if err == nil && done >= len(chunks) {
+			break
+		}
+		if s.IsFailed() || s.IsReplicationBroken() || time.Since(start) > checksumSyncTimeout {
+			return diffs, errors.New("Slave did not replicate the checksum")
+		}
+		cluster.SetState("WARN0086", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0086"], s.URL), ErrFrom: "CHECK", ServerUrl: s.URL})
+		time.Sleep(time.Second)
+	}
+	rows, err := s.Conn.Queryx("SELECT chunk, lower_boundary, upper_boundary, master_crc, master_cnt, this_crc, this_cnt FROM "+checksumTable+" WHERE db=? AND tbl=? AND (this_crc <> master_crc OR this_cnt <> master_cnt) ORDER BY chunk", t.schema, t.table)
+	if err != nil {
+		return diffs, err
+	}
+	for rows.Next() {
+		d := ChecksumDiff{Server: s.URL}
+		if err := rows.Scan(&d.Chunk, &d.Lower, &d.Upper, &d.MasterCrc, &d.MasterCnt, &d.SlaveCrc, &d.SlaveCnt); err != nil {
+			rows.Close()
+			return diffs, err
+		}
+		diffs = append(diffs, d)
+	}
+	rows.Close()
+	for i := range diffs {
+		d := &diffs[i]
+		if d.Chunk >= len(chunks) {
+			continue
+		}
+		d.LowerKey = chunks[d.Chunk].lower
+		d.UpperKey = chunks[d.Chunk].upper
+		cluster.syncChecksumChunk(master, s, t, chunks[d.Chunk], d, cluster.Conf.ChecksumRepair)
+	}
+	return diffs, nil
+}
+
+// checksumSyncPoint is the position of the master a slave must apply before its rows are
+// compared to the master rows
+type checksumSyncPoint struct {
+	query string
+	args  []interface{}
+	// MASTER_POS_WAIT returns the number of events waited, the GTID waits return 0
+	events bool
+}
+
+// getChecksumSyncPoint reads the position of the master in the transaction locking a chunk,
+// it covers every change committed on the locked rows
+func (cluster *Cluster) getChecksumSyncPoint(master *ServerMonitor, tx *sqlx.Tx) (checksumSyncPoint, error) {
+	var pos string
+	switch {
+	case master.DBVersion != nil && master.DBVersion.IsMariaDB():
+		err := tx.QueryRowx("SELECT @@GLOBAL.gtid_binlog_pos").Scan(&pos)
+		return checksumSyncPoint{query: "SELECT MASTER_GTID_WAIT(?, ?)", args: []interface{}{pos, checksumChunkLockTimeout}}, err
+	case master.Variables["GTID_MODE"] == "ON":
+		err := tx.QueryRowx("SELECT @@GLOBAL.gtid_executed").Scan(&pos)
+		return checksumSyncPoint{query: "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", args: []interface{}{pos, checksumChunkLockTimeout}}, err
+	}
+	rows, err := tx.Queryx("SHOW MASTER STATUS")
+	if err != nil {
+		return checksumSyncPoint{}, err
+	}
+	defer rows.Close()
+	ms := make(map[string]interface{})
+	if !rows.Next() {
+		return checksumSyncPoint{}, errors.New("No binary log on master")
+	}
+	if err := rows.MapScan(ms); err != nil {
+		return checksumSyncPoint{}, err
+	}
+	return checksumSyncPoint{query: "SELECT MASTER_POS_WAIT(?, ?, ?)", args: []interface{}{fmt.Sprintf("%s", ms["File"]), fmt.Sprintf("%s", ms["Position"]), checksumChunkLockTimeout}, events: true}, nil
+}
+
+// reached tells if the result of the wait function means the slave applied the position,
+// a NULL result is a stopped slave
+func (p checksumSyncPoint) reached(res sql.NullInt64) bool {
+	if p.events {
+		return res.Valid && res.Int64 >= 0
+	}
+	return res.Valid && res.Int64 == 0
+}
+
+// wait returns an error when the slave did not apply the master up to the position
+func (p checksumSyncPoint) wait(s *ServerMonitor) error {
+	var res sql.NullInt64
+	if err := s.Conn.QueryRowx(p.query, p.args...).Scan(&res); err != nil {
+		return err
+	}
+	if !p.reached(res) {
+		return errors.New("Slave did not reach the master position in time")
+	}
+	return nil
+}
+
+// syncChecksumChunk compares the rows of a diverging chunk, the master rows are read for
+// update and stay locked while the slave catches up with the master and is repaired, so
+// the repair never overwrites a change replicating to the slave
+func (cluster *Cluster) syncChecksumChunk(master *ServerMonitor, s *ServerMonitor, t *checksumTableDef, c checksumChunk, d *ChecksumDiff, repair bool) {
+	tx, err := master.Conn.Beginx()
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	// the transaction only locks the rows
+	defer tx.Rollback()
+	masterRows, err := t.chunkRows(tx, c, true)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	point, err := cluster.getChecksumSyncPoint(master, tx)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	if err := point.wait(s); err != nil {
+		d.Error = err.Error()
+		return
+	}
+	slaveRows, err := t.chunkRows(s.Conn, c, false)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	d.Repair = t.repairStatements(masterRows, slaveRows)
+	d.Error = ""
+	if repair {
+		cluster.applyChecksumRepair(s, d)
+	}
+}
+
+// applyChecksumRepair runs the repair statements of a chunk on the slave without
+// writing them to its binlog
+func (cluster *Cluster) applyChecksumRepair(s *ServerMonitor, d *ChecksumDiff) {
+	ctx := context.Background()
+	conn, err := s.Conn.Conn(ctx)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	defer conn.Close()
+	// the session goes back to the pool
+	defer conn.ExecContext(ctx, "SET SESSION sql_log_bin=1")
+	for _, query := range append([]string{"SET SESSION sql_log_bin=0"}, d.Repair...) {
+		_, err := conn.ExecContext(ctx, query)
+		cluster.LogSQL(query, err, s.URL, "Checksum", LvlInfo, "Repair chunk")
+		if err != nil {
+			d.Error = err.Error()
+			return
+		}
+	}
+	d.Repaired = true
+	d.Error = ""
+	cluster.LogPrintf(LvlInfo, "Repaired chunk %d with %d statements on %s", d.Chunk, len(d.Repair), s.URL)
+}
+
+// RepairChecksum applies the repair statements of the last consistency check on the
+// diverging slaves
+func (cluster *Cluster) RepairChecksum() (ChecksumReport, error) {
+	cluster.checksumMutex.Lock()
+	if cluster.checksumRunning {
+		cluster.checksumMutex.Unlock()
+		return ChecksumReport{}, errors.New("A consistency check is in progress")
+	}
+	if len(cluster.checksumReports) == 0 {
+		cluster.checksumMutex.Unlock()
+		return ChecksumReport{}, errors.New("No consistency check report")
+	}
+	cluster.checksumRunning = true
+	report := cluster.checksumReports[len(cluster.checksumReports)-1]
+	cluster.checksumMutex.Unlock()
+
+	master := cluster.GetMaster()
+	var tables []ChecksumTable
+	for _, t := range report.Tables {
+		t.Diffs = append([]ChecksumDiff(nil), t.Diffs...)
+		var def *checksumTableDef
+		for i := range t.Diffs {
+			d := &t.Diffs[i]
+			if d.Repaired || d.Repair == nil {
+				continue
+			}
+			s := cluster.GetServerFromURL(d.Server)
+			if s == nil || s.IsFailed() {
+				d.Error = "Slave not found"
+				continue
+			}
+			if master == nil || cluster.sme.IsInFailover() {
+				d.Error = "Cluster has no master or is in failover"
+				continue
+			}
+			// the rows are compared again under lock, the slave may have changed since the check
+			if (d.LowerKey == nil && d.Lower != "") || (d.UpperKey == nil && d.Upper != "") {
+				d.Error = "No chunk boundaries in report, run a new consistency check"
+				continue
+			}
+			if def == nil {
+				var err error
+				if def, err = cluster.getChecksumTableDef(master, t.Schema, t.Table); err != nil {
+					d.Error = err.Error()
+					continue
+				}
+			}
+			cluster.syncChecksumChunk(master, s, def, checksumChunk{lower: d.LowerKey, upper: d.UpperKey}, d, true)
+		}
+		tables = append(tables, t)
+	}
+	report.Tables = tables
+
+	cluster.checksumMutex.Lock()
+	cluster.checksumReports[len(cluster.checksumReports)-1] = report
+	cluster.checksumRunning = false
+	cluster.checksumMutex.Unlock()
+	if err := cluster.saveChecksumReports(); err != nil {
+		cluster.LogPrintf(LvlErr, "Could not save consistency check history: %s", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,102 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+func TestChecksumLiteral(t *testing.T) {
+	tests := []struct {
+		value    []byte
+		dataType string
+		want     string
+	}{
+		{nil, "int", "NULL"},
+		{[]byte("42"), "bigint", "42"},
+		{[]byte("it's a\\b\n"), "varchar", `'it''s a\\b\n'`},
+		{[]byte("2021-01-01 00:00:00"), "datetime", "'2021-01-01 00:00:00'"},
+		{[]byte{0, 0xff}, "varbinary", "0x00ff"},
+		{[]byte{}, "blob", "''"},
+	}
+	for _, test := range tests {
+		if got := checksumLiteral(test.value, test.dataType); got != test.want {
+			t.Errorf("Literal of %q %s: got %s want %s", test.value, test.dataType, got, test.want)
+		}
+	}
+}
+
+func TestChecksumCompositeKey(t *testing.T) {
+	def := &checksumTableDef{
+		schema:  "db",
+		table:   "t`1",
+		columns: []dbhelper.TableColumn{{Name: "a", DataType: "int"}, {Name: "b", DataType: "varchar"}, {Name: "v", DataType: "text"}},
+		pk:      []dbhelper.TableColumn{{Name: "a", DataType: "int"}, {Name: "b", DataType: "varchar"}},
+	}
+	if got := def.name(); got != "`db`.`t``1`" {
+		t.Errorf("Unexpected table name %s", got)
+	}
+	want := "((`a` > 1) OR (`a` = 1 AND `b` > 'x')) AND ((`a` < 2) OR (`a` = 2 AND `b` <= 'y'))"
+	if got := def.where([]string{"1", "'x'"}, []string{"2", "'y'"}); got != want {
+		t.Errorf("Unexpected chunk predicate\n got %s\nwant %s", got, want)
+	}
+	if got := def.where(nil, nil); got != "1=1" {
+		t.Errorf("Unexpected open chunk predicate %s", got)
+	}
+
+	master := []checksumRow{
+		{key: "1,'x'", pk: []string{"1", "'x'"}, values: []string{"1", "'x'", "'same'"}, crc: "10"},
+		{key: "1,'y'", pk: []string{"1", "'y'"}, values: []string{"1", "'y'", "'master'"}, crc: "20"},
+		{key: "2,'x'", pk: []string{"2", "'x'"}, values: []string{"2", "'x'", "NULL"}, crc: "30"},
+	}
+	slave := []checksumRow{
+		{key: "1,'x'", pk: []string{"1", "'x'"}, values: []string{"1", "'x'", "'same'"}, crc: "10"},
+		{key: "1,'y'", pk: []string{"1", "'y'"}, values: []string{"1", "'y'", "'slave'"}, crc: "21"},
+		{key: "1,'z'", pk: []string{"1", "'z'"}, values: []string{"1", "'z'", "'extra'"}, crc: "40"},
+	}
+	stmts := def.repairStatements(master, slave)
+	expected := []string{
+		"REPLACE INTO `db`.`t``1`(`a`,`b`,`v`) VALUES(1,'y','master')",
+		"REPLACE INTO `db`.`t``1`(`a`,`b`,`v`) VALUES(2,'x',NULL)",
+		"DELETE FROM `db`.`t``1` WHERE `a` = 1 AND `b` = 'z' LIMIT 1",
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("Unexpected repair statements\n got %q\nwant %q", stmts, expected)
+	}
+}
+
+func TestChecksumSyncPoint(t *testing.T) {
+	gtid := checksumSyncPoint{query: "SELECT MASTER_GTID_WAIT(?, ?)"}
+	pos := checksumSyncPoint{query: "SELECT MASTER_POS_WAIT(?, ?, ?)", events: true}
+	tests := []struct {
+		point checksumSyncPoint
+		res   sql.NullInt64
+		want  bool
+	}{
+		{gtid, sql.NullInt64{Int64: 0, Valid: true}, true},
+		{gtid, sql.NullInt64{Int64: -1, Valid: true}, false},
+		// WAIT_FOR_EXECUTED_GTID_SET returns 1 on timeout
+		{gtid, sql.NullInt64{Int64: 1, Valid: true}, false},
+		{gtid, sql.NullInt64{}, false},
+		{pos, sql.NullInt64{Int64: 12, Valid: true}, true},
+		{pos, sql.NullInt64{Int64: 0, Valid: true}, true},
+		{pos, sql.NullInt64{Int64: -1, Valid: true}, false},
+		{pos, sql.NullInt64{}, false},
+	}
+	for _, test := range tests {
+		if got := test.point.reached(test.res); got != test.want {
+			t.Errorf("%s returning %+v: got %t want %t", test.point.query, test.res, got, test.want)
+		}
+	}
+}
@@ -266,7 +266,14 @@ func (cluster *Cluster) isMaxscaleSupectRunning() bool {
 
 func (cluster *Cluster) isFoundCandidateMaster() bool {
 
-	key := cluster.electFailoverCandidate(cluster.slaves, false)
+	key := -1
+	if cluster.GetTopology() == topoMasterSlavePgStream {
+		key = cluster.electPgStreamCandidate(cluster.slaves, false)
+	} else if cluster.GetTopology() == topoGroupReplication {
+		key = cluster.electGroupReplicationCandidate(cluster.slaves, false)
+	} else {
+		key = cluster.electFailoverCandidate(cluster.slaves, false)
+	}
 	if key == -1 {
 		cluster.sme.AddState("ERR00032", state.State{ErrType: LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00032"]), ErrFrom: "CHECK"})
 		return false
@@ -463,146 +470,6 @@ func (cluster *Cluster) CheckCapture(state state.State) {
 	}
 }
 
-func (cluster *Cluster) CheckAllTableChecksum() {
-	for _, t := range cluster.master.Tables {
-		cluster.CheckTableChecksum(t.Table_schema, t.Table_name)
-	}
-}
-
-func (cluster *Cluster) CheckTableChecksum(schema string, table string) {
-
-	cluster.LogPrintf(LvlInfo, "Checksum master table %s.%s %s", schema, table, cluster.master.URL)
-
-	Conn, err := cluster.master.GetNewDBConn()
-	if err != nil {
-		cluster.master.ClusterGroup.LogPrintf(LvlErr, "Error connection in exec query no log %s", err)
-		return
-	}
-	defer Conn.Close()
-	Conn.SetConnMaxLifetime(3595 * time.Second)
-	pk, _ := cluster.master.GetTablePK(schema, table)
-	if pk == "" {
-		cluster.master.ClusterGroup.LogPrintf(LvlErr, "Checksum, no primary key for table %s.%s", schema, table)
-		t := cluster.master.DictTables[schema+"."+table]
-		t.Table_sync = "NA"
-		cluster.master.DictTables[schema+"."+table] = t
-		return
-	}
-	if strings.Contains(pk, ",") {
-		cluster.master.ClusterGroup.LogPrintf(LvlInfo, "Checksum, composit primary key for table %s.%s", schema, table)
-	}
-	Conn.Exec("CREATE DATABASE IF NOT EXISTS replication_manager_schema")
-	Conn.Exec("USE replication_manager_schema")
-	Conn.Exec("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ")
-	Conn.Exec("SET SESSION group_concat_max_len = 1000000")
-
-	Conn.Exec("CREATE OR REPLACE TABLE replication_manager_schema.table_checksum(chunkId BIGINT,chunkMinKey VARCHAR(254),chunkMaxKey VARCHAR(254),chunkCheckSum BIGINT UNSIGNED ) ENGINE=MYISAM")
-	query := "CREATE TEMPORARY TABLE replication_manager_schema.table_chunck ENGINE=MYISAM SELECT FLOOR((@rows:=@rows+1/2000)) as chunkId, MIN(CONCAT_WS('/*;*/'," + pk + ")) as chunkMinKey, MAX(CONCAT_WS('/*;*/'," + pk + ")) as chunkMaxKey from " + schema + "." + table + " , (SELECT @rows:=0 FROM DUAL) A group by chunkId"
-	_, err = Conn.Exec(query)
-	Conn.Exec("SET SESSION binlog_format = 'STATEMENT'")
-	if err != nil {
-		cluster.LogPrintf(LvlErr, "ERROR: Could not process chunck %s %s", query, err)
-		return
-	}
-	var md5Sum string
-	err = Conn.QueryRowx("SELECT CONCAT( \"SUM(CRC32(CONCAT(\" , GROUP_CONCAT( CONCAT( \"IFNULL(\" , COLUMN_NAME, \",'N')\")),\")))\") as fields FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA ='" + schema + "' AND TABLE_NAME='" + table + "'").Scan(&md5Sum)
-	if err != nil {
-		cluster.LogPrintf(LvlErr, "ERROR: Could not get SQL md5Sum", err)
-		return
-	}
-
-	// build predicate iterating over each pk columns
-	predicate := " 1=1"
-	pks := strings.Split(pk, ",")
-	//	var ftype string
-	for i, p := range pks {
-		/*	query := "SELECT (select COLUMN_TYPE from information_schema.columns C where C.TABLE_NAME=TABLE_NAME AND C.COLUMN_NAME=COLUMN_NAME AND C.TABLE_SCHEMA=TABLE_SCHEMA LIMIT 1) as TYPE   from information_schema.KEY_COLUMN_USAGE WHERE CONSTRAINT_NAME='PRIMARY' AND CONSTRAINT_SCHEMA='" + schema + "' AND TABLE_NAME='" + table + "' AND  ORDINAL_POSITION=" + strconv.Itoa(i+1)
-			err := Conn.QueryRowx(query).Scan(&ftype)
-			if err != nil {
-				cluster.LogPrintf(LvlErr, "ERROR: Could not fetch  datatype %s %s", query, err)
-				return
-			}
-			separator := ""
-			if strings.Contains(strings.ToLower(ftype), "char") || strings.Contains(strings.ToLower(ftype), "date") || strings.Contains(strings.ToLower(ftype), "enum") || strings.Contains(strings.ToLower(ftype), "timestamp") {
-				separator = "'"
-			}*/
-		predicate = predicate + " AND A." + p + " >= SUBSTRING_INDEX(SUBSTRING_INDEX(B.chunkMinKey,'/*;*/'," + strconv.Itoa(i+1) + "),'/*;*/',-1) and A." + p + "<= SUBSTRING_INDEX(SUBSTRING_INDEX(B.chunkMaxKey,'/*;*/'," + strconv.Itoa(i+1) + "),'/*;*/',-1)"
-	}
-
-	for true {
-		query := "INSERT INTO replication_manager_schema.table_checksum SELECT chunkId, chunkMinKey , chunkMaxKey," + md5Sum + " as chunkCheckSum FROM " + schema + "." + table + " A inner join (select * from replication_manager_schema.table_chunck limit 1) B on " + predicate
-		_, err := Conn.Exec(query)
-		if err != nil {
-			cluster.LogPrintf(LvlErr, "ERROR: Could not process chunck %s %s", query, err)
-			return
-		}
-		res, err2 := Conn.Exec("DELETE FROM replication_manager_schema.table_chunck limit 1")
-		if err2 != nil {
-			cluster.LogPrintf(LvlErr, "Checksum error deleting chunck %s", err)
-			return
-		}
-
-		i, err3 := res.RowsAffected()
-		if err3 != nil {
-			cluster.LogPrintf(LvlErr, "Checksum can't fetch rows affected ", err)
-			return
-		}
-		if i == 0 {
-			cluster.LogPrintf(LvlInfo, "Finished checksum table %s.%s", schema, table)
-			break
-		}
-		/*	slave := cluster.GetFirstWorkingSlave()
-			if slave != nil {
-				if slave.GetReplicationDelay() > 5 {
-					time.Sleep(time.Duration(slave.GetReplicationDelay()) * time.Second)
-				}
-			}*/
-	}
-	cluster.master.Refresh()
-	masterSeq := cluster.master.CurrentGtid.GetSeqServerIdNos(uint64(cluster.master.ServerID))
-	cluster.LogPrintf(LvlInfo, "Wait sync: Master sequence %d", masterSeq)
-
-	for _, s := range cluster.slaves {
-		if !s.IsFailed() && !s.IsReplicationBroken() {
-			for true {
-				slaveSeq := s.SlaveGtid.GetSeqServerIdNos(uint64(cluster.master.ServerID))
-				cluster.LogPrintf(LvlInfo, "Wait sync on slave %s sequence %d", s.URL, slaveSeq)
-				if slaveSeq >= masterSeq {
-					break
-				} else {
-					cluster.SetState("WARN0086", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["WARN0086"], s.URL), ErrFrom: "MON", ServerUrl: s.URL})
-				}
-				time.Sleep(1 * time.Second)
-			}
-
-		}
-	}
-	// check slave result
-	masterChecksums, logs, err := dbhelper.GetTableChecksumResult(cluster.master.Conn)
-	cluster.LogSQL(logs, err, cluster.master.URL, "CheckTableChecksum", LvlDbg, "GetTableChecksumResult")
-	for _, s := range cluster.slaves {
-		slaveChecksums, logs, err := dbhelper.GetTableChecksumResult(s.Conn)
-		cluster.LogSQL(logs, err, s.URL, "CheckTableChecksum", LvlDbg, "GetTableChecksumResult")
-		checkok := true
-		for _, chunk := range masterChecksums {
-			if chunk.ChunkCheckSum != slaveChecksums[chunk.ChunkId].ChunkCheckSum {
-				checkok = false
-				cluster.LogPrintf(LvlInfo, "Checksum table failed chunk(%s,%s) %s.%s %s", chunk.ChunkMinKey, chunk.ChunkMaxKey, schema, table, s.URL)
-				t := cluster.master.DictTables[schema+"."+table]
-				t.Table_sync = "ER"
-				cluster.master.DictTables[schema+"."+table] = t
-			}
-
-		}
-		if checkok {
-			cluster.LogPrintf(LvlInfo, "Checksum table succeed %s.%s %s", schema, table, s.URL)
-			t := cluster.master.DictTables[schema+"."+table]
-			t.Table_sync = "OK"
-			cluster.master.DictTables[schema+"."+table] = t
-		}
-	}
-}
-
 //CheckSameServerID Check against the servers that all server id are differents
 func (cluster *Cluster) CheckSameServerID() {
 	for _, s := range cluster.Servers {
@@ -650,9 +517,13 @@ func (cluster *Cluster) IsNotHavingMySQLErrantTransaction() bool {
 		if s.IsFailed() || s.IsIgnored() {
 			continue
 		}
-		hasErrantTrx, _, _ := dbhelper.HaveErrantTransactions(s.Conn, cluster.master.Variables["GTID_EXECUTED"], s.Variables["GTID_EXECUTED"])
-		if hasErrantTrx {
-			cluster.SetState("WARN0091", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0091"], s.URL), ErrFrom: "MON", ServerUrl: s.URL})
+		errant, err := s.GetErrantTransactions()
+		if err != nil {
+			cluster.LogPrintf(LvlDbg, "Could not check errant transactions on %s: %s", s.URL, err)
+			continue
+		}
+		if !errant.IsEmpty() {
+			cluster.SetState("WARN0091", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0091"], s.URL, errant.String()), ErrFrom: "MON", ServerUrl: s.URL})
 			return false
 		}
 	}
@@ -0,0 +1,194 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+const (
+	EventServerState  = "server-state"
+	EventAlertOpen    = "alert-open"
+	EventAlertResolve = "alert-resolve"
+	EventFailover     = "failover"
+	EventJob          = "job"
+	EventLog          = "log"
+	// EventResync tells a subscriber that its resume token is gone from the buffer
+	// and that the full state must be reloaded from the topology endpoints
+	EventResync = "resync"
+)
+
+const (
+	FailoverPhaseStart    = "start"
+	FailoverPhaseElected  = "elected"
+	FailoverPhaseProxies  = "proxies"
+	FailoverPhaseComplete = "complete"
+	FailoverPhaseCancel   = "cancel"
+)
+
+// size of the channel of a subscriber, a subscriber lagging behind is dropped and
+// must resume from its last event id
+const eventSubscriberQueue = 256
+
+// Event is a cluster change sent to the stream subscribers, Id is the resume token
+type Event struct {
+	Id      int64       `json:"id"`
+	Type    string      `json:"type"`
+	Time    time.Time   `json:"time"`
+	Cluster string      `json:"cluster"`
+	Server  string      `json:"server,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type EventServerStateData struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type EventAlertData struct {
+	Number string `json:"number"`
+	Type   string `json:"type"`
+	Desc   string `json:"desc"`
+	From   string `json:"from"`
+}
+
+type EventFailoverData struct {
+	Phase  string `json:"phase"`
+	Fail   bool   `json:"fail"`
+	Master string `json:"master,omitempty"`
+}
+
+type EventJobData struct {
+	Task   string `json:"task"`
+	Status string `json:"status"`
+	Count  int    `json:"count,omitempty"`
+}
+
+type EventLogData struct {
+	Level string `json:"level"`
+	Text  string `json:"text"`
+}
+
+// eventStream keeps the last events for resuming and fans them out to subscribers
+type eventStream struct {
+	sync.Mutex
+	lastId      int64
+	evictedId   int64
+	buffer      []Event
+	subscribers map[chan Event]bool
+}
+
+// PublishEvent sends an event to all the stream subscribers of the cluster
+func (cluster *Cluster) PublishEvent(typ string, server string, data interface{}) {
+	es := &cluster.events
+	es.Lock()
+	defer es.Unlock()
+	// ids are based on the time so that tokens from before a restart are seen as too old
+	id := time.Now().UnixNano() / int64(time.Microsecond)
+	if id <= es.lastId {
+		id = es.lastId + 1
+	}
+	es.lastId = id
+	ev := Event{Id: id, Type: typ, Time: time.Now(), Cluster: cluster.Name, Server: server, Data: data}
+	size := cluster.Conf.HttpStreamBuffer
+	if size <= 0 {
+		size = 1000
+	}
+	es.buffer = append(es.buffer, ev)
+	if len(es.buffer) > size {
+		es.evictedId = es.buffer[len(es.buffer)-size-1].Id
+		es.buffer = es.buffer[len(es.buffer)-size:]
+	}
+	for ch := range es.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			delete(es.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SubscribeEvents returns the events published after the since resume token and a channel
+// for the next ones, since 0 only subscribes to new events. Only a resync event is returned
+// when since is older than the buffer or unknown. The channel is closed when the subscriber
+// does not keep up, cancel must be called when done.
+func (cluster *Cluster) SubscribeEvents(since int64) ([]Event, <-chan Event, func()) {
+	es := &cluster.events
+	es.Lock()
+	defer es.Unlock()
+	var backlog []Event
+	if since > 0 {
+		if since < es.evictedId || since > es.lastId {
+			backlog = append(backlog, Event{Id: es.lastId, Type: EventResync, Time: time.Now(), Cluster: cluster.Name})
+		} else {
+			for _, ev := range es.buffer {
+				if ev.Id > since {
+					backlog = append(backlog, ev)
+				}
+			}
+		}
+	}
+	ch := make(chan Event, eventSubscriberQueue)
+	if es.subscribers == nil {
+		es.subscribers = make(map[chan Event]bool)
+	}
+	es.subscribers[ch] = true
+	cancel := func() {
+		es.Lock()
+		if es.subscribers[ch] {
+			delete(es.subscribers, ch)
+			close(ch)
+		}
+		es.Unlock()
+	}
+	return backlog, ch, cancel
+}
+
+// GetEventSubscribers returns the number of connected stream subscribers
+func (cluster *Cluster) GetEventSubscribers() int {
+	cluster.events.Lock()
+	defer cluster.events.Unlock()
+	return len(cluster.events.subscribers)
+}
+
+func (cluster *Cluster) publishStateEvent(typ string, s state.State) {
+	cluster.PublishEvent(typ, s.ServerUrl, EventAlertData{Number: s.ErrKey, Type: s.ErrType, Desc: s.ErrDesc, From: s.ErrFrom})
+}
+
+func (cluster *Cluster) publishFailoverEvent(phase string, fail bool) {
+	var master string
+	if cluster.master != nil {
+		master = cluster.master.URL
+	}
+	cluster.PublishEvent(EventFailover, master, EventFailoverData{Phase: phase, Fail: fail, Master: master})
+}
+
+func (server *ServerMonitor) publishStateChange() {
+	server.ClusterGroup.PublishEvent(EventServerState, server.URL, EventServerStateData{From: server.PrevState, To: server.State})
+}
+
+// publishJobsProgress sends the jobs started or finished since the last check
+func (server *ServerMonitor) publishJobsProgress(running map[string]int) {
+	for task, ct := range running {
+		if prev, ok := server.runningJobs[task]; !ok || prev != ct {
+			server.ClusterGroup.PublishEvent(EventJob, server.URL, EventJobData{Task: task, Status: "running", Count: ct})
+		}
+	}
+	for task := range server.runningJobs {
+		if _, ok := running[task]; !ok {
+			server.ClusterGroup.PublishEvent(EventJob, server.URL, EventJobData{Task: task, Status: "done"})
+		}
+	}
+	server.runningJobs = running
+}
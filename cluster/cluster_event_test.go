@@ -0,0 +1,145 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/signal18/replication-manager/utils/mysqlsim"
+)
+
+func TestEventStreamResume(t *testing.T) {
+	cluster := new(Cluster)
+	cluster.Name = "events"
+	cluster.Conf.HttpStreamBuffer = 3
+
+	backlog, ch, cancel := cluster.SubscribeEvents(0)
+	if len(backlog) != 0 {
+		t.Fatalf("Expected no backlog for a new subscriber, got %d events", len(backlog))
+	}
+	for i := 0; i < 5; i++ {
+		cluster.PublishEvent(EventLog, "", EventLogData{Level: "INFO", Text: "event"})
+	}
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ev := <-ch
+		if ev.Type != EventLog || ev.Cluster != "events" {
+			t.Fatalf("Unexpected event %+v", ev)
+		}
+		if len(ids) > 0 && ev.Id <= ids[len(ids)-1] {
+			t.Fatalf("Event ids are not increasing: %d after %d", ev.Id, ids[len(ids)-1])
+		}
+		ids = append(ids, ev.Id)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("Channel not closed after cancel")
+	}
+	if cluster.GetEventSubscribers() != 0 {
+		t.Fatal("Subscriber not removed after cancel")
+	}
+
+	// the last 3 events are buffered, resuming after the 3rd event returns the 2 next ones
+	backlog, _, cancel = cluster.SubscribeEvents(ids[2])
+	cancel()
+	if len(backlog) != 2 || backlog[0].Id != ids[3] || backlog[1].Id != ids[4] {
+		t.Fatalf("Wrong backlog resuming from %d: %+v", ids[2], backlog)
+	}
+
+	// the 2nd event is gone from the buffer, the subscriber must resync
+	backlog, _, cancel = cluster.SubscribeEvents(ids[0])
+	cancel()
+	if len(backlog) != 1 || backlog[0].Type != EventResync || backlog[0].Id != ids[4] {
+		t.Fatalf("Expected a resync resuming from %d: %+v", ids[0], backlog)
+	}
+
+	// a token from the future comes from another process
+	backlog, _, cancel = cluster.SubscribeEvents(ids[4] + 1)
+	cancel()
+	if len(backlog) != 1 || backlog[0].Type != EventResync {
+		t.Fatalf("Expected a resync for an unknown token: %+v", backlog)
+	}
+}
+
+func TestEventStreamSlowSubscriber(t *testing.T) {
+	cluster := new(Cluster)
+	_, ch, cancel := cluster.SubscribeEvents(0)
+	defer cancel()
+	for i := 0; i <= eventSubscriberQueue; i++ {
+		cluster.PublishEvent(EventJob, "db1:3306", EventJobData{Task: "optimize", Status: "queued"})
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != eventSubscriberQueue {
+		t.Fatalf("Expected %d events before dropping the subscriber, got %d", eventSubscriberQueue, n)
+	}
+	if cluster.GetEventSubscribers() != 0 {
+		t.Fatal("Slow subscriber not dropped")
+	}
+}
+
+// collectFailoverPhases returns the phases of the failover events published until stop is called
+func collectFailoverPhases(cluster *Cluster) func() string {
+	_, events, cancel := cluster.SubscribeEvents(0)
+	phases := make(chan []string)
+	go func() {
+		var p []string
+		for ev := range events {
+			if ev.Type == EventFailover {
+				p = append(p, ev.Data.(EventFailoverData).Phase)
+			}
+		}
+		phases <- p
+	}()
+	return func() string {
+		cancel()
+		return strings.Join(<-phases, ",")
+	}
+}
+
+func TestEventStreamFailover(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMariaDB, 2, nil)
+	sc.ticks(2)
+	master, s1 := sc.topo.Servers[0], sc.topo.Servers[1]
+	stop := collectFailoverPhases(sc.cluster)
+	master.Stop()
+	sc.ticks(sc.cluster.Conf.MaxFail + 1)
+	sc.assertMaster(s1)
+	if p := stop(); p != "start,elected,proxies,complete" {
+		t.Fatalf("Unexpected failover events %s", p)
+	}
+}
+
+func TestEventStreamGroupReplicationFailover(t *testing.T) {
+	sc := newSimGroupCluster(t, 3, nil)
+	sc.ticks(2)
+	primary, s1, s2 := sc.topo.Servers[0], sc.topo.Servers[1], sc.topo.Servers[2]
+	stop := collectFailoverPhases(sc.cluster)
+	sc.cluster.MasterFailover(false)
+	sc.assertMaster(s1)
+	if p := stop(); p != "start,elected,proxies,complete" {
+		t.Fatalf("Unexpected switchover events %s", p)
+	}
+
+	stop = collectFailoverPhases(sc.cluster)
+	primary.Stop()
+	s1.Stop()
+	sc.ticks(sc.cluster.Conf.MaxFail + 1)
+	if sc.getServer(s2).IsGroupReplicationPrimary {
+		t.Fatal("Expected no primary without a majority")
+	}
+	// each failover attempt of the monitor is cancelled
+	if p := stop(); !strings.HasPrefix(p, "start,cancel") || strings.Contains(p, "elected") {
+		t.Fatalf("Unexpected failover events without a majority %s", p)
+	}
+}
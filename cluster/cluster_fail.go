@@ -21,6 +21,7 @@ import (
 
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/gtid"
+	"github.com/signal18/replication-manager/utils/hooks"
 	"github.com/signal18/replication-manager/utils/state"
 )
 
@@ -30,7 +31,14 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		res := cluster.VMasterFailover(fail)
 		return res
 	}
+	if cluster.GetTopology() == topoMasterSlavePgStream {
+		return cluster.PgStreamFailover(fail)
+	}
+	if cluster.GetTopology() == topoGroupReplication {
+		return cluster.GroupReplicationFailover(fail)
+	}
 	cluster.sme.SetFailoverState()
+	cluster.publishFailoverEvent(FailoverPhaseStart, fail)
 	// Phase 1: Cleanup and election
 	var err error
 	if fail == false {
@@ -40,16 +48,19 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		cluster.LogPrintf(LvlInfo, "Checking long running updates on master %d", cluster.Conf.SwitchWaitWrite)
 		if cluster.master == nil {
 			cluster.LogPrintf(LvlErr, "Cannot switchover without a master")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			return false
 		}
 		if cluster.master.Conn == nil {
 			cluster.LogPrintf(LvlErr, "Cannot switchover without a master connection")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			return false
 		}
 		qt, logs, err := dbhelper.CheckLongRunningWrites(cluster.master.Conn, cluster.Conf.SwitchWaitWrite)
 		cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", LvlDbg, "CheckLongRunningWrites")
 		if qt > 0 {
 			cluster.LogPrintf(LvlErr, "Long updates running on master. Cannot switchover")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			cluster.sme.RemoveFailoverState()
 			return false
 		}
@@ -81,6 +92,7 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 			}
 		case <-time.After(time.Second * time.Duration(cluster.Conf.SwitchWaitTrx)):
 			cluster.LogPrintf(LvlErr, "Long running trx on master at least %d, can not switchover ", cluster.Conf.SwitchWaitTrx)
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			cluster.sme.RemoveFailoverState()
 			return false
 		}
@@ -90,6 +102,11 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		cluster.LogPrintf(LvlInfo, "Starting master failover")
 		cluster.LogPrintf(LvlInfo, "------------------------")
 	}
+	if cluster.fireFailoverHook(hooks.EventPreFailover, fail, cluster.master, nil) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
 	cluster.LogPrintf(LvlInfo, "Electing a new master")
 	for _, s := range cluster.slaves {
 		s.Refresh()
@@ -102,6 +119,7 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	}
 	if key == -1 {
 		cluster.LogPrintf(LvlErr, "No candidates found")
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 		cluster.sme.RemoveFailoverState()
 		return false
 	}
@@ -109,6 +127,12 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	cluster.LogPrintf(LvlInfo, "Slave %s has been elected as a new master", cluster.slaves[key].URL)
 	if fail && !cluster.isSlaveElectable(cluster.slaves[key], true) {
 		cluster.LogPrintf(LvlInfo, "Elected slave have issue cancelling failover", cluster.slaves[key].URL)
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	if cluster.fireFailoverHook(hooks.EventCandidateElected, fail, cluster.master, cluster.slaves[key]) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 		cluster.sme.RemoveFailoverState()
 		return false
 	}
@@ -123,6 +147,7 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	cluster.oldMaster = cluster.master
 	cluster.master = cluster.Servers[skey]
 	cluster.master.SetMaster()
+	cluster.publishFailoverEvent(FailoverPhaseElected, fail)
 	if cluster.Conf.MultiMaster == false {
 		cluster.slaves[key].delete(&cluster.slaves)
 	}
@@ -289,8 +314,11 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "Could not set new master as read-write")
 	}
+	cluster.fireFailoverHook(hooks.EventPostPromote, fail, cluster.oldMaster, cluster.master)
 	cluster.LogPrintf(LvlInfo, "Failover proxies")
+	cluster.publishFailoverEvent(FailoverPhaseProxies, fail)
 	cluster.failoverProxies()
+	cluster.fireFailoverHook(hooks.EventProxiesUpdated, fail, cluster.oldMaster, cluster.master)
 	cluster.LogPrintf(LvlInfo, "Waiting %ds for unmanaged proxy to monitor route change", cluster.Conf.SwitchSlaveWaitRouteChange)
 	time.Sleep(time.Duration(cluster.Conf.SwitchSlaveWaitRouteChange) * time.Second)
 	if cluster.Conf.FailEventScheduler {
@@ -643,6 +671,7 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		cluster.FailoverCtr++
 		cluster.FailoverTs = time.Now().Unix()
 	}
+	cluster.publishFailoverEvent(FailoverPhaseComplete, fail)
 	cluster.sme.RemoveFailoverState()
 
 	// Not a prefered master this code is not default
@@ -1030,6 +1059,7 @@ func (cluster *Cluster) foundPreferedMaster(l []*ServerMonitor) *ServerMonitor {
 func (cluster *Cluster) VMasterFailover(fail bool) bool {
 
 	cluster.sme.SetFailoverState()
+	cluster.publishFailoverEvent(FailoverPhaseStart, fail)
 	// Phase 1: Cleanup and election
 	var err error
 	cluster.oldMaster = cluster.vmaster
@@ -1040,16 +1070,19 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 		cluster.LogPrintf(LvlInfo, "Checking long running updates on virtual master %d", cluster.Conf.SwitchWaitWrite)
 		if cluster.vmaster == nil {
 			cluster.LogPrintf(LvlErr, "Cannot switchover without a virtual master")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			return false
 		}
 		if cluster.vmaster.Conn == nil {
 			cluster.LogPrintf(LvlErr, "Cannot switchover without a vmaster connection")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			return false
 		}
 		qt, logs, err := dbhelper.CheckLongRunningWrites(cluster.vmaster.Conn, cluster.Conf.SwitchWaitWrite)
 		cluster.LogSQL(logs, err, cluster.vmaster.URL, "MasterFailover", LvlDbg, "CheckLongRunningWrites")
 		if qt > 0 {
 			cluster.LogPrintf(LvlErr, "Long updates running on virtual master. Cannot switchover")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			cluster.sme.RemoveFailoverState()
 			return false
 		}
@@ -1071,6 +1104,7 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 			}
 		case <-time.After(time.Second * time.Duration(cluster.Conf.SwitchWaitTrx)):
 			cluster.LogPrintf(LvlErr, "Long running trx on master at least %d, can not switchover ", cluster.Conf.SwitchWaitTrx)
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 			cluster.sme.RemoveFailoverState()
 			return false
 		}
@@ -1081,6 +1115,11 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 		cluster.LogPrintf(LvlInfo, "-------------------------------")
 		cluster.oldMaster = cluster.master
 	}
+	if cluster.fireFailoverHook(hooks.EventPreFailover, fail, cluster.oldMaster, nil) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
 	cluster.LogPrintf(LvlInfo, "Electing a new virtual master")
 	for _, s := range cluster.slaves {
 		s.Refresh()
@@ -1093,10 +1132,16 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 	}
 	if key == -1 {
 		cluster.LogPrintf(LvlErr, "No candidates found")
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
 		cluster.sme.RemoveFailoverState()
 		return false
 	}
 	cluster.LogPrintf(LvlInfo, "Server %s has been elected as a new master", cluster.slaves[key].URL)
+	if cluster.fireFailoverHook(hooks.EventCandidateElected, fail, cluster.oldMaster, cluster.slaves[key]) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
 
 	// Shuffle the server list
 
@@ -1109,6 +1154,7 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 	}
 	cluster.vmaster = cluster.Servers[skey]
 	cluster.master = cluster.Servers[skey]
+	cluster.publishFailoverEvent(FailoverPhaseElected, fail)
 	// Call pre-failover script
 	if cluster.Conf.PreScript != "" {
 		cluster.LogPrintf(LvlInfo, "Calling pre-failover script")
@@ -1202,8 +1248,11 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 		}
 		cluster.LogPrintf(LvlInfo, "Post-failover script complete", string(out))
 	}
+	cluster.publishFailoverEvent(FailoverPhaseProxies, fail)
 	cluster.failoverProxies()
+	cluster.fireFailoverHook(hooks.EventProxiesUpdated, fail, cluster.oldMaster, cluster.master)
 	cluster.master.SetReadWrite()
+	cluster.fireFailoverHook(hooks.EventPostPromote, fail, cluster.oldMaster, cluster.master)
 
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "Could not set new master as read-write")
@@ -1263,6 +1312,7 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 		cluster.FailoverCtr++
 		cluster.FailoverTs = time.Now().Unix()
 	}
+	cluster.publishFailoverEvent(FailoverPhaseComplete, fail)
 	cluster.master = nil
 
 	cluster.sme.RemoveFailoverState()
@@ -1366,3 +1416,373 @@ func (cluster *Cluster) CloseRing(oldMaster *ServerMonitor) error {
 
 	return nil
 }
+
+// PgStreamFailover promotes the most up to date standby of a PostgreSQL streaming replication cluster
+// and points the other standbys to it, the old primary needs a pg_rewind to rejoin
+func (cluster *Cluster) PgStreamFailover(fail bool) bool {
+	cluster.sme.SetFailoverState()
+	cluster.publishFailoverEvent(FailoverPhaseStart, fail)
+	if fail == false {
+		cluster.LogPrintf(LvlInfo, "--------------------------")
+		cluster.LogPrintf(LvlInfo, "Starting master switchover")
+		cluster.LogPrintf(LvlInfo, "--------------------------")
+		if cluster.master == nil || cluster.master.Conn == nil {
+			cluster.LogPrintf(LvlErr, "Cannot switchover without a master connection")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+			cluster.sme.RemoveFailoverState()
+			return false
+		}
+	} else {
+		cluster.LogPrintf(LvlInfo, "------------------------")
+		cluster.LogPrintf(LvlInfo, "Starting master failover")
+		cluster.LogPrintf(LvlInfo, "------------------------")
+	}
+	if cluster.fireFailoverHook(hooks.EventPreFailover, fail, cluster.master, nil) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	cluster.LogPrintf(LvlInfo, "Electing a new master")
+	for _, s := range cluster.slaves {
+		s.Refresh()
+	}
+	key := cluster.electPgStreamCandidate(cluster.slaves, true)
+	if key == -1 {
+		cluster.LogPrintf(LvlErr, "No candidates found")
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	candidate := cluster.slaves[key]
+	cluster.LogPrintf(LvlInfo, "Standby %s has been elected as a new master", candidate.URL)
+	if cluster.fireFailoverHook(hooks.EventCandidateElected, fail, cluster.master, candidate) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+
+	if fail == false {
+		cluster.LogPrintf(LvlInfo, "Rejecting updates on %s (old master)", cluster.master.URL)
+		logs, err := dbhelper.SetPGReadOnly(cluster.master.Conn, true)
+		cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", LvlErr, "Could not set %s (old master) read-only %s", cluster.master.URL, err)
+		logs, err = dbhelper.KillThreads(cluster.master.Conn, cluster.master.DBVersion)
+		cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", LvlErr, "Could not kill sessions on %s (old master) %s", cluster.master.URL, err)
+		cluster.LogPrintf(LvlInfo, "Waiting for candidate master to replay WAL")
+		err = candidate.PgWaitCatchUp(cluster.master, cluster.Conf.SwitchWaitTrx)
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Cancel switchover: %s", err)
+			logs, err = dbhelper.SetPGReadOnly(cluster.master.Conn, false)
+			cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", LvlErr, "Could not set %s (old master) read-write %s", cluster.master.URL, err)
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+			cluster.sme.RemoveFailoverState()
+			return false
+		}
+	}
+	crash := new(Crash)
+	crash.URL = cluster.master.URL
+	crash.ElectedMasterURL = candidate.URL
+	ms, err := candidate.GetSlaveStatus(candidate.ReplicationSourceName)
+	if err == nil {
+		crash.FailoverMasterLogFile = ms.MasterLogFile.String
+		crash.FailoverMasterLogPos = ms.ReadMasterLogPos.String
+		crash.FailoverIOGtid = gtid.NewList(ms.GtidIOPos.String)
+	}
+
+	cluster.oldMaster = cluster.master
+	cluster.master = candidate
+	cluster.master.SetMaster()
+	cluster.publishFailoverEvent(FailoverPhaseElected, fail)
+	cluster.slaves[key].delete(&cluster.slaves)
+	if cluster.Conf.PreScript != "" {
+		cluster.LogPrintf(LvlInfo, "Calling pre-failover script")
+		var out []byte
+		out, err = exec.Command(cluster.Conf.PreScript, cluster.oldMaster.Host, cluster.master.Host, cluster.oldMaster.Port, cluster.master.Port, cluster.oldMaster.MxsServerName, cluster.master.MxsServerName).CombinedOutput()
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "%s", err)
+		}
+		cluster.LogPrintf(LvlInfo, "Pre-failover script complete: %s", string(out))
+	}
+
+	cluster.LogPrintf(LvlInfo, "Promoting %s", cluster.master.URL)
+	err = cluster.master.PgPromote()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Cancel failover, promotion failed: %s", err)
+		cluster.slaves = append(cluster.slaves, cluster.master)
+		cluster.master = cluster.oldMaster
+		if fail == false {
+			logs, err := dbhelper.SetPGReadOnly(cluster.master.Conn, false)
+			cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", LvlErr, "Could not set %s (old master) read-write %s", cluster.master.URL, err)
+		}
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	cluster.master.Refresh()
+	crash.NewMasterLogFile = cluster.master.BinaryLogFile
+	crash.NewMasterLogPos = cluster.master.BinaryLogPos
+	cluster.Crashes = append(cluster.Crashes, crash)
+	t := time.Now()
+	crash.Save(cluster.WorkingDir + "/failover." + t.Format("20060102150405") + ".json")
+	crash.Purge(cluster.WorkingDir, cluster.Conf.FailoverLogFileKeep)
+	cluster.Save()
+	if cluster.Conf.PostScript != "" {
+		cluster.LogPrintf(LvlInfo, "Calling post-failover script")
+		var out []byte
+		out, err = exec.Command(cluster.Conf.PostScript, cluster.oldMaster.Host, cluster.master.Host, cluster.oldMaster.Port, cluster.master.Port, cluster.oldMaster.MxsServerName, cluster.master.MxsServerName).CombinedOutput()
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "%s", err)
+		}
+		cluster.LogPrintf(LvlInfo, "Post-failover script complete: %s", string(out))
+	}
+	err = cluster.master.SetReadWrite()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Could not set new master as read-write")
+	}
+	cluster.fireFailoverHook(hooks.EventPostPromote, fail, cluster.oldMaster, cluster.master)
+	cluster.LogPrintf(LvlInfo, "Failover proxies")
+	cluster.publishFailoverEvent(FailoverPhaseProxies, fail)
+	cluster.failoverProxies()
+	cluster.fireFailoverHook(hooks.EventProxiesUpdated, fail, cluster.oldMaster, cluster.master)
+	cluster.LogPrintf(LvlInfo, "Waiting %ds for unmanaged proxy to monitor route change", cluster.Conf.SwitchSlaveWaitRouteChange)
+	time.Sleep(time.Duration(cluster.Conf.SwitchSlaveWaitRouteChange) * time.Second)
+
+	cluster.LogPrintf(LvlInfo, "Switching other standbys to the new master")
+	for _, sl := range cluster.slaves {
+		if sl.URL == cluster.oldMaster.URL {
+			continue
+		}
+		cluster.LogPrintf(LvlInfo, "Change primary_conninfo on standby %s", sl.URL)
+		logs, err := sl.PgStreamChangeMaster(cluster.master)
+		cluster.LogSQL(logs, err, sl.URL, "MasterFailover", LvlErr, "Could not change primary on standby %s, %s", sl.URL, err)
+	}
+	if fail == false {
+		// the old primary is still running on the previous timeline, it can not stream before a rewind
+		cluster.LogPrintf(LvlInfo, "Rejoining old master %s as a standby", cluster.oldMaster.URL)
+		cluster.sme.RemoveFailoverState()
+		err = cluster.oldMaster.RejoinPgStream()
+		switch err {
+		case nil:
+		case errPgRewindStopped:
+			cluster.LogPrintf(LvlErr, "Old master %s is stopped and need a manual restart and rejoin: %s", cluster.oldMaster.URL, err)
+		case errPgRewindNotBack:
+			cluster.LogPrintf(LvlErr, "Old master %s was rewound but is not reachable as a standby: %s", cluster.oldMaster.URL, err)
+		default:
+			cluster.LogPrintf(LvlErr, "Old master %s stays read-only and need a manual rejoin: %s", cluster.oldMaster.URL, err)
+		}
+	}
+	cluster.backendStateChangeProxies()
+
+	cluster.LogPrintf(LvlInfo, "Master switch on %s complete", cluster.master.URL)
+	cluster.master.FailCount = 0
+	if fail == true {
+		cluster.FailoverCtr++
+		cluster.FailoverTs = time.Now().Unix()
+	}
+	cluster.publishFailoverEvent(FailoverPhaseComplete, fail)
+	cluster.sme.RemoveFailoverState()
+	return true
+}
+
+// electPgStreamCandidate returns the standby that received the most WAL, a preferred standby wins on equality
+// and always wins in switchover
+func (cluster *Cluster) electPgStreamCandidate(l []*ServerMonitor, forcingLog bool) int {
+	key := -1
+	var maxpos uint64
+	for i, sl := range l {
+		if sl.IsFull || sl.IsRelay {
+			continue
+		}
+		if cluster.isSlaveElectable(sl, forcingLog) == false {
+			cluster.sme.AddState("ERR00039", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00039"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			continue
+		}
+		if cluster.master != nil && cluster.master.State != stateFailed && cluster.IsInPreferedHosts(sl) {
+			if forcingLog {
+				cluster.LogPrintf(LvlInfo, "Election rig: %s elected as preferred master", sl.URL)
+			}
+			return i
+		}
+		ss, err := sl.GetSlaveStatus(sl.ReplicationSourceName)
+		if err != nil {
+			cluster.sme.AddState("ERR00033", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00033"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			continue
+		}
+		var pos uint64
+		for _, v := range gtid.NewList(ss.GtidIOPos.String).GetSeqNos() {
+			pos += v
+		}
+		if forcingLog {
+			cluster.LogPrintf(LvlInfo, "Election standby %s received WAL position %d", sl.URL, pos)
+		}
+		if key == -1 || pos > maxpos || (pos == maxpos && sl.IsPrefered() && !l[key].IsPrefered()) {
+			key = i
+			maxpos = pos
+		}
+	}
+	return key
+}
+
+// GroupReplicationFailover follows the primary of a single primary group, on failure the group elects
+// the new primary itself, on switchover it is requested with group_replication_set_as_primary
+func (cluster *Cluster) GroupReplicationFailover(fail bool) bool {
+	cluster.sme.SetFailoverState()
+	cluster.publishFailoverEvent(FailoverPhaseStart, fail)
+	if fail == false {
+		cluster.LogPrintf(LvlInfo, "--------------------------")
+		cluster.LogPrintf(LvlInfo, "Starting master switchover")
+		cluster.LogPrintf(LvlInfo, "--------------------------")
+		if cluster.master == nil || cluster.master.Conn == nil {
+			cluster.LogPrintf(LvlErr, "Cannot switchover without a master connection")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+			cluster.sme.RemoveFailoverState()
+			return false
+		}
+	} else {
+		cluster.LogPrintf(LvlInfo, "------------------------")
+		cluster.LogPrintf(LvlInfo, "Starting master failover")
+		cluster.LogPrintf(LvlInfo, "------------------------")
+	}
+	if cluster.fireFailoverHook(hooks.EventPreFailover, fail, cluster.master, nil) != nil {
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	for _, s := range cluster.slaves {
+		s.Refresh()
+	}
+	if !cluster.hasGroupReplicationQuorum() {
+		// a minority can not elect a primary, promoting one of its members would split the group
+		cluster.LogPrintf(LvlErr, "No secondary reaches a majority of the group, cancel master switch")
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	if fail == false {
+		key := cluster.electGroupReplicationCandidate(cluster.slaves, true)
+		if key == -1 {
+			cluster.LogPrintf(LvlErr, "No candidates found")
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+			cluster.sme.RemoveFailoverState()
+			return false
+		}
+		cluster.LogPrintf(LvlInfo, "Secondary %s has been elected as a new master", cluster.slaves[key].URL)
+		if cluster.fireFailoverHook(hooks.EventCandidateElected, fail, cluster.master, cluster.slaves[key]) != nil {
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+			cluster.sme.RemoveFailoverState()
+			return false
+		}
+		err := cluster.slaves[key].SetGroupReplicationPrimary()
+		if err != nil {
+			cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+			cluster.sme.RemoveFailoverState()
+			return false
+		}
+	} else {
+		cluster.LogPrintf(LvlInfo, "Waiting for the group to elect a new primary")
+	}
+	var candidate *ServerMonitor
+	for i := int64(0); i < cluster.Conf.SwitchWaitTrx && candidate == nil; i++ {
+		for _, s := range cluster.slaves {
+			s.Refresh()
+			if s.IsGroupReplicationPrimary {
+				candidate = s
+				break
+			}
+		}
+		if candidate == nil {
+			time.Sleep(time.Second)
+		}
+	}
+	if candidate == nil {
+		cluster.LogPrintf(LvlErr, "No new primary elected by the group after %ds", cluster.Conf.SwitchWaitTrx)
+		cluster.publishFailoverEvent(FailoverPhaseCancel, fail)
+		cluster.sme.RemoveFailoverState()
+		return false
+	}
+	cluster.LogPrintf(LvlInfo, "Group primary is now %s", candidate.URL)
+	if fail {
+		// the group already promoted the primary, a veto can not cancel the failover
+		cluster.fireFailoverHook(hooks.EventCandidateElected, fail, cluster.master, candidate)
+	}
+
+	crash := new(Crash)
+	crash.URL = cluster.master.URL
+	crash.ElectedMasterURL = candidate.URL
+	crash.FailoverIOGtid = candidate.GTIDBinlogPos
+
+	cluster.oldMaster = cluster.master
+	cluster.master = candidate
+	cluster.master.SetMaster()
+	cluster.publishFailoverEvent(FailoverPhaseElected, fail)
+	candidate.delete(&cluster.slaves)
+	if fail == false {
+		// the old primary stays in the group as a secondary
+		cluster.slaves = append(cluster.slaves, cluster.oldMaster)
+		cluster.oldMaster.Refresh()
+	}
+	cluster.master.Refresh()
+	cluster.fireFailoverHook(hooks.EventPostPromote, fail, cluster.oldMaster, cluster.master)
+	crash.NewMasterLogFile = cluster.master.BinaryLogFile
+	crash.NewMasterLogPos = cluster.master.BinaryLogPos
+	cluster.Crashes = append(cluster.Crashes, crash)
+	t := time.Now()
+	crash.Save(cluster.WorkingDir + "/failover." + t.Format("20060102150405") + ".json")
+	crash.Purge(cluster.WorkingDir, cluster.Conf.FailoverLogFileKeep)
+	cluster.Save()
+	if cluster.Conf.PostScript != "" {
+		cluster.LogPrintf(LvlInfo, "Calling post-failover script")
+		out, err := exec.Command(cluster.Conf.PostScript, cluster.oldMaster.Host, cluster.master.Host, cluster.oldMaster.Port, cluster.master.Port, cluster.oldMaster.MxsServerName, cluster.master.MxsServerName).CombinedOutput()
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "%s", err)
+		}
+		cluster.LogPrintf(LvlInfo, "Post-failover script complete: %s", string(out))
+	}
+	cluster.LogPrintf(LvlInfo, "Failover proxies")
+	cluster.publishFailoverEvent(FailoverPhaseProxies, fail)
+	cluster.failoverProxies()
+	cluster.backendStateChangeProxies()
+	cluster.fireFailoverHook(hooks.EventProxiesUpdated, fail, cluster.oldMaster, cluster.master)
+
+	cluster.LogPrintf(LvlInfo, "Master switch on %s complete", cluster.master.URL)
+	cluster.master.FailCount = 0
+	if fail == true {
+		cluster.FailoverCtr++
+		cluster.FailoverTs = time.Now().Unix()
+	}
+	cluster.publishFailoverEvent(FailoverPhaseComplete, fail)
+	cluster.sme.RemoveFailoverState()
+	return true
+}
+
+// hasGroupReplicationQuorum returns true when an online secondary sees a majority of the group
+func (cluster *Cluster) hasGroupReplicationQuorum() bool {
+	for _, s := range cluster.slaves {
+		if s.IsGroupReplicationOnline() && s.HasGroupReplicationQuorum() {
+			return true
+		}
+	}
+	return false
+}
+
+// electGroupReplicationCandidate returns an online secondary, a preferred one first
+func (cluster *Cluster) electGroupReplicationCandidate(l []*ServerMonitor, forcingLog bool) int {
+	key := -1
+	for i, sl := range l {
+		if !sl.IsGroupReplicationOnline() || sl.IsMaintenance || sl.IsIgnored() {
+			if forcingLog {
+				cluster.LogPrintf(LvlInfo, "Election secondary %s is not electable in state %s", sl.URL, sl.GroupReplicationState)
+			}
+			continue
+		}
+		if cluster.IsInPreferedHosts(sl) {
+			if forcingLog {
+				cluster.LogPrintf(LvlInfo, "Election rig: %s elected as preferred master", sl.URL)
+			}
+			return i
+		}
+		if key == -1 {
+			key = i
+		}
+	}
+	return key
+}
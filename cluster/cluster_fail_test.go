@@ -0,0 +1,183 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/mysqlsim"
+)
+
+func TestTopologyDiscover(t *testing.T) {
+	for _, flavor := range []string{mysqlsim.FlavorMariaDB, mysqlsim.FlavorMySQL} {
+		sc := newSimCluster(t, flavor, 2, nil)
+		sc.ticks(2)
+		sc.assertMaster(sc.topo.Servers[0])
+		if len(sc.cluster.slaves) != 2 {
+			t.Fatalf("%s: expected 2 slaves got %d", flavor, len(sc.cluster.slaves))
+		}
+		if sc.cluster.GetTopology() != topoMasterSlave {
+			t.Fatalf("%s: expected topology %s got %s", flavor, topoMasterSlave, sc.cluster.GetTopology())
+		}
+	}
+}
+
+func TestMasterFailoverAndRejoin(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMariaDB, 2, nil)
+	sc.ticks(2)
+	master, s1, s2 := sc.topo.Servers[0], sc.topo.Servers[1], sc.topo.Servers[2]
+	master.Write(3)
+
+	// the slave with the most transactions must be elected
+	sc.topo.Partition(master, s2)
+	master.Write(1)
+	master.Stop()
+	sc.ticks(sc.cluster.Conf.MaxFail + 1)
+	sc.assertMaster(s1)
+	sc.assertReplicateFrom(s2, s1)
+	if s1.GetVariable("READ_ONLY") != "OFF" {
+		t.Fatal("Expected new master to be writable")
+	}
+	if sc.getServer(master).State != stateFailed {
+		t.Fatalf("Expected old master to be failed got %s", sc.getServer(master).State)
+	}
+	if s2.GetGtid() != s1.GetGtid() {
+		t.Fatalf("Expected slave to catch up with the new master got %s expected %s", s2.GetGtid(), s1.GetGtid())
+	}
+
+	// the old master is rejoined as a slave of the new master
+	if err := master.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sc.ticks(3)
+	sc.assertMaster(s1)
+	sc.assertReplicateFrom(master, s1)
+	if sc.getServer(master).State != stateSlave {
+		t.Fatalf("Expected old master to be rejoined got %s", sc.getServer(master).State)
+	}
+}
+
+func TestInteractiveNoFailover(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMariaDB, 1, func(conf *config.Config) {
+		conf.Interactive = true
+	})
+	sc.ticks(2)
+	master := sc.topo.Servers[0]
+	master.Stop()
+	sc.ticks(sc.cluster.Conf.MaxFail + 1)
+	if sc.cluster.GetMaster() != nil && sc.cluster.GetMaster().Port != master.Port {
+		t.Fatal("Expected no failover in interactive mode")
+	}
+	sc.assertReplicateFrom(sc.topo.Servers[1], master)
+}
+
+func TestSwitchover(t *testing.T) {
+	sc := newSimCluster(t, mysqlsim.FlavorMySQL, 2, nil)
+	sc.ticks(2)
+	master, s1, s2 := sc.topo.Servers[0], sc.topo.Servers[1], sc.topo.Servers[2]
+	master.Write(2)
+	sc.cluster.MasterFailover(false)
+	sc.ticks(2)
+	sc.assertMaster(s1)
+	sc.assertReplicateFrom(master, s1)
+	sc.assertReplicateFrom(s2, s1)
+	if master.GetVariable("READ_ONLY") != "ON" {
+		t.Fatal("Expected old master to be read only")
+	}
+}
+
+func TestGroupReplicationElection(t *testing.T) {
+	sc := newSimGroupCluster(t, 3, nil)
+	sc.ticks(2)
+	primary, s2 := sc.topo.Servers[0], sc.topo.Servers[2]
+	sc.assertMaster(primary)
+	if sc.cluster.GetTopology() != topoGroupReplication || len(sc.cluster.slaves) != 2 {
+		t.Fatalf("Expected a group of 2 secondaries got %s with %d slaves", sc.cluster.GetTopology(), len(sc.cluster.slaves))
+	}
+	primary.Write(2)
+
+	// the group elects the member of highest weight and the monitor follows it
+	s2.SetVariable("group_replication_member_weight", "80")
+	primary.Stop()
+	if !sc.cluster.GroupReplicationFailover(true) {
+		t.Fatal("Expected failover to follow the group election")
+	}
+	sc.ticks(sc.cluster.Conf.MaxFail + 1)
+	if sc.topo.GetGroupPrimary() != s2 {
+		t.Fatalf("Expected the group to elect %s", s2.Addr())
+	}
+	sc.assertMaster(s2)
+	if len(sc.cluster.slaves) != 1 || sc.getServer(primary).State != stateFailed {
+		t.Fatalf("Expected one secondary and a failed old primary got %d secondaries and %s", len(sc.cluster.slaves), sc.getServer(primary).State)
+	}
+	if sc.cluster.FailoverCtr != 1 {
+		t.Fatalf("Expected one failover got %d", sc.cluster.FailoverCtr)
+	}
+	if s2.GetVariable("READ_ONLY") != "OFF" || s2.GetGtid() != primary.GetGtid() {
+		t.Fatalf("Expected new primary to be writable with all transactions got read_only %s gtid %s", s2.GetVariable("READ_ONLY"), s2.GetGtid())
+	}
+}
+
+func TestGroupReplicationNoMajorityNoFailover(t *testing.T) {
+	sc := newSimGroupCluster(t, 3, nil)
+	sc.ticks(2)
+	primary, s1, s2 := sc.topo.Servers[0], sc.topo.Servers[1], sc.topo.Servers[2]
+
+	// the last member can not reach a majority of the group
+	primary.Stop()
+	s1.Stop()
+	sc.ticks(sc.cluster.Conf.MaxFail + 1)
+	if sc.topo.GetGroupPrimary() != nil {
+		t.Fatalf("Expected no primary in a minority got %s", sc.topo.GetGroupPrimary().Addr())
+	}
+	if !sc.cluster.sme.IsInState("ERR00085") {
+		t.Fatal("Expected the lost quorum to be reported")
+	}
+	if sc.cluster.GroupReplicationFailover(true) {
+		t.Fatal("Expected failover to be refused without a majority")
+	}
+	if sc.cluster.FailoverCtr != 0 {
+		t.Fatalf("Expected no failover got %d", sc.cluster.FailoverCtr)
+	}
+	if m := sc.cluster.GetMaster(); m != nil && m.Port == s2.Port {
+		t.Fatal("Expected the minority member not to be promoted")
+	}
+	if s2.GetVariable("READ_ONLY") != "ON" {
+		t.Fatal("Expected the minority member to stay read only")
+	}
+}
+
+func TestGroupReplicationSwitchover(t *testing.T) {
+	sc := newSimGroupCluster(t, 3, nil)
+	sc.ticks(2)
+	primary, s2 := sc.topo.Servers[0], sc.topo.Servers[2]
+	primary.Write(2)
+
+	// the preferred secondary is chosen over the first online one
+	sc.cluster.Conf.PrefMaster = sc.getServer(s2).URL
+	if !sc.cluster.MasterFailover(false) {
+		t.Fatal("Expected switchover to succeed")
+	}
+	sc.ticks(2)
+	if sc.topo.GetGroupPrimary() != s2 {
+		t.Fatalf("Expected %s to be the group primary", s2.Addr())
+	}
+	sc.assertMaster(s2)
+	if sc.getServer(primary).IsFailed() || primary.GetVariable("SUPER_READ_ONLY") != "ON" {
+		t.Fatal("Expected old primary to stay in the group as a read only secondary")
+	}
+	if len(sc.cluster.slaves) != 2 {
+		t.Fatalf("Expected 2 secondaries got %d", len(sc.cluster.slaves))
+	}
+	if sc.cluster.FailoverCtr != 0 {
+		t.Fatalf("Expected a switchover not to count as failover got %d", sc.cluster.FailoverCtr)
+	}
+}
@@ -56,6 +56,13 @@ func (cluster *Cluster) GetMysqlclientPath() string {
 	return cluster.Conf.BackupMysqlclientPath
 }
 
+func (cluster *Cluster) GetPgBasebackupPath() string {
+	if cluster.Conf.BackupPgBasebackupPath == "" {
+		return "pg_basebackup"
+	}
+	return cluster.Conf.BackupPgBasebackupPath
+}
+
 func (cluster *Cluster) GetDomain() string {
 	if cluster.Conf.ProvNetCNI {
 		return "." + cluster.Name + ".svc." + cluster.Conf.ProvOrchestratorCluster
@@ -451,6 +458,8 @@ func (cluster *Cluster) GetTopology() string {
 		cluster.Conf.Topology = topoMultiMasterRing
 	} else if cluster.Conf.MultiMasterWsrep {
 		cluster.Conf.Topology = topoMultiMasterWsrep
+	} else if cluster.Conf.MultiMasterGrouprep {
+		cluster.Conf.Topology = topoGroupReplication
 	} else if cluster.Conf.MxsBinlogOn {
 		cluster.Conf.Topology = topoBinlogServer
 	} else if cluster.Conf.MultiTierSlave {
@@ -0,0 +1,106 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/hooks"
+)
+
+// GetHooks returns the hooks of the lifecycle events, Go hooks are added with
+// Register
+func (cluster *Cluster) GetHooks() *hooks.Manager {
+	return cluster.hooks
+}
+
+// initHooks configures the script and webhook hooks, the Go hooks registered are
+// kept on reload
+func (cluster *Cluster) initHooks() {
+	if cluster.hooks == nil {
+		cluster.hooks = hooks.NewManager()
+		cluster.hooks.Register("binlog-server", cluster.binlogServerHook, hooks.EventPostPromote)
+	}
+	events, err := hooks.ParseEvents(cluster.Conf.HookEvents)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Hooks disabled: %s", err)
+		cluster.hooks.Configure(false, 0, nil, nil)
+		return
+	}
+	var hs []hooks.Hook
+	for _, s := range strings.Split(cluster.Conf.HookScripts, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			hs = append(hs, &hooks.ScriptHook{Path: s})
+		}
+	}
+	for _, u := range strings.Split(cluster.Conf.HookWebhooks, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			hs = append(hs, &hooks.WebhookHook{URL: u, Secret: cluster.Conf.HookWebhookSecret})
+		}
+	}
+	cluster.hooks.Configure(cluster.Conf.HookVeto, time.Duration(cluster.Conf.HookTimeout)*time.Second, hs, events)
+}
+
+func (server *ServerMonitor) getHookServer() *hooks.Server {
+	if server == nil {
+		return nil
+	}
+	return &hooks.Server{URL: server.URL, Host: server.Host, Port: server.Port, Id: server.Id, Name: server.MxsServerName}
+}
+
+// fireHook calls the hooks of the event and logs their results, the error is not
+// nil when a hook vetoes the operation
+func (cluster *Cluster) fireHook(p *hooks.Payload) error {
+	p.Cluster = cluster.Name
+	results, err := cluster.hooks.Fire(p)
+	for _, r := range results {
+		switch {
+		case r.Timeout:
+			cluster.LogPrintf(LvlWarn, "Hook %s on %s timed out after %s", r.Hook, r.Event, r.Duration)
+		case r.Err != nil:
+			cluster.LogPrintf(LvlErr, "Hook %s on %s failed in %s: %s %s", r.Hook, r.Event, r.Duration, r.Err, r.Output)
+		default:
+			cluster.LogPrintf(LvlInfo, "Hook %s on %s complete in %s: %s", r.Hook, r.Event, r.Duration, r.Output)
+		}
+	}
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "%s", err)
+	}
+	return err
+}
+
+// fireFailoverHook fires a failover event from the old master to the new one
+func (cluster *Cluster) fireFailoverHook(event string, fail bool, oldMaster *ServerMonitor, newMaster *ServerMonitor) error {
+	return cluster.fireHook(&hooks.Payload{
+		Event:      event,
+		Switchover: !fail,
+		OldMaster:  oldMaster.getHookServer(),
+		NewMaster:  newMaster.getHookServer(),
+	})
+}
+
+// fireRejoinHook fires a rejoin event of the server to the master
+func (server *ServerMonitor) fireRejoinHook(event string, err error) error {
+	p := &hooks.Payload{Event: event, Server: server.getHookServer(), NewMaster: server.ClusterGroup.master.getHookServer()}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	return server.ClusterGroup.fireHook(p)
+}
+
+// fireBackupHook fires backup-finished for a backup of the server
+func (server *ServerMonitor) fireBackupHook(b *hooks.Backup, err error) {
+	p := &hooks.Payload{Event: hooks.EventBackupFinished, Server: server.getHookServer(), Backup: b}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	server.ClusterGroup.fireHook(p)
+}
@@ -154,6 +154,8 @@ func (cluster *Cluster) LogPrintf(level string, format string, args ...interface
 			cluster.tlog.Add(fmt.Sprintf(format, args...))
 			cluster.display()
 		}
+		cluster.addTestLog(stamp + " " + fmt.Sprintf(format, args...))
+		cluster.PublishEvent(EventLog, "", EventLogData{Level: level, Text: fmt.Sprintf(cliformat, args...)})
 
 		if cluster.Conf.HttpServ {
 			msg := s18log.HttpMessage{
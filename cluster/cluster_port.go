@@ -0,0 +1,50 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import "sync"
+
+// number of ports a cluster service is shifted by when its ports are used by
+// another cluster of the same replication-manager
+const clusterPortStride = 10
+
+// clusterPorts holds the ports bound by the internal services of all clusters
+var clusterPorts = struct {
+	sync.Mutex
+	m map[int]string
+}{m: make(map[int]string)}
+
+// reservePorts returns the ports of the service for the cluster. As all clusters share
+// the default configuration, ports already reserved by another cluster are shifted by
+// clusterPortStride until free, the clusters being started in the order of the
+// configuration the ports of a cluster are the same on every start.
+func (cluster *Cluster) reservePorts(service string, ports ...int) []int {
+	owner := cluster.Name + "/" + service
+	clusterPorts.Lock()
+	defer clusterPorts.Unlock()
+	for offset := 0; ; offset += clusterPortStride {
+		free := true
+		for _, p := range ports {
+			if o, ok := clusterPorts.m[p+offset]; ok && o != owner {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+		reserved := make([]int, len(ports))
+		for i, p := range ports {
+			reserved[i] = p + offset
+			clusterPorts.m[p+offset] = owner
+		}
+		return reserved
+	}
+}
@@ -129,6 +129,23 @@ func (cluster *Cluster) SetSchedulerBackupPhysical() {
 	}
 }
 
+func (cluster *Cluster) SetSchedulerBackupIncremental() {
+	if cluster.HasSchedulerEntry("backupincremental") {
+		cluster.LogPrintf(LvlInfo, "Disable database incremental backup")
+		cluster.scheduler.Remove(cluster.idSchedulerIncrementalBackup)
+	}
+	if cluster.Conf.SchedulerBackupIncremental {
+		var err error
+		cluster.LogPrintf(LvlInfo, "Schedule incremental backup time at: %s", cluster.Conf.BackupIncrementalCron)
+		cluster.idSchedulerIncrementalBackup, err = cluster.scheduler.AddFunc(cluster.Conf.BackupIncrementalCron, func() {
+			cluster.master.JobBackupPhysicalIncremental()
+		})
+		if err == nil {
+			cluster.Schedule["backupincremental"] = cluster.scheduler.Entry(cluster.idSchedulerIncrementalBackup)
+		}
+	}
+}
+
 func (cluster *Cluster) SetSchedulerLogsTableRotate() {
 	if cluster.HasSchedulerEntry("logstablerotate") {
 		cluster.LogPrintf(LvlInfo, "Disable database logs table rotate")
@@ -844,6 +861,12 @@ func (cluster *Cluster) SetSchedulerDbServersPhysicalBackupCron(value string) er
 	return nil
 }
 
+func (cluster *Cluster) SetSchedulerDbServersIncrementalBackupCron(value string) error {
+	cluster.Conf.BackupIncrementalCron = value
+	cluster.SetSchedulerBackupIncremental()
+	return nil
+}
+
 func (cluster *Cluster) SetSchedulerDbServersOptimizeCron(value string) error {
 	cluster.Conf.BackupDatabaseOptimizeCron = value
 	cluster.SetSchedulerOptimize()
@@ -922,6 +945,18 @@ func (cluster *Cluster) SetSchedulerJobsSshCron(value string) error {
 	return nil
 }
 
+func (cluster *Cluster) SetSchedulerChaosCron(value string) error {
+	cluster.Conf.SchedulerChaosCron = value
+	cluster.SetSchedulerChaos()
+	return nil
+}
+
+func (cluster *Cluster) SetSchedulerChecksumCron(value string) error {
+	cluster.Conf.SchedulerChecksumCron = value
+	cluster.SetSchedulerChecksum()
+	return nil
+}
+
 func (cluster *Cluster) SetDbServerHosts(value string) error {
 	cluster.Conf.Hosts = value
 	cluster.hostList = strings.Split(value, ",")
@@ -0,0 +1,166 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/mysqlsim"
+	"github.com/signal18/replication-manager/utils/s18log"
+)
+
+// simCluster drives a cluster monitoring a simulated topology, the monitor loop is run one
+// tick at a time so that tests are deterministic
+type simCluster struct {
+	t       *testing.T
+	cluster *Cluster
+	topo    *mysqlsim.Topology
+	dir     string
+}
+
+func getSimConfig(topo *mysqlsim.Topology, dir string) config.Config {
+	var conf config.Config
+	var hosts []string
+	for _, s := range topo.Servers {
+		hosts = append(hosts, s.Addr())
+	}
+	conf.Hosts = strings.Join(hosts, ",")
+	conf.User = topo.User + ":" + topo.Password
+	conf.RplUser = topo.User + ":" + topo.Password
+	conf.WorkingDir = dir
+	conf.ShareDir = dir
+	conf.ProvOrchestrator = config.ConstOrchestratorOnPremise
+	conf.CheckType = "tcp"
+	conf.MonitoringTicker = 1
+	conf.Timeout = 1
+	conf.ReadTimeout = 1
+	conf.MaxFail = 2
+	conf.Autorejoin = true
+	conf.ReadOnly = true
+	conf.FailLimit = 5
+	conf.SwitchWaitTrx = 1
+	conf.SwitchWaitKill = 1
+	conf.FailMaxDelay = 30
+	conf.SwitchMaxDelay = 30
+	conf.MasterConnectRetry = 10
+	conf.ForceSlaveHeartbeatTime = 3
+	conf.FailoverLogFileKeep = 5
+	conf.LogLevel = 1
+	return conf
+}
+
+// newSimCluster starts a master with n slaves and a cluster monitoring them, conf can
+// change the configuration before the cluster is initialized
+func newSimCluster(t *testing.T, flavor string, n int, conf func(*config.Config)) *simCluster {
+	topo, err := mysqlsim.NewMasterSlaves(flavor, "root", "secret", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newSimClusterTopology(t, topo, conf)
+}
+
+// newSimGroupCluster starts a group replication of n members and a cluster monitoring it
+func newSimGroupCluster(t *testing.T, n int, conf func(*config.Config)) *simCluster {
+	topo, err := mysqlsim.NewGroup("root", "secret", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newSimClusterTopology(t, topo, func(c *config.Config) {
+		c.MultiMasterGrouprep = true
+		if conf != nil {
+			conf(c)
+		}
+	})
+}
+
+// newSimClusterTopology starts a cluster monitoring a simulated topology
+func newSimClusterTopology(t *testing.T, topo *mysqlsim.Topology, conf func(*config.Config)) *simCluster {
+	dir, err := ioutil.TempDir("", "repman-sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := getSimConfig(topo, dir)
+	if conf != nil {
+		conf(&c)
+	}
+	cluster := new(Cluster)
+	tlog := s18log.NewTermLog(0)
+	hlog := s18log.NewHttpLog(0)
+	if err := cluster.Init(c, "sim", &tlog, &hlog, 0, "sim", "sim", "localhost", nil); err != nil {
+		t.Fatal(err)
+	}
+	sc := &simCluster{t: t, cluster: cluster, topo: topo, dir: dir}
+	t.Cleanup(sc.close)
+	return sc
+}
+
+func (sc *simCluster) close() {
+	sc.topo.Close()
+	for _, s := range sc.cluster.Servers {
+		if s.Conn != nil {
+			s.Conn.Close()
+		}
+	}
+	os.RemoveAll(sc.dir)
+}
+
+// tick runs one iteration of the monitor loop without proxies and scheduler
+func (sc *simCluster) tick() {
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	sc.cluster.TopologyDiscover(wg)
+	sc.cluster.IsFailable = sc.cluster.GetStatus()
+	sc.cluster.CheckFailed()
+	sc.cluster.Topology = sc.cluster.GetTopology()
+	sc.cluster.SetStatus()
+	sc.cluster.StateProcessing()
+}
+
+// ticks runs the monitor loop n times
+func (sc *simCluster) ticks(n int) {
+	for i := 0; i < n; i++ {
+		sc.tick()
+	}
+}
+
+// getServer returns the monitor of a simulated server
+func (sc *simCluster) getServer(s *mysqlsim.Server) *ServerMonitor {
+	for _, sv := range sc.cluster.Servers {
+		if sv.Port == s.Port {
+			return sv
+		}
+	}
+	sc.t.Fatalf("No server monitor for %s", s.Addr())
+	return nil
+}
+
+// assertMaster fails the test if the monitor does not see s as the master
+func (sc *simCluster) assertMaster(s *mysqlsim.Server) {
+	m := sc.cluster.GetMaster()
+	if m == nil {
+		sc.t.Fatalf("No master found, expected %s", s.Addr())
+	}
+	if m.Port != s.Port {
+		sc.t.Fatalf("Master is %s, expected %s", m.URL, s.Addr())
+	}
+}
+
+// assertReplicateFrom fails the test if slave is not replicating from master
+func (sc *simCluster) assertReplicateFrom(slave *mysqlsim.Server, master *mysqlsim.Server) {
+	rpl := slave.GetReplication()
+	if rpl == nil || rpl.Port != master.Port || !rpl.IORunning || !rpl.SQLRunning {
+		sc.t.Fatalf("Server %s is not replicating from %s: %+v", slave.Addr(), master.Addr(), rpl)
+	}
+}
@@ -7,25 +7,37 @@
 package cluster
 
 import (
-	"fmt"
+	"crypto/tls"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/signal18/replication-manager/utils/backupstore"
+	"github.com/signal18/replication-manager/utils/misc"
+	sstchannel "github.com/signal18/replication-manager/utils/sst"
 )
 
+// number of reconnections of an interrupted transfer before it fails
+const sstRetries = 3
+
 type SST struct {
 	in              io.Reader
 	file            *os.File
+	spool           *os.File
 	listener        net.Listener
 	tcplistener     *net.TCPListener
 	outfilewriter   io.Writer
 	outresticreader io.WriteCloser
+	outstore        backupstore.Writer
 	cluster         *Cluster
 	port            int
+	transfer        *sstchannel.Transfer
 }
 
 type ProtectedSSTconnections struct {
@@ -36,7 +48,16 @@ type ProtectedSSTconnections struct {
 var SSTs = ProtectedSSTconnections{SSTconnections: make(map[int]*SST)}
 
 func (cluster *Cluster) SSTCloseReceiver(destinationPort int) {
-	SSTs.SSTconnections[destinationPort].in.(net.Conn).Close()
+	SSTs.Lock()
+	sst, ok := SSTs.SSTconnections[destinationPort]
+	SSTs.Unlock()
+	if !ok {
+		return
+	}
+	sst.listener.Close()
+	if conn, ok := sst.in.(net.Conn); ok {
+		conn.Close()
+	}
 }
 
 func (cluster *Cluster) SSTWatchRestic(r io.Reader) error {
@@ -59,7 +80,48 @@ func (cluster *Cluster) SSTWatchRestic(r io.Reader) error {
 	}
 }
 
-func (cluster *Cluster) SSTRunReceiverToRestic(filename string) (string, error) {
+// sstServerConfig authenticates the database hosts with the cluster CA
+func (cluster *Cluster) sstServerConfig() (*tls.Config, error) {
+	return sstchannel.ServerConfig(cluster.WorkingDir+"/ca-cert.pem", cluster.WorkingDir+"/server-cert.pem", cluster.WorkingDir+"/server-key.pem")
+}
+
+func (cluster *Cluster) sstClientConfig() (*tls.Config, error) {
+	return sstchannel.ClientConfig(cluster.WorkingDir+"/ca-cert.pem", cluster.WorkingDir+"/client-cert.pem", cluster.WorkingDir+"/client-key.pem")
+}
+
+// sstListen opens the TLS listener of a transfer for task and returns its one-time token
+func (sst *SST) sstListen(task string, w io.Writer) (string, error) {
+	cluster := sst.cluster
+	tlsConfig, err := cluster.sstServerConfig()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Exiting SST on TLS configuration %s", err)
+		return "", err
+	}
+	token, err := sstchannel.NewToken()
+	if err != nil {
+		return "", err
+	}
+	sst.transfer = sstchannel.NewTransfer(task, token, w)
+	sst.listener, err = net.Listen("tcp", cluster.Conf.BindAddr+":0")
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Exiting SST on socket listen %s", err)
+		return "", err
+	}
+	sst.tcplistener = sst.listener.(*net.TCPListener)
+	sst.port = sst.listener.Addr().(*net.TCPAddr).Port
+	sst.listener = tls.NewListener(sst.tcplistener, tlsConfig)
+	if sst.cluster.Conf.LogSST {
+		cluster.LogPrintf(LvlInfo, "Listening for SST %s on port %d", task, sst.port)
+	}
+	SSTs.Lock()
+	SSTs.SSTconnections[sst.port] = sst
+	SSTs.Unlock()
+	return token, nil
+}
+
+// SSTRunReceiverToRestic receives the transfer of task into a restic backup, it
+// returns the port and the token the sender must present
+func (cluster *Cluster) SSTRunReceiverToRestic(task string, filename string) (string, string, error) {
 	sst := new(SST)
 	sst.cluster = cluster
 
@@ -75,40 +137,33 @@ func (cluster *Cluster) SSTRunReceiverToRestic(filename string) (string, error)
 	stdout, err := resticcmd.StdoutPipe()
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "Exiting SST on restic StdoutPipe %s", err)
-		return "", err
+		return "", "", err
 	}
 	go cluster.SSTWatchRestic(stdout)
 	sst.outresticreader, err = resticcmd.StdinPipe()
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "Exiting SST on restic StdinPipe %s", err)
-		return "", err
+		return "", "", err
 	}
 	err = resticcmd.Start()
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "Error restic command: %s", err)
-		return "", err
+		return "", "", err
 	}
 
-	sst.listener, err = net.Listen("tcp", cluster.Conf.BindAddr+":0")
+	token, err := sst.sstListen(task, sst.outresticreader)
 	if err != nil {
-		cluster.LogPrintf(LvlErr, "Exiting SST on socket listen %s", err)
-		return "", err
-	}
-	sst.tcplistener = sst.listener.(*net.TCPListener)
-	sst.tcplistener.SetDeadline(time.Now().Add(time.Second * 120))
-	destinationPort := sst.listener.Addr().(*net.TCPAddr).Port
-	if sst.cluster.Conf.LogSST {
-		cluster.LogPrintf(LvlInfo, "Listening for SST on port %d", destinationPort)
+		sst.outresticreader.Close()
+		return "", "", err
 	}
-	SSTs.Lock()
-	SSTs.SSTconnections[destinationPort] = sst
-	SSTs.Unlock()
 	go sst.tcp_con_handle_to_restic()
 
-	return strconv.Itoa(destinationPort), nil
+	return strconv.Itoa(sst.port), token, nil
 }
 
-func (cluster *Cluster) SSTRunReceiverToFile(filename string, openfile string) (string, error) {
+// SSTRunReceiverToFile receives the transfer of task into filename, it returns the
+// port and the token the sender must present
+func (cluster *Cluster) SSTRunReceiverToFile(task string, filename string, openfile string) (string, string, error) {
 	sst := new(SST)
 	sst.cluster = cluster
 	var writers []io.Writer
@@ -121,200 +176,185 @@ func (cluster *Cluster) SSTRunReceiverToFile(filename string, openfile string) (
 	}
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "Open file failed for job %s %s", filename, err)
-		return "", err
+		return "", "", err
 	}
 	writers = append(writers, sst.file)
+	if openfile != ConstJobCreateFile {
+		// appended logs are spooled and appended once the transfer is verified, an
+		// aborted or forged transfer must not leave partial content in the file
+		sst.spool, err = ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".sst")
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Open spool file failed for job %s %s", filename, err)
+			sst.file.Close()
+			return "", "", err
+		}
+		writers = []io.Writer{sst.spool}
+	}
 
 	sst.outfilewriter = io.MultiWriter(writers...)
 
-	sst.listener, err = net.Listen("tcp", cluster.Conf.BindAddr+":0")
+	token, err := sst.sstListen(task, sst.outfilewriter)
 	if err != nil {
-		cluster.LogPrintf(LvlErr, "Exiting SST on socket listen %s", err)
-		return "", err
+		sst.closeFile(false)
+		return "", "", err
 	}
-	sst.tcplistener = sst.listener.(*net.TCPListener)
-	sst.tcplistener.SetDeadline(time.Now().Add(time.Second * 120))
-	destinationPort := sst.listener.Addr().(*net.TCPAddr).Port
-	if sst.cluster.Conf.LogSST {
-		cluster.LogPrintf(LvlInfo, "Listening for SST on port %d", destinationPort)
-	}
-	SSTs.Lock()
-	SSTs.SSTconnections[destinationPort] = sst
-	SSTs.Unlock()
 	go sst.tcp_con_handle_to_file()
 
-	return strconv.Itoa(destinationPort), nil
+	return strconv.Itoa(sst.port), token, nil
 }
 
-func (sst *SST) tcp_con_handle_to_file() {
-
-	var err error
-
-	defer func() {
-		if sst.cluster.Conf.LogSST {
-			sst.cluster.LogPrintf(LvlInfo, "SST connection end cleanup %d", sst.listener.Addr().(*net.TCPAddr).Port)
-		}
-		port := sst.listener.Addr().(*net.TCPAddr).Port
-		sst.tcplistener.Close()
-		sst.file.Close()
-		sst.listener.Close()
-		SSTs.Lock()
-		delete(SSTs.SSTconnections, port)
-		SSTs.Unlock()
-	}()
-
-	sst.in, err = sst.listener.Accept()
-
+// SSTRunReceiverToStore receives the transfer of task into the backup store, the
+// object is written in key once the transfer is verified
+func (cluster *Cluster) SSTRunReceiverToStore(task string, key string) (string, string, error) {
+	store, err := cluster.GetBackupStore()
 	if err != nil {
-
-		return
+		return "", "", err
 	}
+	w, err := store.Create(key)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Create backup %s in %s failed for job %s %s", key, store.Name(), task, err)
+		return "", "", err
+	}
+	return cluster.SSTRunReceiverToWriter(task, w)
+}
 
-	chan_to_stdout := sst.stream_copy_to_file()
+// SSTRunReceiverToWriter receives the transfer of task into w, it is closed once the
+// transfer is verified and aborted otherwise
+func (cluster *Cluster) SSTRunReceiverToWriter(task string, w backupstore.Writer) (string, string, error) {
+	sst := new(SST)
+	sst.cluster = cluster
+	sst.outstore = w
+	token, err := sst.sstListen(task, sst.outstore)
+	if err != nil {
+		sst.outstore.Abort()
+		return "", "", err
+	}
+	go sst.tcp_con_handle_to_store()
 
-	select {
+	return strconv.Itoa(sst.port), token, nil
+}
 
-	case <-chan_to_stdout:
-		if sst.cluster.Conf.LogSST {
-			sst.cluster.LogPrintf(LvlInfo, "Chan SST out for %d", sst.listener.Addr().(*net.TCPAddr).Port)
-		}
+func (sst *SST) cleanup() {
+	if sst.cluster.Conf.LogSST {
+		sst.cluster.LogPrintf(LvlInfo, "SST connection end cleanup %d", sst.port)
 	}
+	sst.listener.Close()
+	SSTs.Lock()
+	delete(SSTs.SSTconnections, sst.port)
+	SSTs.Unlock()
 }
 
-func (sst *SST) tcp_con_handle_to_restic() {
-
-	var err error
+func (sst *SST) tcp_con_handle_to_file() {
+	defer sst.cleanup()
+	sst.closeFile(sst.receive())
+}
 
+// closeFile appends the spool to the file when the transfer is verified, the spool
+// is removed in any case
+func (sst *SST) closeFile(verified bool) {
+	defer sst.file.Close()
+	if sst.spool == nil {
+		return
+	}
 	defer func() {
-		if sst.cluster.Conf.LogSST {
-			sst.cluster.LogPrintf(LvlInfo, "SST connection end cleanup %d", sst.listener.Addr().(*net.TCPAddr).Port)
-		}
-		port := sst.listener.Addr().(*net.TCPAddr).Port
-		sst.tcplistener.Close()
-		sst.file.Close()
-		sst.listener.Close()
-		SSTs.Lock()
-		delete(SSTs.SSTconnections, port)
-		SSTs.Unlock()
+		sst.spool.Close()
+		os.Remove(sst.spool.Name())
 	}()
-
-	sst.in, err = sst.listener.Accept()
-
+	if !verified {
+		return
+	}
+	_, err := sst.spool.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = io.Copy(sst.file, sst.spool)
+	}
 	if err != nil {
+		sst.cluster.LogPrintf(LvlErr, "SST %s failed appending to %s: %s", sst.transfer.Task, sst.file.Name(), err)
+	}
+}
 
+func (sst *SST) tcp_con_handle_to_store() {
+	defer sst.cleanup()
+	if !sst.receive() {
+		sst.outstore.Abort()
 		return
 	}
-
-	chan_to_stdout := sst.stream_copy_to_restic()
-
-	select {
-
-	case <-chan_to_stdout:
-		if sst.cluster.Conf.LogSST {
-			sst.cluster.LogPrintf(LvlInfo, "Chan SST out for %d", sst.listener.Addr().(*net.TCPAddr).Port)
-		}
+	if err := sst.outstore.Close(); err != nil {
+		sst.cluster.LogPrintf(LvlErr, "SST %s failed writing the backup store: %s", sst.transfer.Task, err)
 	}
 }
 
-// Performs copy operation between streams: os and tcp streams
-func (sst *SST) stream_copy_to_file() <-chan int {
-	buf := make([]byte, 1024)
-	sync_channel := make(chan int)
-	go func() {
-		defer func() {
-			if con, ok := sst.in.(net.Conn); ok {
-
-				if sst.cluster.Conf.LogSST {
-					sst.cluster.LogPrintf(LvlErr, "SST closing connection from stream_copy %v ", con.RemoteAddr())
-				}
-				sst.in.(net.Conn).Close()
-			}
-			sync_channel <- 0 // Notify that processing is finished
-		}()
-		for {
-			var nBytes int
-			var err error
-
-			nBytes, err = sst.in.Read(buf)
-
-			if err != nil {
-				if err != io.EOF {
-					sst.cluster.LogPrintf(LvlErr, "Read error: %s", err)
-				}
-				break
-			}
-			_, err = sst.outfilewriter.Write(buf[0:nBytes])
-			if err != nil {
-				sst.cluster.LogPrintf(LvlErr, "Write error: %s", err)
-			}
-		}
+func (sst *SST) tcp_con_handle_to_restic() {
+	defer func() {
+		sst.cleanup()
+		sst.outresticreader.Close()
 	}()
-	return sync_channel
+	sst.receive()
 }
 
-func (sst *SST) stream_copy_to_restic() <-chan int {
-	buf := make([]byte, 1024)
-	sync_channel := make(chan int)
-	go func() {
-		defer func() {
-			if con, ok := sst.in.(net.Conn); ok {
-
-				if sst.cluster.Conf.LogSST {
-					sst.cluster.LogPrintf(LvlErr, "SST closing connection from stream_copy %v ", con.RemoteAddr())
-				}
-				sst.in.(net.Conn).Close()
-			}
-			sync_channel <- 0 // Notify that processing is finished
-		}()
-		for {
-			var nBytes int
-			var err error
-
-			nBytes, err = sst.in.Read(buf)
-
-			if err != nil {
-				if err != io.EOF {
-					sst.cluster.LogPrintf(LvlErr, "Read error: %s", err)
-				}
-				break
-			}
-			_, err = sst.outresticreader.Write(buf[0:nBytes])
-			if err != nil {
-				sst.cluster.LogPrintf(LvlErr, "Write error: %s", err)
+// receive serves the sender until the transfer completes, an interrupted sender has
+// 120s to reconnect and resume. It returns false when the transfer did not complete.
+func (sst *SST) receive() bool {
+	for {
+		sst.tcplistener.SetDeadline(time.Now().Add(time.Second * 120))
+		conn, err := sst.listener.Accept()
+		if err != nil {
+			sst.cluster.LogPrintf(LvlErr, "SST %s on port %d ended after %d bytes: %s", sst.transfer.Task, sst.port, sst.transfer.Received(), err)
+			return false
+		}
+		sst.in = conn
+		done, err := sst.transfer.Receive(conn)
+		conn.Close()
+		if done {
+			if sst.cluster.Conf.LogSST {
+				sst.cluster.LogPrintf(LvlInfo, "SST %s received and verified %d bytes from %s", sst.transfer.Task, sst.transfer.Received(), conn.RemoteAddr())
 			}
+			return true
 		}
-	}()
-	return sync_channel
+		if err == sstchannel.ErrToken {
+			sst.cluster.LogPrintf(LvlWarn, "SST %s refused connection with invalid token from %s", sst.transfer.Task, conn.RemoteAddr())
+			continue
+		}
+		sst.cluster.LogPrintf(LvlWarn, "SST %s interrupted after %d bytes from %s: %s", sst.transfer.Task, sst.transfer.Received(), conn.RemoteAddr(), err)
+	}
 }
 
+// SSTRunSender sends the backup of the store key to the job receiving it on the
+// database host with the token of the job
 func (cluster *Cluster) SSTRunSender(backupfile string, sv *ServerMonitor) {
-	port, _ := strconv.Atoi(sv.SSTPort)
-	client, err := net.Dial("tcp", fmt.Sprintf("%s:%d", sv.Host, port))
+	store, err := cluster.GetBackupStore()
 	if err != nil {
-		cluster.LogPrintf(LvlErr, "SST Reseed failed connection to port %s server %s %s ", sv.SSTPort, sv.Host, err)
 		return
 	}
-	defer client.Close()
-	file, err := os.Open(backupfile)
+	file, err := store.Open(backupfile)
 	if err != nil {
 		cluster.LogPrintf(LvlErr, "SST failed to open backup file server %s %s ", sv.URL, err)
 		return
 	}
-	/*fileInfo, err := file.Stat()
+	defer file.Close()
+	cluster.sstSend(backupfile, file, sv)
+}
+
+func (cluster *Cluster) sstSend(backupfile string, file io.Reader, sv *ServerMonitor) {
+	tlsConfig, err := cluster.sstClientConfig()
 	if err != nil {
-		fmt.Println(err)
+		cluster.LogPrintf(LvlErr, "SST Reseed failed TLS configuration server %s %s ", sv.URL, err)
+		return
+	}
+	task, token := sv.GetSSTJob()
+	sender := &sstchannel.Sender{
+		Task:      task,
+		Token:     token,
+		Bandwidth: int64(cluster.Conf.SSTBandwidth) * 1024,
+		Retries:   sstRetries,
+		Dial: func() (io.ReadWriteCloser, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", net.JoinHostPort(misc.Unbracket(sv.Host), sv.SSTPort), tlsConfig)
+		},
+	}
+	cluster.LogPrintf(LvlInfo, "Start sending backup %s to server %s", backupfile, sv.URL)
+	err = sender.Send(file)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "SST Reseed failed sending to port %s server %s %s ", sv.SSTPort, sv.Host, err)
 		return
-	}*/
-
-	sendBuffer := make([]byte, 16384)
-	fmt.Println("Start sending file!")
-	for {
-		_, err = file.Read(sendBuffer)
-		if err == io.EOF {
-			break
-		}
-		client.Write(sendBuffer)
 	}
 	cluster.LogPrintf(LvlInfo, "Backup has been sent, closing connection!")
-
 }
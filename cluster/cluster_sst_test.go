@@ -0,0 +1,49 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSSTAppendSpool(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "log_error.log")
+	if err := ioutil.WriteFile(filename, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, verified := range []bool{false, true} {
+		sst := &SST{cluster: new(Cluster)}
+		var err error
+		sst.file, err = os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sst.spool, err = ioutil.TempFile(dir, ".log_error.log.sst")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sst.spool.WriteString("new\n")
+		sst.closeFile(verified)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "old\nnew\n" {
+		t.Errorf("Expected only the verified transfer appended, got %q", data)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected the spool files removed, found %d files", len(files))
+	}
+}
@@ -144,6 +144,11 @@ func (cluster *Cluster) SwitchSchedulerBackupPhysical() {
 	cluster.SetSchedulerBackupPhysical()
 }
 
+func (cluster *Cluster) SwitchSchedulerBackupIncremental() {
+	cluster.Conf.SchedulerBackupIncremental = !cluster.Conf.SchedulerBackupIncremental
+	cluster.SetSchedulerBackupIncremental()
+}
+
 func (cluster *Cluster) SwitchSchedulerDbJobsSsh() {
 	cluster.Conf.SchedulerJobsSSH = !cluster.Conf.SchedulerJobsSSH
 	cluster.SetSchedulerDbJobsSsh()
@@ -173,6 +178,20 @@ func (cluster *Cluster) SwitchSchedulerRollingReprov() {
 	cluster.SetSchedulerRollingReprov()
 }
 
+func (cluster *Cluster) SwitchSchedulerChaos() {
+	cluster.Conf.SchedulerChaos = !cluster.Conf.SchedulerChaos
+	cluster.SetSchedulerChaos()
+}
+
+func (cluster *Cluster) SwitchSchedulerChecksum() {
+	cluster.Conf.SchedulerChecksum = !cluster.Conf.SchedulerChecksum
+	cluster.SetSchedulerChecksum()
+}
+
+func (cluster *Cluster) SwitchChecksumRepair() {
+	cluster.Conf.ChecksumRepair = !cluster.Conf.ChecksumRepair
+}
+
 func (cluster *Cluster) SwitchGraphiteEmbedded() {
 	cluster.Conf.GraphiteEmbedded = !cluster.Conf.GraphiteEmbedded
 }
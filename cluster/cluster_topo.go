@@ -32,6 +32,7 @@ const (
 	topoMultiMasterWsrep    string = "multi-master-wsrep"
 	topoMasterSlavePgLog    string = "master-slave-pg-logical"
 	topoMasterSlavePgStream string = "master-slave-pg-stream"
+	topoGroupReplication    string = "group-replication"
 )
 
 func (cluster *Cluster) newServerList() error {
@@ -130,6 +131,12 @@ func (cluster *Cluster) TopologyDiscover(wcg *sync.WaitGroup) error {
 	cluster.TopologyClusterDown()
 	// Check topology Cluster all servers down
 	cluster.IsDown = cluster.AllServersFailed()
+	if cluster.IsDown && cluster.GetTopology() == topoMultiMasterWsrep && cluster.Conf.MultiMasterWsrepAutoBootstrap && cluster.IsActive() && cluster.lockWsrepBootstrap() {
+		go func() {
+			defer cluster.unlockWsrepBootstrap()
+			cluster.wsrepBootstrap()
+		}()
+	}
 	cluster.CheckSameServerID()
 	// Spider shard discover
 	if cluster.Conf.Spider == true {
@@ -141,6 +148,16 @@ func (cluster *Cluster) TopologyDiscover(wcg *sync.WaitGroup) error {
 		if sv.IsFailed() {
 			continue
 		}
+		// the group elects its primary, secondaries have no asynchronous replication
+		if cluster.GetTopology() == topoGroupReplication && sv.HaveGroupReplication {
+			if sv.IsGroupReplicationPrimary {
+				cluster.master = cluster.Servers[k]
+				cluster.master.SetMaster()
+			} else {
+				cluster.slaves = append(cluster.slaves, sv)
+			}
+			continue
+		}
 		// count wsrep node as  slaves
 		if sv.IsSlave || sv.IsWsrepPrimary {
 			if cluster.Conf.LogLevel > 2 {
@@ -183,8 +200,15 @@ func (cluster *Cluster) TopologyDiscover(wcg *sync.WaitGroup) error {
 		cluster.SetState("ERR00010", state.State{ErrType: "ERROR", ErrDesc: fmt.Sprintf(clusterError["ERR00010"]), ErrFrom: "TOPO"})
 	}
 
+	if cluster.GetTopology() == topoGroupReplication {
+		cluster.CheckGroupReplicationQuorum()
+	}
+	if cluster.GetTopology() == topoMultiMasterWsrep {
+		cluster.CheckWsrep()
+	}
+
 	// Check that all slave servers have the same master and conformity.
-	if cluster.Conf.MultiMaster == false && cluster.Conf.Spider == false {
+	if cluster.Conf.MultiMaster == false && cluster.Conf.Spider == false && cluster.GetTopology() != topoGroupReplication {
 		for _, sl := range cluster.slaves {
 			if sl.IsMaxscale == false && !sl.IsFailed() {
 				sl.CheckSlaveSettings()
@@ -246,7 +270,7 @@ func (cluster *Cluster) TopologyDiscover(wcg *sync.WaitGroup) error {
 		}
 	}
 
-	if cluster.slaves != nil {
+	if cluster.slaves != nil && cluster.GetTopology() != topoGroupReplication {
 		if len(cluster.slaves) > 0 {
 			// Depending if we are doing a failover or a switchover, we will find the master in the list of
 			// failed hosts or unconnected hosts.
@@ -300,7 +324,7 @@ func (cluster *Cluster) TopologyDiscover(wcg *sync.WaitGroup) error {
 			cluster.master.CheckMasterSettings()
 		}
 		// Replication checks
-		if cluster.Conf.MultiMaster == false {
+		if cluster.Conf.MultiMaster == false && cluster.GetTopology() != topoGroupReplication {
 			for _, sl := range cluster.slaves {
 
 				if sl.IsRelay == false {
@@ -405,6 +429,27 @@ func (cluster *Cluster) TopologyClusterDown() bool {
 	return false
 }
 
+// CheckGroupReplicationQuorum reports members not online in the group view and a member that can
+// not reach a majority of the group, such partition blocks all writes
+func (cluster *Cluster) CheckGroupReplicationQuorum() {
+	for _, s := range cluster.Servers {
+		if s.IsFailed() || !s.IsGroupReplicationOnline() {
+			continue
+		}
+		online := 0
+		for _, m := range s.GroupReplicationMembers {
+			if m.MemberState == "ONLINE" {
+				online++
+			} else {
+				cluster.SetState("WARN0101", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0101"], m.MemberHost+":"+m.MemberPort, m.MemberState), ErrFrom: "TOPO", ServerUrl: s.URL})
+			}
+		}
+		if !s.HasGroupReplicationQuorum() {
+			cluster.SetState("ERR00085", state.State{ErrType: LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00085"], s.URL, online, len(s.GroupReplicationMembers)), ErrFrom: "TOPO", ServerUrl: s.URL})
+		}
+	}
+}
+
 func (cluster *Cluster) PrintTopology() {
 	for k, v := range cluster.Servers {
 		cluster.LogPrintf(LvlInfo, "Server [%d] %s %s %s", k, v.URL, v.State, v.PrevState)
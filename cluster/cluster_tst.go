@@ -12,8 +12,10 @@ package cluster
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"log"
 	"os/exec"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,6 +37,35 @@ type Test struct {
 	ConfigFile string        `json:"config-file"`
 	ConfigInit config.Config `json:"config-init"`
 	ConfigTest config.Config `json:"config-test"`
+	Start      time.Time     `json:"start"`
+	Duration   float64       `json:"duration"`
+	Steps      []TestStep    `json:"steps"`
+	Logs       []string      `json:"logs"`
+	ConfigDiff []string      `json:"config-diff"`
+	Topology   string        `json:"topology"`
+	Servers    []string      `json:"servers"`
+}
+
+// TestStep times a phase of a test, durations are in seconds
+type TestStep struct {
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	running  bool
+}
+
+// StartStep ends the running step of the test and starts a new one
+func (test *Test) StartStep(name string) {
+	test.EndStep()
+	test.Steps = append(test.Steps, TestStep{Name: name, Start: time.Now(), running: true})
+}
+
+func (test *Test) EndStep() {
+	if len(test.Steps) > 0 && test.Steps[len(test.Steps)-1].running {
+		step := &test.Steps[len(test.Steps)-1]
+		step.Duration = time.Since(step.Start).Seconds()
+		step.running = false
+	}
 }
 
 func (cluster *Cluster) PrepareBench() error {
@@ -337,6 +368,15 @@ func (cluster *Cluster) InitBenchTable() error {
 }
 
 func (cluster *Cluster) InitTestCluster(conf string, test *Test) bool {
+	test.Start = time.Now()
+	test.Duration = 0
+	test.Steps = nil
+	test.Logs = nil
+	test.ConfigDiff = nil
+	test.Topology = ""
+	test.Servers = nil
+	test.StartStep("init")
+	cluster.startTestLog()
 	test.ConfigInit = cluster.Conf
 	savedConf = cluster.Conf
 	savedFailoverCtr = cluster.FailoverCtr
@@ -347,24 +387,86 @@ func (cluster *Cluster) InitTestCluster(conf string, test *Test) bool {
 		if err != nil {
 			cluster.LogPrintf(LvlErr, "Abording test, bootstrap failed, %s", err)
 			cluster.Unprovision()
+			test.EndStep()
+			test.Duration = time.Since(test.Start).Seconds()
+			test.Logs = cluster.stopTestLog()
 			return false
 		}
 	}
 	cluster.LogPrintf(LvlInfo, "Starting Test %s", test.Name)
+	test.StartStep("run")
 	return true
 }
 
 func (cluster *Cluster) CloseTestCluster(conf string, test *Test) bool {
+	test.StartStep("close")
 	test.ConfigTest = cluster.Conf
+	test.ConfigDiff = getConfigDiff(test.ConfigInit, test.ConfigTest)
+	test.Topology = cluster.GetTopology()
+	for _, s := range cluster.Servers {
+		if s != nil {
+			test.Servers = append(test.Servers, s.URL+" "+s.State)
+		}
+	}
 	if cluster.testStopCluster {
 		cluster.Unprovision()
 		cluster.WaitClusterStop()
 	}
 	cluster.RestoreConf()
-
+	test.EndStep()
+	test.Duration = time.Since(test.Start).Seconds()
+	test.Logs = cluster.stopTestLog()
 	return true
 }
 
+func (cluster *Cluster) startTestLog() {
+	cluster.testLogMutex.Lock()
+	defer cluster.testLogMutex.Unlock()
+	cluster.testLogs = []string{}
+}
+
+func (cluster *Cluster) stopTestLog() []string {
+	cluster.testLogMutex.Lock()
+	defer cluster.testLogMutex.Unlock()
+	logs := cluster.testLogs
+	cluster.testLogs = nil
+	return logs
+}
+
+// addTestLog keeps the log lines printed while a test is running
+func (cluster *Cluster) addTestLog(line string) {
+	cluster.testLogMutex.Lock()
+	defer cluster.testLogMutex.Unlock()
+	if cluster.testLogs != nil {
+		cluster.testLogs = append(cluster.testLogs, line)
+	}
+}
+
+// getConfigDiff returns the settings changed by a test as name: before -> after, secrets are masked
+func getConfigDiff(before config.Config, after config.Config) []string {
+	diff := []string{}
+	vb := reflect.ValueOf(before)
+	va := reflect.ValueOf(after)
+	t := vb.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || reflect.DeepEqual(vb.Field(i).Interface(), va.Field(i).Interface()) {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("toml"), ",")[0]
+		if name == "" || name == "-" {
+			name = f.Name
+		}
+		lname := strings.ToLower(f.Name)
+		if strings.Contains(lname, "pass") || strings.Contains(lname, "secret") || strings.Contains(lname, "credential") || strings.Contains(lname, "token") || strings.HasSuffix(lname, "key") {
+			diff = append(diff, name+": XXXXXXXX")
+			continue
+		}
+		diff = append(diff, fmt.Sprintf("%s: %v -> %v", name, vb.Field(i).Interface(), va.Field(i).Interface()))
+	}
+	return diff
+}
+
 func (cluster *Cluster) SwitchoverWaitTest() {
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
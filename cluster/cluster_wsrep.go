@@ -0,0 +1,185 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// CheckWsrep reports nodes out of the primary component, recovers the quorum when no node is primary
+// and pins the preferred SST donors
+func (cluster *Cluster) CheckWsrep() {
+	alive := 0
+	primary := 0
+	for _, s := range cluster.Servers {
+		if s.IsFailed() || !s.HaveWsrep {
+			continue
+		}
+		alive++
+		if s.IsWsrepPrimary {
+			primary++
+		} else {
+			cluster.SetState("ERR00086", state.State{ErrType: LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00086"], s.URL), ErrFrom: "TOPO", ServerUrl: s.URL})
+		}
+	}
+	if alive > 0 && primary == 0 {
+		cluster.SetState("ERR00087", state.State{ErrType: LvlErr, ErrDesc: clusterError["ERR00087"], ErrFrom: "TOPO"})
+		// a node not reachable by the monitor may still be primary in an other partition
+		if cluster.Conf.MultiMasterWsrepAutoBootstrap && cluster.IsActive() && alive == len(cluster.Servers) {
+			cluster.WsrepRecoverQuorum()
+		}
+	}
+	if primary > 0 && cluster.Conf.MultiMasterWsrepDonorHosts != "" && cluster.IsActive() {
+		cluster.SetWsrepDonors()
+	}
+}
+
+// WsrepRecoverQuorum bootstraps a new primary component on the running node with the highest committed seqno
+func (cluster *Cluster) WsrepRecoverQuorum() error {
+	var best *ServerMonitor
+	for _, s := range cluster.Servers {
+		if s.IsFailed() || !s.HaveWsrep {
+			continue
+		}
+		cluster.LogPrintf(LvlInfo, "Galera node %s last committed %d", s.URL, s.GetWsrepLastCommitted())
+		if best == nil || s.GetWsrepLastCommitted() > best.GetWsrepLastCommitted() || (s.GetWsrepLastCommitted() == best.GetWsrepLastCommitted() && cluster.IsInPreferedHosts(s)) {
+			best = s
+		}
+	}
+	if best == nil {
+		return errors.New("No running Galera node")
+	}
+	cluster.LogPrintf(LvlInfo, "Bootstrapping primary component on most advanced node %s", best.URL)
+	return best.WsrepBootstrapPrimary()
+}
+
+// lockWsrepBootstrap reserves the bootstrap of the cluster, it returns false when a bootstrap is
+// already running so that two nodes never start a new cluster
+func (cluster *Cluster) lockWsrepBootstrap() bool {
+	cluster.wsrepBootstrapMutex.Lock()
+	defer cluster.wsrepBootstrapMutex.Unlock()
+	if cluster.inWsrepBootstrap {
+		return false
+	}
+	cluster.inWsrepBootstrap = true
+	return true
+}
+
+func (cluster *Cluster) unlockWsrepBootstrap() {
+	cluster.wsrepBootstrapMutex.Lock()
+	cluster.inWsrepBootstrap = false
+	cluster.wsrepBootstrapMutex.Unlock()
+}
+
+// WsrepBootstrap restarts a fully down cluster from the node with the highest saved seqno, all nodes
+// must be reachable over ssh to compare their grastate
+func (cluster *Cluster) WsrepBootstrap() error {
+	if !cluster.lockWsrepBootstrap() {
+		return errors.New("Galera bootstrap already running")
+	}
+	defer cluster.unlockWsrepBootstrap()
+	return cluster.wsrepBootstrap()
+}
+
+func (cluster *Cluster) wsrepBootstrap() error {
+
+	var best *ServerMonitor
+	var bestState dbhelper.Grastate
+	for _, s := range cluster.Servers {
+		gs, err := s.JobGetGrastate()
+		if err != nil {
+			cluster.SetState("ERR00088", state.State{ErrType: LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00088"], s.URL+" "+err.Error()), ErrFrom: "TOPO", ServerUrl: s.URL})
+			return err
+		}
+		cluster.LogPrintf(LvlInfo, "Galera node %s saved state uuid %s seqno %d safe_to_bootstrap %t", s.URL, gs.UUID, gs.Seqno, gs.SafeToBootstrap)
+		if best == nil || gs.Seqno > bestState.Seqno || (gs.Seqno == bestState.Seqno && gs.SafeToBootstrap && !bestState.SafeToBootstrap) {
+			best = s
+			bestState = gs
+		}
+	}
+	if best == nil {
+		return errors.New("No Galera node")
+	}
+	cluster.LogPrintf(LvlInfo, "Galera node %s elected for bootstrap with seqno %d", best.URL, bestState.Seqno)
+	err := best.JobBootstrapWsrep()
+	if err != nil {
+		cluster.SetState("ERR00088", state.State{ErrType: LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00088"], best.URL+" "+err.Error()), ErrFrom: "TOPO", ServerUrl: best.URL})
+		return err
+	}
+	up := false
+	for i := 0; i < 60 && !up; i++ {
+		up = best.Conn != nil && best.Conn.Ping() == nil
+		if !up {
+			time.Sleep(time.Second)
+		}
+	}
+	if !up {
+		return errors.New("Galera node " + best.URL + " not up after bootstrap")
+	}
+	for _, s := range cluster.Servers {
+		if s.URL == best.URL {
+			continue
+		}
+		err = s.JobStartWsrep()
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Could not start Galera node %s: %s", s.URL, err)
+		}
+	}
+	return nil
+}
+
+// GetWsrepDonors returns the wsrep_sst_donor value of the preferred donors, the trailing comma keeps
+// the other nodes as fallback donors
+func (cluster *Cluster) GetWsrepDonors() string {
+	var names []string
+	for _, host := range strings.Split(cluster.Conf.MultiMasterWsrepDonorHosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		found := false
+		for _, s := range cluster.Servers {
+			if s.URL == host || s.Host == host {
+				found = true
+				if s.GetWsrepNodeName() != "" {
+					names = append(names, s.GetWsrepNodeName())
+				}
+			}
+		}
+		if !found {
+			cluster.SetState("WARN0102", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0102"], host), ErrFrom: "TOPO"})
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.Join(names, ",") + ","
+}
+
+// SetWsrepDonors pins the preferred donors on every running node
+func (cluster *Cluster) SetWsrepDonors() {
+	donors := cluster.GetWsrepDonors()
+	if donors == "" {
+		return
+	}
+	for _, s := range cluster.Servers {
+		if s.IsFailed() || !s.HaveWsrep || s.Variables["WSREP_SST_DONOR"] == donors {
+			continue
+		}
+		cluster.LogPrintf(LvlInfo, "Setting preferred SST donors %s on %s", donors, s.URL)
+		s.SetWsrepSSTDonor(donors)
+	}
+}
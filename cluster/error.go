@@ -92,6 +92,10 @@ var clusterError = map[string]string{
 	"ERR00082": "Could not get agents from orchestrator %s",
 	"ERR00083": "Different cluster uuid found on %s:%s %s:%s",
 	"ERR00084": "Cluster have no master when slave %s was started",
+	"ERR00085": "Group replication lost quorum on %s, %d online members out of %d",
+	"ERR00086": "Galera node %s is in a non-primary component",
+	"ERR00087": "Galera cluster has no primary component",
+	"ERR00088": "Galera cluster bootstrap failed: %s",
 	"WARN0022": "Rejoining standalone server %s to master %s",
 	"WARN0023": "Number of failed master ping has been reached",
 	"WARN0045": "Provision task is in queue",
@@ -139,7 +143,7 @@ var clusterError = map[string]string{
 	"WARN0088": "High number of slow queries %s ",
 	"WARN0089": "ShardProxy Could not fetch master schemas %s",
 	"WARN0090": "Cluster arbitrator unreachable %s",
-	"WARN0091": "Server as errant transaction %s",
+	"WARN0091": "Server %s has errant transactions %s",
 	"WARN0092": "ProxySQL could not load query rules from runtime (%s)",
 	"WARN0093": "Restic fetch repo issue: %s\n%s\n%s",
 	"WARN0094": "Restic purge repo issue: %s\n%s\n%s",
@@ -149,4 +153,13 @@ var clusterError = map[string]string{
 	"WARN0098": "ProxySQL could not load global variables from runtime (%s)",
 	"WARN0099": "MariaDB version as replication issue https://jira.mariadb.org/browse/MDEV-20821",
 	"WARN0100": "No space left on device pn %s",
+	"WARN0101": "Group replication member %s is in state %s",
+	"WARN0102": "Galera preferred donor %s is not in the cluster",
+	"WARN0103": "Chaos fault %s broke invariant %s: %s",
+	"WARN0104": "Table %s diverges from master on slave %s in %d chunks",
+	"WARN0105": "Digest %s is %.1f times slower on %s with a p95 latency of %.1fms instead of %.1fms",
+	"WARN0106": "Disk of %s is forecast full in %.1f days, growing by %.0fMB a day",
+	"WARN0107": "Connections of %s are forecast to reach max_connections %.0f in %.1f days",
+	"WARN0108": "Configuration drift dangerous for failover: %s",
+	"WARN0109": "Binlog server can not follow master %s: %s",
 }
@@ -108,6 +108,10 @@ func (cluster *Cluster) newProxyList() error {
 	if cluster.Conf.MyproxyOn {
 		nbproxies++
 	}
+	// internal gobetween
+	if cluster.Conf.GobetweenOn {
+		nbproxies++
+	}
 	cluster.Proxies = make([]*Proxy, nbproxies)
 
 	cluster.LogPrintf(LvlInfo, "Loading %d proxies", nbproxies)
@@ -326,6 +330,24 @@ func (cluster *Cluster) newProxyList() error {
 		cluster.Proxies[ctproxy], err = cluster.newProxy(prx)
 		ctproxy++
 	}
+	if cluster.Conf.GobetweenOn {
+		prx := new(Proxy)
+		prx.Type = config.ConstProxyGobetween
+		ports := cluster.reservePorts("gobetween", cluster.Conf.GobetweenWritePort, cluster.Conf.GobetweenReadPort)
+		prx.Port = strconv.Itoa(ports[0])
+		prx.Host = cluster.Conf.GobetweenBindIp
+		prx.ReadPort = ports[1]
+		prx.WritePort = ports[0]
+		prx.ReadWritePort = ports[0]
+		prx.Name = prx.Host
+		prx.Id = "px" + strconv.FormatUint(crc64.Checksum([]byte(cluster.Name+prx.Name+":"+strconv.Itoa(prx.WritePort)), crcTable), 10)
+		prx.ClusterGroup = cluster
+		prx.SetDataDir()
+		prx.SetServiceName(cluster.Name, prx.Name)
+		cluster.LogPrintf(LvlInfo, "New proxy monitored %s: %s:%s", prx.Type, prx.Host, prx.Port)
+		cluster.Proxies[ctproxy], err = cluster.newProxy(prx)
+		ctproxy++
+	}
 
 	return nil
 }
@@ -459,6 +481,12 @@ func (cluster *Cluster) refreshProxies(wcg *sync.WaitGroup) {
 		if cluster.Conf.SphinxOn && pr.Type == config.ConstProxySphinx {
 			err = cluster.refreshSphinx(pr)
 		}
+		if cluster.Conf.GobetweenOn && pr.Type == config.ConstProxyGobetween {
+			err = cluster.refreshGoBetween(pr)
+		}
+		if cluster.Conf.MyproxyOn && pr.Type == config.ConstProxyMyProxy {
+			err = cluster.refreshMyProxy(pr)
+		}
 		if err == nil {
 			pr.FailCount = 0
 			pr.State = stateProxyRunning
@@ -508,6 +536,12 @@ func (cluster *Cluster) failoverProxies() {
 		if cluster.Conf.ProxysqlOn && pr.Type == config.ConstProxySqlproxy {
 			cluster.failoverProxysql(pr)
 		}
+		if cluster.Conf.GobetweenOn && pr.Type == config.ConstProxyGobetween {
+			cluster.initGoBetween(cluster.oldMaster, pr)
+		}
+		if cluster.Conf.MyproxyOn && pr.Type == config.ConstProxyMyProxy {
+			cluster.failoverMyProxy(pr)
+		}
 	}
 	cluster.initConsul()
 }
@@ -530,6 +564,9 @@ func (cluster *Cluster) initProxies() {
 		if cluster.Conf.MyproxyOn && pr.Type == config.ConstProxyMyProxy {
 			cluster.initMyProxy(pr)
 		}
+		if cluster.Conf.GobetweenOn && pr.Type == config.ConstProxyGobetween {
+			cluster.initGoBetween(nil, pr)
+		}
 	}
 	cluster.initConsul()
 }
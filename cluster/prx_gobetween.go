@@ -6,11 +6,119 @@
 
 package cluster
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	gbconfig "github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/manager"
+	"github.com/signal18/replication-manager/router/gobetween/stats"
+)
+
+// the gobetween manager is process wide and shared by all clusters
+var gobetweenOnce sync.Once
+
+func (proxy *Proxy) getGoBetweenServerName(service string) string {
+	return proxy.ClusterGroup.Name + "_" + proxy.Id + "_" + service
+}
+
+// getGoBetweenBackends returns the master for the write service and the healthy slaves for the read service,
+// reads fall back to the master when no slave is usable
+func (cluster *Cluster) getGoBetweenBackends() ([]string, []string) {
+	var write, read []string
+	master := cluster.GetMaster()
+	if master != nil && !master.IsDown() {
+		write = append(write, master.Host+":"+master.Port)
+	}
+	for _, s := range cluster.slaves {
+		if s.State != stateSlave || s.IsMaintenance || s.IsIgnored() || s.IsDelayed {
+			continue
+		}
+		read = append(read, s.Host+":"+s.Port)
+	}
+	if len(read) == 0 {
+		read = write
+	}
+	return write, read
+}
+
+// setGoBetweenServer creates the gobetween server or updates its backends in place when the list
+// changed, a static discovery is used as replication-manager is the one tracking the topology
+func (cluster *Cluster) setGoBetweenServer(name string, bind string, balance string, backends []string) error {
+	if cur, ok := manager.Get(name).(gbconfig.Server); ok {
+		if cur.Discovery != nil && strings.Join(cur.Discovery.StaticList, ",") == strings.Join(backends, ",") {
+			return nil
+		}
+		return manager.UpdateStaticBackends(name, backends)
+	}
+	return manager.Create(name, gbconfig.Server{
+		Bind:     bind,
+		Protocol: "tcp",
+		Balance:  balance,
+		Discovery: &gbconfig.DiscoveryConfig{
+			Kind:                  "static",
+			StaticDiscoveryConfig: &gbconfig.StaticDiscoveryConfig{StaticList: backends},
+		},
+	})
+}
+
 func (cluster *Cluster) initGoBetween(oldmaster *ServerMonitor, proxy *Proxy) {
+	gobetweenOnce.Do(func() {
+		manager.Initialize(gbconfig.Config{Servers: map[string]gbconfig.Server{}})
+	})
+	write, read := cluster.getGoBetweenBackends()
+	if oldmaster != nil {
+		cluster.LogPrintf(LvlInfo, "Gobetween switching write service from %s to %v", oldmaster.URL, write)
+	}
+	err := cluster.setGoBetweenServer(proxy.getGoBetweenServerName("write"), proxy.Host+":"+strconv.Itoa(proxy.WritePort), "weight", write)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Could not start gobetween write service on port %d: %s", proxy.WritePort, err)
+	}
+	err = cluster.setGoBetweenServer(proxy.getGoBetweenServerName("read"), proxy.Host+":"+strconv.Itoa(proxy.ReadPort), cluster.Conf.GobetweenReadBalance, read)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Could not start gobetween read service on port %d: %s", proxy.ReadPort, err)
+	}
+}
 
+// getGoBetweenStats maps the backend counters of a gobetween server to proxy backends
+func (cluster *Cluster) getGoBetweenStats(name string) ([]Backend, error) {
+	st, ok := stats.GetStats(name).(stats.Stats)
+	if !ok {
+		return nil, errors.New("No gobetween stats for " + name)
+	}
+	var backends []Backend
+	for _, b := range st.Backends {
+		bke := Backend{
+			Host:           b.Host,
+			Port:           b.Port,
+			PrxName:        b.Address(),
+			PrxStatus:      "DOWN",
+			PrxConnections: strconv.FormatUint(uint64(b.Stats.ActiveConnections), 10),
+			PrxByteOut:     strconv.FormatUint(b.Stats.TxBytes, 10),
+			PrxByteIn:      strconv.FormatUint(b.Stats.RxBytes, 10),
+		}
+		if b.Stats.Live {
+			bke.PrxStatus = "UP"
+		}
+		if srv := cluster.GetServerFromURL(b.Address()); srv != nil {
+			bke.Status = srv.State
+			bke.PrxMaintenance = srv.IsMaintenance
+		}
+		backends = append(backends, bke)
+	}
+	return backends, nil
 }
 
 func (cluster *Cluster) refreshGoBetween(proxy *Proxy) error {
-
-	return nil
+	// follow slaves going in and out of the read pool between failovers
+	cluster.initGoBetween(nil, proxy)
+	var err error
+	proxy.BackendsWrite, err = cluster.getGoBetweenStats(proxy.getGoBetweenServerName("write"))
+	if err != nil {
+		return err
+	}
+	proxy.BackendsRead, err = cluster.getGoBetweenStats(proxy.getGoBetweenServerName("read"))
+	return err
 }
@@ -0,0 +1,128 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	gbconfig "github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/manager"
+)
+
+func TestReservePorts(t *testing.T) {
+	c1 := &Cluster{Name: "gb1"}
+	c2 := &Cluster{Name: "gb2"}
+	if p := c1.reservePorts("gobetween", 23308, 23309); p[0] != 23308 || p[1] != 23309 {
+		t.Errorf("Expected the configured ports for the first cluster got %v", p)
+	}
+	if p := c2.reservePorts("gobetween", 23308, 23309); p[0] != 23318 || p[1] != 23319 {
+		t.Errorf("Expected shifted ports for the second cluster got %v", p)
+	}
+	if p := c1.reservePorts("gobetween", 23308, 23309); p[0] != 23308 || p[1] != 23309 {
+		t.Errorf("Expected the same ports when reloading the first cluster got %v", p)
+	}
+	if p := c1.reservePorts("binlog", 23318); p[0] != 23328 {
+		t.Errorf("Expected another service not to share ports got %v", p)
+	}
+}
+
+// nameServer answers its name to every line
+func nameServer(t *testing.T, name string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					if _, err := r.ReadString('\n'); err != nil {
+						return
+					}
+					conn.Write([]byte(name + "\n"))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// askName returns the name of the backend of the connection, empty when it was closed
+func askName(conn net.Conn, r *bufio.Reader) string {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("?\n")); err != nil {
+		return ""
+	}
+	name, _ := r.ReadString('\n')
+	return strings.TrimSpace(name)
+}
+
+// dialName connects until the balancer elects a backend answering want
+func dialName(t *testing.T, bind string, want string) (net.Conn, *bufio.Reader) {
+	var name string
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", bind)
+		if err == nil {
+			r := bufio.NewReader(conn)
+			if name = askName(conn, r); name == want {
+				return conn, r
+			}
+			conn.Close()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Expected backend %s got %q", want, name)
+	return nil, nil
+}
+
+func TestGoBetweenUpdateBackends(t *testing.T) {
+	gobetweenOnce.Do(func() {
+		manager.Initialize(gbconfig.Config{Servers: map[string]gbconfig.Server{}})
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bind := l.Addr().String()
+	l.Close()
+
+	cluster := &Cluster{Name: "gb"}
+	name := "gb_test_write"
+	defer manager.Delete(name)
+	first, second := nameServer(t, "first"), nameServer(t, "second")
+	if err := cluster.setGoBetweenServer(name, bind, "weight", []string{first}); err != nil {
+		t.Fatal(err)
+	}
+	conn, r := dialName(t, bind, "first")
+	defer conn.Close()
+
+	if err := cluster.setGoBetweenServer(name, bind, "weight", []string{second}); err != nil {
+		t.Fatal(err)
+	}
+	if cur := manager.Get(name).(gbconfig.Server); strings.Join(cur.Discovery.StaticList, ",") != second {
+		t.Errorf("Expected the configuration to list the new backend got %v", cur.Discovery.StaticList)
+	}
+	if n := askName(conn, r); n != "first" {
+		t.Errorf("Expected the connection kept on the first backend got %q", n)
+	}
+	c, _ := dialName(t, bind, "second")
+	c.Close()
+}
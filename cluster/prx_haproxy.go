@@ -250,11 +250,11 @@ func (cluster *Cluster) refreshHaproxy(proxy *Proxy) error {
 					PrxByteOut:     line[9],
 					PrxLatency:     line[61],
 				})
-				if (srv.State == stateSlaveErr || srv.State == stateRelayErr || srv.State == stateSlaveLate || srv.State == stateRelayLate || srv.IsIgnored()) && line[17] == "UP" {
+				if (srv.State == stateSlaveErr || srv.State == stateRelayErr || srv.State == stateSlaveLate || srv.State == stateRelayLate || srv.State == stateWsrepDonor || srv.State == stateWsrepLate || srv.IsIgnored()) && line[17] == "UP" {
 					cluster.LogPrintf(LvlInfo, "Detecting broken resplication and UP state in haproxy %s drain  server %s", proxy.Host+":"+proxy.Port, srv.URL)
 					haRuntime.SetDrain(srv.Id, cluster.Conf.HaproxyAPIReadBackend)
 				}
-				if (srv.State == stateSlave || srv.State == stateRelay) && line[17] == "DRAIN" {
+				if (srv.State == stateSlave || srv.State == stateRelay || srv.State == stateWsrep) && line[17] == "DRAIN" {
 					cluster.LogPrintf(LvlInfo, "Detecting valid resplication and DRAIN state in haproxy %s enable traffic on server %s", proxy.Host+":"+proxy.Port, srv.URL)
 					haRuntime.SetReady(srv.Id, cluster.Conf.HaproxyAPIReadBackend)
 				}
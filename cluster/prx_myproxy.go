@@ -1,21 +1,81 @@
 package cluster
 
 import (
-	"database/sql"
+	"errors"
+	"net"
+	"strconv"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/signal18/replication-manager/router/myproxy"
+	"github.com/signal18/replication-manager/utils/misc"
 )
 
 func (cluster *Cluster) initMyProxy(proxy *Proxy) {
 	if proxy.InternalProxy != nil {
 		proxy.InternalProxy.Close()
 	}
-	db, err := sql.Open("mysql", cluster.master.DSN)
-	if err != nil {
-		cluster.LogPrintf(LvlErr, "Could not connect to Master for MyProxy %s", err)
+	proxy.InternalProxy, _ = myproxy.NewProxyServer("0.0.0.0:"+proxy.Port, proxy.User, proxy.Pass, cluster.dbUser, cluster.dbPass)
+	// autocommit reads wait for the failure detection and the switch of the writer
+	proxy.InternalProxy.RetryTimeout = time.Duration(cluster.Conf.MonitoringTicker*int64(cluster.Conf.MaxFail+1)+cluster.Conf.SwitchWaitTrx) * time.Second
+	proxy.InternalProxy.LogPrintf = cluster.LogPrintf
+	cluster.setMyProxyBackends(proxy)
+	go proxy.InternalProxy.Run()
+}
+
+// setMyProxyBackends sends the master and the slaves under the delay threshold to the proxy
+func (cluster *Cluster) setMyProxyBackends(proxy *Proxy) {
+	writer := ""
+	if cluster.master != nil && !cluster.master.IsDown() {
+		writer = net.JoinHostPort(misc.Unbracket(cluster.master.Host), cluster.master.Port)
+	}
+	var readers []string
+	for _, s := range cluster.slaves {
+		if s.State != stateSlave || s.IsMaintenance || s.IsIgnored() || s.IsDelayed || s.GetReplicationDelay() > cluster.Conf.MyproxyMaxDelay {
+			continue
+		}
+		readers = append(readers, net.JoinHostPort(misc.Unbracket(s.Host), s.Port))
+	}
+	proxy.InternalProxy.SetBackends(writer, readers)
+}
+
+func (cluster *Cluster) failoverMyProxy(proxy *Proxy) {
+	if proxy.InternalProxy == nil {
+		cluster.initMyProxy(proxy)
 		return
 	}
-	proxy.InternalProxy, _ = myproxy.NewProxyServer("0.0.0.0:"+proxy.Port, proxy.User, proxy.Pass, db)
-	go proxy.InternalProxy.Run()
+	cluster.setMyProxyBackends(proxy)
+}
+
+func (cluster *Cluster) refreshMyProxy(proxy *Proxy) error {
+	if proxy.InternalProxy == nil || !proxy.InternalProxy.IsRunning() {
+		return errors.New("MyProxy is not running")
+	}
+	cluster.setMyProxyBackends(proxy)
+	writer := proxy.InternalProxy.GetWriter()
+	var bkwrite, bkread []Backend
+	for addr, st := range proxy.InternalProxy.GetBackendStats() {
+		bke := Backend{
+			PrxName:        addr,
+			PrxStatus:      "OFFLINE",
+			PrxConnections: strconv.FormatInt(st.Connections, 10),
+		}
+		if srv := cluster.GetServerFromURL(addr); srv != nil {
+			bke.Host = srv.Host
+			bke.Port = srv.Port
+			bke.Status = srv.State
+			bke.PrxMaintenance = srv.IsMaintenance
+		}
+		if addr == writer {
+			bke.PrxStatus = "ONLINE"
+			bkwrite = append(bkwrite, bke)
+			continue
+		}
+		if proxy.InternalProxy.IsReader(addr) {
+			bke.PrxStatus = "ONLINE"
+		}
+		bkread = append(bkread, bke)
+	}
+	proxy.BackendsWrite = bkwrite
+	proxy.BackendsRead = bkread
+	return nil
 }
@@ -223,6 +223,24 @@ func (cluster *Cluster) refreshProxysql(proxy *Proxy) error {
 			updated = true
 		}
 
+		// drain Galera donor, desynced or non-primary nodes and bring them back once synced
+		if (s.State == stateWsrepDonor || s.State == stateWsrepLate) && bkeread.PrxStatus == "ONLINE" && !s.IsMaster() {
+			cluster.LogPrintf(LvlDbg, "Monitor ProxySQL setting offline soft Galera node %s in state %s", s.URL, s.State)
+			err = psql.SetOfflineSoft(misc.Unbracket(s.Host), s.Port)
+			if err != nil {
+				cluster.sme.AddState("ERR00070", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00070"], err, s.URL), ErrFrom: "PRX", ServerUrl: proxy.Name})
+			}
+			updated = true
+		}
+		if s.State == stateWsrep && bkeread.PrxStatus == "OFFLINE_SOFT" && !s.IsIgnored() {
+			cluster.LogPrintf(LvlDbg, "Monitor ProxySQL setting online synced Galera node %s", s.URL)
+			err = psql.SetOnline(misc.Unbracket(s.Host), s.Port)
+			if err != nil {
+				cluster.sme.AddState("ERR00069", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00069"], s.URL, err), ErrFrom: "PRX", ServerUrl: proxy.Name})
+			}
+			updated = true
+		}
+
 		// if server is Standalone, set offline in ProxySQL
 		if s.State == stateUnconn && bke.PrxStatus == "ONLINE" {
 			cluster.LogPrintf(LvlDbg, "Monitor ProxySQL setting offline standalone server %s", s.URL)
@@ -30,7 +30,9 @@ import (
 	"github.com/go-sql-driver/mysql"
 	"github.com/hpcloud/tail"
 	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/utils/capacity"
 	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/digesthistory"
 	"github.com/signal18/replication-manager/utils/gtid"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/s18log"
@@ -99,11 +101,14 @@ type ServerMonitor struct {
 	HaveSQLErrorLog             bool                         `json:"haveSQLErrorLog"`
 	HavePFS                     bool                         `json:"havePFS"`
 	HaveWsrep                   bool                         `json:"haveWsrep"`
+	HaveGroupReplication        bool                         `json:"haveGroupReplication"`
 	HaveReadOnly                bool                         `json:"haveReadOnly"`
 	HaveNoMasterOnStart         bool                         `json:"haveNoMasterOnStart"`
 	IsWsrepSync                 bool                         `json:"isWsrepSync"`
 	IsWsrepDonor                bool                         `json:"isWsrepDonor"`
 	IsWsrepPrimary              bool                         `json:"isWsrepPrimary"`
+	IsGroupReplicationPrimary   bool                         `json:"isGroupReplicationPrimary"`
+	GroupReplicationState       string                       `json:"groupReplicationState"`
 	IsMaxscale                  bool                         `json:"isMaxscale"`
 	IsRelay                     bool                         `json:"isRelay"`
 	IsSlave                     bool                         `json:"isSlave"`
@@ -130,6 +135,10 @@ type ServerMonitor struct {
 	RelayLogSize                uint64                       `json:"relayLogSize"`
 	Replications                []dbhelper.SlaveStatus       `json:"replications"`
 	LastSeenReplications        []dbhelper.SlaveStatus       `json:"lastSeenReplications"`
+	PGReplicationStats          []dbhelper.PGReplicationStat `json:"pgReplicationStats"`
+	PGReplicationSlots          []dbhelper.PGReplicationSlot `json:"pgReplicationSlots"`
+	GroupReplicationMembers     []dbhelper.GroupMember       `json:"groupReplicationMembers"`
+	DelayedStop                 *DelayedStop                 `json:"delayedStop"`
 	MasterStatus                dbhelper.MasterStatus        `json:"masterStatus"`
 	SlaveStatus                 *dbhelper.SlaveStatus        `json:"-"`
 	ReplicationSourceName       string                       `json:"replicationSourceName"`
@@ -166,6 +175,27 @@ type ServerMonitor struct {
 	SSTPort                     string                       `json:"sstPort"`       //used to send data to dbjobs
 	Agent                       string                       `json:"agent"`         //used to provision service in orchestrator
 	BinaryLogFiles              map[string]uint              `json:"binaryLogFiles"`
+	runningJobs                 map[string]int               `json:"-"` //used to stream job progress
+	sstTask                     string                       `json:"-"` //job receiving the backup sent on SSTPort
+	sstToken                    string                       `json:"-"`
+	sstMutex                    sync.Mutex                   `json:"-"`
+	workloadCapture             *workloadCapture             `json:"-"`
+	workloadMutex               sync.Mutex                   `json:"-"`
+	DigestRegressions           []digesthistory.Regression   `json:"digestRegressions"`
+	digestHistory               *digesthistory.Store         `json:"-"`
+	digestSampler               *digesthistory.Sampler       `json:"-"`
+	digestSampleTime            time.Time                    `json:"-"`
+	digestRegressionTime        time.Time                    `json:"-"`
+	digestMutex                 sync.Mutex                   `json:"-"`
+	digestRegressionMutex       sync.Mutex                   `json:"-"`
+	CapacityForecasts           []capacity.Forecast          `json:"-"`
+	capacityHistory             *capacity.History            `json:"-"`
+	capacitySampleTime          time.Time                    `json:"-"`
+	capacityConnections         int64                        `json:"-"`
+	capacityBinlogs             map[string]int64             `json:"-"`
+	capacityMutex               sync.Mutex                   `json:"-"`
+	capacityForecastMutex       sync.Mutex                   `json:"-"`
+	readOnlyVariables           map[string]bool              `json:"-"`
 }
 
 type serverList []*ServerMonitor
@@ -382,6 +412,7 @@ func (server *ServerMonitor) Ping(wg *sync.WaitGroup) {
 			}
 		}
 		if server.PrevState != server.State {
+			server.publishStateChange()
 			server.PrevState = server.State
 		}
 		return
@@ -478,12 +509,13 @@ func (server *ServerMonitor) Ping(wg *sync.WaitGroup) {
 			}
 		}
 
-	} else if server.ClusterGroup.IsActive() && errss == nil && (server.PrevState == stateFailed) {
+	} else if server.ClusterGroup.IsActive() && errss == nil && (server.PrevState == stateFailed) && !server.HaveGroupReplication {
 
 		server.rejoinSlave(ss)
 	}
 
 	if server.PrevState != server.State {
+		server.publishStateChange()
 		server.PrevState = server.State
 		if server.PrevState != stateSuspect {
 			server.ClusterGroup.backendStateChangeProxies()
@@ -614,6 +646,15 @@ func (server *ServerMonitor) Refresh() error {
 			}
 			server.ServerID = uint64(sid)
 
+			server.HaveGroupReplication = server.HasGroupReplication()
+			if server.HaveGroupReplication {
+				server.RefreshGroupReplication()
+			} else {
+				server.IsGroupReplicationPrimary = false
+				server.GroupReplicationState = ""
+				server.GroupReplicationMembers = nil
+			}
+
 			server.EventStatus, logs, err = dbhelper.GetEventStatus(server.Conn, server.DBVersion)
 			server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get events status %s %s", server.URL, err)
 			if err != nil {
@@ -635,6 +676,16 @@ func (server *ServerMonitor) Refresh() error {
 				server.ClusterGroup.LogSQL(logs, err, server.URL, "MasterFailover", LvlErr, "Could not enable event scheduler on the  master")
 			}
 
+		} else {
+			server.ReadOnly = server.Variables["DEFAULT_TRANSACTION_READ_ONLY"]
+			// a standby in recovery is always read-only
+			server.HaveReadOnly = server.ReadOnly == "ON" || server.Variables["TRANSACTION_READ_ONLY"] == "ON"
+			if server.IsPgStream() {
+				server.PGReplicationStats, logs, err = dbhelper.GetPGReplicationStats(server.Conn)
+				server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get replication stats %s %s", server.URL, err)
+				server.PGReplicationSlots, logs, err = dbhelper.GetPGReplicationSlots(server.Conn)
+				server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get replication slots %s %s", server.URL, err)
+			}
 		} // end not postgress
 
 		// get Users
@@ -701,6 +752,10 @@ func (server *ServerMonitor) Refresh() error {
 			server.PFSQueries, logs, err = dbhelper.GetQueries(server.Conn)
 			server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get queries %s %s", server.URL, err)
 		}
+		if server.ClusterGroup.Conf.MonitorDigestHistory && time.Since(server.digestSampleTime) >= time.Duration(server.ClusterGroup.Conf.MonitorDigestHistoryInterval)*time.Second {
+			server.digestSampleTime = time.Now()
+			go server.SampleDigestHistory()
+		}
 		if server.HaveDiskMonitor {
 			server.Disks, logs, err = dbhelper.GetDisks(server.Conn, server.DBVersion)
 		}
@@ -717,12 +772,21 @@ func (server *ServerMonitor) Refresh() error {
 
 	// SHOW SLAVE STATUS
 
-	if !(server.ClusterGroup.Conf.MxsBinlogOn && server.IsMaxscale) && server.DBVersion.IsMariaDB() || server.DBVersion.IsPPostgreSQL() {
+	if server.IsPgStream() {
+		server.Replications, logs, err = dbhelper.GetPGStreamSlaveStatus(server.Conn)
+		if len(server.Replications) > 0 && err == nil {
+			// a standby have a single upstream named after its slot
+			server.ReplicationSourceName = server.Replications[0].ConnectionName.String
+		}
+	} else if !(server.ClusterGroup.Conf.MxsBinlogOn && server.IsMaxscale) && server.DBVersion.IsMariaDB() || server.DBVersion.IsPPostgreSQL() {
 		server.Replications, logs, err = dbhelper.GetAllSlavesStatus(server.Conn, server.DBVersion)
 		if len(server.Replications) > 0 && err == nil && server.DBVersion.IsPPostgreSQL() && server.ReplicationSourceName == "" {
 			//setting first subscription if we don't have one
 			server.ReplicationSourceName = server.Replications[0].ConnectionName.String
 		}
+	} else if server.HaveGroupReplication {
+		// group_replication_applier and recovery channels are not asynchronous replication
+		server.Replications = nil
 	} else {
 		server.Replications, logs, err = dbhelper.GetChannelSlaveStatus(server.Conn, server.DBVersion)
 	}
@@ -813,6 +877,23 @@ func (server *ServerMonitor) Refresh() error {
 	if server.HasHighNumberSlowQueries() {
 		server.ClusterGroup.SetState("WARN0088", state.State{ErrType: LvlInfo, ErrDesc: fmt.Sprintf(clusterError["WARN0088"], server.URL), ServerUrl: server.URL, ErrFrom: "MON"})
 	}
+	if r := server.GetDigestRegressions(); len(r) > 0 {
+		server.ClusterGroup.SetState("WARN0105", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0105"], r[0].Digest, r[0].Ratio, server.URL, r[0].P95, r[0].BaselineP95), ServerUrl: server.URL, ErrFrom: "MON"})
+	}
+	if server.ClusterGroup.Conf.MonitorCapacity {
+		server.MonitorCapacity()
+		for _, f := range server.GetCapacityForecasts() {
+			if f.DaysToLimit < 0 || f.DaysToLimit > float64(server.ClusterGroup.Conf.MonitorCapacityWarnDays) {
+				continue
+			}
+			switch f.Metric {
+			case capacity.ConstMetricDisk:
+				server.ClusterGroup.SetState("WARN0106", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0106"], server.URL, f.DaysToLimit, f.PerDay/1024/1024), ServerUrl: server.URL, ErrFrom: "MON"})
+			case capacity.ConstMetricConnections:
+				server.ClusterGroup.SetState("WARN0107", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0107"], server.URL, f.Limit, f.DaysToLimit), ServerUrl: server.URL, ErrFrom: "MON"})
+			}
+		}
+	}
 	// monitor plugins
 	if !server.DBVersion.IsPPostgreSQL() {
 		if server.ClusterGroup.sme.GetHeartbeats()%60 == 0 {
@@ -977,6 +1058,9 @@ func (server *ServerMonitor) StopSlave() (string, error) {
 	if server.Conn == nil {
 		return "", errors.New("No database connection pool")
 	}
+	if server.IsPgStream() {
+		return dbhelper.PGPauseReplay(server.Conn)
+	}
 	return dbhelper.StopSlave(server.Conn, server.ClusterGroup.Conf.MasterConn, server.DBVersion)
 }
 
@@ -984,6 +1068,9 @@ func (server *ServerMonitor) StartSlave() (string, error) {
 	if server.Conn == nil {
 		return "", errors.New("No databse connection")
 	}
+	if server.IsPgStream() {
+		return dbhelper.PGResumeReplay(server.Conn)
+	}
 	return dbhelper.StartSlave(server.Conn, server.ClusterGroup.Conf.MasterConn, server.DBVersion)
 
 }
@@ -1045,6 +1132,32 @@ func (server *ServerMonitor) SkipReplicationEvent() {
 	server.StartSlave()
 }
 
+// InjectErrantTransactionsOnMaster commits empty transactions on the master for each errant GTID of the server
+func (server *ServerMonitor) InjectErrantTransactionsOnMaster() error {
+	master := server.ClusterGroup.GetMaster()
+	if master == nil || master.IsFailed() {
+		return errors.New("No valid master found")
+	}
+	if !master.HasMySQLGTID() {
+		return errors.New("Master is not using MySQL GTID")
+	}
+	errant, err := server.GetErrantTransactions()
+	if err != nil {
+		return err
+	}
+	if errant.IsEmpty() {
+		server.ClusterGroup.LogPrintf(LvlInfo, "No errant transaction found on %s", server.URL)
+		return nil
+	}
+	if errant.Count() > maxErrantTrxInject {
+		return fmt.Errorf("Too many errant transactions %d on %s, reseed the replica", errant.Count(), server.URL)
+	}
+	server.ClusterGroup.LogPrintf(LvlInfo, "Injecting empty transactions %s on master %s", errant.String(), master.URL)
+	logs, err := dbhelper.InjectEmptyTrx(master.Conn, errant.GetGtids(0))
+	server.ClusterGroup.LogSQL(logs, err, master.URL, "Rejoin", LvlErr, "Could not inject empty transactions on master %s %s", master.URL, err)
+	return err
+}
+
 func (server *ServerMonitor) KillThread(id string) (string, error) {
 	return dbhelper.KillThread(server.Conn, id, server.DBVersion)
 }
@@ -0,0 +1,330 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/backupstore"
+	"github.com/signal18/replication-manager/utils/hooks"
+	"github.com/signal18/replication-manager/utils/xbstream"
+)
+
+// BackupChainEntry is a physical backup of a chain, the first one is full and each
+// incremental backup starts at the LSN the previous one ends
+type BackupChainEntry struct {
+	Key         string    `json:"key"`
+	Incremental bool      `json:"incremental"`
+	FromLSN     uint64    `json:"fromLsn"`
+	ToLSN       uint64    `json:"toLsn"`
+	Size        int64     `json:"size"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+}
+
+type BackupChain struct {
+	Id      string             `json:"id"`
+	Server  string             `json:"server"`
+	Type    string             `json:"type"`
+	Backups []BackupChainEntry `json:"backups"`
+}
+
+// the chains of a server are kept with its backups in the store
+const backupChainsFile = "chains.json"
+
+// incremental backups follow the full one in a reseed stream under this directory
+const backupChainIncrementalDir = "incremental"
+
+func (server *ServerMonitor) GetBackupChains() ([]BackupChain, error) {
+	chains := []BackupChain{}
+	store, err := server.ClusterGroup.GetBackupStore()
+	if err != nil {
+		return chains, err
+	}
+	r, err := store.Open(server.GetMyBackupKey(backupChainsFile))
+	if os.IsNotExist(err) {
+		return chains, nil
+	}
+	if err != nil {
+		return chains, err
+	}
+	defer r.Close()
+	err = json.NewDecoder(r).Decode(&chains)
+	return chains, err
+}
+
+func (server *ServerMonitor) saveBackupChains(chains []BackupChain) error {
+	store, err := server.ClusterGroup.GetBackupStore()
+	if err != nil {
+		return err
+	}
+	w, err := store.Create(server.GetMyBackupKey(backupChainsFile))
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(chains); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
+
+// addBackupChainEntry records a completed backup. A full backup starts a chain and
+// the oldest chains out of backup-physical-chains-keep are deleted.
+func (server *ServerMonitor) addBackupChainEntry(entry BackupChainEntry) error {
+	cluster := server.ClusterGroup
+	cluster.backupChainMutex.Lock()
+	defer cluster.backupChainMutex.Unlock()
+	chains, err := server.GetBackupChains()
+	if err != nil {
+		return err
+	}
+	if entry.Incremental {
+		if len(chains) == 0 {
+			return errors.New("No backup chain for incremental backup")
+		}
+		last := &chains[len(chains)-1]
+		if prev := last.Backups[len(last.Backups)-1]; entry.FromLSN != prev.ToLSN {
+			return fmt.Errorf("Incremental backup from LSN %d does not follow LSN %d of the chain %s", entry.FromLSN, prev.ToLSN, last.Id)
+		}
+		last.Backups = append(last.Backups, entry)
+	} else {
+		chains = append(chains, BackupChain{
+			Id:      entry.Start.Format("20060102150405"),
+			Server:  server.URL,
+			Type:    cluster.Conf.BackupPhysicalType,
+			Backups: []BackupChainEntry{entry},
+		})
+	}
+	var expired []BackupChain
+	if keep := cluster.Conf.BackupPhysicalChainsKeep; keep > 0 && len(chains) > keep {
+		expired = chains[:len(chains)-keep]
+		chains = chains[len(chains)-keep:]
+	}
+	if err := server.saveBackupChains(chains); err != nil {
+		return err
+	}
+	store, _ := cluster.GetBackupStore()
+	for _, chain := range expired {
+		cluster.LogPrintf(LvlInfo, "Purging backup chain %s of %s", chain.Id, server.URL)
+		for _, b := range chain.Backups {
+			if err := store.Delete(b.Key); err != nil {
+				cluster.LogPrintf(LvlErr, "Purging backup %s failed: %s", b.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+type backupChainScan struct {
+	cp  xbstream.Checkpoints
+	err error
+}
+
+// backupChainWriter writes a physical backup in the store and reads the LSN range of
+// its xbstream to record it in the chain once complete
+type backupChainWriter struct {
+	backupstore.Writer
+	server *ServerMonitor
+	entry  BackupChainEntry
+	pw     *io.PipeWriter
+	scan   chan backupChainScan
+}
+
+func (server *ServerMonitor) newBackupChainWriter(incremental bool) (*backupChainWriter, error) {
+	store, err := server.ClusterGroup.GetBackupStore()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	kind := "full"
+	if incremental {
+		kind = "incr"
+	}
+	entry := BackupChainEntry{
+		Key:         server.GetMyBackupKey(server.ClusterGroup.Conf.BackupPhysicalType + "-" + kind + "-" + now.Format("20060102150405") + ".xbtream"),
+		Incremental: incremental,
+		Start:       now,
+	}
+	w, err := store.Create(entry.Key)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	cw := &backupChainWriter{Writer: w, server: server, entry: entry, pw: pw, scan: make(chan backupChainScan, 1)}
+	go func() {
+		cp, err := xbstream.ScanCheckpoints(pr)
+		// the backup is still written when it can not be read
+		io.Copy(ioutil.Discard, pr)
+		cw.scan <- backupChainScan{cp: cp, err: err}
+	}()
+	return cw, nil
+}
+
+func (w *backupChainWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if n > 0 {
+		w.pw.Write(p[:n])
+		w.entry.Size += int64(n)
+	}
+	return n, err
+}
+
+// Close records the backup in the chain and fires backup-finished
+func (w *backupChainWriter) Close() error {
+	err := w.close()
+	w.server.fireBackupHook(&hooks.Backup{Type: "physical", Method: w.server.ClusterGroup.Conf.BackupPhysicalType, Path: w.entry.Key, Size: w.entry.Size}, err)
+	return err
+}
+
+func (w *backupChainWriter) close() error {
+	w.pw.Close()
+	scan := <-w.scan
+	cluster := w.server.ClusterGroup
+	if scan.err != nil {
+		w.Writer.Abort()
+		cluster.LogPrintf(LvlErr, "Physical backup %s of %s has no LSN: %s", w.entry.Key, w.server.URL, scan.err)
+		return scan.err
+	}
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	w.entry.FromLSN = scan.cp.FromLSN
+	w.entry.ToLSN = scan.cp.ToLSN
+	w.entry.End = time.Now()
+	if err := w.server.addBackupChainEntry(w.entry); err != nil {
+		cluster.LogPrintf(LvlErr, "Physical backup %s of %s not added to the chain: %s", w.entry.Key, w.server.URL, err)
+		if store, serr := cluster.GetBackupStore(); serr == nil {
+			store.Delete(w.entry.Key)
+		}
+		return err
+	}
+	cluster.LogPrintf(LvlInfo, "Physical backup %s of %s from LSN %d to %d", w.entry.Key, w.server.URL, w.entry.FromLSN, w.entry.ToLSN)
+	return nil
+}
+
+func (w *backupChainWriter) Abort() error {
+	w.pw.CloseWithError(errors.New("Backup aborted"))
+	<-w.scan
+	return w.Writer.Abort()
+}
+
+// JobBackupPhysicalIncremental backups the changes since the last backup of the
+// chain, a full backup is taken when there is no chain yet
+func (server *ServerMonitor) JobBackupPhysicalIncremental() (int64, error) {
+	//server can be nil as no dicovered master
+	if server == nil {
+		return 0, nil
+	}
+	cluster := server.ClusterGroup
+	cluster.LogPrintf(LvlInfo, "Receive incremental physical backup %s request for server: %s", cluster.Conf.BackupPhysicalType, server.URL)
+	if server.IsDown() {
+		return 0, nil
+	}
+	if server.DBVersion.IsPPostgreSQL() {
+		return 0, errors.New("No incremental backup for PostgreSQL")
+	}
+	chains, err := server.GetBackupChains()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Reading backup chains of %s failed: %s", server.URL, err)
+		return 0, err
+	}
+	if len(chains) == 0 || chains[len(chains)-1].Type != cluster.Conf.BackupPhysicalType {
+		cluster.LogPrintf(LvlInfo, "No %s backup chain for server %s, starting one with a full backup", cluster.Conf.BackupPhysicalType, server.URL)
+		return server.JobBackupPhysical()
+	}
+	last := chains[len(chains)-1]
+	lsn := last.Backups[len(last.Backups)-1].ToLSN
+	w, err := server.newBackupChainWriter(true)
+	if err != nil {
+		return 0, err
+	}
+	task := cluster.Conf.BackupPhysicalType + "incr"
+	port, token, err := cluster.SSTRunReceiverToWriter(task, w)
+	if err != nil {
+		return 0, err
+	}
+	return server.JobInsertTaksFromLSN(task, port, token, strconv.FormatUint(lsn, 10), cluster.Conf.MonitorAddress)
+}
+
+// writeBackupChain writes the xbstream of the full backup of chain followed by its
+// incremental backups under incremental/<n>/ for the job to prepare them in order
+func (cluster *Cluster) writeBackupChain(chain BackupChain, w io.Writer) error {
+	store, err := cluster.GetBackupStore()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, 1<<20)
+	xw := xbstream.NewWriter(bw)
+	for i, b := range chain.Backups {
+		r, err := store.Open(b.Key)
+		if err != nil {
+			return err
+		}
+		prefix := ""
+		if i > 0 {
+			prefix = fmt.Sprintf("%s/%03d/", backupChainIncrementalDir, i)
+		}
+		err = xbstream.Copy(xw, xbstream.NewReader(r), prefix)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", b.Key, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// SSTRunSenderChain sends the last backup chain of source to the reseed job of sv,
+// it sends the backup of the type before the chains when there is none
+func (cluster *Cluster) SSTRunSenderChain(source *ServerMonitor, sv *ServerMonitor) {
+	chains, err := source.GetBackupChains()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Reading backup chains of %s failed: %s", source.URL, err)
+		return
+	}
+	if len(chains) == 0 {
+		cluster.SSTRunSender(source.GetMyBackupKey(cluster.Conf.BackupPhysicalType+".xbtream"), sv)
+		return
+	}
+	chain := chains[len(chains)-1]
+	if len(chain.Backups) == 1 {
+		cluster.SSTRunSender(chain.Backups[0].Key, sv)
+		return
+	}
+	cluster.LogPrintf(LvlInfo, "Sending backup chain %s of %s with %d incremental backups", chain.Id, source.URL, len(chain.Backups)-1)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(cluster.writeBackupChain(chain, pw))
+	}()
+	cluster.sstSend("chain "+chain.Id, pr, sv)
+	pr.Close()
+}
+
+// GetBackupChains returns the backup chains of all servers
+func (cluster *Cluster) GetBackupChains() []BackupChain {
+	chains := []BackupChain{}
+	for _, server := range cluster.Servers {
+		c, err := server.GetBackupChains()
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Reading backup chains of %s failed: %s", server.URL, err)
+			continue
+		}
+		chains = append(chains, c...)
+	}
+	return chains
+}
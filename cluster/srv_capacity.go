@@ -0,0 +1,144 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/capacity"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+func (server *ServerMonitor) GetCapacityFile() string {
+	return server.ClusterGroup.Conf.WorkingDir + "/" + server.ClusterGroup.Name + "/capacity/" + server.Host + "_" + server.Port + ".json"
+}
+
+// MonitorCapacity keeps the peak of connections between two samples and samples
+// the usage of the server every capacity interval
+func (server *ServerMonitor) MonitorCapacity() {
+	cluster := server.ClusterGroup
+	if connected, err := strconv.ParseInt(server.Status["THREADS_CONNECTED"], 10, 64); err == nil && connected > server.capacityConnections {
+		server.capacityConnections = connected
+	}
+	if time.Since(server.capacitySampleTime) < time.Duration(cluster.Conf.MonitorCapacityInterval)*time.Second {
+		return
+	}
+	server.capacitySampleTime = time.Now()
+	s := capacity.Sample{
+		Time:        server.capacitySampleTime,
+		Connections: server.capacityConnections,
+		BinlogBytes: server.getBinlogBytesWritten(),
+	}
+	server.capacityConnections = 0
+	s.MaxConnections, _ = strconv.ParseInt(server.Variables["MAX_CONNECTIONS"], 10, 64)
+	if d := server.getDataDisk(); d != nil {
+		// the disks plugin reports in KB
+		s.DiskUsed = int64(d.Used) * 1024
+		s.DiskTotal = int64(d.Total) * 1024
+	}
+	// the size of the tables is only collected on the master
+	if server.IsMaster() {
+		s.DataSize = cluster.DBTableSize + cluster.DBIndexSize
+	}
+	go server.SampleCapacity(s)
+}
+
+// getDataDisk returns the disk of the datadir, the fullest disk when no disk path
+// contains it
+func (server *ServerMonitor) getDataDisk() *dbhelper.Disk {
+	var disk, fullest *dbhelper.Disk
+	datadir := server.Variables["DATADIR"]
+	for i, d := range server.Disks {
+		if d.Total <= 0 {
+			continue
+		}
+		if datadir != "" && strings.HasPrefix(datadir, d.Path) && (disk == nil || len(d.Path) > len(disk.Path)) {
+			disk = &server.Disks[i]
+		}
+		if fullest == nil || float64(d.Used)/float64(d.Total) > float64(fullest.Used)/float64(fullest.Total) {
+			fullest = &server.Disks[i]
+		}
+	}
+	if disk == nil {
+		return fullest
+	}
+	return disk
+}
+
+// getBinlogBytesWritten returns the growth of the binary logs since the previous
+// call, the current binary log is sized by its position
+func (server *ServerMonitor) getBinlogBytesWritten() int64 {
+	sizes := make(map[string]int64, len(server.BinaryLogFiles)+1)
+	for file, size := range server.BinaryLogFiles {
+		sizes[file] = int64(size)
+	}
+	if server.BinaryLogFile != "" {
+		sizes[server.BinaryLogFile] = int64(server.MasterStatus.Position)
+	}
+	var written int64
+	if server.capacityBinlogs != nil {
+		last := ""
+		for file := range server.capacityBinlogs {
+			if file > last {
+				last = file
+			}
+		}
+		for file, size := range sizes {
+			prev, ok := server.capacityBinlogs[file]
+			// binary logs older than the last one known were written before
+			if !ok && file < last {
+				continue
+			}
+			if size > prev {
+				written += size - prev
+			}
+		}
+	}
+	server.capacityBinlogs = sizes
+	return written
+}
+
+// SampleCapacity adds the sample to the capacity history of the server and
+// forecasts its usage
+func (server *ServerMonitor) SampleCapacity(s capacity.Sample) {
+	server.capacityMutex.Lock()
+	defer server.capacityMutex.Unlock()
+	cluster := server.ClusterGroup
+	file := server.GetCapacityFile()
+	if server.capacityHistory == nil {
+		os.MkdirAll(cluster.Conf.WorkingDir+"/"+cluster.Name+"/capacity", os.ModePerm)
+		h, err := capacity.Load(file)
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Could not read capacity history of %s: %s", server.URL, err)
+			h = &capacity.History{}
+		}
+		server.capacityHistory = h
+	}
+	server.capacityHistory.Add(s, time.Duration(cluster.Conf.MonitorCapacityKeep)*24*time.Hour)
+	if err := server.capacityHistory.Save(file); err != nil {
+		cluster.LogPrintf(LvlErr, "Could not write capacity history of %s: %s", server.URL, err)
+	}
+	forecasts := capacity.Forecasts(server.capacityHistory.Samples, s.Time, time.Duration(cluster.Conf.MonitorCapacityHorizon)*24*time.Hour)
+	server.capacityForecastMutex.Lock()
+	server.CapacityForecasts = forecasts
+	server.capacityForecastMutex.Unlock()
+}
+
+func (server *ServerMonitor) GetCapacityForecasts() []capacity.Forecast {
+	server.capacityForecastMutex.Lock()
+	defer server.capacityForecastMutex.Unlock()
+	if server.CapacityForecasts == nil {
+		return []capacity.Forecast{}
+	}
+	return server.CapacityForecasts
+}
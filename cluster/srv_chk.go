@@ -49,7 +49,13 @@ func (server *ServerMonitor) CheckReplication() string {
 		return "In Failover"
 	}
 	if server.HaveWsrep && !server.IsFailed() {
-		if server.IsWsrepSync {
+		if !server.IsWsrepPrimary {
+			server.State = stateWsrepLate
+			return "Galera Non-Primary"
+		} else if server.IsWsrepDesync() {
+			server.State = stateWsrepDonor
+			return "Galera Desync"
+		} else if server.IsWsrepSync {
 			server.State = stateWsrep
 			return "Galera OK"
 		} else if server.IsWsrepDonor {
@@ -60,6 +66,22 @@ func (server *ServerMonitor) CheckReplication() string {
 			return "Galera Late"
 		}
 	}
+	if server.HaveGroupReplication && !server.IsFailed() {
+		if server.IsGroupReplicationPrimary {
+			return "Master OK"
+		}
+		switch server.GroupReplicationState {
+		case "ONLINE":
+			server.State = stateSlave
+			return "Group OK"
+		case "RECOVERING":
+			server.State = stateSlaveLate
+			return "Group Recovering"
+		default:
+			server.State = stateSlaveErr
+			return "Group " + server.GroupReplicationState
+		}
+	}
 	if (server.IsDown()) && server.IsSlave == false {
 
 		return "Master OK"
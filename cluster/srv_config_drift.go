@@ -0,0 +1,157 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/signal18/replication-manager/utils/configdrift"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// GetDatabaseConfigOptions returns the options of the server groups of the
+// configuration generated for the server, nil when it was not generated
+func (server *ServerMonitor) GetDatabaseConfigOptions() map[string]string {
+	dir := server.Datadir + "/init/etc/mysql"
+	files, _ := filepath.Glob(dir + "/conf.d/*.cnf")
+	// the server reads the included directory in alphabetical order after my.cnf
+	files = append([]string{dir + "/my.cnf"}, files...)
+	var options map[string]string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if options == nil {
+			options = make(map[string]string)
+		}
+		for k, v := range configdrift.ParseOptionFile(string(content)) {
+			options[k] = v
+		}
+	}
+	return options
+}
+
+func (server *ServerMonitor) getReadOnlyVariables() map[string]bool {
+	if server.readOnlyVariables == nil && server.IsMariaDB() && server.Conn != nil {
+		vars, logs, err := dbhelper.GetReadOnlyVariables(server.Conn)
+		server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get read only variables %s %s", server.URL, err)
+		if err == nil {
+			server.readOnlyVariables = vars
+		}
+	}
+	return server.readOnlyVariables
+}
+
+// GetConfigDrift returns the drift of the variables of the server from its generated
+// configuration and from the master
+func (server *ServerMonitor) GetConfigDrift() configdrift.Report {
+	cluster := server.ClusterGroup
+	var masterVariables map[string]string
+	if master := cluster.GetMaster(); master != nil && master.Id != server.Id && !master.IsDown() {
+		masterVariables = master.Variables
+	}
+	return configdrift.Compare(configdrift.Server{
+		URL:       server.URL,
+		Master:    server.IsMaster(),
+		Variables: server.Variables,
+		Config:    server.GetDatabaseConfigOptions(),
+		ReadOnly:  server.getReadOnlyVariables(),
+	}, masterVariables, configdrift.NewRules(cluster.Conf.MonitorConfigDriftIgnore))
+}
+
+// GetConfigDrift returns the drift reports of the database servers up
+func (cluster *Cluster) GetConfigDrift() []configdrift.Report {
+	reports := []configdrift.Report{}
+	for _, server := range cluster.Servers {
+		if server.IsDown() || len(server.Variables) == 0 || server.DBVersion.IsPPostgreSQL() {
+			continue
+		}
+		reports = append(reports, server.GetConfigDrift())
+	}
+	return reports
+}
+
+// MonitorConfigDrift raises a warning for the drifts that would change the
+// behavior of the cluster after a failover
+func (cluster *Cluster) MonitorConfigDrift() {
+	if !cluster.Conf.MonitorConfigDrift || cluster.GetMaster() == nil {
+		return
+	}
+	var drifts []string
+	for _, r := range cluster.GetConfigDrift() {
+		for _, d := range r.Drifts {
+			if d.Class == configdrift.ConstClassFailover {
+				drifts = append(drifts, fmt.Sprintf("%s on %s is %s instead of %s from %s", strings.ToLower(d.Variable), r.Server, d.Value, d.Expected, d.Source))
+			}
+		}
+	}
+	if len(drifts) > 0 {
+		cluster.SetState("WARN0108", state.State{ErrType: LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0108"], strings.Join(drifts, ", ")), ErrFrom: "MON", ServerUrl: cluster.GetMaster().URL})
+	}
+}
+
+// RemediateConfigDrift sets the dynamic variables drifting on the server and sets the
+// reprovisioning cookie for the static ones. Without variable only the drifts dangerous
+// for a failover are remediated. It returns the drifts remediated.
+func (server *ServerMonitor) RemediateConfigDrift(variable string) ([]configdrift.Drift, error) {
+	cluster := server.ClusterGroup
+	if server.Conn == nil || server.IsDown() {
+		return nil, errors.New("No database connection pool")
+	}
+	name := configdrift.VariableName(variable)
+	reprov := false
+	remediated := []configdrift.Drift{}
+	for _, d := range server.GetConfigDrift().Drifts {
+		if (variable != "" && d.Variable != name) || (variable == "" && d.Class != configdrift.ConstClassFailover) || d.Class == configdrift.ConstClassRole {
+			continue
+		}
+		if d.Remediation != "" && d.Source == configdrift.ConstSourceMaster {
+			master := cluster.GetMaster()
+			if master == nil || master.Conn == nil {
+				return remediated, errors.New("No master to read " + d.Variable)
+			}
+			value, logs, err := dbhelper.GetVariableExactValueByName(master.Conn, d.Variable, master.DBVersion)
+			cluster.LogSQL(logs, err, master.URL, "Monitor", LvlErr, "Could not get variable %s on %s %s", d.Variable, master.URL, err)
+			if err != nil {
+				return remediated, err
+			}
+			d.Expected = value
+			d.Remediation = configdrift.SetGlobal(d.Variable, value)
+		}
+		if d.Remediation != "" {
+			cluster.LogPrintf(LvlInfo, "Remediating config drift on %s: %s", server.URL, d.Remediation)
+			err := server.ExecQueryNoBinLog(d.Remediation)
+			if err == nil {
+				remediated = append(remediated, d)
+				continue
+			}
+			// ERROR 1238: Variable is a read only variable
+			if !strings.Contains(err.Error(), "1238") {
+				return remediated, err
+			}
+		}
+		reprov = true
+		remediated = append(remediated, d)
+	}
+	if variable != "" && len(remediated) == 0 {
+		return remediated, errors.New("No drift to remediate on variable " + variable)
+	}
+	if reprov {
+		cluster.LogPrintf(LvlInfo, "Config drift of static variables on %s, setting reprovisioning cookie", server.URL)
+		server.SetReprovCookie()
+	}
+	return remediated, nil
+}
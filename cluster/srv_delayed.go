@@ -0,0 +1,332 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/misc"
+)
+
+// DelayedStop is the point a delayed replica is stopped at to read the data just before a destructive event
+type DelayedStop struct {
+	Method  string             `json:"method"`
+	Value   string             `json:"value"`
+	Trx     dbhelper.BinlogTrx `json:"trx"`
+	Reached bool               `json:"reached"`
+	Since   int64              `json:"since"`
+	Host    string             `json:"host"`
+	Port    string             `json:"port"`
+}
+
+// JobDelayedStopBefore replays a delayed replica up to the transaction found in the master binary logs and stops
+// before it. The search method is gtid, timestamp (2006-01-02T15:04:05 in monitor local time) or event, a regexp
+// matched against the statements. The replica is put in maintenance so it stays out of proxies while it is read
+func (server *ServerMonitor) JobDelayedStopBefore(method string, value string) (stop *DelayedStop, err error) {
+	cluster := server.ClusterGroup
+	master := cluster.GetMaster()
+	if !server.IsDelayed {
+		return nil, errors.New("Server " + server.URL + " is not a delayed replica")
+	}
+	if server.DelayedStop != nil {
+		return nil, errors.New("Delayed replica " + server.URL + " is already stopped")
+	}
+	if master == nil || master.Conn == nil {
+		return nil, errors.New("No master found")
+	}
+	ss, err := server.GetSlaveStatus(server.ReplicationSourceName)
+	if err != nil {
+		return nil, err
+	}
+	binlogs, logs, err := dbhelper.GetBinaryLogs(master.Conn, master.DBVersion)
+	cluster.LogSQL(logs, err, master.URL, "Delayed", LvlErr, "Could not get binary log files %s %s", master.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	// the event is not yet applied, only look after the replica position
+	var files []string
+	for file := range binlogs {
+		if file >= ss.RelayMasterLogFile.String {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 || files[0] != ss.RelayMasterLogFile.String {
+		return nil, errors.New("Binary log " + ss.RelayMasterLogFile.String + " executed by the delayed replica is purged on master")
+	}
+	var trx dbhelper.BinlogTrx
+	switch method {
+	case "gtid":
+		trx, logs, err = dbhelper.FindBinlogTrx(master.Conn, files, ss.ExecMasterLogPos.String, func(gtid string, ev dbhelper.BinlogEvents) bool {
+			return gtid == value
+		})
+		cluster.LogSQL(logs, err, master.URL, "Delayed", LvlDbg, "Could not find GTID %s in binary logs %s %s", value, master.URL, err)
+	case "event":
+		var re *regexp.Regexp
+		re, err = regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		trx, logs, err = dbhelper.FindBinlogTrx(master.Conn, files, ss.ExecMasterLogPos.String, func(gtid string, ev dbhelper.BinlogEvents) bool {
+			return (ev.Event_type == "Query" || ev.Event_type == "Annotate_rows" || ev.Event_type == "Rows_query") && re.MatchString(ev.Info)
+		})
+		cluster.LogSQL(logs, err, master.URL, "Delayed", LvlDbg, "Could not find event %s in binary logs %s %s", value, master.URL, err)
+	case "timestamp":
+		var t time.Time
+		t, err = time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		trx, err = master.findBinlogTrxAtTime(files, ss.ExecMasterLogPos.String, t)
+	default:
+		return nil, errors.New("Unknown delayed stop method " + method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cluster.LogPrintf(LvlInfo, "Delayed replica %s will stop before transaction %s at %s:%d", server.URL, trx.Gtid, trx.File, trx.Pos)
+
+	server.IsMaintenance = true
+	cluster.failoverProxies()
+	defer func() {
+		if err == nil {
+			return
+		}
+		// the replica is delayed again and back in the proxies whatever step failed
+		logs, errRestore := server.StopSlave()
+		cluster.LogSQL(logs, errRestore, server.URL, "Delayed", LvlErr, "Could not stop replication on %s %s", server.URL, errRestore)
+		logs, errRestore = dbhelper.SetSlaveDelay(server.Conn, strconv.Itoa(cluster.Conf.HostsDelayedTime), cluster.Conf.MasterConn, server.DBVersion)
+		cluster.LogSQL(logs, errRestore, server.URL, "Delayed", LvlErr, "Could not restore replication delay on %s %s", server.URL, errRestore)
+		logs, errRestore = server.StartSlave()
+		cluster.LogSQL(logs, errRestore, server.URL, "Delayed", LvlErr, "Could not start replication on %s %s", server.URL, errRestore)
+		server.IsMaintenance = false
+		cluster.failoverProxies()
+	}()
+	logs, err = server.StopSlave()
+	cluster.LogSQL(logs, err, server.URL, "Delayed", LvlErr, "Could not stop replication on %s %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	logs, err = dbhelper.SetSlaveDelay(server.Conn, "0", cluster.Conf.MasterConn, server.DBVersion)
+	cluster.LogSQL(logs, err, server.URL, "Delayed", LvlErr, "Could not remove replication delay on %s %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	logs, err = dbhelper.StartSlaveUntilPos(server.Conn, cluster.Conf.MasterConn, trx.File, strconv.FormatUint(uint64(trx.Pos), 10), server.DBVersion)
+	cluster.LogSQL(logs, err, server.URL, "Delayed", LvlErr, "Could not start replication until %s:%d on %s %s", trx.File, trx.Pos, server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	server.DelayedStop = &DelayedStop{
+		Method: method,
+		Value:  value,
+		Trx:    trx,
+		Since:  time.Now().Unix(),
+		Host:   server.Host,
+		Port:   server.Port,
+	}
+	return server.DelayedStop, nil
+}
+
+// GetDelayedStop returns the stop point of the delayed replica and whether it is reached
+func (server *ServerMonitor) GetDelayedStop() (*DelayedStop, error) {
+	if server.DelayedStop == nil {
+		return nil, errors.New("Delayed replica " + server.URL + " is not stopped")
+	}
+	ss, err := server.GetSlaveStatus(server.ReplicationSourceName)
+	if err != nil {
+		return server.DelayedStop, err
+	}
+	pos, _ := strconv.ParseUint(ss.ExecMasterLogPos.String, 10, 64)
+	server.DelayedStop.Reached = ss.SlaveSQLRunning.String == "No" && (ss.RelayMasterLogFile.String > server.DelayedStop.Trx.File || (ss.RelayMasterLogFile.String == server.DelayedStop.Trx.File && pos >= uint64(server.DelayedStop.Trx.Pos)))
+	return server.DelayedStop, nil
+}
+
+// JobDelayedResume restores the replication delay and puts the replica back in the proxies
+func (server *ServerMonitor) JobDelayedResume() error {
+	cluster := server.ClusterGroup
+	logs, err := server.StopSlave()
+	cluster.LogSQL(logs, err, server.URL, "Delayed", LvlErr, "Could not stop replication on %s %s", server.URL, err)
+	logs, err = dbhelper.SetSlaveDelay(server.Conn, strconv.Itoa(cluster.Conf.HostsDelayedTime), cluster.Conf.MasterConn, server.DBVersion)
+	cluster.LogSQL(logs, err, server.URL, "Delayed", LvlErr, "Could not restore replication delay on %s %s", server.URL, err)
+	if err != nil {
+		return err
+	}
+	logs, err = server.StartSlave()
+	cluster.LogSQL(logs, err, server.URL, "Delayed", LvlErr, "Could not start replication on %s %s", server.URL, err)
+	if err != nil {
+		return err
+	}
+	server.DelayedStop = nil
+	server.IsMaintenance = false
+	cluster.failoverProxies()
+	return nil
+}
+
+// JobDelayedExtractTables copies tables from the stopped delayed replica into a schema of the master named
+// after the source schema with a _flashback suffix, tables is a list of schema.table
+func (server *ServerMonitor) JobDelayedExtractTables(tables []string) error {
+	cluster := server.ClusterGroup
+	bySchema, err := delayedTablesBySchema(tables)
+	if err != nil {
+		return err
+	}
+	master := cluster.GetMaster()
+	if master == nil || master.Conn == nil {
+		return errors.New("No master found")
+	}
+	stop, err := server.GetDelayedStop()
+	if err != nil {
+		return err
+	}
+	if !stop.Reached {
+		return errors.New("Delayed replica " + server.URL + " did not reach its stop position")
+	}
+	for schema, tbls := range bySchema {
+		target := schema + "_flashback"
+		logs, err := dbhelper.CreateDatabase(master.Conn, target)
+		cluster.LogSQL(logs, err, master.URL, "Delayed", LvlErr, "Could not create schema %s on %s %s", target, master.URL, err)
+		if err != nil {
+			return err
+		}
+		dumpargs := []string{"--host=" + misc.Unbracket(server.Host), "--port=" + server.Port, "--user=" + cluster.dbUser, "--password=" + cluster.dbPass, "--single-transaction", "--skip-triggers", "--hex-blob"}
+		if !server.DBVersion.IsMariaDB() {
+			dumpargs = append(dumpargs, "--set-gtid-purged=OFF")
+		}
+		dumpargs = append(dumpargs, schema)
+		dumpargs = append(dumpargs, tbls...)
+		dumpCmd := exec.Command(cluster.GetMysqlDumpPath(), dumpargs...)
+		clientCmd := exec.Command(cluster.GetMysqlclientPath(), "--host="+misc.Unbracket(master.Host), "--port="+master.Port, "--user="+cluster.dbUser, "--password="+cluster.dbPass, "--batch", "--database="+target)
+		cluster.LogPrintf(LvlInfo, "Extract tables %s from %s into %s on %s", strings.Join(tbls, ","), server.URL, target, master.URL)
+		clientCmd.Stdin, err = dumpCmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		stderrIn, _ := dumpCmd.StderrPipe()
+		stderrOut, _ := clientCmd.StderrPipe()
+		if err := dumpCmd.Start(); err != nil {
+			cluster.LogPrintf(LvlErr, "Failed mysqldump command: %s at %s", err, strings.Replace(dumpCmd.String(), cluster.dbPass, "XXXX", -1))
+			return err
+		}
+		if err := clientCmd.Start(); err != nil {
+			cluster.LogPrintf(LvlErr, "Can't start mysql client:%s at %s", err, strings.Replace(clientCmd.String(), cluster.dbPass, "XXXX", -1))
+			dumpCmd.Process.Kill()
+			return err
+		}
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			server.copyLogs(stderrIn)
+		}()
+		go func() {
+			defer wg.Done()
+			master.copyLogs(stderrOut)
+		}()
+		wg.Wait()
+		errDump := dumpCmd.Wait()
+		err = clientCmd.Wait()
+		if errDump != nil {
+			return errDump
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var delayedIdentifier = regexp.MustCompile(`^[0-9A-Za-z_$]+$`)
+
+// delayedTablesBySchema groups a list of schema.table, the names are passed to mysqldump and the mysql
+// client so only unquoted identifiers are accepted
+func delayedTablesBySchema(tables []string) (map[string][]string, error) {
+	bySchema := make(map[string][]string)
+	for _, t := range tables {
+		st := strings.SplitN(strings.TrimSpace(t), ".", 2)
+		if len(st) != 2 || !delayedIdentifier.MatchString(st[0]) || !delayedIdentifier.MatchString(st[1]) {
+			return nil, errors.New("Table " + t + " is not schema.table of unquoted identifiers")
+		}
+		bySchema[st[0]] = append(bySchema[st[0]], st[1])
+	}
+	return bySchema, nil
+}
+
+// findBinlogTrxAtTime decodes the binary logs with mysqlbinlog and returns the first transaction written at or after t
+func (server *ServerMonitor) findBinlogTrxAtTime(files []string, pos string, t time.Time) (dbhelper.BinlogTrx, error) {
+	cluster := server.ClusterGroup
+	for i, file := range files {
+		args := []string{"--read-from-remote-server", "--user=" + cluster.rplUser, "--password=" + cluster.rplPass, "--host=" + misc.Unbracket(server.Host), "--port=" + server.Port, "--base64-output=DECODE-ROWS", "--start-datetime=" + t.Format("2006-01-02 15:04:05")}
+		if i == 0 && pos != "" {
+			args = append(args, "--start-position="+pos)
+		}
+		args = append(args, file)
+		cmd := exec.Command(cluster.GetMysqlBinlogPath(), args...)
+		cluster.LogPrintf(LvlDbg, "Command: %s", strings.Replace(cmd.String(), cluster.rplPass, "XXXX", -1))
+		out, err := cmd.Output()
+		if err != nil {
+			return dbhelper.BinlogTrx{}, err
+		}
+		if p, gtid, ok := parseMysqlbinlogTrx(string(out), t); ok {
+			return dbhelper.BinlogTrx{Gtid: gtid, File: file, Pos: p}, nil
+		}
+	}
+	return dbhelper.BinlogTrx{}, errors.New("No transaction found after " + t.String())
+}
+
+// parseMysqlbinlogTrx returns the position and GTID of the first transaction of a mysqlbinlog output
+// written at or after t, event headers look like
+// #230101  9:05:01 server id 1  end_log_pos 370 CRC32 0x3c4e8a1b 	GTID 0-1-6 trans
+func parseMysqlbinlogTrx(out string, t time.Time) (uint, string, bool) {
+	var at uint64
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "# at ") {
+			if found {
+				break
+			}
+			at, _ = strconv.ParseUint(strings.TrimSpace(line[5:]), 10, 64)
+			continue
+		}
+		if found {
+			// MySQL prints the GTID in the statement following the header
+			if i := strings.Index(line, "GTID_NEXT="); i >= 0 {
+				return uint(at), dbhelper.GetBinlogEventGtid(strings.TrimSuffix(line[i:], "/*!*/;")), true
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "#") || !strings.Contains(line, "end_log_pos") || at <= 4 {
+			continue
+		}
+		f := strings.Fields(line[1:])
+		if len(f) < 2 {
+			continue
+		}
+		ts, err := time.ParseInLocation("060102 15:04:05", f[0]+" "+f[1], time.Local)
+		if err != nil || ts.Before(t) {
+			continue
+		}
+		if strings.Contains(line, "GTID") || strings.Contains(line, "Query") {
+			found = true
+			if strings.Contains(line, "\tGTID ") {
+				return uint(at), dbhelper.GetBinlogEventGtid(line[strings.Index(line, "\tGTID ")+1:]), true
+			}
+		}
+	}
+	return uint(at), "", found
+}
@@ -0,0 +1,94 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+const mariadbBinlogOutput = `# at 4
+#230101  9:00:00 server id 1  end_log_pos 256 CRC32 0x11111111 	Start: binlog v 4, server v 10.6.12-MariaDB-log created 230101  9:00:00
+# at 256
+#230101  9:00:00 server id 1  end_log_pos 285 CRC32 0x22222222 	Gtid list [0-1-5]
+# at 285
+#230101  9:05:00 server id 1  end_log_pos 327 CRC32 0x3c4e8a1b 	GTID 0-1-6 trans
+/*!100001 SET @@session.gtid_seq_no=6*//*!*/;
+BEGIN
+/*!*/;
+# at 327
+#230101  9:05:00 server id 1  end_log_pos 420 CRC32 0x3c4e8a1c 	Query	thread_id=8	exec_time=0	error_code=0	xid=0
+SET TIMESTAMP=1672560300/*!*/;
+INSERT INTO t VALUES (1)
+/*!*/;
+# at 420
+#230101  9:06:00 server id 1  end_log_pos 462 CRC32 0x3c4e8a1d 	GTID 0-1-7 ddl
+/*!100001 SET @@session.gtid_seq_no=7*//*!*/;
+# at 462
+#230101  9:06:00 server id 1  end_log_pos 550 CRC32 0x3c4e8a1e 	Query	thread_id=8	exec_time=0	error_code=0	xid=0
+DROP TABLE t
+/*!*/;
+`
+
+const mysqlBinlogOutput = `# at 4
+#230101  9:00:00 server id 1  end_log_pos 125 CRC32 0x11111111 	Start: binlog v 4, server v 8.0.32 created 230101  9:00:00
+# at 125
+#230101  9:00:00 server id 1  end_log_pos 156 CRC32 0x22222222 	Previous-GTIDs
+# [empty]
+# at 156
+#230101  9:05:00 server id 1  end_log_pos 235 CRC32 0x33333333 	GTID	last_committed=0	sequence_number=1	rbr_only=no	original_committed_timestamp=1672560300000000
+/*!50718 SET TRANSACTION ISOLATION LEVEL READ COMMITTED*//*!*/;
+SET @@SESSION.GTID_NEXT= '3e11fa47-71ca-11e1-9e33-c80aa9429562:23'/*!*/;
+# at 235
+#230101  9:05:00 server id 1  end_log_pos 330 CRC32 0x44444444 	Query	thread_id=8	exec_time=0	error_code=0
+DROP TABLE t
+/*!*/;
+`
+
+func TestParseMysqlbinlogTrx(t *testing.T) {
+	at := func(clock string) time.Time {
+		ts, _ := time.ParseInLocation("2006-01-02 15:04:05", "2023-01-01 "+clock, time.Local)
+		return ts
+	}
+	for _, c := range []struct {
+		name string
+		out  string
+		t    time.Time
+		pos  uint
+		gtid string
+		ok   bool
+	}{
+		{"mariadb first", mariadbBinlogOutput, at("09:01:00"), 285, "0-1-6", true},
+		{"mariadb exact time", mariadbBinlogOutput, at("09:06:00"), 420, "0-1-7", true},
+		{"mariadb after", mariadbBinlogOutput, at("09:07:00"), 462, "", false},
+		{"mysql", mysqlBinlogOutput, at("09:01:00"), 156, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23", true},
+		{"empty", "", at("09:01:00"), 0, "", false},
+	} {
+		pos, gtid, ok := parseMysqlbinlogTrx(c.out, c.t)
+		if ok != c.ok || gtid != c.gtid || (ok && pos != c.pos) {
+			t.Errorf("%s: expected %d %q %t got %d %q %t", c.name, c.pos, c.gtid, c.ok, pos, gtid, ok)
+		}
+	}
+}
+
+func TestDelayedTablesBySchema(t *testing.T) {
+	bySchema, err := delayedTablesBySchema([]string{"app.users", " app.orders ", "crm.contacts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bySchema["app"]) != 2 || len(bySchema["crm"]) != 1 {
+		t.Errorf("Unexpected tables by schema %v", bySchema)
+	}
+	for _, table := range []string{"users", "app.", ".users", "app`; DROP DATABASE app; --.users", "app.--where=1", "app.users orders"} {
+		if _, err := delayedTablesBySchema([]string{table}); err == nil {
+			t.Errorf("Expected table %q to be refused", table)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/digesthistory"
+)
+
+func (server *ServerMonitor) GetDigestHistoryDir() string {
+	return server.ClusterGroup.Conf.WorkingDir + "/" + server.ClusterGroup.Name + "/digests/" + server.Host + "_" + server.Port
+}
+
+func (server *ServerMonitor) getDigestHistory() (*digesthistory.Store, error) {
+	if server.digestHistory == nil {
+		st, err := digesthistory.Open(server.GetDigestHistoryDir(), server.ClusterGroup.Conf.MonitorDigestHistoryKeep)
+		if err != nil {
+			return nil, err
+		}
+		server.digestHistory = st
+	}
+	return server.digestHistory, nil
+}
+
+// SampleDigestHistory stores the activity of the digests since the previous sample
+// and looks every hour for the digests slower than the previous day
+func (server *ServerMonitor) SampleDigestHistory() {
+	cluster := server.ClusterGroup
+	st, now := server.sampleDigestHistory()
+	if st == nil {
+		return
+	}
+	since := now.Add(-time.Hour)
+	regressions, err := st.Regressions(since.Add(-24*time.Hour), since, now, cluster.Conf.MonitorDigestHistoryRegressionRatio, int64(cluster.Conf.MonitorDigestHistoryRegressionMinCount))
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Could not check digest regressions of %s: %s", server.URL, err)
+		return
+	}
+	for _, r := range regressions {
+		cluster.LogPrintf(LvlInfo, "Digest %s is %.1f times slower on %s: %s", r.Digest, r.Ratio, server.URL, r.Text)
+	}
+	server.digestRegressionMutex.Lock()
+	server.DigestRegressions = regressions
+	server.digestRegressionMutex.Unlock()
+}
+
+// sampleDigestHistory returns the store when the regressions are due for a check
+func (server *ServerMonitor) sampleDigestHistory() (*digesthistory.Store, time.Time) {
+	server.digestMutex.Lock()
+	defer server.digestMutex.Unlock()
+	cluster := server.ClusterGroup
+	if server.Conn == nil {
+		return nil, time.Time{}
+	}
+	st, err := server.getDigestHistory()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Could not open digest history of %s: %s", server.URL, err)
+		return nil, time.Time{}
+	}
+	rows, logs, err := dbhelper.GetPFSDigestCounters(server.Conn, cluster.Conf.MonitorDigestHistoryDigests)
+	cluster.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get digest counters %s %s", server.URL, err)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	now := time.Now()
+	counters := make([]digesthistory.Counters, len(rows))
+	index := make(map[string]int, len(rows))
+	for i, r := range rows {
+		counters[i] = digesthistory.Counters{
+			Digest:       r.Digest,
+			Schema:       r.Schema_name,
+			Text:         r.Digest_text,
+			Count:        r.Exec_count,
+			Errors:       r.Err_count,
+			RowsExamined: r.Rows_examined,
+			RowsSent:     r.Rows_sent,
+			Latency:      r.Latency,
+			Age:          r.Age,
+		}
+		index[r.Digest] = i
+	}
+	if server.DBVersion.IsMySQLOrPercona() && server.DBVersion.Major >= 8 {
+		buckets, logs, err := dbhelper.GetPFSDigestHistograms(server.Conn)
+		cluster.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get digest histograms %s %s", server.URL, err)
+		for _, b := range buckets {
+			if i, ok := index[b.Digest]; ok {
+				if counters[i].Histogram == nil {
+					counters[i].Histogram = make(map[float64]int64)
+				}
+				counters[i].Histogram[b.Bucket_high] += b.Count
+			}
+		}
+	}
+	if server.digestSampler == nil {
+		server.digestSampler = digesthistory.NewSampler()
+	}
+	sample := server.digestSampler.Sample(now, counters)
+	if sample == nil {
+		return nil, time.Time{}
+	}
+	if err := st.Append(sample, counters); err != nil {
+		cluster.LogPrintf(LvlErr, "Could not write digest history of %s: %s", server.URL, err)
+		return nil, time.Time{}
+	}
+	if now.Sub(server.digestRegressionTime) < time.Hour {
+		return nil, time.Time{}
+	}
+	server.digestRegressionTime = now
+	return st, now
+}
+
+// GetDigestRegressions returns the regressions found by the last hourly check
+func (server *ServerMonitor) GetDigestRegressions() []digesthistory.Regression {
+	server.digestRegressionMutex.Lock()
+	defer server.digestRegressionMutex.Unlock()
+	return server.DigestRegressions
+}
+
+// GetDigestHistoryTop returns the digests with the most activity in [from, to)
+// ordered on total latency, rows examined, executions or errors
+func (server *ServerMonitor) GetDigestHistoryTop(from time.Time, to time.Time, order string, limit int) ([]digesthistory.Summary, error) {
+	st, err := server.getDigestHistoryForRead()
+	if err != nil {
+		return nil, err
+	}
+	return st.Top(from, to, order, limit)
+}
+
+// GetDigestHistoryRegressions compares the digests in [since, to) with [baseline, since)
+func (server *ServerMonitor) GetDigestHistoryRegressions(baseline time.Time, since time.Time, to time.Time) ([]digesthistory.Regression, error) {
+	st, err := server.getDigestHistoryForRead()
+	if err != nil {
+		return nil, err
+	}
+	return st.Regressions(baseline, since, to, server.ClusterGroup.Conf.MonitorDigestHistoryRegressionRatio, int64(server.ClusterGroup.Conf.MonitorDigestHistoryRegressionMinCount))
+}
+
+// GetDigestHistorySparkline returns the activity of a digest in steps of [from, to)
+func (server *ServerMonitor) GetDigestHistorySparkline(digest string, from time.Time, to time.Time, steps int) ([]digesthistory.SparkPoint, error) {
+	st, err := server.getDigestHistoryForRead()
+	if err != nil {
+		return nil, err
+	}
+	return st.Sparkline(digest, from, to, steps)
+}
+
+func (server *ServerMonitor) getDigestHistoryForRead() (*digesthistory.Store, error) {
+	server.digestMutex.Lock()
+	defer server.digestMutex.Unlock()
+	if server.digestHistory == nil && !server.ClusterGroup.Conf.MonitorDigestHistory {
+		return nil, errors.New("Digest history is disabled")
+	}
+	return server.getDigestHistory()
+}
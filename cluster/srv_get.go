@@ -25,6 +25,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/gtid"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/s18log"
 	"github.com/signal18/replication-manager/utils/state"
@@ -812,3 +813,67 @@ func (server *ServerMonitor) GetDatabaseDynamicConfig(filter string, cmd string)
 	}
 	return mydynamicconf
 }
+
+// GetErrantTransactions returns the GTIDs executed on a MySQL server that are not part of the master gtid_executed
+func (server *ServerMonitor) GetErrantTransactions() (gtid.MySQLSet, error) {
+	master := server.ClusterGroup.GetMaster()
+	if master == nil {
+		return nil, errors.New("No master found")
+	}
+	if master.URL == server.URL {
+		return make(gtid.MySQLSet), nil
+	}
+	slaveSet, err := gtid.NewMySQLSet(server.Variables["GTID_EXECUTED"])
+	if err != nil {
+		return nil, err
+	}
+	masterSet, err := gtid.NewMySQLSet(master.Variables["GTID_EXECUTED"])
+	if err != nil {
+		return nil, err
+	}
+	errant := slaveSet.Subtract(masterSet)
+	if errant.IsEmpty() || master.Conn == nil {
+		return errant, nil
+	}
+	// master variables may be older than the slave ones, confirm with a fresh gtid_executed
+	executed, logs, err := dbhelper.GetVariableByName(master.Conn, "GTID_EXECUTED", master.DBVersion)
+	server.ClusterGroup.LogSQL(logs, err, master.URL, "Monitor", LvlDbg, "Could not get gtid_executed %s %s", master.URL, err)
+	if err != nil {
+		return errant, nil
+	}
+	masterSet, err = gtid.NewMySQLSet(executed)
+	if err != nil {
+		return nil, err
+	}
+	return slaveSet.Subtract(masterSet), nil
+}
+
+// GetErrantTransactionsEvents returns the binlog events of the errant transactions found in the server binary logs
+func (server *ServerMonitor) GetErrantTransactionsEvents() ([]dbhelper.GtidEvents, error) {
+	errant, err := server.GetErrantTransactions()
+	if err != nil {
+		return nil, err
+	}
+	if errant.IsEmpty() {
+		return []dbhelper.GtidEvents{}, nil
+	}
+	binlogs, logs, err := dbhelper.GetBinaryLogs(server.Conn, server.DBVersion)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Rejoin", LvlErr, "Could not get binary log files %s %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for file := range binlogs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	events, logs, err := dbhelper.GetBinlogEventsByGtid(server.Conn, files, errant.ContainsGtid, maxErrantTrxEvents)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Rejoin", LvlDbg, "Could not get binlog events %s %s", server.URL, err)
+	return events, err
+}
+
+func (server *ServerMonitor) GetSSTJob() (string, string) {
+	server.sstMutex.Lock()
+	defer server.sstMutex.Unlock()
+	return server.sstTask, server.sstToken
+}
@@ -0,0 +1,66 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+// RefreshGroupReplication fetches the group view of the member and finds its own role and state
+func (server *ServerMonitor) RefreshGroupReplication() {
+	members, logs, err := dbhelper.GetGroupReplicationMembers(server.Conn)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get group replication members %s %s", server.URL, err)
+	if err != nil {
+		return
+	}
+	server.GroupReplicationMembers = members
+	server.IsGroupReplicationPrimary = false
+	server.GroupReplicationState = ""
+	for _, m := range members {
+		if strings.EqualFold(m.MemberId, server.GetGroupReplicationMemberId()) {
+			server.GroupReplicationState = m.MemberState
+			server.IsGroupReplicationPrimary = m.MemberRole == "PRIMARY" && m.MemberState == "ONLINE"
+		}
+	}
+}
+
+// GetGroupReplicationMemberId returns the uuid identifying the server in the group
+func (server *ServerMonitor) GetGroupReplicationMemberId() string {
+	return server.Variables["SERVER_UUID"]
+}
+
+// IsGroupReplicationOnline returns true when the member is part of the group and applies its transactions
+func (server *ServerMonitor) IsGroupReplicationOnline() bool {
+	return server.HaveGroupReplication && server.GroupReplicationState == "ONLINE"
+}
+
+// HasGroupReplicationQuorum returns true when a majority of the members are online in the group view
+func (server *ServerMonitor) HasGroupReplicationQuorum() bool {
+	online := 0
+	for _, m := range server.GroupReplicationMembers {
+		if m.MemberState == "ONLINE" {
+			online++
+		}
+	}
+	return online*2 > len(server.GroupReplicationMembers)
+}
+
+// SetGroupReplicationPrimary asks the group to move the primary role to the server
+func (server *ServerMonitor) SetGroupReplicationPrimary() error {
+	if server.Conn == nil {
+		return errors.New("No database connection pool")
+	}
+	logs, err := dbhelper.SetGroupReplicationPrimary(server.Conn, server.GetGroupReplicationMemberId())
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "MasterFailover", LvlErr, "Could not set %s as group primary %s", server.URL, err)
+	return err
+}
@@ -164,6 +164,11 @@ func (server *ServerMonitor) HasWsrep() bool {
 	return server.Variables["WSREP_ON"] == "ON"
 }
 
+// HasGroupReplication returns true when the group replication plugin is configured
+func (server *ServerMonitor) HasGroupReplication() bool {
+	return server.Variables["GROUP_REPLICATION_GROUP_NAME"] != ""
+}
+
 func (server *ServerMonitor) HasEventScheduler() bool {
 	return server.Variables["EVENT_SCHEDULER"] == "ON"
 }
@@ -303,6 +308,11 @@ func (server *ServerMonitor) IsAcid() bool {
 	return false
 }
 
+// IsPgStream returns true when the server is a PostgreSQL node of a streaming replication cluster
+func (server *ServerMonitor) IsPgStream() bool {
+	return server.DBVersion.IsPPostgreSQL() && server.ClusterGroup.Conf.MasterSlavePgStream
+}
+
 func (server *ServerMonitor) HasSlaves(sib []*ServerMonitor) bool {
 	for _, sl := range sib {
 		sssib, err := sl.GetSlaveStatus(sl.ReplicationSourceName)
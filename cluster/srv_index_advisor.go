@@ -0,0 +1,128 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/indexadvisor"
+)
+
+// GetIndexAdvice explains the performance schema digests with the highest total
+// latency and returns the indexes to create for their full scans, filesorts and
+// temporary tables, with the redundant and unused indexes of the server
+func (server *ServerMonitor) GetIndexAdvice() (*indexadvisor.Report, error) {
+	cluster := server.ClusterGroup
+	if server.Conn == nil || server.IsDown() {
+		return nil, errors.New("No database connection pool")
+	}
+	if server.DBVersion.IsPPostgreSQL() {
+		return nil, errors.New("Index advisor is not supported on PostgreSQL")
+	}
+	queries, logs, err := dbhelper.GetQueries(server.Conn)
+	cluster.LogSQL(logs, err, server.URL, "IndexAdvisor", LvlErr, "Could not get queries %s %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	rows, logs, err := dbhelper.GetIndexColumns(server.Conn)
+	cluster.LogSQL(logs, err, server.URL, "IndexAdvisor", LvlErr, "Could not get indexes %s %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	columns, logs, err := dbhelper.GetSchemaColumns(server.Conn)
+	cluster.LogSQL(logs, err, server.URL, "IndexAdvisor", LvlErr, "Could not get columns %s %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	// without performance schema instruments the unused indexes are not reported
+	unusedRows, logs, err := dbhelper.GetPFSUnusedIndexes(server.Conn)
+	cluster.LogSQL(logs, err, server.URL, "IndexAdvisor", LvlDbg, "Could not get unused indexes %s %s", server.URL, err)
+
+	tables := make(map[string]*indexadvisor.Table)
+	var tableList []indexadvisor.Table
+	for _, c := range columns {
+		k := c.Table_schema + "." + c.Table_name
+		t, ok := tables[k]
+		if !ok {
+			t = &indexadvisor.Table{Schema: c.Table_schema, Name: c.Table_name}
+			if dt, ok := server.DictTables[k]; ok {
+				t.Rows = dt.Table_rows
+			}
+			tables[k] = t
+		}
+		t.Columns = append(t.Columns, c.Column_name)
+	}
+	for _, t := range tables {
+		tableList = append(tableList, *t)
+	}
+	indexes := indexList(rows)
+	unique := make(map[string]bool)
+	for _, idx := range indexes {
+		unique[idx.Schema+"."+idx.Table+"."+idx.Name] = idx.Unique
+	}
+	var unused []indexadvisor.Index
+	for _, idx := range indexList(unusedRows) {
+		idx.Unique = unique[idx.Schema+"."+idx.Table+"."+idx.Name]
+		unused = append(unused, idx)
+	}
+
+	advisor := indexadvisor.NewAdvisor(tableList, indexes, int64(cluster.Conf.IndexAdvisorMinRows))
+	digests := make([]dbhelper.PFSQuery, 0, len(queries))
+	for _, q := range queries {
+		digests = append(digests, q)
+	}
+	sort.Sort(dbhelper.PFSQuerySorter(digests))
+	if len(digests) > cluster.Conf.IndexAdvisorDigests {
+		digests = digests[:cluster.Conf.IndexAdvisorDigests]
+	}
+	for _, q := range digests {
+		latency, _ := strconv.ParseFloat(q.Value, 64)
+		d := indexadvisor.Digest{
+			Digest:       q.Digest,
+			Schema:       q.Schema_name,
+			Query:        q.Query,
+			Count:        q.Exec_count,
+			Latency:      latency * 1000,
+			RowsExamined: q.Rows_scanned,
+		}
+		if q.Query == "" {
+			advisor.Add(d, nil, errors.New("No query sample in the statements history"))
+			continue
+		}
+		explain, logs, err := dbhelper.GetQueryPlan(server.Conn, q.Schema_name, q.Query)
+		cluster.LogSQL(logs, err, server.URL, "IndexAdvisor", LvlDbg, "Could not explain digest %s %s", q.Digest, err)
+		plans := make([]indexadvisor.Plan, len(explain))
+		for i, e := range explain {
+			plans[i] = indexadvisor.Plan{Table: e.Table.String, Type: e.Type.String, Key: e.Key.String, Extra: e.Extra.String}
+			plans[i].Rows, _ = strconv.ParseInt(e.Rows.String, 10, 64)
+		}
+		advisor.Add(d, plans, err)
+	}
+	return advisor.Report(unused), nil
+}
+
+// indexList groups the columns of the indexes returned in index order
+func indexList(rows []dbhelper.IndexColumn) []indexadvisor.Index {
+	var indexes []indexadvisor.Index
+	for _, r := range rows {
+		n := len(indexes)
+		if n == 0 || indexes[n-1].Schema != r.Table_schema || indexes[n-1].Table != r.Table_name || indexes[n-1].Name != r.Index_name {
+			indexes = append(indexes, indexadvisor.Index{Schema: r.Table_schema, Table: r.Table_name, Name: r.Index_name, Unique: r.Non_unique == 0})
+			n++
+		}
+		if r.Column_name != "" {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, r.Column_name)
+		}
+	}
+	return indexes
+}
@@ -31,10 +31,13 @@ import (
 	"github.com/jmoiron/sqlx"
 	dumplingext "github.com/pingcap/dumpling/v4/export"
 	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/backupstore"
 	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/hooks"
 	"github.com/signal18/replication-manager/utils/misc"
 	river "github.com/signal18/replication-manager/utils/river"
 	"github.com/signal18/replication-manager/utils/s18log"
+	sstchannel "github.com/signal18/replication-manager/utils/sst"
 	"github.com/signal18/replication-manager/utils/state"
 )
 
@@ -48,20 +51,62 @@ func (server *ServerMonitor) JobsCreateTable() error {
 	}
 
 	server.ExecQueryNoBinLog("CREATE DATABASE IF NOT EXISTS  replication_manager_schema")
-	err := server.ExecQueryNoBinLog("CREATE TABLE IF NOT EXISTS replication_manager_schema.jobs(id INT NOT NULL auto_increment PRIMARY KEY, task VARCHAR(20),  port INT, server VARCHAR(255), done TINYINT not null default 0, result VARCHAR(1000), start DATETIME, end DATETIME, KEY idx1(task,done) ,KEY idx2(result(1),task)) engine=innodb")
+	err := server.ExecQueryNoBinLog("CREATE TABLE IF NOT EXISTS replication_manager_schema.jobs(id INT NOT NULL auto_increment PRIMARY KEY, task VARCHAR(20),  port INT, server VARCHAR(255), token VARCHAR(1024), lsn BIGINT UNSIGNED, done TINYINT not null default 0, result VARCHAR(1000), start DATETIME, end DATETIME, KEY idx1(task,done) ,KEY idx2(result(1),task)) engine=innodb")
 	if err != nil {
 		if server.ClusterGroup.Conf.LogLevel > 2 {
 			server.ClusterGroup.LogPrintf(LvlErr, "Can't create table replication_manager_schema.jobs")
 		}
+		return err
+	}
+	// jobs table created before SST tokens and incremental backups
+	for _, column := range []struct{ name, definition string }{
+		{"token", "token VARCHAR(1024) AFTER server"},
+		{"lsn", "lsn BIGINT UNSIGNED AFTER token"},
+	} {
+		var hasColumn int
+		err = server.Conn.QueryRowx("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA='replication_manager_schema' AND TABLE_NAME='jobs' AND COLUMN_NAME='" + column.name + "'").Scan(&hasColumn)
+		if err == nil && hasColumn == 0 {
+			err = server.ExecQueryNoBinLog("ALTER TABLE replication_manager_schema.jobs ADD COLUMN " + column.definition)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// sealed tokens are longer than the plain tokens of older versions
+	var tokenLength int
+	err = server.Conn.QueryRowx("SELECT CHARACTER_MAXIMUM_LENGTH FROM information_schema.COLUMNS WHERE TABLE_SCHEMA='replication_manager_schema' AND TABLE_NAME='jobs' AND COLUMN_NAME='token'").Scan(&tokenLength)
+	if err == nil && tokenLength < 1024 {
+		err = server.ExecQueryNoBinLog("ALTER TABLE replication_manager_schema.jobs MODIFY COLUMN token VARCHAR(1024)")
 	}
 	return err
 }
 
 func (server *ServerMonitor) JobInsertTaks(task string, port string, repmanhost string) (int64, error) {
+	return server.JobInsertTaksToken(task, port, "", repmanhost)
+}
+
+// JobInsertTaksToken inserts a job transferring data on the SST channel, the token
+// is the one-time secret of the transfer, it is stored sealed with the cluster
+// client certificate that only the database hosts can open
+func (server *ServerMonitor) JobInsertTaksToken(task string, port string, token string, repmanhost string) (int64, error) {
+	return server.JobInsertTaksFromLSN(task, port, token, "NULL", repmanhost)
+}
+
+// JobInsertTaksFromLSN inserts an incremental backup job copying the changes
+// since lsn
+func (server *ServerMonitor) JobInsertTaksFromLSN(task string, port string, token string, lsn string, repmanhost string) (int64, error) {
 	if server.ClusterGroup.IsInFailover() {
 		server.ClusterGroup.LogPrintf(LvlInfo, "Cancel job %s during failover", task)
 		return 0, errors.New("In failover can't insert job")
 	}
+	if token != "" {
+		sealed, err := sstchannel.SealToken(server.ClusterGroup.WorkingDir+"/client-cert.pem", token)
+		if err != nil {
+			server.ClusterGroup.LogPrintf(LvlErr, "Job can't seal the token of %s: %s", task, err)
+			return 0, err
+		}
+		token = sealed
+	}
 	server.JobsCreateTable()
 	conn, err := sqlx.Connect("mysql", server.DSN)
 	if err != nil {
@@ -80,8 +125,9 @@ func (server *ServerMonitor) JobInsertTaks(task string, port string, repmanhost
 	}
 
 	if task != "" {
-		res, err := conn.Exec("INSERT INTO replication_manager_schema.jobs(task, port,server,start) VALUES('" + task + "'," + port + ",'" + repmanhost + "', NOW())")
+		res, err := conn.Exec("INSERT INTO replication_manager_schema.jobs(task, port,server,token,lsn,start) VALUES('" + task + "'," + port + ",'" + repmanhost + "','" + token + "'," + lsn + ", NOW())")
 		if err == nil {
+			server.ClusterGroup.PublishEvent(EventJob, server.URL, EventJobData{Task: task, Status: "queued"})
 			return res.LastInsertId()
 		}
 		server.ClusterGroup.LogPrintf(LvlErr, "Job can't insert job %s", err)
@@ -90,6 +136,17 @@ func (server *ServerMonitor) JobInsertTaks(task string, port string, repmanhost
 	return 0, nil
 }
 
+// JobInsertSSTReceiver inserts a job receiving a backup sent by SSTRunSender on the
+// SST port of the server
+func (server *ServerMonitor) JobInsertSSTReceiver(task string) (int64, error) {
+	token, err := sstchannel.NewToken()
+	if err != nil {
+		return 0, err
+	}
+	server.SetSSTJob(task, token)
+	return server.JobInsertTaksToken(task, server.SSTPort, token, server.ClusterGroup.Conf.MonitorAddress)
+}
+
 func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 	//server can be nil as no dicovered master
 	if server == nil {
@@ -99,22 +156,30 @@ func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 	if server.IsDown() {
 		return 0, nil
 	}
-	// not  needed to stream internaly using S3 fuse
+	if server.DBVersion.IsPPostgreSQL() {
+		return 0, server.JobBackupPgBasebackup()
+	}
+	// the backup store streams to the bucket, restic is not needed
 	/*
 		if server.ClusterGroup.Conf.BackupRestic {
-			port, err := server.ClusterGroup.SSTRunReceiverToRestic(server.DSN + ".xbtream")
+			port, token, err := server.ClusterGroup.SSTRunReceiverToRestic(server.ClusterGroup.Conf.BackupPhysicalType, server.DSN + ".xbtream")
 			if err != nil {
 				return 0, nil
 			}
-			jobid, err := server.JobInsertTaks(server.ClusterGroup.Conf.BackupPhysicalType, port, server.ClusterGroup.Conf.MonitorAddress)
+			jobid, err := server.JobInsertTaksToken(server.ClusterGroup.Conf.BackupPhysicalType, port, token, server.ClusterGroup.Conf.MonitorAddress)
 			return jobid, err
 		} else {
 	*/
-	port, err := server.ClusterGroup.SSTRunReceiverToFile(server.GetMyBackupDirectory()+server.ClusterGroup.Conf.BackupPhysicalType+".xbtream", ConstJobCreateFile)
+	w, err := server.newBackupChainWriter(false)
+	if err != nil {
+		server.ClusterGroup.LogPrintf(LvlErr, "Physical backup of %s can't be stored: %s", server.URL, err)
+		return 0, nil
+	}
+	port, token, err := server.ClusterGroup.SSTRunReceiverToWriter(server.ClusterGroup.Conf.BackupPhysicalType, w)
 	if err != nil {
 		return 0, nil
 	}
-	jobid, err := server.JobInsertTaks(server.ClusterGroup.Conf.BackupPhysicalType, port, server.ClusterGroup.Conf.MonitorAddress)
+	jobid, err := server.JobInsertTaksToken(server.ClusterGroup.Conf.BackupPhysicalType, port, token, server.ClusterGroup.Conf.MonitorAddress)
 
 	return jobid, err
 	//	}
@@ -123,7 +188,7 @@ func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 
 func (server *ServerMonitor) JobReseedPhysicalBackup() (int64, error) {
 
-	jobid, err := server.JobInsertTaks("reseed"+server.ClusterGroup.Conf.BackupPhysicalType, server.SSTPort, server.ClusterGroup.Conf.MonitorAddress)
+	jobid, err := server.JobInsertSSTReceiver("reseed" + server.ClusterGroup.Conf.BackupPhysicalType)
 
 	if err != nil {
 		server.ClusterGroup.LogPrintf(LvlErr, "Receive reseed physical backup %s request for server: %s %s", server.ClusterGroup.Conf.BackupPhysicalType, server.URL, err)
@@ -154,7 +219,7 @@ func (server *ServerMonitor) JobReseedPhysicalBackup() (int64, error) {
 
 func (server *ServerMonitor) JobFlashbackPhysicalBackup() (int64, error) {
 
-	jobid, err := server.JobInsertTaks("flashback"+server.ClusterGroup.Conf.BackupPhysicalType, server.SSTPort, server.ClusterGroup.Conf.MonitorAddress)
+	jobid, err := server.JobInsertSSTReceiver("flashback" + server.ClusterGroup.Conf.BackupPhysicalType)
 
 	if err != nil {
 		server.ClusterGroup.LogPrintf(LvlErr, "Receive reseed physical backup %s request for server: %s %s", server.ClusterGroup.Conf.BackupPhysicalType, server.URL, err)
@@ -186,7 +251,7 @@ func (server *ServerMonitor) JobFlashbackPhysicalBackup() (int64, error) {
 }
 
 func (server *ServerMonitor) JobReseedLogicalBackup() (int64, error) {
-	jobid, err := server.JobInsertTaks("reseed"+server.ClusterGroup.Conf.BackupLogicalType, server.SSTPort, server.ClusterGroup.Conf.MonitorAddress)
+	jobid, err := server.JobInsertSSTReceiver("reseed" + server.ClusterGroup.Conf.BackupLogicalType)
 
 	if err != nil {
 		server.ClusterGroup.LogPrintf(LvlErr, "Receive reseed logical backup %s request for server: %s %s", server.ClusterGroup.Conf.BackupLogicalType, server.URL, err)
@@ -237,7 +302,7 @@ func (server *ServerMonitor) JobServerRestart() (int64, error) {
 }
 
 func (server *ServerMonitor) JobFlashbackLogicalBackup() (int64, error) {
-	jobid, err := server.JobInsertTaks("flashback"+server.ClusterGroup.Conf.BackupLogicalType, server.SSTPort, server.ClusterGroup.Conf.MonitorAddress)
+	jobid, err := server.JobInsertSSTReceiver("flashback" + server.ClusterGroup.Conf.BackupLogicalType)
 	if err != nil {
 		server.ClusterGroup.LogPrintf(LvlErr, "Receive reseed logical backup %s request for server: %s %s", server.ClusterGroup.Conf.BackupPhysicalType, server.URL, err)
 
@@ -272,11 +337,11 @@ func (server *ServerMonitor) JobBackupErrorLog() (int64, error) {
 	if server.IsDown() {
 		return 0, nil
 	}
-	port, err := server.ClusterGroup.SSTRunReceiverToFile(server.Datadir+"/log/log_error.log", ConstJobAppendFile)
+	port, token, err := server.ClusterGroup.SSTRunReceiverToFile("error", server.Datadir+"/log/log_error.log", ConstJobAppendFile)
 	if err != nil {
 		return 0, nil
 	}
-	return server.JobInsertTaks("error", port, server.ClusterGroup.Conf.MonitorAddress)
+	return server.JobInsertTaksToken("error", port, token, server.ClusterGroup.Conf.MonitorAddress)
 }
 
 // ErrorLogWatcher monitor the tail of the log and populate ring buffer
@@ -323,6 +388,7 @@ func (server *ServerMonitor) SlowLogWatcher() {
 			}
 			if log.Query != "" {
 				server.SlowLog.Add(log)
+				server.addWorkloadSlowMessage(log)
 			}
 			log = newlog
 		}
@@ -337,11 +403,11 @@ func (server *ServerMonitor) JobBackupSlowQueryLog() (int64, error) {
 	if server.IsDown() {
 		return 0, nil
 	}
-	port, err := server.ClusterGroup.SSTRunReceiverToFile(server.Datadir+"/log/log_slow_query.log", ConstJobAppendFile)
+	port, token, err := server.ClusterGroup.SSTRunReceiverToFile("slowquery", server.Datadir+"/log/log_slow_query.log", ConstJobAppendFile)
 	if err != nil {
 		return 0, nil
 	}
-	return server.JobInsertTaks("slowquery", port, server.ClusterGroup.Conf.MonitorAddress)
+	return server.JobInsertTaksToken("slowquery", port, token, server.ClusterGroup.Conf.MonitorAddress)
 }
 
 func (server *ServerMonitor) JobOptimize() (int64, error) {
@@ -360,6 +426,16 @@ func (server *ServerMonitor) JobZFSSnapBack() (int64, error) {
 
 func (server *ServerMonitor) JobReseedMyLoader() {
 
+	if !server.ClusterGroup.IsBackupStoreLocal() {
+		store, err := server.ClusterGroup.GetBackupStore()
+		if err != nil {
+			return
+		}
+		if err := backupstore.DownloadDir(store, server.ClusterGroup.master.GetMasterBackupKey(""), server.ClusterGroup.master.GetMasterBackupDirectory()); err != nil {
+			server.ClusterGroup.LogPrintf(LvlErr, "MyLoader download from %s failed: %s", store.Name(), err)
+			return
+		}
+	}
 	threads := strconv.Itoa(server.ClusterGroup.Conf.BackupLogicalLoadThreads)
 	dumpCmd := exec.Command(server.ClusterGroup.GetMyLoaderPath(), "--overwrite-tables", "--directory="+server.ClusterGroup.master.GetMasterBackupDirectory(), "--verbose=3", "--threads="+threads, "--host="+misc.Unbracket(server.Host), "--port="+server.Port, "--user="+server.ClusterGroup.dbUser, "--password="+server.ClusterGroup.dbPass)
 	server.ClusterGroup.LogPrintf(LvlInfo, "Command: %s", strings.Replace(dumpCmd.String(), server.ClusterGroup.dbPass, "XXXX", 1))
@@ -472,9 +548,12 @@ func (server *ServerMonitor) JobsCheckRunning() error {
 		return err
 	}
 	defer rows.Close()
+	running := make(map[string]int)
+	defer server.publishJobsProgress(running)
 	for rows.Next() {
 		var task DBTask
 		rows.Scan(&task.task, &task.ct)
+		running[task.task] = task.ct
 		if task.ct > 0 {
 			if task.ct > 10 {
 				server.ClusterGroup.sme.AddState("ERR00060", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(server.ClusterGroup.GetErrorList()["ERR00060"], server.URL), ErrFrom: "JOB", ServerUrl: server.URL})
@@ -556,6 +635,15 @@ func (server *ServerMonitor) GetMyBackupDirectory() string {
 
 }
 
+// GetMyBackupKey is the key of a backup file of the server in the backup store
+func (server *ServerMonitor) GetMyBackupKey(file string) string {
+	return server.ClusterGroup.Name + "/" + server.Host + "_" + server.Port + "/" + file
+}
+
+func (server *ServerMonitor) GetMasterBackupKey(file string) string {
+	return server.ClusterGroup.Name + "/" + server.ClusterGroup.master.Host + "_" + server.ClusterGroup.master.Port + "/" + file
+}
+
 func (server *ServerMonitor) GetMasterBackupDirectory() string {
 
 	s3dir := server.ClusterGroup.Conf.WorkingDir + "/" + config.ConstStreamingSubDir + "/" + server.ClusterGroup.Name + "/" + server.ClusterGroup.master.Host + "_" + server.ClusterGroup.master.Port
@@ -580,6 +668,7 @@ func (server *ServerMonitor) JobBackupLogical() error {
 	if server.IsDown() {
 		return nil
 	}
+	var backupErr error
 
 	if server.ClusterGroup.Conf.BackupLogicalType == config.ConstBackupLogicalTypeRiver {
 		cfg := new(river.Config)
@@ -627,7 +716,11 @@ func (server *ServerMonitor) JobBackupLogical() error {
 		dumpCmd := exec.Command(server.ClusterGroup.GetMysqlDumpPath(), dumpargs...)
 
 		server.ClusterGroup.LogPrintf(LvlInfo, "Command: %s ", strings.Replace(dumpCmd.String(), server.ClusterGroup.dbPass, "XXXX", -1))
-		f, err := os.Create(server.GetMyBackupDirectory() + "mysqldump.sql.gz")
+		store, err := server.ClusterGroup.GetBackupStore()
+		if err != nil {
+			return err
+		}
+		f, err := store.Create(server.GetMyBackupKey("mysqldump.sql.gz"))
 		if err != nil {
 			server.ClusterGroup.LogPrintf(LvlErr, "Error backup request: %s", err)
 			return err
@@ -639,6 +732,7 @@ func (server *ServerMonitor) JobBackupLogical() error {
 		stderrIn, _ := dumpCmd.StderrPipe()
 		err = dumpCmd.Start()
 		if err != nil {
+			f.Abort()
 			server.ClusterGroup.LogPrintf(LvlErr, "Error backup request: %s", err)
 			return err
 		}
@@ -654,11 +748,20 @@ func (server *ServerMonitor) JobBackupLogical() error {
 
 			if err != nil {
 				log.Println(err)
+				backupErr = err
 			}
 			gw.Flush()
 			gw.Close()
-			wf.Flush()
-			f.Close()
+			if err := wf.Flush(); err != nil {
+				f.Abort()
+				server.ClusterGroup.LogPrintf(LvlErr, "Error writing backup to %s: %s", store.Name(), err)
+				backupErr = err
+				return
+			}
+			if err := f.Close(); err != nil {
+				server.ClusterGroup.LogPrintf(LvlErr, "Error writing backup to %s: %s", store.Name(), err)
+				backupErr = err
+			}
 		}()
 		wg.Wait()
 
@@ -687,6 +790,7 @@ func (server *ServerMonitor) JobBackupLogical() error {
 
 		err := dumplingext.Dump(conf)
 		server.ClusterGroup.LogPrintf(LvlErr, "Dumpling %s", err)
+		backupErr = err
 
 	}
 	if server.ClusterGroup.Conf.BackupLogicalType == config.ConstBackupLogicalTypeMydumper {
@@ -730,10 +834,21 @@ func (server *ServerMonitor) JobBackupLogical() error {
 		wg.Wait()
 		if err := dumpCmd.Wait(); err != nil {
 			server.ClusterGroup.LogPrintf(LvlErr, "MyDumper: %s", err)
+			backupErr = err
 		}
 	}
 
+	if (server.ClusterGroup.Conf.BackupLogicalType == config.ConstBackupLogicalTypeDumpling || server.ClusterGroup.Conf.BackupLogicalType == config.ConstBackupLogicalTypeMydumper) && !server.ClusterGroup.IsBackupStoreLocal() {
+		// the dump tools write a directory staged before the upload
+		if store, err := server.ClusterGroup.GetBackupStore(); err == nil {
+			if err := backupstore.UploadDir(store, server.GetMyBackupKey(""), server.GetMyBackupDirectory()); err != nil {
+				server.ClusterGroup.LogPrintf(LvlErr, "Upload logical backup to %s failed: %s", store.Name(), err)
+				backupErr = err
+			}
+		}
+	}
 	server.ClusterGroup.LogPrintf(LvlInfo, "Finish logical backup %s for: %s", server.ClusterGroup.Conf.BackupLogicalType, server.URL)
+	server.fireBackupHook(&hooks.Backup{Type: "logical", Method: server.ClusterGroup.Conf.BackupLogicalType, Path: server.GetMyBackupKey("")}, backupErr)
 	server.BackupRestic()
 	return nil
 }
@@ -891,8 +1006,17 @@ func (server *ServerMonitor) JobBackupBinlog(binlogfile string) error {
 		return cmdrunErr
 	}
 
-	// Get
-
+	if !server.ClusterGroup.IsBackupStoreLocal() {
+		store, err := server.ClusterGroup.GetBackupStore()
+		if err != nil {
+			return err
+		}
+		if err := backupstore.Upload(store, server.GetMyBackupKey(binlogfile), server.GetMyBackupDirectory()+binlogfile); err != nil {
+			server.ClusterGroup.LogPrintf(LvlErr, "Failed to archive binlog %s of %s in %s: %s", binlogfile, server.URL, store.Name(), err)
+			return err
+		}
+		os.Remove(server.GetMyBackupDirectory() + binlogfile)
+	}
 	return nil
 }
 
@@ -903,36 +1027,37 @@ func (server *ServerMonitor) JobBackupBinlogPurge(binlogfile string) error {
 	if !server.ClusterGroup.Conf.BackupBinlogs {
 		return errors.New("Copy binlog not enable")
 	}
+	store, err := server.ClusterGroup.GetBackupStore()
+	if err != nil {
+		return err
+	}
 	binlogfilestart, _ := strconv.Atoi(strings.Split(binlogfile, ".")[1])
 	prefix := strings.Split(binlogfile, ".")[0]
 	binlogfilestop := binlogfilestart - server.ClusterGroup.Conf.BackupBinlogsKeep
-	keeping := make(map[string]int)
 	for binlogfilestop < binlogfilestart {
 		if binlogfilestop > 0 {
 			filename := prefix + "." + fmt.Sprintf("%06d", binlogfilestop)
-			if _, err := os.Stat(server.GetMyBackupDirectory() + "/" + filename); os.IsNotExist(err) {
+			if _, err := store.Stat(server.GetMyBackupKey(filename)); os.IsNotExist(err) {
 				if _, ok := server.BinaryLogFiles[filename]; ok {
 					server.ClusterGroup.LogPrintf(LvlInfo, "Backup master missing binlog of %s,%s", server.URL, filename)
 					server.JobBackupBinlog(filename)
 				}
 			}
-			keeping[filename] = binlogfilestop
 		}
 		binlogfilestop++
 	}
-	files, err := ioutil.ReadDir(server.GetMyBackupDirectory())
-	if err != nil {
-		server.ClusterGroup.LogPrintf(LvlErr, "Failed to read backup directory of %s,%s", server.URL, err.Error())
+	purged, err := backupstore.Prune(store, server.GetMyBackupKey(prefix+"."), backupstore.Retention{
+		Keep:      server.ClusterGroup.Conf.BackupBinlogsKeep,
+		MaxAge:    time.Duration(server.ClusterGroup.Conf.BackupStreamingKeepDays) * 24 * time.Hour,
+		Lifecycle: server.ClusterGroup.Conf.BackupStreamingLifecycle,
+	})
+	for _, key := range purged {
+		server.ClusterGroup.LogPrintf(LvlInfo, "Purging binlog file %s", key)
 	}
-
-	for _, file := range files {
-		_, ok := keeping[file.Name()]
-		if strings.HasPrefix(file.Name(), prefix) && !ok {
-			server.ClusterGroup.LogPrintf(LvlInfo, "Purging binlog file %s", file.Name())
-			os.Remove(server.GetMyBackupDirectory() + "/" + file.Name())
-		}
+	if err != nil {
+		server.ClusterGroup.LogPrintf(LvlErr, "Failed to purge binlogs of %s in %s,%s", server.URL, store.Name(), err.Error())
 	}
-	return nil
+	return err
 }
 
 func (server *ServerMonitor) JobCapturePurge(path string, keep int) error {
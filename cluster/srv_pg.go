@@ -0,0 +1,229 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/misc"
+	"golang.org/x/crypto/ssh"
+)
+
+// exit status of the pg_rewind script when the service could not be started again
+const pgRewindStopped = 4
+
+var (
+	errPgRewindStopped = errors.New("PostgreSQL service stopped after pg_rewind")
+	errPgRewindNotBack = errors.New("PostgreSQL service not reachable after pg_rewind")
+)
+
+// GetPgSlotName returns the physical replication slot used by the server when it streams from a primary
+func (server *ServerMonitor) GetPgSlotName() string {
+	return "repman_" + server.Id
+}
+
+// GetPgWalPosition returns the current LSN on a primary or the replayed LSN on a standby
+func (server *ServerMonitor) GetPgWalPosition() (uint64, error) {
+	if server.Conn == nil {
+		return 0, errors.New("No database connection pool")
+	}
+	pos, logs, err := dbhelper.GetPGWalPosition(server.Conn)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Monitor", LvlDbg, "Could not get WAL position %s %s", server.URL, err)
+	return pos, err
+}
+
+// PgPromote ends recovery on a standby and waits for it to accept writes
+func (server *ServerMonitor) PgPromote() error {
+	if server.Conn == nil {
+		return errors.New("No database connection pool")
+	}
+	// a paused replay would block the promotion
+	logs, err := dbhelper.PGResumeReplay(server.Conn)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "MasterFailover", LvlDbg, "Could not resume WAL replay on %s %s", server.URL, err)
+	logs, err = dbhelper.PGPromote(server.Conn, 60)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "MasterFailover", LvlErr, "Could not promote %s %s", server.URL, err)
+	return err
+}
+
+// PgStreamChangeMaster points a standby to a new primary, the slot is created on the primary first when slots are enabled
+func (server *ServerMonitor) PgStreamChangeMaster(master *ServerMonitor) (string, error) {
+	if server.Conn == nil {
+		return "", errors.New("No database connection pool")
+	}
+	slot := ""
+	if server.ClusterGroup.Conf.PgStreamSlots {
+		slot = server.GetPgSlotName()
+		logs, err := dbhelper.CreatePGPhysicalSlot(master.Conn, slot)
+		server.ClusterGroup.LogSQL(logs, err, master.URL, "Topology", LvlErr, "Could not create replication slot %s on %s %s", slot, master.URL, err)
+		if err != nil {
+			return logs, err
+		}
+	}
+	return dbhelper.SetPGPrimaryConnInfo(server.Conn, dbhelper.ChangeMasterOpt{
+		Host:     master.Host,
+		Port:     master.Port,
+		User:     server.ClusterGroup.rplUser,
+		Password: server.ClusterGroup.rplPass,
+		SSL:      server.ClusterGroup.Conf.ReplicationSSL,
+		Channel:  slot,
+	}, server.Id)
+}
+
+// PgWaitCatchUp waits until the standby has replayed all WAL written by the primary
+func (server *ServerMonitor) PgWaitCatchUp(master *ServerMonitor, timeout int64) error {
+	target, err := master.GetPgWalPosition()
+	if err != nil {
+		return err
+	}
+	for i := int64(0); i < timeout*10; i++ {
+		pos, err := server.GetPgWalPosition()
+		if err == nil && pos >= target {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("Timeout waiting standby " + server.URL + " to replay WAL of " + master.URL)
+}
+
+// PgDropSlot removes a replication slot that no longer has a standby attached
+func (server *ServerMonitor) PgDropSlot(slot string) error {
+	for _, s := range server.PGReplicationSlots {
+		if s.SlotName == slot && s.Active {
+			return errors.New("Replication slot " + slot + " is in use")
+		}
+	}
+	logs, err := dbhelper.DropPGSlot(server.Conn, slot)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Topology", LvlErr, "Could not drop replication slot %s on %s %s", slot, server.URL, err)
+	return err
+}
+
+// RejoinPgStream turns a failed primary into a standby of the current primary
+func (server *ServerMonitor) RejoinPgStream() error {
+	master := server.ClusterGroup.master
+	if server.ClusterGroup.Conf.AutorejoinPgRewind {
+		return server.JobRejoinPgRewind(master)
+	}
+	if server.ClusterGroup.Conf.RejoinScript != "" {
+		server.ClusterGroup.LogPrintf(LvlInfo, "Calling rejoin script")
+		out, err := exec.Command(server.ClusterGroup.Conf.RejoinScript, misc.Unbracket(server.Host), misc.Unbracket(master.Host)).CombinedOutput()
+		if err != nil {
+			server.ClusterGroup.LogPrintf(LvlErr, "%s", err)
+		}
+		server.ClusterGroup.LogPrintf(LvlInfo, "Rejoin script complete %s", string(out))
+		return err
+	}
+	server.ClusterGroup.LogPrintf(LvlInfo, "No pg_rewind or script rejoin method found for %s", server.URL)
+	return errors.New("No Postgres rejoin method found")
+}
+
+// JobRejoinPgRewind stops the server over ssh, rewinds its datadir to the timeline of the new primary and restarts it as a standby
+func (server *ServerMonitor) JobRejoinPgRewind(master *ServerMonitor) error {
+	if master == nil || master.URL == server.URL {
+		return errors.New("No primary to rewind from")
+	}
+	cluster := server.ClusterGroup
+	client, err := cluster.OnPremiseConnect(server)
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Rejoin pg_rewind %s: %s", server.URL, err)
+		return err
+	}
+	defer client.Close()
+	source := "host=" + misc.Unbracket(master.Host) + " port=" + master.Port + " user=" + cluster.dbUser + " dbname=postgres"
+	cluster.LogPrintf(LvlInfo, "Rejoin pg_rewind %s from %s", server.URL, source)
+	// the script is sent on the standard input of the remote shell so the password only lands in a
+	// private pgpass file removed on exit
+	out, err := client.Script(getPgRewindScript(cluster.Conf.PgServiceName, cluster.Conf.PgRewindPath, cluster.Conf.PgDatadir, source, getPgPassLine(misc.Unbracket(master.Host), master.Port, cluster.dbUser, cluster.dbPass))).SmartOutput()
+	cluster.LogPrintf(LvlInfo, "Rejoin pg_rewind %s: %s", server.URL, strings.Replace(string(out), cluster.dbPass, "XXXX", -1))
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Rejoin pg_rewind %s failed: %s", server.URL, err)
+		if exitErr, ok := err.(*ssh.ExitError); ok && exitErr.ExitStatus() == pgRewindStopped {
+			return errPgRewindStopped
+		}
+		return err
+	}
+	// pg_rewind wrote the monitoring credentials in primary_conninfo, switch to the replication user
+	for i := 0; i < 30; i++ {
+		if server.Conn != nil && server.Conn.Ping() == nil {
+			logs, err := server.PgStreamChangeMaster(master)
+			cluster.LogSQL(logs, err, server.URL, "Rejoin", LvlErr, "Could not set primary_conninfo on %s %s", server.URL, err)
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+	cluster.LogPrintf(LvlErr, "Rejoin pg_rewind %s: server not back after 30s", server.URL)
+	return errPgRewindNotBack
+}
+
+// getPgPassLine returns the pgpass entry of a user, backslash and colon are escaped
+func getPgPassLine(host string, port string, user string, password string) string {
+	r := strings.NewReplacer(`\`, `\\`, ":", `\:`)
+	return r.Replace(host) + ":" + r.Replace(port) + ":*:" + r.Replace(user) + ":" + r.Replace(password)
+}
+
+// pgShellQuote quotes a word for a POSIX shell
+func pgShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// getPgRewindScript stops the service, rewinds the datadir as postgres with the credentials of a
+// temporary pgpass file and restarts the service. The service is restarted as well when pg_rewind
+// fails, the script exits with pgRewindStopped when it could not be started again.
+func getPgRewindScript(service string, rewindPath string, datadir string, source string, pgpass string) string {
+	rewind := pgShellQuote(rewindPath) + " --target-pgdata=" + pgShellQuote(datadir) + " --source-server=" + pgShellQuote(source) + " --write-recovery-conf"
+	return `set -e
+PGPASSFILE=$(mktemp)
+trap 'rm -f "$PGPASSFILE"' EXIT
+cat > "$PGPASSFILE" <<'REPMAN_PGPASS'
+` + pgpass + `
+REPMAN_PGPASS
+chown postgres "$PGPASSFILE"
+systemctl stop ` + pgShellQuote(service) + `
+if ! su postgres -c "PGPASSFILE=$PGPASSFILE "` + pgShellQuote(rewind) + `; then
+  echo "pg_rewind failed, starting the service on its previous timeline" >&2
+  systemctl start ` + pgShellQuote(service) + ` || exit ` + strconv.Itoa(pgRewindStopped) + `
+  exit 1
+fi
+systemctl start ` + pgShellQuote(service) + ` || exit ` + strconv.Itoa(pgRewindStopped) + `
+exit
+`
+}
+
+// JobBackupPgBasebackup streams a compressed tar base backup with its WAL into the server backup directory,
+// the previous backup is only replaced once the new one is complete
+func (server *ServerMonitor) JobBackupPgBasebackup() error {
+	dir := server.GetMyBackupDirectory() + "pg_basebackup"
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	cmd := exec.Command(server.ClusterGroup.GetPgBasebackupPath(), "--host="+misc.Unbracket(server.Host), "--port="+server.Port, "--username="+server.ClusterGroup.rplUser, "--pgdata="+tmp, "--format=tar", "--gzip", "--wal-method=stream", "--checkpoint=fast", "--no-password")
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+server.ClusterGroup.rplPass)
+	server.ClusterGroup.LogPrintf(LvlInfo, "Command: %s", cmd.String())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		os.RemoveAll(tmp)
+		server.ClusterGroup.LogPrintf(LvlErr, "pg_basebackup of %s failed: %s %s", server.URL, err, string(out))
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		server.ClusterGroup.LogPrintf(LvlErr, "pg_basebackup of %s could not remove previous backup: %s", server.URL, err)
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		server.ClusterGroup.LogPrintf(LvlErr, "pg_basebackup of %s could not rename %s: %s", server.URL, tmp, err)
+		return err
+	}
+	server.ClusterGroup.LogPrintf(LvlInfo, "pg_basebackup of %s complete in %s", server.URL, dir)
+	return nil
+}
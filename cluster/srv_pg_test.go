@@ -0,0 +1,151 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/signal18/replication-manager/config"
+)
+
+func TestPgPassLine(t *testing.T) {
+	if l := getPgPassLine("db1", "5432", "repman", `pa:ss\word`); l != `db1:5432:*:repman:pa\:ss\\word` {
+		t.Errorf("Unexpected pgpass line %s", l)
+	}
+}
+
+func TestPgRewindScript(t *testing.T) {
+	password := "s3cr'et"
+	script := getPgRewindScript("postgresql", "/usr/lib/postgresql/13/bin/pg_rewind", "/var/lib/postgresql/13/it's main", "host=db1 port=5432 user=repman dbname=postgres", getPgPassLine("db1", "5432", "repman", password))
+	var su string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(line, "if ! su postgres") {
+			su = strings.TrimSuffix(line, "; then")
+		}
+	}
+	if su == "" || strings.Contains(su, password) {
+		t.Fatalf("Expected the password outside of the command line %q", su)
+	}
+	if strings.Count(script, password) != 1 {
+		t.Errorf("Expected the password only in the pgpass file %q", script)
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("No shell")
+	}
+	// the arguments received by pg_rewind once both shells parsed the quoting
+	inner := su[strings.Index(su, `"PGPASSFILE=$PGPASSFILE "`)+len(`"PGPASSFILE=$PGPASSFILE "`):]
+	out, err := exec.Command("sh", "-c", `f() { for a; do printf '[%s]' "$a"; done; }; eval "f "`+inner).CombinedOutput()
+	if err != nil {
+		t.Fatal(err, string(out))
+	}
+	if string(out) != "[/usr/lib/postgresql/13/bin/pg_rewind][--target-pgdata=/var/lib/postgresql/13/it's main][--source-server=host=db1 port=5432 user=repman dbname=postgres][--write-recovery-conf]" {
+		t.Errorf("Unexpected pg_rewind arguments %s", out)
+	}
+}
+
+func TestPgRewindScriptRestart(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("No shell")
+	}
+	bin, err := ioutil.TempDir("", "repman-pg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	// systemctl logs its calls and fails to start when asked, su runs the command as the current user
+	ioutil.WriteFile(filepath.Join(bin, "systemctl"), []byte(`#!/bin/sh
+echo "$@" >> "$PG_SYSTEMCTL_LOG"
+[ "$1" != start ] || [ -z "$PG_START_FAIL" ]
+`), 0755)
+	ioutil.WriteFile(filepath.Join(bin, "su"), []byte("#!/bin/sh\nexec sh -c \"$3\"\n"), 0755)
+	ioutil.WriteFile(filepath.Join(bin, "chown"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(filepath.Join(bin, "pg_rewind"), []byte("#!/bin/sh\n[ -z \"$PG_REWIND_FAIL\" ]\n"), 0755)
+	script := getPgRewindScript("postgresql", filepath.Join(bin, "pg_rewind"), "/var/lib/postgresql/13/main", "host=db1", getPgPassLine("db1", "5432", "repman", "secret"))
+	log := filepath.Join(bin, "systemctl.log")
+
+	for _, c := range []struct {
+		env    []string
+		status int
+	}{
+		{nil, 0},
+		{[]string{"PG_REWIND_FAIL=1"}, 1},
+		{[]string{"PG_REWIND_FAIL=1", "PG_START_FAIL=1"}, pgRewindStopped},
+		{[]string{"PG_START_FAIL=1"}, pgRewindStopped},
+	} {
+		os.Remove(log)
+		cmd := exec.Command("sh")
+		cmd.Stdin = strings.NewReader(script)
+		cmd.Env = append(os.Environ(), append(c.env, "PATH="+bin+":"+os.Getenv("PATH"), "PG_SYSTEMCTL_LOG="+log)...)
+		out, err := cmd.CombinedOutput()
+		status := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			status = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if status != c.status {
+			t.Errorf("Expected exit status %d with %v got %d %s", c.status, c.env, status, out)
+		}
+		if b, _ := ioutil.ReadFile(log); string(b) != "stop postgresql\nstart postgresql\n" {
+			t.Errorf("Expected the service stopped then started with %v got %q", c.env, b)
+		}
+	}
+}
+
+func TestJobBackupPgBasebackup(t *testing.T) {
+	bin, err := ioutil.TempDir("", "repman-pg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	// a pg_basebackup writing a partial backup before failing when asked
+	fake := filepath.Join(bin, "pg_basebackup")
+	ioutil.WriteFile(fake, []byte(`#!/bin/sh
+for a; do case $a in --pgdata=*) dir=${a#--pgdata=};; esac; done
+mkdir -p "$dir"
+echo "$PG_BACKUP_CONTENT" > "$dir/base.tar.gz"
+[ -z "$PG_BACKUP_FAIL" ]
+`), 0755)
+	sc := newSimCluster(t, "mariadb", 1, func(conf *config.Config) {
+		conf.BackupPgBasebackupPath = fake
+	})
+	server := sc.getServer(sc.topo.Servers[0])
+	dir := server.GetMyBackupDirectory() + "pg_basebackup"
+
+	os.Setenv("PG_BACKUP_CONTENT", "first")
+	defer os.Unsetenv("PG_BACKUP_CONTENT")
+	if err := server.JobBackupPgBasebackup(); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PG_BACKUP_CONTENT", "second")
+	os.Setenv("PG_BACKUP_FAIL", "1")
+	defer os.Unsetenv("PG_BACKUP_FAIL")
+	if err := server.JobBackupPgBasebackup(); err == nil {
+		t.Fatal("Expected the backup to fail")
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "base.tar.gz")); string(b) != "first\n" {
+		t.Errorf("Expected the previous backup kept after a failure got %q", b)
+	}
+	if _, err := os.Stat(dir + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial backup removed %v", err)
+	}
+	os.Unsetenv("PG_BACKUP_FAIL")
+	if err := server.JobBackupPgBasebackup(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "base.tar.gz")); string(b) != "second\n" {
+		t.Errorf("Expected the new backup got %q", b)
+	}
+}
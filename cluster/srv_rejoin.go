@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/hooks"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/state"
 )
@@ -46,28 +47,51 @@ func (server *ServerMonitor) RejoinMaster() error {
 	if server.ClusterGroup.Conf.LogLevel > 2 {
 		server.ClusterGroup.LogPrintf("INFO", "Trying to rejoin restarted standalone server %s", server.URL)
 	}
+	if server.IsPgStream() && server.ClusterGroup.master != nil && server.URL != server.ClusterGroup.master.URL {
+		if err := server.fireRejoinHook(hooks.EventRejoinStart, nil); err != nil {
+			server.ClusterGroup.rejoinCond.Send <- true
+			return err
+		}
+		err := server.RejoinPgStream()
+		server.ClusterGroup.rejoinCond.Send <- true
+		server.fireRejoinHook(hooks.EventRejoinEnd, err)
+		return err
+	}
+	if server.ClusterGroup.GetTopology() == topoGroupReplication {
+		// distributed recovery brings a member back when group_replication is started on it
+		server.ClusterGroup.LogPrintf(LvlInfo, "Server %s need START GROUP_REPLICATION to rejoin the group", server.URL)
+		server.ClusterGroup.rejoinCond.Send <- true
+		return nil
+	}
 	server.ClusterGroup.canFlashBack = true
 	if server.ClusterGroup.master != nil {
 		if server.URL != server.ClusterGroup.master.URL {
+			if err := server.fireRejoinHook(hooks.EventRejoinStart, nil); err != nil {
+				server.ClusterGroup.rejoinCond.Send <- true
+				return err
+			}
+			var rejoinErr error
+			defer func() { server.fireRejoinHook(hooks.EventRejoinEnd, rejoinErr) }()
 			server.ClusterGroup.SetState("WARN0022", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["WARN0022"], server.URL, server.ClusterGroup.master.URL), ErrFrom: "REJOIN"})
 			crash := server.ClusterGroup.getCrashFromJoiner(server.URL)
 			if crash == nil {
 				server.ClusterGroup.SetState("ERR00066", state.State{ErrType: "ERROR", ErrDesc: fmt.Sprintf(clusterError["ERR00066"], server.URL, server.ClusterGroup.master.URL), ErrFrom: "REJOIN"})
 				if server.ClusterGroup.oldMaster != nil {
 					if server.ClusterGroup.oldMaster.URL == server.URL {
-						server.RejoinMasterSST()
+						rejoinErr = server.RejoinMasterSST()
 						server.ClusterGroup.rejoinCond.Send <- true
 						return nil
 					}
 				}
 				if server.ClusterGroup.Conf.Autoseed {
-					server.ReseedMasterSST()
+					rejoinErr = server.ReseedMasterSST()
 					server.ClusterGroup.rejoinCond.Send <- true
 					return nil
 				} else {
 					server.ClusterGroup.rejoinCond.Send <- true
 					server.ClusterGroup.LogPrintf("INFO", "No auto seeding %s", server.URL)
-					return errors.New("No Autoseed")
+					rejoinErr = errors.New("No Autoseed")
+					return rejoinErr
 				}
 			}
 			if server.ClusterGroup.Conf.AutorejoinBackupBinlog == true {
@@ -77,8 +101,8 @@ func (server *ServerMonitor) RejoinMaster() error {
 			err := server.rejoinMasterIncremental(crash)
 			if err != nil {
 				server.ClusterGroup.LogPrintf("ERROR", "Failed to autojoin incremental to master %s", server.URL)
-				err := server.RejoinMasterSST()
-				if err != nil {
+				rejoinErr = server.RejoinMasterSST()
+				if rejoinErr != nil {
 					server.ClusterGroup.LogPrintf("ERROR", "State transfer rejoin failed")
 				}
 			}
@@ -66,6 +66,9 @@ func (server *ServerMonitor) SetPreferedBackup(pref bool) {
 
 func (server *ServerMonitor) SetReadOnly() (string, error) {
 	logs := ""
+	if server.DBVersion.IsPPostgreSQL() {
+		return dbhelper.SetPGReadOnly(server.Conn, true)
+	}
 	if !server.IsReadOnly() {
 		logs, err := dbhelper.SetReadOnly(server.Conn, true)
 		if err != nil {
@@ -98,6 +101,14 @@ func (server *ServerMonitor) SetReadWrite() error {
 		server.ClusterGroup.LogPrintf(LvlErr, "Cancel ReadWrite on %s caused by arbitration failed ", server.URL)
 		return errors.New("Arbitration is Failed")
 	}
+	if server.DBVersion.IsPPostgreSQL() {
+		if server.ReadOnly == "ON" {
+			logs, err := dbhelper.SetPGReadOnly(server.Conn, false)
+			server.ClusterGroup.LogSQL(logs, err, server.URL, "Rejoin", LvlErr, "Failed Set Read Write on %s : %s", server.URL, err)
+			return err
+		}
+		return nil
+	}
 	if server.IsReadOnly() {
 		logs, err := dbhelper.SetReadOnly(server.Conn, false)
 		server.ClusterGroup.LogSQL(logs, err, server.URL, "Rejoin", LvlErr, "Failed Set Read Write on %s : %s", server.URL, err)
@@ -328,3 +339,11 @@ func (server *ServerMonitor) SetReprovCookie() {
 	}
 	newFile.Close()
 }
+
+// SetSSTJob keeps the job and the token presented by SSTRunSender to the job
+func (server *ServerMonitor) SetSSTJob(task string, token string) {
+	server.sstMutex.Lock()
+	server.sstTask = task
+	server.sstToken = token
+	server.sstMutex.Unlock()
+}
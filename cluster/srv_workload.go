@@ -0,0 +1,427 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/s18log"
+	"github.com/signal18/replication-manager/utils/workload"
+)
+
+// WorkloadCapture describes the queries of a server recorded during a time window
+type WorkloadCapture struct {
+	Id        string    `json:"id"`
+	Server    string    `json:"server"`
+	Version   string    `json:"version"`
+	Source    string    `json:"source"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Events    int64     `json:"events"`
+	Truncated int64     `json:"truncated"`
+	Running   bool      `json:"running"`
+}
+
+// WorkloadReplay is the comparison of a capture replayed on a server
+type WorkloadReplay struct {
+	Capture  string           `json:"capture"`
+	Server   string           `json:"server"`
+	Version  string           `json:"version"`
+	Speed    float64          `json:"speed"`
+	ReadOnly bool             `json:"readOnly"`
+	Report   *workload.Report `json:"report"`
+}
+
+type workloadCapture struct {
+	sync.Mutex
+	info     WorkloadCapture
+	file     *os.File
+	writer   *workload.Writer
+	stop     chan bool
+	stopOnce sync.Once
+	// settings of the server restored at the end of the capture
+	restoreSlowQuery bool
+	restorePFS       bool
+}
+
+func (cluster *Cluster) GetWorkloadDir() string {
+	return cluster.Conf.WorkingDir + "/" + cluster.Name + "/workload"
+}
+
+func (cluster *Cluster) getWorkloadFile(id string, suffix string) string {
+	return cluster.GetWorkloadDir() + "/" + filepath.Base(id) + suffix
+}
+
+// StartWorkloadCapture records the queries of the server from the configured source
+// for duration
+func (server *ServerMonitor) StartWorkloadCapture(duration time.Duration) (WorkloadCapture, error) {
+	cluster := server.ClusterGroup
+	server.workloadMutex.Lock()
+	defer server.workloadMutex.Unlock()
+	if server.workloadCapture != nil {
+		return server.workloadCapture.info, errors.New("Workload capture already running")
+	}
+	if server.IsDown() {
+		return WorkloadCapture{}, errors.New("Server is down")
+	}
+	if duration <= 0 {
+		return WorkloadCapture{}, errors.New("No duration for workload capture")
+	}
+	source := cluster.Conf.WorkloadCaptureSource
+	switch source {
+	case workload.ConstSourcePFS:
+		if !server.HavePFS {
+			return WorkloadCapture{}, errors.New("Workload capture from performance schema disabled on server")
+		}
+	case workload.ConstSourceSlowLog:
+	default:
+		return WorkloadCapture{}, errors.New("Unknown workload capture source " + source)
+	}
+	if err := os.MkdirAll(cluster.GetWorkloadDir(), os.ModePerm); err != nil {
+		return WorkloadCapture{}, err
+	}
+	cluster.PurgeWorkloadCaptures(cluster.Conf.WorkloadCaptureKeep - 1)
+
+	now := time.Now()
+	wc := &workloadCapture{
+		info: WorkloadCapture{
+			Id:      server.Id + "_" + now.Format("20060102150405"),
+			Server:  server.URL,
+			Version: server.Variables["VERSION"],
+			Source:  source,
+			Start:   now,
+			Running: true,
+		},
+		stop: make(chan bool),
+	}
+	var err error
+	wc.file, err = os.Create(cluster.getWorkloadFile(wc.info.Id, ".events.gz"))
+	if err != nil {
+		return WorkloadCapture{}, err
+	}
+	wc.writer = workload.NewWriter(wc.file)
+	cluster.saveWorkloadCapture(wc.info)
+
+	if source == workload.ConstSourceSlowLog {
+		if !server.SlowQueryCapture {
+			server.SwitchSlowQueryCapture()
+			wc.restoreSlowQuery = true
+		}
+	} else if !server.HavePFSSlowQueryLog {
+		server.ExecQueryNoBinLog("update performance_schema.setup_consumers set ENABLED='YES' WHERE NAME IN('events_statements_history_long','events_stages_history')")
+		wc.restorePFS = true
+	}
+	server.workloadCapture = wc
+	cluster.LogPrintf(LvlInfo, "Workload capture %s of %s from %s for %s", wc.info.Id, server.URL, source, duration)
+	go server.workloadCaptureLoop(wc, duration)
+	return wc.info, nil
+}
+
+func (server *ServerMonitor) StopWorkloadCapture() error {
+	server.workloadMutex.Lock()
+	wc := server.workloadCapture
+	server.workloadMutex.Unlock()
+	if wc == nil {
+		return errors.New("No workload capture running")
+	}
+	wc.stopCapture()
+	return nil
+}
+
+func (wc *workloadCapture) stopCapture() {
+	wc.stopOnce.Do(func() { close(wc.stop) })
+}
+
+func (wc *workloadCapture) add(e *workload.Event, max int) {
+	wc.Lock()
+	defer wc.Unlock()
+	if !wc.info.Running {
+		return
+	}
+	if err := wc.writer.Write(e); err != nil {
+		return
+	}
+	wc.info.Events++
+	if e.Truncated {
+		wc.info.Truncated++
+	}
+	if max > 0 && wc.info.Events >= int64(max) {
+		wc.stopCapture()
+	}
+}
+
+// addWorkloadSlowMessage records a query of the slow log watcher in the capture
+func (server *ServerMonitor) addWorkloadSlowMessage(m *s18log.SlowMessage) {
+	server.workloadMutex.Lock()
+	wc := server.workloadCapture
+	server.workloadMutex.Unlock()
+	if wc == nil || wc.info.Source != workload.ConstSourceSlowLog {
+		return
+	}
+	if e, ok := workload.FromSlowMessage(m, wc.info.Start, time.Now()); ok {
+		wc.add(e, server.ClusterGroup.Conf.WorkloadCaptureMaxEvents)
+	}
+}
+
+func (server *ServerMonitor) workloadCaptureLoop(wc *workloadCapture, duration time.Duration) {
+	cluster := server.ClusterGroup
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	if wc.info.Source == workload.ConstSourcePFS {
+		poll := time.Duration(cluster.Conf.WorkloadCapturePoll) * time.Millisecond
+		if poll <= 0 {
+			poll = 100 * time.Millisecond
+		}
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		// offsets are relative to the timer of the server at the start
+		var base uint64
+		server.Conn.Get(&base, "SELECT COALESCE(MAX(TIMER_END),0) FROM performance_schema.events_statements_history_long")
+		end := base
+		maxText, err := strconv.Atoi(server.Variables["PERFORMANCE_SCHEMA_MAX_SQL_TEXT_LENGTH"])
+		if err != nil || maxText <= 0 {
+			maxText = 1024
+		}
+	loop:
+		for {
+			select {
+			case <-timer.C:
+				break loop
+			case <-wc.stop:
+				break loop
+			case <-ticker.C:
+				events, logs, err := dbhelper.GetPFSStatementsHistory(server.Conn, end, server.User)
+				cluster.LogSQL(logs, err, server.URL, "WorkloadCapture", LvlDbg, "Could not get statements history %s %s", server.URL, err)
+				for _, s := range events {
+					if base == 0 {
+						base = s.Timer_start
+					}
+					e := &workload.Event{
+						Session:   strconv.FormatUint(s.Thread_id, 10),
+						Db:        s.Schema_name,
+						Query:     s.Sql_text,
+						Digest:    workload.Digest(s.Sql_text),
+						Duration:  time.Duration(s.Timer_wait / 1000),
+						Errno:     s.Errno,
+						Truncated: len(s.Sql_text) >= maxText,
+					}
+					if s.Timer_start > base {
+						e.Offset = time.Duration((s.Timer_start - base) / 1000)
+					}
+					wc.add(e, cluster.Conf.WorkloadCaptureMaxEvents)
+					end = s.Timer_end
+				}
+			}
+		}
+	} else {
+		select {
+		case <-timer.C:
+		case <-wc.stop:
+		}
+	}
+	server.finishWorkloadCapture(wc)
+}
+
+func (server *ServerMonitor) finishWorkloadCapture(wc *workloadCapture) {
+	cluster := server.ClusterGroup
+	wc.Lock()
+	wc.info.Running = false
+	wc.info.End = time.Now()
+	err := wc.writer.Close()
+	if cerr := wc.file.Close(); err == nil {
+		err = cerr
+	}
+	info := wc.info
+	wc.Unlock()
+	if err != nil {
+		cluster.LogPrintf(LvlErr, "Workload capture %s of %s failed: %s", info.Id, server.URL, err)
+	}
+	cluster.saveWorkloadCapture(info)
+
+	if wc.restoreSlowQuery && server.SlowQueryCapture {
+		server.SwitchSlowQueryCapture()
+	}
+	if wc.restorePFS {
+		server.ExecQueryNoBinLog("update performance_schema.setup_consumers set ENABLED='NO' WHERE NAME IN('events_statements_history_long','events_stages_history')")
+	}
+	server.workloadMutex.Lock()
+	server.workloadCapture = nil
+	server.workloadMutex.Unlock()
+	cluster.LogPrintf(LvlInfo, "Workload capture %s of %s ended with %d queries, %d truncated", info.Id, server.URL, info.Events, info.Truncated)
+}
+
+func (cluster *Cluster) saveWorkloadCapture(info WorkloadCapture) {
+	data, _ := json.MarshalIndent(info, "", "\t")
+	if err := ioutil.WriteFile(cluster.getWorkloadFile(info.Id, ".json"), data, 0644); err != nil {
+		cluster.LogPrintf(LvlErr, "Could not save workload capture %s: %s", info.Id, err)
+	}
+}
+
+// GetWorkloadCaptures returns the captures of the cluster, the newest first
+func (cluster *Cluster) GetWorkloadCaptures() []WorkloadCapture {
+	captures := []WorkloadCapture{}
+	running := make(map[string]WorkloadCapture)
+	for _, server := range cluster.Servers {
+		server.workloadMutex.Lock()
+		if wc := server.workloadCapture; wc != nil {
+			wc.Lock()
+			running[wc.info.Id] = wc.info
+			wc.Unlock()
+		}
+		server.workloadMutex.Unlock()
+	}
+	files, _ := filepath.Glob(cluster.GetWorkloadDir() + "/*.json")
+	for _, file := range files {
+		if strings.Contains(filepath.Base(file), "_replay_") {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var info WorkloadCapture
+		if json.Unmarshal(data, &info) != nil {
+			continue
+		}
+		if r, ok := running[info.Id]; ok {
+			info = r
+		} else {
+			// stopped with replication-manager
+			info.Running = false
+		}
+		captures = append(captures, info)
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].Start.After(captures[j].Start) })
+	return captures
+}
+
+func (cluster *Cluster) GetWorkloadCapture(id string) (WorkloadCapture, error) {
+	for _, c := range cluster.GetWorkloadCaptures() {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+	return WorkloadCapture{}, errors.New("No workload capture " + id)
+}
+
+// PurgeWorkloadCaptures deletes the captures and their replays out of the keep newest
+func (cluster *Cluster) PurgeWorkloadCaptures(keep int) {
+	if keep < 0 {
+		keep = 0
+	}
+	captures := cluster.GetWorkloadCaptures()
+	for i, c := range captures {
+		if i < keep || c.Running {
+			continue
+		}
+		cluster.LogPrintf(LvlInfo, "Purging workload capture %s", c.Id)
+		replays, _ := filepath.Glob(cluster.getWorkloadFile(c.Id, "_replay_*.json"))
+		for _, file := range append(replays, cluster.getWorkloadFile(c.Id, ".json"), cluster.getWorkloadFile(c.Id, ".events.gz")) {
+			os.Remove(file)
+		}
+	}
+}
+
+// ReplayWorkload replays a capture on target in background and saves the comparison
+// of the latencies by digest
+func (cluster *Cluster) ReplayWorkload(id string, target *ServerMonitor) error {
+	capture, err := cluster.GetWorkloadCapture(id)
+	if err != nil {
+		return err
+	}
+	if capture.Running {
+		return errors.New("Workload capture " + id + " still running")
+	}
+	if target.IsDown() {
+		return errors.New("Server is down")
+	}
+	if target.IsMaster() {
+		return errors.New("Workload replay on the master is refused")
+	}
+	// the writes of a replay must never reach the cluster, they are not binlogged and only
+	// replayed on a server detached from the replication, a read only replay is enforced
+	// by the server as well
+	var init []string
+	if !cluster.Conf.WorkloadReplayReadOnly {
+		if target.IsSlave || len(target.Replications) > 0 || target.HaveWsrep {
+			return errors.New("Workload replay with writes is refused on a replicating server, detach it or enable workload-replay-read-only")
+		}
+		init = append(init, "SET SESSION sql_log_bin=0")
+	} else {
+		init = append(init, "SET SESSION TRANSACTION READ ONLY")
+	}
+	go func() {
+		f, err := os.Open(cluster.getWorkloadFile(id, ".events.gz"))
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Workload replay %s on %s failed: %s", id, target.URL, err)
+			return
+		}
+		defer f.Close()
+		r, err := workload.NewReader(f)
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Workload replay %s on %s failed: %s", id, target.URL, err)
+			return
+		}
+		conn, err := target.GetNewDBConn()
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Workload replay %s on %s can't connect: %s", id, target.URL, err)
+			return
+		}
+		defer conn.Close()
+		replay := WorkloadReplay{
+			Capture:  id,
+			Server:   target.URL,
+			Version:  target.Variables["VERSION"],
+			Speed:    cluster.Conf.WorkloadReplaySpeed,
+			ReadOnly: cluster.Conf.WorkloadReplayReadOnly,
+		}
+		cluster.LogPrintf(LvlInfo, "Workload replay %s of %s on %s with %d queries", id, capture.Server, target.URL, capture.Events)
+		replay.Report, err = workload.Replay(context.Background(), r, workload.NewSQLTarget(conn.DB, init...), workload.Options{Speed: replay.Speed, ReadOnly: replay.ReadOnly})
+		if err != nil {
+			cluster.LogPrintf(LvlErr, "Workload replay %s on %s stopped: %s", id, target.URL, err)
+		}
+		data, _ := json.MarshalIndent(replay, "", "\t")
+		file := cluster.getWorkloadFile(id, "_replay_"+target.Id+"_"+replay.Report.Start.Format("20060102150405")+".json")
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			cluster.LogPrintf(LvlErr, "Could not save workload replay %s: %s", id, err)
+		}
+		cluster.LogPrintf(LvlInfo, "Workload replay %s on %s ended with %d queries, %d errors, %d skipped, max lag %.0fms", id, target.URL, replay.Report.Replayed, replay.Report.Errors, replay.Report.Skipped, replay.Report.MaxLag)
+	}()
+	return nil
+}
+
+// GetWorkloadReplays returns the replays of a capture
+func (cluster *Cluster) GetWorkloadReplays(id string) []WorkloadReplay {
+	replays := []WorkloadReplay{}
+	files, _ := filepath.Glob(cluster.getWorkloadFile(id, "_replay_*.json"))
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var replay WorkloadReplay
+		if json.Unmarshal(data, &replay) == nil {
+			replays = append(replays, replay)
+		}
+	}
+	return replays
+}
@@ -0,0 +1,115 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+// GetWsrepLastCommitted returns the seqno of the last transaction committed by the node
+func (server *ServerMonitor) GetWsrepLastCommitted() int64 {
+	seqno, err := strconv.ParseInt(server.Status["WSREP_LAST_COMMITTED"], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return seqno
+}
+
+// GetWsrepNodeName returns the name used by the other nodes in wsrep_sst_donor
+func (server *ServerMonitor) GetWsrepNodeName() string {
+	return server.Variables["WSREP_NODE_NAME"]
+}
+
+// IsWsrepDesync returns true when the node was removed from flow control by wsrep_desync
+func (server *ServerMonitor) IsWsrepDesync() bool {
+	return server.Variables["WSREP_DESYNC"] == "ON"
+}
+
+// WsrepBootstrapPrimary turns the non primary component of the node into the primary component
+func (server *ServerMonitor) WsrepBootstrapPrimary() error {
+	if server.Conn == nil {
+		return errors.New("No database connection pool")
+	}
+	logs, err := dbhelper.SetWsrepBootstrapPrimary(server.Conn)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Topology", LvlErr, "Could not bootstrap primary component on %s %s", server.URL, err)
+	return err
+}
+
+// SetWsrepSSTDonor pins the donors of the node for the next state transfer
+func (server *ServerMonitor) SetWsrepSSTDonor(donors string) error {
+	logs, err := dbhelper.SetWsrepSSTDonor(server.Conn, donors)
+	server.ClusterGroup.LogSQL(logs, err, server.URL, "Topology", LvlErr, "Could not set SST donor on %s %s", server.URL, err)
+	return err
+}
+
+// JobGetGrastate reads grastate.dat over ssh, after a crash the seqno is recovered from InnoDB with galera_recovery.
+// The service must be stopped as a running node would not have a meaningful saved state
+func (server *ServerMonitor) JobGetGrastate() (dbhelper.Grastate, error) {
+	var gs dbhelper.Grastate
+	cluster := server.ClusterGroup
+	client, err := cluster.OnPremiseConnect(server)
+	if err != nil {
+		return gs, err
+	}
+	defer client.Close()
+	out, _ := client.Cmd("systemctl is-active " + cluster.Conf.MultiMasterWsrepServiceName).SmartOutput()
+	if strings.TrimSpace(string(out)) == "active" {
+		return gs, errors.New("Service " + cluster.Conf.MultiMasterWsrepServiceName + " is running on " + server.Host)
+	}
+	out, err = client.Cmd("cat " + cluster.Conf.MultiMasterWsrepDatadir + "/grastate.dat").SmartOutput()
+	if err != nil {
+		return gs, err
+	}
+	gs, err = dbhelper.ParseGrastate(string(out))
+	if err != nil {
+		return gs, err
+	}
+	if gs.Seqno == -1 {
+		cluster.LogPrintf(LvlInfo, "Recovering Galera position of %s with galera_recovery", server.URL)
+		out, err = client.Cmd("galera_recovery").SmartOutput()
+		if err != nil {
+			return gs, err
+		}
+		_, gs.Seqno, err = dbhelper.ParseWsrepRecoveredPosition(string(out))
+	}
+	return gs, err
+}
+
+// JobBootstrapWsrep marks the node safe to bootstrap and starts it as a new cluster
+func (server *ServerMonitor) JobBootstrapWsrep() error {
+	cluster := server.ClusterGroup
+	client, err := cluster.OnPremiseConnect(server)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	cmd := "sed -i 's/^safe_to_bootstrap:.*/safe_to_bootstrap: 1/' " + cluster.Conf.MultiMasterWsrepDatadir + "/grastate.dat && galera_new_cluster"
+	cluster.LogPrintf(LvlInfo, "Bootstrap Galera on %s: %s", server.URL, cmd)
+	out, err := client.Cmd(cmd).SmartOutput()
+	cluster.LogPrintf(LvlInfo, "Bootstrap Galera on %s: %s", server.URL, string(out))
+	return err
+}
+
+// JobStartWsrep starts the service of a node that joins the cluster with IST or SST
+func (server *ServerMonitor) JobStartWsrep() error {
+	cluster := server.ClusterGroup
+	client, err := cluster.OnPremiseConnect(server)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	out, err := client.Cmd("systemctl start " + cluster.Conf.MultiMasterWsrepServiceName).SmartOutput()
+	cluster.LogPrintf(LvlInfo, "Start Galera node %s: %s", server.URL, string(out))
+	return err
+}
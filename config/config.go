@@ -77,13 +77,14 @@ type Config struct {
 	LogRotateMaxBackup                        int    `mapstructure:"log-rotate-max-backup" toml:"log-rotate-max-backup" json:"logRotateMaxBackup"`
 	LogRotateMaxAge                           int    `mapstructure:"log-rotate-max-age" toml:"log-rotate-max-age" json:"logRotateMaxAge"`
 	LogSST                                    bool   `mapstructure:"log-sst" toml:"log-sst" json:"logSst"` // internal replication-manager sst
+	SSTBandwidth                              int    `mapstructure:"sst-bandwidth" toml:"sst-bandwidth" json:"sstBandwidth"`
 	LogHeartbeat                              bool   `mapstructure:"log-heartbeat" toml:"log-heartbeat" json:"logHeartbeat"`
 	LogSQLInMonitoring                        bool   `mapstructure:"log-sql-in-monitoring"  toml:"log-sql-in-monitoring" json:"logSqlInMonitoring"`
 	LogFailedElection                         bool   `mapstructure:"log-failed-election"  toml:"log-failed-election" json:"logFailedElection"`
 	User                                      string `mapstructure:"db-servers-credential" toml:"db-servers-credential" json:"dbServersCredential"`
 	Hosts                                     string `mapstructure:"db-servers-hosts" toml:"db-servers-hosts" json:"dbServersHosts"`
-	HostsDelayed                              string `mapstructure:"replication-delayed-hosts", toml:"replication-delayed-hosts" json:"replicationDelayedHosts"`
-	HostsDelayedTime                          int    `mapstructure:"replication-delayed-time", toml:"replication-delayed-time" json:"replicationDelayedTime"`
+	HostsDelayed                              string `mapstructure:"replication-delayed-hosts" toml:"replication-delayed-hosts" json:"replicationDelayedHosts"`
+	HostsDelayedTime                          int    `mapstructure:"replication-delayed-time" toml:"replication-delayed-time" json:"replicationDelayedTime"`
 	DBServersTLSUseGeneratedCertificate       bool   `mapstructure:"db-servers-tls-use-generated-cert" toml:"db-servers-tls-use-generated-cert" json:"dbServersUseGeneratedCert"`
 	HostsTLSCA                                string `mapstructure:"db-servers-tls-ca-cert" toml:"db-servers-tls-ca-cert" json:"dbServersTlsCaCert"`
 	HostsTLSKEY                               string `mapstructure:"db-servers-tls-client-key" toml:"db-servers-tls-client-key" json:"dbServersTlsClientKey"`
@@ -108,10 +109,19 @@ type Config struct {
 	MultiMasterRing                           bool   `mapstructure:"replication-multi-master-ring" toml:"replication-multi-master-ring" json:"replicationMultiMasterRing"`
 	MultiMasterWsrep                          bool   `mapstructure:"replication-multi-master-wsrep" toml:"replication-multi-master-wsrep" json:"replicationMultiMasterWsrep"`
 	MultiMasterWsrepSSTMethod                 string `mapstructure:"replication-multi-master-wsrep-sst-method" toml:"replication-multi-master-wsrep-sst-method" json:"replicationMultiMasterWsrepSSTMethod"`
+	MultiMasterWsrepAutoBootstrap             bool   `mapstructure:"replication-multi-master-wsrep-autobootstrap" toml:"replication-multi-master-wsrep-autobootstrap" json:"replicationMultiMasterWsrepAutoBootstrap"`
+	MultiMasterWsrepDonorHosts                string `mapstructure:"replication-multi-master-wsrep-donor-hosts" toml:"replication-multi-master-wsrep-donor-hosts" json:"replicationMultiMasterWsrepDonorHosts"`
+	MultiMasterWsrepDatadir                   string `mapstructure:"replication-multi-master-wsrep-datadir" toml:"replication-multi-master-wsrep-datadir" json:"replicationMultiMasterWsrepDatadir"`
+	MultiMasterWsrepServiceName               string `mapstructure:"replication-multi-master-wsrep-service-name" toml:"replication-multi-master-wsrep-service-name" json:"replicationMultiMasterWsrepServiceName"`
+	MultiMasterGrouprep                       bool   `mapstructure:"replication-multi-master-grouprep" toml:"replication-multi-master-grouprep" json:"replicationMultiMasterGrouprep"`
 	MultiMaster                               bool   `mapstructure:"replication-multi-master" toml:"replication-multi-master" json:"replicationMultiMaster"`
 	MultiTierSlave                            bool   `mapstructure:"replication-multi-tier-slave" toml:"replication-multi-tier-slave" json:"replicationMultiTierSlave"`
 	MasterSlavePgStream                       bool   `mapstructure:"replication-master-slave-pg-stream" toml:"replication-master-slave-pg-stream" json:"replicationMasterSlavePgStream"`
 	MasterSlavePgLogical                      bool   `mapstructure:"replication-master-slave-pg-logical" toml:"replication-master-slave-pg-logical" json:"replicationMasterSlavePgLogical"`
+	PgStreamSlots                             bool   `mapstructure:"replication-pg-stream-slots" toml:"replication-pg-stream-slots" json:"replicationPgStreamSlots"`
+	PgDatadir                                 string `mapstructure:"replication-pg-datadir" toml:"replication-pg-datadir" json:"replicationPgDatadir"`
+	PgServiceName                             string `mapstructure:"replication-pg-service-name" toml:"replication-pg-service-name" json:"replicationPgServiceName"`
+	PgRewindPath                              string `mapstructure:"replication-pg-rewind-path" toml:"replication-pg-rewind-path" json:"replicationPgRewindPath"`
 	ReplicationNoRelay                        bool   `mapstructure:"replication-master-slave-never-relay" toml:"replication-master-slave-never-relay" json:"replicationMasterSlaveNeverRelay"`
 	ReplicationRestartOnSQLErrorMatch         string `mapstructure:"replication-restart-on-sqlerror-match" toml:"replication-restart-on-sqlerror-match" json:"eeplicationRestartOnSqlLErrorMatch"`
 	SwitchWaitKill                            int64  `mapstructure:"switchover-wait-kill" toml:"switchover-wait-kill" json:"switchoverWaitKill"`
@@ -151,6 +161,7 @@ type Config struct {
 	AutorejoinFlashback                       bool   `mapstructure:"autorejoin-flashback" toml:"autorejoin-flashback" json:"autorejoinFlashback"`
 	AutorejoinMysqldump                       bool   `mapstructure:"autorejoin-mysqldump" toml:"autorejoin-mysqldump" json:"autorejoinMysqldump"`
 	AutorejoinZFSFlashback                    bool   `mapstructure:"autorejoin-zfs-flashback" toml:"autorejoin-zfs-flashback" json:"autorejoinZfsFlashback"`
+	AutorejoinPgRewind                        bool   `mapstructure:"autorejoin-pg-rewind" toml:"autorejoin-pg-rewind" json:"autorejoinPgRewind"`
 	AutorejoinPhysicalBackup                  bool   `mapstructure:"autorejoin-physical-backup" toml:"autorejoin-physical-backup" json:"autorejoinPhysicalBackup"`
 	AutorejoinLogicalBackup                   bool   `mapstructure:"autorejoin-logical-backup" toml:"autorejoin-logical-backup" json:"autorejoinLogicalBackup"`
 	RejoinScript                              string `mapstructure:"autorejoin-script" toml:"autorejoin-script" json:"autorejoinScript"`
@@ -191,6 +202,9 @@ type Config struct {
 	HttpBootstrapButton                       bool   `mapstructure:"http-bootstrap-button" toml:"http-bootstrap-button" json:"httpBootstrapButton"`
 	SessionLifeTime                           int    `mapstructure:"http-session-lifetime" toml:"http-session-lifetime" json:"httpSessionLifetime"`
 	HttpRefreshInterval                       int    `mapstructure:"http-refresh-interval" toml:"http-refresh-interval" json:"httpRefreshInterval"`
+	HttpStreamBuffer                          int    `mapstructure:"http-stream-buffer" toml:"http-stream-buffer" json:"httpStreamBuffer"`
+	HttpStreamKeepAlive                       int    `mapstructure:"http-stream-keepalive" toml:"http-stream-keepalive" json:"httpStreamKeepalive"`
+	HttpStreamAllowedOrigins                  string `mapstructure:"http-stream-allowed-origins" toml:"http-stream-allowed-origins" json:"httpStreamAllowedOrigins"`
 	Daemon                                    bool   `mapstructure:"daemon" toml:"-" json:"-"`
 	MailFrom                                  string `mapstructure:"mail-from" toml:"mail-from" json:"mailFrom"`
 	MailTo                                    string `mapstructure:"mail-to" toml:"mail-to" json:"mailTo"`
@@ -232,6 +246,12 @@ type Config struct {
 	MyproxyPort                               int    `mapstructure:"myproxy-port" toml:"myproxy-port" json:"myproxyPort"`
 	MyproxyUser                               string `mapstructure:"myproxy-user" toml:"myproxy-user" json:"myproxyUser"`
 	MyproxyPassword                           string `mapstructure:"myproxy-password" toml:"myproxy-password" json:"myproxyPassword"`
+	MyproxyMaxDelay                           int64  `mapstructure:"myproxy-max-delay" toml:"myproxy-max-delay" json:"myproxyMaxDelay"`
+	GobetweenOn                               bool   `mapstructure:"gobetween" toml:"gobetween" json:"gobetween"`
+	GobetweenBindIp                           string `mapstructure:"gobetween-ip-bind" toml:"gobetween-ip-bind" json:"gobetweenIpBind"`
+	GobetweenWritePort                        int    `mapstructure:"gobetween-write-port" toml:"gobetween-write-port" json:"gobetweenWritePort"`
+	GobetweenReadPort                         int    `mapstructure:"gobetween-read-port" toml:"gobetween-read-port" json:"gobetweenReadPort"`
+	GobetweenReadBalance                      string `mapstructure:"gobetween-read-balance" toml:"gobetween-read-balance" json:"gobetweenReadBalance"`
 	HaproxyOn                                 bool   `mapstructure:"haproxy" toml:"haproxy" json:"haproxy"`
 	HaproxyUser                               string `mapstructure:"haproxy-user" toml:"haproxy-user" json:"haproxylUser"`
 	HaproxyPassword                           string `mapstructure:"haproxy-password" toml:"haproxy-password" json:"haproxyPassword"`
@@ -419,6 +439,7 @@ type Config struct {
 	APIUsersACLDiscard                        string `mapstructure:"api-credentials-acl-discard" toml:"api-credentials-acl-discard" json:"apiCredentialsACLDiscard"`
 	APISecureConfig                           bool   `mapstructure:"api-credentials-secure-config" toml:"api-credentials-secure-config" json:"apiCredentialsSecureConfig"`
 	APIPort                                   string `mapstructure:"api-port" toml:"api-port" json:"apiPort"`
+	APIGrpcPort                               string `mapstructure:"api-grpc-port" toml:"api-grpc-port" json:"apiGrpcPort"`
 	APIBind                                   string `mapstructure:"api-bind" toml:"api-bind" json:"apiBind"`
 	APIHttpsBind                              bool   `mapstructure:"api-https-bind" toml:"api-secure" json:"apiHttpsBind"`
 	AlertScript                               string `mapstructure:"alert-script" toml:"alert-script" json:"alertScript"`
@@ -431,6 +452,9 @@ type Config struct {
 	SchedulerDatabaseOptimize                 bool   `mapstructure:"scheduler-db-servers-optimize" toml:"scheduler-db-servers-optimize" json:"schedulerDbServersOptimize"`
 	BackupLogicalCron                         string `mapstructure:"scheduler-db-servers-logical-backup-cron" toml:"scheduler-db-servers-logical-backup-cron" json:"schedulerDbServersLogicalBackupCron"`
 	BackupPhysicalCron                        string `mapstructure:"scheduler-db-servers-physical-backup-cron" toml:"scheduler-db-servers-physical-backup-cron" json:"schedulerDbServersPhysicalBackupCron"`
+	SchedulerBackupIncremental                bool   `mapstructure:"scheduler-db-servers-incremental-backup" toml:"scheduler-db-servers-incremental-backup" json:"schedulerDbServersIncrementalBackup"`
+	BackupIncrementalCron                     string `mapstructure:"scheduler-db-servers-incremental-backup-cron" toml:"scheduler-db-servers-incremental-backup-cron" json:"schedulerDbServersIncrementalBackupCron"`
+	BackupPhysicalChainsKeep                  int    `mapstructure:"backup-physical-chains-keep" toml:"backup-physical-chains-keep" json:"backupPhysicalChainsKeep"`
 	BackupDatabaseLogCron                     string `mapstructure:"scheduler-db-servers-logs-cron" toml:"scheduler-db-servers-logs-cron" json:"schedulerDbServersLogsCron"`
 	BackupDatabaseOptimizeCron                string `mapstructure:"scheduler-db-servers-optimize-cron" toml:"scheduler-db-servers-optimize-cron" json:"schedulerDbServersOptimizeCron"`
 	SchedulerDatabaseLogsTableRotate          bool   `mapstructure:"scheduler-db-servers-logs-table-rotate" toml:"scheduler-db-servers-logs-table-rotate" json:"schedulerDbServersLogsTableRotate"`
@@ -443,6 +467,18 @@ type Config struct {
 	SchedulerRollingReprovCron                string `mapstructure:"scheduler-rolling-reprov-cron" toml:"scheduler-rolling-reprov-cron" json:"schedulerRollingReprovCron"`
 	SchedulerJobsSSH                          bool   `mapstructure:"scheduler-jobs-ssh" toml:"scheduler-jobs-ssh" json:"schedulerJobsSsh"`
 	SchedulerJobsSSHCron                      string `mapstructure:"scheduler-jobs-ssh-cron" toml:"scheduler-jobs-ssh-cron" json:"schedulerJobsSshCron"`
+	SchedulerChaos                            bool   `mapstructure:"scheduler-chaos" toml:"scheduler-chaos" json:"schedulerChaos"`
+	SchedulerChaosCron                        string `mapstructure:"scheduler-chaos-cron" toml:"scheduler-chaos-cron" json:"schedulerChaosCron"`
+	ChaosFaults                               string `mapstructure:"chaos-faults" toml:"chaos-faults" json:"chaosFaults"`
+	ChaosFillDiskSize                         int    `mapstructure:"chaos-fill-disk-size" toml:"chaos-fill-disk-size" json:"chaosFillDiskSize"`
+	ChaosReportKeep                           int    `mapstructure:"chaos-report-keep" toml:"chaos-report-keep" json:"chaosReportKeep"`
+	SchedulerChecksum                         bool   `mapstructure:"scheduler-checksum" toml:"scheduler-checksum" json:"schedulerChecksum"`
+	SchedulerChecksumCron                     string `mapstructure:"scheduler-checksum-cron" toml:"scheduler-checksum-cron" json:"schedulerChecksumCron"`
+	ChecksumChunkSize                         int    `mapstructure:"checksum-chunk-size" toml:"checksum-chunk-size" json:"checksumChunkSize"`
+	ChecksumChunkSleep                        int    `mapstructure:"checksum-chunk-sleep" toml:"checksum-chunk-sleep" json:"checksumChunkSleep"`
+	ChecksumMaxDelay                          int    `mapstructure:"checksum-max-delay" toml:"checksum-max-delay" json:"checksumMaxDelay"`
+	ChecksumRepair                            bool   `mapstructure:"checksum-repair" toml:"checksum-repair" json:"checksumRepair"`
+	ChecksumHistoryKeep                       int    `mapstructure:"checksum-history-keep" toml:"checksum-history-keep" json:"checksumHistoryKeep"`
 	Backup                                    bool   `mapstructure:"backup" toml:"backup" json:"backup"`
 	BackupLogicalType                         string `mapstructure:"backup-logical-type" toml:"backup-logical-type" json:"backupLogicalType"`
 	BackupLogicalLoadThreads                  int    `mapstructure:"backup-logical-load-threads" toml:"backup-logical-load-threads" json:"backupLogicalLoadThreads"`
@@ -468,16 +504,73 @@ type Config struct {
 	BackupStreamingEndpoint                   string `mapstructure:"backup-streaming-endpoint" toml:"backup-streaming-endpoint" json:"backupStreamingEndpoint"`
 	BackupStreamingRegion                     string `mapstructure:"backup-streaming-region" toml:"backup-streaming-region" json:"backupStreamingRegion"`
 	BackupStreamingBucket                     string `mapstructure:"backup-streaming-bucket" toml:"backup-streaming-bucket" json:"backupStreamingBucket"`
+	BackupStreamingType                       string `mapstructure:"backup-streaming-type" toml:"backup-streaming-type" json:"backupStreamingType"`
+	BackupStreamingPrefix                     string `mapstructure:"backup-streaming-prefix" toml:"backup-streaming-prefix" json:"backupStreamingPrefix"`
+	BackupStreamingEncryption                 string `mapstructure:"backup-streaming-encryption" toml:"backup-streaming-encryption" json:"backupStreamingEncryption"`
+	BackupStreamingKmsKeyId                   string `mapstructure:"backup-streaming-kms-key-id" toml:"backup-streaming-kms-key-id" json:"backupStreamingKmsKeyId"`
+	BackupStreamingSseCKey                    string `mapstructure:"backup-streaming-sse-c-key" toml:"backup-streaming-sse-c-key" json:"-"`
+	BackupStreamingStorageClass               string `mapstructure:"backup-streaming-storage-class" toml:"backup-streaming-storage-class" json:"backupStreamingStorageClass"`
+	BackupStreamingPartSize                   int    `mapstructure:"backup-streaming-part-size" toml:"backup-streaming-part-size" json:"backupStreamingPartSize"`
+	BackupStreamingLifecycle                  bool   `mapstructure:"backup-streaming-lifecycle" toml:"backup-streaming-lifecycle" json:"backupStreamingLifecycle"`
+	BackupStreamingKeepDays                   int    `mapstructure:"backup-streaming-keep-days" toml:"backup-streaming-keep-days" json:"backupStreamingKeepDays"`
 	BackupMysqldumpPath                       string `mapstructure:"backup-mysqldump-path" toml:"backup-mysqldump-path" json:"backupMysqldumpPath"`
 	BackupMysqldumpOptions                    string `mapstructure:"backup-mysqldump-options" toml:"backup-mysqldump-options" json:"backupMysqldumpOptions"`
 	BackupMyDumperPath                        string `mapstructure:"backup-mydumper-path" toml:"backup-mydumper-path" json:"backupMydumperPath"`
 	BackupMyLoaderPath                        string `mapstructure:"backup-myloader-path" toml:"backup-myloader-path" json:"backupMyloaderPath"`
 	BackupMysqlbinlogPath                     string `mapstructure:"backup-mysqlbinlog-path" toml:"backup-mysqlbinlog-path" json:"backupMysqlbinlogPath"`
 	BackupMysqlclientPath                     string `mapstructure:"backup-mysqlclient-path" toml:"backup-mysqlclient-path" json:"backupMysqlclientgPath"`
+	BackupPgBasebackupPath                    string `mapstructure:"backup-pg-basebackup-path" toml:"backup-pg-basebackup-path" json:"backupPgBasebackupPath"`
 	BackupBinlogs                             bool   `mapstructure:"backup-binlogs" toml:"backup-binlogs" json:"backupBinlogs"`
 	BackupBinlogsKeep                         int    `mapstructure:"backup-binlogs-keep" toml:"backup-binlogs-keep" json:"backupBinlogsKeep"`
 	ClusterConfigPath                         string `mapstructure:"cluster-config-file" toml:"-" json:"-"`
 
+	// workload capture and replay
+	WorkloadCaptureSource    string  `mapstructure:"workload-capture-source" toml:"workload-capture-source" json:"workloadCaptureSource"`
+	WorkloadCapturePoll      int     `mapstructure:"workload-capture-poll" toml:"workload-capture-poll" json:"workloadCapturePoll"`
+	WorkloadCaptureMaxEvents int     `mapstructure:"workload-capture-max-events" toml:"workload-capture-max-events" json:"workloadCaptureMaxEvents"`
+	WorkloadCaptureKeep      int     `mapstructure:"workload-capture-keep" toml:"workload-capture-keep" json:"workloadCaptureKeep"`
+	WorkloadReplaySpeed      float64 `mapstructure:"workload-replay-speed" toml:"workload-replay-speed" json:"workloadReplaySpeed"`
+	WorkloadReplayReadOnly   bool    `mapstructure:"workload-replay-read-only" toml:"workload-replay-read-only" json:"workloadReplayReadOnly"`
+
+	// query digest history
+	MonitorDigestHistory                   bool    `mapstructure:"monitoring-digest-history" toml:"monitoring-digest-history" json:"monitoringDigestHistory"`
+	MonitorDigestHistoryInterval           int     `mapstructure:"monitoring-digest-history-interval" toml:"monitoring-digest-history-interval" json:"monitoringDigestHistoryInterval"`
+	MonitorDigestHistoryDigests            int     `mapstructure:"monitoring-digest-history-digests" toml:"monitoring-digest-history-digests" json:"monitoringDigestHistoryDigests"`
+	MonitorDigestHistoryKeep               int     `mapstructure:"monitoring-digest-history-keep" toml:"monitoring-digest-history-keep" json:"monitoringDigestHistoryKeep"`
+	MonitorDigestHistoryRegressionRatio    float64 `mapstructure:"monitoring-digest-history-regression-ratio" toml:"monitoring-digest-history-regression-ratio" json:"monitoringDigestHistoryRegressionRatio"`
+	MonitorDigestHistoryRegressionMinCount int     `mapstructure:"monitoring-digest-history-regression-min-count" toml:"monitoring-digest-history-regression-min-count" json:"monitoringDigestHistoryRegressionMinCount"`
+
+	// index advisor
+	IndexAdvisorDigests int `mapstructure:"index-advisor-digests" toml:"index-advisor-digests" json:"indexAdvisorDigests"`
+	IndexAdvisorMinRows int `mapstructure:"index-advisor-min-rows" toml:"index-advisor-min-rows" json:"indexAdvisorMinRows"`
+
+	// capacity forecasting
+	MonitorCapacity         bool `mapstructure:"monitoring-capacity" toml:"monitoring-capacity" json:"monitoringCapacity"`
+	MonitorCapacityInterval int  `mapstructure:"monitoring-capacity-interval" toml:"monitoring-capacity-interval" json:"monitoringCapacityInterval"`
+	MonitorCapacityKeep     int  `mapstructure:"monitoring-capacity-keep" toml:"monitoring-capacity-keep" json:"monitoringCapacityKeep"`
+	MonitorCapacityHorizon  int  `mapstructure:"monitoring-capacity-horizon" toml:"monitoring-capacity-horizon" json:"monitoringCapacityHorizon"`
+	MonitorCapacityWarnDays int  `mapstructure:"monitoring-capacity-warn-days" toml:"monitoring-capacity-warn-days" json:"monitoringCapacityWarnDays"`
+
+	// configuration drift
+	MonitorConfigDrift       bool   `mapstructure:"monitoring-config-drift" toml:"monitoring-config-drift" json:"monitoringConfigDrift"`
+	MonitorConfigDriftIgnore string `mapstructure:"monitoring-config-drift-ignore" toml:"monitoring-config-drift-ignore" json:"monitoringConfigDriftIgnore"`
+
+	// failover hooks
+	HookScripts       string `mapstructure:"hook-scripts" toml:"hook-scripts" json:"hookScripts"`
+	HookWebhooks      string `mapstructure:"hook-webhooks" toml:"hook-webhooks" json:"hookWebhooks"`
+	HookWebhookSecret string `mapstructure:"hook-webhook-secret" toml:"hook-webhook-secret" json:"-"`
+	HookEvents        string `mapstructure:"hook-events" toml:"hook-events" json:"hookEvents"`
+	HookVeto          bool   `mapstructure:"hook-veto" toml:"hook-veto" json:"hookVeto"`
+	HookTimeout       int    `mapstructure:"hook-timeout" toml:"hook-timeout" json:"hookTimeout"`
+	// binlog server
+	BinlogServerNative         bool   `mapstructure:"binlog-server-native" toml:"binlog-server-native" json:"binlogServerNative"`
+	BinlogServerBind           string `mapstructure:"binlog-server-bind" toml:"binlog-server-bind" json:"binlogServerBind"`
+	BinlogServerPort           int    `mapstructure:"binlog-server-port" toml:"binlog-server-port" json:"binlogServerPort"`
+	BinlogServerId             int    `mapstructure:"binlog-server-id" toml:"binlog-server-id" json:"binlogServerId"`
+	BinlogServerKeepDays       int    `mapstructure:"binlog-server-keep-days" toml:"binlog-server-keep-days" json:"binlogServerKeepDays"`
+	BinlogServerArchive        bool   `mapstructure:"binlog-server-archive" toml:"binlog-server-archive" json:"binlogServerArchive"`
+	BinlogServerLocalKeepFiles int    `mapstructure:"binlog-server-local-keep-files" toml:"binlog-server-local-keep-files" json:"binlogServerLocalKeepFiles"`
+
 	//	BackupResticStoragePolicy                 string `mapstructure:"backup-restic-storage-policy"  toml:"backup-restic-storage-policy" json:"backupResticStoragePolicy"`
 	//ProvMode                           string `mapstructure:"prov-mode" toml:"prov-mode" json:"provMode"` //InitContainer vs API
 
@@ -544,6 +637,7 @@ const (
 	ConstProxyMysqlrouter string = "mysqlrouter"
 	ConstProxySphinx      string = "sphinx"
 	ConstProxyMyProxy     string = "myproxy"
+	ConstProxyGobetween   string = "gobetween"
 )
 
 type ServicePlan struct {
@@ -657,14 +751,16 @@ const (
 )
 
 const (
-	ConstBackupPhysicalTypeXtrabackup  string = "xtrabackup"
-	ConstBackupPhysicalTypeMariaBackup string = "mariabackup"
+	ConstBackupPhysicalTypeXtrabackup   string = "xtrabackup"
+	ConstBackupPhysicalTypeMariaBackup  string = "mariabackup"
+	ConstBackupPhysicalTypePgBasebackup string = "pg_basebackup"
 )
 
 func (conf *Config) GetBackupPhysicalType() map[string]bool {
 	return map[string]bool{
-		ConstBackupPhysicalTypeXtrabackup:  true,
-		ConstBackupPhysicalTypeMariaBackup: true,
+		ConstBackupPhysicalTypeXtrabackup:   true,
+		ConstBackupPhysicalTypeMariaBackup:  true,
+		ConstBackupPhysicalTypePgBasebackup: true,
 	}
 }
 
@@ -725,6 +821,7 @@ func (conf *Config) GetMonitorType() map[string]string {
 		"shardproxy": "proxy",
 		"haproxy":    "proxy",
 		"myproxy":    "proxy",
+		"gobetween":  "proxy",
 		"extproxy":   "proxy",
 		"sphinx":     "proxy",
 	}
@@ -184,8 +184,33 @@ var (
 	NewGoofys         = internal.NewGoofys
 	TryUnmount        = internal.TryUnmount
 	MyUserAndGroup    = internal.MyUserAndGroup
+	NewBackend        = internal.NewBackend
 )
 
 type (
 	Goofys = internal.Goofys
 )
+
+// expose the storage backends to use a bucket without mounting it
+type (
+	StorageBackend            = internal.StorageBackend
+	HeadBlobInput             = internal.HeadBlobInput
+	HeadBlobOutput            = internal.HeadBlobOutput
+	ListBlobsInput            = internal.ListBlobsInput
+	ListBlobsOutput           = internal.ListBlobsOutput
+	BlobItemOutput            = internal.BlobItemOutput
+	DeleteBlobInput           = internal.DeleteBlobInput
+	DeleteBlobOutput          = internal.DeleteBlobOutput
+	GetBlobInput              = internal.GetBlobInput
+	GetBlobOutput             = internal.GetBlobOutput
+	PutBlobInput              = internal.PutBlobInput
+	PutBlobOutput             = internal.PutBlobOutput
+	MultipartBlobBeginInput   = internal.MultipartBlobBeginInput
+	MultipartBlobAddInput     = internal.MultipartBlobAddInput
+	MultipartBlobAddOutput    = internal.MultipartBlobAddOutput
+	MultipartBlobCommitInput  = internal.MultipartBlobCommitInput
+	MultipartBlobCommitOutput = internal.MultipartBlobCommitOutput
+	MultipartBlobAbortOutput  = internal.MultipartBlobAbortOutput
+	MultipartExpireInput      = internal.MultipartExpireInput
+	MultipartExpireOutput     = internal.MultipartExpireOutput
+)
@@ -178,12 +178,47 @@ func init() {
 	monitorCmd.Flags().StringVar(&conf.MonitorTenant, "monitoring-tenant", "default", "Can be use to store multi tenant identifier")
 	monitorCmd.Flags().Int64Var(&conf.MonitorWaitRetry, "monitoring-wait-retry", 30, "Retry this number of time before giving up state transition <999999")
 	monitorCmd.Flags().BoolVar(&conf.LogSST, "log-sst", false, "Log open and close SST transfert")
+	monitorCmd.Flags().IntVar(&conf.SSTBandwidth, "sst-bandwidth", 0, "Bandwidth limit in KB/s of the backups sent to the database hosts, 0 for unlimited")
 	monitorCmd.Flags().BoolVar(&conf.LogHeartbeat, "log-heartbeat", false, "Log Heartbeat")
 	monitorCmd.Flags().BoolVar(&conf.LogFailedElection, "log-failed-election", false, "Log failed election")
 	monitorCmd.Flags().BoolVar(&conf.LogSQLInMonitoring, "log-sql-in-monitoring", false, "Log SQL queries send to servers in monitoring")
 	monitorCmd.Flags().BoolVar(&conf.MonitorCapture, "monitoring-capture", true, "Enable capture on error for 5 monitor loops")
 	monitorCmd.Flags().StringVar(&conf.MonitorCaptureTrigger, "monitoring-capture-trigger", "ERR00076,ERR00041", "List of errno triggering capture mode")
 	monitorCmd.Flags().IntVar(&conf.MonitorCaptureFileKeep, "monitoring-capture-file-keep", 5, "Purge capture file keep that number of them")
+	monitorCmd.Flags().StringVar(&conf.WorkloadCaptureSource, "workload-capture-source", "pfs", "Workload capture from the statements history of performance schema (pfs) or from the slow log with long_query_time=0 (slowlog)")
+	monitorCmd.Flags().IntVar(&conf.WorkloadCapturePoll, "workload-capture-poll", 100, "Workload capture poll of performance schema in ms, statements out of events_statements_history_long between polls are lost")
+	monitorCmd.Flags().IntVar(&conf.WorkloadCaptureMaxEvents, "workload-capture-max-events", 5000000, "Workload capture stops after that number of queries")
+	monitorCmd.Flags().IntVar(&conf.WorkloadCaptureKeep, "workload-capture-keep", 10, "Purge workload captures and their replays keep that number of them")
+	monitorCmd.Flags().Float64Var(&conf.WorkloadReplaySpeed, "workload-replay-speed", 1, "Workload replay speed, 1 at the captured pace, 2 twice faster, 0 as fast as possible")
+	monitorCmd.Flags().BoolVar(&conf.WorkloadReplayReadOnly, "workload-replay-read-only", true, "Workload replay skips the queries changing data, writes are only replayed without binlog on a server detached from the replication")
+	monitorCmd.Flags().BoolVar(&conf.MonitorDigestHistory, "monitoring-digest-history", false, "Sample the query digests of performance schema to keep their activity over time")
+	monitorCmd.Flags().IntVar(&conf.MonitorDigestHistoryInterval, "monitoring-digest-history-interval", 60, "Digest history sample interval in seconds")
+	monitorCmd.Flags().IntVar(&conf.MonitorDigestHistoryDigests, "monitoring-digest-history-digests", 500, "Digest history samples that number of digests with the highest total latency")
+	monitorCmd.Flags().IntVar(&conf.MonitorDigestHistoryKeep, "monitoring-digest-history-keep", 8, "Purge digest history samples older than that number of days")
+	monitorCmd.Flags().Float64Var(&conf.MonitorDigestHistoryRegressionRatio, "monitoring-digest-history-regression-ratio", 2, "Digest history reports a regression when the p95 latency of a digest in the last hour is that times its p95 latency of the previous day")
+	monitorCmd.Flags().IntVar(&conf.MonitorDigestHistoryRegressionMinCount, "monitoring-digest-history-regression-min-count", 100, "Digest history ignores the digests with less executions in the compared windows")
+	monitorCmd.Flags().IntVar(&conf.IndexAdvisorDigests, "index-advisor-digests", 20, "Index advisor explains that number of performance schema digests with the highest total latency")
+	monitorCmd.Flags().IntVar(&conf.IndexAdvisorMinRows, "index-advisor-min-rows", 1000, "Index advisor ignores the full scans of tables with less rows in the plan")
+	monitorCmd.Flags().BoolVar(&conf.MonitorCapacity, "monitoring-capacity", true, "Keep the usage of disk, connections and binary logs over time and forecast when they are exhausted")
+	monitorCmd.Flags().IntVar(&conf.MonitorCapacityInterval, "monitoring-capacity-interval", 900, "Capacity sample interval in seconds")
+	monitorCmd.Flags().IntVar(&conf.MonitorCapacityKeep, "monitoring-capacity-keep", 90, "Purge capacity samples older than that number of days")
+	monitorCmd.Flags().IntVar(&conf.MonitorCapacityHorizon, "monitoring-capacity-horizon", 90, "Capacity forecast horizon in days")
+	monitorCmd.Flags().IntVar(&conf.MonitorCapacityWarnDays, "monitoring-capacity-warn-days", 30, "Raise a warning when the disk or the connections are forecast exhausted within that number of days")
+	monitorCmd.Flags().BoolVar(&conf.MonitorConfigDrift, "monitoring-config-drift", true, "Compare the variables of the database servers with their generated configuration and with the master, and warn on drifts dangerous for failover")
+	monitorCmd.Flags().StringVar(&conf.MonitorConfigDriftIgnore, "monitoring-config-drift-ignore", "", "Configuration drifts to ignore, comma separated variable patterns with an optional @host:port")
+	monitorCmd.Flags().StringVar(&conf.HookScripts, "hook-scripts", "", "Scripts called on the failover lifecycle events, comma separated, with the event as argument and a JSON payload on stdin")
+	monitorCmd.Flags().StringVar(&conf.HookWebhooks, "hook-webhooks", "", "URLs receiving the JSON payload of the failover lifecycle events, comma separated")
+	monitorCmd.Flags().StringVar(&conf.HookWebhookSecret, "hook-webhook-secret", "", "Secret signing the webhook payloads with HMAC-SHA256 in the X-Replication-Manager-Signature header")
+	monitorCmd.Flags().StringVar(&conf.HookEvents, "hook-events", "all", "Events calling the hooks: pre-failover, candidate-elected, post-promote, proxies-updated, rejoin-start, rejoin-end, backup-finished or all")
+	monitorCmd.Flags().BoolVar(&conf.HookVeto, "hook-veto", false, "A hook failing on pre-failover, candidate-elected or rejoin-start cancels the operation, a hook timing out never does")
+	monitorCmd.Flags().IntVar(&conf.HookTimeout, "hook-timeout", 10, "Timeout in seconds of each hook")
+	monitorCmd.Flags().BoolVar(&conf.BinlogServerNative, "binlog-server-native", false, "Relay the binary logs of the master in replication-manager and serve them to the replicas over GTID, following the new master on failover")
+	monitorCmd.Flags().StringVar(&conf.BinlogServerBind, "binlog-server-bind", "0.0.0.0", "Address the replicas of the native binlog server connect to")
+	monitorCmd.Flags().IntVar(&conf.BinlogServerPort, "binlog-server-port", 3310, "Port the replicas of the native binlog server connect to, shifted by 10 for each other cluster using it")
+	monitorCmd.Flags().IntVar(&conf.BinlogServerId, "binlog-server-id", 10000, "Server id of the native binlog server, unique in the cluster")
+	monitorCmd.Flags().IntVar(&conf.BinlogServerKeepDays, "binlog-server-keep-days", 7, "Days the binary logs of the native binlog server are kept once rotated, 0 keeps them")
+	monitorCmd.Flags().BoolVar(&conf.BinlogServerArchive, "binlog-server-archive", false, "Archive the binary logs of the native binlog server in the backup store once rotated")
+	monitorCmd.Flags().IntVar(&conf.BinlogServerLocalKeepFiles, "binlog-server-local-keep-files", 10, "Binary logs of the native binlog server kept locally once archived, older ones are read back from the backup store")
 	monitorCmd.Flags().StringVar(&conf.User, "db-servers-credential", "root:mariadb", "Database login, specified in the [user]:[password] format")
 	monitorCmd.Flags().StringVar(&conf.Hosts, "db-servers-hosts", "", "Database hosts list to monitor, IP and port (optional), specified in the host:[port] format and separated by commas")
 	monitorCmd.Flags().BoolVar(&conf.DBServersTLSUseGeneratedCertificate, "db-servers-tls-use-generated-cert", false, "Use the auto generated certificates to connect to database backend")
@@ -216,10 +251,19 @@ func init() {
 	monitorCmd.Flags().BoolVar(&conf.MultiMaster, "replication-multi-master", false, "Multi-master topology")
 	monitorCmd.Flags().BoolVar(&conf.MultiMasterWsrep, "replication-multi-master-wsrep", false, "Enable Galera multi-master")
 	monitorCmd.Flags().StringVar(&conf.MultiMasterWsrepSSTMethod, "replication-multi-master-wsrep-sst-method", "mariabackup", "mariabackup|xtrabackup-v2|rsync|mysqldump")
+	monitorCmd.Flags().BoolVar(&conf.MultiMasterWsrepAutoBootstrap, "replication-multi-master-wsrep-autobootstrap", false, "Bootstrap the most advanced Galera node when the cluster lost its primary component or all nodes are down")
+	monitorCmd.Flags().StringVar(&conf.MultiMasterWsrepDonorHosts, "replication-multi-master-wsrep-donor-hosts", "", "Galera preferred SST donors, list of host:[port] pinned in wsrep_sst_donor of the other nodes")
+	monitorCmd.Flags().StringVar(&conf.MultiMasterWsrepDatadir, "replication-multi-master-wsrep-datadir", "/var/lib/mysql", "Galera nodes datadir where grastate.dat is read over ssh")
+	monitorCmd.Flags().StringVar(&conf.MultiMasterWsrepServiceName, "replication-multi-master-wsrep-service-name", "mariadb", "Galera nodes systemd service name")
 	monitorCmd.Flags().BoolVar(&conf.MultiMasterRing, "replication-multi-master-ring", false, "Multi-master ring topology")
+	monitorCmd.Flags().BoolVar(&conf.MultiMasterGrouprep, "replication-multi-master-grouprep", false, "Enable MySQL Group Replication single primary")
 	monitorCmd.Flags().BoolVar(&conf.MultiTierSlave, "replication-multi-tier-slave", false, "Relay slaves topology")
 	monitorCmd.Flags().BoolVar(&conf.MasterSlavePgStream, "replication-master-slave-pg-stream", false, "Postgres streaming replication")
 	monitorCmd.Flags().BoolVar(&conf.MasterSlavePgLogical, "replication-master-slave-pg-locgical", false, "Postgres logical replication")
+	monitorCmd.Flags().BoolVar(&conf.PgStreamSlots, "replication-pg-stream-slots", false, "Postgres streaming replication use a physical replication slot per standby")
+	monitorCmd.Flags().StringVar(&conf.PgDatadir, "replication-pg-datadir", "/var/lib/postgresql/data", "Postgres data directory on database hosts used by pg_rewind")
+	monitorCmd.Flags().StringVar(&conf.PgServiceName, "replication-pg-service-name", "postgresql", "Postgres systemd service name on database hosts")
+	monitorCmd.Flags().StringVar(&conf.PgRewindPath, "replication-pg-rewind-path", "pg_rewind", "Path to pg_rewind binary on database hosts")
 	monitorCmd.Flags().BoolVar(&conf.ReplicationNoRelay, "replication-master-slave-never-relay", true, "Do not allow relay server MSS MXS XXM RSM")
 	monitorCmd.Flags().StringVar(&conf.ReplicationErrorScript, "replication-error-script", "", "Replication error script")
 	monitorCmd.Flags().StringVar(&conf.ReplicationRestartOnSQLErrorMatch, "replication-restart-on-sqlerror-match", "", "Auto restart replication on SQL Error regexep")
@@ -250,6 +294,7 @@ func init() {
 	monitorCmd.Flags().BoolVar(&conf.AutorejoinNoSemisync, "autorejoin-flashback-on-unsync", false, "Automatic rejoin flashback if election status is semisync NOT SYNC ")
 	monitorCmd.Flags().BoolVar(&conf.AutorejoinFlashback, "autorejoin-flashback", false, "Automatic rejoin ahead failed master via binlog flashback")
 	monitorCmd.Flags().BoolVar(&conf.AutorejoinZFSFlashback, "autorejoin-zfs-flashback", false, "Automatic rejoin ahead failed master via previous ZFS snapshot")
+	monitorCmd.Flags().BoolVar(&conf.AutorejoinPgRewind, "autorejoin-pg-rewind", false, "Automatic rejoin Postgres failed primary via pg_rewind over ssh")
 	monitorCmd.Flags().BoolVar(&conf.AutorejoinMysqldump, "autorejoin-mysqldump", false, "Automatic rejoin ahead failed master via direct current master dump")
 	monitorCmd.Flags().BoolVar(&conf.AutorejoinPhysicalBackup, "autorejoin-physical-backup", false, "Automatic rejoin ahead failed master via reseed previous phyiscal backup")
 	monitorCmd.Flags().BoolVar(&conf.AutorejoinLogicalBackup, "autorejoin-logical-backup", false, "Automatic rejoin ahead failed master via reseed previous logical backup")
@@ -270,6 +315,7 @@ func init() {
 	monitorCmd.Flags().BoolVar(&conf.RplChecks, "check-replication-state", true, "Check replication status when electing master server")
 
 	monitorCmd.Flags().StringVar(&conf.APIPort, "api-port", "10005", "Rest API listen port")
+	monitorCmd.Flags().StringVar(&conf.APIGrpcPort, "api-grpc-port", "10006", "gRPC API listen port, empty to disable")
 	monitorCmd.Flags().StringVar(&conf.APIUsers, "api-credentials", "admin:repman", "Rest API user list user:password,..")
 	monitorCmd.Flags().StringVar(&conf.APIUsersExternal, "api-credentials-external", "dba:repman,foo:bar", "Rest API user list user:password,..")
 	monitorCmd.Flags().StringVar(&conf.APIUsersACLAllow, "api-credentials-acl-allow", "admin:cluster proxy db prov,dba:cluster proxy db,foo:", "User acl allow")
@@ -312,6 +358,9 @@ func init() {
 		monitorCmd.Flags().StringVar(&conf.HttpRoot, "http-root", "/usr/share/replication-manager/dashboard", "Path to HTTP replication-monitor files")
 	}
 	monitorCmd.Flags().IntVar(&conf.HttpRefreshInterval, "http-refresh-interval", 4000, "Http refresh interval in ms")
+	monitorCmd.Flags().IntVar(&conf.HttpStreamBuffer, "http-stream-buffer", 1000, "Number of cluster events kept to resume event streams")
+	monitorCmd.Flags().IntVar(&conf.HttpStreamKeepAlive, "http-stream-keepalive", 15, "Seconds between keep alive messages on event streams")
+	monitorCmd.Flags().StringVar(&conf.HttpStreamAllowedOrigins, "http-stream-allowed-origins", "", "Comma separated list of origins allowed to stream the cluster events, besides the host of the API")
 	monitorCmd.Flags().IntVar(&conf.SessionLifeTime, "http-session-lifetime", 3600, "Http Session life time ")
 
 	if WithMail == "ON" {
@@ -395,6 +444,12 @@ func init() {
 	monitorCmd.Flags().IntVar(&conf.MyproxyPort, "myproxy-port", 4000, "Internal proxy read/write port")
 	monitorCmd.Flags().StringVar(&conf.MyproxyUser, "myproxy-user", "admin", "Myproxy user")
 	monitorCmd.Flags().StringVar(&conf.MyproxyPassword, "myproxy-password", "repman", "Myproxy password")
+	monitorCmd.Flags().Int64Var(&conf.MyproxyMaxDelay, "myproxy-max-delay", 30, "Myproxy remove slaves from the read pool when replication delay is over this value in seconds")
+	monitorCmd.Flags().BoolVar(&conf.GobetweenOn, "gobetween", false, "Use internal gobetween L4 load balancer")
+	monitorCmd.Flags().StringVar(&conf.GobetweenBindIp, "gobetween-ip-bind", "0.0.0.0", "Gobetween input bind address")
+	monitorCmd.Flags().IntVar(&conf.GobetweenWritePort, "gobetween-write-port", 3308, "Gobetween read-write port to leader, shifted by 10 for each other cluster using it")
+	monitorCmd.Flags().IntVar(&conf.GobetweenReadPort, "gobetween-read-port", 3309, "Gobetween load balance read port to healthy slaves, shifted by 10 for each other cluster using it")
+	monitorCmd.Flags().StringVar(&conf.GobetweenReadBalance, "gobetween-read-balance", "leastconn", "Gobetween read balancing weight|leastconn|roundrobin|leastbandwidth|iphash|iphash1")
 
 	if WithProxysql == "ON" {
 		monitorCmd.Flags().BoolVar(&conf.ProxysqlOn, "proxysql", false, "Use ProxySQL")
@@ -463,6 +518,9 @@ func init() {
 	monitorCmd.Flags().BoolVar(&conf.SchedulerDatabaseOptimize, "scheduler-db-servers-optimize", true, "Schedule database optimize")
 	monitorCmd.Flags().StringVar(&conf.BackupLogicalCron, "scheduler-db-servers-logical-backup-cron", "0 0 1 * * 6", "Logical backup cron expression represents a set of times, using 6 space-separated fields.")
 	monitorCmd.Flags().StringVar(&conf.BackupPhysicalCron, "scheduler-db-servers-physical-backup-cron", "0 0 0 * * 0-4", "Physical backup cron expression represents a set of times, using 6 space-separated fields.")
+	monitorCmd.Flags().BoolVar(&conf.SchedulerBackupIncremental, "scheduler-db-servers-incremental-backup", false, "Schedule incremental physical backup on the last backup chain")
+	monitorCmd.Flags().StringVar(&conf.BackupIncrementalCron, "scheduler-db-servers-incremental-backup-cron", "0 0 * * * *", "Incremental physical backup cron expression represents a set of times, using 6 space-separated fields.")
+	monitorCmd.Flags().IntVar(&conf.BackupPhysicalChainsKeep, "backup-physical-chains-keep", 2, "Number of physical backup chains, a full backup and its incremental backups, to keep")
 	monitorCmd.Flags().StringVar(&conf.BackupDatabaseOptimizeCron, "scheduler-db-servers-optimize-cron", "0 0 3 1 * 5", "Optimize cron expression represents a set of times, using 6 space-separated fields.")
 	monitorCmd.Flags().StringVar(&conf.BackupDatabaseLogCron, "scheduler-db-servers-logs-cron", "0 0/10 * * * *", "Logs backup cron expression represents a set of times, using 6 space-separated fields.")
 	monitorCmd.Flags().BoolVar(&conf.SchedulerDatabaseLogsTableRotate, "scheduler-db-servers-logs-table-rotate", true, "Schedule rotate database system table logs")
@@ -475,6 +533,18 @@ func init() {
 	monitorCmd.Flags().StringVar(&conf.SchedulerRollingReprovCron, "scheduler-rolling-reprov-cron", "0 30 10 * * 5", "Rolling reprov cron expression represents a set of times, using 6 space-separated fields.")
 	monitorCmd.Flags().BoolVar(&conf.SchedulerJobsSSH, "scheduler-jobs-ssh", false, "Schedule remote execution of dbjobs via ssh ")
 	monitorCmd.Flags().StringVar(&conf.SchedulerJobsSSHCron, "scheduler-jobs-ssh-cron", "0 * * * * *", "Remote execution of dbjobs via ssh ")
+	monitorCmd.Flags().BoolVar(&conf.SchedulerChaos, "scheduler-chaos", false, "Schedule chaos runs injecting faults on clusters flagged with test")
+	monitorCmd.Flags().StringVar(&conf.SchedulerChaosCron, "scheduler-chaos-cron", "0 0 3 * * 6", "Chaos run cron expression represents a set of times, using 6 space-separated fields.")
+	monitorCmd.Flags().StringVar(&conf.ChaosFaults, "chaos-faults", "kill-master,stop-io-thread,replication-delay,block-proxy-backend,fill-disk", "Faults injected by a chaos run in order")
+	monitorCmd.Flags().IntVar(&conf.ChaosFillDiskSize, "chaos-fill-disk-size", 1024, "Size in MB of the temporary file written on the master by the fill-disk fault")
+	monitorCmd.Flags().IntVar(&conf.ChaosReportKeep, "chaos-report-keep", 10, "Number of chaos run reports kept in memory")
+	monitorCmd.Flags().BoolVar(&conf.SchedulerChecksum, "scheduler-checksum", false, "Schedule replication consistency checks of all tables")
+	monitorCmd.Flags().StringVar(&conf.SchedulerChecksumCron, "scheduler-checksum-cron", "0 0 2 * * *", "Consistency check cron expression represents a set of times, using 6 space-separated fields.")
+	monitorCmd.Flags().IntVar(&conf.ChecksumChunkSize, "checksum-chunk-size", 1000, "Number of rows of a table checksum chunk")
+	monitorCmd.Flags().IntVar(&conf.ChecksumChunkSleep, "checksum-chunk-sleep", 100, "Time in ms to sleep between two checksum chunks")
+	monitorCmd.Flags().IntVar(&conf.ChecksumMaxDelay, "checksum-max-delay", 10, "Pause the checksum while a slave is delayed for more than this number of seconds, 0 to disable")
+	monitorCmd.Flags().BoolVar(&conf.ChecksumRepair, "checksum-repair", false, "Apply the repair statements of diverging chunks on the slaves without binlog")
+	monitorCmd.Flags().IntVar(&conf.ChecksumHistoryKeep, "checksum-history-keep", 10, "Number of consistency check reports kept in history")
 
 	monitorCmd.Flags().BoolVar(&conf.Backup, "backup", false, "Turn on Backup")
 	monitorCmd.Flags().IntVar(&conf.BackupLogicalLoadThreads, "backup-logical-load-threads", 2, "Number of threads to load database")
@@ -496,6 +566,15 @@ func init() {
 	monitorCmd.Flags().StringVar(&conf.BackupStreamingEndpoint, "backup-streaming-endpoint", "https://s3.signal18.io/", "Backup AWS endpoint")
 	monitorCmd.Flags().StringVar(&conf.BackupStreamingRegion, "backup-streaming-region", "fr-1", "Backup AWS region")
 	monitorCmd.Flags().StringVar(&conf.BackupStreamingBucket, "backup-streaming-bucket", "repman", "Backup AWS bucket")
+	monitorCmd.Flags().StringVar(&conf.BackupStreamingType, "backup-streaming-type", "s3", "Backup streaming storage s3|azure, the azure account and key are the access key id and secret")
+	monitorCmd.Flags().StringVar(&conf.BackupStreamingPrefix, "backup-streaming-prefix", "", "Backup streaming key prefix in the bucket")
+	monitorCmd.Flags().StringVar(&conf.BackupStreamingEncryption, "backup-streaming-encryption", "", "Backup S3 server side encryption AES256|aws:kms|sse-c")
+	monitorCmd.Flags().StringVar(&conf.BackupStreamingKmsKeyId, "backup-streaming-kms-key-id", "", "Backup S3 KMS key id for aws:kms encryption")
+	monitorCmd.Flags().StringVar(&conf.BackupStreamingSseCKey, "backup-streaming-sse-c-key", "", "Backup S3 base64 customer key for sse-c encryption")
+	monitorCmd.Flags().StringVar(&conf.BackupStreamingStorageClass, "backup-streaming-storage-class", "STANDARD", "Backup S3 storage class")
+	monitorCmd.Flags().IntVar(&conf.BackupStreamingPartSize, "backup-streaming-part-size", 16, "Backup streaming multipart upload part size in MB")
+	monitorCmd.Flags().BoolVar(&conf.BackupStreamingLifecycle, "backup-streaming-lifecycle", false, "Backup bucket lifecycle rules expire old objects, retention does not delete on age")
+	monitorCmd.Flags().IntVar(&conf.BackupStreamingKeepDays, "backup-streaming-keep-days", 0, "Backup archived binlogs older are deleted beyond backup-binlogs-keep, 0 to keep")
 
 	//monitorCmd.Flags().StringVar(&conf.BackupResticStoragePolicy, "backup-restic-storage-policy", "--prune --keep-last 10 --keep-hourly 24 --keep-daily 7 --keep-weekly 52 --keep-monthly 120 --keep-yearly 102", "Restic keep backup policy")
 	monitorCmd.Flags().IntVar(&conf.BackupKeepHourly, "backup-keep-hourly", 1, "Keep this number of hourly backup")
@@ -510,6 +589,7 @@ func init() {
 	monitorCmd.Flags().StringVar(&conf.BackupMysqldumpOptions, "backup-mysqldump-options", "--hex-blob --single-transaction --verbose --all-databases --add-drop-database --system=all", "Extra options")
 	monitorCmd.Flags().StringVar(&conf.BackupMysqlbinlogPath, "backup-mysqlbinlog-path", "", "Path to mysqlbinlog binary")
 	monitorCmd.Flags().StringVar(&conf.BackupMysqlclientPath, "backup-mysqlclient-path", "", "Path to mysql client binary")
+	monitorCmd.Flags().StringVar(&conf.BackupPgBasebackupPath, "backup-pg-basebackup-path", "", "Path to pg_basebackup binary, found in PATH when empty")
 	monitorCmd.Flags().BoolVar(&conf.BackupBinlogs, "backup-binlogs", false, "Archive binlogs")
 	monitorCmd.Flags().IntVar(&conf.BackupBinlogsKeep, "backup-binlogs-keep", 10, "Number of master binlog to keep")
 	monitorCmd.Flags().BoolVar(&conf.ProvBinaryInTarball, "prov-db-binary-in-tarball", false, "Add prov-db-binary-tarball-name binaries to init tarball")
@@ -3,6 +3,7 @@ package regtest
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/signal18/replication-manager/cluster"
 )
@@ -540,14 +541,20 @@ func (regtest *RegTest) RunAllTests(cl *cluster.Cluster, test string) []cluster.
 	}
 	vals := make([]cluster.Test, 0, len(allTests))
 	keys := make([]string, 0, len(allTests))
-	for key, val := range allTests {
+	for key := range allTests {
 		keys = append(keys, key)
-		vals = append(vals, val)
 	}
 	sort.Strings(keys)
 	for _, v := range keys {
+		vals = append(vals, allTests[v])
 		cl.LogPrintf("TEST", "Result %s -> %s", strings.Trim(v+strings.Repeat(" ", 60-len(v)), "test"), allTests[v].Result)
 	}
+	if len(vals) > 0 {
+		err := WriteReport(cl.GetConf().WorkingDir+"/"+cl.Name+"/tests", "regtest-"+time.Now().Format("20060102150405"), cl.Name, vals)
+		if err != nil {
+			cl.LogPrintf(LvlErr, "Could not write test report: %s", err)
+		}
+	}
 	cl.CleanAll = false
 	return vals
 }
@@ -0,0 +1,130 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package regtest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/cluster"
+)
+
+// TestResults is the JSON report, it is also read by test --convert
+type TestResults struct {
+	Results []cluster.Test `json:"results"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// GetJUnitReport returns the tests as a JUnit XML test suite
+func GetJUnitReport(suite string, tests []cluster.Test) ([]byte, error) {
+	ts := junitTestSuite{Name: suite, Tests: len(tests), Timestamp: time.Now().Format(time.RFC3339)}
+	var total float64
+	for _, t := range tests {
+		total += t.Duration
+		tc := junitTestCase{
+			Name:      t.Name,
+			ClassName: suite,
+			Time:      fmt.Sprintf("%.3f", t.Duration),
+			SystemOut: getTestOutput(t),
+		}
+		switch t.Result {
+		case "PASS":
+		case "FAIL":
+			ts.Failures++
+			tc.Failure = &junitMessage{Message: "Test failed", Type: t.Result, Body: strings.Join(t.Servers, "\n")}
+		default:
+			ts.Errors++
+			tc.Error = &junitMessage{Message: "Test did not run", Type: t.Result}
+		}
+		ts.Cases = append(ts.Cases, tc)
+	}
+	ts.Time = fmt.Sprintf("%.3f", total)
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{ts}}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func getTestOutput(t cluster.Test) string {
+	var out []string
+	if t.ConfigFile != "" {
+		out = append(out, "Config file: "+t.ConfigFile)
+	}
+	for _, s := range t.Steps {
+		out = append(out, fmt.Sprintf("Step %s: %.3fs", s.Name, s.Duration))
+	}
+	if len(t.ConfigDiff) > 0 {
+		out = append(out, "Config diff:")
+		out = append(out, t.ConfigDiff...)
+	}
+	if t.Topology != "" {
+		out = append(out, "Topology: "+t.Topology)
+		out = append(out, t.Servers...)
+	}
+	if len(t.Logs) > 0 {
+		out = append(out, "Logs:")
+		out = append(out, t.Logs...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// WriteReport writes the tests in dir as name.json and name.xml in JUnit format
+func WriteReport(dir string, name string, suite string, tests []cluster.Test) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(TestResults{Results: tests}, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dir+"/"+name+".json", data, 0644); err != nil {
+		return err
+	}
+	data, err = GetJUnitReport(suite, tests)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dir+"/"+name+".xml", data, 0644)
+}
@@ -0,0 +1,93 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package regtest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/signal18/replication-manager/cluster"
+)
+
+func getReportTests() []cluster.Test {
+	var pass, fail, err cluster.Test
+	pass.Name = "testSwitchoverReadOnlyNoRplCheck"
+	pass.Result = "PASS"
+	pass.StartStep("init")
+	pass.StartStep("run")
+	pass.EndStep()
+	pass.Duration = 12.5
+	pass.Logs = []string{"2021/01/01 00:00:00 [cluster] INFO  - Starting Test testSwitchoverReadOnlyNoRplCheck"}
+	fail.Name = "testFailoverManual"
+	fail.Result = "FAIL"
+	fail.Duration = 3
+	fail.ConfigDiff = []string{"failover-mode: manual -> automatic"}
+	fail.Topology = "master-slave"
+	fail.Servers = []string{"db1:3306 Failed", "db2:3306 Master"}
+	err.Name = "testFailoverTimeNotReach"
+	err.Result = "ERR"
+	return []cluster.Test{pass, fail, err}
+}
+
+func TestJUnitReport(t *testing.T) {
+	data, err := GetJUnitReport("cluster1", getReportTests())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("Invalid JUnit XML: %s\n%s", err, data)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("Expected one test suite got %d", len(suites.Suites))
+	}
+	ts := suites.Suites[0]
+	if ts.Tests != 3 || ts.Failures != 1 || ts.Errors != 1 || ts.Time != "15.500" {
+		t.Fatalf("Unexpected suite counters %+v", ts)
+	}
+	if ts.Cases[0].Failure != nil || ts.Cases[1].Failure == nil || ts.Cases[2].Error == nil {
+		t.Fatalf("Unexpected test case results %+v", ts.Cases)
+	}
+	if !strings.Contains(ts.Cases[0].SystemOut, "Step run:") || !strings.Contains(ts.Cases[0].SystemOut, "Starting Test") {
+		t.Fatalf("Expected steps and logs in output got %s", ts.Cases[0].SystemOut)
+	}
+	if !strings.Contains(ts.Cases[1].SystemOut, "failover-mode: manual -> automatic") || !strings.Contains(ts.Cases[1].SystemOut, "db2:3306 Master") {
+		t.Fatalf("Expected config diff and topology in output got %s", ts.Cases[1].SystemOut)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteReport(dir+"/tests", "regtest", "cluster1", getReportTests()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(dir + "/tests/regtest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res TestResults
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 3 || len(res.Results[0].Steps) != 2 || res.Results[1].Topology != "master-slave" {
+		t.Fatalf("Unexpected JSON report %+v", res.Results)
+	}
+	if _, err := os.Stat(dir + "/tests/regtest.xml"); err != nil {
+		t.Fatal(err)
+	}
+}
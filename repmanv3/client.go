@@ -0,0 +1,84 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package repmanv3 is the gRPC API of replication-manager and its Go client
+package repmanv3
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Client is a ClusterService client that sends the token returned by Authenticate
+// with every call
+type Client struct {
+	ClusterServiceClient
+	conn  *grpc.ClientConn
+	mu    sync.RWMutex
+	token string
+}
+
+type clientToken struct {
+	client *Client
+}
+
+func (t clientToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	t.client.mu.RLock()
+	defer t.client.mu.RUnlock()
+	if t.client.token == "" {
+		return nil, nil
+	}
+	return map[string]string{"authorization": "Bearer " + t.client.token}, nil
+}
+
+func (t clientToken) RequireTransportSecurity() bool {
+	return true
+}
+
+// Dial connects to the gRPC API listening on addr, a nil tlsConfig verifies the
+// server certificate with the system pool
+func Dial(addr string, tlsConfig *tls.Config, opts ...grpc.DialOption) (*Client, error) {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	c := new(Client)
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithPerRPCCredentials(clientToken{client: c}),
+	}, opts...)
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.ClusterServiceClient = NewClusterServiceClient(conn)
+	return c, nil
+}
+
+// Authenticate logs in and keeps the token for the next calls
+func (c *Client) Authenticate(ctx context.Context, user string, password string) error {
+	res, err := c.Login(ctx, &LoginRequest{Username: user, Password: password})
+	if err != nil {
+		return err
+	}
+	c.SetToken(res.Token)
+	return nil
+}
+
+// SetToken sets the JWT token, a token from the REST /api/login route is valid
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
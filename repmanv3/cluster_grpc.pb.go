@@ -0,0 +1,766 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: cluster.proto
+
+package repmanv3
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ClusterService_Login_FullMethodName                = "/signal18.replication_manager.v3.ClusterService/Login"
+	ClusterService_ListClusters_FullMethodName         = "/signal18.replication_manager.v3.ClusterService/ListClusters"
+	ClusterService_GetCluster_FullMethodName           = "/signal18.replication_manager.v3.ClusterService/GetCluster"
+	ClusterService_ListServers_FullMethodName          = "/signal18.replication_manager.v3.ClusterService/ListServers"
+	ClusterService_GetMaster_FullMethodName            = "/signal18.replication_manager.v3.ClusterService/GetMaster"
+	ClusterService_ListProxies_FullMethodName          = "/signal18.replication_manager.v3.ClusterService/ListProxies"
+	ClusterService_ListAlerts_FullMethodName           = "/signal18.replication_manager.v3.ClusterService/ListAlerts"
+	ClusterService_Switchover_FullMethodName           = "/signal18.replication_manager.v3.ClusterService/Switchover"
+	ClusterService_Failover_FullMethodName             = "/signal18.replication_manager.v3.ClusterService/Failover"
+	ClusterService_ResetFailoverControl_FullMethodName = "/signal18.replication_manager.v3.ClusterService/ResetFailoverControl"
+	ClusterService_SetTraffic_FullMethodName           = "/signal18.replication_manager.v3.ClusterService/SetTraffic"
+	ClusterService_SwitchSetting_FullMethodName        = "/signal18.replication_manager.v3.ClusterService/SwitchSetting"
+	ClusterService_SetSetting_FullMethodName           = "/signal18.replication_manager.v3.ClusterService/SetSetting"
+	ClusterService_ServerAction_FullMethodName         = "/signal18.replication_manager.v3.ClusterService/ServerAction"
+	ClusterService_ProxyAction_FullMethodName          = "/signal18.replication_manager.v3.ClusterService/ProxyAction"
+	ClusterService_StreamLogs_FullMethodName           = "/signal18.replication_manager.v3.ClusterService/StreamLogs"
+	ClusterService_StreamEvents_FullMethodName         = "/signal18.replication_manager.v3.ClusterService/StreamEvents"
+)
+
+// ClusterServiceClient is the client API for ClusterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ClusterServiceClient interface {
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	ListClusters(ctx context.Context, in *ListClustersRequest, opts ...grpc.CallOption) (*ListClustersResponse, error)
+	GetCluster(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*Cluster, error)
+	ListServers(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ListServersResponse, error)
+	GetMaster(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*Server, error)
+	ListProxies(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ListProxiesResponse, error)
+	ListAlerts(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	Switchover(ctx context.Context, in *SwitchoverRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	Failover(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	ResetFailoverControl(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	SetTraffic(ctx context.Context, in *SetTrafficRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	SwitchSetting(ctx context.Context, in *SettingRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	SetSetting(ctx context.Context, in *SettingRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	ServerAction(ctx context.Context, in *ServerActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	ProxyAction(ctx context.Context, in *ProxyActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// StreamLogs sends the buffered log lines of the cluster then follows new ones
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (ClusterService_StreamLogsClient, error)
+	// StreamEvents follows the events of /api/clusters/{clusterName}/stream
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (ClusterService_StreamEventsClient, error)
+}
+
+type clusterServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClusterServiceClient(cc grpc.ClientConnInterface) ClusterServiceClient {
+	return &clusterServiceClient{cc}
+}
+
+func (c *clusterServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ListClusters(ctx context.Context, in *ListClustersRequest, opts ...grpc.CallOption) (*ListClustersResponse, error) {
+	out := new(ListClustersResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ListClusters_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) GetCluster(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*Cluster, error) {
+	out := new(Cluster)
+	err := c.cc.Invoke(ctx, ClusterService_GetCluster_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ListServers(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ListServersResponse, error) {
+	out := new(ListServersResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ListServers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) GetMaster(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*Server, error) {
+	out := new(Server)
+	err := c.cc.Invoke(ctx, ClusterService_GetMaster_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ListProxies(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ListProxiesResponse, error) {
+	out := new(ListProxiesResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ListProxies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ListAlerts(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	out := new(ListAlertsResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ListAlerts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Switchover(ctx context.Context, in *SwitchoverRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Switchover_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) Failover(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_Failover_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ResetFailoverControl(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ResetFailoverControl_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) SetTraffic(ctx context.Context, in *SetTrafficRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_SetTraffic_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) SwitchSetting(ctx context.Context, in *SettingRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_SwitchSetting_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) SetSetting(ctx context.Context, in *SettingRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_SetSetting_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ServerAction(ctx context.Context, in *ServerActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ServerAction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) ProxyAction(ctx context.Context, in *ProxyActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, ClusterService_ProxyAction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterServiceClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (ClusterService_StreamLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ClusterService_ServiceDesc.Streams[0], ClusterService_StreamLogs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clusterServiceStreamLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ClusterService_StreamLogsClient interface {
+	Recv() (*LogLine, error)
+	grpc.ClientStream
+}
+
+type clusterServiceStreamLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *clusterServiceStreamLogsClient) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *clusterServiceClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (ClusterService_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ClusterService_ServiceDesc.Streams[1], ClusterService_StreamEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clusterServiceStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ClusterService_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type clusterServiceStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *clusterServiceStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ClusterServiceServer is the server API for ClusterService service.
+// All implementations must embed UnimplementedClusterServiceServer
+// for forward compatibility
+type ClusterServiceServer interface {
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	ListClusters(context.Context, *ListClustersRequest) (*ListClustersResponse, error)
+	GetCluster(context.Context, *ClusterRequest) (*Cluster, error)
+	ListServers(context.Context, *ClusterRequest) (*ListServersResponse, error)
+	GetMaster(context.Context, *ClusterRequest) (*Server, error)
+	ListProxies(context.Context, *ClusterRequest) (*ListProxiesResponse, error)
+	ListAlerts(context.Context, *ClusterRequest) (*ListAlertsResponse, error)
+	Switchover(context.Context, *SwitchoverRequest) (*ActionResponse, error)
+	Failover(context.Context, *ClusterRequest) (*ActionResponse, error)
+	ResetFailoverControl(context.Context, *ClusterRequest) (*ActionResponse, error)
+	SetTraffic(context.Context, *SetTrafficRequest) (*ActionResponse, error)
+	SwitchSetting(context.Context, *SettingRequest) (*ActionResponse, error)
+	SetSetting(context.Context, *SettingRequest) (*ActionResponse, error)
+	ServerAction(context.Context, *ServerActionRequest) (*ActionResponse, error)
+	ProxyAction(context.Context, *ProxyActionRequest) (*ActionResponse, error)
+	// StreamLogs sends the buffered log lines of the cluster then follows new ones
+	StreamLogs(*StreamLogsRequest, ClusterService_StreamLogsServer) error
+	// StreamEvents follows the events of /api/clusters/{clusterName}/stream
+	StreamEvents(*StreamEventsRequest, ClusterService_StreamEventsServer) error
+	mustEmbedUnimplementedClusterServiceServer()
+}
+
+// UnimplementedClusterServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedClusterServiceServer struct {
+}
+
+func (UnimplementedClusterServiceServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedClusterServiceServer) ListClusters(context.Context, *ListClustersRequest) (*ListClustersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClusters not implemented")
+}
+func (UnimplementedClusterServiceServer) GetCluster(context.Context, *ClusterRequest) (*Cluster, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCluster not implemented")
+}
+func (UnimplementedClusterServiceServer) ListServers(context.Context, *ClusterRequest) (*ListServersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServers not implemented")
+}
+func (UnimplementedClusterServiceServer) GetMaster(context.Context, *ClusterRequest) (*Server, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMaster not implemented")
+}
+func (UnimplementedClusterServiceServer) ListProxies(context.Context, *ClusterRequest) (*ListProxiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProxies not implemented")
+}
+func (UnimplementedClusterServiceServer) ListAlerts(context.Context, *ClusterRequest) (*ListAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAlerts not implemented")
+}
+func (UnimplementedClusterServiceServer) Switchover(context.Context, *SwitchoverRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Switchover not implemented")
+}
+func (UnimplementedClusterServiceServer) Failover(context.Context, *ClusterRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Failover not implemented")
+}
+func (UnimplementedClusterServiceServer) ResetFailoverControl(context.Context, *ClusterRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetFailoverControl not implemented")
+}
+func (UnimplementedClusterServiceServer) SetTraffic(context.Context, *SetTrafficRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTraffic not implemented")
+}
+func (UnimplementedClusterServiceServer) SwitchSetting(context.Context, *SettingRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwitchSetting not implemented")
+}
+func (UnimplementedClusterServiceServer) SetSetting(context.Context, *SettingRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSetting not implemented")
+}
+func (UnimplementedClusterServiceServer) ServerAction(context.Context, *ServerActionRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ServerAction not implemented")
+}
+func (UnimplementedClusterServiceServer) ProxyAction(context.Context, *ProxyActionRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProxyAction not implemented")
+}
+func (UnimplementedClusterServiceServer) StreamLogs(*StreamLogsRequest, ClusterService_StreamLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedClusterServiceServer) StreamEvents(*StreamEventsRequest, ClusterService_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedClusterServiceServer) mustEmbedUnimplementedClusterServiceServer() {}
+
+// UnsafeClusterServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ClusterServiceServer will
+// result in compilation errors.
+type UnsafeClusterServiceServer interface {
+	mustEmbedUnimplementedClusterServiceServer()
+}
+
+func RegisterClusterServiceServer(s grpc.ServiceRegistrar, srv ClusterServiceServer) {
+	s.RegisterService(&ClusterService_ServiceDesc, srv)
+}
+
+func _ClusterService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ListClusters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClustersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ListClusters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ListClusters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ListClusters(ctx, req.(*ListClustersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_GetCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).GetCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_GetCluster_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).GetCluster(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ListServers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ListServers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ListServers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ListServers(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_GetMaster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).GetMaster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_GetMaster_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).GetMaster(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ListProxies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ListProxies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ListProxies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ListProxies(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ListAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ListAlerts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ListAlerts(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Switchover_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwitchoverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Switchover(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Switchover_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Switchover(ctx, req.(*SwitchoverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_Failover_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).Failover(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_Failover_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).Failover(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ResetFailoverControl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ResetFailoverControl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ResetFailoverControl_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ResetFailoverControl(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_SetTraffic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetTrafficRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).SetTraffic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_SetTraffic_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).SetTraffic(ctx, req.(*SetTrafficRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_SwitchSetting_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SettingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).SwitchSetting(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_SwitchSetting_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).SwitchSetting(ctx, req.(*SettingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_SetSetting_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SettingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).SetSetting(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_SetSetting_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).SetSetting(ctx, req.(*SettingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ServerAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServerActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ServerAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ServerAction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ServerAction(ctx, req.(*ServerActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_ProxyAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProxyActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServiceServer).ProxyAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterService_ProxyAction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServiceServer).ProxyAction(ctx, req.(*ProxyActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterService_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterServiceServer).StreamLogs(m, &clusterServiceStreamLogsServer{stream})
+}
+
+type ClusterService_StreamLogsServer interface {
+	Send(*LogLine) error
+	grpc.ServerStream
+}
+
+type clusterServiceStreamLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *clusterServiceStreamLogsServer) Send(m *LogLine) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ClusterService_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterServiceServer).StreamEvents(m, &clusterServiceStreamEventsServer{stream})
+}
+
+type ClusterService_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type clusterServiceStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *clusterServiceStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ClusterService_ServiceDesc is the grpc.ServiceDesc for ClusterService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ClusterService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "signal18.replication_manager.v3.ClusterService",
+	HandlerType: (*ClusterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Login",
+			Handler:    _ClusterService_Login_Handler,
+		},
+		{
+			MethodName: "ListClusters",
+			Handler:    _ClusterService_ListClusters_Handler,
+		},
+		{
+			MethodName: "GetCluster",
+			Handler:    _ClusterService_GetCluster_Handler,
+		},
+		{
+			MethodName: "ListServers",
+			Handler:    _ClusterService_ListServers_Handler,
+		},
+		{
+			MethodName: "GetMaster",
+			Handler:    _ClusterService_GetMaster_Handler,
+		},
+		{
+			MethodName: "ListProxies",
+			Handler:    _ClusterService_ListProxies_Handler,
+		},
+		{
+			MethodName: "ListAlerts",
+			Handler:    _ClusterService_ListAlerts_Handler,
+		},
+		{
+			MethodName: "Switchover",
+			Handler:    _ClusterService_Switchover_Handler,
+		},
+		{
+			MethodName: "Failover",
+			Handler:    _ClusterService_Failover_Handler,
+		},
+		{
+			MethodName: "ResetFailoverControl",
+			Handler:    _ClusterService_ResetFailoverControl_Handler,
+		},
+		{
+			MethodName: "SetTraffic",
+			Handler:    _ClusterService_SetTraffic_Handler,
+		},
+		{
+			MethodName: "SwitchSetting",
+			Handler:    _ClusterService_SwitchSetting_Handler,
+		},
+		{
+			MethodName: "SetSetting",
+			Handler:    _ClusterService_SetSetting_Handler,
+		},
+		{
+			MethodName: "ServerAction",
+			Handler:    _ClusterService_ServerAction_Handler,
+		},
+		{
+			MethodName: "ProxyAction",
+			Handler:    _ClusterService_ProxyAction_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _ClusterService_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _ClusterService_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cluster.proto",
+}
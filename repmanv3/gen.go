@@ -0,0 +1,4 @@
+package repmanv3
+
+// protoc-gen-go v1.27.1 and protoc-gen-go-grpc v1.3.0 generate code without generics for the go version of go.mod
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cluster.proto
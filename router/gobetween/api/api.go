@@ -6,10 +6,10 @@
 package api
 
 import (
-	"../config"
-	"../logging"
-	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
 )
 
 /* gin app */
@@ -6,9 +6,9 @@
 package api
 
 import (
-	"../info"
-	"../manager"
 	"github.com/gin-gonic/gin"
+	"github.com/signal18/replication-manager/router/gobetween/info"
+	"github.com/signal18/replication-manager/router/gobetween/manager"
 	"net/http"
 	"os"
 	"time"
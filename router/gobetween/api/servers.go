@@ -6,10 +6,10 @@
 package api
 
 import (
-	"../config"
-	"../manager"
-	"../stats"
 	"github.com/gin-gonic/gin"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/manager"
+	"github.com/signal18/replication-manager/router/gobetween/stats"
 	"net/http"
 )
 
@@ -7,8 +7,8 @@
 package balance
 
 import (
-	"../core"
 	"errors"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 	"hash/fnv"
 )
 
@@ -9,7 +9,7 @@ package balance
 import (
 	"errors"
 
-	"../core"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 )
 
 /**
@@ -12,9 +12,9 @@ import (
 	"regexp"
 	"strings"
 
-	"../../config"
-	"../../core"
-	"../../logging"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
 )
 
 /**
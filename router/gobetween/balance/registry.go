@@ -9,10 +9,10 @@ package balance
 import (
 	"reflect"
 
-	"./middleware"
+	"github.com/signal18/replication-manager/router/gobetween/balance/middleware"
 
-	"../config"
-	"../core"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 )
 
 /**
@@ -10,7 +10,7 @@ import (
 	"errors"
 	"sort"
 
-	"../core"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 )
 
 /**
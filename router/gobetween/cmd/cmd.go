@@ -6,7 +6,7 @@
 package cmd
 
 import (
-	"../config"
+	"github.com/signal18/replication-manager/router/gobetween/config"
 )
 
 /**
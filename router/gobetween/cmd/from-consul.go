@@ -6,10 +6,10 @@
 package cmd
 
 import (
-	"../config"
-	"../info"
-	"../utils/codec"
 	consul "github.com/hashicorp/consul/api"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/info"
+	"github.com/signal18/replication-manager/router/gobetween/utils/codec"
 	"github.com/spf13/cobra"
 	"log"
 )
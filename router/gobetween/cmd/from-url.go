@@ -6,9 +6,9 @@
 package cmd
 
 import (
-	"../config"
-	"../info"
-	"../utils/codec"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/info"
+	"github.com/signal18/replication-manager/router/gobetween/utils/codec"
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	"log"
@@ -6,8 +6,8 @@
 package cmd
 
 import (
-	"../info"
 	"fmt"
+	"github.com/signal18/replication-manager/router/gobetween/info"
 	"github.com/spf13/cobra"
 )
 
@@ -8,7 +8,7 @@
 package core
 
 import (
-	"../config"
+	"github.com/signal18/replication-manager/router/gobetween/config"
 )
 
 /**
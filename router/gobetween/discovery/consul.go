@@ -7,12 +7,12 @@
 package discovery
 
 import (
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
 	"fmt"
 	consul "github.com/hashicorp/consul/api"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 	"net/http"
 	"strings"
 	"time"
@@ -115,7 +115,7 @@ func consulFetch(cfg config.DiscoveryConfig) (*[]core.Backend, error) {
 		} else {
 			host = entry.Node.Address
 		}
-		
+
 		backends = append(backends, core.Backend{
 			Target: core.Target{
 				Host: host,
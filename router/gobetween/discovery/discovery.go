@@ -7,9 +7,11 @@
 package discovery
 
 import (
-	"../config"
-	"../core"
-	"../logging"
+	"errors"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"sync"
 	"time"
 )
 
@@ -19,17 +21,16 @@ import (
 var registry = make(map[string]func(config.DiscoveryConfig) interface{})
 
 /**
- * Initialize type registry
+ * Initialize type registry, json and lxd discoveries register
+ * themselves when built with their tags as they need extra modules
  */
 func init() {
 	registry["static"] = NewStaticDiscovery
 	registry["srv"] = NewSrvDiscovery
 	registry["docker"] = NewDockerDiscovery
-	registry["json"] = NewJsonDiscovery
 	registry["exec"] = NewExecDiscovery
 	registry["plaintext"] = NewPlaintextDiscovery
 	registry["consul"] = NewConsulDiscovery
-	registry["lxd"] = NewLXDDiscovery
 }
 
 /**
@@ -80,6 +81,11 @@ type Discovery struct {
 	 * Channel where to push newly discovered backends
 	 */
 	out chan ([]core.Backend)
+
+	/**
+	 * Serializes fetches and updates of the configuration
+	 */
+	mu sync.Mutex
 }
 
 /**
@@ -99,6 +105,7 @@ func (this *Discovery) Start() {
 
 	go func() {
 		for {
+			this.mu.Lock()
 			backends, err := this.fetch(this.cfg)
 
 			if err != nil {
@@ -110,6 +117,7 @@ func (this *Discovery) Start() {
 					this.backends = &[]core.Backend{}
 					this.out <- *this.backends
 				}
+				this.mu.Unlock()
 
 				time.Sleep(this.opts.RetryWaitDuration)
 				continue
@@ -120,6 +128,7 @@ func (this *Discovery) Start() {
 
 			// out
 			this.out <- *this.backends
+			this.mu.Unlock()
 
 			// exit gorouting if no cacheTtl
 			// used for static discovery
@@ -132,6 +141,32 @@ func (this *Discovery) Start() {
 	}()
 }
 
+/**
+ * Replace the list of a static discovery, the scheduler merges the
+ * backends with the current ones so active connections are kept
+ */
+func (this *Discovery) UpdateStatic(list []string) error {
+
+	if this.cfg.Kind != "static" {
+		return errors.New("Can't update backends of " + this.cfg.Kind + " discovery")
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.cfg.StaticDiscoveryConfig = &config.StaticDiscoveryConfig{StaticList: list}
+
+	backends, err := this.fetch(this.cfg)
+	if err != nil {
+		return err
+	}
+
+	this.backends = backends
+	this.out <- *this.backends
+
+	return nil
+}
+
 /**
  * Stop discovery
  */
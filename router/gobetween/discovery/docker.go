@@ -7,13 +7,13 @@
 package discovery
 
 import (
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
 	"errors"
 	"fmt"
 	"github.com/fsouza/go-dockerclient"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 	"regexp"
 	"time"
 )
@@ -1,3 +1,6 @@
+//go:build gobetween_json
+// +build gobetween_json
+
 /**
  * docker.go - Docker API discovery implementation
  *
@@ -15,13 +18,17 @@ import (
 	"strconv"
 	"time"
 
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
 	"github.com/elgs/gojq"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 )
 
+func init() {
+	registry["json"] = NewJsonDiscovery
+}
+
 const (
 	jsonRetryWaitDuration      = 2 * time.Second
 	jsonDefaultHttpTimeout     = 5 * time.Second
@@ -1,3 +1,6 @@
+//go:build gobetween_lxd
+// +build gobetween_lxd
+
 /**
  * lxd.go - LXD API discovery implementation
  *
@@ -13,10 +16,10 @@ import (
 	"strings"
 	"time"
 
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 
 	lxd "github.com/lxc/lxd/client"
 	lxd_config "github.com/lxc/lxd/lxc/config"
@@ -24,6 +27,10 @@ import (
 	lxd_api "github.com/lxc/lxd/shared/api"
 )
 
+func init() {
+	registry["lxd"] = NewLXDDiscovery
+}
+
 const (
 	lxdRetryWaitDuration = 2 * time.Second
 	lxdTimeout           = 5 * time.Second
@@ -259,9 +266,10 @@ func lxdBuildConfig(cfg config.DiscoveryConfig) (*lxd_config.Config, error) {
 	return config, nil
 }
 
-/**
-* lxdGetRemoteCertificate will attempt to retrieve a remote LXD server's
-  certificate and save it to the servercert's path.
+/*
+*
+  - lxdGetRemoteCertificate will attempt to retrieve a remote LXD server's
+    certificate and save it to the servercert's path.
 */
 func lxdGetRemoteCertificate(config *lxd_config.Config, remote string) error {
 	addr := config.Remotes[remote]
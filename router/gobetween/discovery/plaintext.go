@@ -8,11 +8,11 @@
 package discovery
 
 import (
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
-	"../utils/parsers"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
+	"github.com/signal18/replication-manager/router/gobetween/utils/parsers"
 	"io/ioutil"
 	"net/http"
 	"strings"
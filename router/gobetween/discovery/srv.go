@@ -12,11 +12,11 @@ import (
 	"strings"
 	"time"
 
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
 	"github.com/miekg/dns"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 )
 
 const (
@@ -12,12 +12,12 @@ import (
 	"runtime"
 	"time"
 
-	"./api"
-	"./config"
-	"./info"
-	"./logging"
-	"./manager"
-	"./utils/codec"
+	"github.com/signal18/replication-manager/router/gobetween/api"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/info"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/manager"
+	"github.com/signal18/replication-manager/router/gobetween/utils/codec"
 )
 
 /**
@@ -47,7 +47,7 @@ func init() {
 /**
  * Entry point
  */
-func Run(string path) {
+func Run(path string) {
 
 	log.Printf("gobetween v%s", version)
 
@@ -64,10 +64,10 @@ func Run(string path) {
 	logging.Configure(cfg.Logging.Output, cfg.Logging.Level)
 
 	// Start API
-	go api.Start((*cfg).Api)
+	go api.Start(cfg.Api)
 
 	// Start manager
-	go manager.Initialize(*cfg)
+	go manager.Initialize(cfg)
 
 	// block forever
 	<-(chan string)(nil)
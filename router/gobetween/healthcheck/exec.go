@@ -7,10 +7,10 @@
 package healthcheck
 
 import (
-	"../config"
-	"../core"
-	"../logging"
-	"../utils"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 	"time"
 )
 
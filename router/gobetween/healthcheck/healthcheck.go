@@ -7,8 +7,8 @@
 package healthcheck
 
 import (
-	"../config"
-	"../core"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 )
 
 /**
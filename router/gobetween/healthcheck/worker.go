@@ -7,9 +7,9 @@
 package healthcheck
 
 import (
-	"../config"
-	"../core"
-	"../logging"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
 	"time"
 )
 
@@ -12,12 +12,12 @@ import (
 	"sync"
 	"time"
 
-	"../config"
-	"../core"
-	"../logging"
-	"../server"
-	"../service"
-	"../utils/codec"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/server"
+	"github.com/signal18/replication-manager/router/gobetween/service"
+	"github.com/signal18/replication-manager/router/gobetween/utils/codec"
 )
 
 /* Map of app current servers */
@@ -198,6 +198,30 @@ func Create(name string, cfg config.Server) error {
 	return nil
 }
 
+/**
+ * Update the backends of a server with static discovery, active
+ * connections are kept
+ */
+func UpdateStaticBackends(name string, list []string) error {
+
+	servers.Lock()
+	defer servers.Unlock()
+
+	server, ok := servers.m[name]
+	if !ok {
+		return errors.New("Server not found")
+	}
+
+	updater, ok := server.(interface {
+		UpdateStaticBackends([]string) error
+	})
+	if !ok {
+		return errors.New("Server can't update backends: " + name)
+	}
+
+	return updater.UpdateStaticBackends(list)
+}
+
 /**
  * Delete server stopping all active connections
  */
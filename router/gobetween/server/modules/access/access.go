@@ -7,8 +7,8 @@
 package access
 
 import (
-	"../../../config"
 	"errors"
+	"github.com/signal18/replication-manager/router/gobetween/config"
 	"net"
 )
 
@@ -9,12 +9,12 @@ package scheduler
 import (
 	"time"
 
-	"../../core"
-	"../../discovery"
-	"../../healthcheck"
-	"../../logging"
-	"../../stats"
-	"../../stats/counters"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/discovery"
+	"github.com/signal18/replication-manager/router/gobetween/healthcheck"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/stats"
+	"github.com/signal18/replication-manager/router/gobetween/stats/counters"
 )
 
 /**
@@ -8,11 +8,11 @@
 package server
 
 import (
-	"../config"
-	"../core"
-	"./tcp"
-	"./udp"
 	"errors"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/server/tcp"
+	"github.com/signal18/replication-manager/router/gobetween/server/udp"
 )
 
 /**
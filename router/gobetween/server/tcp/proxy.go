@@ -7,8 +7,8 @@
 package tcp
 
 import (
-	"../../core"
-	"../../logging"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
 	"io"
 	"net"
 	"time"
@@ -11,19 +11,19 @@ import (
 	"net"
 	"time"
 
-	"../../balance"
-	"../../config"
-	"../../core"
-	"../../discovery"
-	"../../healthcheck"
-	"../../logging"
-	"../../stats"
-	"../../utils"
-	"../../utils/proxyprotocol"
-	tlsutil "../../utils/tls"
-	"../../utils/tls/sni"
-	"../modules/access"
-	"../scheduler"
+	"github.com/signal18/replication-manager/router/gobetween/balance"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/discovery"
+	"github.com/signal18/replication-manager/router/gobetween/healthcheck"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/server/modules/access"
+	"github.com/signal18/replication-manager/router/gobetween/server/scheduler"
+	"github.com/signal18/replication-manager/router/gobetween/stats"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
+	"github.com/signal18/replication-manager/router/gobetween/utils/proxyprotocol"
+	tlsutil "github.com/signal18/replication-manager/router/gobetween/utils/tls"
+	"github.com/signal18/replication-manager/router/gobetween/utils/tls/sni"
 )
 
 /**
@@ -135,6 +135,22 @@ func (this *Server) Cfg() config.Server {
 	return this.cfg
 }
 
+/**
+ * Update the backends of a static discovery without restarting the server
+ */
+func (this *Server) UpdateStaticBackends(list []string) error {
+
+	if err := this.scheduler.Discovery.UpdateStatic(list); err != nil {
+		return err
+	}
+
+	discovery := *this.cfg.Discovery
+	discovery.StaticDiscoveryConfig = &config.StaticDiscoveryConfig{StaticList: list}
+	this.cfg.Discovery = &discovery
+
+	return nil
+}
+
 /**
  * Start server
  */
@@ -13,17 +13,17 @@ import (
 	"sync/atomic"
 	"time"
 
-	"../../balance"
-	"../../config"
-	"../../core"
-	"../../discovery"
-	"../../healthcheck"
-	"../../logging"
-	"../../stats"
-	"../../utils"
-	"../modules/access"
-	"../scheduler"
-	"./session"
+	"github.com/signal18/replication-manager/router/gobetween/balance"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/discovery"
+	"github.com/signal18/replication-manager/router/gobetween/healthcheck"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/server/modules/access"
+	"github.com/signal18/replication-manager/router/gobetween/server/scheduler"
+	"github.com/signal18/replication-manager/router/gobetween/server/udp/session"
+	"github.com/signal18/replication-manager/router/gobetween/stats"
+	"github.com/signal18/replication-manager/router/gobetween/utils"
 )
 
 const UDP_PACKET_SIZE = 65507
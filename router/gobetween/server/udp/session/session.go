@@ -1,10 +1,10 @@
 package session
 
 import (
-	"../../../core"
-	"../../../logging"
-	"../../scheduler"
 	"fmt"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
+	"github.com/signal18/replication-manager/router/gobetween/server/scheduler"
 	"net"
 	"sync/atomic"
 	"time"
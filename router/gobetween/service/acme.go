@@ -1,11 +1,11 @@
 package service
 
 import (
-	"../config"
-	"../core"
-	"../server/tcp"
 	"context"
 	"fmt"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/server/tcp"
 	"golang.org/x/crypto/acme/autocert"
 	"net/http"
 	"sync"
@@ -1,9 +1,9 @@
 package service
 
 import (
-	"../config"
-	"../core"
-	"../logging"
+	"github.com/signal18/replication-manager/router/gobetween/config"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
 )
 
 /**
@@ -7,7 +7,7 @@
 package counters
 
 import (
-	"../../core"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 	"time"
 )
 
@@ -7,8 +7,8 @@
 package stats
 
 import (
-	"../core"
-	"./counters"
+	"github.com/signal18/replication-manager/router/gobetween/core"
+	"github.com/signal18/replication-manager/router/gobetween/stats/counters"
 	"time"
 )
 
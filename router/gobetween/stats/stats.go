@@ -7,7 +7,7 @@
 package stats
 
 import (
-	"../core"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 )
 
 /**
@@ -8,7 +8,7 @@
 package utils
 
 import (
-	"../logging"
+	"github.com/signal18/replication-manager/router/gobetween/logging"
 	"os/exec"
 	"time"
 )
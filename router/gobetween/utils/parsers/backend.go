@@ -8,8 +8,8 @@
 package parsers
 
 import (
-	"../../core"
 	"errors"
+	"github.com/signal18/replication-manager/router/gobetween/core"
 	"regexp"
 	"strconv"
 	"strings"
@@ -28,8 +28,8 @@ func addrToIPAndPort(addr net.Addr) (ip net.IP, port uint16, err error) {
 	return
 }
 
-/// SendProxyProtocolV1 sends a proxy protocol v1 header to initialize the connection
-/// https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
+// / SendProxyProtocolV1 sends a proxy protocol v1 header to initialize the connection
+// / https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
 func SendProxyProtocolV1(client net.Conn, backend net.Conn) error {
 	sourceIP, sourcePort, err := addrToIPAndPort(client.RemoteAddr())
 	if err != nil {
@@ -11,7 +11,7 @@ import (
 	"crypto/x509"
 	"io/ioutil"
 
-	"../../config"
+	"github.com/signal18/replication-manager/router/gobetween/config"
 )
 
 /**
@@ -1,111 +1,635 @@
 package myproxy
 
 import (
+	"encoding/binary"
 	"fmt"
-	"log"
+	"sync/atomic"
+	"time"
 
-	"database/sql"
-
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/siddontang/go-mysql/client"
 	. "github.com/siddontang/go-mysql/mysql"
-	"github.com/xwb1989/sqlparser"
+	siddon "github.com/siddontang/go-mysql/server"
 )
 
+// MysqlHandler is the state of a client session. The client is authenticated by the proxy, then
+// the packets of its commands and of the responses are relayed unchanged between the client and
+// the backend the command is routed to, only statement ids are translated. The session owns a
+// connection to the writer and an optional one to a reader, opened on first use.
 type MysqlHandler struct {
-	db      *sql.DB
-	verbose bool
+	siddon.EmptyHandler
+	server *Server
+	client *siddon.Conn
+	writer *backend
+	reader *backend
+	// connection of the previous statement for SHOW WARNINGS
+	last *backend
+	// the response of the previous statement was not an error
+	lastOK bool
+	db     string
+	// session statements replayed on every new backend connection
+	vars *sessionVars
+	// prepared statements by the id given to the client
+	stmts    map[uint32]*stmtContext
+	nextStmt uint32
+	// a response packet was written to the client for the current command
+	relayed bool
 }
 
-func (h MysqlHandler) UseDB(dbName string) error {
-	//log.Printf("use %s \n", dbName)
-	_, err := h.SelectDB("use " + dbName)
-	if err != nil {
-		log.Printf("Error in use %s \n", err)
+// backend is a connection to a database and the status flags of its last response
+type backend struct {
+	*client.Conn
+	addr   string
+	status uint16
+}
+
+// stmtContext is a statement prepared by the client, it is prepared on each backend it runs on
+type stmtContext struct {
+	query  string
+	read   bool
+	params int
+	// statement id and bound parameter types on each backend
+	ids   map[*backend]uint32
+	bound map[*backend]bool
+	// parameter types of the last execute binding them
+	types []byte
+}
+
+// clientError is a failure of the client connection, the session ends
+type clientError struct {
+	err error
+}
+
+func (e *clientError) Error() string {
+	return e.err.Error()
+}
+
+// errPacket is an error response of a backend relayed to the client
+type errPacket []byte
+
+func (e errPacket) Error() string {
+	if len(e) > 9 {
+		return string(e[9:])
 	}
+	return "Backend error"
+}
+
+func newMysqlHandler(s *Server) *MysqlHandler {
+	return &MysqlHandler{server: s, vars: newSessionVars(), stmts: make(map[uint32]*stmtContext)}
+}
 
+// UseDB keeps the database sent by the client during the handshake
+func (h *MysqlHandler) UseDB(dbName string) error {
+	h.db = dbName
 	return nil
 }
 
-func (h MysqlHandler) HandleOtherCommand(cmd byte, data []byte) error {
+// Serve relays the commands of the client until it quits or a connection fails
+func (h *MysqlHandler) Serve(c *siddon.Conn) error {
+	h.client = c
+	for {
+		c.ResetSequence()
+		data, err := c.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return errors.New("Empty command packet")
+		}
+		if data[0] == COM_QUIT {
+			return nil
+		}
+		if err := h.dispatch(data); err != nil {
+			return err
+		}
+	}
+}
 
-	log.Printf("Other command %d is not supported now ", cmd)
-	return fmt.Errorf("Other command %d is not supported now ", cmd)
+func (h *MysqlHandler) dispatch(data []byte) error {
+	switch data[0] {
+	case COM_QUERY:
+		return h.handleQuery(data)
+	case COM_INIT_DB:
+		if err := h.forward(false, command(data), h.relayResult); err != nil || !h.lastOK {
+			return err
+		}
+		h.db = string(data[1:])
+		h.closeReader()
+		return nil
+	case COM_PING:
+		return h.forward(false, command(data), h.relayResult)
+	case COM_FIELD_LIST:
+		return h.forward(false, command(data), h.relayUntilEOF)
+	case COM_STATISTICS, COM_SET_OPTION:
+		return h.forward(false, command(data), h.relayPacket)
+	case COM_RESET_CONNECTION:
+		h.vars = newSessionVars()
+		h.closeReader()
+		return h.forward(false, command(data), h.relayResult)
+	case COM_STMT_PREPARE:
+		return h.handleStmtPrepare(data)
+	case COM_STMT_EXECUTE, COM_STMT_SEND_LONG_DATA, COM_STMT_RESET, COM_STMT_FETCH, COM_STMT_CLOSE:
+		return h.handleStmt(data)
+	}
+	return h.writeError(fmt.Errorf("Command %d is not supported by the proxy", data[0]))
 }
 
-// HandleQuery is response to process select/insert/update/delete statement
-func (h MysqlHandler) HandleQuery(queryStr string) (*Result, error) {
-	// 1. parse
-	statement, err := sqlparser.Parse(queryStr)
-	if err != nil {
-		log.Printf("HandleQuery: %s, err=%v \n", queryStr, err)
-		return nil, err
+// handleQuery routes a text query, reads outside of a transaction go to a reader, everything
+// else to the writer
+func (h *MysqlHandler) handleQuery(data []byte) error {
+	query := string(data[1:])
+	switch getQueryType(query) {
+	case queryRead:
+		return h.forward(true, command(data), h.relayResult)
+	case queryLast:
+		if h.last != nil {
+			return h.forwardTo(h.last, command(data), h.relayResult)
+		}
+	case querySet:
+		if err := h.forward(false, command(data), h.relayResult); err != nil || !h.lastOK {
+			return err
+		}
+		h.vars.set(query)
+		if h.reader != nil {
+			if _, err := h.reader.Execute(query); err != nil {
+				h.closeReader()
+			}
+		}
+		return nil
+	case queryUse:
+		if err := h.forward(false, command(data), h.relayResult); err != nil || !h.lastOK {
+			return err
+		}
+		h.db = getUseDB(query)
+		h.closeReader()
+		return nil
 	}
+	return h.forward(false, command(data), h.relayResult)
+}
 
-	var ok bool
-	var query *sqlparser.Select
-	var insert *sqlparser.Insert
-	var update *sqlparser.Update
-	var delete *sqlparser.Delete
+// handleStmtPrepare prepares the statement on the backend it is routed to and gives the client
+// an id of the proxy
+func (h *MysqlHandler) handleStmtPrepare(data []byte) error {
+	query := string(data[1:])
+	h.nextStmt++
+	sc := &stmtContext{query: query, read: getQueryType(query) == queryRead, ids: make(map[*backend]uint32), bound: make(map[*backend]bool)}
+	id := h.nextStmt
+	err := h.forward(sc.read, command(data), func(b *backend) error {
+		pkt, err := b.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if pkt[0] != OK_HEADER || len(pkt) < 12 {
+			h.lastOK = false
+			return h.toClient(pkt)
+		}
+		sc.ids[b] = binary.LittleEndian.Uint32(pkt[1:5])
+		columns := int(binary.LittleEndian.Uint16(pkt[5:7]))
+		sc.params = int(binary.LittleEndian.Uint16(pkt[7:9]))
+		h.stmts[id] = sc
+		h.lastOK = true
+		binary.LittleEndian.PutUint32(pkt[1:5], id)
+		if err := h.toClient(pkt); err != nil {
+			return err
+		}
+		return h.relayDefinitions(b, sc.params, columns, true)
+	})
+	return err
+}
 
-	var result *Result
+// handleStmt runs a command of a prepared statement on the backend of the session, the statement
+// is prepared again when the session has moved to another backend
+func (h *MysqlHandler) handleStmt(data []byte) error {
+	if len(data) < 5 {
+		return h.writeError(errors.New("Malformed statement command"))
+	}
+	id := binary.LittleEndian.Uint32(data[1:5])
+	sc, ok := h.stmts[id]
+	if data[0] == COM_STMT_CLOSE {
+		if ok {
+			h.closeStmt(sc)
+			delete(h.stmts, id)
+		}
+		return nil
+	}
+	if !ok {
+		if data[0] == COM_STMT_SEND_LONG_DATA {
+			return nil
+		}
+		return h.writeError(fmt.Errorf("Unknown prepared statement handler (%d)", id))
+	}
+	cmd := func(b *backend) ([]byte, error) {
+		bid, err := h.prepareOn(sc, b)
+		if err != nil {
+			return nil, err
+		}
+		pkt := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(pkt[1:5], bid)
+		if pkt[0] == COM_STMT_EXECUTE {
+			return sc.bindTypes(b, pkt), nil
+		}
+		return pkt, nil
+	}
+	switch data[0] {
+	case COM_STMT_EXECUTE, COM_STMT_RESET:
+		return h.forward(sc.read, cmd, h.relayResult)
+	case COM_STMT_FETCH:
+		return h.forward(sc.read, cmd, h.relayUntilEOF)
+	}
+	// long data has no response
+	return h.forward(sc.read, cmd, nil)
+}
 
-	// 2. convert type to Select/Insert/Upadte/Delete
-	switch statement.(type) {
-	case *sqlparser.Select:
-		query, ok = statement.(*sqlparser.Select)
-		if !ok {
-			return nil, fmt.Errorf("convert to select sql failed. sql=%s \n", query)
+// prepareOn returns the id of the statement on the backend, preparing it when needed
+func (h *MysqlHandler) prepareOn(sc *stmtContext, b *backend) (uint32, error) {
+	if id, ok := sc.ids[b]; ok {
+		return id, nil
+	}
+	b.ResetSequence()
+	if err := b.WritePacket(packet(append([]byte{COM_STMT_PREPARE}, sc.query...))); err != nil {
+		return 0, err
+	}
+	pkt, err := b.ReadPacket()
+	if err != nil {
+		return 0, err
+	}
+	if pkt[0] != OK_HEADER || len(pkt) < 12 {
+		return 0, errPacket(pkt)
+	}
+	id := binary.LittleEndian.Uint32(pkt[1:5])
+	columns := int(binary.LittleEndian.Uint16(pkt[5:7]))
+	params := int(binary.LittleEndian.Uint16(pkt[7:9]))
+	if err := h.relayDefinitions(b, params, columns, false); err != nil {
+		return 0, err
+	}
+	sc.ids[b] = id
+	return id, nil
+}
+
+// closeStmt closes the statement on the backends it was prepared on that are still open
+func (h *MysqlHandler) closeStmt(sc *stmtContext) {
+	for b, id := range sc.ids {
+		if b != h.writer && b != h.reader {
+			continue
+		}
+		b.ResetSequence()
+		pkt := []byte{COM_STMT_CLOSE, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(pkt[1:5], id)
+		if err := b.WritePacket(packet(pkt)); err != nil {
+			h.resetConn(b)
 		}
-		result, err = h.handleSelect(query)
+	}
+}
 
-	case *sqlparser.Insert:
-		insert, ok = statement.(*sqlparser.Insert)
-		if !ok {
-			return nil, fmt.Errorf("convert to insert sql failed. sql=%s \n", insert)
+// bindTypes keeps the parameter types sent by the client and sends them again on the first
+// execute on a backend where the statement was prepared again, as clients only bind them once
+func (sc *stmtContext) bindTypes(b *backend, pkt []byte) []byte {
+	if sc.params == 0 {
+		return pkt
+	}
+	pos := 10 + (sc.params+7)/8
+	if len(pkt) <= pos {
+		return pkt
+	}
+	if pkt[pos] == 1 {
+		if len(pkt) >= pos+1+2*sc.params {
+			sc.types = append([]byte(nil), pkt[pos+1:pos+1+2*sc.params]...)
 		}
-		result, err = h.handleInsert(insert)
+		sc.bound[b] = true
+		return pkt
+	}
+	if sc.bound[b] || sc.types == nil {
+		return pkt
+	}
+	sc.bound[b] = true
+	res := append([]byte(nil), pkt[:pos]...)
+	res = append(res, 1)
+	res = append(res, sc.types...)
+	return append(res, pkt[pos+1:]...)
+}
+
+// command returns a builder of a command sent unchanged to any backend
+func command(data []byte) func(*backend) ([]byte, error) {
+	return func(*backend) ([]byte, error) {
+		return data, nil
+	}
+}
+
+// packet prepends the room of the header written by WritePacket
+func packet(payload []byte) []byte {
+	return append(make([]byte, 4, 4+len(payload)), payload...)
+}
+
+func (h *MysqlHandler) toClient(pkt []byte) error {
+	h.relayed = true
+	if err := h.client.WritePacket(packet(pkt)); err != nil {
+		return &clientError{err}
+	}
+	return nil
+}
+
+// writeError sends an error to the client, errors of the backends are relayed as is
+func (h *MysqlHandler) writeError(err error) error {
+	if pkt, ok := errors.Cause(err).(errPacket); ok {
+		return h.toClient(pkt)
+	}
+	code, state, msg := uint16(ER_UNKNOWN_ERROR), "HY000", err.Error()
+	if e, ok := errors.Cause(err).(*MyError); ok {
+		code, state, msg = e.Code, e.State, e.Message
+	}
+	pkt := []byte{ERR_HEADER, byte(code), byte(code >> 8), '#'}
+	pkt = append(pkt, state...)
+	return h.toClient(append(pkt, msg...))
+}
 
-	case *sqlparser.Update:
-		update, ok = statement.(*sqlparser.Update)
-		if !ok {
-			return nil, fmt.Errorf("convert to update sql failed. sql=%s \n", update)
+func (h *MysqlHandler) connect(addr string) (*backend, error) {
+	if addr == "" {
+		return nil, errNoBackend
+	}
+	conn, err := client.Connect(addr, h.server.backendUser, h.server.backendPassword, h.db)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range h.vars.statements() {
+		if _, err := conn.Execute(v); err != nil {
+			conn.Close()
+			return nil, err
 		}
-		result, err = h.handleUpdate(update)
+	}
+	b := &backend{Conn: conn, addr: addr}
+	if conn.IsAutoCommit() {
+		b.status |= SERVER_STATUS_AUTOCOMMIT
+	}
+	if conn.IsInTransaction() {
+		b.status |= SERVER_STATUS_IN_TRANS
+	}
+	atomic.AddInt64(&h.server.getStats(addr).Connections, 1)
+	return b, nil
+}
+
+func (h *MysqlHandler) closeConn(b *backend) {
+	if b == nil {
+		return
+	}
+	b.Close()
+	atomic.AddInt64(&h.server.getStats(b.addr).Connections, -1)
+	if h.last == b {
+		h.last = nil
+	}
+	// statements die with the connection
+	for _, sc := range h.stmts {
+		delete(sc.ids, b)
+		delete(sc.bound, b)
+	}
+}
+
+func (h *MysqlHandler) closeWriter() {
+	h.closeConn(h.writer)
+	h.writer = nil
+}
 
-	case *sqlparser.Delete:
-		delete, ok = statement.(*sqlparser.Delete)
-		if !ok {
-			return nil, fmt.Errorf("convert to delete sql failed. sql=%s \n", delete)
+func (h *MysqlHandler) closeReader() {
+	h.closeConn(h.reader)
+	h.reader = nil
+}
+
+// Close releases the backend connections of the session
+func (h *MysqlHandler) Close() {
+	h.closeWriter()
+	h.closeReader()
+}
+
+// inTransaction uses the status flags of the last writer response
+func (h *MysqlHandler) inTransaction() bool {
+	return h.writer != nil && (h.writer.status&SERVER_STATUS_IN_TRANS != 0 || h.writer.status&SERVER_STATUS_AUTOCOMMIT == 0)
+}
+
+// getWriter returns the writer connection, the session moves to a new writer after a failover
+// unless a transaction is open on the old one, the transaction is then lost
+func (h *MysqlHandler) getWriter() (*backend, error) {
+	addr := h.server.GetWriter()
+	if h.writer != nil && h.writer.addr != addr {
+		old := h.writer.addr
+		trx := h.inTransaction()
+		h.closeWriter()
+		if trx {
+			return nil, fmt.Errorf("Transaction aborted, writer switched from %s to %s", old, addr)
+		}
+	}
+	if h.writer == nil {
+		b, err := h.connect(addr)
+		if err != nil {
+			return nil, err
 		}
-		result, err = h.handleDelete(delete)
+		h.writer = b
+	}
+	return h.writer, nil
+}
 
-	case *sqlparser.Show:
-		query, ok = statement.(*sqlparser.Select)
-		if !ok {
-			return nil, fmt.Errorf("convert to select sql failed. sql=%s \n", query)
+// getReader returns the reader connection, a reader removed from the pool is replaced
+func (h *MysqlHandler) getReader() (*backend, error) {
+	if h.reader != nil && !h.server.IsReader(h.reader.addr) {
+		h.closeReader()
+	}
+	if h.reader == nil {
+		addr := h.server.GetReader()
+		if addr == h.server.GetWriter() {
+			return h.getWriter()
 		}
-		result, err = h.handleSelect(query)
+		b, err := h.connect(addr)
+		if err != nil {
+			return nil, err
+		}
+		h.reader = b
+	}
+	return h.reader, nil
+}
 
-	default:
+func (h *MysqlHandler) getConn(read bool) (*backend, error) {
+	if read && !h.inTransaction() {
+		return h.getReader()
+	}
+	return h.getWriter()
+}
 
+// resetConn drops a failed backend connection so the next try opens a new one
+func (h *MysqlHandler) resetConn(b *backend) {
+	if b == h.reader {
+		h.closeReader()
+	}
+	if b == h.writer {
+		h.closeWriter()
 	}
+}
 
-	return result, err
+// forward sends the command to the backend of the statement and relays the response, autocommit
+// reads are retried on network errors until the retry timeout to hide a failover or a reader
+// leaving the pool, as long as nothing was relayed to the client
+func (h *MysqlHandler) forward(read bool, cmd func(*backend) ([]byte, error), relay func(*backend) error) error {
+	retry := read && !h.inTransaction()
+	start := time.Now()
+	for {
+		b, err := h.getConn(read)
+		if err == nil {
+			err = h.forwardOnce(b, cmd, relay)
+			if err == nil {
+				return nil
+			}
+			if _, ok := err.(*clientError); ok || h.relayed {
+				return err
+			}
+			if _, ok := errors.Cause(err).(errPacket); ok {
+				return h.writeError(err)
+			}
+		}
+		if !retry || time.Since(start) > h.server.RetryTimeout {
+			return h.writeError(err)
+		}
+		h.server.logPrintf(lvlDbg, "Retry read after %s", err)
+		time.Sleep(500 * time.Millisecond)
+	}
 }
 
-func (h MysqlHandler) HandleFieldList(table string, fieldWildcard string) ([]*Field, error) {
-	return nil, fmt.Errorf("HandleFieldList: not supported now")
+// forwardTo runs the command on a given backend without retry
+func (h *MysqlHandler) forwardTo(b *backend, cmd func(*backend) ([]byte, error), relay func(*backend) error) error {
+	err := h.forwardOnce(b, cmd, relay)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*clientError); ok || h.relayed {
+		return err
+	}
+	return h.writeError(err)
+}
+
+func (h *MysqlHandler) forwardOnce(b *backend, cmd func(*backend) ([]byte, error), relay func(*backend) error) error {
+	h.relayed = false
+	st := h.server.getStats(b.addr)
+	data, err := cmd(b)
+	if err == nil {
+		b.ResetSequence()
+		err = b.WritePacket(packet(data))
+		if err == nil && relay != nil {
+			err = relay(b)
+		}
+	}
+	atomic.AddInt64(&st.Queries, 1)
+	if err == nil {
+		h.last = b
+		return nil
+	}
+	if _, ok := err.(*clientError); ok {
+		return err
+	}
+	if _, ok := errors.Cause(err).(errPacket); ok {
+		h.last = b
+		return err
+	}
+	atomic.AddInt64(&st.Errors, 1)
+	h.resetConn(b)
+	return err
+}
+
+// relayPacket relays a response of a single packet
+func (h *MysqlHandler) relayPacket(b *backend) error {
+	pkt, err := b.ReadPacket()
+	if err != nil {
+		return err
+	}
+	h.lastOK = pkt[0] != ERR_HEADER
+	return h.toClient(pkt)
 }
 
-func (h MysqlHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
-	return 0, 0, nil, fmt.Errorf("HandleStmtPrepare: not supported now")
+// relayResult relays an OK, an error or a result set, and the next results when the backend
+// announces more
+func (h *MysqlHandler) relayResult(b *backend) error {
+	for {
+		pkt, err := b.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if err := h.toClient(pkt); err != nil {
+			return err
+		}
+		switch pkt[0] {
+		case ERR_HEADER:
+			h.lastOK = false
+			return nil
+		case OK_HEADER:
+			h.lastOK = true
+			b.status = okStatus(pkt)
+		default:
+			// column count, definitions and rows, the rows of a cursor are fetched later
+			if err := h.relayUntilEOF(b); err != nil {
+				return err
+			}
+			if h.lastOK && b.status&SERVER_STATUS_CURSOR_EXISTS == 0 {
+				if err := h.relayUntilEOF(b); err != nil {
+					return err
+				}
+			}
+		}
+		if b.status&SERVER_MORE_RESULTS_EXISTS == 0 || !h.lastOK {
+			return nil
+		}
+	}
 }
 
-func (h MysqlHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*Result, error) {
-	return nil, fmt.Errorf("HandleStmtExecute: not supported now")
+// relayUntilEOF relays column definitions or rows until the EOF or the error ending them
+func (h *MysqlHandler) relayUntilEOF(b *backend) error {
+	for {
+		pkt, err := b.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if err := h.toClient(pkt); err != nil {
+			return err
+		}
+		if pkt[0] == ERR_HEADER {
+			h.lastOK = false
+			return nil
+		}
+		if pkt[0] == EOF_HEADER && len(pkt) < 9 {
+			h.lastOK = true
+			if len(pkt) >= 5 {
+				b.status = binary.LittleEndian.Uint16(pkt[3:5])
+			}
+			return nil
+		}
+	}
 }
 
-func (h MysqlHandler) HandleStmtClose(context interface{}) error {
-	log.Printf("HandleStmtClose\n")
+// relayDefinitions relays or skips the parameter and column definitions of a prepared statement
+func (h *MysqlHandler) relayDefinitions(b *backend, params int, columns int, relay bool) error {
+	for _, n := range []int{params, columns} {
+		if n == 0 {
+			continue
+		}
+		// definitions and their EOF
+		for i := 0; i <= n; i++ {
+			pkt, err := b.ReadPacket()
+			if err != nil {
+				return err
+			}
+			if relay {
+				if err := h.toClient(pkt); err != nil {
+					return err
+				}
+			}
+		}
+	}
 	return nil
 }
+
+// okStatus returns the status flags of an OK packet
+func okStatus(pkt []byte) uint16 {
+	pos := 1
+	for i := 0; i < 2; i++ {
+		_, _, n := LengthEncodedInt(pkt[pos:])
+		pos += n
+	}
+	if len(pkt) < pos+2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(pkt[pos : pos+2])
+}
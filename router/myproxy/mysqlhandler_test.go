@@ -0,0 +1,253 @@
+package myproxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/client"
+	. "github.com/siddontang/go-mysql/mysql"
+	siddon "github.com/siddontang/go-mysql/server"
+)
+
+// fakeBackend answers its name to reads and records the statements it receives
+type fakeBackend struct {
+	siddon.EmptyHandler
+	name    string
+	mu      sync.Mutex
+	queries []string
+	closed  int
+}
+
+func (b *fakeBackend) record(q string) {
+	b.mu.Lock()
+	b.queries = append(b.queries, q)
+	b.mu.Unlock()
+}
+
+func (b *fakeBackend) getQueries() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.queries...)
+}
+
+func (b *fakeBackend) UseDB(dbName string) error {
+	return nil
+}
+
+func (b *fakeBackend) HandleQuery(query string) (*Result, error) {
+	b.record(query)
+	lq := strings.ToLower(query)
+	switch {
+	case strings.HasPrefix(lq, "select"):
+		rs, err := BuildSimpleTextResultset([]string{"backend"}, [][]interface{}{{b.name}})
+		return &Result{Status: SERVER_STATUS_AUTOCOMMIT, Resultset: rs}, err
+	case lq == "begin":
+		return &Result{Status: SERVER_STATUS_AUTOCOMMIT | SERVER_STATUS_IN_TRANS}, nil
+	}
+	return &Result{Status: SERVER_STATUS_AUTOCOMMIT}, nil
+}
+
+func (b *fakeBackend) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	b.record("PREPARE " + query)
+	return 1, 1, query, nil
+}
+
+func (b *fakeBackend) HandleStmtExecute(context interface{}, query string, args []interface{}) (*Result, error) {
+	b.record("EXECUTE " + query)
+	rs, err := BuildSimpleBinaryResultset([]string{"backend"}, [][]interface{}{{b.name}})
+	return &Result{Status: SERVER_STATUS_AUTOCOMMIT, Resultset: rs}, err
+}
+
+func (b *fakeBackend) HandleStmtClose(context interface{}) error {
+	b.mu.Lock()
+	b.closed++
+	b.mu.Unlock()
+	return nil
+}
+
+func startFakeBackend(t *testing.T, name string) (*fakeBackend, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	b := &fakeBackend{name: name}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				c, err := siddon.NewConn(conn, "repl", "pass", b)
+				if err != nil {
+					return
+				}
+				for c.HandleCommand() == nil {
+				}
+			}()
+		}
+	}()
+	return b, l.Addr().String()
+}
+
+func startProxy(t *testing.T, writer string, readers []string) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	s, _ := NewProxyServer(addr, "app", "secret", "repl", "pass")
+	s.RetryTimeout = time.Second
+	s.SetBackends(writer, readers)
+	go s.Run()
+	t.Cleanup(s.Close)
+	for i := 0; i < 50 && !s.IsRunning(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return s, addr
+}
+
+func queryBackend(t *testing.T, conn *client.Conn, query string) string {
+	r, err := conn.Execute(query)
+	if err != nil {
+		t.Fatal(query, err)
+	}
+	name, err := r.GetString(0, 0)
+	if err != nil {
+		t.Fatal(query, err)
+	}
+	return name
+}
+
+func TestProxyPassthrough(t *testing.T) {
+	w, waddr := startFakeBackend(t, "w")
+	_, r1addr := startFakeBackend(t, "r1")
+	r2, r2addr := startFakeBackend(t, "r2")
+	s, addr := startProxy(t, waddr, []string{r1addr})
+
+	conn, err := client.Connect(addr, "app", "secret", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if n := queryBackend(t, conn, "SELECT 1"); n != "r1" {
+		t.Errorf("Expected a read on the reader got %s", n)
+	}
+	if _, err := conn.Execute("INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Execute("BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryBackend(t, conn, "SELECT 1"); n != "w" {
+		t.Errorf("Expected a read in a transaction on the writer got %s", n)
+	}
+	if _, err := conn.Execute("COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryBackend(t, conn, "SELECT 1"); n != "r1" {
+		t.Errorf("Expected a read after commit on the reader got %s", n)
+	}
+
+	// a variable set again is replayed once with its last value
+	for _, q := range []string{"SET @@session.sql_mode='A'", "SET NAMES latin1", "SET sql_mode='B', NAMES utf8mb4"} {
+		if _, err := conn.Execute(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.SetBackends(waddr, []string{r2addr})
+	if n := queryBackend(t, conn, "SELECT 1"); n != "r2" {
+		t.Errorf("Expected a read on the new reader got %s", n)
+	}
+	if q := strings.Join(r2.getQueries(), ";"); q != "SET sql_mode='B';SET NAMES utf8mb4;SELECT 1" {
+		t.Errorf("Unexpected session replay %s", q)
+	}
+
+	// a statement is prepared again on the writer once no reader is left and closed on both
+	stmt, err := conn.Prepare("SELECT ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := stmt.Execute(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := r.GetString(0, 0); n != "r2" {
+		t.Errorf("Expected the statement on the reader got %s", n)
+	}
+	s.SetBackends(waddr, nil)
+	r, err = stmt.Execute(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := r.GetString(0, 0); n != "w" {
+		t.Errorf("Expected the statement on the writer got %s", n)
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the close has no response, a query makes sure it was processed
+	conn.Execute("DO 1")
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed != 1 {
+		t.Errorf("Expected the statement prepared again to be closed on the writer, got %d closes", closed)
+	}
+	stats := s.GetBackendStats()
+	if stats[r2addr].Connections != 0 || stats[waddr].Connections != 1 {
+		t.Errorf("Unexpected backend connections %+v", stats)
+	}
+}
+
+func TestProxyNoBackend(t *testing.T) {
+	_, addr := startProxy(t, "", nil)
+	conn, err := client.Connect(addr, "app", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Execute("SELECT 1"); err == nil || !strings.Contains(err.Error(), errNoBackend.Error()) {
+		t.Errorf("Expected no backend error got %v", err)
+	}
+	// the session is still usable
+	if _, err := conn.Execute("SELECT 1"); err == nil {
+		t.Error("Expected no backend error again")
+	}
+}
+
+func TestBindTypes(t *testing.T) {
+	w, r := &backend{addr: "w"}, &backend{addr: "r"}
+	sc := &stmtContext{params: 2, bound: make(map[*backend]bool)}
+	// id, flags, iteration count, null bitmap, new params bound, types, values
+	first := []byte{COM_STMT_EXECUTE, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, MYSQL_TYPE_LONG, 0, MYSQL_TYPE_VAR_STRING, 0, 7, 0, 0, 0, 1, 'a'}
+	if got := sc.bindTypes(r, first); string(got) != string(first) {
+		t.Errorf("Expected the first execute unchanged %v", got)
+	}
+	next := []byte{COM_STMT_EXECUTE, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 8, 0, 0, 0, 1, 'b'}
+	if got := sc.bindTypes(r, next); string(got) != string(next) {
+		t.Errorf("Expected an execute on the same backend unchanged %v", got)
+	}
+	want := []byte{COM_STMT_EXECUTE, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, MYSQL_TYPE_LONG, 0, MYSQL_TYPE_VAR_STRING, 0, 8, 0, 0, 0, 1, 'b'}
+	if got := sc.bindTypes(w, next); string(got) != string(want) {
+		t.Errorf("Expected the types bound on a new backend got %v", got)
+	}
+	if got := sc.bindTypes(w, next); string(got) != string(next) {
+		t.Errorf("Expected the types bound once per backend %v", got)
+	}
+}
+
+func TestOkStatus(t *testing.T) {
+	// affected rows 1, last insert id 300 on 3 bytes, status, warnings
+	pkt := []byte{OK_HEADER, 1, 0xfc, 0x2c, 0x01, byte(SERVER_STATUS_IN_TRANS | SERVER_STATUS_AUTOCOMMIT), 0, 0, 0}
+	if st := okStatus(pkt); st != SERVER_STATUS_IN_TRANS|SERVER_STATUS_AUTOCOMMIT {
+		t.Errorf("Unexpected status " + strconv.Itoa(int(st)))
+	}
+}
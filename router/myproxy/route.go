@@ -0,0 +1,194 @@
+package myproxy
+
+import (
+	"strings"
+)
+
+const (
+	queryWrite = iota
+	queryRead
+	querySet
+	queryUse
+	queryLast
+)
+
+// reads that need to run on the writer because they lock, write or read the session state of the writer
+var writerOnlyReads = []string{"for update", "lock in share mode", "for share", " into ", "last_insert_id(", "found_rows(", "row_count(", "get_lock(", "release_lock(", "is_used_lock(", "is_free_lock(", "nextval(", "lastval(", "@@identity", "@@last_insert_id"}
+
+// stripLeadingComments removes blanks and comments before the first keyword, optimizer hints
+// and executable comments are removed as well and the query is then sent to the writer
+func stripLeadingComments(query string) string {
+	q := strings.TrimSpace(query)
+	for {
+		switch {
+		case strings.HasPrefix(q, "/*"):
+			i := strings.Index(q, "*/")
+			if i < 0 {
+				return ""
+			}
+			q = strings.TrimSpace(q[i+2:])
+		case strings.HasPrefix(q, "#"), strings.HasPrefix(q, "-- "):
+			i := strings.Index(q, "\n")
+			if i < 0 {
+				return ""
+			}
+			q = strings.TrimSpace(q[i+1:])
+		default:
+			return q
+		}
+	}
+}
+
+func getFirstWord(q string) string {
+	i := strings.IndexAny(q, " \t\r\n(;")
+	if i < 0 {
+		return strings.ToLower(q)
+	}
+	return strings.ToLower(q[:i])
+}
+
+// getQueryType returns how a text query is routed
+func getQueryType(query string) int {
+	q := stripLeadingComments(query)
+	lq := strings.ToLower(q)
+	switch getFirstWord(q) {
+	case "select":
+		for _, kw := range writerOnlyReads {
+			if strings.Contains(lq, kw) {
+				return queryWrite
+			}
+		}
+		if strings.Contains(lq, "@@warning_count") || strings.Contains(lq, "@@error_count") {
+			return queryLast
+		}
+		return queryRead
+	case "show":
+		if strings.HasPrefix(lq, "show warnings") || strings.HasPrefix(lq, "show errors") || strings.HasPrefix(lq, "show count") {
+			return queryLast
+		}
+		if strings.Contains(lq, "master") || strings.Contains(lq, "slave") || strings.Contains(lq, "binary") || strings.Contains(lq, "processlist") {
+			return queryWrite
+		}
+		return queryRead
+	case "desc", "describe", "explain":
+		return queryRead
+	case "set":
+		// one shot or global changes are not part of the session
+		f := strings.Fields(lq)
+		if len(f) > 1 && (f[1] == "transaction" || f[1] == "global" || f[1] == "password" || strings.HasPrefix(f[1], "@@global.")) {
+			return queryWrite
+		}
+		return querySet
+	case "use":
+		return queryUse
+	}
+	return queryWrite
+}
+
+// getUseDB returns the database name of a USE statement
+func getUseDB(query string) string {
+	q := stripLeadingComments(query)
+	f := strings.Fields(q)
+	if len(f) < 2 {
+		return ""
+	}
+	return strings.Trim(f[1], "`; ")
+}
+
+// sessionVars are the session settings of a client replayed on new backend connections, a
+// variable set again replaces its previous value so the list is bounded by the variables used
+type sessionVars struct {
+	names []string
+	stmts map[string]string
+}
+
+func newSessionVars() *sessionVars {
+	return &sessionVars{stmts: make(map[string]string)}
+}
+
+// set records the assignments of a SET statement, a variable set again moves last so that
+// the replay keeps the order of settings overriding each other like NAMES
+func (v *sessionVars) set(query string) {
+	for _, part := range splitSetAssignments(query) {
+		name := getSetVarName(part)
+		if name == "" {
+			continue
+		}
+		if _, ok := v.stmts[name]; ok {
+			for i, n := range v.names {
+				if n == name {
+					v.names = append(v.names[:i], v.names[i+1:]...)
+					break
+				}
+			}
+		}
+		v.names = append(v.names, name)
+		v.stmts[name] = "SET " + part
+	}
+}
+
+// statements returns the SET statements to replay in order
+func (v *sessionVars) statements() []string {
+	res := make([]string, 0, len(v.names))
+	for _, n := range v.names {
+		res = append(res, v.stmts[n])
+	}
+	return res
+}
+
+// splitSetAssignments returns the comma separated assignments of a SET statement
+func splitSetAssignments(query string) []string {
+	q := strings.TrimSpace(stripLeadingComments(query))
+	if len(q) < 4 || !strings.EqualFold(q[:3], "set") {
+		return nil
+	}
+	q = strings.TrimRight(strings.TrimSpace(q[3:]), "; \t\r\n")
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(q[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(q[start:]))
+}
+
+// getSetVarName returns the session variable set by an assignment, empty for a global one
+func getSetVarName(part string) string {
+	lp := strings.ToLower(part)
+	for _, prefix := range []string{"session ", "local ", "@@session.", "@@local.", "@@"} {
+		if strings.HasPrefix(lp, prefix) {
+			lp = strings.TrimSpace(lp[len(prefix):])
+			break
+		}
+	}
+	switch {
+	case strings.HasPrefix(lp, "global ") || strings.HasPrefix(lp, "@@global."):
+		return ""
+	case strings.HasPrefix(lp, "names ") || strings.HasPrefix(lp, "character set ") || strings.HasPrefix(lp, "charset "):
+		return "names"
+	case strings.HasPrefix(lp, "transaction "):
+		return "transaction"
+	}
+	i := strings.Index(lp, "=")
+	if i < 0 {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(lp[:i]), ":` ")
+}
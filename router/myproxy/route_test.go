@@ -0,0 +1,80 @@
+package myproxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetQueryType(t *testing.T) {
+	for _, c := range []struct {
+		query string
+		typ   int
+	}{
+		{"SELECT * FROM t", queryRead},
+		{"  select 1", queryRead},
+		{"/* app */ SELECT 1", queryRead},
+		{"-- comment\nSELECT 1", queryRead},
+		{"# comment\nSELECT 1", queryRead},
+		{"(SELECT 1)", queryWrite},
+		{"SELECT * FROM t FOR UPDATE", queryWrite},
+		{"SELECT * FROM t LOCK IN SHARE MODE", queryWrite},
+		{"SELECT a INTO @a FROM t", queryWrite},
+		{"SELECT LAST_INSERT_ID()", queryWrite},
+		{"SELECT GET_LOCK('a', 1)", queryWrite},
+		{"SELECT @@warning_count", queryLast},
+		{"SHOW WARNINGS", queryLast},
+		{"show count(*) errors", queryLast},
+		{"SHOW TABLES", queryRead},
+		{"SHOW MASTER STATUS", queryWrite},
+		{"SHOW SLAVE STATUS", queryWrite},
+		{"SHOW PROCESSLIST", queryWrite},
+		{"DESC t", queryRead},
+		{"EXPLAIN SELECT 1", queryRead},
+		{"SET NAMES utf8mb4", querySet},
+		{"SET @a = 1", querySet},
+		{"SET SESSION sql_mode=''", querySet},
+		{"SET GLOBAL max_connections=10", queryWrite},
+		{"SET @@global.max_connections=10", queryWrite},
+		{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", queryWrite},
+		{"USE test", queryUse},
+		{"INSERT INTO t VALUES (1)", queryWrite},
+		{"BEGIN", queryWrite},
+		{"/*!40101 SELECT 1 */", queryWrite},
+		{"/* unterminated", queryWrite},
+		{"", queryWrite},
+	} {
+		if typ := getQueryType(c.query); typ != c.typ {
+			t.Errorf("Query %q routed as %d expected %d", c.query, typ, c.typ)
+		}
+	}
+}
+
+func TestGetUseDB(t *testing.T) {
+	for q, db := range map[string]string{"USE test": "test", "use `my db`;": "my", "USE `test`;": "test", "USE": ""} {
+		if d := getUseDB(q); d != db {
+			t.Errorf("Expected database %q in %q got %q", db, q, d)
+		}
+	}
+}
+
+func TestSessionVars(t *testing.T) {
+	v := newSessionVars()
+	for _, q := range []string{
+		"SET @@session.sql_mode='A'",
+		"SET @a=1, @b:=2",
+		"SET NAMES latin1",
+		"SET GLOBAL max_connections=10, sql_mode=concat('B', ',C')",
+		"SET CHARACTER SET utf8",
+		"SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED",
+	} {
+		v.set(q)
+	}
+	for i := 0; i < 100; i++ {
+		v.set("SET @a=" + strings.Repeat("1", i%3+1))
+	}
+	// global assignments are not replayed and each variable is kept once
+	got := strings.Join(v.statements(), ";")
+	if got != "SET @b:=2;SET sql_mode=concat('B', ',C');SET CHARACTER SET utf8;SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED;SET @a=1" {
+		t.Errorf("Unexpected session statements %s", got)
+	}
+}
@@ -1,95 +1,213 @@
 package myproxy
 
 import (
-	"database/sql"
-	"log"
+	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	siddon "github.com/siddontang/go-mysql/server"
 	"github.com/signal18/replication-manager/config"
 )
 
+// log levels of the cluster logger
+const (
+	lvlInfo = "INFO"
+	lvlWarn = "WARN"
+	lvlErr  = "ERROR"
+	lvlDbg  = "DEBUG"
+)
+
 type Server struct {
 	cfg      *config.Config
-	db       *sql.DB
 	addr     string
 	user     string
 	password string
-
-	running bool
-	verbose bool
-
+	// credentials used to open the backend connections
+	backendUser     string
+	backendPassword string
+
+	// backends are set by the monitor on refresh and failover
+	mu      sync.RWMutex
+	writer  string
+	readers []string
+	stats   map[string]*BackendStats
+	next    uint32
+
+	// time an autocommit read is retried while backends are switched
+	RetryTimeout time.Duration
+	// LogPrintf is the logger of the cluster, nothing is logged when nil
+	LogPrintf func(level string, format string, args ...interface{})
+
+	running  bool
 	listener net.Listener
 }
 
-// StartProxyServer start tcp proxy server for Mysql.
-func NewProxyServer(host string, user string, password string, db *sql.DB) (*Server, error) {
+// BackendStats defines the proxy counters of a backend
+type BackendStats struct {
+	Connections int64 `json:"connections"`
+	Queries     int64 `json:"queries"`
+	Errors      int64 `json:"errors"`
+}
+
+// NewProxyServer creates a tcp proxy server for Mysql, clients authenticate with user and password
+// and each client session opens its own backend connections with the backend credentials
+func NewProxyServer(host string, user string, password string, backendUser string, backendPassword string) (*Server, error) {
 	s := new(Server)
-	s.db = db
 	s.addr = host
 	s.password = password
 	s.user = user
+	s.backendUser = backendUser
+	s.backendPassword = backendPassword
+	s.stats = make(map[string]*BackendStats)
+	s.RetryTimeout = 30 * time.Second
 	return s, nil
 }
 
-func (s *Server) Run() {
-	var err error
-	s.listener, err = net.Listen("tcp", s.addr)
-	if err != nil {
+// SetBackends changes the write backend and the read backends, sessions move to the new writer
+// on their next statement outside of a transaction
+func (s *Server) SetBackends(writer string, readers []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer != writer && s.writer != "" {
+		s.logPrintf(lvlInfo, "MyProxy switching writer from %s to %s", s.writer, writer)
+	}
+	s.writer = writer
+	s.readers = readers
+	for _, addr := range append([]string{writer}, readers...) {
+		if _, ok := s.stats[addr]; !ok && addr != "" {
+			s.stats[addr] = new(BackendStats)
+		}
+	}
+}
 
-		return
+func (s *Server) GetWriter() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.writer
+}
+
+// GetReader returns the next read backend in round robin, or the writer when no reader is available
+func (s *Server) GetReader() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.readers) == 0 {
+		return s.writer
+	}
+	n := atomic.AddUint32(&s.next, 1)
+	return s.readers[int(n)%len(s.readers)]
+}
+
+func (s *Server) IsReader(addr string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.readers {
+		if r == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBackendStats returns a copy of the counters of each backend
+func (s *Server) GetBackendStats() map[string]BackendStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make(map[string]BackendStats)
+	for addr, st := range s.stats {
+		res[addr] = BackendStats{
+			Connections: atomic.LoadInt64(&st.Connections),
+			Queries:     atomic.LoadInt64(&st.Queries),
+			Errors:      atomic.LoadInt64(&st.Errors),
+		}
+	}
+	return res
+}
 
+func (s *Server) getStats(addr string) *BackendStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[addr]
+	if !ok {
+		st = new(BackendStats)
+		s.stats[addr] = st
 	}
-	defer s.listener.Close()
+	return st
+}
 
-	log.Println("start proxy successful", s.addr)
+func (s *Server) logPrintf(level string, format string, args ...interface{}) {
+	if s.LogPrintf != nil {
+		s.LogPrintf(level, format, args...)
+	}
+}
 
-	// begin to receive request
+func (s *Server) Run() {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.logPrintf(lvlErr, "MyProxy can't listen on %s: %s", s.addr, err)
+		return
+	}
+	s.mu.Lock()
+	s.listener = listener
 	s.running = true
+	s.mu.Unlock()
+	defer listener.Close()
+
+	s.logPrintf(lvlInfo, "MyProxy listening on %s", s.addr)
 
-	for s.running {
-		conn, err := s.listener.Accept()
+	// back off on accept errors like running out of file descriptors
+	var delay time.Duration
+	for s.IsRunning() {
+		conn, err := listener.Accept()
 		if err != nil {
-			log.Println(err)
+			if !s.IsRunning() {
+				return
+			}
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			s.logPrintf(lvlWarn, "MyProxy accept error: %s, retrying in %s", err, delay)
+			time.Sleep(delay)
 			continue
 		}
-
-		go s.proxyHandle(conn, s.db)
+		delay = 0
+		go s.proxyHandle(conn)
 	}
 }
+
 func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.running = false
 	if s.listener != nil {
 		s.listener.Close()
-		s.db.Close()
 	}
 }
-func (s *Server) IsRunning() bool {
 
+func (s *Server) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.running
 }
 
-func (s *Server) proxyHandle(conn net.Conn, db *sql.DB) {
+func (s *Server) proxyHandle(conn net.Conn) {
 	// close connection before exit
 	defer conn.Close()
 
-	if s.verbose {
-		log.Println("recv client", conn.RemoteAddr().String())
-	}
-	// Create a connection with user root and an empty passowrd
-	// We only an empty handler to handle command too
-	siddonconn, err := siddon.NewConn(conn, s.user, s.password, MysqlHandler{db: db, verbose: s.verbose})
+	s.logPrintf(lvlDbg, "MyProxy client connected from %s", conn.RemoteAddr())
+	h := newMysqlHandler(s)
+	defer h.Close()
+	siddonconn, err := siddon.NewConn(conn, s.user, s.password, h)
 	if err != nil {
+		s.logPrintf(lvlDbg, "MyProxy client %s failed handshake: %s", conn.RemoteAddr(), err)
 		return
 	}
-	//defer siddonconn.Close()
-	for s.running {
-		err := siddonconn.HandleCommand()
-		if err != nil {
-			if s.verbose {
-				log.Println(err)
-			}
-			return
-		}
+	if err := h.Serve(siddonconn); err != nil {
+		s.logPrintf(lvlDbg, "MyProxy client %s disconnected: %s", conn.RemoteAddr(), err)
 	}
 }
+
+var errNoBackend = errors.New("No backend available")
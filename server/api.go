@@ -102,41 +102,64 @@ type token struct {
 
 func (repman *ReplicationManager) apiserver() {
 	repman.initKeys()
+	router := repman.apiRouter()
+
+	if repman.Conf.APIGrpcPort != "" {
+		go repman.grpcserver()
+	}
+
+	log.Info("Starting HTTPS & JWT API on " + repman.Conf.APIBind + ":" + repman.Conf.APIPort)
+	var err error
+
+	if repman.Conf.MonitoringSSLCert == "" {
+		//	err = http.ListenAndServeTLS(repman.Conf.APIBind+":"+repman.Conf.APIPort, repman.Conf.ShareDir+"/server.crt", repman.Conf.ShareDir+"/server.key", router)
+		err = http.ListenAndServeTLS(repman.Conf.APIBind+":"+repman.Conf.APIPort, repman.Conf.ShareDir+"/server.crt", repman.Conf.ShareDir+"/server.key", handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router))
+	} else {
+		err = http.ListenAndServeTLS(repman.Conf.APIBind+":"+repman.Conf.APIPort, repman.Conf.MonitoringSSLCert, repman.Conf.MonitoringSSLKey, handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router))
+	}
+	if err != nil {
+		log.Errorf("JWT API can't start: %s", err)
+	}
+
+}
+
+// apiRouter registers the routes of the JWT API
+func (repman *ReplicationManager) apiRouter() *mux.Router {
 	//PUBLIC ENDPOINTS
 	router := mux.NewRouter()
 	router.HandleFunc("/", repman.handlerApp)
 	// page to view which does not need authorization
 	router.PathPrefix("/static/").Handler(http.FileServer(http.Dir(repman.Conf.HttpRoot)))
 	router.PathPrefix("/app/").Handler(http.FileServer(http.Dir(repman.Conf.HttpRoot)))
-	router.HandleFunc("/api/login", repman.loginHandler)
-	router.Handle("/api/clusters", negroni.New(
+	apiDoc(router.HandleFunc("/api/login", repman.loginHandler), apiRoute{Summary: "Login and get a JWT token", Method: "POST", Public: true, Request: new(userCredentials), Response: new(token)})
+	apiDoc(router.Handle("/api/clusters", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusters)),
-	))
-	router.Handle("/api/prometheus", negroni.New(
+	)), apiRoute{Summary: "List the clusters granted to the user", Response: []*cluster.Cluster{}})
+	apiDoc(router.Handle("/api/prometheus", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxPrometheus)),
-	))
-	router.Handle("/api/status", negroni.New(
+	)), apiRoute{Summary: "Prometheus metrics of all database servers", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/status", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStatus)),
-	))
-	router.Handle("/api/timeout", negroni.New(
+	)), apiRoute{Summary: "Status of replication-manager", Public: true, Response: new(apiStatus)})
+	apiDoc(router.Handle("/api/timeout", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxTimeout)),
-	))
-	router.Handle("/api/repocomp/current", negroni.New(
+	)), apiRoute{Summary: "Liveness check", Public: true, Response: new(apiStatus)})
+	apiDoc(router.Handle("/api/repocomp/current", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerRepoComp)),
-	))
+	)), apiRoute{Summary: "OpenSVC repository components", Public: true, ContentType: "application/octet-stream"})
 	//UNPROTECTED ENDPOINTS FOR SETTINGS
-	router.Handle("/api/monitor", negroni.New(
+	apiDoc(router.Handle("/api/monitor", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxReplicationManager)),
-	))
+	)), apiRoute{Summary: "Settings of replication-manager", Public: true, Response: new(ReplicationManager)})
 	//PROTECTED ENDPOINTS FOR SETTINGS
-	router.Handle("/api/monitor", negroni.New(
+	apiDoc(router.Handle("/api/monitor", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxReplicationManager)),
-	))
-	router.Handle("/api/monitor/actions/adduser/{userName}", negroni.New(
+	)), apiRoute{Summary: "Settings of replication-manager", Response: new(ReplicationManager)})
+	apiDoc(router.Handle("/api/monitor/actions/adduser/{userName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddUser)),
-	))
+	)), apiRoute{Summary: "Add an API user to all clusters"})
 
 	repman.apiDatabaseUnprotectedHandler(router)
 	repman.apiDatabaseProtectedHandler(router)
@@ -144,19 +167,10 @@ func (repman *ReplicationManager) apiserver() {
 	repman.apiClusterProtectedHandler(router)
 	repman.apiProxyProtectedHandler(router)
 
-	log.Info("Starting HTTPS & JWT API on " + repman.Conf.APIBind + ":" + repman.Conf.APIPort)
-	var err error
-
-	if repman.Conf.MonitoringSSLCert == "" {
-		//	err = http.ListenAndServeTLS(repman.Conf.APIBind+":"+repman.Conf.APIPort, repman.Conf.ShareDir+"/server.crt", repman.Conf.ShareDir+"/server.key", router)
-		err = http.ListenAndServeTLS(repman.Conf.APIBind+":"+repman.Conf.APIPort, repman.Conf.ShareDir+"/server.crt", repman.Conf.ShareDir+"/server.key", handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router))
-	} else {
-		err = http.ListenAndServeTLS(repman.Conf.APIBind+":"+repman.Conf.APIPort, repman.Conf.MonitoringSSLCert, repman.Conf.MonitoringSSLKey, handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router))
-	}
-	if err != nil {
-		log.Errorf("JWT API can't start: %s", err)
-	}
-
+	apiDoc(router.Handle("/api/openapi.json", negroni.New(
+		negroni.Wrap(repman.handlerMuxOpenAPI(router)),
+	)), apiRoute{Summary: "OpenAPI specification of the API", Public: true, Response: map[string]interface{}{}})
+	return router
 }
 
 //////////////////////////////////////////
@@ -197,23 +211,7 @@ func (repman *ReplicationManager) loginHandler(w http.ResponseWriter, r *http.Re
 
 		if cluster.IsValidACL(user.Username, user.Password, r.URL.Path) {
 
-			signer := jwt.New(jwt.SigningMethodRS256)
-			claims := signer.Claims.(jwt.MapClaims)
-			//set claims
-			claims["iss"] = "https://api.replication-manager.signal18.io"
-			claims["iat"] = time.Now().Unix()
-			claims["exp"] = time.Now().Add(time.Minute * 120).Unix()
-			claims["jti"] = "1" // should be user ID(?)
-			claims["CustomUserInfo"] = struct {
-				Name     string
-				Role     string
-				Password string
-			}{user.Username, "Member", user.Password}
-			signer.Claims = claims
-			sk, _ := jwt.ParseRSAPrivateKeyFromPEM(signingKey)
-			//sk, _ := jwt.ParseRSAPublicKeyFromPEM(signingKey)
-
-			tokenString, err := signer.SignedString(sk)
+			tokenString, err := signToken(user.Username, user.Password)
 
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
@@ -244,6 +242,27 @@ func (repman *ReplicationManager) loginHandler(w http.ResponseWriter, r *http.Re
 
 }
 
+// signToken returns the JWT token of a user for the REST and gRPC APIs
+func signToken(username string, password string) (string, error) {
+	signer := jwt.New(jwt.SigningMethodRS256)
+	claims := signer.Claims.(jwt.MapClaims)
+	//set claims
+	claims["iss"] = "https://api.replication-manager.signal18.io"
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Minute * 120).Unix()
+	claims["jti"] = "1" // should be user ID(?)
+	claims["CustomUserInfo"] = struct {
+		Name     string
+		Role     string
+		Password string
+	}{username, "Member", password}
+	signer.Claims = claims
+	sk, _ := jwt.ParseRSAPrivateKeyFromPEM(signingKey)
+	//sk, _ := jwt.ParseRSAPublicKeyFromPEM(signingKey)
+
+	return signer.SignedString(sk)
+}
+
 //AUTH TOKEN VALIDATION
 
 func (repman *ReplicationManager) handlerMuxReplicationManager(w http.ResponseWriter, r *http.Request) {
@@ -20,261 +20,319 @@ import (
 	"github.com/signal18/replication-manager/cluster"
 	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/regtest"
+	"github.com/signal18/replication-manager/utils/binlogserver"
+	"github.com/signal18/replication-manager/utils/configdrift"
+	"github.com/signal18/replication-manager/utils/dbhelper"
 )
 
 func (repman *ReplicationManager) apiClusterUnprotectedHandler(router *mux.Router) {
-	router.Handle("/api/clusters/{clusterName}/status", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/status", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/master-physical-backup", negroni.New(
+	)), apiRoute{Summary: "Status of a cluster", Public: true, Response: new(apiStatus)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/master-physical-backup", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterMasterPhysicalBackup)),
-	))
+	)), apiRoute{Summary: "Physical backup of the master", Grant: config.GrantDBBackup, Public: true})
 
 }
 
 func (repman *ReplicationManager) apiClusterProtectedHandler(router *mux.Router) {
 
-	router.Handle("/api/clusters/{clusterName}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxCluster)),
-	))
+	)), apiRoute{Summary: "Get a cluster", Response: new(cluster.Cluster)})
 
 	//PROTECTED ENDPOINTS FOR CLUSTERS ACTIONS
-	router.Handle("/api/clusters/{clusterName}/settings", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSettings)),
-	))
+	)), apiRoute{Summary: "Settings of a cluster", Response: new(config.Config)})
 
-	router.Handle("/api/clusters/{clusterName}/tags", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tags", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterTags)),
-	))
+	)), apiRoute{Summary: "Database configurator tags", Response: []cluster.Tag{}})
 
-	router.Handle("/api/clusters/{clusterName}/backups", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/backups", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackups)),
-	))
+	)), apiRoute{Summary: "Backups of a cluster", Grant: config.GrantClusterShowBackups, Response: []cluster.Backup{}})
 
-	router.Handle("/api/clusters/{clusterName}/certificates", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/backups/chains", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupChains)),
+	)), apiRoute{Summary: "Physical backup chains of a cluster", Grant: config.GrantClusterShowBackups, Response: []cluster.BackupChain{}})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/config-drift", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterConfigDrift)),
+	)), apiRoute{Summary: "Drift of the variables of the database servers from their generated configuration and from the master", Grant: config.GrantDBShowVariables, Response: []configdrift.Report{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/binlog-server", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBinlogServer)),
+	)), apiRoute{Summary: "Relay, binary logs and replicas of the native binlog server", Grant: config.GrantClusterShowBackups, Response: binlogserver.Status{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/workloads", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterWorkloads)),
+	)), apiRoute{Summary: "Workload captures of a cluster", Grant: config.GrantDBCapture, Response: []cluster.WorkloadCapture{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/workloads/{captureId}/replays", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterWorkloadReplays)),
+	)), apiRoute{Summary: "Replays of a workload capture with latencies and errors by digest", Grant: config.GrantDBCapture, Response: []cluster.WorkloadReplay{}})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/certificates", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterCertificates)),
-	))
+	)), apiRoute{Summary: "Client certificates of a cluster", Response: map[string]string{}})
 
-	router.Handle("/api/clusters/{clusterName}/queryrules", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/queryrules", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterQueryRules)),
-	))
-	router.Handle("/api/clusters/{clusterName}/shardclusters", negroni.New(
+	)), apiRoute{Summary: "Query rules of the proxies", Grant: config.GrantClusterShowRoutes, Response: []config.QueryRule{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/shardclusters", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterShardClusters)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/reload", negroni.New(
+	)), apiRoute{Summary: "Clusters sharded by the cluster proxies", Grant: config.GrantClusterSharding, Response: map[string]*cluster.Cluster{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/reload", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSettingsReload)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/switch/{settingName}", negroni.New(
+	)), apiRoute{Summary: "Reload the cluster configuration"})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/switch/{settingName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSettings)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/set/{settingName}/{settingValue}", negroni.New(
+	)), apiRoute{Summary: "Toggle a boolean setting", Grant: config.GrantClusterSettings})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/set/{settingName}/{settingValue}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetSettings)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/add-db-tag/{tagValue}", negroni.New(
+	)), apiRoute{Summary: "Set a setting", Grant: config.GrantClusterSettings})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/add-db-tag/{tagValue}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddTag)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/drop-db-tag/{tagValue}", negroni.New(
+	)), apiRoute{Summary: "Add a database configurator tag", Grant: config.GrantDBConfigFlag})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/drop-db-tag/{tagValue}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxDropTag)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/add-proxy-tag/{tagValue}", negroni.New(
+	)), apiRoute{Summary: "Drop a database configurator tag", Grant: config.GrantDBConfigFlag})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/add-proxy-tag/{tagValue}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddProxyTag)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/drop-proxy-tag/{tagValue}", negroni.New(
+	)), apiRoute{Summary: "Add a proxy configurator tag", Grant: config.GrantProxyConfigFlag})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/drop-proxy-tag/{tagValue}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxDropProxyTag)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/reset-failover-control", negroni.New(
+	)), apiRoute{Summary: "Drop a proxy configurator tag", Grant: config.GrantProxyConfigFlag})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/reset-failover-control", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterResetFailoverControl)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/discover", negroni.New(
+	)), apiRoute{Summary: "Reset the failover counter"})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/discover", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetSettingsDiscover)),
-	))
-	router.Handle("/api/clusters/{clusterName}/settings/actions/apply-dynamic-config", negroni.New(
+	)), apiRoute{Summary: "Discover the settings from the database servers", Grant: config.GrantClusterSettings})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/settings/actions/apply-dynamic-config", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterApplyDynamicConfig)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/add/{clusterShardingName}", negroni.New(
+	)), apiRoute{Summary: "Apply the dynamic configuration to the database servers", Grant: config.GrantDBConfigFlag})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/add/{clusterShardingName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterShardingAdd)),
-	))
+	)), apiRoute{Summary: "Add a cluster to the sharding proxies"})
 
-	router.Handle("/api/clusters/{clusterName}/actions/switchover", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/switchover", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchover)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/failover", negroni.New(
+	)), apiRoute{Summary: "Switchover the master", Grant: config.GrantClusterSwitchover, Query: []apiParam{{"prefmaster", "Preferred candidate in election, host:[port] format"}}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/failover", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxFailover)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/rotatekeys", negroni.New(
+	)), apiRoute{Summary: "Failover a dead master", Grant: config.GrantClusterFailover})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/rotatekeys", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRotateKeys)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/reset-sla", negroni.New(
+	)), apiRoute{Summary: "Rotate the encryption keys", Grant: config.GrantClusterRotateKey})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/reset-sla", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxResetSla)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/replication/bootstrap/{topology}", negroni.New(
+	)), apiRoute{Summary: "Reset the SLA counters", Grant: config.GrantClusterResetSLA})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/replication/bootstrap/{topology}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxBootstrapReplication)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/replication/cleanup", negroni.New(
+	)), apiRoute{Summary: "Bootstrap the replication", Grant: config.GrantClusterReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/replication/cleanup", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxBootstrapReplicationCleanup)),
-	))
-	router.Handle("/api/clusters/{clusterName}/services/actions/provision", negroni.New(
+	)), apiRoute{Summary: "Reset the replication of all servers", Grant: config.GrantClusterReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/replication/wsrep/bootstrap", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxWsrepBootstrap)),
+	)), apiRoute{Summary: "Bootstrap a Galera cluster", Grant: config.GrantClusterReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/replication/wsrep/recover-quorum", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxWsrepRecoverQuorum)),
+	)), apiRoute{Summary: "Recover the quorum of a Galera cluster", Grant: config.GrantClusterReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/services/actions/provision", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesProvision)),
-	))
-	router.Handle("/api/clusters/{clusterName}/services/actions/unprovision", negroni.New(
+	)), apiRoute{Summary: "Provision the cluster services", Grant: config.GrantProvCluster})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/services/actions/unprovision", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesUnprovision)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/cancel-rolling-restart", negroni.New(
+	)), apiRoute{Summary: "Unprovision the cluster services", Grant: config.GrantProvClusterUnprovision})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/cancel-rolling-restart", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesCancelRollingRestart)),
-	))
-	router.Handle("/api/clusters/{clusterName}/actions/cancel-rolling-reprov", negroni.New(
+	)), apiRoute{Summary: "Cancel a rolling restart", Grant: config.GrantClusterRolling})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/cancel-rolling-reprov", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesCancelRollingReprov)),
-	))
+	)), apiRoute{Summary: "Cancel a rolling reprovisioning", Grant: config.GrantClusterRolling})
 
-	router.Handle("/api/clusters/{clusterName}/actions/stop-traffic", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/stop-traffic", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStopTraffic)),
-	))
+	)), apiRoute{Summary: "Stop the traffic through the proxies", Grant: config.GrantClusterTraffic})
 
-	router.Handle("/api/clusters/{clusterName}/actions/start-traffic", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/start-traffic", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStartTraffic)),
-	))
+	)), apiRoute{Summary: "Start the traffic through the proxies", Grant: config.GrantClusterTraffic})
 
-	router.Handle("/api/clusters/{clusterName}/actions/optimize", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/optimize", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterOptimize)),
-	))
+	)), apiRoute{Summary: "Optimize all database servers", Grant: config.GrantClusterRolling})
 
-	router.Handle("/api/clusters/{clusterName}/actions/sysbench", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/sysbench", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSysbench)),
-	))
+	)), apiRoute{Summary: "Run sysbench", Grant: config.GrantClusterBench})
 
-	router.Handle("/api/clusters/{clusterName}/actions/waitdatabases", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/waitdatabases", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterWaitDatabases)),
-	))
+	)), apiRoute{Summary: "Wait for the database servers to be up"})
 
-	router.Handle("/api/clusters/{clusterName}/actions/addserver/{host}/{port}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/addserver/{host}/{port}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerAdd)),
-	))
+	)), apiRoute{Summary: "Add a database server", Grant: config.GrantClusterCreateMonitor})
 
-	router.Handle("/api/clusters/{clusterName}/actions/addserver/{host}/{port}/{type}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/addserver/{host}/{port}/{type}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerAdd)),
-	))
+	)), apiRoute{Summary: "Add a database server of a given type", Grant: config.GrantClusterCreateMonitor})
 
-	router.Handle("/api/clusters/{clusterName}/actions/rolling", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/rolling", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRolling)),
-	))
+	)), apiRoute{Summary: "Rolling restart of the database servers", Grant: config.GrantClusterRolling})
 
-	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/reshard-table", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/reshard-table", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaReshardTable)),
-	))
-	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/reshard-table/{clusterList}", negroni.New(
+	)), apiRoute{Summary: "Reshard a table on all shard clusters", Grant: config.GrantClusterSharding})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/reshard-table/{clusterList}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaReshardTable)),
-	))
-	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/move-table/{clusterShard}", negroni.New(
+	)), apiRoute{Summary: "Reshard a table on a list of clusters", Grant: config.GrantClusterSharding})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/move-table/{clusterShard}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaMoveTable)),
-	))
-	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/universal-table", negroni.New(
+	)), apiRoute{Summary: "Move a table to a shard cluster", Grant: config.GrantClusterSharding})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/universal-table", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaUniversalTable)),
-	))
-	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/checksum-table", negroni.New(
+	)), apiRoute{Summary: "Replicate a table to all shard clusters", Grant: config.GrantClusterSharding})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/checksum-table", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaChecksumTable)),
-	))
+	)), apiRoute{Summary: "Check the consistency of a table on the slaves in background", Grant: config.GrantClusterSharding})
 
-	router.Handle("/api/clusters/{clusterName}/actions/checksum-all-tables", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/actions/checksum-all-tables", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaChecksumAllTable)),
-	))
+	)), apiRoute{Summary: "Check the consistency of all tables on the slaves in background", Grant: config.GrantClusterChecksum})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/checksums", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterChecksums)),
+	)), apiRoute{Summary: "History of the consistency checks", Grant: config.GrantClusterChecksum, Response: []cluster.ChecksumReport{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/checksums/actions/repair", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterChecksumRepair)),
+	)), apiRoute{Summary: "Apply the repair statements of the last consistency check on the diverging slaves", Grant: config.GrantClusterChecksum, Response: new(cluster.ChecksumReport)})
 
-	router.Handle("/api/clusters/{clusterName}/schema", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/schema", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchema)),
-	))
+	)), apiRoute{Summary: "Tables of the master", Grant: config.GrantClusterSharding, Response: []dbhelper.Table{}})
 
 	//PROTECTED ENDPOINTS FOR CLUSTERS TOPOLOGY
 
-	router.Handle("/api/clusters/actions/add/{clusterName}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/actions/add/{clusterName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterAdd)),
-	))
+	)), apiRoute{Summary: "Add a cluster"})
 
-	router.Handle("/api/clusters/{clusterName}/topology/servers", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/servers", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServers)),
-	))
-	router.Handle("/api/clusters/{clusterName}/topology/master", negroni.New(
+	)), apiRoute{Summary: "Database servers", Response: []*cluster.ServerMonitor{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/master", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxMaster)),
-	))
-	router.Handle("/api/clusters/{clusterName}/topology/slaves", negroni.New(
+	)), apiRoute{Summary: "Master database server", Response: new(cluster.ServerMonitor)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/slaves", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSlaves)),
-	))
-	router.Handle("/api/clusters/{clusterName}/topology/logs", negroni.New(
+	)), apiRoute{Summary: "Slave database servers", Response: []*cluster.ServerMonitor{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/logs", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxLog)),
-	))
-	router.Handle("/api/clusters/{clusterName}/topology/proxies", negroni.New(
+	)), apiRoute{Summary: "Log lines of the cluster", Response: []string{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/proxies", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxies)),
-	))
-	router.Handle("/api/clusters/{clusterName}/topology/alerts", negroni.New(
+	)), apiRoute{Summary: "Proxies", Response: []*cluster.Proxy{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/alerts", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAlerts)),
-	))
-	router.Handle("/api/clusters/{clusterName}/topology/crashes", negroni.New(
+	)), apiRoute{Summary: "Open errors and warnings", Response: new(cluster.Alerts)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/stream", negroni.New(
+		negroni.HandlerFunc(repman.validateStreamTokenMiddleware),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStream)),
+	)), apiRoute{Summary: "Stream the cluster events as SSE or WebSocket, WebSocket clients can pass the token as the subprotocols bearer and the token", Query: []apiParam{{"since", "Resume after this event id, Last-Event-ID header is also accepted"}, {"types", "Comma separated list of event types"}}, Response: new(cluster.Event), ContentType: "text/event-stream"})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/topology/crashes", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxCrashes)),
-	))
+	)), apiRoute{Summary: "Failover history", Response: []*cluster.Crash{}})
 	//PROTECTED ENDPOINTS FOR TESTS
 
-	router.Handle("/api/clusters/{clusterName}/tests/actions/run/all", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tests/actions/run/all", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxTests)),
-	))
-	router.Handle("/api/clusters/{clusterName}/tests/actions/run/{testName}", negroni.New(
+	)), apiRoute{Summary: "Run all regression tests", Grant: config.GrantClusterTest, Response: []cluster.Test{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tests/actions/run/{testName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxOneTest)),
-	))
+	)), apiRoute{Summary: "Run a regression test", Grant: config.GrantClusterTest, Query: []apiParam{{"provision", "Provision the cluster before the test when true"}, {"unprovision", "Unprovision the cluster after the test when true"}}, Response: new(cluster.Test)})
 
-	router.Handle("/api/clusters/{clusterName}/tests/actions/run/{testName}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tests/actions/run/{testName}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxOneTest)),
-	))
+	)), apiRoute{Summary: "Run a regression test", Grant: config.GrantClusterTest, Query: []apiParam{{"provision", "Provision the cluster before the test when true"}, {"unprovision", "Unprovision the cluster after the test when true"}}, Response: new(cluster.Test)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tests/actions/chaos", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxChaosRun)),
+	)), apiRoute{Summary: "Start a chaos scenario in the background, the running report gives its id", Grant: config.GrantClusterTest, Response: new(cluster.ChaosReport)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tests/chaos", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxChaosReports)),
+	)), apiRoute{Summary: "Reports of the chaos runs", Grant: config.GrantClusterTest, Response: []cluster.ChaosReport{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/tests/chaos/{chaosId}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxChaosReport)),
+	)), apiRoute{Summary: "Report of a chaos run", Grant: config.GrantClusterTest, Response: new(cluster.ChaosReport)})
 }
 
 func (repman *ReplicationManager) handlerMuxServers(w http.ResponseWriter, r *http.Request) {
@@ -550,6 +608,52 @@ func (repman *ReplicationManager) handlerMuxBootstrapReplicationCleanup(w http.R
 	return
 }
 
+func (repman *ReplicationManager) handlerMuxWsrepBootstrap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		err := mycluster.WsrepBootstrap()
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error Galera bootstrap: %s", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxWsrepRecoverQuorum(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		err := mycluster.WsrepRecoverQuorum()
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error Galera quorum recovery: %s", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
 func (repman *ReplicationManager) handlerMuxBootstrapReplication(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -877,6 +981,121 @@ func (repman *ReplicationManager) handlerMuxClusterBackups(w http.ResponseWriter
 	}
 }
 
+func (repman *ReplicationManager) handlerMuxClusterBackupChains(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetBackupChains())
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterConfigDrift(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetConfigDrift())
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterBinlogServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		st := mycluster.GetBinlogServerStatus()
+		if st == nil {
+			http.Error(w, "Binlog server not running", 500)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(st)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterWorkloads(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetWorkloadCaptures())
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterWorkloadReplays(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetWorkloadReplays(vars["captureId"]))
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxClusterShardClusters(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -931,128 +1150,7 @@ func (repman *ReplicationManager) handlerMuxSwitchSettings(w http.ResponseWriter
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		setting := vars["settingName"]
-		mycluster.LogPrintf("INFO", "API receive switch setting %s", setting)
-		switch setting {
-		case "verbose":
-			mycluster.SwitchVerbosity()
-		case "failover-mode":
-			mycluster.SwitchInteractive()
-		case "failover-readonly-state":
-			mycluster.SwitchReadOnly()
-		case "failover-restart-unsafe":
-			mycluster.SwitchFailoverRestartUnsafe()
-		case "failover-at-sync":
-			mycluster.SwitchFailSync()
-		case "force-slave-no-gtid-mode":
-			mycluster.SwitchForceSlaveNoGtid()
-		case "failover-event-status":
-			mycluster.SwitchFailoverEventStatus()
-		case "failover-event-scheduler":
-			mycluster.SwitchFailoverEventScheduler()
-		case "autorejoin":
-			mycluster.SwitchRejoin()
-		case "autoseed":
-			mycluster.SwitchAutoseed()
-		case "autorejoin-backup-binlog":
-			mycluster.SwitchRejoinBackupBinlog()
-		case "autorejoin-flashback":
-			mycluster.SwitchRejoinFlashback()
-		case "autorejoin-flashback-on-sync":
-			mycluster.SwitchRejoinSemisync()
-		case "autorejoin-flashback-on-unsync": //?????
-		case "autorejoin-slave-positional-heartbeat":
-			mycluster.SwitchRejoinPseudoGTID()
-		case "autorejoin-zfs-flashback":
-			mycluster.SwitchRejoinZFSFlashback()
-		case "autorejoin-mysqldump":
-			mycluster.SwitchRejoinDump()
-		case "autorejoin-logical-backup":
-			mycluster.SwitchRejoinLogicalBackup()
-		case "autorejoin-physical-backup":
-			mycluster.SwitchRejoinPhysicalBackup()
-		case "switchover-at-sync":
-			mycluster.SwitchSwitchoverSync()
-		case "check-replication-filters":
-			mycluster.SwitchCheckReplicationFilters()
-		case "check-replication-state":
-			mycluster.SwitchRplChecks()
-		case "scheduler-db-servers-logical-backup":
-			mycluster.SwitchSchedulerBackupLogical()
-		case "scheduler-db-servers-physical-backup":
-			mycluster.SwitchSchedulerBackupPhysical()
-		case "scheduler-db-servers-logs":
-			mycluster.SwitchSchedulerDatabaseLogs()
-		case "scheduler-jobs-ssh":
-			mycluster.SwitchSchedulerDbJobsSsh()
-		case "scheduler-db-servers-logs-table-rotate":
-			mycluster.SwitchSchedulerDatabaseLogsTableRotate()
-		case "scheduler-rolling-restart":
-			mycluster.SwitchSchedulerRollingRestart()
-		case "scheduler-rolling-reprov":
-			mycluster.SwitchSchedulerRollingReprov()
-		case "scheduler-db-servers-optimize":
-			mycluster.SwitchSchedulerDatabaseOptimize()
-		case "graphite-metrics":
-			mycluster.SwitchGraphiteMetrics()
-		case "graphite-embedded":
-			mycluster.SwitchGraphiteEmbedded()
-		case "shardproxy-copy-grants":
-			mycluster.SwitchProxysqlCopyGrants()
-
-		case "proxysql-copy-grants":
-			mycluster.SwitchProxysqlCopyGrants()
-		case "proxysql-bootstrap-users":
-			mycluster.SwitchProxysqlCopyGrants()
-		case "proxysql-bootstrap-variables":
-			mycluster.SwitchProxysqlBootstrapVariables()
-		case "proxysql-bootstrap-hostgroups":
-			mycluster.SwitchProxysqlBootstrapHostgroups()
-		case "proxysql-bootstrap-servers":
-			mycluster.SwitchProxysqlBootstrapServers()
-		case "proxysql-bootstrap-query-rules":
-			mycluster.SwitchProxysqlBootstrapQueryRules()
-		case "proxysql-bootstrap":
-			mycluster.SwitchProxysqlBootstrap()
-		case "proxysql":
-			mycluster.SwitchProxySQL()
-		case "proxy-servers-read-on-master":
-			mycluster.SwitchProxyServersReadOnMaster()
-		case "proxy-servers-backend-compression":
-			mycluster.SwitchProxyServersBackendCompression()
-		case "database-heartbeat":
-			mycluster.SwitchTraffic()
-		case "test":
-			mycluster.SwitchTestMode()
-		case "prov-net-cni":
-			mycluster.SwitchProvNetCNI()
-		case "prov-db-apply-dynamic-config":
-			mycluster.SwitchDBApplyDynamicConfig()
-		case "prov-docker-daemon-private":
-			mycluster.SwitchProvDockerDaemonPrivate()
-		case "backup-restic":
-			mycluster.SwitchBackupRestic()
-		case "backup-binlogs":
-			mycluster.SwitchBackupBinlogs()
-		case "monitoring-pause":
-			mycluster.SwitchMonitoringPause()
-		case "monitoring-save-config":
-			mycluster.SwitchMonitoringSaveConfig()
-		case "monitoring-queries":
-			mycluster.SwitchMonitoringQueries()
-		case "monitoring-scheduler":
-			mycluster.SwitchMonitoringScheduler()
-		case "monitoring-schema-change":
-			mycluster.SwitchMonitoringSchemaChange()
-		case "monitoring-capture":
-			mycluster.SwitchMonitoringCapture()
-		case "monitoring-innodb-status":
-			mycluster.SwitchMonitoringInnoDBStatus()
-		case "monitoring-variable-diff":
-			mycluster.SwitchMonitoringVariableDiff()
-		case "monitoring-processlist":
-			mycluster.SwitchMonitoringProcesslist()
-		}
+		repman.switchSettings(mycluster, vars["settingName"])
 
 	} else {
 		http.Error(w, "No cluster", 500)
@@ -1061,6 +1159,138 @@ func (repman *ReplicationManager) handlerMuxSwitchSettings(w http.ResponseWriter
 	return
 }
 
+func (repman *ReplicationManager) switchSettings(mycluster *cluster.Cluster, setting string) {
+	mycluster.LogPrintf("INFO", "API receive switch setting %s", setting)
+	switch setting {
+	case "verbose":
+		mycluster.SwitchVerbosity()
+	case "failover-mode":
+		mycluster.SwitchInteractive()
+	case "failover-readonly-state":
+		mycluster.SwitchReadOnly()
+	case "failover-restart-unsafe":
+		mycluster.SwitchFailoverRestartUnsafe()
+	case "failover-at-sync":
+		mycluster.SwitchFailSync()
+	case "force-slave-no-gtid-mode":
+		mycluster.SwitchForceSlaveNoGtid()
+	case "failover-event-status":
+		mycluster.SwitchFailoverEventStatus()
+	case "failover-event-scheduler":
+		mycluster.SwitchFailoverEventScheduler()
+	case "autorejoin":
+		mycluster.SwitchRejoin()
+	case "autoseed":
+		mycluster.SwitchAutoseed()
+	case "autorejoin-backup-binlog":
+		mycluster.SwitchRejoinBackupBinlog()
+	case "autorejoin-flashback":
+		mycluster.SwitchRejoinFlashback()
+	case "autorejoin-flashback-on-sync":
+		mycluster.SwitchRejoinSemisync()
+	case "autorejoin-flashback-on-unsync": //?????
+	case "autorejoin-slave-positional-heartbeat":
+		mycluster.SwitchRejoinPseudoGTID()
+	case "autorejoin-zfs-flashback":
+		mycluster.SwitchRejoinZFSFlashback()
+	case "autorejoin-mysqldump":
+		mycluster.SwitchRejoinDump()
+	case "autorejoin-logical-backup":
+		mycluster.SwitchRejoinLogicalBackup()
+	case "autorejoin-physical-backup":
+		mycluster.SwitchRejoinPhysicalBackup()
+	case "switchover-at-sync":
+		mycluster.SwitchSwitchoverSync()
+	case "check-replication-filters":
+		mycluster.SwitchCheckReplicationFilters()
+	case "check-replication-state":
+		mycluster.SwitchRplChecks()
+	case "scheduler-db-servers-logical-backup":
+		mycluster.SwitchSchedulerBackupLogical()
+	case "scheduler-db-servers-physical-backup":
+		mycluster.SwitchSchedulerBackupPhysical()
+	case "scheduler-db-servers-incremental-backup":
+		mycluster.SwitchSchedulerBackupIncremental()
+	case "scheduler-db-servers-logs":
+		mycluster.SwitchSchedulerDatabaseLogs()
+	case "scheduler-jobs-ssh":
+		mycluster.SwitchSchedulerDbJobsSsh()
+	case "scheduler-db-servers-logs-table-rotate":
+		mycluster.SwitchSchedulerDatabaseLogsTableRotate()
+	case "scheduler-rolling-restart":
+		mycluster.SwitchSchedulerRollingRestart()
+	case "scheduler-rolling-reprov":
+		mycluster.SwitchSchedulerRollingReprov()
+	case "scheduler-chaos":
+		mycluster.SwitchSchedulerChaos()
+	case "scheduler-checksum":
+		mycluster.SwitchSchedulerChecksum()
+	case "checksum-repair":
+		mycluster.SwitchChecksumRepair()
+	case "scheduler-db-servers-optimize":
+		mycluster.SwitchSchedulerDatabaseOptimize()
+	case "graphite-metrics":
+		mycluster.SwitchGraphiteMetrics()
+	case "graphite-embedded":
+		mycluster.SwitchGraphiteEmbedded()
+	case "shardproxy-copy-grants":
+		mycluster.SwitchProxysqlCopyGrants()
+
+	case "proxysql-copy-grants":
+		mycluster.SwitchProxysqlCopyGrants()
+	case "proxysql-bootstrap-users":
+		mycluster.SwitchProxysqlCopyGrants()
+	case "proxysql-bootstrap-variables":
+		mycluster.SwitchProxysqlBootstrapVariables()
+	case "proxysql-bootstrap-hostgroups":
+		mycluster.SwitchProxysqlBootstrapHostgroups()
+	case "proxysql-bootstrap-servers":
+		mycluster.SwitchProxysqlBootstrapServers()
+	case "proxysql-bootstrap-query-rules":
+		mycluster.SwitchProxysqlBootstrapQueryRules()
+	case "proxysql-bootstrap":
+		mycluster.SwitchProxysqlBootstrap()
+	case "proxysql":
+		mycluster.SwitchProxySQL()
+	case "proxy-servers-read-on-master":
+		mycluster.SwitchProxyServersReadOnMaster()
+	case "proxy-servers-backend-compression":
+		mycluster.SwitchProxyServersBackendCompression()
+	case "database-heartbeat":
+		mycluster.SwitchTraffic()
+	case "test":
+		mycluster.SwitchTestMode()
+	case "prov-net-cni":
+		mycluster.SwitchProvNetCNI()
+	case "prov-db-apply-dynamic-config":
+		mycluster.SwitchDBApplyDynamicConfig()
+	case "prov-docker-daemon-private":
+		mycluster.SwitchProvDockerDaemonPrivate()
+	case "backup-restic":
+		mycluster.SwitchBackupRestic()
+	case "backup-binlogs":
+		mycluster.SwitchBackupBinlogs()
+	case "monitoring-pause":
+		mycluster.SwitchMonitoringPause()
+	case "monitoring-save-config":
+		mycluster.SwitchMonitoringSaveConfig()
+	case "monitoring-queries":
+		mycluster.SwitchMonitoringQueries()
+	case "monitoring-scheduler":
+		mycluster.SwitchMonitoringScheduler()
+	case "monitoring-schema-change":
+		mycluster.SwitchMonitoringSchemaChange()
+	case "monitoring-capture":
+		mycluster.SwitchMonitoringCapture()
+	case "monitoring-innodb-status":
+		mycluster.SwitchMonitoringInnoDBStatus()
+	case "monitoring-variable-diff":
+		mycluster.SwitchMonitoringVariableDiff()
+	case "monitoring-processlist":
+		mycluster.SwitchMonitoringProcesslist()
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxSetSettings(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -1070,133 +1300,7 @@ func (repman *ReplicationManager) handlerMuxSetSettings(w http.ResponseWriter, r
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		setting := vars["settingName"]
-		mycluster.LogPrintf("INFO", "API receive set setting %s", setting)
-		switch setting {
-		case "replication-credential":
-			mycluster.SetReplicationCredential(vars["settingValue"])
-		case "failover-max-slave-delay":
-			val, _ := strconv.ParseInt(vars["settingValue"], 10, 64)
-			mycluster.SetRplMaxDelay(val)
-		case "switchover-wait-route-change":
-			mycluster.SetSwitchoverWaitRouteChange(vars["settingValue"])
-		case "failover-limit":
-			val, _ := strconv.Atoi(vars["settingValue"])
-			mycluster.SetFailLimit(val)
-		case "backup-keep-hourly":
-			mycluster.SetBackupKeepHourly(vars["settingValue"])
-		case "backup-keep-daily":
-			mycluster.SetBackupKeepDaily(vars["settingValue"])
-		case "backup-keep-monthly":
-			mycluster.SetBackupKeepMonthly(vars["settingValue"])
-		case "backup-keep-weekly":
-			mycluster.SetBackupKeepWeekly(vars["settingValue"])
-		case "backup-keep-yearly":
-			mycluster.SetBackupKeepYearly(vars["settingValue"])
-		case "backup-logical-type":
-			mycluster.SetBackupLogicalType(vars["settingValue"])
-		case "backup-physical-type":
-			mycluster.SetBackupPhysicalType(vars["settingValue"])
-		case "db-servers-hosts":
-			mycluster.SetDbServerHosts(vars["settingValue"])
-		case "db-servers-credential":
-			mycluster.SetDbServersCredential(vars["settingValue"])
-		case "prov-service-plan":
-			mycluster.SetServicePlan(vars["settingValue"])
-		case "prov-net-cni-cluster":
-			mycluster.SetProvNetCniCluster(vars["settingValue"])
-		case "prov-orchestrator-cluster":
-			mycluster.SetProvOrchestratorCluster(vars["settingValue"])
-		case "prov-db-disk-size":
-			mycluster.SetDBDiskSize(vars["settingValue"])
-		case "prov-db-cpu-cores":
-			mycluster.SetDBCores(vars["settingValue"])
-		case "prov-db-memory":
-			mycluster.SetDBMemorySize(vars["settingValue"])
-		case "prov-db-disk-iops":
-			mycluster.SetDBDiskIOPS(vars["settingValue"])
-		case "prov-db-max-connections":
-			mycluster.SetDBMaxConnections(vars["settingValue"])
-		case "prov-db-expire-log-days":
-			mycluster.SetDBExpireLogDays(vars["settingValue"])
-		case "prov-db-agents":
-			mycluster.SetProvDbAgents(vars["settingValue"])
-		case "prov-proxy-agents":
-			mycluster.SetProvProxyAgents(vars["settingValue"])
-		case "prov-orchestrator":
-			mycluster.SetProvOrchestrator(vars["settingValue"])
-		case "prov-sphinx-img":
-			mycluster.SetProvSphinxImage(vars["settingValue"])
-		case "prov-db-image":
-			mycluster.SetProvDBImage(vars["settingValue"])
-		case "prov-db-disk-type":
-			mycluster.SetProvDbDiskType(vars["settingValue"])
-		case "prov-db-disk-fs":
-			mycluster.SetProvDbDiskFS(vars["settingValue"])
-		case "prov-db-disk-pool":
-			mycluster.SetProvDbDiskPool(vars["settingValue"])
-		case "prov-db-disk-device":
-			mycluster.SetProvDbDiskDevice(vars["settingValue"])
-		case "prov-db-service-type":
-			mycluster.SetProvDbServiceType(vars["settingValue"])
-		case "proxysql-servers-credential":
-			mycluster.SetProxyServersCredential(vars["settingValue"], config.ConstProxySqlproxy)
-		case "proxy-servers-backend-max-connections":
-			mycluster.SetProxyServersBackendMaxConnections(vars["settingValue"])
-		case "proxy-servers-backend-max-replication-lag":
-			mycluster.SetProxyServersBackendMaxReplicationLag(vars["settingValue"])
-		case "maxscale-servers-credential":
-			mycluster.SetProxyServersCredential(vars["settingValue"], config.ConstProxyMaxscale)
-		case "shardproxy-servers-credential":
-			mycluster.SetProxyServersCredential(vars["settingValue"], config.ConstProxySpider)
-		case "prov-proxy-disk-size":
-			mycluster.SetProxyDiskSize(vars["settingValue"])
-		case "prov-proxy-cpu-cores":
-			mycluster.SetProxyCores(vars["settingValue"])
-		case "prov-proxy-memory":
-			mycluster.SetProxyMemorySize(vars["settingValue"])
-		case "prov-proxy-docker-proxysql-img":
-			mycluster.SetProvProxySQLImage(vars["settingValue"])
-		case "prov-proxy-docker-maxscale-img":
-			mycluster.SetProvMaxscaleImage(vars["settingValue"])
-		case "prov-proxy-docker-haproxy-img":
-			mycluster.SetProvHaproxyImage(vars["settingValue"])
-		case "prov-proxy-docker-shardproxy-img":
-			mycluster.SetProvShardproxyImage(vars["settingValue"])
-		case "prov-proxy-disk-type":
-			mycluster.SetProvProxyDiskType(vars["settingValue"])
-		case "prov-proxy-disk-fs":
-			mycluster.SetProvProxyDiskFS(vars["settingValue"])
-		case "prov-proxy-disk-pool":
-			mycluster.SetProvProxyDiskPool(vars["settingValue"])
-		case "prov-proxy-disk-device":
-			mycluster.SetProvProxyDiskDevice(vars["settingValue"])
-		case "prov-proxy-service-type":
-			mycluster.SetProvProxyServiceType(vars["settingValue"])
-		case "monitoring-address":
-			mycluster.SetMonitoringAddress(vars["settingValue"])
-		case "scheduler-db-servers-logical-backup-cron":
-			mycluster.SetSchedulerDbServersLogicalBackupCron(vars["settingValue"])
-		case "scheduler-db-servers-logs-cron":
-			mycluster.SetSchedulerDbServersLogsCron(vars["settingValue"])
-		case "scheduler-db-servers-logs-table-rotate-cron":
-			mycluster.SetSchedulerDbServersLogsTableRotateCron(vars["settingValue"])
-		case "scheduler-db-servers-optimize-cron":
-			mycluster.SetSchedulerDbServersOptimizeCron(vars["settingValue"])
-		case "scheduler-db-servers-physical-backup-cron":
-			mycluster.SetSchedulerDbServersPhysicalBackupCron(vars["settingValue"])
-		case "scheduler-rolling-reprov-cron":
-			mycluster.SetSchedulerRollingReprovCron(vars["settingValue"])
-		case "scheduler-rolling-restart-cron":
-			mycluster.SetSchedulerRollingRestartCron(vars["settingValue"])
-		case "scheduler-sla-rotate-cron":
-			mycluster.SetSchedulerSlaRotateCron(vars["settingValue"])
-		case "scheduler-jobs-ssh-cron":
-			mycluster.SetSchedulerJobsSshCron(vars["settingValue"])
-		case "backup-binlogs-keep":
-			mycluster.SetBackupBinlogsKeep(vars["settingValue"])
-
-		}
+		repman.setSettings(mycluster, vars["settingName"], vars["settingValue"])
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
@@ -1204,6 +1308,141 @@ func (repman *ReplicationManager) handlerMuxSetSettings(w http.ResponseWriter, r
 	return
 }
 
+func (repman *ReplicationManager) setSettings(mycluster *cluster.Cluster, setting string, value string) {
+	mycluster.LogPrintf("INFO", "API receive set setting %s", setting)
+	switch setting {
+	case "replication-credential":
+		mycluster.SetReplicationCredential(value)
+	case "failover-max-slave-delay":
+		val, _ := strconv.ParseInt(value, 10, 64)
+		mycluster.SetRplMaxDelay(val)
+	case "switchover-wait-route-change":
+		mycluster.SetSwitchoverWaitRouteChange(value)
+	case "failover-limit":
+		val, _ := strconv.Atoi(value)
+		mycluster.SetFailLimit(val)
+	case "backup-keep-hourly":
+		mycluster.SetBackupKeepHourly(value)
+	case "backup-keep-daily":
+		mycluster.SetBackupKeepDaily(value)
+	case "backup-keep-monthly":
+		mycluster.SetBackupKeepMonthly(value)
+	case "backup-keep-weekly":
+		mycluster.SetBackupKeepWeekly(value)
+	case "backup-keep-yearly":
+		mycluster.SetBackupKeepYearly(value)
+	case "backup-logical-type":
+		mycluster.SetBackupLogicalType(value)
+	case "backup-physical-type":
+		mycluster.SetBackupPhysicalType(value)
+	case "db-servers-hosts":
+		mycluster.SetDbServerHosts(value)
+	case "db-servers-credential":
+		mycluster.SetDbServersCredential(value)
+	case "prov-service-plan":
+		mycluster.SetServicePlan(value)
+	case "prov-net-cni-cluster":
+		mycluster.SetProvNetCniCluster(value)
+	case "prov-orchestrator-cluster":
+		mycluster.SetProvOrchestratorCluster(value)
+	case "prov-db-disk-size":
+		mycluster.SetDBDiskSize(value)
+	case "prov-db-cpu-cores":
+		mycluster.SetDBCores(value)
+	case "prov-db-memory":
+		mycluster.SetDBMemorySize(value)
+	case "prov-db-disk-iops":
+		mycluster.SetDBDiskIOPS(value)
+	case "prov-db-max-connections":
+		mycluster.SetDBMaxConnections(value)
+	case "prov-db-expire-log-days":
+		mycluster.SetDBExpireLogDays(value)
+	case "prov-db-agents":
+		mycluster.SetProvDbAgents(value)
+	case "prov-proxy-agents":
+		mycluster.SetProvProxyAgents(value)
+	case "prov-orchestrator":
+		mycluster.SetProvOrchestrator(value)
+	case "prov-sphinx-img":
+		mycluster.SetProvSphinxImage(value)
+	case "prov-db-image":
+		mycluster.SetProvDBImage(value)
+	case "prov-db-disk-type":
+		mycluster.SetProvDbDiskType(value)
+	case "prov-db-disk-fs":
+		mycluster.SetProvDbDiskFS(value)
+	case "prov-db-disk-pool":
+		mycluster.SetProvDbDiskPool(value)
+	case "prov-db-disk-device":
+		mycluster.SetProvDbDiskDevice(value)
+	case "prov-db-service-type":
+		mycluster.SetProvDbServiceType(value)
+	case "proxysql-servers-credential":
+		mycluster.SetProxyServersCredential(value, config.ConstProxySqlproxy)
+	case "proxy-servers-backend-max-connections":
+		mycluster.SetProxyServersBackendMaxConnections(value)
+	case "proxy-servers-backend-max-replication-lag":
+		mycluster.SetProxyServersBackendMaxReplicationLag(value)
+	case "maxscale-servers-credential":
+		mycluster.SetProxyServersCredential(value, config.ConstProxyMaxscale)
+	case "shardproxy-servers-credential":
+		mycluster.SetProxyServersCredential(value, config.ConstProxySpider)
+	case "prov-proxy-disk-size":
+		mycluster.SetProxyDiskSize(value)
+	case "prov-proxy-cpu-cores":
+		mycluster.SetProxyCores(value)
+	case "prov-proxy-memory":
+		mycluster.SetProxyMemorySize(value)
+	case "prov-proxy-docker-proxysql-img":
+		mycluster.SetProvProxySQLImage(value)
+	case "prov-proxy-docker-maxscale-img":
+		mycluster.SetProvMaxscaleImage(value)
+	case "prov-proxy-docker-haproxy-img":
+		mycluster.SetProvHaproxyImage(value)
+	case "prov-proxy-docker-shardproxy-img":
+		mycluster.SetProvShardproxyImage(value)
+	case "prov-proxy-disk-type":
+		mycluster.SetProvProxyDiskType(value)
+	case "prov-proxy-disk-fs":
+		mycluster.SetProvProxyDiskFS(value)
+	case "prov-proxy-disk-pool":
+		mycluster.SetProvProxyDiskPool(value)
+	case "prov-proxy-disk-device":
+		mycluster.SetProvProxyDiskDevice(value)
+	case "prov-proxy-service-type":
+		mycluster.SetProvProxyServiceType(value)
+	case "monitoring-address":
+		mycluster.SetMonitoringAddress(value)
+	case "scheduler-db-servers-logical-backup-cron":
+		mycluster.SetSchedulerDbServersLogicalBackupCron(value)
+	case "scheduler-db-servers-logs-cron":
+		mycluster.SetSchedulerDbServersLogsCron(value)
+	case "scheduler-db-servers-logs-table-rotate-cron":
+		mycluster.SetSchedulerDbServersLogsTableRotateCron(value)
+	case "scheduler-db-servers-optimize-cron":
+		mycluster.SetSchedulerDbServersOptimizeCron(value)
+	case "scheduler-db-servers-physical-backup-cron":
+		mycluster.SetSchedulerDbServersPhysicalBackupCron(value)
+	case "scheduler-db-servers-incremental-backup-cron":
+		mycluster.SetSchedulerDbServersIncrementalBackupCron(value)
+	case "scheduler-rolling-reprov-cron":
+		mycluster.SetSchedulerRollingReprovCron(value)
+	case "scheduler-rolling-restart-cron":
+		mycluster.SetSchedulerRollingRestartCron(value)
+	case "scheduler-sla-rotate-cron":
+		mycluster.SetSchedulerSlaRotateCron(value)
+	case "scheduler-jobs-ssh-cron":
+		mycluster.SetSchedulerJobsSshCron(value)
+	case "scheduler-chaos-cron":
+		mycluster.SetSchedulerChaosCron(value)
+	case "scheduler-checksum-cron":
+		mycluster.SetSchedulerChecksumCron(value)
+	case "backup-binlogs-keep":
+		mycluster.SetBackupBinlogsKeep(value)
+
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxAddTag(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -1407,6 +1646,90 @@ func (repman *ReplicationManager) handlerMuxTests(w http.ResponseWriter, r *http
 	return
 }
 
+func (repman *ReplicationManager) handlerMuxChaosRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		report, err := mycluster.StartChaos()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(report)
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error encoding JSON: ", err)
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxChaosReports(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetChaosReports())
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error encoding JSON: ", err)
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxChaosReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		id, err := strconv.ParseInt(vars["chaosId"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid chaos id", 400)
+			return
+		}
+		report, ok := mycluster.GetChaosReport(id)
+		if !ok {
+			http.Error(w, "Chaos run Not Found", 404)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(report)
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error encoding JSON: ", err)
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxSettingsReload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -1637,7 +1960,11 @@ func (repman *ReplicationManager) handlerMuxClusterSchemaChecksumAllTable(w http
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		go mycluster.CheckAllTableChecksum()
+		if mycluster.IsChecksumRunning() {
+			http.Error(w, "A consistency check is already in progress", 500)
+			return
+		}
+		go mycluster.RunChecksum()
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
@@ -1656,7 +1983,11 @@ func (repman *ReplicationManager) handlerMuxClusterSchemaChecksumTable(w http.Re
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		go mycluster.CheckTableChecksum(vars["schemaName"], vars["tableName"])
+		if mycluster.IsChecksumRunning() {
+			http.Error(w, "A consistency check is already in progress", 500)
+			return
+		}
+		go mycluster.RunChecksumTable(vars["schemaName"], vars["tableName"])
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
@@ -1665,6 +1996,57 @@ func (repman *ReplicationManager) handlerMuxClusterSchemaChecksumTable(w http.Re
 
 }
 
+func (repman *ReplicationManager) handlerMuxClusterChecksums(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetChecksumReports())
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error encoding JSON: ", err)
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterChecksumRepair(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		report, err := mycluster.RepairChecksum()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(report)
+		if err != nil {
+			mycluster.LogPrintf(cluster.LvlErr, "API Error encoding JSON: ", err)
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxClusterSchemaUniversalTable(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
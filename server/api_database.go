@@ -11,296 +11,392 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/codegangsta/negroni"
 	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/cluster"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/capacity"
+	"github.com/signal18/replication-manager/utils/configdrift"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/digesthistory"
+	"github.com/signal18/replication-manager/utils/indexadvisor"
+	"github.com/signal18/replication-manager/utils/s18log"
 )
 
 func (repman *ReplicationManager) apiDatabaseUnprotectedHandler(router *mux.Router) {
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/is-master", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/is-master", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersIsMasterStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/is-slave", negroni.New(
+	)), apiRoute{Summary: "Check that the server is the master", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/is-slave", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersIsSlaveStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/is-master", negroni.New(
+	)), apiRoute{Summary: "Check that the server is a valid slave", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/is-master", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersPortIsMasterStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-restart", negroni.New(
+	)), apiRoute{Summary: "Check that the server is the master", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-restart", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerNeedRestart)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-reprov", negroni.New(
+	)), apiRoute{Summary: "Check if the server needs a restart", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-reprov", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerNeedReprov)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-start", negroni.New(
+	)), apiRoute{Summary: "Check if the server needs a reprovisioning", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-start", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerNeedStart)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-stop", negroni.New(
+	)), apiRoute{Summary: "Check if the server needs a start", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/need-stop", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerNeedStop)),
-	))
+	)), apiRoute{Summary: "Check if the server needs a stop", Public: true, Response: ""})
 
-	router.Handle("/api/clusters/{clusterName}/need-rolling-reprov", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/need-rolling-reprov", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerNeedRollingReprov)),
-	))
+	)), apiRoute{Summary: "Check if the cluster needs a rolling reprovisioning", Public: true, Response: ""})
 
-	router.Handle("/api/clusters/{clusterName}/need-rolling-restart", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/need-rolling-restart", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerNeedRollingRestart)),
-	))
+	)), apiRoute{Summary: "Check if the cluster needs a rolling restart", Public: true, Response: ""})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/is-slave", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/is-slave", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersPortIsSlaveStatus)),
-	))
+	)), apiRoute{Summary: "Check that the server is a valid slave", Public: true, Response: ""})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/config", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/config", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersPortConfig)),
-	))
+	)), apiRoute{Summary: "Configuration archive of a server", Grant: config.GrantProxyConfigFlag, Public: true, ContentType: "application/gzip"})
 
 }
 
 func (repman *ReplicationManager) apiDatabaseProtectedHandler(router *mux.Router) {
 	//PROTECTED ENDPOINTS FOR SERVERS
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/backup", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/{serverPort}/backup", negroni.New(
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersPortBackup)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/processlist", negroni.New(
+	)), apiRoute{Summary: "Check that the server can be backed up", Public: true, Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/processlist", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerProcesslist)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/variables", negroni.New(
+	)), apiRoute{Summary: "Process list", Grant: config.GrantDBLogs, Response: []dbhelper.Processlist{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/variables", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerVariables)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status", negroni.New(
+	)), apiRoute{Summary: "Global variables", Grant: config.GrantDBShowVariables, Response: []dbhelper.Variable{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status-delta", negroni.New(
+	)), apiRoute{Summary: "Global status", Grant: config.GrantDBShowStatus, Response: []dbhelper.Variable{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status-delta", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStatusDelta)),
-	))
+	)), apiRoute{Summary: "Global status delta since the last monitoring loop", Grant: config.GrantDBShowStatus, Response: []dbhelper.Variable{}})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/errorlog", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/errorlog", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerErrorLog)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/slow-queries", negroni.New(
+	)), apiRoute{Summary: "Error log", Grant: config.GrantDBLogs, Response: new(s18log.HttpLog)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/slow-queries", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSlowLog)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-statements-pfs", negroni.New(
+	)), apiRoute{Summary: "Slow queries", Grant: config.GrantDBLogs, Response: []dbhelper.PFSQuery{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-statements-pfs", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPFSStatements)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-statements-slow", negroni.New(
+	)), apiRoute{Summary: "Statements digest from performance schema", Grant: config.GrantDBLogs, Response: []dbhelper.PFSQuery{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-statements-slow", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPFSStatementsSlowLog)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/tables", negroni.New(
+	)), apiRoute{Summary: "Statements digest from the slow log", Grant: config.GrantDBLogs, Response: []dbhelper.PFSQuery{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-history/top", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDigestHistoryTop)),
+	)), apiRoute{Summary: "Top digests between from and to ordered on time, rows, count or errors", Grant: config.GrantDBLogs, Response: []digesthistory.Summary{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-history/regressions", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDigestHistoryRegressions)),
+	)), apiRoute{Summary: "Digests with a p95 latency since a time higher than in the baseline before it", Grant: config.GrantDBLogs, Response: []digesthistory.Regression{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-history/digests/{digest}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDigestHistorySparkline)),
+	)), apiRoute{Summary: "Activity of a digest in steps between from and to", Grant: config.GrantDBLogs, Response: []digesthistory.SparkPoint{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/index-advisor", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerIndexAdvisor)),
+	)), apiRoute{Summary: "Indexes to create for the slowest digests, redundant and unused indexes", Grant: config.GrantDBLogs, Response: indexadvisor.Report{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/config-drift", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerConfigDrift)),
+	)), apiRoute{Summary: "Drift of the variables from the generated configuration and from the master", Grant: config.GrantDBShowVariables, Response: configdrift.Report{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/config-drift-remediate", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerConfigDriftRemediate)),
+	)), apiRoute{Summary: "Set the dynamic variables drifting dangerously for a failover and the reprovisioning cookie for the static ones", Grant: config.GrantDBConfigFlag, Query: []apiParam{{"variable", "Remediate only this variable, of any class but role"}}, Response: []configdrift.Drift{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/capacity", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerCapacity)),
+	)), apiRoute{Summary: "Usage history and forecast of disk, data size, connections and binary logs", Grant: config.GrantDBShowStatus, Response: []capacity.Forecast{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/tables", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerTables)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/vtables", negroni.New(
+	)), apiRoute{Summary: "Tables", Grant: config.GrantDBShowSchema, Response: []dbhelper.Table{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/vtables", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerVTables)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/schemas", negroni.New(
+	)), apiRoute{Summary: "Tables of the sharding proxies", Grant: config.GrantDBShowSchema, Response: map[string]dbhelper.Table{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/schemas", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSchemas)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status-innodb", negroni.New(
+	)), apiRoute{Summary: "Schemas", Grant: config.GrantDBShowSchema, Response: []dbhelper.Table{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status-innodb", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerInnoDBStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/all-slaves-status", negroni.New(
+	)), apiRoute{Summary: "InnoDB status", Grant: config.GrantDBLogs, Response: []dbhelper.Variable{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/all-slaves-status", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerAllSlavesStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/master-status", negroni.New(
+	)), apiRoute{Summary: "Status of all replication channels", Grant: config.GrantDBReplication, Response: []dbhelper.SlaveStatus{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/master-status", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerMasterStatus)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/service-opensvc", negroni.New(
+	)), apiRoute{Summary: "Binary log position", Grant: config.GrantDBReplication, Response: new(dbhelper.MasterStatus)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/service-opensvc", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxGetDatabaseServiceConfig)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/meta-data-locks", negroni.New(
+	)), apiRoute{Summary: "OpenSVC service configuration", Response: ""})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/meta-data-locks", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerMetaDataLocks)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/query-response-time", negroni.New(
+	)), apiRoute{Summary: "Metadata locks", Grant: config.GrantDBLogs, Response: []dbhelper.MetaDataLock{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/query-response-time", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerQueryResponseTime)),
-	))
+	)), apiRoute{Summary: "Query response time histogram", Grant: config.GrantDBLogs, Response: []dbhelper.ResponseTime{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/errant-transactions", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerErrantTransactions)),
+	)), apiRoute{Summary: "Errant transactions", Response: new(errantTransactions)})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/start", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/start", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStart)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/stop", negroni.New(
+	)), apiRoute{Summary: "Start the server", Grant: config.GrantDBStart})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/stop", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStop)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/maintenance", negroni.New(
+	)), apiRoute{Summary: "Stop the server", Grant: config.GrantDBStop})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/maintenance", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerMaintenance)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/unprovision", negroni.New(
+	)), apiRoute{Summary: "Toggle the maintenance mode", Grant: config.GrantDBMaintenance})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/unprovision", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerUnprovision)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/provision", negroni.New(
+	)), apiRoute{Summary: "Unprovision the server", Grant: config.GrantProvDBUnprovision})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/provision", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerProvision)),
-	))
+	)), apiRoute{Summary: "Provision the server", Grant: config.GrantProvDBProvision})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-physical", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-physical", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupPhysical)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-logical", negroni.New(
+	)), apiRoute{Summary: "Physical backup", Grant: config.GrantDBBackup})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-physical-incremental", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupPhysicalIncremental)),
+	)), apiRoute{Summary: "Incremental physical backup on the last backup chain", Grant: config.GrantDBBackup})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-logical", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupLogical)),
-	))
+	)), apiRoute{Summary: "Logical backup", Grant: config.GrantDBBackup})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-error-log", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-error-log", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupErrorLog)),
-	))
+	)), apiRoute{Summary: "Archive the error log", Grant: config.GrantDBBackup})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-slowquery-log", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-slowquery-log", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupSlowQueryLog)),
-	))
+	)), apiRoute{Summary: "Archive the slow query log", Grant: config.GrantDBBackup})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/optimize", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/optimize", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerOptimize)),
-	))
+	)), apiRoute{Summary: "Optimize the tables", Grant: config.GrantDBMaintenance})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reseed/{backupMethod}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reseed/{backupMethod}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerReseed)),
-	))
+	)), apiRoute{Summary: "Reseed the server from a backup", Grant: config.GrantDBRestore})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-innodb-monitor", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-innodb-monitor", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetInnoDBMonitor)),
-	))
+	)), apiRoute{Summary: "Toggle the InnoDB monitor", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/wait-innodb-purge", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/wait-innodb-purge", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerWaitInnoDBPurge)),
-	))
+	)), apiRoute{Summary: "Wait for the InnoDB purge", Grant: config.GrantDBMaintenance})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query-capture", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query-capture", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSlowQueryCapture)),
-	))
+	)), apiRoute{Summary: "Toggle the slow query capture", Grant: config.GrantDBCapture})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/workload-capture/{seconds}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerWorkloadCapture)),
+	)), apiRoute{Summary: "Capture the workload of the server for a number of seconds", Grant: config.GrantDBCapture, Response: cluster.WorkloadCapture{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/workload-capture-stop", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerWorkloadCaptureStop)),
+	)), apiRoute{Summary: "Stop the workload capture of the server", Grant: config.GrantDBCapture})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/workload-replay/{captureId}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerWorkloadReplay)),
+	)), apiRoute{Summary: "Replay a workload capture on the server", Grant: config.GrantDBCapture})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query-table", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query-table", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSlowQueryTable)),
-	))
+	)), apiRoute{Summary: "Toggle the slow log to table", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSlowQuery)),
-	))
+	)), apiRoute{Summary: "Toggle the slow log", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-pfs-slow-query", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-pfs-slow-query", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchPFSSlowQuery)),
-	))
+	)), apiRoute{Summary: "Toggle the performance schema slow queries", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-long-query-time/{queryTime}", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-long-query-time/{queryTime}", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSetLongQueryTime)),
-	))
+	)), apiRoute{Summary: "Set long_query_time", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-read-only", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-read-only", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchReadOnly)),
-	))
+	)), apiRoute{Summary: "Toggle read only", Grant: config.GrantDBReadOnly})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-meta-data-locks", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-meta-data-locks", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchMetaDataLocks)),
-	))
+	)), apiRoute{Summary: "Toggle the metadata locks plugin", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-query-response-time", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-query-response-time", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchQueryResponseTime)),
-	))
+	)), apiRoute{Summary: "Toggle the query response time plugin", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-sql-error-log", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-sql-error-log", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchSqlErrorLog)),
-	))
+	)), apiRoute{Summary: "Toggle the SQL error log plugin", Grant: config.GrantDBLogs})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-master", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-master", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerResetMaster)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-slave-all", negroni.New(
+	)), apiRoute{Summary: "Reset master", Grant: config.GrantDBReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-slave-all", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerResetSlaveAll)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/flush-logs", negroni.New(
+	)), apiRoute{Summary: "Reset slave all", Grant: config.GrantDBReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/flush-logs", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerFlushLogs)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-pfs-queries", negroni.New(
+	)), apiRoute{Summary: "Flush logs", Grant: config.GrantDBBackup})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-pfs-queries", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerResetPFSQueries)),
-	))
+	)), apiRoute{Summary: "Reset the performance schema statements digest", Grant: config.GrantDBAnalyse})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/start-slave", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/start-slave", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStartSlave)),
-	))
+	)), apiRoute{Summary: "Start slave", Grant: config.GrantDBReplication})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/stop-slave", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/stop-slave", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStopSlave)),
-	))
+	)), apiRoute{Summary: "Stop slave", Grant: config.GrantDBReplication})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/skip-replication-event", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/skip-replication-event", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSkipReplicationEvent)),
-	))
+	)), apiRoute{Summary: "Skip a replication event", Grant: config.GrantDBReplication})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/errant-transactions/inject-empty-on-master", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerInjectErrantTransactions)),
+	)), apiRoute{Summary: "Inject the errant transactions as empty transactions on the master"})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/errant-transactions/reseed/{backupMethod}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerReseed)),
+	)), apiRoute{Summary: "Reseed the server to drop its errant transactions"})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/pg-drop-slot/{slotName}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPgDropSlot)),
+	)), apiRoute{Summary: "Drop a PostgreSQL replication slot"})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/pg-rewind", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPgRewind)),
+	)), apiRoute{Summary: "Rewind a PostgreSQL server"})
+
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/delayed-stop", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDelayedStop)),
+	)), apiRoute{Summary: "Position where the delayed slave is stopped", Grant: config.GrantDBReplication, Response: new(cluster.DelayedStop)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/delayed/stop-before/{method}/{value:.+}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDelayedStopBefore)),
+	)), apiRoute{Summary: "Stop the delayed slave before a GTID, a position or a time", Grant: config.GrantDBReplication, Response: new(cluster.DelayedStop)})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/delayed/extract-tables/{tables}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDelayedExtractTables)),
+	)), apiRoute{Summary: "Extract tables from the stopped delayed slave", Grant: config.GrantDBReplication})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/delayed/resume", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDelayedResume)),
+	)), apiRoute{Summary: "Resume the delayed slave", Grant: config.GrantDBReplication})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/run-jobs", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/run-jobs", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRunJobs)),
-	))
+	)), apiRoute{Summary: "Run the pending remote jobs", Grant: config.GrantClusterProcess})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/kill-thread", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/kill-thread", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryKillThread)),
-	))
+	)), apiRoute{Summary: "Kill the thread of a query", Grant: config.GrantDBKill})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/kill-query", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/kill-query", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryKillQuery)),
-	))
+	)), apiRoute{Summary: "Kill a query", Grant: config.GrantDBKill})
 
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/explain-pfs", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/explain-pfs", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryExplainPFS)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/explain-slowlog", negroni.New(
+	)), apiRoute{Summary: "Explain a performance schema query", Grant: config.GrantDBLogs, Response: []dbhelper.Explain{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/explain-slowlog", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryExplainSlowLog)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/analyze-pfs", negroni.New(
+	)), apiRoute{Summary: "Explain a slow log query", Grant: config.GrantDBLogs, Response: []dbhelper.Explain{}})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/analyze-pfs", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryAnalyzePFS)),
-	))
-	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/analyze-slowlog", negroni.New(
+	)), apiRoute{Summary: "Analyze a performance schema query", Grant: config.GrantDBAnalyse})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/analyze-slowlog", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryAnalyzePFS)),
-	))
+	)), apiRoute{Summary: "Analyze a slow log query", Grant: config.GrantDBAnalyse})
 }
 
 func (repman *ReplicationManager) handlerMuxQueryKillQuery(w http.ResponseWriter, r *http.Request) {
@@ -472,6 +568,28 @@ func (repman *ReplicationManager) handlerMuxServerBackupPhysical(w http.Response
 	}
 }
 
+func (repman *ReplicationManager) handlerMuxServerBackupPhysicalIncremental(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			node.JobBackupPhysicalIncremental()
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxServerBackupLogical(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -869,6 +987,94 @@ func (repman *ReplicationManager) handlerMuxServerResetPFSQueries(w http.Respons
 	}
 }
 
+func (repman *ReplicationManager) handlerMuxServerWorkloadCapture(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			seconds, err := strconv.Atoi(vars["seconds"])
+			if err != nil {
+				http.Error(w, "Wrong duration", 500)
+				return
+			}
+			capture, err := node.StartWorkloadCapture(time.Duration(seconds) * time.Second)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err = e.Encode(capture)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerWorkloadCaptureStop(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			err := node.StopWorkloadCapture()
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerWorkloadReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			err := mycluster.ReplayWorkload(vars["captureId"], node)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxSwitchSlowQueryCapture(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -1422,7 +1628,13 @@ func (repman *ReplicationManager) handlerMuxServerProcesslist(w http.ResponseWri
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerMetaDataLocks(w http.ResponseWriter, r *http.Request) {
+type errantTransactions struct {
+	GtidSet      string                `json:"gtidSet"`
+	Count        uint64                `json:"count"`
+	Transactions []dbhelper.GtidEvents `json:"transactions"`
+}
+
+func (repman *ReplicationManager) handlerMuxServerErrantTransactions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1433,10 +1645,19 @@ func (repman *ReplicationManager) handlerMuxServerMetaDataLocks(w http.ResponseW
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
 		if node != nil && node.IsDown() == false {
+			errant, err := node.GetErrantTransactions()
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			events, err := node.GetErrantTransactionsEvents()
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			prl := node.GetMetaDataLocks()
-			err := e.Encode(prl)
+			err = e.Encode(errantTransactions{errant.String(), errant.Count(), events})
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
 				return
@@ -1446,13 +1667,14 @@ func (repman *ReplicationManager) handlerMuxServerMetaDataLocks(w http.ResponseW
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("503 -Not a Valid Server!"))
 		}
+
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerQueryResponseTime(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerInjectErrantTransactions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1463,15 +1685,11 @@ func (repman *ReplicationManager) handlerMuxServerQueryResponseTime(w http.Respo
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
 		if node != nil && node.IsDown() == false {
-			e := json.NewEncoder(w)
-			e.SetIndent("", "\t")
-			prl := node.GetQueryResponseTime()
-			err := e.Encode(prl)
+			err := node.InjectErrantTransactionsOnMaster()
 			if err != nil {
-				http.Error(w, "Encoding error", 500)
+				http.Error(w, err.Error(), 500)
 				return
 			}
-			return
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("503 -Not a Valid Server!"))
@@ -1482,7 +1700,7 @@ func (repman *ReplicationManager) handlerMuxServerQueryResponseTime(w http.Respo
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerErrorLog(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerPgDropSlot(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1492,28 +1710,23 @@ func (repman *ReplicationManager) handlerMuxServerErrorLog(w http.ResponseWriter
 			return
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
-		if node != nil && node.IsDown() == false {
-			e := json.NewEncoder(w)
-			e.SetIndent("", "\t")
-			l := node.GetErrorLog()
-			err := e.Encode(l)
+		if node != nil && node.IsDown() == false && node.IsPgStream() {
+			err := node.PgDropSlot(vars["slotName"])
 			if err != nil {
-				http.Error(w, "Encoding error", 500)
+				http.Error(w, err.Error(), 500)
 				return
 			}
-			return
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("503 -Not a Valid Server!"))
 		}
-
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerSlowLog(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerPgRewind(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1523,28 +1736,23 @@ func (repman *ReplicationManager) handlerMuxServerSlowLog(w http.ResponseWriter,
 			return
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
-		if node != nil && node.IsDown() == false {
-			e := json.NewEncoder(w)
-			e.SetIndent("", "\t")
-			l := node.GetSlowLog()
-			err := e.Encode(l)
+		if node != nil && node.IsPgStream() {
+			err := node.JobRejoinPgRewind(mycluster.GetMaster())
 			if err != nil {
-				http.Error(w, "Encoding error", 500)
+				http.Error(w, err.Error(), 500)
 				return
 			}
-			return
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("503 -Not a Valid Server!"))
 		}
-
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerPFSStatements(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerMetaDataLocks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1557,8 +1765,8 @@ func (repman *ReplicationManager) handlerMuxServerPFSStatements(w http.ResponseW
 		if node != nil && node.IsDown() == false {
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetPFSStatements()
-			err := e.Encode(l)
+			prl := node.GetMetaDataLocks()
+			err := e.Encode(prl)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
 				return
@@ -1574,7 +1782,7 @@ func (repman *ReplicationManager) handlerMuxServerPFSStatements(w http.ResponseW
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerPFSStatementsSlowLog(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerQueryResponseTime(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1587,8 +1795,8 @@ func (repman *ReplicationManager) handlerMuxServerPFSStatementsSlowLog(w http.Re
 		if node != nil && node.IsDown() == false {
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetPFSStatementsSlowLog()
-			err := e.Encode(l)
+			prl := node.GetQueryResponseTime()
+			err := e.Encode(prl)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
 				return
@@ -1598,14 +1806,13 @@ func (repman *ReplicationManager) handlerMuxServerPFSStatementsSlowLog(w http.Re
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("503 -Not a Valid Server!"))
 		}
-
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerVariables(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerErrorLog(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1618,7 +1825,7 @@ func (repman *ReplicationManager) handlerMuxServerVariables(w http.ResponseWrite
 		if node != nil && node.IsDown() == false {
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetVariables()
+			l := node.GetErrorLog()
 			err := e.Encode(l)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
@@ -1636,7 +1843,7 @@ func (repman *ReplicationManager) handlerMuxServerVariables(w http.ResponseWrite
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerStatus(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerSlowLog(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1649,7 +1856,7 @@ func (repman *ReplicationManager) handlerMuxServerStatus(w http.ResponseWriter,
 		if node != nil && node.IsDown() == false {
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetStatus()
+			l := node.GetSlowLog()
 			err := e.Encode(l)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
@@ -1667,7 +1874,7 @@ func (repman *ReplicationManager) handlerMuxServerStatus(w http.ResponseWriter,
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerStatusDelta(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerPFSStatements(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1680,7 +1887,7 @@ func (repman *ReplicationManager) handlerMuxServerStatusDelta(w http.ResponseWri
 		if node != nil && node.IsDown() == false {
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetStatusDelta()
+			l := node.GetPFSStatements()
 			err := e.Encode(l)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
@@ -1691,14 +1898,25 @@ func (repman *ReplicationManager) handlerMuxServerStatusDelta(w http.ResponseWri
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("503 -Not a Valid Server!"))
 		}
-
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerTables(w http.ResponseWriter, r *http.Request) {
+// digestHistoryWindow reads the from and to parameters of the digest history, the
+// last 24 hours by default
+func digestHistoryWindow(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	to, err := digesthistory.ParseTime(r.URL.Query().Get("to"), now, now)
+	if err != nil {
+		return to, to, err
+	}
+	from, err := digesthistory.ParseTime(r.URL.Query().Get("from"), now, to.Add(-24*time.Hour))
+	return from, to, err
+}
+
+func (repman *ReplicationManager) handlerMuxServerDigestHistoryTop(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1708,28 +1926,48 @@ func (repman *ReplicationManager) handlerMuxServerTables(w http.ResponseWriter,
 			return
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
-		if node != nil && node.IsDown() == false {
+		if node != nil {
+			from, to, err := digestHistoryWindow(r)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			order := r.URL.Query().Get("order")
+			if order == "" {
+				order = digesthistory.ConstOrderLatency
+			}
+			limit := 20
+			if l := r.URL.Query().Get("limit"); l != "" {
+				if limit, err = strconv.Atoi(l); err != nil {
+					http.Error(w, "Wrong limit", 400)
+					return
+				}
+			}
+			top, err := node.GetDigestHistoryTop(from, to, order, limit)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetTables()
-			err := e.Encode(l)
+			err = e.Encode(top)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
 				return
 			}
-			return
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("503 -Not a Valid Server!"))
+			http.Error(w, "Server Not Found", 500)
+			return
 		}
-
 	} else {
-		http.Error(w, "No cluster", 500)
+		http.Error(w, "Cluster Not Found", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxServerVTables(w http.ResponseWriter, r *http.Request) {
+// handlerMuxServerDigestHistoryRegressions compares the digests since a time, the
+// last hour by default, with the baseline before it, the previous 24 hours by default
+func (repman *ReplicationManager) handlerMuxServerDigestHistoryRegressions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1739,28 +1977,46 @@ func (repman *ReplicationManager) handlerMuxServerVTables(w http.ResponseWriter,
 			return
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
-		if node != nil && node.IsDown() == false {
+		if node != nil {
+			now := time.Now()
+			to, err := digesthistory.ParseTime(r.URL.Query().Get("to"), now, now)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			since, err := digesthistory.ParseTime(r.URL.Query().Get("since"), now, to.Add(-time.Hour))
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			baseline, err := digesthistory.ParseTime(r.URL.Query().Get("baseline"), now, since.Add(-24*time.Hour))
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			regressions, err := node.GetDigestHistoryRegressions(baseline, since, to)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
 			e := json.NewEncoder(w)
 			e.SetIndent("", "\t")
-			l := node.GetVTables()
-			err := e.Encode(l)
+			err = e.Encode(regressions)
 			if err != nil {
 				http.Error(w, "Encoding error", 500)
 				return
 			}
-			return
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("503 -Not a Valid Server!"))
+			http.Error(w, "Server Not Found", 500)
+			return
 		}
-
 	} else {
-		http.Error(w, "No cluster", 500)
+		http.Error(w, "Cluster Not Found", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxRunJobs(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServerDigestHistorySparkline(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -1771,20 +2027,373 @@ func (repman *ReplicationManager) handlerMuxRunJobs(w http.ResponseWriter, r *ht
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
 		if node != nil {
-			err := node.JobRunViaSSH()
+			from, to, err := digestHistoryWindow(r)
 			if err != nil {
-				http.Error(w, "Encoding running job", 500)
+				http.Error(w, err.Error(), 400)
 				return
 			}
-			return
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("503 -Not a Valid Server!"))
-		}
-	} else {
-		http.Error(w, "No cluster", 500)
-		return
-	}
+			steps := 60
+			if s := r.URL.Query().Get("steps"); s != "" {
+				if steps, err = strconv.Atoi(s); err != nil || steps > 10000 {
+					http.Error(w, "Wrong steps", 400)
+					return
+				}
+			}
+			points, err := node.GetDigestHistorySparkline(vars["digest"], from, to, steps)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err = e.Encode(points)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerIndexAdvisor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			report, err := node.GetIndexAdvice()
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err = e.Encode(report)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerConfigDrift(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err := e.Encode(node.GetConfigDrift())
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerConfigDriftRemediate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			drifts, err := node.RemediateConfigDrift(r.URL.Query().Get("variable"))
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err = e.Encode(drifts)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerCapacity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err := e.Encode(node.GetCapacityForecasts())
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerPFSStatementsSlowLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			l := node.GetPFSStatementsSlowLog()
+			err := e.Encode(l)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerVariables(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			l := node.GetVariables()
+			err := e.Encode(l)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			l := node.GetStatus()
+			err := e.Encode(l)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerStatusDelta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			l := node.GetStatusDelta()
+			err := e.Encode(l)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerTables(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			l := node.GetTables()
+			err := e.Encode(l)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerVTables(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			l := node.GetVTables()
+			err := e.Encode(l)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxRunJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			err := node.JobRunViaSSH()
+			if err != nil {
+				http.Error(w, "Encoding running job", 500)
+				return
+			}
+			return
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
 }
 
 func (repman *ReplicationManager) handlerMuxServerSchemas(w http.ResponseWriter, r *http.Request) {
@@ -1977,3 +2586,121 @@ func (repman *ReplicationManager) handlerMuxGetDatabaseServiceConfig(w http.Resp
 		return
 	}
 }
+
+func (repman *ReplicationManager) handlerMuxServerDelayedStop(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			stop, err := node.GetDelayedStop()
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err = e.Encode(stop)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerDelayedStopBefore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			stop, err := node.JobDelayedStopBefore(vars["method"], vars["value"])
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			err = e.Encode(stop)
+			if err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerDelayedExtractTables(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			err := node.JobDelayedExtractTables(strings.Split(vars["tables"], ","))
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerDelayedResume(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if !repman.IsValidClusterACL(r, mycluster) {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil && node.IsDown() == false {
+			err := node.JobDelayedResume()
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("503 -Not a Valid Server!"))
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
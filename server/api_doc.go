@@ -0,0 +1,292 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiRoute documents a route of the JWT API, the OpenAPI specification served on
+// /api/openapi.json is generated from the documented routes of the router
+type apiRoute struct {
+	Summary string
+	// GET when empty, routes are not restricted to a method
+	Method string
+	// ACL grant checked by the handler, empty when a valid token is enough
+	Grant string
+	// served without token
+	Public bool
+	Query  []apiParam
+	// values of the JSON request and response types, a string response is plain text
+	Request  interface{}
+	Response interface{}
+	// content type of a response that is not JSON
+	ContentType string
+}
+
+type apiParam struct {
+	Name string
+	Desc string
+}
+
+// apiStatus is the answer of the status routes
+type apiStatus struct {
+	Alive string `json:"alive"`
+}
+
+var apiRoutes = make(map[string]apiRoute)
+
+// apiDoc attaches the documentation to a route
+func apiDoc(route *mux.Route, doc apiRoute) *mux.Route {
+	// the first route registered on a path is the one served
+	if tpl, err := route.GetPathTemplate(); err == nil {
+		if _, ok := apiRoutes[tpl]; !ok {
+			apiRoutes[tpl] = doc
+		}
+	}
+	return route
+}
+
+var apiPathVar = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+// apiOperationId builds the operation id from the path, the cluster, server and proxy
+// names are skipped unless they end the path as they are implied by the next segment
+func apiOperationId(tpl string) string {
+	var id string
+	segs := strings.Split(strings.TrimPrefix(tpl, "/api/"), "/")
+	for i, seg := range segs {
+		if m := apiPathVar.FindStringSubmatch(seg); m != nil {
+			if (m[1] == "clusterName" || m[1] == "serverName" || m[1] == "proxyName") && i < len(segs)-1 {
+				continue
+			}
+			seg = m[1]
+		}
+		for _, w := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '.' }) {
+			if id == "" {
+				id = strings.ToLower(w[:1]) + w[1:]
+			} else {
+				id += strings.ToUpper(w[:1]) + w[1:]
+			}
+		}
+	}
+	return id
+}
+
+func apiTag(tpl string) string {
+	switch {
+	case strings.Contains(tpl, "/servers/{serverName}"):
+		return "servers"
+	case strings.Contains(tpl, "/proxies/{proxyName}"):
+		return "proxies"
+	case strings.HasPrefix(tpl, "/api/clusters"):
+		return "clusters"
+	}
+	return "monitor"
+}
+
+// openAPISchemas collects the JSON schemas of the Go types in the components
+type openAPISchemas map[string]interface{}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+func (schemas openAPISchemas) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemas.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemas.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return schemas.object(t)
+		}
+		name := strings.Replace(t.String(), "*", "", -1)
+		if _, ok := schemas[name]; !ok {
+			// placeholder for recursive types
+			schemas[name] = nil
+			schemas[name] = schemas.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (schemas openAPISchemas) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	schemas.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (schemas openAPISchemas) fields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			schemas.fields(ft, props)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		switch ft.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(tag, ",string") {
+			props[name] = map[string]interface{}{"type": "string"}
+			continue
+		}
+		props[name] = schemas.schema(f.Type)
+	}
+}
+
+func (schemas openAPISchemas) content(contentType string, v interface{}) map[string]interface{} {
+	t := reflect.TypeOf(v)
+	switch {
+	case contentType != "" && t == nil:
+		return map[string]interface{}{contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}}
+	case contentType != "":
+		return map[string]interface{}{contentType: map[string]interface{}{"schema": schemas.schema(t)}}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	}
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.schema(t)}}
+}
+
+func (repman *ReplicationManager) getOpenAPI(router *mux.Router) (map[string]interface{}, error) {
+	schemas := make(openAPISchemas)
+	paths := make(map[string]interface{})
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		doc, ok := apiRoutes[tpl]
+		if !ok {
+			return nil
+		}
+		op := map[string]interface{}{
+			"operationId": apiOperationId(tpl),
+			"summary":     doc.Summary,
+			"tags":        []string{apiTag(tpl)},
+		}
+		var params []interface{}
+		for _, m := range apiPathVar.FindAllStringSubmatch(tpl, -1) {
+			params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+		}
+		for _, q := range doc.Query {
+			params = append(params, map[string]interface{}{"name": q.Name, "in": "query", "description": q.Desc, "schema": map[string]interface{}{"type": "string"}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if doc.Request != nil {
+			op["requestBody"] = map[string]interface{}{"required": true, "content": schemas.content("", doc.Request)}
+		}
+		ok200 := map[string]interface{}{"description": "OK"}
+		if doc.Response != nil || doc.ContentType != "" {
+			ok200["content"] = schemas.content(doc.ContentType, doc.Response)
+		}
+		responses := map[string]interface{}{"200": ok200, "500": map[string]interface{}{"description": "No cluster or server"}}
+		if doc.Public {
+			op["security"] = []interface{}{}
+		} else {
+			responses["401"] = map[string]interface{}{"description": "Token is not valid"}
+		}
+		if doc.Grant != "" {
+			op["x-acl-grant"] = doc.Grant
+			responses["403"] = map[string]interface{}{"description": "No valid ACL"}
+		}
+		op["responses"] = responses
+		method := strings.ToLower(doc.Method)
+		if method == "" {
+			method = "get"
+		}
+		paths[apiPathVar.ReplaceAllString(tpl, "{$1}")] = map[string]interface{}{method: op}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "replication-manager",
+			"version": repman.Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"jwt": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"jwt": []string{}}},
+	}, nil
+}
+
+func (repman *ReplicationManager) handlerMuxOpenAPI(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		spec, err := repman.getOpenAPI(router)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		if err := e.Encode(spec); err != nil {
+			http.Error(w, "Encoding error", 500)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/cluster"
+)
+
+func TestAPIRoutesDocumented(t *testing.T) {
+	router := new(ReplicationManager).apiRouter()
+	ids := make(map[string]string)
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, "/api/") {
+			return nil
+		}
+		doc, ok := apiRoutes[tpl]
+		if !ok || doc.Summary == "" {
+			t.Errorf("Route %s is not documented, use apiDoc when registering it", tpl)
+			return nil
+		}
+		id := apiOperationId(tpl)
+		if other, ok := ids[id]; ok && other != tpl {
+			t.Errorf("Operation id %s of %s already used by %s", id, tpl, other)
+		}
+		ids[id] = tpl
+		return nil
+	})
+}
+
+func TestAPIRoutesGrant(t *testing.T) {
+	new(ReplicationManager).apiRouter()
+	for tpl, doc := range apiRoutes {
+		if doc.Grant == "" {
+			continue
+		}
+		url := apiPathVar.ReplaceAllStringFunc(tpl, func(v string) string {
+			if v == "{clusterName}" {
+				return "c1"
+			}
+			return "p" + strings.Trim(v, "{}")
+		})
+		c := new(cluster.Cluster)
+		c.Name = "c1"
+		c.APIUsers = map[string]cluster.APIUser{"u": {User: "u", Password: "p", Grants: map[string]bool{doc.Grant: true}}}
+		if !c.IsValidACL("u", "p", url) {
+			t.Errorf("Grant %s documented on %s does not pass the ACL", doc.Grant, tpl)
+		}
+	}
+}
+
+func TestAPIOpenAPI(t *testing.T) {
+	router := new(ReplicationManager).apiRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if w.Code != 200 {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spec.Paths["/api/clusters/{clusterName}/servers/{serverName}/actions/delayed/stop-before/{method}/{value}"]["get"]; !ok {
+		t.Error("Path variables patterns must be removed from the paths")
+	}
+	if _, ok := spec.Paths["/api/login"]["post"]; !ok {
+		t.Error("Login must be documented as POST")
+	}
+	for _, name := range []string{"cluster.ServerMonitor", "cluster.Cluster", "config.Config"} {
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("Schema %s missing", name)
+		}
+	}
+	for name, schema := range spec.Components.Schemas {
+		if string(schema) == "null" {
+			t.Errorf("Schema %s not resolved", name)
+		}
+	}
+}
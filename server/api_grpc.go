@@ -0,0 +1,544 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/signal18/replication-manager/cluster"
+	"github.com/signal18/replication-manager/repmanv3"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// apiGrpcServer implements the gRPC API, every call maps to the REST route it mirrors
+// so that the ACL grants are the same for both APIs
+type apiGrpcServer struct {
+	repmanv3.UnimplementedClusterServiceServer
+	repman *ReplicationManager
+}
+
+type apiGrpcUser struct {
+	name     string
+	password string
+}
+
+type apiGrpcUserKey struct{}
+
+func (repman *ReplicationManager) grpcserver() {
+	certFile, keyFile := repman.Conf.ShareDir+"/server.crt", repman.Conf.ShareDir+"/server.key"
+	if repman.Conf.MonitoringSSLCert != "" {
+		certFile, keyFile = repman.Conf.MonitoringSSLCert, repman.Conf.MonitoringSSLKey
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Errorf("gRPC API can't load certificate: %s", err)
+		return
+	}
+	lis, err := net.Listen("tcp", repman.Conf.APIBind+":"+repman.Conf.APIGrpcPort)
+	if err != nil {
+		log.Errorf("gRPC API can't start: %s", err)
+		return
+	}
+	s := repman.newGrpcServer(grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	log.Info("Starting gRPC API on " + repman.Conf.APIBind + ":" + repman.Conf.APIGrpcPort)
+	if err := s.Serve(lis); err != nil {
+		log.Errorf("gRPC API can't start: %s", err)
+	}
+}
+
+func (repman *ReplicationManager) newGrpcServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(repman.grpcUnaryAuth), grpc.StreamInterceptor(repman.grpcStreamAuth))
+	s := grpc.NewServer(opts...)
+	repmanv3.RegisterClusterServiceServer(s, &apiGrpcServer{repman: repman})
+	return s
+}
+
+// getGrpcUser validates the JWT token of the call metadata
+func getGrpcUser(ctx context.Context) (apiGrpcUser, error) {
+	var user apiGrpcUser
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 || !strings.HasPrefix(auth[0], "Bearer ") {
+		return user, status.Error(codes.Unauthenticated, "No token")
+	}
+	token, err := jwt.Parse(strings.TrimPrefix(auth[0], "Bearer "), func(token *jwt.Token) (interface{}, error) {
+		return jwt.ParseRSAPublicKeyFromPEM(verificationKey)
+	})
+	if err != nil || !token.Valid {
+		return user, status.Error(codes.Unauthenticated, "Token is not valid")
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	userinfo, ok := claims["CustomUserInfo"].(map[string]interface{})
+	if !ok {
+		return user, status.Error(codes.Unauthenticated, "Token is not valid")
+	}
+	user.name, _ = userinfo["Name"].(string)
+	user.password, _ = userinfo["Password"].(string)
+	return user, nil
+}
+
+func (repman *ReplicationManager) grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod == repmanv3.ClusterService_Login_FullMethodName {
+		return handler(ctx, req)
+	}
+	user, err := getGrpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, apiGrpcUserKey{}, user), req)
+}
+
+type apiGrpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *apiGrpcStream) Context() context.Context {
+	return s.ctx
+}
+
+func (repman *ReplicationManager) grpcStreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	user, err := getGrpcUser(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &apiGrpcStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), apiGrpcUserKey{}, user)})
+}
+
+// getCluster returns the cluster if the user of the call is granted the equivalent REST route,
+// reads are granted to the users of the cluster as the topology routes only check the token
+func (s *apiGrpcServer) getCluster(ctx context.Context, name string, route string) (*cluster.Cluster, error) {
+	mycluster := s.repman.getClusterByName(name)
+	if mycluster == nil {
+		return nil, status.Errorf(codes.NotFound, "No cluster %s", name)
+	}
+	user, _ := ctx.Value(apiGrpcUserKey{}).(apiGrpcUser)
+	if !mycluster.IsValidACL(user.name, user.password, "/api/clusters/"+mycluster.Name+route) {
+		return nil, status.Error(codes.PermissionDenied, "No valid ACL")
+	}
+	return mycluster, nil
+}
+
+func (s *apiGrpcServer) Login(ctx context.Context, in *repmanv3.LoginRequest) (*repmanv3.LoginResponse, error) {
+	for _, mycluster := range s.repman.Clusters {
+		if mycluster.IsValidACL(in.Username, in.Password, "/api/login") {
+			token, err := signToken(in.Username, in.Password)
+			if err != nil {
+				return nil, status.Error(codes.Internal, "Error while signing the token")
+			}
+			return &repmanv3.LoginResponse{Token: token}, nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "Invalid credentials")
+}
+
+func (s *apiGrpcServer) ListClusters(ctx context.Context, in *repmanv3.ListClustersRequest) (*repmanv3.ListClustersResponse, error) {
+	res := new(repmanv3.ListClustersResponse)
+	for name := range s.repman.Clusters {
+		if _, err := s.getCluster(ctx, name, ""); err == nil {
+			res.Clusters = append(res.Clusters, name)
+		}
+	}
+	sort.Strings(res.Clusters)
+	return res, nil
+}
+
+func (s *apiGrpcServer) GetCluster(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.Cluster, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "")
+	if err != nil {
+		return nil, err
+	}
+	res := &repmanv3.Cluster{
+		Name:            mycluster.Name,
+		Topology:        mycluster.GetTopology(),
+		Status:          "errors",
+		Interactive:     mycluster.Conf.Interactive,
+		Failable:        mycluster.IsFailable,
+		InFailover:      mycluster.IsInFailover(),
+		FailoverCounter: int32(mycluster.FailoverCtr),
+		Traffic:         mycluster.GetTraffic(),
+	}
+	if mycluster.GetStatus() {
+		res.Status = "running"
+	}
+	if m := mycluster.GetMaster(); m != nil {
+		res.Master = m.URL
+	}
+	if mycluster.FailoverTs > 0 {
+		res.FailoverTime = timestamppb.New(time.Unix(mycluster.FailoverTs, 0))
+	}
+	return res, nil
+}
+
+func getGrpcServer(server *cluster.ServerMonitor) *repmanv3.Server {
+	res := &repmanv3.Server{
+		Id:                server.Id,
+		Url:               server.URL,
+		Host:              server.Host,
+		Port:              server.Port,
+		State:             server.State,
+		PrevState:         server.PrevState,
+		Maintenance:       server.IsMaintenance,
+		ReadOnly:          server.ReadOnly == "ON",
+		VirtualMaster:     server.IsVirtualMaster,
+		FailCount:         int32(server.FailCount),
+		ReplicationDelay:  server.GetReplicationDelay(),
+		ReplicationHealth: server.ReplicationHealth,
+	}
+	if server.CurrentGtid != nil {
+		res.CurrentGtid = server.CurrentGtid.Sprint()
+	}
+	if server.SlaveGtid != nil {
+		res.SlaveGtid = server.SlaveGtid.Sprint()
+	}
+	for _, ss := range server.Replications {
+		rpl := &repmanv3.Replication{
+			ConnectionName:      ss.ConnectionName.String,
+			MasterHost:          ss.MasterHost.String,
+			MasterPort:          ss.MasterPort.String,
+			IoRunning:           ss.SlaveIORunning.String == "Yes",
+			SqlRunning:          ss.SlaveSQLRunning.String == "Yes",
+			SecondsBehindMaster: -1,
+			LastIoError:         ss.LastIOError.String,
+			LastSqlError:        ss.LastSQLError.String,
+			UsingGtid:           ss.UsingGtid.String,
+			GtidIoPos:           ss.GtidIOPos.String,
+		}
+		if ss.SecondsBehindMaster.Valid {
+			rpl.SecondsBehindMaster = ss.SecondsBehindMaster.Int64
+		}
+		res.Replications = append(res.Replications, rpl)
+	}
+	return res
+}
+
+func (s *apiGrpcServer) ListServers(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.ListServersResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "")
+	if err != nil {
+		return nil, err
+	}
+	res := new(repmanv3.ListServersResponse)
+	for _, server := range mycluster.GetServers() {
+		res.Servers = append(res.Servers, getGrpcServer(server))
+	}
+	return res, nil
+}
+
+func (s *apiGrpcServer) GetMaster(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.Server, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "")
+	if err != nil {
+		return nil, err
+	}
+	m := mycluster.GetMaster()
+	if m == nil {
+		return nil, status.Error(codes.NotFound, "No master")
+	}
+	return getGrpcServer(m), nil
+}
+
+func getGrpcBackends(backends []cluster.Backend) []*repmanv3.Backend {
+	var res []*repmanv3.Backend
+	for _, b := range backends {
+		res = append(res, &repmanv3.Backend{Host: b.Host, Port: b.Port, Status: b.PrxStatus, Connections: b.PrxConnections, Maintenance: b.PrxMaintenance})
+	}
+	return res
+}
+
+func (s *apiGrpcServer) ListProxies(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.ListProxiesResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "")
+	if err != nil {
+		return nil, err
+	}
+	res := new(repmanv3.ListProxiesResponse)
+	for _, prx := range mycluster.GetProxies() {
+		res.Proxies = append(res.Proxies, &repmanv3.Proxy{
+			Id:            prx.Id,
+			Name:          prx.Name,
+			Type:          prx.Type,
+			Host:          prx.Host,
+			Port:          prx.Port,
+			State:         prx.State,
+			BackendsWrite: getGrpcBackends(prx.BackendsWrite),
+			BackendsRead:  getGrpcBackends(prx.BackendsRead),
+		})
+	}
+	return res, nil
+}
+
+func (s *apiGrpcServer) ListAlerts(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.ListAlertsResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "")
+	if err != nil {
+		return nil, err
+	}
+	res := new(repmanv3.ListAlertsResponse)
+	for _, a := range mycluster.GetStateMachine().GetOpenErrors() {
+		res.Errors = append(res.Errors, &repmanv3.Alert{Number: a.ErrNumber, Desc: a.ErrDesc, From: a.ErrFrom})
+	}
+	for _, a := range mycluster.GetStateMachine().GetOpenWarnings() {
+		res.Warnings = append(res.Warnings, &repmanv3.Alert{Number: a.ErrNumber, Desc: a.ErrDesc, From: a.ErrFrom})
+	}
+	return res, nil
+}
+
+func (s *apiGrpcServer) Switchover(ctx context.Context, in *repmanv3.SwitchoverRequest) (*repmanv3.ActionResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/actions/switchover")
+	if err != nil {
+		return nil, err
+	}
+	mycluster.LogPrintf(cluster.LvlInfo, "gRPC API receive switchover request")
+	if mycluster.IsMasterFailed() {
+		mycluster.LogPrintf(cluster.LvlErr, "Master failed, cannot initiate switchover")
+		return nil, status.Error(codes.FailedPrecondition, "Master failed")
+	}
+	savedPrefMaster := mycluster.GetConf().PrefMaster
+	if in.PreferedMaster != "" {
+		mycluster.LogPrintf(cluster.LvlInfo, "API force for prefered master: %s", in.PreferedMaster)
+		if mycluster.IsInHostList(in.PreferedMaster) {
+			mycluster.SetPrefMaster(in.PreferedMaster)
+		} else {
+			mycluster.LogPrintf(cluster.LvlInfo, "Prefered master: not found in database servers %s", in.PreferedMaster)
+		}
+	}
+	done := mycluster.MasterFailover(false)
+	mycluster.SetPrefMaster(savedPrefMaster)
+	return &repmanv3.ActionResponse{Done: done}, nil
+}
+
+func (s *apiGrpcServer) Failover(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.ActionResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/actions/failover")
+	if err != nil {
+		return nil, err
+	}
+	return &repmanv3.ActionResponse{Done: mycluster.MasterFailover(true)}, nil
+}
+
+func (s *apiGrpcServer) ResetFailoverControl(ctx context.Context, in *repmanv3.ClusterRequest) (*repmanv3.ActionResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/actions/reset-failover-control")
+	if err != nil {
+		return nil, err
+	}
+	mycluster.ResetFailoverCtr()
+	return &repmanv3.ActionResponse{Done: true}, nil
+}
+
+func (s *apiGrpcServer) SetTraffic(ctx context.Context, in *repmanv3.SetTrafficRequest) (*repmanv3.ActionResponse, error) {
+	route := "/actions/stop-traffic"
+	if in.Traffic {
+		route = "/actions/start-traffic"
+	}
+	mycluster, err := s.getCluster(ctx, in.Cluster, route)
+	if err != nil {
+		return nil, err
+	}
+	mycluster.SetTraffic(in.Traffic)
+	return &repmanv3.ActionResponse{Done: true}, nil
+}
+
+func (s *apiGrpcServer) SwitchSetting(ctx context.Context, in *repmanv3.SettingRequest) (*repmanv3.ActionResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/settings/actions/switch/"+in.Setting)
+	if err != nil {
+		return nil, err
+	}
+	s.repman.switchSettings(mycluster, in.Setting)
+	return &repmanv3.ActionResponse{Done: true}, nil
+}
+
+func (s *apiGrpcServer) SetSetting(ctx context.Context, in *repmanv3.SettingRequest) (*repmanv3.ActionResponse, error) {
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/settings/actions/set/"+in.Setting+"/"+in.Value)
+	if err != nil {
+		return nil, err
+	}
+	s.repman.setSettings(mycluster, in.Setting, in.Value)
+	return &repmanv3.ActionResponse{Done: true}, nil
+}
+
+var apiGrpcServerActions = map[repmanv3.ServerActionRequest_Action]string{
+	repmanv3.ServerActionRequest_START:           "start",
+	repmanv3.ServerActionRequest_STOP:            "stop",
+	repmanv3.ServerActionRequest_MAINTENANCE:     "maintenance",
+	repmanv3.ServerActionRequest_START_SLAVE:     "start-slave",
+	repmanv3.ServerActionRequest_STOP_SLAVE:      "stop-slave",
+	repmanv3.ServerActionRequest_RESET_SLAVE_ALL: "reset-slave-all",
+	repmanv3.ServerActionRequest_OPTIMIZE:        "optimize",
+	repmanv3.ServerActionRequest_BACKUP_LOGICAL:  "backup-logical",
+	repmanv3.ServerActionRequest_BACKUP_PHYSICAL: "backup-physical",
+}
+
+func (s *apiGrpcServer) ServerAction(ctx context.Context, in *repmanv3.ServerActionRequest) (*repmanv3.ActionResponse, error) {
+	action, ok := apiGrpcServerActions[in.Action]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown server action %s", in.Action)
+	}
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/servers/"+in.Server+"/actions/"+action)
+	if err != nil {
+		return nil, err
+	}
+	node := mycluster.GetServerFromName(in.Server)
+	if node == nil {
+		return nil, status.Errorf(codes.NotFound, "Server Not Found %s", in.Server)
+	}
+	switch in.Action {
+	case repmanv3.ServerActionRequest_START:
+		mycluster.StartDatabaseService(node)
+	case repmanv3.ServerActionRequest_STOP:
+		mycluster.StopDatabaseService(node)
+	case repmanv3.ServerActionRequest_MAINTENANCE:
+		mycluster.SwitchServerMaintenance(node.ServerID)
+	case repmanv3.ServerActionRequest_START_SLAVE:
+		node.StartSlave()
+	case repmanv3.ServerActionRequest_STOP_SLAVE:
+		node.StopSlave()
+	case repmanv3.ServerActionRequest_RESET_SLAVE_ALL:
+		node.StopSlave()
+		node.ResetSlave()
+	case repmanv3.ServerActionRequest_OPTIMIZE:
+		node.JobOptimize()
+	case repmanv3.ServerActionRequest_BACKUP_LOGICAL:
+		go node.JobBackupLogical()
+	case repmanv3.ServerActionRequest_BACKUP_PHYSICAL:
+		go node.JobBackupPhysical()
+	}
+	return &repmanv3.ActionResponse{Done: true}, nil
+}
+
+var apiGrpcProxyActions = map[repmanv3.ProxyActionRequest_Action]string{
+	repmanv3.ProxyActionRequest_START:       "start",
+	repmanv3.ProxyActionRequest_STOP:        "stop",
+	repmanv3.ProxyActionRequest_PROVISION:   "provision",
+	repmanv3.ProxyActionRequest_UNPROVISION: "unprovision",
+}
+
+func (s *apiGrpcServer) ProxyAction(ctx context.Context, in *repmanv3.ProxyActionRequest) (*repmanv3.ActionResponse, error) {
+	action, ok := apiGrpcProxyActions[in.Action]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown proxy action %s", in.Action)
+	}
+	mycluster, err := s.getCluster(ctx, in.Cluster, "/proxies/"+in.Proxy+"/actions/"+action)
+	if err != nil {
+		return nil, err
+	}
+	node := mycluster.GetProxyFromName(in.Proxy)
+	if node == nil {
+		return nil, status.Errorf(codes.NotFound, "Proxy Not Found %s", in.Proxy)
+	}
+	switch in.Action {
+	case repmanv3.ProxyActionRequest_START:
+		mycluster.StartProxyService(node)
+	case repmanv3.ProxyActionRequest_STOP:
+		mycluster.StopProxyService(node)
+	case repmanv3.ProxyActionRequest_PROVISION:
+		mycluster.InitProxyService(node)
+	case repmanv3.ProxyActionRequest_UNPROVISION:
+		mycluster.UnprovisionProxyService(node)
+	}
+	return &repmanv3.ActionResponse{Done: true}, nil
+}
+
+func (s *apiGrpcServer) StreamLogs(in *repmanv3.StreamLogsRequest, stream repmanv3.ClusterService_StreamLogsServer) error {
+	mycluster, err := s.getCluster(stream.Context(), in.Cluster, "")
+	if err != nil {
+		return err
+	}
+	_, events, cancel := mycluster.SubscribeEvents(0)
+	defer cancel()
+	// the header tells the client that no new line will be missed
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	if !in.FollowOnly {
+		// the buffer is ordered from the newest message
+		buffer := mycluster.Log.GetBuffer()
+		for i := len(buffer) - 1; i >= 0; i-- {
+			if buffer[i].Text == "" {
+				continue
+			}
+			if err := stream.Send(&repmanv3.LogLine{Level: buffer[i].Level, Timestamp: buffer[i].Timestamp, Text: buffer[i].Text}); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "Log stream lagging behind")
+			}
+			if data, ok := ev.Data.(cluster.EventLogData); ok {
+				if err := stream.Send(&repmanv3.LogLine{Level: data.Level, Timestamp: ev.Time.Format("2006/01/02 15:04:05"), Text: data.Text}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func getGrpcEvent(ev cluster.Event) *repmanv3.Event {
+	res := &repmanv3.Event{Id: ev.Id, Type: ev.Type, Time: timestamppb.New(ev.Time), Cluster: ev.Cluster, Server: ev.Server}
+	switch data := ev.Data.(type) {
+	case cluster.EventServerStateData:
+		res.Data = &repmanv3.Event_ServerState{ServerState: &repmanv3.ServerStateData{From: data.From, To: data.To}}
+	case cluster.EventAlertData:
+		res.Data = &repmanv3.Event_Alert{Alert: &repmanv3.AlertData{Number: data.Number, Type: data.Type, Desc: data.Desc, From: data.From}}
+	case cluster.EventFailoverData:
+		res.Data = &repmanv3.Event_Failover{Failover: &repmanv3.FailoverData{Phase: data.Phase, Fail: data.Fail, Master: data.Master}}
+	case cluster.EventJobData:
+		res.Data = &repmanv3.Event_Job{Job: &repmanv3.JobData{Task: data.Task, Status: data.Status, Count: int32(data.Count)}}
+	case cluster.EventLogData:
+		res.Data = &repmanv3.Event_Log{Log: &repmanv3.LogData{Level: data.Level, Text: data.Text}}
+	}
+	return res
+}
+
+func (s *apiGrpcServer) StreamEvents(in *repmanv3.StreamEventsRequest, stream repmanv3.ClusterService_StreamEventsServer) error {
+	mycluster, err := s.getCluster(stream.Context(), in.Cluster, "")
+	if err != nil {
+		return err
+	}
+	types := make(map[string]bool)
+	for _, t := range in.Types {
+		types[t] = true
+	}
+	backlog, events, cancel := mycluster.SubscribeEvents(in.Since)
+	defer cancel()
+	for _, ev := range backlog {
+		if isStreamedEvent(ev, types) {
+			if err := stream.Send(getGrpcEvent(ev)); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				// the client resumes from the id of its last event
+				return status.Error(codes.ResourceExhausted, "Event stream lagging behind")
+			}
+			if isStreamedEvent(ev, types) {
+				if err := stream.Send(getGrpcEvent(ev)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/signal18/replication-manager/cluster"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/repmanv3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newGrpcTestCluster(grants ...string) *cluster.Cluster {
+	c := new(cluster.Cluster)
+	c.Name = "c1"
+	g := make(map[string]bool)
+	for _, grant := range grants {
+		g[grant] = true
+	}
+	c.APIUsers = map[string]cluster.APIUser{"u": {User: "u", Password: "p", Grants: g}}
+	return c
+}
+
+func newGrpcTestClient(t *testing.T, repman *ReplicationManager) repmanv3.ClusterServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	s := repman.newGrpcServer()
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return repmanv3.NewClusterServiceClient(conn)
+}
+
+func withGrpcToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGetGrpcUser(t *testing.T) {
+	new(ReplicationManager).initKeys()
+	token, err := signToken("u", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	user, err := getGrpcUser(ctx)
+	if err != nil || user.name != "u" || user.password != "p" {
+		t.Errorf("Expected user u from the token, got %+v %v", user, err)
+	}
+	for name, md := range map[string]metadata.MD{
+		"no metadata":  nil,
+		"no bearer":    metadata.Pairs("authorization", "Basic dTpw"),
+		"invalid":      metadata.Pairs("authorization", "Bearer abc"),
+		"empty bearer": metadata.Pairs("authorization", "Bearer "),
+	} {
+		ctx := context.Background()
+		if md != nil {
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+		if _, err := getGrpcUser(ctx); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected %s to be unauthenticated, got %v", name, err)
+		}
+	}
+	// a token signed before the keys changed is rejected
+	new(ReplicationManager).initKeys()
+	if _, err := getGrpcUser(ctx); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected a token of other keys to be unauthenticated, got %v", err)
+	}
+}
+
+func TestGrpcAuth(t *testing.T) {
+	new(ReplicationManager).initKeys()
+	repman := &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster()}}
+	client := newGrpcTestClient(t, repman)
+
+	// login is the only call without a token
+	if _, err := client.Login(context.Background(), &repmanv3.LoginRequest{Username: "u", Password: "x"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected wrong credentials to be unauthenticated, got %v", err)
+	}
+	res, err := client.Login(context.Background(), &repmanv3.LoginRequest{Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListClusters(context.Background(), &repmanv3.ListClustersRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected a unary call without token to be unauthenticated, got %v", err)
+	}
+	stream, err := client.StreamLogs(context.Background(), &repmanv3.StreamLogsRequest{Cluster: "c1"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected a stream without token to be unauthenticated, got %v", err)
+	}
+
+	ctx := withGrpcToken(res.Token)
+	list, err := client.ListClusters(ctx, &repmanv3.ListClustersRequest{})
+	if err != nil || len(list.Clusters) != 1 || list.Clusters[0] != "c1" {
+		t.Errorf("Expected cluster c1, got %v %v", list, err)
+	}
+	if _, err := client.GetCluster(ctx, &repmanv3.ClusterRequest{Cluster: "c2"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected an unknown cluster to be not found, got %v", err)
+	}
+	// the stream interceptor passes the user to the call
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err = client.StreamLogs(sctx, &repmanv3.StreamLogsRequest{Cluster: "c1", FollowOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Errorf("Expected the log stream to start, got %v", err)
+	}
+}
+
+func TestGrpcClusterACL(t *testing.T) {
+	user := context.WithValue(context.Background(), apiGrpcUserKey{}, apiGrpcUser{name: "u", password: "p"})
+	// routes of the calls acting on the cluster and the grant allowing them
+	for _, c := range []struct {
+		route string
+		grant string
+	}{
+		{"", ""},
+		{"/actions/switchover", config.GrantClusterSwitchover},
+		{"/actions/failover", config.GrantClusterFailover},
+		{"/actions/reset-failover-control", config.GrantClusterSettings},
+		{"/actions/start-traffic", config.GrantClusterTraffic},
+		{"/actions/stop-traffic", config.GrantClusterTraffic},
+		{"/settings/actions/switch/failover-mode", config.GrantClusterSettings},
+		{"/settings/actions/set/failover-limit/3", config.GrantClusterSettings},
+	} {
+		s := &apiGrpcServer{repman: &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster(c.grant)}}}
+		if _, err := s.getCluster(user, "c1", c.route); err != nil {
+			t.Errorf("Expected grant %q to allow %q, got %v", c.grant, c.route, err)
+		}
+		if c.grant == "" {
+			if _, err := s.getCluster(context.Background(), "c1", c.route); status.Code(err) != codes.PermissionDenied {
+				t.Errorf("Expected a call without user to be denied, got %v", err)
+			}
+			continue
+		}
+		s.repman.Clusters["c1"] = newGrpcTestCluster()
+		if _, err := s.getCluster(user, "c1", c.route); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected %q to be denied without grant %q, got %v", c.route, c.grant, err)
+		}
+	}
+}
+
+func TestGrpcActionACL(t *testing.T) {
+	new(ReplicationManager).initKeys()
+	token, _ := signToken("u", "p")
+	ctx := withGrpcToken(token)
+	// the actions are granted as the REST routes, the unknown server or proxy tells
+	// that the ACL passed
+	for action, grant := range map[repmanv3.ServerActionRequest_Action]string{
+		repmanv3.ServerActionRequest_START:           config.GrantDBStart,
+		repmanv3.ServerActionRequest_STOP:            config.GrantDBStop,
+		repmanv3.ServerActionRequest_MAINTENANCE:     config.GrantDBMaintenance,
+		repmanv3.ServerActionRequest_START_SLAVE:     config.GrantDBReplication,
+		repmanv3.ServerActionRequest_STOP_SLAVE:      config.GrantDBReplication,
+		repmanv3.ServerActionRequest_RESET_SLAVE_ALL: config.GrantDBReplication,
+		repmanv3.ServerActionRequest_OPTIMIZE:        config.GrantDBMaintenance,
+		repmanv3.ServerActionRequest_BACKUP_LOGICAL:  config.GrantDBBackup,
+		repmanv3.ServerActionRequest_BACKUP_PHYSICAL: config.GrantDBBackup,
+	} {
+		req := &repmanv3.ServerActionRequest{Cluster: "c1", Server: "db1", Action: action}
+		client := newGrpcTestClient(t, &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster(grant)}})
+		if _, err := client.ServerAction(ctx, req); status.Code(err) != codes.NotFound {
+			t.Errorf("Expected grant %s to allow %s, got %v", grant, action, err)
+		}
+		client = newGrpcTestClient(t, &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster()}})
+		if _, err := client.ServerAction(ctx, req); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected %s to be denied without grant %s, got %v", action, grant, err)
+		}
+	}
+	for action, grant := range map[repmanv3.ProxyActionRequest_Action]string{
+		repmanv3.ProxyActionRequest_START:       config.GrantProxyStart,
+		repmanv3.ProxyActionRequest_STOP:        config.GrantProxyStop,
+		repmanv3.ProxyActionRequest_PROVISION:   config.GrantProvProxyProvision,
+		repmanv3.ProxyActionRequest_UNPROVISION: config.GrantProvProxyUnprovision,
+	} {
+		req := &repmanv3.ProxyActionRequest{Cluster: "c1", Proxy: "px1", Action: action}
+		client := newGrpcTestClient(t, &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster(grant)}})
+		if _, err := client.ProxyAction(ctx, req); status.Code(err) != codes.NotFound {
+			t.Errorf("Expected grant %s to allow %s, got %v", grant, action, err)
+		}
+		client = newGrpcTestClient(t, &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster()}})
+		if _, err := client.ProxyAction(ctx, req); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected %s to be denied without grant %s, got %v", action, grant, err)
+		}
+	}
+	client := newGrpcTestClient(t, &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": newGrpcTestCluster()}})
+	if _, err := client.ServerAction(ctx, &repmanv3.ServerActionRequest{Cluster: "c1", Server: "db1"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected an unspecified action to be invalid, got %v", err)
+	}
+}
@@ -12,27 +12,28 @@ import (
 
 	"github.com/codegangsta/negroni"
 	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/config"
 )
 
 func (repman *ReplicationManager) apiProxyProtectedHandler(router *mux.Router) {
 	//PROTECTED ENDPOINTS FOR PROXIES
 
-	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/unprovision", negroni.New(
+	apiDoc(router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/unprovision", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyUnprovision)),
-	))
-	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/provision", negroni.New(
+	)), apiRoute{Summary: "Unprovision the proxy", Grant: config.GrantProvProxyUnprovision})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/provision", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyProvision)),
-	))
-	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/stop", negroni.New(
+	)), apiRoute{Summary: "Provision the proxy", Grant: config.GrantProvProxyProvision})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/stop", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyStop)),
-	))
-	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/start", negroni.New(
+	)), apiRoute{Summary: "Stop the proxy", Grant: config.GrantProxyStop})
+	apiDoc(router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/start", negroni.New(
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyStart)),
-	))
+	)), apiRoute{Summary: "Start the proxy", Grant: config.GrantProxyStart})
 
 }
 
@@ -0,0 +1,236 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/cluster"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// event sent on idle websocket streams, SSE streams use a comment instead
+	streamKeepAlive = "keepalive"
+	// websocket subprotocol followed by the token, browsers can not set the
+	// Authorization header of WebSocket requests
+	streamTokenProtocol = "bearer"
+)
+
+// validateStreamTokenMiddleware rejects the origins not allowed to stream and takes the
+// token of WebSocket requests from the subprotocols, tokens are never read from the URL
+// as they end in logs
+func (repman *ReplicationManager) validateStreamTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if !repman.isStreamOriginAllowed(r) {
+		http.Error(w, "Origin not allowed", 403)
+		return
+	}
+	if r.Header.Get("Authorization") == "" {
+		protocols := strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",")
+		for i := 0; i+1 < len(protocols); i++ {
+			if strings.TrimSpace(protocols[i]) == streamTokenProtocol {
+				r.Header.Set("Authorization", "Bearer "+strings.TrimSpace(protocols[i+1]))
+				break
+			}
+		}
+	}
+	next(w, r)
+}
+
+// isStreamOriginAllowed accepts requests without origin, from the API host itself
+// and from the origins of http-stream-allowed-origins
+func (repman *ReplicationManager) isStreamOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	for _, o := range strings.Split(repman.Conf.HttpStreamAllowedOrigins, ",") {
+		if strings.TrimSpace(o) == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// handlerMuxStream streams the cluster events as SSE or as WebSocket when the connection
+// is upgraded. The since argument or the Last-Event-ID header resume the stream after
+// the given event id, the types argument filters the events by a comma separated list
+// of types.
+func (repman *ReplicationManager) handlerMuxStream(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	} else {
+		w.Header().Del("Access-Control-Allow-Origin")
+	}
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	if !repman.IsValidClusterACL(r, mycluster) {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		since = r.Header.Get("Last-Event-ID")
+	}
+	var token int64
+	if since != "" {
+		var err error
+		token, err = strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid resume token", 400)
+			return
+		}
+	}
+	types := make(map[string]bool)
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t != "" {
+			types[t] = true
+		}
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		websocket.Server{Handshake: streamHandshake, Handler: func(ws *websocket.Conn) {
+			repman.streamWebSocket(ws, mycluster, token, types)
+		}}.ServeHTTP(w, r)
+		return
+	}
+	repman.streamSSE(w, r, mycluster, token, types)
+}
+
+// streamHandshake selects the token subprotocol the browsers expect in the response,
+// the origin is checked by validateStreamTokenMiddleware
+func streamHandshake(config *websocket.Config, r *http.Request) error {
+	protocols := config.Protocol
+	config.Protocol = nil
+	for _, p := range protocols {
+		if p == streamTokenProtocol {
+			config.Protocol = []string{streamTokenProtocol}
+		}
+	}
+	return nil
+}
+
+func isStreamedEvent(ev cluster.Event, types map[string]bool) bool {
+	return len(types) == 0 || types[ev.Type] || ev.Type == cluster.EventResync
+}
+
+func (repman *ReplicationManager) getStreamKeepAlive(mycluster *cluster.Cluster) time.Duration {
+	if mycluster.Conf.HttpStreamKeepAlive <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(mycluster.Conf.HttpStreamKeepAlive) * time.Second
+}
+
+func (repman *ReplicationManager) streamSSE(w http.ResponseWriter, r *http.Request, mycluster *cluster.Cluster, since int64, types map[string]bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	backlog, events, cancel := mycluster.SubscribeEvents(since)
+	defer cancel()
+	send := func(ev cluster.Event) error {
+		if !isStreamedEvent(ev, types) {
+			return nil
+		}
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Id, ev.Type, data)
+		return err
+	}
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, ev := range backlog {
+		if send(ev) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(repman.getStreamKeepAlive(mycluster))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// subscriber dropped, the client reconnects with its last event id
+				return
+			}
+			if send(ev) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (repman *ReplicationManager) streamWebSocket(ws *websocket.Conn, mycluster *cluster.Cluster, since int64, types map[string]bool) {
+	defer ws.Close()
+	backlog, events, cancel := mycluster.SubscribeEvents(since)
+	defer cancel()
+
+	// the client does not send anything, reading detects when it goes away
+	closed := make(chan bool)
+	go func() {
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		close(closed)
+	}()
+
+	for _, ev := range backlog {
+		if isStreamedEvent(ev, types) && websocket.JSON.Send(ws, ev) != nil {
+			return
+		}
+	}
+	ticker := time.NewTicker(repman.getStreamKeepAlive(mycluster))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if isStreamedEvent(ev, types) && websocket.JSON.Send(ws, ev) != nil {
+				return
+			}
+		case <-ticker.C:
+			if websocket.JSON.Send(ws, cluster.Event{Type: streamKeepAlive, Time: time.Now(), Cluster: mycluster.Name}) != nil {
+				return
+			}
+		}
+	}
+}
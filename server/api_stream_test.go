@@ -0,0 +1,138 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/signal18/replication-manager/cluster"
+	"golang.org/x/net/websocket"
+)
+
+func newStreamTestServer(t *testing.T) (*httptest.Server, *cluster.Cluster, string) {
+	new(ReplicationManager).initKeys()
+	token, err := signToken("u", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newGrpcTestCluster()
+	repman := &ReplicationManager{Clusters: map[string]*cluster.Cluster{"c1": c}}
+	repman.Conf.HttpStreamAllowedOrigins = "https://gui.example, https://other.example"
+	ts := httptest.NewServer(repman.apiRouter())
+	t.Cleanup(ts.Close)
+	return ts, c, token
+}
+
+func TestStreamAuth(t *testing.T) {
+	ts, _, token := newStreamTestServer(t)
+	for _, c := range []struct {
+		name   string
+		query  string
+		header map[string]string
+		status int
+		origin string
+	}{
+		{"no token", "", nil, http.StatusUnauthorized, ""},
+		{"token in url", "?token=" + token, nil, http.StatusUnauthorized, ""},
+		{"header", "", map[string]string{"Authorization": "Bearer " + token}, http.StatusOK, ""},
+		{"same host", "", map[string]string{"Authorization": "Bearer " + token, "Origin": ts.URL}, http.StatusOK, ts.URL},
+		{"allowed origin", "", map[string]string{"Authorization": "Bearer " + token, "Origin": "https://other.example"}, http.StatusOK, "https://other.example"},
+		{"other origin", "", map[string]string{"Authorization": "Bearer " + token, "Origin": "https://evil.example"}, http.StatusForbidden, ""},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/clusters/c1/stream"+c.query, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != c.status {
+			t.Errorf("%s: expected status %d got %d", c.name, c.status, res.StatusCode)
+		}
+		if o := res.Header.Get("Access-Control-Allow-Origin"); c.status == http.StatusOK && o != c.origin {
+			t.Errorf("%s: expected allowed origin %q got %q", c.name, c.origin, o)
+		}
+		res.Body.Close()
+		cancel()
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	ts, c, token := newStreamTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/clusters/c1/stream?types="+cluster.EventJob, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream got %s", ct)
+	}
+	c.PublishEvent(cluster.EventLog, "", cluster.EventLogData{Level: "INFO", Text: "filtered"})
+	c.PublishEvent(cluster.EventJob, "db1:3306", cluster.EventJobData{Task: "optimize", Status: "queued"})
+	r := bufio.NewReader(res.Body)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "event: ") {
+			if typ := strings.TrimSpace(line[7:]); typ != cluster.EventJob {
+				t.Fatalf("Expected only %s events got %s", cluster.EventJob, typ)
+			}
+			return
+		}
+	}
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	ts, c, token := newStreamTestServer(t)
+	wsurl := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/clusters/c1/stream"
+	config, err := websocket.NewConfig(wsurl, "https://evil.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Protocol = []string{streamTokenProtocol, token}
+	if _, err := websocket.DialConfig(config); err == nil {
+		t.Error("Expected a websocket from another origin to be rejected")
+	}
+	config.Protocol = nil
+	config.Origin, _ = config.Origin.Parse(ts.URL)
+	if _, err := websocket.DialConfig(config); err == nil {
+		t.Error("Expected a websocket without token to be rejected")
+	}
+	config.Protocol = []string{streamTokenProtocol, token}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	// the handler subscribes once the handshake is done
+	for i := 0; i < 100 && c.GetEventSubscribers() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.PublishEvent(cluster.EventJob, "db1:3306", cluster.EventJobData{Task: "optimize", Status: "queued"})
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev cluster.Event
+	if err := websocket.JSON.Receive(ws, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != cluster.EventJob || ev.Cluster != "c1" {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+}
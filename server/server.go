@@ -541,8 +541,6 @@ func (repman *ReplicationManager) Run() error {
 		log.WithField("apiport", repman.Conf.GraphiteCarbonApiPort).Info("Carbon server API started")
 	}
 
-	go repman.MountS3()
-
 	//repman.InitRestic()
 
 	// If there's an existing encryption key, decrypt the passwords
@@ -570,7 +568,6 @@ func (repman *ReplicationManager) Run() error {
 	go func() {
 		s := <-sigs
 		log.Printf("RECEIVED SIGNAL: %s", s)
-		repman.UnMountS3()
 		for _, cl := range repman.Clusters {
 			cl.Stop()
 		}
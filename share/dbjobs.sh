@@ -5,7 +5,12 @@ ERROLOG=/var/lib/mysql/.system/logs/errors.log
 SLOWLOG=/var/lib/mysql/.system/logs/sql-slow
 BACKUPDIR=/var/lib/mysql/.system/backup
 DATADIR=/var/lib/mysql/
-JOBS=( "xtrabackup" "error" "slowquery" "zfssnapback" "optimize" "reseedxtrabackup" "reseedmysqldump" "flashbackxtrabackup" "flashbackmysqldump" )
+# Backups and logs are transferred with replication-manager-cli sst, the binary must
+# be in the PATH of the database host and $SSLDIR must hold the cluster certificates
+# ca-cert.pem, server-cert.pem, server-key.pem, client-cert.pem and client-key.pem
+SSLDIR=/etc/mysql/ssl
+SST="replication-manager-cli sst --ssl-dir=$SSLDIR"
+JOBS=( "xtrabackup" "xtrabackupincr" "error" "slowquery" "zfssnapback" "optimize" "reseedxtrabackup" "reseedmysqldump" "flashbackxtrabackup" "flashbackmysqldump" )
 
 doneJob()
 {
@@ -17,6 +22,32 @@ pauseJob()
  /usr/bin/mysql -u$USER -p$PASSWORD -e "select sleep(6);set sql_log_bin=0;UPDATE replication_manager_schema.jobs set result=LOAD_FILE('/tmp/dbjob.out') WHERE id='$ID';" &
 }
 
+prepareBackup()
+{
+ # a backup chain streams its incremental backups under incremental/<n>/
+ rm -rf $BACKUPDIR.incremental
+ if [ -d $BACKUPDIR/incremental ]; then
+  mv $BACKUPDIR/incremental $BACKUPDIR.incremental
+  xtrabackup --prepare --apply-log-only --target-dir=$BACKUPDIR
+  for dir in $(ls -d $BACKUPDIR.incremental/*/ | sort) ; do
+   xtrabackup --prepare --apply-log-only --target-dir=$BACKUPDIR --incremental-dir=$dir
+  done
+  rm -rf $BACKUPDIR.incremental
+ fi
+ xtrabackup --prepare --export --target-dir=$BACKUPDIR
+}
+
+checkSST()
+{
+ case "$1" in
+  zfssnapback|optimize) return 0 ;;
+ esac
+ if ! command -v replication-manager-cli > /dev/null 2>&1; then
+  echo "replication-manager-cli not found in PATH, $1 can not be transferred" > /tmp/dbjob.out
+  return 1
+ fi
+}
+
 partialRestore()
 {
  /usr/bin/mysql -p$PASSWORD -u$USER -e "set sql_log_bin=0;install plugin BLACKHOLE soname 'ha_blackhole.so'"
@@ -54,27 +85,34 @@ partialRestore()
 for job in "${JOBS[@]}"
 do
 
- TASK=($(echo "select concat(id,'@',server,':',port) from replication_manager_schema.jobs WHERE task='$job' and done=0 order by task desc limit 1" | /usr/bin/mysql -p$PASSWORD -u$USER -N))
+ TASK=($(echo "select concat(id,'@',server,':',port,'@',ifnull(token,''),'@',ifnull(lsn,0)) from replication_manager_schema.jobs WHERE task='$job' and done=0 order by task desc limit 1" | /usr/bin/mysql -p$PASSWORD -u$USER -N))
 
  ADDRESS=($(echo $TASK | awk -F@ '{ print $2 }'))
  ID=($(echo $TASK | awk -F@ '{ print $1 }'))
+ TOKEN=($(echo $TASK | awk -F@ '{ print $3 }'))
+ LSN=($(echo $TASK | awk -F@ '{ print $4 }'))
  /usr/bin/mysql -uroot -p$PASSWORD -e "set sql_log_bin=0;UPDATE replication_manager_schema.jobs set done=1 WHERE task='$job';"
 
   if [ "$ADDRESS" == "" ]; then
     echo "No $job needed"
+  elif ! checkSST $job; then
+    cat /tmp/dbjob.out
+    doneJob
   else
     echo "Processing $job"
     case "$job" in
       reseedmysqldump)
        echo "Waiting backup." >  /tmp/dbjob.out
        pauseJob
-       socat -u TCP-LISTEN:4444,reuseaddr STDOUT | gunzip | /usr/bin/mysql -p$PASSWORD -u$USER > /tmp/dbjob.out 2>&1
+       $SST --receive --listen=:4444 --task=$job --sealed-token=$TOKEN --file=/tmp/$job.sql.gz > /tmp/dbjob.out 2>&1 && gunzip < /tmp/$job.sql.gz | /usr/bin/mysql -p$PASSWORD -u$USER > /tmp/dbjob.out 2>&1
+       rm -f /tmp/$job.sql.gz
         /usr/bin/mysql -p$PASSWORD -u$USER -e 'start slave;'
       ;;
       flashbackmysqldump)
        echo "Waiting backup." >  /tmp/dbjob.out
        pauseJob
-       socat -u TCP-LISTEN:4444,reuseaddr STDOUT | gunzip | /usr/bin/mysql -p$PASSWORD -u$USER > /tmp/dbjob.out 2>&1
+       $SST --receive --listen=:4444 --task=$job --sealed-token=$TOKEN --file=/tmp/$job.sql.gz > /tmp/dbjob.out 2>&1 && gunzip < /tmp/$job.sql.gz | /usr/bin/mysql -p$PASSWORD -u$USER > /tmp/dbjob.out 2>&1
+       rm -f /tmp/$job.sql.gz
         /usr/bin/mysql -p$PASSWORD -u$USER -e 'start slave;'
       ;;
       reseedxtrabackup)
@@ -82,29 +120,31 @@ do
        mkdir $BACKUPDIR
        echo "Waiting backup." >  /tmp/dbjob.out
        pauseJob
-       socat -u TCP-LISTEN:4444,reuseaddr STDOUT | xbstream -x -C $BACKUPDIR
-       xtrabackup --prepare --export --target-dir=$BACKUPDIR
-       partialRestore
+       $SST --receive --listen=:4444 --task=$job --sealed-token=$TOKEN --file=$BACKUPDIR.xbstream > /tmp/dbjob.out 2>&1 && xbstream -x -C $BACKUPDIR < $BACKUPDIR.xbstream && prepareBackup && partialRestore
+       rm -f $BACKUPDIR.xbstream
       ;;
       flashbackxtrabackup)
        rm -rf $BACKUPDIR
        mkdir $BACKUPDIR
        echo "Waiting backup." >  /tmp/dbjob.out
        pauseJob
-       socat -u TCP-LISTEN:4444,reuseaddr STDOUT | xbstream -x -C $BACKUPDIR
-       xtrabackup --prepare --export --target-dir=$BACKUPDIR
-       partialRestore
+       $SST --receive --listen=:4444 --task=$job --sealed-token=$TOKEN --file=$BACKUPDIR.xbstream > /tmp/dbjob.out 2>&1 && xbstream -x -C $BACKUPDIR < $BACKUPDIR.xbstream && prepareBackup && partialRestore
+       rm -f $BACKUPDIR.xbstream
       ;;
       xtrabackup)
        cd /docker-entrypoint-initdb.d
-       /usr/bin/innobackupex  --defaults-file=/etc/mysql/my.cnf --socket='/var/run/mysqld/mysqld.sock' --slave-info --no-version-check  --user=$USER --password=$PASSWORD --stream=xbstream /tmp/ | socat -u stdio TCP:$ADDRESS &>/tmp/dbjob.out
+       /usr/bin/innobackupex  --defaults-file=/etc/mysql/my.cnf --socket='/var/run/mysqld/mysqld.sock' --slave-info --no-version-check  --user=$USER --password=$PASSWORD --stream=xbstream /tmp/ | $SST --address=$ADDRESS --task=$job --sealed-token=$TOKEN &>/tmp/dbjob.out
+      ;;
+      xtrabackupincr)
+       cd /docker-entrypoint-initdb.d
+       /usr/bin/innobackupex  --defaults-file=/etc/mysql/my.cnf --socket='/var/run/mysqld/mysqld.sock' --slave-info --no-version-check  --user=$USER --password=$PASSWORD --incremental --incremental-lsn=$LSN --stream=xbstream /tmp/ | $SST --address=$ADDRESS --task=$job --sealed-token=$TOKEN &>/tmp/dbjob.out
       ;;
       error)
-       cat $ERROLOG| socat -u stdio TCP:$ADDRESS &>/tmp/dbjob.out
+       $SST --address=$ADDRESS --task=$job --sealed-token=$TOKEN --file=$ERROLOG &>/tmp/dbjob.out
        > $ERROLOG
       ;;
       slowquery)
-       cat $SLOWLOG| socat -u stdio TCP:$ADDRESS &>/tmp/dbjob.out
+       $SST --address=$ADDRESS --task=$job --sealed-token=$TOKEN --file=$SLOWLOG &>/tmp/dbjob.out
        > $SLOWLOG
       ;;
       zfssnapback)
@@ -0,0 +1,325 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package backupstore stores the backups and the archived binlogs in a local directory
+// or in an S3 compatible or Azure Blob bucket. Buckets are used through the storage
+// backends of goofys without mounting them.
+package backupstore
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ConstStoreLocal = "local"
+	ConstStoreS3    = "s3"
+	ConstStoreAzure = "azure"
+)
+
+// server side encryption of the S3 store
+const (
+	ConstEncryptionNone = ""
+	ConstEncryptionS3   = "AES256"
+	ConstEncryptionKMS  = "aws:kms"
+	ConstEncryptionSseC = "sse-c"
+)
+
+type Config struct {
+	// local, s3 or azure
+	Type string
+	// root of the local store
+	Dir string
+	// bucket or Azure container, Prefix is prepended to the keys
+	Bucket    string
+	Prefix    string
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// S3 server side encryption, KMSKeyID for aws:kms and a base64 key for sse-c
+	Encryption   string
+	KMSKeyID     string
+	SseCKey      string
+	StorageClass string
+	// bytes of the first parts of a multipart upload
+	PartSize int64
+	Debug    bool
+}
+
+type Object struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Writer is an object being written, it is visible in the store once closed
+type Writer interface {
+	io.WriteCloser
+	// Abort discards the object
+	Abort() error
+}
+
+type Store interface {
+	// Name describes the location of the store
+	Name() string
+	Create(key string) (Writer, error)
+	Open(key string) (io.ReadCloser, error)
+	// Stat returns an error satisfying os.IsNotExist for a missing key
+	Stat(key string) (Object, error)
+	// List returns the objects under prefix sorted by key
+	List(prefix string) ([]Object, error)
+	// Delete does not fail on a missing key, it may have been expired by the bucket
+	// lifecycle rules
+	Delete(key string) error
+	// Expire aborts the stale multipart uploads
+	Expire() error
+}
+
+func New(conf Config) (Store, error) {
+	switch conf.Type {
+	case ConstStoreLocal, "":
+		if conf.Dir == "" {
+			return nil, errors.New("No directory for local backup store")
+		}
+		return &localStore{dir: conf.Dir}, nil
+	case ConstStoreS3, ConstStoreAzure:
+		return newCloudStore(conf)
+	}
+	return nil, errors.New("Unknown backup store type " + conf.Type)
+}
+
+// Retention of the objects under a prefix
+type Retention struct {
+	// newest objects never deleted, without MaxAge the older ones are deleted
+	Keep int
+	// objects older than MaxAge and not in the newest Keep are deleted
+	MaxAge time.Duration
+	// the bucket has lifecycle rules expiring the objects on age, they are left
+	// to the rules
+	Lifecycle bool
+}
+
+// Prune deletes the objects under prefix out of the retention, the keys must sort
+// in the order of creation as binlog or timestamped names. It returns the deleted keys.
+func Prune(store Store, prefix string, r Retention) ([]string, error) {
+	objects, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].Key > objects[j].Key })
+	var deleted []string
+	for i, o := range objects {
+		if i < r.Keep {
+			continue
+		}
+		drop := false
+		switch {
+		case r.MaxAge > 0:
+			drop = !r.Lifecycle && time.Since(o.Modified) > r.MaxAge
+		case r.Keep > 0:
+			drop = true
+		}
+		if !drop {
+			continue
+		}
+		if err := store.Delete(o.Key); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, o.Key)
+	}
+	return deleted, nil
+}
+
+// localStore keeps the objects as files under dir, an object is written in a
+// temporary file renamed on close
+type localStore struct {
+	dir string
+}
+
+const localPartSuffix = ".part"
+
+func (s *localStore) Name() string {
+	return s.dir
+}
+
+func (s *localStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+type localWriter struct {
+	*os.File
+	path string
+}
+
+func (w *localWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return os.Rename(w.File.Name(), w.path)
+}
+
+func (w *localWriter) Abort() error {
+	w.File.Close()
+	return os.Remove(w.File.Name())
+}
+
+func (s *localStore) Create(key string) (Writer, error) {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+localPartSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: f, path: path}, nil
+}
+
+func (s *localStore) Open(key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s *localStore) Stat(key string) (Object, error) {
+	fi, err := os.Stat(s.path(key))
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Key: key, Size: fi.Size(), Modified: fi.ModTime()}, nil
+}
+
+// List walks the directory of prefix, the prefix may end with the beginning of a
+// file name
+func (s *localStore) List(prefix string) ([]Object, error) {
+	var objects []Object
+	root := s.path(prefix)
+	if !strings.HasSuffix(prefix, "/") && prefix != "" {
+		root = filepath.Dir(root)
+	}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() || strings.HasSuffix(path, localPartSuffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: fi.Size(), Modified: fi.ModTime()})
+		}
+		return nil
+	})
+	return objects, err
+}
+
+func (s *localStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Expire removes the temporary files of writers that did not close
+func (s *localStore) Expire() error {
+	return filepath.Walk(s.dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !fi.IsDir() && strings.HasSuffix(path, localPartSuffix) && time.Since(fi.ModTime()) > 48*time.Hour {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// Upload copies a local file in the store
+func Upload(store Store, key string, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := store.Create(key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
+
+// UploadDir copies the files of a local directory under prefix and removes them
+func UploadDir(store Store, prefix string, dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		if err := Upload(store, prefix+fi.Name(), filepath.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+		os.Remove(filepath.Join(dir, fi.Name()))
+	}
+	return nil
+}
+
+// DownloadDir copies the objects under prefix in a local directory
+func DownloadDir(store Store, prefix string, dir string) error {
+	objects, err := store.List(prefix)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		r, err := store.Open(o.Key)
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			r.Close()
+			return err
+		}
+		_, err = io.Copy(f, r)
+		r.Close()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
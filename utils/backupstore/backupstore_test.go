@@ -0,0 +1,153 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package backupstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	goofys "github.com/signal18/replication-manager/goofys/api"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backupstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := New(Config{Type: ConstStoreLocal, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := store.Create("cluster1/db1_3306/mysqldump.sql.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("dump"))
+	if _, err := store.Stat("cluster1/db1_3306/mysqldump.sql.gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected an object being written to be missing, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if o, err := store.Stat("cluster1/db1_3306/mysqldump.sql.gz"); err != nil || o.Size != 4 {
+		t.Errorf("Unexpected object %v %v", o, err)
+	}
+
+	w, _ = store.Create("cluster1/db1_3306/xtrabackup.xbtream")
+	w.Write([]byte("partial"))
+	w.Abort()
+	if _, err := store.Stat("cluster1/db1_3306/xtrabackup.xbtream"); !os.IsNotExist(err) {
+		t.Errorf("Expected an aborted object to be missing, got %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		w, _ := store.Create("cluster1/db1_3306/mysql-bin.00000" + string(rune('0'+i)))
+		w.Write([]byte("binlog"))
+		w.Close()
+	}
+	objects, err := store.List("cluster1/db1_3306/mysql-bin.")
+	if err != nil || len(objects) != 5 {
+		t.Fatalf("Expected 5 binlogs got %v %v", objects, err)
+	}
+	purged, err := Prune(store, "cluster1/db1_3306/mysql-bin.", Retention{Keep: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(purged, []string{"cluster1/db1_3306/mysql-bin.000002", "cluster1/db1_3306/mysql-bin.000001"}) {
+		t.Errorf("Unexpected purged binlogs %v", purged)
+	}
+
+	// recent objects are kept on age and lifecycle rules expire the old ones
+	old := time.Now().Add(-72 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "cluster1/db1_3306/mysql-bin.000003"), old, old)
+	if purged, _ := Prune(store, "cluster1/db1_3306/mysql-bin.", Retention{Keep: 1, MaxAge: 24 * time.Hour, Lifecycle: true}); len(purged) != 0 {
+		t.Errorf("Expected no purge with lifecycle rules, got %v", purged)
+	}
+	purged, _ = Prune(store, "cluster1/db1_3306/mysql-bin.", Retention{Keep: 1, MaxAge: 24 * time.Hour})
+	if !reflect.DeepEqual(purged, []string{"cluster1/db1_3306/mysql-bin.000003"}) {
+		t.Errorf("Unexpected purged binlogs on age %v", purged)
+	}
+}
+
+// fakeBackend records the uploads, the methods not used by the store are nil
+type fakeBackend struct {
+	goofys.StorageBackend
+	parts     [][]byte
+	put       []byte
+	committed bool
+	aborted   bool
+}
+
+func (b *fakeBackend) MultipartBlobBegin(param *goofys.MultipartBlobBeginInput) (*goofys.MultipartBlobCommitInput, error) {
+	return &goofys.MultipartBlobCommitInput{Key: &param.Key}, nil
+}
+
+func (b *fakeBackend) MultipartBlobAdd(param *goofys.MultipartBlobAddInput) (*goofys.MultipartBlobAddOutput, error) {
+	data, _ := ioutil.ReadAll(param.Body)
+	if int(param.PartNumber) != len(b.parts)+1 || param.Offset != uint64(len(bytes.Join(b.parts, nil))) {
+		panic("parts out of order")
+	}
+	b.parts = append(b.parts, data)
+	return nil, nil
+}
+
+func (b *fakeBackend) MultipartBlobCommit(param *goofys.MultipartBlobCommitInput) (*goofys.MultipartBlobCommitOutput, error) {
+	b.committed = true
+	return nil, nil
+}
+
+func (b *fakeBackend) MultipartBlobAbort(param *goofys.MultipartBlobCommitInput) (*goofys.MultipartBlobAbortOutput, error) {
+	b.aborted = true
+	return nil, nil
+}
+
+func (b *fakeBackend) PutBlob(param *goofys.PutBlobInput) (*goofys.PutBlobOutput, error) {
+	b.put, _ = ioutil.ReadAll(param.Body)
+	return nil, nil
+}
+
+func TestCloudWriter(t *testing.T) {
+	backend := &fakeBackend{}
+	store := &cloudStore{backend: backend, partSize: 10}
+	w, _ := store.Create("small")
+	w.Write([]byte("12345"))
+	w.Close()
+	if string(backend.put) != "12345" || backend.parts != nil {
+		t.Errorf("Expected a single put of a small object, got %q %d parts", backend.put, len(backend.parts))
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 3)
+	data = append(data, "abc"...)
+	w, _ = store.Create("large")
+	w.Write(data[:7])
+	w.Write(data[7:])
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !backend.committed || len(backend.parts) != 4 || !bytes.Equal(bytes.Join(backend.parts, nil), data) {
+		t.Errorf("Unexpected multipart upload %q committed %t", backend.parts, backend.committed)
+	}
+
+	backend = &fakeBackend{}
+	store.backend = backend
+	w, _ = store.Create("aborted")
+	w.Write(data)
+	w.Abort()
+	if !backend.aborted || backend.committed {
+		t.Errorf("Expected the upload to be aborted")
+	}
+}
@@ -0,0 +1,292 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package backupstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	goofys "github.com/signal18/replication-manager/goofys/api"
+	common "github.com/signal18/replication-manager/goofys/api/common"
+)
+
+const (
+	// S3 refuses parts smaller than 5MB but the last one
+	minPartSize     = 5 * 1024 * 1024
+	defaultPartSize = 16 * 1024 * 1024
+	maxParts        = 10000
+	// the part size doubles every partsDoubling parts so that a stream of unknown
+	// size fits in the maximum number of parts
+	partsDoubling = 1000
+)
+
+type cloudStore struct {
+	backend  goofys.StorageBackend
+	name     string
+	prefix   string
+	partSize int64
+}
+
+func newCloudStore(conf Config) (*cloudStore, error) {
+	if conf.Bucket == "" {
+		return nil, errors.New("No bucket for backup store")
+	}
+	flags := &common.FlagStorage{
+		Endpoint:    conf.Endpoint,
+		HTTPTimeout: 30 * time.Second,
+		DebugS3:     conf.Debug,
+	}
+	switch conf.Type {
+	case ConstStoreS3:
+		s3conf := &common.S3Config{
+			AccessKey:    conf.AccessKey,
+			SecretKey:    conf.SecretKey,
+			Region:       conf.Region,
+			RegionSet:    conf.Region != "",
+			StorageClass: conf.StorageClass,
+		}
+		switch conf.Encryption {
+		case ConstEncryptionNone:
+		case ConstEncryptionS3:
+			s3conf.UseSSE = true
+		case ConstEncryptionKMS:
+			s3conf.UseSSE = true
+			s3conf.UseKMS = true
+			s3conf.KMSKeyID = conf.KMSKeyID
+		case ConstEncryptionSseC:
+			if conf.SseCKey == "" {
+				return nil, errors.New("No key for sse-c encryption")
+			}
+			s3conf.SseC = conf.SseCKey
+		default:
+			return nil, errors.New("Unknown encryption " + conf.Encryption)
+		}
+		flags.Backend = s3conf.Init()
+	case ConstStoreAzure:
+		// the account and its key, or the azure environment and configuration
+		azconf := common.AZBlobConfig{AccountName: conf.AccessKey, AccountKey: conf.SecretKey, Endpoint: conf.Endpoint}
+		if conf.AccessKey == "" {
+			var err error
+			azconf, err = common.AzureBlobConfig(conf.Endpoint, conf.Bucket, "blob")
+			if err != nil {
+				return nil, err
+			}
+		} else if azconf.Endpoint == "" {
+			azconf.Endpoint = "https://" + conf.AccessKey + ".blob.core.windows.net"
+		}
+		azconf.Init()
+		flags.Backend = &azconf
+	}
+	backend, err := goofys.NewBackend(conf.Bucket, flags)
+	if err != nil {
+		return nil, err
+	}
+	if err := backend.Init(""); err != nil {
+		return nil, err
+	}
+	s := &cloudStore{
+		backend:  backend,
+		name:     conf.Type + "://" + conf.Bucket,
+		prefix:   strings.Trim(conf.Prefix, "/"),
+		partSize: conf.PartSize,
+	}
+	if s.prefix != "" {
+		s.prefix += "/"
+		s.name += "/" + s.prefix
+	}
+	if s.partSize < minPartSize {
+		s.partSize = defaultPartSize
+	}
+	return s, nil
+}
+
+func (s *cloudStore) Name() string {
+	return s.name
+}
+
+func notExist(err error) bool {
+	return err == syscall.ENOENT || os.IsNotExist(err)
+}
+
+// cloudWriter uploads the object in parts as it is written, an object smaller than a
+// part is sent in a single request on close
+type cloudWriter struct {
+	store  *cloudStore
+	key    string
+	buf    []byte
+	commit *goofys.MultipartBlobCommitInput
+	part   uint32
+	offset uint64
+	err    error
+}
+
+func (s *cloudStore) Create(key string) (Writer, error) {
+	return &cloudWriter{store: s, key: s.prefix + key, buf: make([]byte, 0, s.partSize)}, nil
+}
+
+func (w *cloudWriter) partSize() int {
+	return int(w.store.partSize) << (w.part / partsDoubling)
+}
+
+func (w *cloudWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		size := w.partSize()
+		free := size - len(w.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		w.buf = append(w.buf, p[:free]...)
+		p = p[free:]
+		if len(w.buf) == size {
+			if err := w.upload(false); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *cloudWriter) upload(last bool) error {
+	if w.commit == nil {
+		w.commit, w.err = w.store.backend.MultipartBlobBegin(&goofys.MultipartBlobBeginInput{Key: w.key})
+		if w.err != nil {
+			return w.err
+		}
+	}
+	if w.part >= maxParts {
+		w.err = fmt.Errorf("Object %s exceeds %d parts", w.key, maxParts)
+		return w.err
+	}
+	w.part++
+	_, w.err = w.store.backend.MultipartBlobAdd(&goofys.MultipartBlobAddInput{
+		Commit:     w.commit,
+		PartNumber: w.part,
+		Body:       bytes.NewReader(w.buf),
+		Size:       uint64(len(w.buf)),
+		Last:       last,
+		Offset:     w.offset,
+	})
+	w.offset += uint64(len(w.buf))
+	w.buf = w.buf[:0]
+	if !last && cap(w.buf) < w.partSize() {
+		w.buf = make([]byte, 0, w.partSize())
+	}
+	return w.err
+}
+
+func (w *cloudWriter) Close() error {
+	if w.err != nil {
+		w.Abort()
+		return w.err
+	}
+	if w.commit == nil {
+		size := uint64(len(w.buf))
+		_, w.err = w.store.backend.PutBlob(&goofys.PutBlobInput{Key: w.key, Body: bytes.NewReader(w.buf), Size: &size})
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.upload(true); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	if _, w.err = w.store.backend.MultipartBlobCommit(w.commit); w.err != nil {
+		w.Abort()
+	}
+	return w.err
+}
+
+func (w *cloudWriter) Abort() error {
+	if w.err == nil {
+		w.err = errors.New("Upload aborted")
+	}
+	if w.commit == nil {
+		return nil
+	}
+	_, err := w.store.backend.MultipartBlobAbort(w.commit)
+	w.commit = nil
+	return err
+}
+
+func (s *cloudStore) Open(key string) (io.ReadCloser, error) {
+	out, err := s.backend.GetBlob(&goofys.GetBlobInput{Key: s.prefix + key})
+	if err != nil {
+		if notExist(err) {
+			return nil, &os.PathError{Op: "open", Path: s.name + key, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *cloudStore) object(item goofys.BlobItemOutput) Object {
+	o := Object{Size: int64(item.Size)}
+	if item.Key != nil {
+		o.Key = strings.TrimPrefix(*item.Key, s.prefix)
+	}
+	if item.LastModified != nil {
+		o.Modified = *item.LastModified
+	}
+	return o
+}
+
+func (s *cloudStore) Stat(key string) (Object, error) {
+	out, err := s.backend.HeadBlob(&goofys.HeadBlobInput{Key: s.prefix + key})
+	if err != nil {
+		if notExist(err) {
+			return Object{}, &os.PathError{Op: "stat", Path: s.name + key, Err: os.ErrNotExist}
+		}
+		return Object{}, err
+	}
+	return s.object(out.BlobItemOutput), nil
+}
+
+func (s *cloudStore) List(prefix string) ([]Object, error) {
+	var objects []Object
+	p := s.prefix + prefix
+	var token *string
+	for {
+		out, err := s.backend.ListBlobs(&goofys.ListBlobsInput{Prefix: &p, ContinuationToken: token})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			objects = append(objects, s.object(item))
+		}
+		if !out.IsTruncated || out.NextContinuationToken == nil {
+			break
+		}
+		token = out.NextContinuationToken
+	}
+	return objects, nil
+}
+
+func (s *cloudStore) Delete(key string) error {
+	_, err := s.backend.DeleteBlob(&goofys.DeleteBlobInput{Key: s.prefix + key})
+	if notExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *cloudStore) Expire() error {
+	_, err := s.backend.MultipartExpire(&goofys.MultipartExpireInput{})
+	return err
+}
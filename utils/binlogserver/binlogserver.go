@@ -0,0 +1,203 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package binlogserver relays the binary logs of a master over GTID replication,
+// stores them locally or in a backup store and serves them to the replicas over
+// the replication protocol. The relay follows a new master from the GTID set
+// stored, so the replicas of the binlog server survive a failover.
+package binlogserver
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"github.com/signal18/replication-manager/utils/backupstore"
+)
+
+type Config struct {
+	// Dir keeps the binary logs and their index
+	Dir  string
+	Bind string
+	Port int
+	// ServerID is the server id of the relay and of the binlog server
+	ServerID uint32
+	// User and Password authenticate the replicas
+	User     string
+	Password string
+	// Prefix names the binary log files, binlog by default
+	Prefix string
+	// KeepDays purges the files closed for longer, 0 keeps them
+	KeepDays int
+	// LocalKeepFiles is the number of local files kept once archived
+	LocalKeepFiles int
+	// Archive copies the closed files in a backup store when not nil
+	Archive backupstore.Store
+}
+
+type BinlogServer struct {
+	conf     Config
+	store    *store
+	listener net.Listener
+	closing  chan struct{}
+
+	mutex    sync.Mutex
+	relay    *relay
+	replicas map[*replicaConn]bool
+	closed   bool
+}
+
+// Status is the state of the relay, of the binary logs and of the replicas
+type Status struct {
+	Address   string    `json:"address"`
+	Source    *Source   `json:"source,omitempty"`
+	Connected bool      `json:"connected"`
+	Error     string    `json:"error,omitempty"`
+	LastEvent time.Time `json:"lastEvent,omitempty"`
+	Flavor    string    `json:"flavor"`
+	GTID      string    `json:"gtid"`
+	Files     []File    `json:"files"`
+	Replicas  []Replica `json:"replicas"`
+}
+
+// New opens the binary logs of the directory, a transaction written partially
+// before a crash is removed
+func New(conf Config) (*BinlogServer, error) {
+	if conf.Dir == "" {
+		return nil, errors.New("No directory for binlog server")
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = "binlog"
+	}
+	st, err := openStore(conf.Dir, conf.Prefix, conf.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	st.archive = conf.Archive
+	return &BinlogServer{conf: conf, store: st, closing: make(chan struct{}), replicas: make(map[*replicaConn]bool)}, nil
+}
+
+// Start listens for the replicas
+func (b *BinlogServer) Start() error {
+	l, err := net.Listen("tcp", net.JoinHostPort(b.conf.Bind, strconv.Itoa(b.conf.Port)))
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	b.listener = l
+	b.mutex.Unlock()
+	go b.accept(l)
+	return nil
+}
+
+// Addr returns the address the replicas connect to
+func (b *BinlogServer) Addr() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.listener == nil {
+		return ""
+	}
+	return b.listener.Addr().String()
+}
+
+// Follow replicates from the source after the transactions already stored, the
+// source replaces the previous one
+func (b *BinlogServer) Follow(src Source) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return
+	}
+	if b.relay != nil {
+		b.relay.stop()
+	}
+	b.relay = startRelay(b.store, src, b.conf.ServerID)
+}
+
+// Source returns the source followed
+func (b *BinlogServer) Source() (Source, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.relay == nil {
+		return Source{}, false
+	}
+	return b.relay.src, true
+}
+
+// Executed returns the GTID set of the transactions stored
+func (b *BinlogServer) Executed() string {
+	return formatGTIDSet(b.store.executed())
+}
+
+// Purge removes the files expired and the local copies of the archived files
+func (b *BinlogServer) Purge() error {
+	return b.store.purge(time.Duration(b.conf.KeepDays)*24*time.Hour, b.conf.LocalKeepFiles)
+}
+
+func (b *BinlogServer) Status() Status {
+	st := Status{
+		Address: b.Addr(),
+		Flavor:  b.store.flavor(),
+		GTID:    b.Executed(),
+		Files:   b.store.files(),
+	}
+	b.mutex.Lock()
+	r := b.relay
+	for c := range b.replicas {
+		st.Replicas = append(st.Replicas, c.state)
+	}
+	b.mutex.Unlock()
+	sort.Slice(st.Replicas, func(i, j int) bool { return st.Replicas[i].Since.Before(st.Replicas[j].Since) })
+	if r != nil {
+		src := r.src
+		st.Source = &src
+		var err error
+		st.Connected, err, st.LastEvent = r.status()
+		if err != nil {
+			st.Error = err.Error()
+		}
+	}
+	return st
+}
+
+// uuid returns the server uuid of a MySQL binlog server, stable for a server id
+func (b *BinlogServer) uuid() string {
+	sum := md5.Sum([]byte(fmt.Sprintf("replication-manager-binlog-server-%d", b.conf.ServerID)))
+	u, _ := uuid.FromBytes(sum[:])
+	return u.String()
+}
+
+// Close stops the relay, disconnects the replicas and closes the binary logs
+func (b *BinlogServer) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.closing)
+	if b.listener != nil {
+		b.listener.Close()
+	}
+	r := b.relay
+	for c := range b.replicas {
+		c.net.Close()
+	}
+	b.mutex.Unlock()
+	if r != nil {
+		r.stop()
+	}
+	return b.store.close()
+}
@@ -0,0 +1,207 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package binlogserver
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+func testFDE(t *testing.T, serverID uint32) []byte {
+	body := make([]byte, 2+50+4+1+40+1)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:], "10.5.0-MariaDB")
+	body[56] = headerSize
+	body[len(body)-1] = replication.BINLOG_CHECKSUM_ALG_CRC32
+	return newEvent(replication.FORMAT_DESCRIPTION_EVENT, serverID, 0, 0, body, true)
+}
+
+func testQuery(serverID uint32, query string) []byte {
+	body := make([]byte, 13+1+len(query))
+	copy(body[14:], query)
+	return newEvent(replication.QUERY_EVENT, serverID, 0, 0, body, true)
+}
+
+// testTxn returns the events of a transaction of the MariaDB GTID
+func testTxn(serverID uint32, seq uint64) [][]byte {
+	gtid := make([]byte, 19)
+	binary.LittleEndian.PutUint64(gtid, seq)
+	xid := make([]byte, 8)
+	binary.LittleEndian.PutUint64(xid, seq)
+	return [][]byte{
+		newEvent(replication.MARIADB_GTID_EVENT, serverID, 0, 0, gtid, true),
+		testQuery(serverID, "BEGIN"),
+		testQuery(serverID, "INSERT INTO t VALUES (1)"),
+		newEvent(replication.XID_EVENT, serverID, 0, 0, xid, true),
+	}
+}
+
+// testMaster returns a binlog server serving the transactions of the servers
+func testMaster(t *testing.T, txns ...[2]uint64) *BinlogServer {
+	b, err := New(Config{Dir: t.TempDir(), Bind: "127.0.0.1", ServerID: 1, User: "repl", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.store.setFlavor(mysql.MariaDBFlavor)
+	if err := b.store.startFile(testFDE(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+	for _, txn := range txns {
+		if err := b.store.appendTxn(fmt.Sprintf("0-%d-%d", txn[0], txn[1]), testTxn(uint32(txn[0]), txn[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func testSource(t *testing.T, b *BinlogServer) Source {
+	host, port, _ := net.SplitHostPort(b.Addr())
+	p, _ := strconv.Atoi(port)
+	return Source{Host: host, Port: uint16(p), User: "repl", Password: "secret", Flavor: mysql.MariaDBFlavor}
+}
+
+func waitGTID(t *testing.T, b *BinlogServer, gtid string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for b.Executed() != gtid {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected GTID %s got %s %+v", gtid, b.Executed(), b.Status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRelayAndFailover(t *testing.T) {
+	m1 := testMaster(t, [2]uint64{1, 1}, [2]uint64{1, 2}, [2]uint64{1, 3})
+	relay, err := New(Config{Dir: t.TempDir(), Bind: "127.0.0.1", ServerID: 10000, User: "repl", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	if err := relay.Start(); err != nil {
+		t.Fatal(err)
+	}
+	relay.Follow(testSource(t, m1))
+	waitGTID(t, relay, "0-1-3")
+
+	// a replica of the binlog server starting after the first transaction
+	src := testSource(t, relay)
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{ServerID: 20, Flavor: mysql.MariaDBFlavor, Host: src.Host, Port: src.Port, User: "repl", Password: "secret"})
+	defer syncer.Close()
+	start, _ := mysql.ParseGTIDSet(mysql.MariaDBFlavor, "0-1-1")
+	streamer, err := syncer.StartSyncGTID(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() string {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for {
+			e, err := streamer.GetEvent(ctx)
+			if err != nil {
+				t.Fatalf("Expected a transaction %s", err)
+			}
+			if g, ok := e.Event.(*replication.MariadbGTIDEvent); ok {
+				return fmt.Sprintf("%d-%d-%d", g.GTID.DomainID, g.GTID.ServerID, g.GTID.SequenceNumber)
+			}
+		}
+	}
+	for _, gtid := range []string{"0-1-2", "0-1-3"} {
+		if g := next(); g != gtid {
+			t.Fatalf("Expected %s got %s", gtid, g)
+		}
+	}
+
+	// the new master has the transactions of the old one and its own
+	m2 := testMaster(t, [2]uint64{1, 1}, [2]uint64{1, 2}, [2]uint64{1, 3}, [2]uint64{2, 4}, [2]uint64{2, 5})
+	relay.Follow(testSource(t, m2))
+	waitGTID(t, relay, "0-2-5")
+	for _, gtid := range []string{"0-2-4", "0-2-5"} {
+		if g := next(); g != gtid {
+			t.Fatalf("Expected %s after the failover got %s", gtid, g)
+		}
+	}
+	st := relay.Status()
+	if len(st.Files) != 2 || st.Files[1].Start != "0-1-3" || !st.Connected || len(st.Replicas) != 1 || st.Replicas[0].ServerID != 20 {
+		t.Errorf("Unexpected status %+v", st)
+	}
+}
+
+func TestStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	b, err := New(Config{Dir: dir, ServerID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.store.setFlavor(mysql.MariaDBFlavor)
+	b.store.startFile(testFDE(t, 1))
+	b.store.appendTxn("0-1-1", testTxn(1, 1))
+	b.store.startFile(testFDE(t, 1))
+	b.store.appendTxn("0-1-2", testTxn(1, 2))
+	size := b.store.files()[1].Size
+	b.Close()
+
+	// a transaction written partially
+	f, err := os.OpenFile(filepath.Join(dir, "binlog.000002"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := testTxn(1, 3)
+	f.Write(partial[0])
+	f.Write(partial[1][:10])
+	f.Close()
+
+	b, err = New(Config{Dir: dir, ServerID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	files := b.store.files()
+	if b.Executed() != "0-1-2" || len(files) != 2 || files[1].Size != size || files[0].Closed.IsZero() {
+		t.Fatalf("Unexpected store after recovery %s %+v", b.Executed(), files)
+	}
+	fi, _ := os.Stat(filepath.Join(dir, "binlog.000002"))
+	if fi.Size() != size {
+		t.Errorf("Expected the partial transaction truncated %d %d", fi.Size(), size)
+	}
+	if err := b.store.appendTxn("0-1-3", testTxn(1, 3)); err != nil || b.Executed() != "0-1-3" {
+		t.Errorf("Expected to append after recovery %s %v", b.Executed(), err)
+	}
+
+	// every file is readable with the positions of the events
+	fd, _ := os.Open(filepath.Join(dir, "binlog.000001"))
+	defer fd.Close()
+	var rotate string
+	scanFile(fd, func(ev []byte, h header, crc bool, end int64, step txnStep, gtid string) error {
+		if int64(h.LogPos) != end || (crc && !validChecksum(ev)) {
+			t.Errorf("Unexpected event %+v at %d", h, end)
+		}
+		if h.Type == replication.ROTATE_EVENT {
+			rotate = string(eventBody(ev, crc)[8:])
+		}
+		return nil
+	})
+	if rotate != "binlog.000002" {
+		t.Errorf("Expected a rotate to the next file got %q", rotate)
+	}
+}
@@ -0,0 +1,274 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package binlogserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/satori/go.uuid"
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+const (
+	headerSize   = replication.EventHeaderSize
+	checksumSize = replication.BinlogChecksumLength
+	// LOG_EVENT_ARTIFICIAL_F marks the events generated by the dump thread
+	flagArtificial = 0x20
+	// XA_PREPARE_LOG_EVENT of MySQL
+	eventXAPrepare replication.EventType = 38
+)
+
+// binlogMagic starts every binary log file
+var binlogMagic = replication.BinLogFileHeader
+
+type header struct {
+	Timestamp uint32
+	Type      replication.EventType
+	ServerID  uint32
+	Size      uint32
+	LogPos    uint32
+	Flags     uint16
+}
+
+func decodeHeader(data []byte) (header, error) {
+	var h header
+	if len(data) < headerSize {
+		return h, errors.New("Binlog event header too short")
+	}
+	h.Timestamp = binary.LittleEndian.Uint32(data)
+	h.Type = replication.EventType(data[4])
+	h.ServerID = binary.LittleEndian.Uint32(data[5:])
+	h.Size = binary.LittleEndian.Uint32(data[9:])
+	h.LogPos = binary.LittleEndian.Uint32(data[13:])
+	h.Flags = binary.LittleEndian.Uint16(data[17:])
+	if h.Size < headerSize {
+		return h, fmt.Errorf("Invalid binlog event size %d", h.Size)
+	}
+	return h, nil
+}
+
+// newEvent returns an event of the body, the checksum is appended when crc is true
+func newEvent(t replication.EventType, serverID uint32, logPos uint32, flags uint16, body []byte, crc bool) []byte {
+	size := headerSize + len(body)
+	if crc {
+		size += checksumSize
+	}
+	ev := make([]byte, size)
+	ev[4] = byte(t)
+	binary.LittleEndian.PutUint32(ev[5:], serverID)
+	binary.LittleEndian.PutUint32(ev[9:], uint32(size))
+	binary.LittleEndian.PutUint32(ev[13:], logPos)
+	binary.LittleEndian.PutUint16(ev[17:], flags)
+	copy(ev[headerSize:], body)
+	if crc {
+		setChecksum(ev)
+	}
+	return ev
+}
+
+func setChecksum(ev []byte) {
+	n := len(ev) - checksumSize
+	binary.LittleEndian.PutUint32(ev[n:], crc32.ChecksumIEEE(ev[:n]))
+}
+
+// setLogPos rewrites the end position of the event and its checksum
+func setLogPos(ev []byte, pos uint32, crc bool) {
+	binary.LittleEndian.PutUint32(ev[13:], pos)
+	if crc {
+		setChecksum(ev)
+	}
+}
+
+// newRotateEvent returns a rotate event to the position of the file, the events
+// sent at the start of a dump are artificial with no timestamp and position
+func newRotateEvent(serverID uint32, logPos uint32, file string, pos uint64, artificial bool, crc bool) []byte {
+	body := make([]byte, 8+len(file))
+	binary.LittleEndian.PutUint64(body, pos)
+	copy(body[8:], file)
+	var flags uint16
+	if artificial {
+		flags = flagArtificial
+		logPos = 0
+	}
+	return newEvent(replication.ROTATE_EVENT, serverID, logPos, flags, body, crc)
+}
+
+func newHeartbeatEvent(serverID uint32, logPos uint32, file string, crc bool) []byte {
+	return newEvent(replication.HEARTBEAT_EVENT, serverID, logPos, 0, []byte(file), crc)
+}
+
+// fdeChecksum returns true when the events described by the format description
+// event end with a CRC32 checksum
+func fdeChecksum(ev []byte) (bool, error) {
+	fde := &replication.FormatDescriptionEvent{}
+	if err := fde.Decode(ev[headerSize:]); err != nil {
+		return false, err
+	}
+	return fde.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32, nil
+}
+
+// validChecksum returns true when the event ends with its checksum, the format
+// description event may be checksummed when the events it describes are not
+func validChecksum(ev []byte) bool {
+	n := len(ev) - checksumSize
+	return n >= headerSize && binary.LittleEndian.Uint32(ev[n:]) == crc32.ChecksumIEEE(ev[:n])
+}
+
+// eventBody returns the data after the header without the checksum
+func eventBody(ev []byte, crc bool) []byte {
+	body := ev[headerSize:]
+	if crc && len(body) >= checksumSize {
+		body = body[:len(body)-checksumSize]
+	}
+	return body
+}
+
+// eventGTID returns the GTID of a GTID event
+func eventGTID(h header, body []byte) (string, error) {
+	switch h.Type {
+	case replication.MARIADB_GTID_EVENT:
+		if len(body) < 13 {
+			return "", errors.New("MariaDB GTID event too short")
+		}
+		seq := binary.LittleEndian.Uint64(body)
+		domain := binary.LittleEndian.Uint32(body[8:])
+		return fmt.Sprintf("%d-%d-%d", domain, h.ServerID, seq), nil
+	case replication.GTID_EVENT:
+		if len(body) < 25 {
+			return "", errors.New("GTID event too short")
+		}
+		u, err := uuid.FromBytes(body[1:17])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:%d", u, binary.LittleEndian.Uint64(body[17:])), nil
+	}
+	return "", nil
+}
+
+// queryText returns the statement of a query event
+func queryText(body []byte) string {
+	// thread id, execution time, schema length, error code and status length
+	if len(body) < 13 {
+		return ""
+	}
+	schema := int(body[8])
+	status := int(binary.LittleEndian.Uint16(body[11:]))
+	pos := 13 + status + schema + 1
+	if pos > len(body) {
+		return ""
+	}
+	return string(body[pos:])
+}
+
+// txnTracker finds the boundaries of the transactions in a stream of events, a
+// transaction opened by BEGIN ends with a XID or COMMIT, otherwise it is the
+// statement after the GTID event
+type txnTracker struct {
+	// GTID of the transaction in progress, empty between transactions
+	gtid  string
+	begun bool
+	anon  bool
+}
+
+// txnStep is the position of an event in a transaction
+type txnStep int
+
+const (
+	txnOutside txnStep = iota
+	txnStart
+	txnInside
+	txnEnd
+)
+
+// next returns the position of the event in its transaction
+func (t *txnTracker) next(h header, body []byte) (txnStep, error) {
+	switch h.Type {
+	case replication.MARIADB_GTID_EVENT, replication.GTID_EVENT:
+		gtid, err := eventGTID(h, body)
+		if err != nil {
+			return txnOutside, err
+		}
+		t.gtid, t.begun, t.anon = gtid, false, false
+		return txnStart, nil
+	case replication.ANONYMOUS_GTID_EVENT:
+		t.gtid, t.begun, t.anon = "", false, true
+		return txnStart, nil
+	}
+	if t.gtid == "" && !t.anon {
+		return txnOutside, nil
+	}
+	switch h.Type {
+	case replication.XID_EVENT, eventXAPrepare:
+		t.reset()
+		return txnEnd, nil
+	case replication.QUERY_EVENT:
+		q := strings.ToUpper(strings.TrimSpace(queryText(body)))
+		if !t.begun {
+			if q == "BEGIN" || strings.HasPrefix(q, "XA START") {
+				t.begun = true
+				return txnInside, nil
+			}
+			// a DDL or a statement outside of a transaction
+			t.reset()
+			return txnEnd, nil
+		}
+		if q == "COMMIT" || q == "ROLLBACK" || strings.HasPrefix(q, "XA END") || strings.HasPrefix(q, "XA COMMIT") || strings.HasPrefix(q, "XA ROLLBACK") {
+			t.reset()
+			return txnEnd, nil
+		}
+	}
+	return txnInside, nil
+}
+
+func (t *txnTracker) reset() {
+	t.gtid, t.begun, t.anon = "", false, false
+}
+
+func parseGTIDSet(flavor string, s string) (mysql.GTIDSet, error) {
+	return mysql.ParseGTIDSet(flavor, strings.TrimSpace(s))
+}
+
+// containsGTID returns true when the transaction is in the set
+func containsGTID(flavor string, set mysql.GTIDSet, gtid string) bool {
+	if set == nil || gtid == "" {
+		return false
+	}
+	g, err := mysql.ParseGTIDSet(flavor, gtid)
+	if err != nil {
+		return false
+	}
+	return set.Contain(g)
+}
+
+// formatGTIDSet returns the set with its domains or sources sorted
+func formatGTIDSet(set mysql.GTIDSet) string {
+	if set == nil {
+		return ""
+	}
+	parts := strings.Split(set.String(), ",")
+	for i := 1; i < len(parts); i++ {
+		for j := i; j > 0 && parts[j] < parts[j-1]; j-- {
+			parts[j], parts[j-1] = parts[j-1], parts[j]
+		}
+	}
+	return strings.Trim(strings.Join(parts, ","), ",")
+}
+
+// isMagic returns true when data starts a binary log file
+func isMagic(data []byte) bool {
+	return bytes.Equal(data, binlogMagic)
+}
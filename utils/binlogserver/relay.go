@@ -0,0 +1,184 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package binlogserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// Source is the master followed by the relay
+type Source struct {
+	Host     string `json:"host"`
+	Port     uint16 `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"-"`
+	// mysql or mariadb
+	Flavor string `json:"flavor"`
+	// GTID set to start from when the store is empty, the oldest position the
+	// replicas need, the past binary logs of the master are skipped
+	StartGTID string `json:"startGtid,omitempty"`
+}
+
+// relay copies the binary logs of the source in the store over GTID replication
+type relay struct {
+	store    *store
+	src      Source
+	serverID uint32
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mutex     sync.Mutex
+	connected bool
+	err       error
+	lastEvent time.Time
+}
+
+const (
+	relayHeartbeat = 5 * time.Second
+	relayRetry     = time.Second
+)
+
+func startRelay(st *store, src Source, serverID uint32) *relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &relay{store: st, src: src, serverID: serverID, cancel: cancel, done: make(chan struct{})}
+	go r.run(ctx)
+	return r
+}
+
+func (r *relay) run(ctx context.Context) {
+	defer close(r.done)
+	// the first file from a new source starts with its format description
+	newSource := true
+	for {
+		err := r.sync(ctx, &newSource)
+		r.mutex.Lock()
+		r.connected = false
+		if ctx.Err() == nil {
+			r.err = err
+		}
+		r.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(relayRetry):
+		}
+	}
+}
+
+func (r *relay) sync(ctx context.Context, newSource *bool) error {
+	if err := r.store.setFlavor(r.src.Flavor); err != nil {
+		return err
+	}
+	set := r.store.executed()
+	if set == nil || (!r.store.hasFile() && set.String() == "") {
+		var err error
+		if set, err = parseGTIDSet(r.src.Flavor, r.src.StartGTID); err != nil {
+			return err
+		}
+	}
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:        r.serverID,
+		Flavor:          r.src.Flavor,
+		Host:            r.src.Host,
+		Port:            r.src.Port,
+		User:            r.src.User,
+		Password:        r.src.Password,
+		RawModeEnabled:  true,
+		HeartbeatPeriod: relayHeartbeat,
+		ReadTimeout:     3 * relayHeartbeat,
+		// a reconnection of the syncer starts from the first position, the relay
+		// starts again from the transactions stored instead
+		MaxReconnectAttempts: 1,
+	})
+	defer syncer.Close()
+	streamer, err := syncer.StartSyncGTID(set)
+	if err != nil {
+		return err
+	}
+
+	var tracker txnTracker
+	var txn [][]byte
+	var gtid string
+	var skip, crc, rotated bool
+	for {
+		e, err := streamer.GetEvent(ctx)
+		if err != nil {
+			return err
+		}
+		r.mutex.Lock()
+		r.connected, r.err, r.lastEvent = true, nil, time.Now()
+		r.mutex.Unlock()
+		ev := append([]byte(nil), e.RawData...)
+		h, err := decodeHeader(ev)
+		if err != nil {
+			return err
+		}
+		switch h.Type {
+		case replication.HEARTBEAT_EVENT:
+			continue
+		case replication.ROTATE_EVENT:
+			// the files of the store are rotated on the rotations of the master
+			if h.Flags&flagArtificial == 0 {
+				rotated = true
+			}
+			tracker.reset()
+			txn = nil
+			continue
+		case replication.FORMAT_DESCRIPTION_EVENT:
+			if crc, err = fdeChecksum(ev); err != nil {
+				return err
+			}
+			tracker.reset()
+			txn = nil
+			if rotated || *newSource || !r.store.hasFile() || crc != r.store.checksum() {
+				if err := r.store.startFile(ev); err != nil {
+					return err
+				}
+				rotated, *newSource = false, false
+			}
+			continue
+		}
+		step, err := tracker.next(h, eventBody(ev, crc))
+		if err != nil {
+			return err
+		}
+		switch step {
+		case txnStart:
+			gtid = tracker.gtid
+			skip = r.store.contains(gtid)
+			txn = [][]byte{ev}
+		case txnInside:
+			txn = append(txn, ev)
+		case txnEnd:
+			txn = append(txn, ev)
+			if !skip {
+				if err := r.store.appendTxn(gtid, txn); err != nil {
+					return err
+				}
+			}
+			txn = nil
+		}
+	}
+}
+
+func (r *relay) status() (connected bool, err error, lastEvent time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.connected, r.err, r.lastEvent
+}
+
+func (r *relay) stop() {
+	r.cancel()
+	<-r.done
+}
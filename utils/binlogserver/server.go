@@ -0,0 +1,447 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package binlogserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+	siddon "github.com/siddontang/go-mysql/server"
+)
+
+const (
+	versionMariaDB = "5.5.5-10.5.0-MariaDB-replication-manager"
+	versionMySQL   = "8.0.25-replication-manager"
+)
+
+var (
+	reSpaces    = regexp.MustCompile(`\s+`)
+	reSetUser   = regexp.MustCompile(`(?i)^SET\s+@(\w+)\s*=\s*(.+)$`)
+	reLike      = regexp.MustCompile(`(?i)^SHOW\s+(?:GLOBAL\s+|SESSION\s+)?VARIABLES\s+LIKE\s+'([^']*)'$`)
+	reSelectVar = regexp.MustCompile(`(?i)^SELECT\s+(@@?[\w.]+(?:\s*,\s*@@?[\w.]+)*)$`)
+)
+
+// Replica is a replica connected to the binlog server
+type Replica struct {
+	Address  string    `json:"address"`
+	ServerID uint32    `json:"serverId"`
+	Since    time.Time `json:"since"`
+	File     string    `json:"file"`
+	Position int64     `json:"position"`
+	// GTID of the last transaction sent
+	GTID string `json:"gtid"`
+}
+
+// replicaConn serves a client of the binlog server, a replica dumps the binary
+// logs after setting the variables of the replication protocol
+type replicaConn struct {
+	srv  *BinlogServer
+	conn *siddon.Conn
+	net  net.Conn
+	// user variables set by the replica
+	vars  map[string]string
+	state Replica
+}
+
+func (b *BinlogServer) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go b.serve(conn)
+	}
+}
+
+func (b *BinlogServer) version() string {
+	if b.store.flavor() == MySQLFlavor {
+		return versionMySQL
+	}
+	return versionMariaDB
+}
+
+func (b *BinlogServer) serve(conn net.Conn) {
+	defer conn.Close()
+	p := siddon.NewInMemoryProvider()
+	p.AddUser(b.conf.User, b.conf.Password)
+	r := &replicaConn{srv: b, net: conn, vars: make(map[string]string)}
+	r.state.Address = conn.RemoteAddr().String()
+	r.state.Since = time.Now()
+	c, err := siddon.NewCustomizedConn(conn, siddon.NewServer(b.version(), DEFAULT_COLLATION_ID, AUTH_NATIVE_PASSWORD, nil, nil), p, r)
+	if err != nil {
+		return
+	}
+	r.conn = c
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+	b.replicas[r] = true
+	b.mutex.Unlock()
+	defer func() {
+		b.mutex.Lock()
+		delete(b.replicas, r)
+		b.mutex.Unlock()
+	}()
+	for {
+		if err := c.HandleCommand(); err != nil {
+			return
+		}
+	}
+}
+
+func (r *replicaConn) UseDB(dbName string) error {
+	return nil
+}
+
+func (r *replicaConn) HandleFieldList(table string, fieldWildcard string) ([]*Field, error) {
+	return nil, nil
+}
+
+func (r *replicaConn) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return 0, 0, nil, NewError(ER_NOT_SUPPORTED_YET, "Prepared statements are not supported by the binlog server")
+}
+
+func (r *replicaConn) HandleStmtExecute(context interface{}, query string, args []interface{}) (*Result, error) {
+	return nil, NewError(ER_NOT_SUPPORTED_YET, "Prepared statements are not supported by the binlog server")
+}
+
+func (r *replicaConn) HandleStmtClose(context interface{}) error {
+	return nil
+}
+
+// variable returns a global variable of the binlog server
+func (r *replicaConn) variable(name string) (string, bool) {
+	b := r.srv
+	executed := formatGTIDSet(b.store.executed())
+	switch strings.ToLower(name) {
+	case "server_id":
+		return strconv.FormatUint(uint64(b.conf.ServerID), 10), true
+	case "server_uuid":
+		return b.uuid(), true
+	case "version":
+		return b.version(), true
+	case "binlog_checksum":
+		if b.store.checksum() {
+			return "CRC32", true
+		}
+		return "NONE", true
+	case "gtid_mode", "enforce_gtid_consistency", "log_bin", "log_slave_updates":
+		return "ON", true
+	case "gtid_domain_id":
+		return "0", true
+	case "gtid_binlog_pos", "gtid_current_pos", "gtid_executed":
+		return executed, true
+	case "gtid_purged":
+		if files := b.store.files(); len(files) > 0 {
+			return files[0].Start, true
+		}
+		return "", true
+	case "rpl_semi_sync_master_enabled", "rpl_semi_sync_slave_enabled", "read_only":
+		return "OFF", true
+	case "port":
+		return strconv.Itoa(b.conf.Port), true
+	}
+	return "", false
+}
+
+func (r *replicaConn) HandleQuery(query string) (*Result, error) {
+	q := strings.TrimRight(strings.TrimSpace(reSpaces.ReplaceAllString(query, " ")), "; ")
+	uq := strings.ToUpper(q)
+	switch {
+	case uq == "SELECT UNIX_TIMESTAMP()":
+		return resultset([]string{"UNIX_TIMESTAMP()"}, []interface{}{time.Now().Unix()})
+	case uq == "SELECT VERSION()":
+		return resultset([]string{"VERSION()"}, []interface{}{r.srv.version()})
+	case uq == "SHOW MASTER STATUS" || uq == "SHOW BINARY LOG STATUS":
+		files := r.srv.store.files()
+		if len(files) == 0 {
+			return resultset([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"})
+		}
+		f := files[len(files)-1]
+		return resultset([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, []interface{}{f.Name, f.Size, "", "", formatGTIDSet(r.srv.store.executed())})
+	case uq == "SHOW BINARY LOGS" || uq == "SHOW MASTER LOGS":
+		var rows [][]interface{}
+		for _, f := range r.srv.store.files() {
+			rows = append(rows, []interface{}{f.Name, f.Size})
+		}
+		return resultset([]string{"Log_name", "File_size"}, rows...)
+	}
+	if m := reSetUser.FindStringSubmatch(q); m != nil {
+		name, value := strings.ToLower(m[1]), strings.Trim(strings.TrimSpace(m[2]), "'\"")
+		if strings.EqualFold(value, "@@global.binlog_checksum") {
+			value, _ = r.variable("binlog_checksum")
+		}
+		r.vars[name] = value
+		return nil, nil
+	}
+	if strings.HasPrefix(uq, "SET ") {
+		return nil, nil
+	}
+	if m := reLike.FindStringSubmatch(q); m != nil {
+		if v, ok := r.variable(m[1]); ok {
+			return resultset([]string{"Variable_name", "Value"}, []interface{}{strings.ToLower(m[1]), v})
+		}
+		return resultset([]string{"Variable_name", "Value"})
+	}
+	if m := reSelectVar.FindStringSubmatch(q); m != nil {
+		var names []string
+		var row []interface{}
+		for _, n := range strings.Split(m[1], ",") {
+			n = strings.TrimSpace(n)
+			names = append(names, n)
+			if strings.HasPrefix(n, "@@") {
+				name := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(n), "@@"), "global."), "session.")
+				if v, ok := r.variable(name); ok {
+					row = append(row, v)
+					continue
+				}
+			} else if v, ok := r.vars[strings.ToLower(n[1:])]; ok {
+				row = append(row, v)
+				continue
+			}
+			row = append(row, nil)
+		}
+		return resultset(names, row)
+	}
+	return nil, NewError(ER_NOT_SUPPORTED_YET, fmt.Sprintf("Statement not supported by the binlog server: %s", q))
+}
+
+func resultset(names []string, rows ...[]interface{}) (*Result, error) {
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+	// the protocol helper encodes empty strings as NULL
+	for _, row := range rows {
+		for i, v := range row {
+			if v == "" {
+				row[i] = []byte{}
+			}
+		}
+	}
+	rs, err := BuildSimpleResultset(names, rows, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Resultset: rs}, nil
+}
+
+func (r *replicaConn) HandleOtherCommand(cmd byte, data []byte) error {
+	switch cmd {
+	case COM_REGISTER_SLAVE:
+		if len(data) >= 4 {
+			r.setState(func(s *Replica) { s.ServerID = binary.LittleEndian.Uint32(data) })
+		}
+		return nil
+	case COM_BINLOG_DUMP:
+		if len(data) < 10 {
+			return NewError(ER_MALFORMED_PACKET, "Malformed binlog dump packet")
+		}
+		pos := int64(binary.LittleEndian.Uint32(data))
+		r.setState(func(s *Replica) { s.ServerID = binary.LittleEndian.Uint32(data[6:]) })
+		name := string(data[10:])
+		if state, ok := r.vars["slave_connect_state"]; ok {
+			set, err := parseGTIDSet(MariaDBFlavor, state)
+			if err != nil {
+				return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, err.Error())
+			}
+			return r.dumpGTID(set)
+		}
+		return r.dump(name, pos, nil)
+	case COM_BINLOG_DUMP_GTID:
+		// flags, server id, file name, position and GTID set
+		if len(data) < 10 {
+			return NewError(ER_MALFORMED_PACKET, "Malformed binlog dump packet")
+		}
+		r.setState(func(s *Replica) { s.ServerID = binary.LittleEndian.Uint32(data[2:]) })
+		n := int(binary.LittleEndian.Uint32(data[6:]))
+		p := 10 + n + 8
+		if len(data) < p+4 {
+			return NewError(ER_MALFORMED_PACKET, "Malformed binlog dump packet")
+		}
+		size := int(binary.LittleEndian.Uint32(data[p:]))
+		if len(data) < p+4+size {
+			return NewError(ER_MALFORMED_PACKET, "Malformed binlog dump packet")
+		}
+		set, err := DecodeMysqlGTIDSet(data[p+4 : p+4+size])
+		if err != nil {
+			return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, err.Error())
+		}
+		return r.dumpGTID(set)
+	}
+	return NewError(ER_UNKNOWN_ERROR, fmt.Sprintf("Command %d is not supported by the binlog server", cmd))
+}
+
+func (r *replicaConn) setState(fn func(s *Replica)) {
+	r.srv.mutex.Lock()
+	fn(&r.state)
+	r.srv.mutex.Unlock()
+}
+
+// dumpGTID streams from the last file starting before the state of the replica
+func (r *replicaConn) dumpGTID(set GTIDSet) error {
+	files := r.srv.store.files()
+	flavor := r.srv.store.flavor()
+	for i := len(files) - 1; i >= 0; i-- {
+		start, err := parseGTIDSet(flavor, files[i].Start)
+		if err != nil {
+			continue
+		}
+		if set.Contain(start) {
+			return r.dump(files[i].Name, int64(len(binlogMagic)), set)
+		}
+	}
+	return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, fmt.Sprintf("Could not find GTID state requested by slave in any binlog files, binlog server starts at %s", r.firstStart(files)))
+}
+
+func (r *replicaConn) firstStart(files []File) string {
+	if len(files) == 0 {
+		return "no binlog"
+	}
+	return "'" + files[0].Start + "'"
+}
+
+// dump streams the events of the file from the position, the transactions in
+// the GTID set are skipped. It returns when the connection is closed.
+func (r *replicaConn) dump(name string, pos int64, set GTIDSet) error {
+	b := r.srv
+	flavor := b.store.flavor()
+	if name == "" {
+		files := b.store.files()
+		if len(files) == 0 {
+			return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, "Binary log is not open")
+		}
+		name = files[0].Name
+	}
+	if pos < int64(len(binlogMagic)) {
+		pos = int64(len(binlogMagic))
+	}
+	if _, ok := r.vars["master_binlog_checksum"]; !ok && b.store.checksum() {
+		return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, "Slave can not handle replication events with the checksum that master is configured to log")
+	}
+	heartbeat := 30 * time.Second
+	if v, err := strconv.ParseInt(r.vars["master_heartbeat_period"], 10, 64); err == nil && v > 0 {
+		heartbeat = time.Duration(v)
+	}
+	for {
+		f, ok := b.store.stat(name)
+		if !ok {
+			return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, "Could not find first log file name in binary log index file")
+		}
+		fd, err := b.store.openFile(name)
+		if err != nil {
+			return NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, fmt.Sprintf("Could not open log file %s: %s", name, err))
+		}
+		next, nextPos, err := r.dumpFile(fd, f, pos, set, flavor, heartbeat)
+		fd.Close()
+		if err != nil {
+			return err
+		}
+		name, pos = next, nextPos
+	}
+}
+
+// dumpFile sends the events of the file and returns the file it rotates to
+func (r *replicaConn) dumpFile(fd *os.File, f File, pos int64, set GTIDSet, flavor string, heartbeat time.Duration) (string, int64, error) {
+	b := r.srv
+	if err := r.send(newRotateEvent(b.conf.ServerID, 0, f.Name, uint64(pos), true, f.Checksum)); err != nil {
+		return "", 0, err
+	}
+	offset := int64(len(binlogMagic))
+	var tracker txnTracker
+	skip := false
+	timer := time.NewTimer(heartbeat)
+	defer timer.Stop()
+	for {
+		// the channel is taken before the size to miss no write
+		changed := b.store.changed()
+		cur, _ := b.store.stat(f.Name)
+		if offset >= cur.Size {
+			select {
+			case <-changed:
+			case <-b.closing:
+				return "", 0, io.EOF
+			case <-timer.C:
+				timer.Reset(heartbeat)
+				if err := r.send(newHeartbeatEvent(b.conf.ServerID, uint32(offset), f.Name, f.Checksum)); err != nil {
+					return "", 0, err
+				}
+			}
+			continue
+		}
+		for offset < cur.Size {
+			ev, h, err := readEvent(io.NewSectionReader(fd, offset, cur.Size-offset))
+			if err != nil {
+				return "", 0, NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, fmt.Sprintf("Could not read log file %s at %d: %s", f.Name, offset, err))
+			}
+			offset += int64(len(ev))
+			switch h.Type {
+			case replication.FORMAT_DESCRIPTION_EVENT:
+				if pos > int64(len(binlogMagic)) {
+					// the replica does not move its position on this event
+					crc := validChecksum(ev)
+					setLogPos(ev, 0, crc)
+				}
+				if err := r.send(ev); err != nil {
+					return "", 0, err
+				}
+				continue
+			case replication.ROTATE_EVENT:
+				if err := r.send(ev); err != nil {
+					return "", 0, err
+				}
+				body := eventBody(ev, f.Checksum)
+				return string(body[8:]), int64(binary.LittleEndian.Uint64(body)), nil
+			}
+			if offset <= pos {
+				continue
+			}
+			step, err := tracker.next(h, eventBody(ev, f.Checksum))
+			if err != nil {
+				return "", 0, NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, err.Error())
+			}
+			if step == txnStart {
+				skip = set != nil && containsGTID(flavor, set, tracker.gtid)
+				if !skip && tracker.gtid != "" {
+					gtid := tracker.gtid
+					r.setState(func(s *Replica) { s.GTID = gtid })
+				}
+			}
+			if skip {
+				if step == txnEnd {
+					skip = false
+				}
+				continue
+			}
+			if err := r.send(ev); err != nil {
+				return "", 0, err
+			}
+			r.setState(func(s *Replica) { s.File, s.Position = f.Name, offset })
+		}
+		timer.Reset(heartbeat)
+	}
+}
+
+// send writes an event in an OK packet
+func (r *replicaConn) send(ev []byte) error {
+	data := make([]byte, 4+1+len(ev))
+	copy(data[5:], ev)
+	return r.conn.WritePacket(data)
+}
@@ -0,0 +1,543 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package binlogserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+	"github.com/signal18/replication-manager/utils/backupstore"
+)
+
+const indexFile = "index.json"
+
+// ArchivePrefix is the key prefix of the binary logs archived in the backup store
+const ArchivePrefix = "binlog-server/"
+
+// File is a binary log file of the store
+type File struct {
+	Name string `json:"name"`
+	// GTID set executed before the first transaction of the file
+	Start    string    `json:"start"`
+	Size     int64     `json:"size"`
+	Checksum bool      `json:"checksum"`
+	Created  time.Time `json:"created"`
+	Closed   time.Time `json:"closed,omitempty"`
+	Archived bool      `json:"archived"`
+	Local    bool      `json:"local"`
+}
+
+type index struct {
+	Flavor string  `json:"flavor"`
+	Files  []*File `json:"files"`
+}
+
+// store keeps the binary logs in sequence of files named like the ones of a
+// server, only complete transactions are written and the end position of every
+// event is an offset of its local file
+type store struct {
+	mutex    sync.Mutex
+	dir      string
+	prefix   string
+	serverID uint32
+	idx      index
+	gtid     mysql.GTIDSet
+	cur      *os.File
+	// closed and replaced on every write to wake up the readers
+	notify   chan struct{}
+	archive  backupstore.Store
+	archives sync.WaitGroup
+}
+
+func openStore(dir string, prefix string, serverID uint32) (*store, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	s := &store{dir: dir, prefix: prefix, serverID: serverID, notify: make(chan struct{})}
+	data, err := ioutil.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.idx); err != nil {
+			return nil, fmt.Errorf("Invalid binlog server index: %s", err)
+		}
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *store) last() *File {
+	if len(s.idx.Files) == 0 {
+		return nil
+	}
+	return s.idx.Files[len(s.idx.Files)-1]
+}
+
+func (s *store) file(name string) *File {
+	for _, f := range s.idx.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// recover scans the last file to rebuild the executed GTID set, a transaction
+// written partially before a crash is truncated
+func (s *store) recover() error {
+	f := s.last()
+	if f == nil {
+		return nil
+	}
+	set, err := parseGTIDSet(s.idx.Flavor, f.Start)
+	if err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(s.path(f.Name), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	var size int64
+	var rotated bool
+	err = scanFile(fd, func(ev []byte, h header, crc bool, end int64, step txnStep, gtid string) error {
+		switch {
+		case step == txnEnd:
+			if gtid != "" {
+				if err := set.Update(gtid); err != nil {
+					return err
+				}
+			}
+			size = end
+		case step == txnOutside:
+			size = end
+			rotated = h.Type == replication.ROTATE_EVENT
+		}
+		return nil
+	})
+	if err != nil && err != io.ErrUnexpectedEOF {
+		fd.Close()
+		return err
+	}
+	if size <= int64(len(binlogMagic)) {
+		// no format description event, the file is started again
+		fd.Close()
+		os.Remove(s.path(f.Name))
+		s.idx.Files = s.idx.Files[:len(s.idx.Files)-1]
+		s.gtid = set
+		return s.saveIndex()
+	}
+	if err := fd.Truncate(size); err != nil {
+		fd.Close()
+		return err
+	}
+	if _, err := fd.Seek(size, io.SeekStart); err != nil {
+		fd.Close()
+		return err
+	}
+	f.Size = size
+	s.gtid = set
+	if rotated {
+		if f.Closed.IsZero() {
+			f.Closed = time.Now()
+		}
+		fd.Close()
+		return s.saveIndex()
+	}
+	f.Closed = time.Time{}
+	s.cur = fd
+	return s.saveIndex()
+}
+
+// scanFile calls fn on every complete event of the file with the end offset of
+// the event and its position in a transaction
+func scanFile(r io.Reader, fn func(ev []byte, h header, crc bool, end int64, step txnStep, gtid string) error) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(binlogMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !isMagic(magic) {
+		return io.ErrUnexpectedEOF
+	}
+	pos := int64(len(magic))
+	var tracker txnTracker
+	var crc bool
+	for {
+		ev, h, err := readEvent(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pos += int64(len(ev))
+		if h.Type == replication.FORMAT_DESCRIPTION_EVENT {
+			if crc, err = fdeChecksum(ev); err != nil {
+				return err
+			}
+		}
+		gtid := tracker.gtid
+		step, err := tracker.next(h, eventBody(ev, crc))
+		if err != nil {
+			return err
+		}
+		if step == txnStart {
+			gtid = tracker.gtid
+		}
+		if err := fn(ev, h, crc, pos, step, gtid); err != nil {
+			return err
+		}
+	}
+}
+
+// readEvent returns the next event, io.ErrUnexpectedEOF on a truncated event
+func readEvent(r io.Reader) ([]byte, header, error) {
+	head := make([]byte, headerSize)
+	if n, err := io.ReadFull(r, head); err != nil {
+		if n == 0 && err == io.EOF {
+			return nil, header{}, io.EOF
+		}
+		return nil, header{}, io.ErrUnexpectedEOF
+	}
+	h, err := decodeHeader(head)
+	if err != nil {
+		return nil, h, err
+	}
+	ev := make([]byte, h.Size)
+	copy(ev, head)
+	if _, err := io.ReadFull(r, ev[headerSize:]); err != nil {
+		return nil, h, io.ErrUnexpectedEOF
+	}
+	return ev, h, nil
+}
+
+func (s *store) saveIndex() error {
+	data, err := json.MarshalIndent(s.idx, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := s.path(indexFile + ".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(indexFile))
+}
+
+// setFlavor sets the GTID flavor of an empty store, a store keeps its flavor
+func (s *store) setFlavor(flavor string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.idx.Flavor == "" || len(s.idx.Files) == 0 {
+		s.idx.Flavor = flavor
+		return nil
+	}
+	if s.idx.Flavor != flavor {
+		return fmt.Errorf("Binlog server store is %s and can not follow a %s master", s.idx.Flavor, flavor)
+	}
+	return nil
+}
+
+func (s *store) flavor() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.idx.Flavor
+}
+
+// executed returns the GTID set of the transactions stored
+func (s *store) executed() mysql.GTIDSet {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.gtid == nil {
+		return nil
+	}
+	return s.gtid.Clone()
+}
+
+// contains returns true when the transaction is stored
+func (s *store) contains(gtid string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return containsGTID(s.idx.Flavor, s.gtid, gtid)
+}
+
+func (s *store) hasFile() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cur != nil
+}
+
+func (s *store) checksum() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if f := s.last(); f != nil {
+		return f.Checksum
+	}
+	return false
+}
+
+// files returns a copy of the files
+func (s *store) files() []File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	files := make([]File, len(s.idx.Files))
+	for i, f := range s.idx.Files {
+		files[i] = *f
+	}
+	return files
+}
+
+// changed returns a channel closed on the next write
+func (s *store) changed() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.notify
+}
+
+func (s *store) wakeUp() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+func (s *store) nextName() string {
+	n := 1
+	if f := s.last(); f != nil {
+		fmt.Sscanf(f.Name[len(s.prefix)+1:], "%d", &n)
+		n++
+	}
+	return fmt.Sprintf("%s.%06d", s.prefix, n)
+}
+
+// startFile closes the current file with a rotate event and starts a new one
+// with the format description event
+func (s *store) startFile(fde []byte) error {
+	crc, err := fdeChecksum(fde)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.gtid == nil {
+		if s.gtid, err = parseGTIDSet(s.idx.Flavor, ""); err != nil {
+			return err
+		}
+	}
+	name := s.nextName()
+	var closed *File
+	if s.cur != nil {
+		closed = s.last()
+		rotate := newRotateEvent(s.serverID, 0, name, uint64(len(binlogMagic)), false, closed.Checksum)
+		setLogPos(rotate, uint32(closed.Size)+uint32(len(rotate)), closed.Checksum)
+		if _, err := s.cur.Write(rotate); err != nil {
+			return err
+		}
+		s.cur.Close()
+		s.cur = nil
+		closed.Size += int64(len(rotate))
+		closed.Closed = time.Now()
+	}
+	fd, err := os.OpenFile(s.path(name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	ev := append([]byte(nil), fde...)
+	setLogPos(ev, uint32(len(binlogMagic)+len(ev)), validChecksum(fde))
+	if _, err := fd.Write(append(append([]byte(nil), binlogMagic...), ev...)); err != nil {
+		fd.Close()
+		return err
+	}
+	s.cur = fd
+	s.idx.Files = append(s.idx.Files, &File{
+		Name:     name,
+		Start:    formatGTIDSet(s.gtid),
+		Size:     int64(len(binlogMagic) + len(ev)),
+		Checksum: crc,
+		Created:  time.Now(),
+		Local:    true,
+	})
+	if err := s.saveIndex(); err != nil {
+		return err
+	}
+	s.wakeUp()
+	if closed != nil && s.archive != nil {
+		s.archiveFile(closed.Name)
+	}
+	return nil
+}
+
+// appendTxn writes the events of a transaction at once
+func (s *store) appendTxn(gtid string, events [][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f := s.last()
+	if s.cur == nil || f == nil {
+		return errors.New("No binlog file to write")
+	}
+	var buf []byte
+	pos := f.Size
+	for _, ev := range events {
+		pos += int64(len(ev))
+		ev = append([]byte(nil), ev...)
+		setLogPos(ev, uint32(pos), f.Checksum)
+		buf = append(buf, ev...)
+	}
+	if _, err := s.cur.Write(buf); err != nil {
+		// the next transaction is written over a partial write
+		s.cur.Truncate(f.Size)
+		s.cur.Seek(f.Size, io.SeekStart)
+		return err
+	}
+	if gtid != "" {
+		if err := s.gtid.Update(gtid); err != nil {
+			return err
+		}
+	}
+	f.Size = pos
+	s.wakeUp()
+	return nil
+}
+
+// openFile opens a file for reading, it is copied back from the archive when it
+// is not local anymore
+func (s *store) openFile(name string) (*os.File, error) {
+	s.mutex.Lock()
+	f := s.file(name)
+	if f == nil {
+		s.mutex.Unlock()
+		return nil, os.ErrNotExist
+	}
+	local, archive := f.Local, s.archive
+	s.mutex.Unlock()
+	if !local {
+		if archive == nil {
+			return nil, os.ErrNotExist
+		}
+		if err := download(archive, ArchivePrefix+name, s.path(name)); err != nil {
+			return nil, err
+		}
+		s.mutex.Lock()
+		if f := s.file(name); f != nil {
+			f.Local = true
+		}
+		s.mutex.Unlock()
+	}
+	return os.Open(s.path(name))
+}
+
+func download(archive backupstore.Store, key string, filename string) error {
+	r, err := archive.Open(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tmp := filename + ".tmp"
+	fd, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fd, r)
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// stat returns a copy of the file
+func (s *store) stat(name string) (File, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if f := s.file(name); f != nil {
+		return *f, true
+	}
+	return File{}, false
+}
+
+// archiveFile uploads a closed file to the archive in the background
+func (s *store) archiveFile(name string) {
+	s.archives.Add(1)
+	go func() {
+		defer s.archives.Done()
+		if err := backupstore.Upload(s.archive, ArchivePrefix+name, s.path(name)); err != nil {
+			return
+		}
+		s.mutex.Lock()
+		if f := s.file(name); f != nil {
+			f.Archived = true
+			s.saveIndex()
+		}
+		s.mutex.Unlock()
+	}()
+}
+
+// purge removes the files closed for longer than keep, local copies of the
+// archived files are kept for the last localKeep files only. The current file is
+// never removed.
+func (s *store) purge(keep time.Duration, localKeep int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var kept []*File
+	var err error
+	n := len(s.idx.Files)
+	for i, f := range s.idx.Files {
+		if i == n-1 || f.Closed.IsZero() {
+			kept = append(kept, f)
+			continue
+		}
+		if keep > 0 && time.Since(f.Closed) > keep {
+			if rerr := os.Remove(s.path(f.Name)); rerr != nil && !os.IsNotExist(rerr) {
+				err = rerr
+			}
+			if f.Archived && s.archive != nil {
+				s.archive.Delete(ArchivePrefix + f.Name)
+			}
+			continue
+		}
+		if f.Local && f.Archived && localKeep > 0 && i < n-localKeep {
+			if rerr := os.Remove(s.path(f.Name)); rerr == nil || os.IsNotExist(rerr) {
+				f.Local = false
+			}
+		}
+		kept = append(kept, f)
+	}
+	s.idx.Files = kept
+	if serr := s.saveIndex(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func (s *store) close() error {
+	s.archives.Wait()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cur != nil {
+		s.cur.Close()
+		s.cur = nil
+	}
+	s.wakeUp()
+	return s.saveIndex()
+}
@@ -0,0 +1,286 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package capacity keeps the usage of the resources of a database server over time
+// and forecasts when they will be exhausted.
+//
+// A forecast is a least squares trend with a seasonal component once the history
+// spans two seasons: the hour of the day for the disk and the data size, the day of
+// the week for the daily peak of connections and the daily binlog volume.
+package capacity
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	ConstMetricDisk        = "disk"
+	ConstMetricData        = "data"
+	ConstMetricConnections = "connections"
+	ConstMetricBinlog      = "binlog"
+)
+
+const day = 24 * time.Hour
+
+// Sample is the usage of a server, sizes in bytes, short keys keep the history
+// compact
+type Sample struct {
+	Time      time.Time `json:"t"`
+	DiskUsed  int64     `json:"du,omitempty"`
+	DiskTotal int64     `json:"dt,omitempty"`
+	DataSize  int64     `json:"ds,omitempty"`
+	// peak of connections since the previous sample
+	Connections    int64 `json:"c"`
+	MaxConnections int64 `json:"mc"`
+	// binary logs written since the previous sample
+	BinlogBytes int64 `json:"b,omitempty"`
+}
+
+type History struct {
+	Samples []Sample `json:"samples"`
+}
+
+// Load reads the history of file, a missing file is an empty history
+func Load(file string) (*History, error) {
+	h := &History{}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *History) Save(file string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// Add appends the sample and drops the samples older than keep
+func (h *History) Add(s Sample, keep time.Duration) {
+	h.Samples = append(h.Samples, s)
+	oldest := s.Time.Add(-keep)
+	i := sort.Search(len(h.Samples), func(i int) bool { return !h.Samples[i].Time.Before(oldest) })
+	h.Samples = h.Samples[i:]
+}
+
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Model is a linear trend with the average deviation from it by bucket of a period
+type Model struct {
+	origin    time.Time
+	slope     float64
+	intercept float64
+	period    time.Duration
+	seasonal  []float64
+}
+
+// Fit returns the model of the points, nil with less than two points. The seasonal
+// component has buckets per period when the points span two periods.
+func Fit(points []Point, period time.Duration, buckets int) *Model {
+	if len(points) < 2 {
+		return nil
+	}
+	m := &Model{origin: points[0].Time, period: period}
+	var sx, sy, sxx, sxy float64
+	n := float64(len(points))
+	for _, p := range points {
+		x := p.Time.Sub(m.origin).Seconds()
+		sx += x
+		sy += p.Value
+		sxx += x * x
+		sxy += x * p.Value
+	}
+	if d := n*sxx - sx*sx; d != 0 {
+		m.slope = (n*sxy - sx*sy) / d
+	}
+	m.intercept = (sy - m.slope*sx) / n
+	if buckets > 0 && points[len(points)-1].Time.Sub(m.origin) >= 2*period {
+		sums := make([]float64, buckets)
+		counts := make([]float64, buckets)
+		for _, p := range points {
+			b := m.bucket(p.Time, buckets)
+			sums[b] += p.Value - m.trend(p.Time)
+			counts[b]++
+		}
+		m.seasonal = make([]float64, buckets)
+		for b := range sums {
+			if counts[b] > 0 {
+				m.seasonal[b] = sums[b] / counts[b]
+			}
+		}
+	}
+	return m
+}
+
+func (m *Model) bucket(t time.Time, buckets int) int {
+	offset := t.Sub(m.origin) % m.period
+	if offset < 0 {
+		offset += m.period
+	}
+	return int(int64(offset) * int64(buckets) / int64(m.period))
+}
+
+func (m *Model) trend(t time.Time) float64 {
+	return m.intercept + m.slope*t.Sub(m.origin).Seconds()
+}
+
+func (m *Model) Predict(t time.Time) float64 {
+	v := m.trend(t)
+	if m.seasonal != nil {
+		v += m.seasonal[m.bucket(t, len(m.seasonal))]
+	}
+	return v
+}
+
+// PerDay is the growth of the trend in a day
+func (m *Model) PerDay() float64 {
+	return m.slope * day.Seconds()
+}
+
+// Reach returns the first time by step after from and within horizon at which the
+// prediction is at least limit
+func (m *Model) Reach(limit float64, from time.Time, horizon time.Duration, step time.Duration) (time.Time, bool) {
+	for t := from; !t.After(from.Add(horizon)); t = t.Add(step) {
+		if m.Predict(t) >= limit {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Forecast of a metric, the unit of sizes is the byte
+type Forecast struct {
+	Metric  string  `json:"metric"`
+	Current float64 `json:"current"`
+	// 0 when the metric has no limit
+	Limit  float64 `json:"limit"`
+	PerDay float64 `json:"perDay"`
+	// -1 when the limit is not reached within the horizon
+	DaysToLimit float64 `json:"daysToLimit"`
+	Seasonal    bool    `json:"seasonal"`
+	History     []Point `json:"history"`
+	Forecast    []Point `json:"forecast"`
+}
+
+// daily aggregates the values of the samples by day with fn, the first day and
+// the day of now are left out as they are partial
+func daily(samples []Sample, now time.Time, value func(Sample) int64, fn func(int64, int64) int64) []Point {
+	var points []Point
+	if len(samples) == 0 {
+		return points
+	}
+	first := samples[0].Time.Truncate(day)
+	today := now.Truncate(day)
+	for _, s := range samples {
+		d := s.Time.Truncate(day)
+		if d.Equal(first) {
+			continue
+		}
+		if !d.Before(today) {
+			break
+		}
+		n := len(points)
+		if n > 0 && points[n-1].Time.Equal(d) {
+			points[n-1].Value = float64(fn(int64(points[n-1].Value), value(s)))
+			continue
+		}
+		points = append(points, Point{Time: d, Value: float64(value(s))})
+	}
+	return points
+}
+
+func max(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func sum(a int64, b int64) int64 {
+	return a + b
+}
+
+// forecast fits the history and returns a point a day over the horizon, the limit
+// is searched by step
+func forecast(metric string, history []Point, limit float64, now time.Time, horizon time.Duration, step time.Duration, period time.Duration, buckets int) *Forecast {
+	m := Fit(history, period, buckets)
+	if m == nil {
+		return nil
+	}
+	f := &Forecast{
+		Metric:      metric,
+		Current:     history[len(history)-1].Value,
+		Limit:       limit,
+		PerDay:      m.PerDay(),
+		DaysToLimit: -1,
+		Seasonal:    m.seasonal != nil,
+		History:     history,
+		Forecast:    []Point{},
+	}
+	for t := now.Truncate(day).Add(day); !t.After(now.Add(horizon)); t = t.Add(day) {
+		f.Forecast = append(f.Forecast, Point{Time: t, Value: m.Predict(t)})
+	}
+	if limit > 0 {
+		if t, ok := m.Reach(limit, now, horizon, step); ok {
+			f.DaysToLimit = t.Sub(now).Hours() / 24
+		}
+	}
+	return f
+}
+
+// Forecasts returns the forecasts of the metrics with enough history
+func Forecasts(samples []Sample, now time.Time, horizon time.Duration) []Forecast {
+	var disk, data []Point
+	var diskTotal, maxConnections float64
+	for _, s := range samples {
+		if s.DiskTotal > 0 {
+			disk = append(disk, Point{Time: s.Time, Value: float64(s.DiskUsed)})
+			diskTotal = float64(s.DiskTotal)
+		}
+		if s.DataSize > 0 {
+			data = append(data, Point{Time: s.Time, Value: float64(s.DataSize)})
+		}
+		if s.MaxConnections > 0 {
+			maxConnections = float64(s.MaxConnections)
+		}
+	}
+	connections := daily(samples, now, func(s Sample) int64 { return s.Connections }, max)
+	binlogs := daily(samples, now, func(s Sample) int64 { return s.BinlogBytes }, sum)
+
+	res := []Forecast{}
+	for _, f := range []*Forecast{
+		forecast(ConstMetricDisk, disk, diskTotal, now, horizon, time.Hour, day, 24),
+		forecast(ConstMetricData, data, 0, now, horizon, time.Hour, day, 24),
+		forecast(ConstMetricConnections, connections, maxConnections, now, horizon, day, 7*day, 7),
+		forecast(ConstMetricBinlog, binlogs, 0, now, horizon, day, 7*day, 7),
+	} {
+		if f != nil {
+			res = append(res, *f)
+		}
+	}
+	return res
+}
@@ -0,0 +1,116 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package capacity
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFit(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < 24*14; i++ {
+		ts := start.Add(time.Duration(i) * time.Hour)
+		v := 1000 + float64(i)*10
+		// busier during the day
+		if ts.Hour() >= 8 && ts.Hour() < 20 {
+			v += 100
+		} else {
+			v -= 100
+		}
+		points = append(points, Point{Time: ts, Value: v})
+	}
+	m := Fit(points, day, 24)
+	if m == nil || m.seasonal == nil {
+		t.Fatalf("Expected a seasonal model")
+	}
+	if math.Abs(m.PerDay()-240) > 1 {
+		t.Errorf("Expected a growth of 240 a day got %f", m.PerDay())
+	}
+	noon := start.Add(20*day + 12*time.Hour)
+	night := start.Add(20*day + 2*time.Hour)
+	if p := m.Predict(noon) - m.Predict(night); math.Abs(p-(200+100)) > 10 {
+		t.Errorf("Expected the day to be higher than the night by 300 got %f", p)
+	}
+	if ts, ok := m.Reach(1000+24*20*10, start.Add(14*day), 30*day, time.Hour); !ok || ts.Before(start.Add(19*day)) || ts.After(start.Add(20*day)) {
+		t.Errorf("Unexpected reach %s %t", ts, ok)
+	}
+	if Fit(points[:1], day, 24) != nil {
+		t.Errorf("Expected no model with a point")
+	}
+	if m := Fit(points[:48], day, 24); m.seasonal != nil {
+		t.Errorf("Expected no season under two periods")
+	}
+}
+
+func TestForecasts(t *testing.T) {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	h := &History{}
+	var s Sample
+	for i := 0; i < 30*24; i++ {
+		s = Sample{
+			Time:           start.Add(time.Duration(i) * time.Hour),
+			DiskUsed:       50e9 + int64(i)*100e6,
+			DiskTotal:      200e9,
+			DataSize:       40e9 + int64(i)*100e6,
+			Connections:    int64(100 + i/24),
+			MaxConnections: 151,
+			BinlogBytes:    1e6,
+		}
+		h.Add(s, 10*day)
+	}
+	if len(h.Samples) != 10*24+1 || !h.Samples[0].Time.Equal(s.Time.Add(-10*day)) {
+		t.Errorf("Expected %d samples from %s got %d from %s", 10*24+1, s.Time.Add(-10*day), len(h.Samples), h.Samples[0].Time)
+	}
+
+	file := filepath.Join(t.TempDir(), "capacity.json")
+	if h, err := Load(file); err != nil || len(h.Samples) != 0 {
+		t.Fatalf("Expected an empty history %v", err)
+	}
+	if err := h.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(file)
+	if err != nil || len(loaded.Samples) != len(h.Samples) || loaded.Samples[0].DiskUsed != h.Samples[0].DiskUsed {
+		t.Fatalf("Unexpected history %v", err)
+	}
+
+	now := s.Time.Add(30 * time.Minute)
+	forecasts := Forecasts(loaded.Samples, now, 90*day)
+	res := make(map[string]Forecast)
+	for _, f := range forecasts {
+		res[f.Metric] = f
+	}
+	if len(res) != 4 {
+		t.Fatalf("Expected 4 forecasts got %d", len(res))
+	}
+	// 100MB an hour until the disk is full
+	disk := res[ConstMetricDisk]
+	if math.Abs(disk.PerDay-2.4e9) > 1e6 || math.Abs(disk.DaysToLimit-(200e9-disk.Current)/2.4e9) > 0.1 || len(disk.Forecast) != 90 {
+		t.Errorf("Unexpected disk forecast %f %f %d", disk.PerDay, disk.DaysToLimit, len(disk.Forecast))
+	}
+	if res[ConstMetricData].DaysToLimit != -1 {
+		t.Errorf("Expected no limit on the data")
+	}
+	conn := res[ConstMetricConnections]
+	if len(conn.History) != 9 || math.Abs(conn.PerDay-1) > 0.01 || conn.Limit != 151 || math.Abs(conn.DaysToLimit-(151-conn.Current)) > 1 {
+		t.Errorf("Unexpected connections forecast %+v", conn)
+	}
+	binlog := res[ConstMetricBinlog]
+	if binlog.Current != 24e6 || math.Abs(binlog.PerDay) > 1 {
+		t.Errorf("Unexpected binlog forecast %+v", binlog)
+	}
+	if f := Forecasts(nil, now, day); len(f) != 0 {
+		t.Errorf("Expected no forecast without history")
+	}
+}
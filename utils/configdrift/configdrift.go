@@ -0,0 +1,386 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package configdrift compares the runtime variables of a database server with its
+// generated configuration and with the variables of the master.
+//
+// A drift is classified as intended by the role of the server, dangerous for a
+// failover when the replicas would not behave like the master once promoted, or
+// cosmetic. Drifts of dynamic variables are fixed with SET GLOBAL, drifts of
+// static variables need the server to be reprovisioned.
+package configdrift
+
+import (
+	"bufio"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ConstSourceConfig = "config"
+	ConstSourceMaster = "master"
+
+	ConstClassRole     = "role"
+	ConstClassFailover = "failover"
+	ConstClassCosmetic = "cosmetic"
+)
+
+// identity variables are specific to each server and never compared to the master
+var identity = map[string]bool{
+	"PORT": true, "SERVER_ID": true, "SERVER_UUID": true, "PID_FILE": true, "SOCKET": true,
+	"HOSTNAME": true, "REPORT_HOST": true, "REPORT_PORT": true, "BIND_ADDRESS": true,
+	"DATADIR": true, "TMPDIR": true, "LOG_ERROR": true, "GENERAL_LOG_FILE": true, "SLOW_QUERY_LOG_FILE": true,
+	"LOG_BIN_INDEX": true, "LOG_BIN_BASENAME": true, "RELAY_LOG": true, "RELAY_LOG_BASENAME": true, "RELAY_LOG_INDEX": true,
+	"GTID_SLAVE_POS": true, "GTID_CURRENT_POS": true, "GTID_BINLOG_POS": true, "GTID_BINLOG_STATE": true,
+	"GTID_PURGED": true, "GTID_EXECUTED": true, "IN_TRANSACTION": true, "TIMESTAMP": true,
+	"WSREP_NODE_NAME": true, "WSREP_NODE_ADDRESS": true, "WSREP_NODE_INCOMING_ADDRESS": true,
+	"WSREP_SST_RECEIVE_ADDRESS": true, "WSREP_DATA_HOME_DIR": true, "WSREP_GTID_DOMAIN_ID": true,
+	"SERVER_UID": true, "INNODB_BUFFER_POOL_LOAD_NOW": true, "INNODB_BUFFER_POOL_DUMP_NOW": true,
+	"THREAD_POOL_SIZE": true, "GTID_DOMAIN_ID": true, "AUTO_INCREMENT_OFFSET": true,
+	"INNODB_VERSION": true, "PROTOCOL_VERSION": true, "LICENSE": true,
+}
+
+// identity prefixes of the build of each server as VERSION_COMMENT or VERSION_COMPILE_OS
+var identityPrefixes = []string{"VERSION"}
+
+// sizing variables follow the memory and the load of each host, they are compared to
+// the configuration but not to the master
+var sizing = map[string]bool{
+	"INNODB_BUFFER_POOL_SIZE": true, "INNODB_BUFFER_POOL_CHUNK_SIZE": true, "INNODB_LOG_FILE_SIZE": true,
+	"INNODB_LOG_BUFFER_SIZE": true, "INNODB_REDO_LOG_CAPACITY": true, "INNODB_IO_CAPACITY": true,
+	"INNODB_IO_CAPACITY_MAX": true, "KEY_BUFFER_SIZE": true, "ARIA_PAGECACHE_BUFFER_SIZE": true,
+	"QUERY_CACHE_SIZE": true, "TMP_TABLE_SIZE": true, "MAX_HEAP_TABLE_SIZE": true, "SORT_BUFFER_SIZE": true,
+	"JOIN_BUFFER_SIZE": true, "READ_BUFFER_SIZE": true, "READ_RND_BUFFER_SIZE": true, "MAX_CONNECTIONS": true,
+	"THREAD_CACHE_SIZE": true, "TABLE_OPEN_CACHE": true, "TABLE_DEFINITION_CACHE": true, "OPEN_FILES_LIMIT": true,
+}
+
+// role variables differ between a master and its replicas by design
+var role = map[string]bool{
+	"READ_ONLY": true, "SUPER_READ_ONLY": true, "INNODB_READ_ONLY": true, "EVENT_SCHEDULER": true,
+	"RPL_SEMI_SYNC_MASTER_ENABLED": true, "RPL_SEMI_SYNC_SLAVE_ENABLED": true,
+	"RPL_SEMI_SYNC_SOURCE_ENABLED": true, "RPL_SEMI_SYNC_REPLICA_ENABLED": true,
+	"SKIP_SLAVE_START": true, "SKIP_REPLICA_START": true,
+}
+
+// role prefixes of the replication filters and of the parallel applier of the replicas
+var rolePrefixes = []string{"REPLICATE_", "SLAVE_PARALLEL_", "REPLICA_PARALLEL_"}
+
+// failover variables change the result of the statements once a replica is promoted
+var failover = map[string]bool{
+	"SQL_MODE": true, "BINLOG_FORMAT": true, "BINLOG_ROW_IMAGE": true, "BINLOG_CHECKSUM": true,
+	"GTID_STRICT_MODE": true, "GTID_MODE": true, "ENFORCE_GTID_CONSISTENCY": true,
+	"LOG_BIN": true, "LOG_SLAVE_UPDATES": true, "LOG_REPLICA_UPDATES": true,
+	"LOWER_CASE_TABLE_NAMES": true, "CHARACTER_SET_SERVER": true, "COLLATION_SERVER": true,
+	"TIME_ZONE": true, "DEFAULT_STORAGE_ENGINE": true, "EXPLICIT_DEFAULTS_FOR_TIMESTAMP": true,
+	"MAX_ALLOWED_PACKET": true, "TRANSACTION_ISOLATION": true, "TX_ISOLATION": true,
+	"INNODB_AUTOINC_LOCK_MODE": true, "AUTO_INCREMENT_INCREMENT": true, "INNODB_STRICT_MODE": true,
+	"LOG_BIN_TRUST_FUNCTION_CREATORS": true, "DIV_PRECISION_INCREMENT": true, "GROUP_CONCAT_MAX_LEN": true,
+}
+
+// static variables commonly set in the configuration that can not be changed at
+// runtime, servers able to report it extend the list
+var static = map[string]bool{
+	"LOG_BIN": true, "LOG_SLAVE_UPDATES": true, "LOWER_CASE_TABLE_NAMES": true, "INNODB_PAGE_SIZE": true,
+	"INNODB_LOG_FILES_IN_GROUP": true, "INNODB_BUFFER_POOL_INSTANCES": true, "INNODB_DATA_FILE_PATH": true,
+	"INNODB_FLUSH_METHOD": true, "INNODB_DOUBLEWRITE": true, "INNODB_AUTOINC_LOCK_MODE": true,
+	"INNODB_LOG_BUFFER_SIZE": true, "INNODB_READ_IO_THREADS": true, "INNODB_WRITE_IO_THREADS": true,
+	"INNODB_OPEN_FILES": true, "INNODB_READ_ONLY": true, "PERFORMANCE_SCHEMA": true, "SKIP_NAME_RESOLVE": true,
+	"THREAD_HANDLING": true, "BACK_LOG": true, "OPEN_FILES_LIMIT": true, "TABLE_OPEN_CACHE_INSTANCES": true,
+	"CHARACTER_SET_SYSTEM": true, "SKIP_NETWORKING": true, "LOG_BIN_INDEX": true, "RELAY_LOG": true,
+	"SKIP_SLAVE_START": true, "SKIP_REPLICA_START": true, "EXPLICIT_DEFAULTS_FOR_TIMESTAMP": true,
+	"LARGE_PAGES": true, "LOCKED_IN_MEMORY": true, "THREAD_STACK": true, "PLUGIN_DIR": true,
+}
+
+// ParseOptionFile returns the options of the server groups of a my.cnf file with
+// their name in variable form, an option without value is ON
+func ParseOptionFile(content string) map[string]string {
+	options := make(map[string]string)
+	server := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+		if line[0] == '[' {
+			group := strings.ToLower(strings.Trim(line, "[] \t"))
+			server = group == "mysqld" || group == "server" || group == "mariadb" || group == "galera" ||
+				strings.HasPrefix(group, "mysqld-") || strings.HasPrefix(group, "mariadb-") || strings.HasPrefix(group, "mariadbd")
+			continue
+		}
+		if !server {
+			continue
+		}
+		name, value := line, "ON"
+		if i := strings.Index(line, "="); i >= 0 {
+			name, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+				value = value[1 : len(value)-1]
+			} else if i := strings.Index(value, "#"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		options[VariableName(name)] = value
+	}
+	return options
+}
+
+// VariableName returns the name of a variable as reported by the server
+func VariableName(option string) string {
+	return strings.ToUpper(strings.Replace(strings.TrimSpace(option), "-", "_", -1))
+}
+
+// resolve returns the variable and its value for an option, the loose, skip,
+// enable and disable prefixes are variable modifiers
+func resolve(name string, value string, runtime map[string]string) (string, string, bool) {
+	if _, ok := runtime[name]; ok {
+		return name, value, true
+	}
+	name = strings.TrimPrefix(name, "LOOSE_")
+	if _, ok := runtime[name]; ok {
+		return name, value, true
+	}
+	for prefix, v := range map[string]string{"SKIP_": "OFF", "DISABLE_": "OFF", "ENABLE_": "ON"} {
+		if strings.HasPrefix(name, prefix) {
+			if _, ok := runtime[name[len(prefix):]]; ok {
+				return name[len(prefix):], v, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// normalize returns a value comparable across the configuration and the runtime
+func normalize(value string) string {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	switch v {
+	case "ON", "TRUE", "YES":
+		return "ON"
+	case "OFF", "FALSE", "NO":
+		return "OFF"
+	}
+	if n, ok := size(v); ok {
+		return n
+	}
+	if strings.Contains(v, ",") {
+		items := strings.Split(v, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return v
+}
+
+// size returns the bytes of a size with a K, M, G or T unit
+func size(v string) (string, bool) {
+	n := len(v)
+	if n < 2 {
+		return "", false
+	}
+	shift := strings.IndexByte("KMGT", v[n-1]&^0x20)
+	if shift < 0 {
+		return "", false
+	}
+	i, err := strconv.ParseInt(v[:n-1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatInt(i<<(10*uint(shift+1)), 10), true
+}
+
+// Equal compares an expected and a runtime value, a list of key=value items such
+// as optimizer_switch only needs its items in the runtime value
+func Equal(expected string, actual string) bool {
+	e, a := normalize(expected), normalize(actual)
+	if e == a {
+		return true
+	}
+	// booleans are 1 and 0 on some variables
+	if (e == "ON" && a == "1") || (e == "1" && a == "ON") || (e == "OFF" && a == "0") || (e == "0" && a == "OFF") {
+		return true
+	}
+	if strings.Contains(e, "=") {
+		items := make(map[string]bool)
+		for _, item := range strings.Split(a, ",") {
+			items[item] = true
+		}
+		for _, item := range strings.Split(e, ",") {
+			if !items[item] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func hasPrefix(variable string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(variable, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdentity tells if a variable is specific to each server
+func isIdentity(variable string) bool {
+	return identity[variable] || hasPrefix(variable, identityPrefixes)
+}
+
+// Class returns the class of the drift of a variable
+func Class(variable string) string {
+	if role[variable] || hasPrefix(variable, rolePrefixes) {
+		return ConstClassRole
+	}
+	if failover[variable] {
+		return ConstClassFailover
+	}
+	return ConstClassCosmetic
+}
+
+// Rules are the drifts to ignore, a rule is a variable pattern with an optional
+// server as in sort_buffer_size or innodb_*@db1:3306
+type Rules struct {
+	rules [][2]string
+}
+
+func NewRules(rules string) *Rules {
+	r := &Rules{}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		server := ""
+		if i := strings.Index(rule, "@"); i >= 0 {
+			rule, server = rule[:i], rule[i+1:]
+		}
+		r.rules = append(r.rules, [2]string{VariableName(rule), server})
+	}
+	return r
+}
+
+func (r *Rules) Ignored(server string, variable string) bool {
+	if r == nil {
+		return false
+	}
+	for _, rule := range r.rules {
+		if rule[1] != "" && rule[1] != server {
+			continue
+		}
+		if ok, _ := path.Match(rule[0], variable); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type Drift struct {
+	Variable string `json:"variable"`
+	Value    string `json:"value"`
+	Expected string `json:"expected"`
+	Source   string `json:"source"`
+	Class    string `json:"class"`
+	Dynamic  bool   `json:"dynamic"`
+	// SET GLOBAL statement fixing a dynamic variable, empty for a role drift. The
+	// variables of the master are upper cased, the remediation of a master drift
+	// must use the value read from the master.
+	Remediation string `json:"remediation"`
+}
+
+type Report struct {
+	Server string  `json:"server"`
+	Master bool    `json:"master"`
+	Drifts []Drift `json:"drifts"`
+	// a static variable drifts and the server needs a reprovisioning
+	Reprov bool `json:"reprov"`
+}
+
+// Server is the state of a server to compare
+type Server struct {
+	URL    string
+	Master bool
+	// runtime variables by upper case name
+	Variables map[string]string
+	// options of the generated configuration, nil when not generated
+	Config map[string]string
+	// variables that can not be set at runtime in addition to the built-in list
+	ReadOnly map[string]bool
+}
+
+// Compare returns the drifts of a server from its configuration and for a replica
+// from the variables of the master
+func Compare(s Server, master map[string]string, rules *Rules) Report {
+	r := Report{Server: s.URL, Master: s.Master, Drifts: []Drift{}}
+	seen := make(map[string]bool)
+	add := func(variable string, expected string, source string) {
+		if seen[variable] || rules.Ignored(s.URL, variable) || Equal(expected, s.Variables[variable]) {
+			return
+		}
+		seen[variable] = true
+		d := Drift{
+			Variable: variable,
+			Value:    s.Variables[variable],
+			Expected: expected,
+			Source:   source,
+			Class:    Class(variable),
+			Dynamic:  !static[variable] && !s.ReadOnly[variable],
+		}
+		if d.Class != ConstClassRole {
+			if d.Dynamic {
+				d.Remediation = SetGlobal(variable, expected)
+			} else {
+				r.Reprov = true
+			}
+		}
+		r.Drifts = append(r.Drifts, d)
+	}
+	names := make([]string, 0, len(s.Config))
+	for name := range s.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if variable, value, ok := resolve(name, s.Config[name], s.Variables); ok && !isIdentity(variable) {
+			add(variable, value, ConstSourceConfig)
+		}
+	}
+	if !s.Master && master != nil {
+		names = names[:0]
+		for name := range master {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, ok := s.Variables[name]; ok && !isIdentity(name) && !sizing[name] {
+				add(name, master[name], ConstSourceMaster)
+			}
+		}
+	}
+	return r
+}
+
+// SetGlobal returns the statement setting a global variable, numbers and
+// booleans are not quoted
+func SetGlobal(variable string, value string) string {
+	v := strings.TrimSpace(value)
+	if n, ok := size(v); ok {
+		v = n
+	} else if n := normalize(v); n == "ON" || n == "OFF" {
+		v = n
+	} else if _, err := strconv.ParseFloat(v, 64); err != nil {
+		v = "'" + strings.Replace(strings.Replace(v, "\\", "\\\\", -1), "'", "\\'", -1) + "'"
+	}
+	return "SET GLOBAL " + strings.ToLower(variable) + " = " + v
+}
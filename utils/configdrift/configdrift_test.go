@@ -0,0 +1,173 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package configdrift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOptionFile(t *testing.T) {
+	options := ParseOptionFile(`
+[client]
+port = 3307
+
+[mysqld]
+# comment
+innodb-buffer-pool-size = 1G
+sql_mode = "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION"
+skip-name-resolve
+loose_rpl_semi_sync_master_enabled = ON # inline
+!includedir /etc/mysql/conf.d
+
+[mariadb-10.6]
+binlog_format=ROW
+`)
+	expected := map[string]string{
+		"INNODB_BUFFER_POOL_SIZE":            "1G",
+		"SQL_MODE":                           "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION",
+		"SKIP_NAME_RESOLVE":                  "ON",
+		"LOOSE_RPL_SEMI_SYNC_MASTER_ENABLED": "ON",
+		"BINLOG_FORMAT":                      "ROW",
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("Unexpected options %v", options)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, c := range []struct {
+		expected, actual string
+		equal            bool
+	}{
+		{"1G", "1073741824", true},
+		{"on", "1", true},
+		{"NO_ENGINE_SUBSTITUTION,STRICT_TRANS_TABLES", "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION", true},
+		{"index_merge=off", "INDEX_MERGE=OFF,MRR=ON", true},
+		{"index_merge=off", "INDEX_MERGE=ON,MRR=ON", false},
+		{"ROW", "MIXED", false},
+	} {
+		if Equal(c.expected, c.actual) != c.equal {
+			t.Errorf("Expected %s and %s equal %t", c.expected, c.actual, c.equal)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	master := map[string]string{
+		"SQL_MODE":         "STRICT_TRANS_TABLES",
+		"READ_ONLY":        "OFF",
+		"SERVER_ID":        "1",
+		"LONG_QUERY_TIME":  "1.000000",
+		"INNODB_PAGE_SIZE": "16384",
+		"TIME_ZONE":        "SYSTEM",
+	}
+	replica := Server{
+		URL: "db2:3306",
+		Variables: map[string]string{
+			"SQL_MODE":                "",
+			"READ_ONLY":               "ON",
+			"SERVER_ID":               "2",
+			"LONG_QUERY_TIME":         "10.000000",
+			"INNODB_PAGE_SIZE":        "65536",
+			"TIME_ZONE":               "SYSTEM",
+			"INNODB_BUFFER_POOL_SIZE": "134217728",
+			"SLOW_QUERY_LOG":          "ON",
+		},
+		Config: map[string]string{
+			"INNODB_BUFFER_POOL_SIZE": "1G",
+			"SKIP_SLOW_QUERY_LOG":     "ON",
+			"SERVER_ID":               "3",
+			"PLUGIN_LOAD_ADD":         "ha_rocksdb",
+		},
+	}
+	r := Compare(replica, master, NewRules("long_*@db3:3306"))
+	var drifts []string
+	for _, d := range r.Drifts {
+		drifts = append(drifts, d.Variable+" "+d.Source+" "+d.Class+" "+d.Remediation)
+	}
+	expected := []string{
+		"INNODB_BUFFER_POOL_SIZE config cosmetic SET GLOBAL innodb_buffer_pool_size = 1073741824",
+		"SLOW_QUERY_LOG config cosmetic SET GLOBAL slow_query_log = OFF",
+		"INNODB_PAGE_SIZE master cosmetic ",
+		"LONG_QUERY_TIME master cosmetic SET GLOBAL long_query_time = 1.000000",
+		"READ_ONLY master role ",
+		"SQL_MODE master failover SET GLOBAL sql_mode = 'STRICT_TRANS_TABLES'",
+	}
+	if !reflect.DeepEqual(drifts, expected) || !r.Reprov {
+		t.Errorf("Unexpected drifts %q reprov %t", drifts, r.Reprov)
+	}
+
+	r = Compare(replica, master, NewRules("long_*, innodb_*@db2:3306,sql_mode@db3:3306"))
+	if len(r.Drifts) != 3 || r.Reprov {
+		t.Errorf("Expected the rules to ignore drifts %+v", r.Drifts)
+	}
+
+	replica.Master = true
+	if r := Compare(replica, master, nil); len(r.Drifts) != 2 {
+		t.Errorf("Expected no comparison of a master with itself %+v", r.Drifts)
+	}
+}
+
+func TestCompareIdentityAndRole(t *testing.T) {
+	master := map[string]string{
+		"VERSION":                 "10.6.12-MARIADB-LOG",
+		"VERSION_COMMENT":         "MARIADB SERVER",
+		"REPLICATE_DO_DB":         "",
+		"SLAVE_PARALLEL_THREADS":  "0",
+		"INNODB_BUFFER_POOL_SIZE": "8589934592",
+		"MAX_CONNECTIONS":         "1000",
+		"BINLOG_FORMAT":           "ROW",
+	}
+	replica := Server{
+		URL: "db2:3306",
+		Variables: map[string]string{
+			"VERSION":                 "10.6.14-MARIADB-LOG",
+			"VERSION_COMMENT":         "MARIADB SERVER BINARY DISTRIBUTION",
+			"REPLICATE_DO_DB":         "APP",
+			"SLAVE_PARALLEL_THREADS":  "8",
+			"INNODB_BUFFER_POOL_SIZE": "1073741824",
+			"MAX_CONNECTIONS":         "200",
+			"BINLOG_FORMAT":           "MIXED",
+		},
+		Config: map[string]string{
+			"MAX_CONNECTIONS": "500",
+		},
+	}
+	r := Compare(replica, master, nil)
+	var drifts []string
+	for _, d := range r.Drifts {
+		drifts = append(drifts, d.Variable+" "+d.Source+" "+d.Class)
+	}
+	expected := []string{
+		"MAX_CONNECTIONS config cosmetic",
+		"BINLOG_FORMAT master failover",
+		"REPLICATE_DO_DB master role",
+		"SLAVE_PARALLEL_THREADS master role",
+	}
+	if !reflect.DeepEqual(drifts, expected) || r.Reprov {
+		t.Errorf("Unexpected drifts %q reprov %t", drifts, r.Reprov)
+	}
+}
+
+func TestSetGlobal(t *testing.T) {
+	for value, expected := range map[string]string{
+		"256M":     "SET GLOBAL v = 268435456",
+		"true":     "SET GLOBAL v = ON",
+		"0.5":      "SET GLOBAL v = 0.5",
+		"+00:00":   "SET GLOBAL v = '+00:00'",
+		"it's":     "SET GLOBAL v = 'it\\'s'",
+		"ANSI,TRA": "SET GLOBAL v = 'ANSI,TRA'",
+	} {
+		if s := SetGlobal("V", value); s != expected {
+			t.Errorf("Expected %s got %s", expected, s)
+		}
+	}
+}
@@ -10,7 +10,9 @@
 package dbhelper
 
 import (
+	"context"
 	"database/sql"
+	sqldriver "database/sql/driver"
 	"errors"
 	"fmt"
 	"hash/crc64"
@@ -37,11 +39,9 @@ type Plugin struct {
 	License string         `json:"license"`
 }
 
-type chunk struct {
-	ChunkId       uint64 `json:"chunkId"`
-	ChunkMinKey   string `json:"chunkMinKey"`
-	ChunkMaxKey   string `json:"chunkMaxKey"`
-	ChunkCheckSum uint64 `json:"chunkCheckSum"`
+type TableColumn struct {
+	Name     string `json:"name"`
+	DataType string `json:"dataType"`
 }
 
 type MetaDataLock struct {
@@ -59,6 +59,55 @@ type ResponseTime struct {
 	Total string `json:"total" db:"TOTAL"`
 }
 
+// PFSStatementEvent is a statement of events_statements_history_long, timers are in
+// picoseconds since the server start
+type PFSStatementEvent struct {
+	Thread_id   uint64 `db:"thread_id"`
+	Timer_start uint64 `db:"timer_start"`
+	Timer_end   uint64 `db:"timer_end"`
+	Timer_wait  uint64 `db:"timer_wait"`
+	Schema_name string `db:"schema_name"`
+	Sql_text    string `db:"sql_text"`
+	Errno       int    `db:"errno"`
+}
+
+// PFSDigestCounters are the counters of a digest cumulated since it was first seen,
+// latency in ms and age in seconds
+type PFSDigestCounters struct {
+	Digest        string  `db:"digest"`
+	Schema_name   string  `db:"schema_name"`
+	Digest_text   string  `db:"digest_text"`
+	Exec_count    int64   `db:"exec_count"`
+	Err_count     int64   `db:"err_count"`
+	Rows_examined int64   `db:"rows_examined"`
+	Rows_sent     int64   `db:"rows_sent"`
+	Latency       float64 `db:"latency"`
+	Age           int64   `db:"age"`
+}
+
+// PFSDigestBucket is the number of executions of a digest up to a latency in ms
+type PFSDigestBucket struct {
+	Digest      string  `db:"digest"`
+	Bucket_high float64 `db:"bucket_high"`
+	Count       int64   `db:"count"`
+}
+
+// IndexColumn is a column of an index in the index order
+type IndexColumn struct {
+	Table_schema string `db:"table_schema"`
+	Table_name   string `db:"table_name"`
+	Index_name   string `db:"index_name"`
+	Non_unique   int    `db:"non_unique"`
+	Column_name  string `db:"column_name"`
+}
+
+// SchemaColumn is a column of a user table
+type SchemaColumn struct {
+	Table_schema string `db:"table_schema"`
+	Table_name   string `db:"table_name"`
+	Column_name  string `db:"column_name"`
+}
+
 type PFSQuery struct {
 	Digest           string          `json:"digest"`
 	Query            string          `json:"query"`
@@ -322,6 +371,73 @@ func GetQueryExplain(db *sqlx.DB, version *MySQLVersion, schema string, query st
 	return pl, stmt, nil
 }
 
+// GetQueryPlan explains a query of schema on a dedicated connection that is discarded
+// afterwards so that the pool never inherits the schema, the columns of EXPLAIN not in
+// Explain are ignored
+func GetQueryPlan(db *sqlx.DB, schema string, query string) ([]Explain, string, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close()
+	defer conn.Raw(func(interface{}) error { return sqldriver.ErrBadConn })
+	if schema != "" {
+		stmt := "USE `" + schema + "`"
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return nil, stmt, err
+		}
+	}
+	stmt := "EXPLAIN " + query
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, stmt, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, stmt, err
+	}
+	pl := []Explain{}
+	for rows.Next() {
+		var e Explain
+		var id sql.NullInt64
+		dest := make([]interface{}, len(cols))
+		for i, col := range cols {
+			switch col {
+			case "id":
+				dest[i] = &id
+			case "select_type":
+				dest[i] = &e.Select_type
+			case "table":
+				dest[i] = &e.Table
+			case "type":
+				dest[i] = &e.Type
+			case "possible_keys":
+				dest[i] = &e.Possible_keys
+			case "key":
+				dest[i] = &e.Key
+			case "key_len":
+				dest[i] = &e.Key_len
+			case "ref":
+				dest[i] = &e.Ref
+			case "rows":
+				dest[i] = &e.Rows
+			case "Extra":
+				dest[i] = &e.Extra
+			default:
+				dest[i] = new(sql.RawBytes)
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, stmt, err
+		}
+		e.Id = uint(id.Int64)
+		pl = append(pl, e)
+	}
+	return pl, stmt, rows.Err()
+}
+
 func GetMetaDataLock(db *sqlx.DB, version *MySQLVersion) ([]MetaDataLock, string, error) {
 	/*	select pid from pg_locks l
 		join pg_class t on l.relation = t.oid
@@ -1456,6 +1572,125 @@ func DisablePFSQueries(db *sqlx.DB) (string, error) {
 	return query, err
 }
 
+// GetPFSStatementsHistory returns the statements ended after the timer end, the
+// statements of user are excluded
+func GetPFSStatementsHistory(db *sqlx.DB, end uint64, user string) ([]PFSStatementEvent, string, error) {
+	events := []PFSStatementEvent{}
+	query := `SELECT
+	H.THREAD_ID AS thread_id,
+	H.TIMER_START AS timer_start,
+	H.TIMER_END AS timer_end,
+	H.TIMER_WAIT AS timer_wait,
+	COALESCE(H.CURRENT_SCHEMA,'') AS schema_name,
+	H.SQL_TEXT AS sql_text,
+	H.MYSQL_ERRNO AS errno
+	FROM performance_schema.events_statements_history_long H
+	LEFT JOIN performance_schema.threads T ON T.THREAD_ID=H.THREAD_ID
+	WHERE H.TIMER_END > ? AND H.SQL_TEXT IS NOT NULL
+	AND COALESCE(T.TYPE,'FOREGROUND')='FOREGROUND' AND COALESCE(T.PROCESSLIST_USER,'')<>?
+	ORDER BY H.TIMER_END`
+	err := db.Select(&events, query, end, user)
+	return events, query, err
+}
+
+// GetPFSDigestCounters returns the counters of the limit digests with the highest
+// total latency, a digest run in several schemas is summed
+func GetPFSDigestCounters(db *sqlx.DB, limit int) ([]PFSDigestCounters, string, error) {
+	counters := []PFSDigestCounters{}
+	query := `SELECT
+	A.DIGEST AS digest,
+	COALESCE(MAX(A.SCHEMA_NAME),'') AS schema_name,
+	MAX(A.DIGEST_TEXT) AS digest_text,
+	SUM(A.COUNT_STAR) AS exec_count,
+	SUM(A.SUM_ERRORS) AS err_count,
+	SUM(A.SUM_ROWS_EXAMINED) AS rows_examined,
+	SUM(A.SUM_ROWS_SENT) AS rows_sent,
+	SUM(A.SUM_TIMER_WAIT)/1000000000 AS latency,
+	TIMESTAMPDIFF(SECOND, MIN(A.FIRST_SEEN), NOW()) AS age
+	FROM performance_schema.events_statements_summary_by_digest A
+	WHERE A.DIGEST IS NOT NULL AND A.DIGEST_TEXT IS NOT NULL
+	GROUP BY A.DIGEST
+	ORDER BY SUM(A.SUM_TIMER_WAIT) DESC
+	LIMIT ?`
+	err := db.Select(&counters, query, limit)
+	return counters, query, err
+}
+
+// GetPFSDigestHistograms returns the latency buckets with executions of the digests,
+// performance schema has histograms since MySQL 8.0.19
+func GetPFSDigestHistograms(db *sqlx.DB) ([]PFSDigestBucket, string, error) {
+	buckets := []PFSDigestBucket{}
+	query := `SELECT
+	H.DIGEST AS digest,
+	H.BUCKET_TIMER_HIGH/1000000000 AS bucket_high,
+	SUM(H.COUNT_BUCKET) AS count
+	FROM performance_schema.events_statements_histogram_by_digest H
+	WHERE H.DIGEST IS NOT NULL AND H.COUNT_BUCKET > 0
+	GROUP BY H.DIGEST, H.BUCKET_NUMBER, H.BUCKET_TIMER_HIGH`
+	err := db.Select(&buckets, query)
+	return buckets, query, err
+}
+
+// GetIndexColumns returns the columns of the indexes of the user tables
+func GetIndexColumns(db *sqlx.DB) ([]IndexColumn, string, error) {
+	columns := []IndexColumn{}
+	query := `SELECT
+	TABLE_SCHEMA AS table_schema,
+	TABLE_NAME AS table_name,
+	INDEX_NAME AS index_name,
+	NON_UNIQUE AS non_unique,
+	COALESCE(COLUMN_NAME,'') AS column_name
+	FROM information_schema.STATISTICS
+	WHERE TABLE_SCHEMA NOT IN('information_schema','mysql','performance_schema','sys')
+	ORDER BY TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`
+	err := db.Select(&columns, query)
+	return columns, query, err
+}
+
+// GetReadOnlyVariables returns the variables that can not be set at runtime, only
+// MariaDB reports it
+func GetReadOnlyVariables(db *sqlx.DB) (map[string]bool, string, error) {
+	vars := make(map[string]bool)
+	names := []string{}
+	query := "SELECT UPPER(VARIABLE_NAME) FROM information_schema.SYSTEM_VARIABLES WHERE READ_ONLY = 'YES'"
+	err := db.Select(&names, query)
+	for _, name := range names {
+		vars[name] = true
+	}
+	return vars, query, err
+}
+
+// GetSchemaColumns returns the columns of the user tables
+func GetSchemaColumns(db *sqlx.DB) ([]SchemaColumn, string, error) {
+	columns := []SchemaColumn{}
+	query := `SELECT
+	TABLE_SCHEMA AS table_schema,
+	TABLE_NAME AS table_name,
+	COLUMN_NAME AS column_name
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA NOT IN('information_schema','mysql','performance_schema','sys')`
+	err := db.Select(&columns, query)
+	return columns, query, err
+}
+
+// GetPFSUnusedIndexes returns the indexes of the user tables without reads or
+// writes since performance schema started, as sys.schema_unused_indexes
+func GetPFSUnusedIndexes(db *sqlx.DB) ([]IndexColumn, string, error) {
+	indexes := []IndexColumn{}
+	query := `SELECT
+	OBJECT_SCHEMA AS table_schema,
+	OBJECT_NAME AS table_name,
+	INDEX_NAME AS index_name,
+	1 AS non_unique,
+	'' AS column_name
+	FROM performance_schema.table_io_waits_summary_by_index_usage
+	WHERE INDEX_NAME IS NOT NULL AND INDEX_NAME <> 'PRIMARY' AND COUNT_STAR = 0
+	AND OBJECT_SCHEMA NOT IN('information_schema','mysql','performance_schema','sys')
+	ORDER BY OBJECT_SCHEMA, OBJECT_NAME, INDEX_NAME`
+	err := db.Select(&indexes, query)
+	return indexes, query, err
+}
+
 func GetQueries(db *sqlx.DB) (map[string]PFSQuery, string, error) {
 
 	vars := make(map[string]PFSQuery)
@@ -1502,23 +1737,44 @@ func GetQueries(db *sqlx.DB) (map[string]PFSQuery, string, error) {
 	return vars, query, nil
 }
 
-func GetTableChecksumResult(db *sqlx.DB) (map[uint64]chunk, string, error) {
-	vars := make(map[uint64]chunk)
-	query := "SELECT * from replication_manager_schema.table_checksum"
-	rows, err := db.Queryx(query)
+// GetTableColumns returns the columns of a table in ordinal order
+func GetTableColumns(db *sqlx.DB, schema string, table string) ([]TableColumn, string, error) {
+	var vars []TableColumn
+	query := "SELECT COLUMN_NAME, DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION"
+	rows, err := db.Queryx(query, schema, table)
 	if err != nil {
 		return vars, query, err
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var v chunk
-		err = rows.Scan(&v.ChunkId, &v.ChunkMinKey, &v.ChunkMaxKey, v.ChunkCheckSum)
+		var v TableColumn
+		err = rows.Scan(&v.Name, &v.DataType)
 		if err != nil {
 			return vars, query, err
 		}
-		vars[v.ChunkId] = v
+		vars = append(vars, v)
 	}
-	return vars, query, nil
+	return vars, query, rows.Err()
+}
+
+// GetTablePrimaryKey returns the primary key columns of a table in index order
+func GetTablePrimaryKey(db *sqlx.DB, schema string, table string) ([]string, string, error) {
+	var vars []string
+	query := "SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE CONSTRAINT_NAME='PRIMARY' AND TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION"
+	rows, err := db.Queryx(query, schema, table)
+	if err != nil {
+		return vars, query, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v string
+		err = rows.Scan(&v)
+		if err != nil {
+			return vars, query, err
+		}
+		vars = append(vars, v)
+	}
+	return vars, query, rows.Err()
 }
 
 func GetPlugins(db *sqlx.DB, myver *MySQLVersion) (map[string]Plugin, string, error) {
@@ -1769,6 +2025,15 @@ func GetVariableByName(db *sqlx.DB, name string, myver *MySQLVersion) (string, s
 	return value, query, nil
 }
 
+// GetVariableExactValueByName returns the value of a global variable in its original case, GetVariables upper cases it
+func GetVariableExactValueByName(db *sqlx.DB, name string, myver *MySQLVersion) (string, string, error) {
+	var value string
+	source := GetVariableSource(db, myver)
+	query := "SELECT Variable_Value AS Value FROM " + source + ".global_variables WHERE Variable_Name = ?"
+	err := db.QueryRowx(query, name).Scan(&value)
+	return value, query, err
+}
+
 func FlushLogs(db *sqlx.DB) (string, error) {
 	_, err := db.Exec("FLUSH LOCAL BINARY LOGS")
 	return "FLUSH LOCAL BINARY LOGS", err
@@ -1965,6 +2230,35 @@ func StartSlave(db *sqlx.DB, Channel string, myver *MySQLVersion) (string, error
 	return cmd, err
 }
 
+// StartSlaveUntilPos starts replication until the SQL thread reaches the master binlog position,
+// the event at this position is not applied
+func StartSlaveUntilPos(db *sqlx.DB, Channel string, file string, pos string, myver *MySQLVersion) (string, error) {
+	cmd := "START SLAVE"
+	if myver.IsMariaDB() && Channel != "" {
+		cmd += " '" + Channel + "'"
+	}
+	cmd += " UNTIL MASTER_LOG_FILE='" + file + "', MASTER_LOG_POS=" + pos
+	if myver.IsMySQLOrPercona() && Channel != "" {
+		cmd += " FOR CHANNEL '" + Channel + "'"
+	}
+	_, err := db.Exec(cmd)
+	return cmd, err
+}
+
+// SetSlaveDelay changes the replication delay, replication must be stopped
+func SetSlaveDelay(db *sqlx.DB, delay string, Channel string, myver *MySQLVersion) (string, error) {
+	cmd := "CHANGE MASTER"
+	if myver.IsMariaDB() && Channel != "" {
+		cmd += " '" + Channel + "'"
+	}
+	cmd += " TO MASTER_DELAY=" + delay
+	if myver.IsMySQLOrPercona() && Channel != "" {
+		cmd += " FOR CHANNEL '" + Channel + "'"
+	}
+	_, err := db.Exec(cmd)
+	return cmd, err
+}
+
 func ResetSlave(db *sqlx.DB, all bool, Channel string, myver *MySQLVersion) (string, error) {
 	stmt := ""
 	if myver.IsPPostgreSQL() {
@@ -0,0 +1,102 @@
+// Galera cluster related functions
+
+package dbhelper
+
+import (
+	"bufio"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Grastate is the saved state of a Galera node found in grastate.dat
+type Grastate struct {
+	UUID            string `json:"uuid"`
+	Seqno           int64  `json:"seqno"`
+	SafeToBootstrap bool   `json:"safeToBootstrap"`
+}
+
+// ParseGrastate reads the content of a grastate.dat file, seqno is -1 after a crash
+func ParseGrastate(content string) (Grastate, error) {
+	gs := Grastate{Seqno: -1}
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		val := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "uuid":
+			gs.UUID = val
+			found = true
+		case "seqno":
+			seqno, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return gs, err
+			}
+			gs.Seqno = seqno
+		case "safe_to_bootstrap":
+			gs.SafeToBootstrap = val == "1"
+		}
+	}
+	if !found {
+		return gs, errors.New("No uuid found in grastate")
+	}
+	return gs, nil
+}
+
+// ParseWsrepRecoveredPosition extracts the position printed by galera_recovery or mysqld --wsrep-recover
+// ie --wsrep_start_position=4a9ab6b3-...:1234 or WSREP: Recovered position: 4a9ab6b3-...:1234
+func ParseWsrepRecoveredPosition(out string) (string, int64, error) {
+	for _, line := range strings.Split(out, "\n") {
+		pos := ""
+		if i := strings.Index(line, "--wsrep_start_position="); i >= 0 {
+			fields := strings.Fields(line[i+len("--wsrep_start_position="):])
+			if len(fields) == 0 {
+				return "", -1, errors.New("Empty recovered position")
+			}
+			pos = fields[0]
+		} else if i := strings.Index(line, "Recovered position:"); i >= 0 {
+			pos = strings.TrimSpace(line[i+len("Recovered position:"):])
+		}
+		if pos == "" {
+			continue
+		}
+		i := strings.LastIndex(pos, ":")
+		if i < 0 {
+			continue
+		}
+		seqno, err := strconv.ParseInt(pos[i+1:], 10, 64)
+		if err != nil {
+			return "", -1, err
+		}
+		return pos[:i], seqno, nil
+	}
+	return "", -1, errors.New("No recovered position found")
+}
+
+// SetWsrepBootstrapPrimary turns the non primary component of the node into a new primary component
+func SetWsrepBootstrapPrimary(db *sqlx.DB) (string, error) {
+	query := "SET GLOBAL wsrep_provider_options='pc.bootstrap=YES'"
+	_, err := db.Exec(query)
+	return query, err
+}
+
+// wsrepDonorsRegexp matches a comma separated list of wsrep_node_name
+var wsrepDonorsRegexp = regexp.MustCompile(`^[A-Za-z0-9_.:-]*(,[A-Za-z0-9_.:-]*)*$`)
+
+// SetWsrepSSTDonor pins the donors used when the node need a state transfer, a trailing comma
+// allows any other node when all donors are unavailable
+func SetWsrepSSTDonor(db *sqlx.DB, donors string) (string, error) {
+	if !wsrepDonorsRegexp.MatchString(donors) {
+		return "", errors.New("Invalid SST donor list " + donors)
+	}
+	query := "SET GLOBAL wsrep_sst_donor='" + donors + "'"
+	_, err := db.Exec(query)
+	return query, err
+}
@@ -0,0 +1,69 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package dbhelper
+
+import "testing"
+
+func TestParseGrastate(t *testing.T) {
+	gs, err := ParseGrastate(`# GALERA saved state
+version: 2.1
+uuid:    4a9ab6b3-62c9-11ea-8a6c-0a5c3a4d5e1f
+seqno:   1234
+safe_to_bootstrap: 1
+`)
+	if err != nil || gs.UUID != "4a9ab6b3-62c9-11ea-8a6c-0a5c3a4d5e1f" || gs.Seqno != 1234 || !gs.SafeToBootstrap {
+		t.Errorf("Unexpected grastate %+v %v", gs, err)
+	}
+	gs, err = ParseGrastate("uuid: 4a9ab6b3-62c9-11ea-8a6c-0a5c3a4d5e1f\nseqno: -1\nsafe_to_bootstrap: 0\n")
+	if err != nil || gs.Seqno != -1 || gs.SafeToBootstrap {
+		t.Errorf("Expected a crashed node %+v %v", gs, err)
+	}
+	if _, err = ParseGrastate("version: 2.1\nseqno: 12\n"); err == nil {
+		t.Error("Expected an error without uuid")
+	}
+	if _, err = ParseGrastate("uuid: 4a9ab6b3\nseqno: abc\n"); err == nil {
+		t.Error("Expected an error on an invalid seqno")
+	}
+}
+
+func TestParseWsrepRecoveredPosition(t *testing.T) {
+	for _, out := range []string{
+		"WSREP: Running position recovery\n--wsrep_start_position=4a9ab6b3-62c9-11ea-8a6c-0a5c3a4d5e1f:1234 \n",
+		"2020-03-10 10:00:00 0 [Note] WSREP: Recovered position: 4a9ab6b3-62c9-11ea-8a6c-0a5c3a4d5e1f:1234\n",
+	} {
+		uuid, seqno, err := ParseWsrepRecoveredPosition(out)
+		if err != nil || uuid != "4a9ab6b3-62c9-11ea-8a6c-0a5c3a4d5e1f" || seqno != 1234 {
+			t.Errorf("Unexpected position %s %d %v in %q", uuid, seqno, err, out)
+		}
+	}
+	for _, out := range []string{
+		"",
+		"--wsrep_start_position=",
+		"--wsrep_start_position=   \n",
+		"WSREP: Recovered position: 4a9ab6b3:abc",
+	} {
+		if _, _, err := ParseWsrepRecoveredPosition(out); err == nil {
+			t.Errorf("Expected an error in %q", out)
+		}
+	}
+}
+
+func TestWsrepDonors(t *testing.T) {
+	for _, donors := range []string{"", "node1,", "db-1.example.com,db_2,"} {
+		if !wsrepDonorsRegexp.MatchString(donors) {
+			t.Errorf("Expected valid donors %q", donors)
+		}
+	}
+	for _, donors := range []string{"node1'", "node1', wsrep_on='OFF", "node 1"} {
+		if wsrepDonorsRegexp.MatchString(donors) {
+			t.Errorf("Expected invalid donors %q", donors)
+		}
+	}
+}
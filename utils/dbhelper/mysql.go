@@ -3,9 +3,19 @@
 package dbhelper
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
 	"github.com/jmoiron/sqlx"
 )
 
+type GtidEvents struct {
+	Gtid   string         `json:"gtid"`
+	Events []BinlogEvents `json:"events"`
+}
+
 func HaveErrantTransactions(db *sqlx.DB, gtidMaster string, gtidSlave string) (bool, string, error) {
 
 	count := 0
@@ -21,3 +31,177 @@ func HaveErrantTransactions(db *sqlx.DB, gtidMaster string, gtidSlave string) (b
 	}
 	return false, query, nil
 }
+
+// GetBinlogEventsByGtid scan binary log files and return the events of the transactions matching the filter
+func GetBinlogEventsByGtid(db *sqlx.DB, files []string, match func(gtid string) bool, limit int) ([]GtidEvents, string, error) {
+	var res []GtidEvents
+	logs := ""
+	for _, file := range files {
+		lastpos := "4"
+		var current *GtidEvents
+		for {
+			events := []BinlogEvents{}
+			sql := "SHOW BINLOG EVENTS IN '" + file + "' FROM " + lastpos + " LIMIT 1000"
+			logs += sql + "\n"
+			err := db.Select(&events, sql)
+			if err != nil {
+				return res, logs, err
+			}
+			if len(events) == 0 {
+				break
+			}
+			for _, row := range events {
+				lastpos = strconv.FormatUint(uint64(row.End_log_pos), 10)
+				if row.Event_type == "Gtid" {
+					if current != nil {
+						res = append(res, *current)
+						current = nil
+						if limit > 0 && len(res) >= limit {
+							return res, logs, nil
+						}
+					}
+					// Info is SET @@SESSION.GTID_NEXT= 'uuid:seq'
+					gtid := row.Info
+					if i := strings.Index(gtid, "'"); i >= 0 {
+						gtid = strings.Trim(gtid[i:], "' ")
+					}
+					if match(gtid) {
+						current = &GtidEvents{Gtid: gtid}
+					}
+				}
+				if current != nil {
+					current.Events = append(current.Events, row)
+				}
+			}
+			if len(events) < 1000 {
+				break
+			}
+		}
+		if current != nil {
+			res = append(res, *current)
+			if limit > 0 && len(res) >= limit {
+				return res, logs, nil
+			}
+		}
+	}
+	return res, logs, nil
+}
+
+// InjectEmptyTrx commits an empty transaction for each GTID, all statements need to share the same session
+func InjectEmptyTrx(db *sqlx.DB, gtids []string) (string, error) {
+	logs := ""
+	conn, err := db.DB.Conn(context.Background())
+	if err != nil {
+		return logs, err
+	}
+	defer conn.Close()
+	for _, gtid := range gtids {
+		for _, query := range []string{"SET GTID_NEXT='" + gtid + "'", "BEGIN", "COMMIT"} {
+			logs += query + "\n"
+			_, err = conn.ExecContext(context.Background(), query)
+			if err != nil {
+				conn.ExecContext(context.Background(), "SET GTID_NEXT='AUTOMATIC'")
+				return logs, err
+			}
+		}
+	}
+	query := "SET GTID_NEXT='AUTOMATIC'"
+	logs += query
+	_, err = conn.ExecContext(context.Background(), query)
+	return logs, err
+}
+
+type GroupMember struct {
+	ChannelName   string `db:"CHANNEL_NAME" json:"channelName"`
+	MemberId      string `db:"MEMBER_ID" json:"memberId"`
+	MemberHost    string `db:"MEMBER_HOST" json:"memberHost"`
+	MemberPort    string `db:"MEMBER_PORT" json:"memberPort"`
+	MemberState   string `db:"MEMBER_STATE" json:"memberState"`
+	MemberRole    string `db:"MEMBER_ROLE" json:"memberRole"`
+	MemberVersion string `db:"MEMBER_VERSION" json:"memberVersion"`
+}
+
+// GetGroupReplicationMembers return the group view of the member, MEMBER_ROLE requires MySQL 8.0.2
+func GetGroupReplicationMembers(db *sqlx.DB) ([]GroupMember, string, error) {
+	members := []GroupMember{}
+	query := "SELECT CHANNEL_NAME, COALESCE(MEMBER_ID,'') AS MEMBER_ID, COALESCE(MEMBER_HOST,'') AS MEMBER_HOST, COALESCE(MEMBER_PORT,0) AS MEMBER_PORT, MEMBER_STATE, COALESCE(MEMBER_ROLE,'') AS MEMBER_ROLE, COALESCE(MEMBER_VERSION,'') AS MEMBER_VERSION FROM performance_schema.replication_group_members"
+	err := db.Select(&members, query)
+	return members, query, err
+}
+
+// SetGroupReplicationPrimary elects a new primary in a single primary group, can be run on any member
+func SetGroupReplicationPrimary(db *sqlx.DB, uuid string) (string, error) {
+	query := "SELECT group_replication_set_as_primary('" + uuid + "')"
+	_, err := db.Exec(query)
+	return query, err
+}
+
+// BinlogTrx locates the transaction of a binlog event, File and Pos are the coordinates of
+// the transaction start in the binary logs of the master
+type BinlogTrx struct {
+	Gtid  string       `json:"gtid"`
+	File  string       `json:"file"`
+	Pos   uint         `json:"pos"`
+	Event BinlogEvents `json:"event"`
+}
+
+// GetBinlogEventGtid returns the GTID of a Gtid event for MariaDB (BEGIN GTID 0-1-5) and MySQL (SET @@SESSION.GTID_NEXT= 'uuid:5')
+func GetBinlogEventGtid(info string) string {
+	if i := strings.Index(info, "'"); i >= 0 {
+		return strings.Trim(info[i:], "' ")
+	}
+	f := strings.Fields(info)
+	for i, w := range f {
+		if w == "GTID" && i+1 < len(f) {
+			return f[i+1]
+		}
+	}
+	return ""
+}
+
+// FindBinlogTrx scan binary log files from a transaction boundary position of the first file and return
+// the transaction of the first event matching the filter
+func FindBinlogTrx(db *sqlx.DB, files []string, pos string, match func(gtid string, ev BinlogEvents) bool) (BinlogTrx, string, error) {
+	var trx BinlogTrx
+	logs := ""
+	for i, file := range files {
+		lastpos := "4"
+		if i == 0 && pos != "" {
+			lastpos = pos
+		}
+		for {
+			events := []BinlogEvents{}
+			sql := "SHOW BINLOG EVENTS IN '" + file + "' FROM " + lastpos + " LIMIT 1000"
+			logs += sql + "\n"
+			err := db.Select(&events, sql)
+			if err != nil {
+				return trx, logs, err
+			}
+			for _, row := range events {
+				lastpos = strconv.FormatUint(uint64(row.End_log_pos), 10)
+				if row.Event_type == "Gtid" || row.Event_type == "Anonymous_Gtid" {
+					trx = BinlogTrx{Gtid: GetBinlogEventGtid(row.Info), File: file, Pos: row.Pos}
+				}
+				if match(trx.Gtid, row) {
+					if trx.File == "" {
+						// no GTID, the transaction start at the event
+						trx.File = file
+						trx.Pos = row.Pos
+					}
+					trx.Event = row
+					return trx, logs, nil
+				}
+			}
+			if len(events) < 1000 {
+				break
+			}
+		}
+	}
+	return trx, logs, errors.New("No binlog event found")
+}
+
+func CreateDatabase(db *sqlx.DB, name string) (string, error) {
+	query := "CREATE DATABASE IF NOT EXISTS `" + strings.Replace(name, "`", "``", -1) + "`"
+	_, err := db.Exec(query)
+	return query, err
+}
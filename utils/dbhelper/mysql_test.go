@@ -0,0 +1,29 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package dbhelper
+
+import "testing"
+
+func TestGetBinlogEventGtid(t *testing.T) {
+	for info, gtid := range map[string]string{
+		"BEGIN GTID 0-1-5":                        "0-1-5",
+		"GTID 1-2-30 trans":                       "1-2-30",
+		"GTID 0-1-7 ddl":                          "0-1-7",
+		"SET @@SESSION.GTID_NEXT= 'abc:5'":        "abc:5",
+		"GTID_NEXT= '3e11fa47-71ca-11e1-9e33:23'": "3e11fa47-71ca-11e1-9e33:23",
+		"COMMIT /* xid=74 */":                     "",
+		"GTID":                                    "",
+		"":                                        "",
+	} {
+		if g := GetBinlogEventGtid(info); g != gtid {
+			t.Errorf("Expected GTID %q in %q got %q", gtid, info, g)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// PostgreSQL streaming replication related functions
+
+package dbhelper
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/utils/misc"
+)
+
+type PGReplicationStat struct {
+	Pid             int64   `db:"pid" json:"pid"`
+	ApplicationName string  `db:"application_name" json:"applicationName"`
+	ClientAddr      string  `db:"client_addr" json:"clientAddr"`
+	State           string  `db:"state" json:"state"`
+	SentLsn         string  `db:"sent_lsn" json:"sentLsn"`
+	ReplayLsn       string  `db:"replay_lsn" json:"replayLsn"`
+	LagBytes        int64   `db:"lag_bytes" json:"lagBytes"`
+	ReplayLag       float64 `db:"replay_lag" json:"replayLag"`
+	SyncState       string  `db:"sync_state" json:"syncState"`
+}
+
+type PGReplicationSlot struct {
+	SlotName      string `db:"slot_name" json:"slotName"`
+	SlotType      string `db:"slot_type" json:"slotType"`
+	Active        bool   `db:"active" json:"active"`
+	RestartLsn    string `db:"restart_lsn" json:"restartLsn"`
+	RetainedBytes int64  `db:"retained_bytes" json:"retainedBytes"`
+}
+
+// GetPGStreamSlaveStatus mimic SHOW SLAVE STATUS on a standby, LSN are exposed as a fake
+// domain 0 GTID and as file/pos using the high and low 32 bits of the LSN
+func GetPGStreamSlaveStatus(db *sqlx.DB) ([]SlaveStatus, string, error) {
+	udb := db.Unsafe()
+	ss := []SlaveStatus{}
+	query := `SELECT
+		COALESCE(r.slot_name, '') as "Connection_name",
+		COALESCE(r.sender_host, substring(current_setting('primary_conninfo') from 'host=([^ ]*)'), '') as "Master_Host",
+		COALESCE(r.sender_port::text, substring(current_setting('primary_conninfo') from 'port=([^ ]*)'), '5432') as "Master_Port",
+		COALESCE(substring(current_setting('primary_conninfo') from 'user=([^ ]*)'), '') as "Master_User",
+		'master.' || lpad((l.rcv >> 32)::text, 6, '0') as "Master_Log_File",
+		(l.rcv & 4294967295)::text as "Read_Master_Log_Pos",
+		'master.' || lpad((l.rpl >> 32)::text, 6, '0') as "Relay_Master_Log_File",
+		CASE WHEN r.status = 'streaming' THEN 'Yes' ELSE 'No' END as "Slave_IO_Running",
+		CASE WHEN pg_is_wal_replay_paused() THEN 'No' ELSE 'Yes' END as "Slave_SQL_Running",
+		(l.rpl & 4294967295)::text as "Exec_Master_Log_Pos",
+		CASE WHEN l.rcv = l.rpl THEN 0 ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::bigint, 0) END as "Seconds_Behind_Master",
+		CASE WHEN r.pid IS NULL THEN '2003' ELSE '' END as "Last_IO_Errno",
+		CASE WHEN r.pid IS NULL THEN 'WAL receiver is not running' ELSE '' END as "Last_IO_Error",
+		'' as "Last_SQL_Errno",
+		'' as "Last_SQL_Error",
+		0 as "Master_Server_Id",
+		'Slave_Pos' as "Using_Gtid",
+		'0-0-' || l.rcv::text as "Gtid_IO_Pos",
+		'0-0-' || l.rpl::text as "Gtid_Slave_Pos",
+		1 as "Slave_Heartbeat_Period",
+		'' as "Slave_SQL_Running_State",
+		'' as external_id
+	FROM (SELECT COALESCE(pg_wal_lsn_diff(pg_last_wal_receive_lsn(), '0/0'), 0)::bigint as rcv,
+			COALESCE(pg_wal_lsn_diff(pg_last_wal_replay_lsn(), '0/0'), 0)::bigint as rpl) l
+		LEFT JOIN pg_stat_wal_receiver r ON true
+	WHERE pg_is_in_recovery()`
+	err := udb.Select(&ss, query)
+	return ss, query, err
+}
+
+// GetPGReplicationStats return the standbys streaming from the server with their lag in bytes and seconds
+func GetPGReplicationStats(db *sqlx.DB) ([]PGReplicationStat, string, error) {
+	stats := []PGReplicationStat{}
+	query := `SELECT pid,
+		COALESCE(application_name, '') as application_name,
+		COALESCE(client_hostname, host(client_addr), '') as client_addr,
+		COALESCE(state, '') as state,
+		COALESCE(sent_lsn::text, '') as sent_lsn,
+		COALESCE(replay_lsn::text, '') as replay_lsn,
+		COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn), 0)::bigint as lag_bytes,
+		COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::float8 as replay_lag,
+		COALESCE(sync_state, '') as sync_state
+	FROM pg_stat_replication
+	ORDER BY pid ASC`
+	err := db.Select(&stats, query)
+	return stats, query, err
+}
+
+// GetPGWalPosition return the LSN as a number, the current LSN on a primary or the replayed one on a standby
+func GetPGWalPosition(db *sqlx.DB) (uint64, string, error) {
+	var pos uint64
+	query := "SELECT COALESCE(pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END, '0/0'), 0)::bigint"
+	err := db.QueryRowx(query).Scan(&pos)
+	return pos, query, err
+}
+
+func IsPGInRecovery(db *sqlx.DB) (bool, string, error) {
+	var rec bool
+	query := "SELECT pg_is_in_recovery()"
+	err := db.QueryRowx(query).Scan(&rec)
+	return rec, query, err
+}
+
+// PGPromote promote a standby and wait for the end of recovery up to wait seconds
+func PGPromote(db *sqlx.DB, wait int) (string, error) {
+	var ok bool
+	query := "SELECT pg_promote(true, " + strconv.Itoa(wait) + ")"
+	err := db.QueryRowx(query).Scan(&ok)
+	if err != nil {
+		return query, err
+	}
+	if !ok {
+		return query, errors.New("Promotion not completed after " + strconv.Itoa(wait) + "s")
+	}
+	return query, nil
+}
+
+func pgQuoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func pgQuoteConnValue(s string) string {
+	return "'" + strings.Replace(strings.Replace(s, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
+
+// SetPGPrimaryConnInfo point a standby to a new primary, primary_conninfo is reloadable since PostgreSQL 13
+func SetPGPrimaryConnInfo(db *sqlx.DB, opt ChangeMasterOpt, appName string) (string, error) {
+	sslmode := "prefer"
+	if opt.SSL {
+		sslmode = "require"
+	}
+	conninfo := "host=" + misc.Unbracket(opt.Host) + " port=" + opt.Port + " user=" + opt.User + " password=" + pgQuoteConnValue(opt.Password) + " application_name=" + appName + " sslmode=" + sslmode
+	stmts := []string{
+		"ALTER SYSTEM SET primary_conninfo = " + pgQuoteLiteral(conninfo),
+		"ALTER SYSTEM SET primary_slot_name = " + pgQuoteLiteral(opt.Channel),
+		"SELECT pg_reload_conf()",
+	}
+	logs := ""
+	for _, stmt := range stmts {
+		logs += strings.Replace(stmt, opt.Password, "XXXX", -1) + "\n"
+		_, err := db.Exec(stmt)
+		if err != nil {
+			return logs, err
+		}
+	}
+	return logs, nil
+}
+
+// SetPGReadOnly change the default transaction mode, existing sessions need to be killed to be enforced
+func SetPGReadOnly(db *sqlx.DB, flag bool) (string, error) {
+	mode := "off"
+	if flag {
+		mode = "on"
+	}
+	logs := ""
+	for _, stmt := range []string{"ALTER SYSTEM SET default_transaction_read_only = " + mode, "SELECT pg_reload_conf()"} {
+		logs += stmt + "\n"
+		_, err := db.Exec(stmt)
+		if err != nil {
+			return logs, err
+		}
+	}
+	return logs, nil
+}
+
+func PGPauseReplay(db *sqlx.DB) (string, error) {
+	query := "SELECT pg_wal_replay_pause()"
+	_, err := db.Exec(query)
+	return query, err
+}
+
+func PGResumeReplay(db *sqlx.DB) (string, error) {
+	query := "SELECT pg_wal_replay_resume()"
+	_, err := db.Exec(query)
+	return query, err
+}
+
+func GetPGReplicationSlots(db *sqlx.DB) ([]PGReplicationSlot, string, error) {
+	slots := []PGReplicationSlot{}
+	query := `SELECT slot_name, slot_type, active,
+		COALESCE(restart_lsn::text, '') as restart_lsn,
+		CASE WHEN pg_is_in_recovery() OR restart_lsn IS NULL THEN 0 ELSE pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn)::bigint END as retained_bytes
+	FROM pg_replication_slots
+	ORDER BY slot_name`
+	err := db.Select(&slots, query)
+	return slots, query, err
+}
+
+// CreatePGPhysicalSlot create a physical slot if it does not exist, the WAL are reserved immediately
+func CreatePGPhysicalSlot(db *sqlx.DB, name string) (string, error) {
+	query := "SELECT pg_create_physical_replication_slot(" + pgQuoteLiteral(name) + ", true) WHERE NOT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = " + pgQuoteLiteral(name) + ")"
+	_, err := db.Exec(query)
+	return query, err
+}
+
+func DropPGSlot(db *sqlx.DB, name string) (string, error) {
+	query := "SELECT pg_drop_replication_slot(" + pgQuoteLiteral(name) + ")"
+	_, err := db.Exec(query)
+	return query, err
+}
@@ -0,0 +1,231 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package digesthistory
+
+import (
+	"sort"
+	"time"
+)
+
+// Summary is the activity of a digest over a window, latencies in ms
+type Summary struct {
+	Digest       string  `json:"digest"`
+	Schema       string  `json:"schema"`
+	Text         string  `json:"digestText"`
+	Count        int64   `json:"count"`
+	Errors       int64   `json:"errors"`
+	RowsExamined int64   `json:"rowsExamined"`
+	RowsSent     int64   `json:"rowsSent"`
+	Latency      float64 `json:"latency"`
+	Avg          float64 `json:"avg"`
+	P95          float64 `json:"p95"`
+	p95s         []weighted
+}
+
+type weighted struct {
+	value  float64
+	weight int64
+}
+
+func (s *Summary) add(p Point) {
+	s.Count += p.Count
+	s.Errors += p.Errors
+	s.RowsExamined += p.RowsExamined
+	s.RowsSent += p.RowsSent
+	s.Latency += p.Latency
+	s.p95s = append(s.p95s, weighted{value: p.P95, weight: p.Count})
+}
+
+// finish computes the window 95th percentile as the one of the interval
+// percentiles weighted by their executions
+func (s *Summary) finish() {
+	if s.Count == 0 {
+		return
+	}
+	s.Avg = s.Latency / float64(s.Count)
+	sort.Slice(s.p95s, func(i, j int) bool { return s.p95s[i].value < s.p95s[j].value })
+	var cumul int64
+	for _, w := range s.p95s {
+		cumul += w.weight
+		s.P95 = w.value
+		if cumul*100 >= s.Count*95 {
+			break
+		}
+	}
+	s.p95s = nil
+}
+
+func (st *Store) summarize(from time.Time, to time.Time) (map[string]*Summary, error) {
+	samples, err := st.Samples(from, to)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(map[string]*Summary)
+	for _, sample := range samples {
+		for _, p := range sample.Points {
+			s, ok := summaries[p.Digest]
+			if !ok {
+				text := st.Text(p.Digest)
+				s = &Summary{Digest: p.Digest, Schema: text.Schema, Text: text.Text}
+				summaries[p.Digest] = s
+			}
+			s.add(p)
+		}
+	}
+	for _, s := range summaries {
+		s.finish()
+	}
+	return summaries, nil
+}
+
+func orderValue(s *Summary, order string) float64 {
+	switch order {
+	case ConstOrderRowsExamined:
+		return float64(s.RowsExamined)
+	case ConstOrderCount:
+		return float64(s.Count)
+	case ConstOrderErrors:
+		return float64(s.Errors)
+	}
+	return s.Latency
+}
+
+// Top returns the limit digests with the highest total latency, rows examined,
+// executions or errors in [from, to)
+func (st *Store) Top(from time.Time, to time.Time, order string, limit int) ([]Summary, error) {
+	summaries, err := st.summarize(from, to)
+	if err != nil {
+		return nil, err
+	}
+	top := make([]*Summary, 0, len(summaries))
+	for _, s := range summaries {
+		top = append(top, s)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		a, b := orderValue(top[i], order), orderValue(top[j], order)
+		if a == b {
+			return top[i].Digest < top[j].Digest
+		}
+		return a > b
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	res := make([]Summary, len(top))
+	for i, s := range top {
+		res[i] = *s
+	}
+	return res, nil
+}
+
+// Regression is a digest with a 95th percentile latency higher than in the baseline
+type Regression struct {
+	Digest        string  `json:"digest"`
+	Schema        string  `json:"schema"`
+	Text          string  `json:"digestText"`
+	BaselineCount int64   `json:"baselineCount"`
+	BaselineAvg   float64 `json:"baselineAvg"`
+	BaselineP95   float64 `json:"baselineP95"`
+	Count         int64   `json:"count"`
+	Avg           float64 `json:"avg"`
+	P95           float64 `json:"p95"`
+	// P95 over BaselineP95
+	Ratio float64 `json:"ratio"`
+}
+
+// Regressions compares the digests run in [since, to) with the same digests in
+// [baseline, since). A digest is reported when its 95th percentile is ratio times
+// the baseline one, with at least minCount executions in both windows so that a
+// few slow queries do not make a regression. The worst regressions come first.
+func (st *Store) Regressions(baseline time.Time, since time.Time, to time.Time, ratio float64, minCount int64) ([]Regression, error) {
+	before, err := st.summarize(baseline, since)
+	if err != nil {
+		return nil, err
+	}
+	after, err := st.summarize(since, to)
+	if err != nil {
+		return nil, err
+	}
+	res := []Regression{}
+	for digest, a := range after {
+		b, ok := before[digest]
+		if !ok || a.Count < minCount || b.Count < minCount || b.P95 <= 0 {
+			continue
+		}
+		r := a.P95 / b.P95
+		if r < ratio {
+			continue
+		}
+		res = append(res, Regression{
+			Digest:        digest,
+			Schema:        a.Schema,
+			Text:          a.Text,
+			BaselineCount: b.Count,
+			BaselineAvg:   b.Avg,
+			BaselineP95:   b.P95,
+			Count:         a.Count,
+			Avg:           a.Avg,
+			P95:           a.P95,
+			Ratio:         r,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Ratio > res[j].Ratio })
+	return res, nil
+}
+
+// SparkPoint is the activity of a digest during a step of a sparkline
+type SparkPoint struct {
+	Time         time.Time `json:"time"`
+	Count        int64     `json:"count"`
+	RowsExamined int64     `json:"rowsExamined"`
+	Avg          float64   `json:"avg"`
+	P95          float64   `json:"p95"`
+}
+
+// Sparkline splits [from, to) in steps and returns the activity of the digest in
+// each of them, steps without executions included
+func (st *Store) Sparkline(digest string, from time.Time, to time.Time, steps int) ([]SparkPoint, error) {
+	if steps <= 0 || !to.After(from) {
+		return []SparkPoint{}, nil
+	}
+	samples, err := st.Samples(from, to)
+	if err != nil {
+		return nil, err
+	}
+	step := to.Sub(from) / time.Duration(steps)
+	if step <= 0 {
+		step = 1
+	}
+	summaries := make([]Summary, steps)
+	for _, sample := range samples {
+		i := int(sample.Time.Sub(from) / step)
+		if i >= steps {
+			i = steps - 1
+		}
+		for _, p := range sample.Points {
+			if p.Digest == digest {
+				summaries[i].add(p)
+			}
+		}
+	}
+	res := make([]SparkPoint, steps)
+	for i := range summaries {
+		s := &summaries[i]
+		s.finish()
+		res[i] = SparkPoint{
+			Time:         from.Add(time.Duration(i) * step),
+			Count:        s.Count,
+			RowsExamined: s.RowsExamined,
+			Avg:          s.Avg,
+			P95:          s.P95,
+		}
+	}
+	return res, nil
+}
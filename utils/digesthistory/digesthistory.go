@@ -0,0 +1,339 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package digesthistory keeps the activity of the query digests of a server over
+// time. The cumulated counters of performance schema are sampled periodically and
+// the deltas between samples are stored, so the history survives a reset of the
+// digests on the server.
+//
+// The store is a directory with a file per day, each sample appended as a gzip
+// member holding a JSON line, and a file with the text of the digests.
+package digesthistory
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConstOrderLatency      = "time"
+	ConstOrderRowsExamined = "rows"
+	ConstOrderCount        = "count"
+	ConstOrderErrors       = "errors"
+)
+
+const (
+	dayLayout  = "20060102"
+	fileSuffix = ".json.gz"
+	textsFile  = "digests.json"
+)
+
+// Counters of a digest cumulated by the server since the digest was first seen
+type Counters struct {
+	Digest       string
+	Schema       string
+	Text         string
+	Count        int64
+	Errors       int64
+	RowsExamined int64
+	RowsSent     int64
+	// total latency in ms
+	Latency float64
+	// seconds since the digest was first seen
+	Age int64
+	// executions by latency bucket upper bound in ms, empty without histograms
+	Histogram map[float64]int64
+}
+
+// Point is the activity of a digest during a sample interval, short keys keep the
+// store compact
+type Point struct {
+	Digest       string  `json:"d"`
+	Count        int64   `json:"c"`
+	Errors       int64   `json:"e,omitempty"`
+	RowsExamined int64   `json:"r,omitempty"`
+	RowsSent     int64   `json:"s,omitempty"`
+	Latency      float64 `json:"t"`
+	// 95th percentile latency in ms from the histograms, the average without them
+	P95 float64 `json:"p"`
+}
+
+type Sample struct {
+	Time time.Time `json:"time"`
+	// seconds since the previous sample
+	Interval float64 `json:"interval"`
+	Points   []Point `json:"points"`
+}
+
+// Sampler computes the deltas of the counters between two calls
+type Sampler struct {
+	last     map[string]Counters
+	lastTime time.Time
+}
+
+func NewSampler() *Sampler {
+	return &Sampler{}
+}
+
+// Sample returns the activity since the previous call, nil on the first call
+// which is the baseline. A digest with counters lower than at the previous call
+// was reset and all its counters are new. A digest not seen before is only
+// counted when first seen during the interval, else it was just not in the top
+// of the previous call.
+func (s *Sampler) Sample(now time.Time, counters []Counters) *Sample {
+	last := s.last
+	lastTime := s.lastTime
+	s.last = make(map[string]Counters, len(counters))
+	s.lastTime = now
+	for _, c := range counters {
+		s.last[c.Digest] = c
+	}
+	if last == nil {
+		return nil
+	}
+	interval := now.Sub(lastTime).Seconds()
+	sample := &Sample{Time: now, Interval: interval, Points: []Point{}}
+	for _, c := range counters {
+		prev, ok := last[c.Digest]
+		if !ok {
+			if float64(c.Age) > interval {
+				continue
+			}
+			prev = Counters{}
+		} else if c.Count < prev.Count {
+			prev = Counters{}
+		}
+		if c.Count == prev.Count {
+			continue
+		}
+		p := Point{
+			Digest:       c.Digest,
+			Count:        c.Count - prev.Count,
+			Errors:       c.Errors - prev.Errors,
+			RowsExamined: c.RowsExamined - prev.RowsExamined,
+			RowsSent:     c.RowsSent - prev.RowsSent,
+			Latency:      c.Latency - prev.Latency,
+		}
+		p.P95 = histogramP95(c.Histogram, prev.Histogram)
+		if p.P95 == 0 {
+			p.P95 = p.Latency / float64(p.Count)
+		}
+		sample.Points = append(sample.Points, p)
+	}
+	return sample
+}
+
+// histogramP95 is the upper bound of the bucket holding the 95th percentile of the
+// executions between the two histograms
+func histogramP95(current map[float64]int64, prev map[float64]int64) float64 {
+	var bounds []float64
+	var total int64
+	for b, n := range current {
+		if n > prev[b] {
+			bounds = append(bounds, b)
+			total += n - prev[b]
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Float64s(bounds)
+	var cumul int64
+	for _, b := range bounds {
+		cumul += current[b] - prev[b]
+		if cumul*100 >= total*95 {
+			return b
+		}
+	}
+	return bounds[len(bounds)-1]
+}
+
+type Text struct {
+	Schema string `json:"schema"`
+	Text   string `json:"text"`
+}
+
+type Store struct {
+	sync.Mutex
+	dir   string
+	keep  int
+	texts map[string]Text
+	day   string
+}
+
+// Open creates the store in dir, keep is the number of days of samples kept
+func Open(dir string, keep int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	st := &Store{dir: dir, keep: keep, texts: make(map[string]Text)}
+	data, err := os.ReadFile(filepath.Join(dir, textsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &st.texts); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// Append writes the sample with the text of its new digests taken from counters,
+// the samples older than the kept days are purged when a day starts
+func (st *Store) Append(sample *Sample, counters []Counters) error {
+	st.Lock()
+	defer st.Unlock()
+	changed := false
+	for _, c := range counters {
+		if _, ok := st.texts[c.Digest]; !ok && c.Text != "" {
+			st.texts[c.Digest] = Text{Schema: c.Schema, Text: c.Text}
+			changed = true
+		}
+	}
+	if changed {
+		data, err := json.Marshal(st.texts)
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(st.dir, textsFile+".tmp")
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(st.dir, textsFile)); err != nil {
+			return err
+		}
+	}
+	day := sample.Time.UTC().Format(dayLayout)
+	f, err := os.OpenFile(filepath.Join(st.dir, day+fileSuffix), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(sample); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if day != st.day {
+		st.day = day
+		return st.purge(sample.Time)
+	}
+	return nil
+}
+
+func (st *Store) purge(now time.Time) error {
+	if st.keep <= 0 {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(st.dir, "*"+fileSuffix))
+	if err != nil {
+		return err
+	}
+	oldest := now.UTC().AddDate(0, 0, -st.keep).Format(dayLayout)
+	for _, file := range files {
+		if strings.TrimSuffix(filepath.Base(file), fileSuffix) < oldest {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Text returns the text of a digest as first sampled
+func (st *Store) Text(digest string) Text {
+	st.Lock()
+	defer st.Unlock()
+	return st.texts[digest]
+}
+
+// Samples returns the samples taken in [from, to) in time order
+func (st *Store) Samples(from time.Time, to time.Time) ([]*Sample, error) {
+	st.Lock()
+	defer st.Unlock()
+	var samples []*Sample
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		f, err := os.Open(filepath.Join(st.dir, day.Format(dayLayout)+fileSuffix))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = readSamples(f, func(s *Sample) {
+			if !s.Time.Before(from) && s.Time.Before(to) {
+				samples = append(samples, s)
+			}
+		})
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return samples, nil
+}
+
+// readSamples stops without error on a sample cut by a crash while appending
+func readSamples(r io.Reader, fn func(*Sample)) error {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	dec := json.NewDecoder(gz)
+	for {
+		s := new(Sample)
+		if err := dec.Decode(s); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		fn(s)
+	}
+}
+
+// ParseTime reads a time of the API as RFC3339, as unix seconds or as a duration
+// before now like 24h, def is returned for an empty string
+func ParseTime(s string, now time.Time, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return def, fmt.Errorf("Invalid time %s", s)
+	}
+	return time.Unix(sec, 0), nil
+}
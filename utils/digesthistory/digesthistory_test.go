@@ -0,0 +1,151 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package digesthistory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	s := NewSampler()
+	now := time.Unix(1700000000, 0)
+	if s.Sample(now, []Counters{{Digest: "a", Count: 10, Latency: 100}}) != nil {
+		t.Fatal("Expected no sample on the baseline")
+	}
+	sample := s.Sample(now.Add(time.Minute), []Counters{
+		{Digest: "a", Count: 15, Latency: 200, RowsExamined: 50, Histogram: map[float64]int64{1: 3, 10: 10}},
+		// first seen during the interval
+		{Digest: "b", Count: 2, Latency: 4, Age: 30},
+		// not in the top at the previous sample
+		{Digest: "c", Count: 100, Latency: 100, Age: 3600},
+	})
+	if sample.Interval != 60 || len(sample.Points) != 2 {
+		t.Fatalf("Unexpected sample %+v", sample)
+	}
+	if p := sample.Points[0]; p.Digest != "a" || p.Count != 5 || p.Latency != 100 || p.RowsExamined != 50 || p.P95 != 10 {
+		t.Errorf("Unexpected point %+v", p)
+	}
+	if p := sample.Points[1]; p.Digest != "b" || p.Count != 2 || p.P95 != 2 {
+		t.Errorf("Unexpected point %+v", p)
+	}
+	// digests reset on the server
+	sample = s.Sample(now.Add(2*time.Minute), []Counters{{Digest: "a", Count: 3, Latency: 30}, {Digest: "c", Count: 100, Latency: 100}})
+	if len(sample.Points) != 1 || sample.Points[0].Count != 3 || sample.Points[0].P95 != 10 {
+		t.Errorf("Unexpected sample after reset %+v", sample.Points)
+	}
+}
+
+func TestHistogramP95(t *testing.T) {
+	prev := map[float64]int64{1: 100, 10: 5}
+	current := map[float64]int64{1: 190, 10: 10, 100: 5}
+	// 90 fast, 5 medium and 5 slow executions
+	if p := histogramP95(current, prev); p != 10 {
+		t.Errorf("Expected 10 got %f", p)
+	}
+	if p := histogramP95(prev, prev); p != 0 {
+		t.Errorf("Expected 0 without executions got %f", p)
+	}
+}
+
+func fill(t *testing.T, st *Store, start time.Time, hours int, latency func(h int) float64) {
+	for h := 0; h < hours; h++ {
+		for m := 0; m < 60; m += 10 {
+			l := latency(h)
+			sample := &Sample{Time: start.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute), Interval: 600, Points: []Point{
+				{Digest: "slow", Count: 10, Latency: 10 * l, P95: l, RowsExamined: 10},
+				{Digest: "scan", Count: 1, Latency: 1, P95: 1, RowsExamined: 1000},
+			}}
+			if err := st.Append(sample, []Counters{{Digest: "slow", Schema: "shop", Text: "SELECT * FROM `orders` WHERE `id` = ?"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	st, err := Open(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 11, 14, 20, 0, 0, 0, time.UTC)
+	fill(t, st, start, 48, func(h int) float64 {
+		if h >= 36 {
+			return 20
+		}
+		return 5
+	})
+	if _, err := os.Stat(filepath.Join(dir, "20231114"+fileSuffix)); err != nil {
+		t.Errorf("Expected the samples of the first day kept %s", err)
+	}
+
+	st, err = Open(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := start.Add(48 * time.Hour)
+	top, err := st.Top(start, end, ConstOrderLatency, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || top[0].Digest != "slow" || top[0].Schema != "shop" || top[0].Count != 48*6*10 || top[0].P95 != 20 {
+		t.Errorf("Unexpected top %+v", top)
+	}
+	top, _ = st.Top(start, start.Add(time.Hour), ConstOrderRowsExamined, 0)
+	if len(top) != 2 || top[0].Digest != "scan" || top[0].RowsExamined != 6000 || top[1].Avg != 5 {
+		t.Errorf("Unexpected top by rows %+v", top)
+	}
+
+	since := start.Add(36 * time.Hour)
+	regressions, err := st.Regressions(since.Add(-24*time.Hour), since, end, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regressions) != 1 || regressions[0].Digest != "slow" || regressions[0].Ratio != 4 || regressions[0].BaselineP95 != 5 {
+		t.Errorf("Unexpected regressions %+v", regressions)
+	}
+	if regressions, _ = st.Regressions(start, start.Add(12*time.Hour), since, 2, 10); len(regressions) != 0 {
+		t.Errorf("Expected no regression before the change %+v", regressions)
+	}
+
+	spark, err := st.Sparkline("slow", since.Add(-2*time.Hour), since.Add(2*time.Hour), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spark) != 4 || spark[0].Count != 60 || spark[1].P95 != 5 || spark[2].P95 != 20 || !spark[3].Time.Equal(since.Add(time.Hour)) {
+		t.Errorf("Unexpected sparkline %+v", spark)
+	}
+
+	// a new day purges the samples older than kept
+	st.Append(&Sample{Time: end.Add(24 * time.Hour)}, nil)
+	if _, err := os.Stat(filepath.Join(dir, "20231114"+fileSuffix)); !os.IsNotExist(err) {
+		t.Errorf("Expected the samples of the first day purged")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for s, expected := range map[string]time.Time{
+		"":                     now,
+		"24h":                  now.Add(-24 * time.Hour),
+		"1699990000":           time.Unix(1699990000, 0),
+		"2023-11-14T22:13:20Z": now,
+	} {
+		if got, err := ParseTime(s, now, now); err != nil || !got.Equal(expected) {
+			t.Errorf("ParseTime(%q) expected %s got %s %v", s, expected, got, err)
+		}
+	}
+	if _, err := ParseTime("yesterday", now, now); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
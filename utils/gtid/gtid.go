@@ -123,20 +123,22 @@ func NewGtid(s string) *Gtid {
 	return g
 }
 
+// NewMySQLGtid returns a Gtid from a single uuid set, the server is the crc64 of
+// the uuid and the sequence the last transaction number of the set
 func NewMySQLGtid(s string) *Gtid {
 	g := new(Gtid)
-	f := strings.Split(s, ":")
+	f := strings.Split(strings.TrimSpace(s), ":")
 	crcTable := crc64.MakeTable(crc64.ECMA)
-	seq := "1"
-	if strings.Contains(f[1], "-") {
-		e := strings.Split(f[1], "-")
-		seq = e[1]
-	} else {
-		seq = f[1]
-	}
 	g.DomainID = 0
 	g.ServerID = crc64.Checksum([]byte(f[0]), crcTable)
-	g.SeqNo, _ = strconv.ParseUint(seq, 10, 64)
+	set, err := NewMySQLSet(s)
+	if err == nil {
+		for _, source := range set.Sources() {
+			if seq := set.GetLastSeqNo(source); seq > g.SeqNo {
+				g.SeqNo = seq
+			}
+		}
+	}
 	return g
 }
 
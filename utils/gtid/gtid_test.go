@@ -9,7 +9,10 @@
 
 package gtid
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestGtid(t *testing.T) {
 	gtid := "0-1-100,1-2-101"
@@ -44,3 +47,83 @@ func TestEqualGtid(t *testing.T) {
 	re := list1.Equal(list2)
 	t.Log("Comparison returned ", re)
 }
+
+func TestMySQLSetParse(t *testing.T) {
+	set, err := NewMySQLSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:11-18:1-5:6,\n2174B383-5441-11E8-B90A-C80AA9429562:1-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "2174b383-5441-11e8-b90a-c80aa9429562:1-3,3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6:11-18"
+	if set.String() != expected {
+		t.Errorf("Expected %s got %s", expected, set.String())
+	}
+	if set.Count() != 17 {
+		t.Errorf("Expected 17 transactions got %d", set.Count())
+	}
+	if _, err := NewMySQLSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:5-2"); err == nil {
+		t.Error("Expected error on reversed interval")
+	}
+	empty, _ := NewMySQLSet("")
+	if !empty.IsEmpty() {
+		t.Error("Expected empty set")
+	}
+}
+
+func TestMySQLSetArithmetic(t *testing.T) {
+	master, _ := NewMySQLSet("aaaa:1-100,bbbb:1-10")
+	slave, _ := NewMySQLSet("aaaa:1-90,bbbb:1-12,cccc:1")
+	errant := slave.Subtract(master)
+	if errant.String() != "bbbb:11-12,cccc:1" {
+		t.Errorf("Expected errant bbbb:11-12,cccc:1 got %s", errant.String())
+	}
+	if slave.IsSubsetOf(master) {
+		t.Error("Slave should not be a subset of master")
+	}
+	behind, _ := NewMySQLSet("aaaa:1-50")
+	if !behind.IsSubsetOf(master) {
+		t.Error("Lagging slave should be a subset of master")
+	}
+	union := master.Union(slave)
+	if union.String() != "aaaa:1-100,bbbb:1-12,cccc:1" {
+		t.Errorf("Unexpected union %s", union.String())
+	}
+	if !union.Contains("BBBB", 12) || union.Contains("bbbb", 13) {
+		t.Error("Contains failed on union")
+	}
+	if !union.ContainsGtid("cccc:1") {
+		t.Error("ContainsGtid failed on union")
+	}
+	hole, _ := NewMySQLSet("aaaa:1-100")
+	cut, _ := NewMySQLSet("aaaa:10-20:50")
+	if hole.Subtract(cut).String() != "aaaa:1-9:21-49:51-100" {
+		t.Errorf("Unexpected subtract %s", hole.Subtract(cut).String())
+	}
+	if gtids := errant.GetGtids(0); len(gtids) != 3 || gtids[0] != "bbbb:11" || gtids[2] != "cccc:1" {
+		t.Errorf("Unexpected expanded gtids %v", gtids)
+	}
+}
+
+func TestMySQLSetTagged(t *testing.T) {
+	set, err := NewMySQLSet("aaaa:1-3:mytag:5-6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.String() != "aaaa:1-3,aaaa:mytag:5-6" {
+		t.Errorf("Unexpected tagged set %s", set.String())
+	}
+	if !set.ContainsGtid("aaaa:mytag:6") {
+		t.Error("ContainsGtid failed on tagged set")
+	}
+	for _, s := range []string{"aaaa:1-3:my-tag:5", "aaaa:1-3:abc!:5", "aaaa:1x", "aaaa:1-3:mytag", "aaaa:tag1:tag2:5", "aaaa:1-3:" + strings.Repeat("t", 33) + ":5"} {
+		if _, err := NewMySQLSet(s); err == nil {
+			t.Errorf("Expected an error parsing malformed set %s", s)
+		}
+	}
+}
+
+func TestMySQLGtid(t *testing.T) {
+	g := NewMySQLGtid("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-18")
+	if g.SeqNo != 18 {
+		t.Errorf("Expected sequence 18 got %d", g.SeqNo)
+	}
+}
@@ -0,0 +1,293 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package gtid
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Interval defines a closed range of transaction numbers of a MySQL GTID set
+type Interval struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+// MySQLSet defines a MySQL GTID set, intervals are indexed by source uuid
+// (or uuid:tag for MySQL 8.3 tagged GTIDs) and kept sorted and merged
+type MySQLSet map[string][]Interval
+
+// NewMySQLSet returns a MySQL GTID set from a gtid_executed like string
+// ie 3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-18,aaa-bbb:1
+func NewMySQLSet(s string) (MySQLSet, error) {
+	set := make(MySQLSet)
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return set, nil
+	}
+	for _, uuidSet := range strings.Split(s, ",") {
+		if uuidSet == "" {
+			continue
+		}
+		f := strings.Split(uuidSet, ":")
+		if len(f) < 2 || f[0] == "" {
+			return nil, fmt.Errorf("Malformed GTID set %s", uuidSet)
+		}
+		source := strings.ToLower(f[0])
+		tagged := false
+		for _, elem := range f[1:] {
+			iv, err := parseInterval(elem)
+			if err != nil {
+				// not a range this is a tag for the next intervals
+				if tagged || !isMySQLTag(elem) {
+					return nil, fmt.Errorf("Malformed GTID interval %s in %s", elem, uuidSet)
+				}
+				source = strings.ToLower(f[0]) + ":" + strings.ToLower(elem)
+				tagged = true
+				continue
+			}
+			set[source] = append(set[source], iv)
+			tagged = false
+		}
+		if tagged {
+			return nil, fmt.Errorf("Malformed GTID set %s, tag without interval", uuidSet)
+		}
+	}
+	for source := range set {
+		set[source] = normalizeIntervals(set[source])
+	}
+	return set, nil
+}
+
+// isMySQLTag returns true when s is a valid GTID tag, up to 32 letters, digits or underscores not starting with a digit
+func isMySQLTag(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for i, r := range strings.ToLower(s) {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z'):
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func parseInterval(s string) (Interval, error) {
+	var iv Interval
+	var err error
+	e := strings.Split(s, "-")
+	if len(e) > 2 {
+		return iv, errors.New("Malformed interval")
+	}
+	iv.Start, err = strconv.ParseUint(e[0], 10, 64)
+	if err != nil {
+		return iv, err
+	}
+	iv.End = iv.Start
+	if len(e) == 2 {
+		iv.End, err = strconv.ParseUint(e[1], 10, 64)
+		if err != nil {
+			return iv, err
+		}
+	}
+	if iv.Start == 0 || iv.End < iv.Start {
+		return iv, errors.New("Malformed interval")
+	}
+	return iv, nil
+}
+
+// normalizeIntervals sorts and merges overlapping or adjacent intervals
+func normalizeIntervals(ivs []Interval) []Interval {
+	if len(ivs) == 0 {
+		return ivs
+	}
+	sort.Slice(ivs, func(i, j int) bool {
+		return ivs[i].Start < ivs[j].Start
+	})
+	merged := []Interval{ivs[0]}
+	for _, iv := range ivs[1:] {
+		last := &merged[len(merged)-1]
+		if iv.Start <= last.End+1 {
+			if iv.End > last.End {
+				last.End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Sources returns the sorted list of uuids of the set
+func (set MySQLSet) Sources() []string {
+	var sources []string
+	for source := range set {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// String returns the set in the canonical MySQL notation
+func (set MySQLSet) String() string {
+	var sl []string
+	for _, source := range set.Sources() {
+		ivs := set[source]
+		if len(ivs) == 0 {
+			continue
+		}
+		s := source
+		for _, iv := range ivs {
+			if iv.Start == iv.End {
+				s += fmt.Sprintf(":%d", iv.Start)
+			} else {
+				s += fmt.Sprintf(":%d-%d", iv.Start, iv.End)
+			}
+		}
+		sl = append(sl, s)
+	}
+	return strings.Join(sl, ",")
+}
+
+// IsEmpty returns true when the set does not contain any transaction
+func (set MySQLSet) IsEmpty() bool {
+	for _, ivs := range set {
+		if len(ivs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns the number of transactions in the set
+func (set MySQLSet) Count() uint64 {
+	var ct uint64
+	for _, ivs := range set {
+		for _, iv := range ivs {
+			ct += iv.End - iv.Start + 1
+		}
+	}
+	return ct
+}
+
+// Clone returns a deep copy of the set
+func (set MySQLSet) Clone() MySQLSet {
+	c := make(MySQLSet)
+	for source, ivs := range set {
+		c[source] = append([]Interval(nil), ivs...)
+	}
+	return c
+}
+
+// Union returns a new set with transactions present in either sets
+func (set MySQLSet) Union(other MySQLSet) MySQLSet {
+	u := set.Clone()
+	for source, ivs := range other {
+		u[source] = normalizeIntervals(append(u[source], ivs...))
+	}
+	return u
+}
+
+// Subtract returns a new set with transactions of set not present in other
+func (set MySQLSet) Subtract(other MySQLSet) MySQLSet {
+	d := make(MySQLSet)
+	for source, ivs := range set {
+		remain := append([]Interval(nil), ivs...)
+		for _, o := range other[source] {
+			var next []Interval
+			for _, iv := range remain {
+				if o.End < iv.Start || o.Start > iv.End {
+					next = append(next, iv)
+					continue
+				}
+				if o.Start > iv.Start {
+					next = append(next, Interval{Start: iv.Start, End: o.Start - 1})
+				}
+				if o.End < iv.End {
+					next = append(next, Interval{Start: o.End + 1, End: iv.End})
+				}
+			}
+			remain = next
+		}
+		if len(remain) > 0 {
+			d[source] = remain
+		}
+	}
+	return d
+}
+
+// Contains returns true if the transaction uuid:seq is part of the set
+func (set MySQLSet) Contains(source string, seq uint64) bool {
+	for _, iv := range set[strings.ToLower(source)] {
+		if seq >= iv.Start && seq <= iv.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsGtid returns true if a single uuid:seq GTID is part of the set
+func (set MySQLSet) ContainsGtid(g string) bool {
+	i := strings.LastIndex(g, ":")
+	if i < 0 {
+		return false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(g[i+1:]), 10, 64)
+	if err != nil {
+		return false
+	}
+	return set.Contains(strings.TrimSpace(g[:i]), seq)
+}
+
+// IsSubsetOf returns true if every transaction of the set is part of other
+func (set MySQLSet) IsSubsetOf(other MySQLSet) bool {
+	return set.Subtract(other).IsEmpty()
+}
+
+// Equal returns true if both sets contain the same transactions
+func (set MySQLSet) Equal(other MySQLSet) bool {
+	return set.IsSubsetOf(other) && other.IsSubsetOf(set)
+}
+
+// GetGtids expands the set into single uuid:seq GTIDs, stop after limit GTIDs when limit > 0
+func (set MySQLSet) GetGtids(limit int) []string {
+	var gtids []string
+	for _, source := range set.Sources() {
+		for _, iv := range set[source] {
+			for seq := iv.Start; seq <= iv.End; seq++ {
+				if limit > 0 && len(gtids) >= limit {
+					return gtids
+				}
+				gtids = append(gtids, source+":"+strconv.FormatUint(seq, 10))
+			}
+		}
+	}
+	return gtids
+}
+
+// GetLastSeqNo returns the highest transaction number of a source
+func (set MySQLSet) GetLastSeqNo(source string) uint64 {
+	ivs := set[strings.ToLower(source)]
+	if len(ivs) == 0 {
+		return 0
+	}
+	return ivs[len(ivs)-1].End
+}
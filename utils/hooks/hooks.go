@@ -0,0 +1,327 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package hooks calls scripts, webhooks and Go functions on the events of the
+// failover lifecycle with a JSON payload. A failing hook of a pre event can veto
+// the operation.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	EventPreFailover      = "pre-failover"
+	EventCandidateElected = "candidate-elected"
+	EventPostPromote      = "post-promote"
+	EventProxiesUpdated   = "proxies-updated"
+	EventRejoinStart      = "rejoin-start"
+	EventRejoinEnd        = "rejoin-end"
+	EventBackupFinished   = "backup-finished"
+)
+
+// Events lists the events in the order of the lifecycle
+var Events = []string{EventPreFailover, EventCandidateElected, EventPostPromote, EventProxiesUpdated, EventRejoinStart, EventRejoinEnd, EventBackupFinished}
+
+const (
+	// HeaderEvent is the webhook header carrying the event
+	HeaderEvent = "X-Replication-Manager-Event"
+	// HeaderSignature is the webhook header carrying the HMAC-SHA256 of the body
+	// with the secret, as sha256=<hex>
+	HeaderSignature = "X-Replication-Manager-Signature"
+)
+
+// IsPre returns true when a hook of the event can veto the operation
+func IsPre(event string) bool {
+	return event == EventPreFailover || event == EventCandidateElected || event == EventRejoinStart
+}
+
+type Server struct {
+	URL  string `json:"url"`
+	Host string `json:"host"`
+	Port string `json:"port"`
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type Backup struct {
+	Type   string `json:"type"`
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Payload is the JSON document given to the hooks
+type Payload struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Cluster    string    `json:"cluster"`
+	Switchover bool      `json:"switchover,omitempty"`
+	OldMaster  *Server   `json:"oldMaster,omitempty"`
+	NewMaster  *Server   `json:"newMaster,omitempty"`
+	Server     *Server   `json:"server,omitempty"`
+	Backup     *Backup   `json:"backup,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Result is the outcome of a hook, Output is the output of a script or the
+// response of a webhook
+type Result struct {
+	Hook     string        `json:"hook"`
+	Event    string        `json:"event"`
+	Duration time.Duration `json:"duration"`
+	Output   string        `json:"output,omitempty"`
+	Err      error         `json:"-"`
+	Timeout  bool          `json:"timeout"`
+	Veto     bool          `json:"veto"`
+}
+
+// Hook is called with the payload and its JSON encoding
+type Hook interface {
+	Name() string
+	Run(ctx context.Context, p *Payload, body []byte) (string, error)
+}
+
+// Func is a hook compiled in replication-manager
+type Func func(ctx context.Context, p *Payload) error
+
+type funcHook struct {
+	name string
+	fn   Func
+}
+
+func (h *funcHook) Name() string {
+	return "go:" + h.name
+}
+
+func (h *funcHook) Run(ctx context.Context, p *Payload, body []byte) (string, error) {
+	return "", h.fn(ctx, p)
+}
+
+// ScriptHook runs the script with the event as argument and the payload on stdin
+type ScriptHook struct {
+	Path string
+}
+
+func (h *ScriptHook) Name() string {
+	return "script:" + h.Path
+}
+
+func (h *ScriptHook) Run(ctx context.Context, p *Payload, body []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, h.Path, p.Event)
+	cmd.Stdin = bytes.NewReader(body)
+	// children of the script inherit its output, the whole process group is killed on
+	// timeout and a child that left the group can not hold the output open for long
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// WebhookHook posts the payload to the URL, a status other than 2xx is an error
+type WebhookHook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (h *WebhookHook) Name() string {
+	return "webhook:" + h.URL
+}
+
+func (h *WebhookHook) Run(ctx context.Context, p *Payload, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, p.Event)
+	if h.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(h.Secret, body))
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return strings.TrimSpace(string(out)), fmt.Errorf("status %s", resp.Status)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Sign returns the signature of the body sent in HeaderSignature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type registration struct {
+	hook   Hook
+	events map[string]bool
+	// registered in Go, kept when the configuration changes
+	builtin bool
+}
+
+// Manager calls the hooks registered on an event one after the other
+type Manager struct {
+	mutex   sync.Mutex
+	veto    bool
+	timeout time.Duration
+	hooks   []registration
+}
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// ParseEvents returns the events of a comma separated list, all events when empty
+// or all
+func ParseEvents(list string) (map[string]bool, error) {
+	events := make(map[string]bool)
+	for _, e := range strings.Split(list, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" || e == "all" {
+			continue
+		}
+		known := false
+		for _, k := range Events {
+			if e == k {
+				known = true
+			}
+		}
+		if !known {
+			return nil, errors.New("Unknown hook event " + e)
+		}
+		events[e] = true
+	}
+	if len(events) == 0 {
+		for _, k := range Events {
+			events[k] = true
+		}
+	}
+	return events, nil
+}
+
+// Configure replaces the hooks of the configuration, called on the events, veto
+// makes a failing hook of a pre event cancel the operation
+func (m *Manager) Configure(veto bool, timeout time.Duration, hs []Hook, events map[string]bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.veto = veto
+	m.timeout = timeout
+	var kept []registration
+	for _, r := range m.hooks {
+		if r.builtin {
+			kept = append(kept, r)
+		}
+	}
+	for _, h := range hs {
+		kept = append(kept, registration{hook: h, events: events})
+	}
+	m.hooks = kept
+}
+
+// Register adds a Go hook on the events, all events when none
+func (m *Manager) Register(name string, fn Func, events ...string) {
+	set := make(map[string]bool)
+	for _, e := range events {
+		set[e] = true
+	}
+	if len(events) == 0 {
+		set, _ = ParseEvents("")
+	}
+	m.mutex.Lock()
+	m.hooks = append(m.hooks, registration{hook: &funcHook{name: name, fn: fn}, events: set, builtin: true})
+	m.mutex.Unlock()
+}
+
+// Len returns the number of hooks registered
+func (m *Manager) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.hooks)
+}
+
+// Fire calls the hooks of the event and returns their results. The error is not
+// nil when a hook vetoes a pre event, the hooks after it are not called. A hook
+// timing out never vetoes.
+func (m *Manager) Fire(p *Payload) ([]Result, error) {
+	if m == nil {
+		return nil, nil
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	m.mutex.Lock()
+	veto, timeout := m.veto && IsPre(p.Event), m.timeout
+	var hooks []Hook
+	for _, r := range m.hooks {
+		if r.events[p.Event] {
+			hooks = append(hooks, r.hook)
+		}
+	}
+	m.mutex.Unlock()
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, h := range hooks {
+		r := run(h, p, body, timeout)
+		r.Veto = r.Err != nil && !r.Timeout && veto
+		results = append(results, r)
+		if r.Veto {
+			return results, fmt.Errorf("Hook %s vetoed %s: %s", r.Hook, p.Event, r.Err)
+		}
+	}
+	return results, nil
+}
+
+func run(h Hook, p *Payload, body []byte, timeout time.Duration) Result {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	out, err := h.Run(ctx, p, body)
+	r := Result{Hook: h.Name(), Event: p.Event, Duration: time.Since(start), Output: out, Err: err}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		r.Timeout = true
+	}
+	return r
+}
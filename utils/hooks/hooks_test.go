@@ -0,0 +1,133 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScriptHook(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho $1\ncat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager()
+	events, _ := ParseEvents("all")
+	m.Configure(true, 5*time.Second, []Hook{&ScriptHook{Path: script}}, events)
+	results, err := m.Fire(&Payload{Event: EventPostPromote, Cluster: "c1", NewMaster: &Server{URL: "db2:3306"}})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Unexpected results %v %v", results, err)
+	}
+	arg, stdin, _ := strings.Cut(results[0].Output, "\n")
+	if arg != EventPostPromote {
+		t.Errorf("Expected the event as argument got %q", arg)
+	}
+	var p Payload
+	if err := json.Unmarshal([]byte(stdin), &p); err != nil || p.NewMaster.URL != "db2:3306" || p.Time.IsZero() {
+		t.Errorf("Expected the payload on stdin %v %+v", err, p)
+	}
+}
+
+func TestScriptHookTimeout(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho started\nsleep 5\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager()
+	events, _ := ParseEvents("all")
+	m.Configure(true, 500*time.Millisecond, []Hook{&ScriptHook{Path: script}}, events)
+	start := time.Now()
+	results, err := m.Fire(&Payload{Event: EventPreFailover, Cluster: "c1"})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the child of the script to be killed on timeout, waited %s", elapsed)
+	}
+	if err != nil || len(results) != 1 || !results[0].Timeout || results[0].Veto {
+		t.Errorf("Expected a timeout without veto %+v %v", results, err)
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	var signature, event string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(HeaderSignature)
+		event = r.Header.Get(HeaderEvent)
+		body, _ = io.ReadAll(r.Body)
+		if event == EventPreFailover {
+			w.WriteHeader(http.StatusConflict)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	m := NewManager()
+	events, _ := ParseEvents("pre-failover, proxies-updated")
+	m.Configure(true, 5*time.Second, []Hook{&WebhookHook{URL: ts.URL, Secret: "s3cret"}}, events)
+	results, err := m.Fire(&Payload{Event: EventProxiesUpdated, Cluster: "c1"})
+	if err != nil || len(results) != 1 || results[0].Output != "ok" {
+		t.Fatalf("Unexpected results %+v %v", results, err)
+	}
+	if event != EventProxiesUpdated || signature != Sign("s3cret", body) {
+		t.Errorf("Unexpected headers %s %s", event, signature)
+	}
+	if _, err := m.Fire(&Payload{Event: EventPreFailover, Cluster: "c1"}); err == nil {
+		t.Errorf("Expected a veto on a conflict")
+	}
+	if results, _ := m.Fire(&Payload{Event: EventRejoinStart}); len(results) != 0 {
+		t.Errorf("Expected no hook on an event not registered")
+	}
+}
+
+func TestVeto(t *testing.T) {
+	fail := func(ctx context.Context, p *Payload) error { return errors.New("no") }
+	slow := func(ctx context.Context, p *Payload) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	called := false
+	after := func(ctx context.Context, p *Payload) error {
+		called = true
+		return nil
+	}
+
+	m := NewManager()
+	m.Configure(true, 50*time.Millisecond, nil, nil)
+	m.Register("slow", slow)
+	m.Register("fail", fail, EventCandidateElected, EventPostPromote)
+	m.Register("after", after)
+	results, err := m.Fire(&Payload{Event: EventCandidateElected})
+	if err == nil || len(results) != 2 || !results[0].Timeout || results[0].Veto || !results[1].Veto || called {
+		t.Errorf("Expected a veto of the failing hook only %+v %v", results, err)
+	}
+	if results, err := m.Fire(&Payload{Event: EventPostPromote}); err != nil || len(results) != 3 || !called {
+		t.Errorf("Expected no veto on a post event %+v %v", results, err)
+	}
+
+	m.Configure(false, 50*time.Millisecond, nil, nil)
+	if m.Len() != 3 {
+		t.Errorf("Expected the Go hooks kept by the configuration")
+	}
+	if _, err := m.Fire(&Payload{Event: EventCandidateElected}); err != nil {
+		t.Errorf("Expected no veto when disabled %v", err)
+	}
+	if _, err := ParseEvents("pre-failover,unknown"); err == nil {
+		t.Errorf("Expected an error on an unknown event")
+	}
+}
@@ -0,0 +1,370 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package indexadvisor proposes the indexes that would avoid the full scans,
+// filesorts and temporary tables in the plans of the slowest query digests, and
+// the existing indexes that are redundant or never used.
+//
+// A candidate index is built per table from the predicates of the query: the
+// columns compared for equality first, then the ORDER BY or GROUP BY columns when
+// there is no range, else the first column compared on a range.
+package indexadvisor
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	ConstProblemFullScan      = "full scan"
+	ConstProblemFullIndexScan = "full index scan"
+	ConstProblemFilesort      = "filesort"
+	ConstProblemTemporary     = "temporary"
+)
+
+// an index on more columns is rarely worth its maintenance
+const maxColumns = 5
+
+// Index is an existing index of a table, columns in index order
+type Index struct {
+	Schema  string   `json:"schema"`
+	Table   string   `json:"table"`
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Columns []string `json:"columns"`
+}
+
+// Table gives the columns of a table to resolve the columns of the queries not
+// qualified by a table
+type Table struct {
+	Schema  string
+	Name    string
+	Rows    int64
+	Columns []string
+}
+
+// Plan is a line of EXPLAIN of a digest
+type Plan struct {
+	Table string
+	Type  string
+	Key   string
+	Rows  int64
+	Extra string
+}
+
+// Digest is a query digest with a sample query to explain, latency in ms
+type Digest struct {
+	Digest       string
+	Schema       string
+	Query        string
+	Count        int64
+	Latency      float64
+	RowsExamined int64
+}
+
+type Problem struct {
+	Table   string `json:"table"`
+	Problem string `json:"problem"`
+	Rows    int64  `json:"rows"`
+}
+
+// DigestAdvice is the analysis of the plan of a digest
+type DigestAdvice struct {
+	Digest     string    `json:"digest"`
+	Schema     string    `json:"schema"`
+	Query      string    `json:"query"`
+	Count      int64     `json:"count"`
+	Latency    float64   `json:"latency"`
+	Problems   []Problem `json:"problems"`
+	Candidates []string  `json:"candidates"`
+	Error      string    `json:"error"`
+}
+
+// Candidate is an index to create, with the activity of the digests it would help
+// as estimated impact
+type Candidate struct {
+	Schema  string   `json:"schema"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	DDL     string   `json:"ddl"`
+	// existing index made redundant by this one
+	Replaces   string   `json:"replaces"`
+	Problems   []string `json:"problems"`
+	Digests    []string `json:"digests"`
+	Executions int64    `json:"executions"`
+	// total latency in ms of the digests
+	Latency float64 `json:"latency"`
+	// rows examined by the digests and by execution in their plans
+	RowsExamined int64   `json:"rowsExamined"`
+	RowsPerExec  int64   `json:"rowsPerExec"`
+	TableRows    int64   `json:"tableRows"`
+	LatencyShare float64 `json:"latencyShare"`
+}
+
+// IndexAdvice is an existing index to drop
+type IndexAdvice struct {
+	Index
+	// index including the columns of this one
+	CoveredBy string `json:"coveredBy"`
+	DDL       string `json:"ddl"`
+}
+
+type Report struct {
+	Digests    []DigestAdvice `json:"digests"`
+	Candidates []Candidate    `json:"candidates"`
+	Redundant  []IndexAdvice  `json:"redundant"`
+	Unused     []IndexAdvice  `json:"unused"`
+}
+
+type Advisor struct {
+	// minimum rows in a plan line to report a problem, small tables are scanned
+	MinRows    int64
+	indexes    map[string][]Index
+	tables     map[string]Table
+	candidates map[string]*Candidate
+	digests    []DigestAdvice
+	latency    float64
+}
+
+func key(schema string, table string) string {
+	return strings.ToLower(schema + "." + table)
+}
+
+func NewAdvisor(tables []Table, indexes []Index, minRows int64) *Advisor {
+	a := &Advisor{
+		MinRows:    minRows,
+		indexes:    make(map[string][]Index),
+		tables:     make(map[string]Table),
+		candidates: make(map[string]*Candidate),
+	}
+	for _, t := range tables {
+		for i := range t.Columns {
+			t.Columns[i] = strings.ToLower(t.Columns[i])
+		}
+		a.tables[key(t.Schema, t.Name)] = t
+	}
+	for _, idx := range indexes {
+		for i := range idx.Columns {
+			idx.Columns[i] = strings.ToLower(idx.Columns[i])
+		}
+		k := key(idx.Schema, idx.Table)
+		a.indexes[k] = append(a.indexes[k], idx)
+	}
+	return a
+}
+
+func quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func hasPrefix(columns []string, prefix []string) bool {
+	if len(prefix) > len(columns) {
+		return false
+	}
+	for i := range prefix {
+		if columns[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// problems returns the problems of a plan line
+func (a *Advisor) problems(p Plan) []string {
+	var res []string
+	if p.Rows >= a.MinRows {
+		switch strings.ToUpper(p.Type) {
+		case "ALL":
+			res = append(res, ConstProblemFullScan)
+		case "INDEX":
+			res = append(res, ConstProblemFullIndexScan)
+		}
+	}
+	if strings.Contains(p.Extra, "Using filesort") {
+		res = append(res, ConstProblemFilesort)
+	}
+	if strings.Contains(p.Extra, "Using temporary") {
+		res = append(res, ConstProblemTemporary)
+	}
+	return res
+}
+
+// Add analyzes the plan of a digest and records the candidate indexes that would
+// solve its problems
+func (a *Advisor) Add(d Digest, plans []Plan, planErr error) DigestAdvice {
+	a.latency += d.Latency
+	advice := DigestAdvice{Digest: d.Digest, Schema: d.Schema, Query: d.Query, Count: d.Count, Latency: d.Latency, Problems: []Problem{}, Candidates: []string{}}
+	defer func() { a.digests = append(a.digests, advice) }()
+	if planErr != nil {
+		advice.Error = planErr.Error()
+		return advice
+	}
+	var q *query
+	for i, p := range plans {
+		problems := a.problems(p)
+		if len(problems) == 0 {
+			continue
+		}
+		for _, pb := range problems {
+			advice.Problems = append(advice.Problems, Problem{Table: p.Table, Problem: pb, Rows: p.Rows})
+		}
+		if q == nil {
+			var err error
+			if q, err = parse(d.Query, d.Schema, a.tables); err != nil {
+				advice.Error = err.Error()
+				return advice
+			}
+		}
+		alias := strings.ToLower(p.Table)
+		ref, ok := q.aliases[alias]
+		if !ok {
+			// derived tables and subqueries
+			continue
+		}
+		// the first table of the plan drives the join
+		columns := q.indexColumns(alias, i > 0)
+		if len(columns) == 0 {
+			continue
+		}
+		c := a.candidate(ref, columns)
+		if c == nil || contains(c.Digests, d.Digest) {
+			continue
+		}
+		advice.Candidates = append(advice.Candidates, c.DDL)
+		c.Digests = append(c.Digests, d.Digest)
+		c.Executions += d.Count
+		c.Latency += d.Latency
+		c.RowsExamined += d.RowsExamined
+		if p.Rows > c.RowsPerExec {
+			c.RowsPerExec = p.Rows
+		}
+		for _, pb := range problems {
+			if !contains(c.Problems, pb) {
+				c.Problems = append(c.Problems, pb)
+			}
+		}
+	}
+	return advice
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// candidate returns the candidate for the columns of the table, nil when an
+// existing index already starts with them
+func (a *Advisor) candidate(ref tableRef, columns []string) *Candidate {
+	k := key(ref.schema, ref.table)
+	replaces := ""
+	for _, idx := range a.indexes[k] {
+		if hasPrefix(idx.Columns, columns) {
+			return nil
+		}
+		if len(idx.Columns) > 0 && hasPrefix(columns, idx.Columns) && !idx.Unique {
+			replaces = idx.Name
+		}
+	}
+	ck := k + "(" + strings.Join(columns, ",") + ")"
+	if c, ok := a.candidates[ck]; ok {
+		return c
+	}
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quote(col)
+	}
+	name := "idx_" + strings.Join(columns, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	c := &Candidate{
+		Schema:    ref.schema,
+		Table:     ref.table,
+		Columns:   columns,
+		DDL:       "ALTER TABLE " + quote(ref.schema) + "." + quote(ref.table) + " ADD INDEX " + quote(name) + " (" + strings.Join(quoted, ",") + ")",
+		Replaces:  replaces,
+		Problems:  []string{},
+		Digests:   []string{},
+		TableRows: a.tables[k].Rows,
+	}
+	a.candidates[ck] = c
+	return c
+}
+
+// redundant returns the indexes whose columns start the columns of another index
+// of the table, unique indexes are kept for their constraint
+func (a *Advisor) redundant() []IndexAdvice {
+	res := []IndexAdvice{}
+	for _, indexes := range a.indexes {
+		for i, idx := range indexes {
+			// functional indexes have no column
+			if idx.Unique || len(idx.Columns) == 0 {
+				continue
+			}
+			for j, other := range indexes {
+				if i == j || !hasPrefix(other.Columns, idx.Columns) {
+					continue
+				}
+				// of two identical indexes the last one is reported
+				if len(other.Columns) == len(idx.Columns) && !other.Unique && j > i {
+					continue
+				}
+				res = append(res, dropAdvice(idx, other.Name))
+				break
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return indexName(res[i].Index) < indexName(res[j].Index) })
+	return res
+}
+
+func indexName(idx Index) string {
+	return idx.Schema + "." + idx.Table + "." + idx.Name
+}
+
+func dropAdvice(idx Index, coveredBy string) IndexAdvice {
+	return IndexAdvice{
+		Index:     idx,
+		CoveredBy: coveredBy,
+		DDL:       "ALTER TABLE " + quote(idx.Schema) + "." + quote(idx.Table) + " DROP INDEX " + quote(idx.Name),
+	}
+}
+
+// Report returns the candidates with the highest latency first, unused are the
+// indexes without reads since the server started
+func (a *Advisor) Report(unused []Index) *Report {
+	r := &Report{Digests: a.digests, Candidates: []Candidate{}, Unused: []IndexAdvice{}}
+	if r.Digests == nil {
+		r.Digests = []DigestAdvice{}
+	}
+	for _, c := range a.candidates {
+		if a.latency > 0 {
+			c.LatencyShare = c.Latency * 100 / a.latency
+		}
+		r.Candidates = append(r.Candidates, *c)
+	}
+	sort.Slice(r.Candidates, func(i, j int) bool {
+		if r.Candidates[i].Latency == r.Candidates[j].Latency {
+			return r.Candidates[i].DDL < r.Candidates[j].DDL
+		}
+		return r.Candidates[i].Latency > r.Candidates[j].Latency
+	})
+	r.Redundant = a.redundant()
+	for _, idx := range unused {
+		if idx.Unique || strings.EqualFold(idx.Name, "PRIMARY") {
+			continue
+		}
+		r.Unused = append(r.Unused, dropAdvice(idx, ""))
+	}
+	return r
+}
@@ -0,0 +1,128 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package indexadvisor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func shop() *Advisor {
+	tables := []Table{
+		{Schema: "shop", Name: "orders", Rows: 100000, Columns: []string{"id", "customer_id", "status", "created", "total"}},
+		{Schema: "shop", Name: "customers", Rows: 5000, Columns: []string{"id", "name", "country"}},
+	}
+	indexes := []Index{
+		{Schema: "shop", Table: "orders", Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+		{Schema: "shop", Table: "orders", Name: "idx_customer", Columns: []string{"customer_id"}},
+		{Schema: "shop", Table: "orders", Name: "idx_customer_created", Columns: []string{"Customer_id", "created"}},
+		{Schema: "shop", Table: "customers", Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+		{Schema: "shop", Table: "customers", Name: "idx_name", Columns: []string{"name"}},
+		{Schema: "shop", Table: "customers", Name: "idx_name2", Columns: []string{"name"}},
+	}
+	return NewAdvisor(tables, indexes, 1000)
+}
+
+func TestColumns(t *testing.T) {
+	tables := shop().tables
+	for sql, expected := range map[string][]string{
+		"SELECT * FROM orders WHERE status='paid' AND created > '2023-01-01' AND total > 10":        {"status", "created"},
+		"SELECT * FROM orders WHERE status IN ('paid','sent') ORDER BY created, id LIMIT 10":        {"status", "created", "id"},
+		"SELECT status, count(*) FROM orders o WHERE o.total BETWEEN 1 AND 5 GROUP BY status":       {"total"},
+		"SELECT * FROM orders WHERE status='paid' OR total > 10":                                    nil,
+		"SELECT * FROM orders WHERE status LIKE '%paid'":                                            nil,
+		"UPDATE orders SET total=0 WHERE status LIKE 'pa%' AND customer_id IS NULL":                 {"customer_id", "status"},
+		"DELETE FROM orders WHERE status='x' ORDER BY created LIMIT 100":                            {"status", "created"},
+		"SELECT * FROM `shop`.`orders` WHERE `status` = 'paid' AND (`created` >= '2023' OR id = 1)": {"status"},
+	} {
+		q, err := parse(sql, "shop", tables)
+		if err != nil {
+			t.Fatalf("%s: %s", sql, err)
+		}
+		alias := "orders"
+		if _, ok := q.aliases["o"]; ok {
+			alias = "o"
+		}
+		if columns := q.indexColumns(alias, false); len(columns) != len(expected) || (len(columns) > 0 && !reflect.DeepEqual(columns, expected)) {
+			t.Errorf("%s: expected %v got %v", sql, expected, columns)
+		}
+	}
+
+	q, err := parse("SELECT c.name FROM customers c JOIN orders o ON o.customer_id = c.id WHERE country = 'FR' AND o.status = 'paid'", "shop", tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns := q.indexColumns("c", false); !reflect.DeepEqual(columns, []string{"country"}) {
+		t.Errorf("Expected the driving table on its filter got %v", columns)
+	}
+	if columns := q.indexColumns("o", true); !reflect.DeepEqual(columns, []string{"status", "customer_id"}) {
+		t.Errorf("Expected the inner table on its filter and join got %v", columns)
+	}
+	if _, err := parse("INSERT INTO orders VALUES (1)", "shop", tables); err == nil {
+		t.Errorf("Expected an error on an insert")
+	}
+}
+
+func TestAdvisor(t *testing.T) {
+	a := shop()
+	a.Add(Digest{Digest: "d1", Schema: "shop", Query: "SELECT * FROM orders WHERE status='paid' ORDER BY created", Count: 100, Latency: 900, RowsExamined: 10000000},
+		[]Plan{{Table: "orders", Type: "ALL", Rows: 100000, Extra: "Using where; Using filesort"}}, nil)
+	a.Add(Digest{Digest: "d2", Schema: "shop", Query: "SELECT id FROM orders WHERE status = 'sent' ORDER BY created DESC LIMIT 5", Count: 10, Latency: 50},
+		[]Plan{{Table: "orders", Type: "ALL", Rows: 100000, Extra: "Using filesort"}}, nil)
+	// an existing index starts with the columns
+	a.Add(Digest{Digest: "d3", Schema: "shop", Query: "SELECT * FROM orders WHERE customer_id = 3", Count: 1, Latency: 40},
+		[]Plan{{Table: "orders", Type: "ALL", Rows: 100000}}, nil)
+	// a small table is not a problem
+	a.Add(Digest{Digest: "d4", Schema: "shop", Query: "SELECT * FROM customers WHERE country = 'FR'", Count: 1, Latency: 5},
+		[]Plan{{Table: "customers", Type: "ALL", Rows: 500}}, nil)
+	a.Add(Digest{Digest: "d5", Schema: "shop", Query: "SELECT * FROM orders WHERE customer_id = 3 AND created > NOW() AND status = 'x'", Count: 1, Latency: 5},
+		[]Plan{{Table: "orders", Type: "ref", Key: "idx_customer", Rows: 1000000}}, nil)
+	a.Add(Digest{Digest: "d6", Query: "SELECT"}, nil, errors.New("No query sample"))
+	r := a.Report([]Index{
+		{Schema: "shop", Table: "orders", Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+		{Schema: "shop", Table: "customers", Name: "idx_name", Columns: []string{"name"}},
+	})
+
+	if len(r.Digests) != 6 || len(r.Digests[2].Problems) != 1 || len(r.Digests[2].Candidates) != 0 || len(r.Digests[3].Problems) != 0 || r.Digests[5].Error != "No query sample" {
+		t.Errorf("Unexpected digests %+v", r.Digests)
+	}
+	if len(r.Candidates) != 1 {
+		t.Fatalf("Expected a candidate got %+v", r.Candidates)
+	}
+	c := r.Candidates[0]
+	if c.DDL != "ALTER TABLE `shop`.`orders` ADD INDEX `idx_status_created` (`status`,`created`)" || c.Executions != 110 || c.Latency != 950 || c.RowsPerExec != 100000 || c.TableRows != 100000 || c.LatencyShare != 950*100/1000.0 {
+		t.Errorf("Unexpected candidate %+v", c)
+	}
+	if !reflect.DeepEqual(c.Problems, []string{ConstProblemFullScan, ConstProblemFilesort}) || !reflect.DeepEqual(c.Digests, []string{"d1", "d2"}) {
+		t.Errorf("Unexpected candidate problems %v digests %v", c.Problems, c.Digests)
+	}
+
+	var redundant []string
+	for _, idx := range r.Redundant {
+		redundant = append(redundant, idx.Name+">"+idx.CoveredBy)
+	}
+	if !reflect.DeepEqual(redundant, []string{"idx_name2>idx_name", "idx_customer>idx_customer_created"}) {
+		t.Errorf("Unexpected redundant indexes %v", redundant)
+	}
+	if len(r.Unused) != 1 || r.Unused[0].DDL != "ALTER TABLE `shop`.`customers` DROP INDEX `idx_name`" {
+		t.Errorf("Unexpected unused indexes %+v", r.Unused)
+	}
+}
+
+func TestReplaces(t *testing.T) {
+	a := shop()
+	a.Add(Digest{Digest: "d1", Schema: "shop", Query: "SELECT * FROM customers WHERE name = 'a' AND country = 'FR'", Count: 1, Latency: 1},
+		[]Plan{{Table: "customers", Type: "ALL", Rows: 5000}}, nil)
+	r := a.Report(nil)
+	if len(r.Candidates) != 1 || r.Candidates[0].Replaces != "idx_name2" {
+		t.Errorf("Expected the candidate to replace an index %+v", r.Candidates)
+	}
+}
@@ -0,0 +1,257 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package indexadvisor
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+type tableRef struct {
+	schema string
+	table  string
+}
+
+// query holds the columns of the predicates of a statement by table alias
+type query struct {
+	aliases map[string]tableRef
+	tables  map[string]Table
+	eq      map[string][]string
+	joinEq  map[string][]string
+	ranges  map[string][]string
+	order   map[string][]string
+}
+
+func parse(sql string, schema string, tables map[string]Table) (*query, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+	q := &query{
+		aliases: make(map[string]tableRef),
+		tables:  tables,
+		eq:      make(map[string][]string),
+		joinEq:  make(map[string][]string),
+		ranges:  make(map[string][]string),
+		order:   make(map[string][]string),
+	}
+	var from sqlparser.TableExprs
+	var where *sqlparser.Where
+	var order []sqlparser.Expr
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		from, where = s.From, s.Where
+		if len(s.GroupBy) > 0 {
+			order = s.GroupBy
+		} else {
+			for _, o := range s.OrderBy {
+				order = append(order, o.Expr)
+			}
+		}
+	case *sqlparser.Update:
+		from, where = s.TableExprs, s.Where
+		for _, o := range s.OrderBy {
+			order = append(order, o.Expr)
+		}
+	case *sqlparser.Delete:
+		from, where = s.TableExprs, s.Where
+		for _, o := range s.OrderBy {
+			order = append(order, o.Expr)
+		}
+	default:
+		return nil, errors.New("Only SELECT, UPDATE and DELETE are analyzed")
+	}
+	var joins []sqlparser.Expr
+	q.addTables(from, schema, &joins)
+	for _, on := range joins {
+		q.addPredicate(on)
+	}
+	if where != nil {
+		q.addPredicate(where.Expr)
+	}
+	q.addOrder(order)
+	return q, nil
+}
+
+func (q *query) addTables(exprs sqlparser.TableExprs, schema string, joins *[]sqlparser.Expr) {
+	for _, expr := range exprs {
+		switch t := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			name, ok := t.Expr.(sqlparser.TableName)
+			if !ok {
+				continue
+			}
+			ref := tableRef{schema: schema, table: name.Name.String()}
+			if !name.Qualifier.IsEmpty() {
+				ref.schema = name.Qualifier.String()
+			}
+			alias := ref.table
+			if !t.As.IsEmpty() {
+				alias = t.As.String()
+			}
+			q.aliases[strings.ToLower(alias)] = ref
+		case *sqlparser.JoinTableExpr:
+			q.addTables(sqlparser.TableExprs{t.LeftExpr, t.RightExpr}, schema, joins)
+			if t.Condition.On != nil {
+				*joins = append(*joins, t.Condition.On)
+			}
+			for _, col := range t.Condition.Using {
+				for alias := range q.aliases {
+					if q.hasColumn(alias, col.Lowered()) {
+						q.joinEq[alias] = appendColumn(q.joinEq[alias], col.Lowered())
+					}
+				}
+			}
+		case *sqlparser.ParenTableExpr:
+			q.addTables(t.Exprs, schema, joins)
+		}
+	}
+}
+
+func (q *query) hasColumn(alias string, column string) bool {
+	ref := q.aliases[alias]
+	t, ok := q.tables[key(ref.schema, ref.table)]
+	return ok && contains(t.Columns, column)
+}
+
+// resolve returns the alias of the table of a column, a column not qualified is
+// searched in the columns of the tables of the query
+func (q *query) resolve(expr sqlparser.Expr) (string, string, bool) {
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return "", "", false
+	}
+	name := col.Name.Lowered()
+	if !col.Qualifier.IsEmpty() {
+		alias := strings.ToLower(col.Qualifier.Name.String())
+		_, ok := q.aliases[alias]
+		return alias, name, ok
+	}
+	found := ""
+	for alias := range q.aliases {
+		if len(q.aliases) == 1 || q.hasColumn(alias, name) {
+			if found != "" {
+				return "", "", false
+			}
+			found = alias
+		}
+	}
+	return found, name, found != ""
+}
+
+func appendColumn(columns []string, column string) []string {
+	if contains(columns, column) {
+		return columns
+	}
+	return append(columns, column)
+}
+
+func (q *query) addEq(expr sqlparser.Expr) {
+	if alias, col, ok := q.resolve(expr); ok {
+		q.eq[alias] = appendColumn(q.eq[alias], col)
+	}
+}
+
+func (q *query) addRange(expr sqlparser.Expr) {
+	if alias, col, ok := q.resolve(expr); ok {
+		q.ranges[alias] = appendColumn(q.ranges[alias], col)
+	}
+}
+
+// addPredicate records the columns of the conditions joined by AND, the conditions
+// under OR can not use a single index
+func (q *query) addPredicate(expr sqlparser.Expr) {
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		q.addPredicate(e.Left)
+		q.addPredicate(e.Right)
+	case *sqlparser.ParenExpr:
+		q.addPredicate(e.Expr)
+	case *sqlparser.IsExpr:
+		if e.Operator == sqlparser.IsNullStr {
+			q.addEq(e.Expr)
+		}
+	case *sqlparser.RangeCond:
+		if e.Operator == sqlparser.BetweenStr {
+			q.addRange(e.Left)
+		}
+	case *sqlparser.ComparisonExpr:
+		switch e.Operator {
+		case sqlparser.EqualStr, sqlparser.NullSafeEqualStr:
+			left, lcol, lok := q.resolve(e.Left)
+			right, rcol, rok := q.resolve(e.Right)
+			if lok && rok {
+				// a join only helps to read the inner table
+				q.joinEq[left] = appendColumn(q.joinEq[left], lcol)
+				q.joinEq[right] = appendColumn(q.joinEq[right], rcol)
+			} else {
+				q.addEq(e.Left)
+				q.addEq(e.Right)
+			}
+		case sqlparser.InStr:
+			q.addEq(e.Left)
+		case sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr, sqlparser.GreaterEqualStr:
+			q.addRange(e.Left)
+			q.addRange(e.Right)
+		case sqlparser.LikeStr:
+			// only a pattern with a fixed prefix is a range
+			if v, ok := e.Right.(*sqlparser.SQLVal); ok && len(v.Val) > 0 && v.Val[0] != '%' && v.Val[0] != '_' {
+				q.addRange(e.Left)
+			}
+		}
+	}
+}
+
+// addOrder records the columns of ORDER BY or GROUP BY when they are all columns
+// of the same table
+func (q *query) addOrder(exprs []sqlparser.Expr) {
+	var columns []string
+	table := ""
+	for _, expr := range exprs {
+		alias, col, ok := q.resolve(expr)
+		if !ok || (table != "" && alias != table) {
+			return
+		}
+		table = alias
+		columns = appendColumn(columns, col)
+	}
+	if table != "" {
+		q.order[table] = columns
+	}
+}
+
+// indexColumns returns the columns of the index for a table of the query, the
+// join columns are used for an inner table of a join
+func (q *query) indexColumns(alias string, inner bool) []string {
+	columns := append([]string{}, q.eq[alias]...)
+	if inner {
+		for _, col := range q.joinEq[alias] {
+			columns = appendColumn(columns, col)
+		}
+	}
+	if ranges := q.ranges[alias]; len(ranges) > 0 {
+		for _, col := range ranges {
+			if !contains(columns, col) {
+				columns = append(columns, col)
+				break
+			}
+		}
+	} else {
+		for _, col := range q.order[alias] {
+			columns = appendColumn(columns, col)
+		}
+	}
+	if len(columns) > maxColumns {
+		columns = columns[:maxColumns]
+	}
+	return columns
+}
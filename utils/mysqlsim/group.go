@@ -0,0 +1,165 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package mysqlsim
+
+import (
+	"strconv"
+
+	. "github.com/siddontang/go-mysql/mysql"
+)
+
+// GroupName is the group_replication_group_name of the members of a simulated group
+const GroupName = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+
+// NewGroup starts a single primary MySQL group replication of n members, the first one is the primary.
+// A stopped member is reported UNREACHABLE and never expelled, so the group loses its majority when
+// half of its members are stopped or partitioned, like after simultaneous crashes
+func NewGroup(user string, password string, n int) (*Topology, error) {
+	t := NewTopology(user, password)
+	for i := 0; i < n; i++ {
+		s, err := t.AddServer(FlavorMySQL)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.mu.Lock()
+		s.variables["GROUP_REPLICATION_GROUP_NAME"] = GroupName
+		s.variables["GROUP_REPLICATION_MEMBER_WEIGHT"] = "50"
+		s.variables["GROUP_REPLICATION_SINGLE_PRIMARY_MODE"] = "ON"
+		s.groupOnline = true
+		t.mu.Unlock()
+	}
+	t.mu.Lock()
+	t.setGroupPrimary(t.Servers[0])
+	t.mu.Unlock()
+	return t, nil
+}
+
+// GetGroupPrimary returns the primary elected by the group, nil when the group has no majority
+func (t *Topology) GetGroupPrimary() *Server {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.primary
+}
+
+// getGroupMembers returns the members of the group, stopped members stay in the group view
+func (t *Topology) getGroupMembers() []*Server {
+	var members []*Server
+	for _, s := range t.Servers {
+		if s.variables["GROUP_REPLICATION_GROUP_NAME"] != "" && (s.groupOnline || s.down) {
+			members = append(members, s)
+		}
+	}
+	return members
+}
+
+// getGroupMemberState returns the state of member m in the group view of s
+func (t *Topology) getGroupMemberState(s *Server, m *Server) string {
+	if m == s {
+		return "ONLINE"
+	}
+	if m.down || t.cuts[[2]*Server{s, m}] {
+		return "UNREACHABLE"
+	}
+	return "ONLINE"
+}
+
+// hasGroupQuorum returns true when s is a running member reaching a majority of the group
+func (t *Topology) hasGroupQuorum(s *Server) bool {
+	if s.down || !s.groupOnline {
+		return false
+	}
+	members := t.getGroupMembers()
+	online := 0
+	for _, m := range members {
+		if t.getGroupMemberState(s, m) == "ONLINE" {
+			online++
+		}
+	}
+	return online*2 > len(members)
+}
+
+// electGroupPrimary replaces a primary that left the majority by the member of highest weight and lowest
+// uuid of the majority, there is no primary without a majority
+func (t *Topology) electGroupPrimary() {
+	if t.primary != nil && t.hasGroupQuorum(t.primary) {
+		return
+	}
+	var elected *Server
+	for _, s := range t.getGroupMembers() {
+		if !t.hasGroupQuorum(s) {
+			continue
+		}
+		w, _ := strconv.Atoi(s.variables["GROUP_REPLICATION_MEMBER_WEIGHT"])
+		if elected == nil {
+			elected = s
+			continue
+		}
+		ew, _ := strconv.Atoi(elected.variables["GROUP_REPLICATION_MEMBER_WEIGHT"])
+		if w > ew || (w == ew && s.GetUUID() < elected.GetUUID()) {
+			elected = s
+		}
+	}
+	t.setGroupPrimary(elected)
+}
+
+// setGroupPrimary moves the primary role, secondaries are super read only
+func (t *Topology) setGroupPrimary(p *Server) {
+	t.primary = p
+	for _, s := range t.getGroupMembers() {
+		s.variables["READ_ONLY"] = onOff(s != p)
+		s.variables["SUPER_READ_ONLY"] = onOff(s != p)
+	}
+}
+
+// replicateGroup applies the transactions of the primary on the members it reaches
+func (t *Topology) replicateGroup() {
+	t.electGroupPrimary()
+	p := t.primary
+	if p == nil {
+		return
+	}
+	for _, s := range t.getGroupMembers() {
+		if s != p && t.getGroupMemberState(s, p) == "ONLINE" && t.hasGroupQuorum(s) && !p.mysqlSet.IsSubsetOf(s.mysqlSet) {
+			s.mysqlSet = s.mysqlSet.Union(p.mysqlSet)
+			s.binlogPos += 100
+		}
+	}
+}
+
+// getGroupMemberRows returns performance_schema.replication_group_members as seen by the server
+func (s *Server) getGroupMemberRows() [][]interface{} {
+	if !s.groupOnline {
+		return [][]interface{}{{"group_replication_applier", s.GetUUID(), s.Host, s.Port, "OFFLINE", "", s.Version}}
+	}
+	var rows [][]interface{}
+	for _, m := range s.topo.getGroupMembers() {
+		role := "SECONDARY"
+		if m == s.topo.primary {
+			role = "PRIMARY"
+		}
+		rows = append(rows, []interface{}{"group_replication_applier", m.GetUUID(), m.Host, m.Port, s.topo.getGroupMemberState(s, m), role, m.Version})
+	}
+	return rows
+}
+
+// setGroupPrimaryByUUID runs group_replication_set_as_primary on the server
+func (s *Server) setGroupPrimaryByUUID(uuid string) error {
+	if !s.topo.hasGroupQuorum(s) {
+		return NewError(ER_UNKNOWN_ERROR, "The group_replication_set_as_primary UDF requires a member in the majority of the group")
+	}
+	for _, m := range s.topo.getGroupMembers() {
+		if m.GetUUID() == uuid && s.topo.hasGroupQuorum(m) {
+			s.topo.setGroupPrimary(m)
+			return nil
+		}
+	}
+	return NewError(ER_UNKNOWN_ERROR, "The requested member for primary election is not part of the group")
+}
@@ -0,0 +1,436 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package mysqlsim
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/siddontang/go-mysql/mysql"
+	"github.com/signal18/replication-manager/utils/gtid"
+)
+
+var (
+	reSpaces      = regexp.MustCompile(`\s+`)
+	reSetGlobal   = regexp.MustCompile(`(?i)^SET\s+(?:GLOBAL\s+|@@GLOBAL\.)(.+)$`)
+	reChange      = regexp.MustCompile(`(?i)^CHANGE\s+MASTER\s*(?:'[^']*')?\s*TO\s+(.+)$`)
+	reAssign      = regexp.MustCompile(`(?i)(\w+)\s*=\s*('(?:[^']*)'|[^,\s]+)`)
+	reLike        = regexp.MustCompile(`(?i)LIKE\s+'([^']*)'`)
+	reVarName     = regexp.MustCompile(`(?i)VARIABLE_NAME\s*=\s*'([^']*)'`)
+	reSelectVars  = regexp.MustCompile(`(?i)^SELECT\s+(@@[\w.]+(?:\s*,\s*@@[\w.]+)*)$`)
+	reSecondsWait = regexp.MustCompile(`(?i)^SELECT\s+(MASTER_GTID_WAIT|MASTER_POS_WAIT)\s*\(`)
+	reSetPrimary  = regexp.MustCompile(`(?i)^SELECT\s+group_replication_set_as_primary\s*\(\s*'([^']*)'`)
+)
+
+// handler serves a client connection of a simulated server
+type handler struct {
+	srv *Server
+}
+
+func (h *handler) UseDB(dbName string) error {
+	return nil
+}
+
+func (h *handler) HandleQuery(query string) (*Result, error) {
+	return h.handle(query, false)
+}
+
+func (h *handler) handle(query string, binary bool) (*Result, error) {
+	t := h.srv.topo
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h.srv.down || h.srv.isolated {
+		return nil, NewError(ER_SERVER_SHUTDOWN, "Server shutdown in progress")
+	}
+	t.replicate()
+	res, err := h.srv.execute(query, binary)
+	// statements changing the topology are applied right away
+	t.replicate()
+	return res, err
+}
+
+func (h *handler) HandleFieldList(table string, fieldWildcard string) ([]*Field, error) {
+	return nil, nil
+}
+
+func (h *handler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return strings.Count(query, "?"), 0, query, nil
+}
+
+// HandleStmtExecute runs a prepared statement as a text query with the parameters inlined
+func (h *handler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*Result, error) {
+	var sb strings.Builder
+	i := 0
+	for _, c := range query {
+		if c != '?' || i >= len(args) {
+			sb.WriteRune(c)
+			continue
+		}
+		switch v := args[i].(type) {
+		case nil:
+			sb.WriteString("NULL")
+		case []byte:
+			sb.WriteString("'" + strings.Replace(string(v), "'", "''", -1) + "'")
+		case string:
+			sb.WriteString("'" + strings.Replace(v, "'", "''", -1) + "'")
+		default:
+			sb.WriteString(fmt.Sprintf("%v", v))
+		}
+		i++
+	}
+	return h.handle(sb.String(), true)
+}
+
+func (h *handler) HandleStmtClose(context interface{}) error {
+	return nil
+}
+
+func (h *handler) HandleOtherCommand(cmd byte, data []byte) error {
+	return NewError(ER_UNKNOWN_ERROR, fmt.Sprintf("command %d is not supported", cmd))
+}
+
+func resultset(binary bool, names []string, rows ...[]interface{}) (*Result, error) {
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+	// the protocol helper encodes empty strings as NULL
+	for _, row := range rows {
+		for i, v := range row {
+			if v == "" {
+				row[i] = []byte{}
+			}
+		}
+	}
+	rs, err := BuildSimpleResultset(names, rows, binary)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Resultset: rs}, nil
+}
+
+// execute runs a statement with the topology lock held
+func (s *Server) execute(query string, binary bool) (*Result, error) {
+	q := strings.TrimRight(strings.TrimSpace(reSpaces.ReplaceAllString(query, " ")), "; ")
+	uq := strings.ToUpper(q)
+	if !strings.HasPrefix(uq, "SELECT") && !strings.HasPrefix(uq, "SHOW") {
+		s.Queries = append(s.Queries, q)
+	}
+	switch {
+	case uq == "SELECT VERSION()":
+		return resultset(binary, []string{"version()"}, []interface{}{s.Version})
+	case uq == "SELECT USER()":
+		return resultset(binary, []string{"user()"}, []interface{}{s.topo.User + "@127.0.0.1"})
+	case reSelectVars.MatchString(q):
+		var names []string
+		var row []interface{}
+		for _, v := range strings.Split(reSelectVars.FindStringSubmatch(q)[1], ",") {
+			v = strings.TrimSpace(v)
+			names = append(names, v)
+			name := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(strings.TrimPrefix(v, "@@")), "GLOBAL."), "SESSION.")
+			if name == "VERSION_COMMENT" {
+				row = append(row, s.getVersionComment())
+				continue
+			}
+			row = append(row, s.getVariable(name))
+		}
+		return resultset(binary, names, row)
+	case reSecondsWait.MatchString(q):
+		return resultset(binary, []string{"result"}, []interface{}{int64(0)})
+	case strings.Contains(uq, "GLOBAL_VARIABLES"):
+		if m := reVarName.FindStringSubmatch(q); m != nil {
+			return resultset(binary, []string{"Value"}, []interface{}{strings.ToUpper(s.getVariable(m[1]))})
+		}
+		return resultset(binary, []string{"variable_name", "value"}, s.getVariableRows("")...)
+	case strings.Contains(uq, "GLOBAL_STATUS"):
+		return resultset(binary, []string{"variable_name", "value"}, s.getStatusRows("")...)
+	case strings.HasPrefix(uq, "SHOW GLOBAL VARIABLES"), strings.HasPrefix(uq, "SHOW VARIABLES"), strings.HasPrefix(uq, "SHOW SESSION VARIABLES"):
+		return resultset(binary, []string{"Variable_name", "Value"}, s.getVariableRows(getLike(q))...)
+	case strings.HasPrefix(uq, "SHOW GLOBAL STATUS"), strings.HasPrefix(uq, "SHOW STATUS"):
+		return resultset(binary, []string{"Variable_name", "Value"}, s.getStatusRows(getLike(q))...)
+	case strings.HasPrefix(uq, "SHOW ALL SLAVES STATUS"), strings.HasPrefix(uq, "SHOW SLAVE STATUS"), strings.HasPrefix(uq, "SHOW REPLICA STATUS"):
+		return s.getSlaveStatus(binary)
+	case uq == "SHOW MASTER STATUS":
+		names := []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB"}
+		row := []interface{}{s.binlogFile(), s.binlogPos, "", ""}
+		if s.Flavor == FlavorMySQL {
+			names = append(names, "Executed_Gtid_Set")
+			row = append(row, s.mysqlSet.String())
+		}
+		return resultset(binary, names, row)
+	case uq == "SHOW BINARY LOGS":
+		var rows [][]interface{}
+		for i := 1; i <= s.binlogNum; i++ {
+			rows = append(rows, []interface{}{fmt.Sprintf("mysql-bin.%06d", i), s.binlogPos})
+		}
+		return resultset(binary, []string{"Log_name", "File_size"}, rows...)
+	case uq == "SHOW SLAVE HOSTS":
+		var rows [][]interface{}
+		for _, sl := range s.topo.Servers {
+			if s.topo.getMaster(sl) == s {
+				rows = append(rows, []interface{}{sl.ServerId, sl.Host, sl.Port, s.ServerId})
+			}
+		}
+		return resultset(binary, []string{"Server_id", "Host", "Port", "Master_id"}, rows...)
+	case strings.Contains(uq, "FROM MYSQL.USER WHERE USER"):
+		return resultset(binary, []string{"Select_priv", "Process_priv", "Super_priv", "Repl_slave_priv", "Repl_client_priv", "Reload_priv"}, []interface{}{"Y", "Y", "Y", "Y", "Y", "Y"})
+	case strings.Contains(uq, "PROCESSLIST WHERE COMMAND LIKE 'BINLOG DUMP%'"):
+		n := int64(0)
+		for _, sl := range s.topo.Servers {
+			if s.topo.getMaster(sl) == s {
+				n++
+			}
+		}
+		return resultset(binary, []string{"n"}, []interface{}{n})
+	case strings.Contains(uq, "REPLICATION_GROUP_MEMBERS"):
+		return resultset(binary, []string{"CHANNEL_NAME", "MEMBER_ID", "MEMBER_HOST", "MEMBER_PORT", "MEMBER_STATE", "MEMBER_ROLE", "MEMBER_VERSION"}, s.getGroupMemberRows()...)
+	case reSetPrimary.MatchString(q):
+		s.Queries = append(s.Queries, q)
+		if err := s.setGroupPrimaryByUUID(reSetPrimary.FindStringSubmatch(q)[1]); err != nil {
+			return nil, err
+		}
+		return resultset(binary, []string{"result"}, []interface{}{"Primary server switched to: " + reSetPrimary.FindStringSubmatch(q)[1]})
+	case strings.HasPrefix(uq, "SELECT"), strings.HasPrefix(uq, "SHOW"):
+		// everything else is monitored as an empty result
+		return nil, nil
+	case reSetGlobal.MatchString(q):
+		for _, a := range reAssign.FindAllStringSubmatch(reSetGlobal.FindStringSubmatch(q)[1], -1) {
+			s.setGlobal(a[1], strings.Trim(a[2], "'"))
+		}
+		return nil, nil
+	case strings.HasPrefix(uq, "CHANGE MASTER"):
+		return nil, s.changeMaster(q)
+	case strings.HasPrefix(uq, "STOP SLAVE"), strings.HasPrefix(uq, "STOP ALL SLAVES"), strings.HasPrefix(uq, "STOP REPLICA"):
+		if s.rpl != nil {
+			s.rpl.IORunning = s.rpl.IORunning && strings.Contains(uq, "SQL_THREAD")
+			s.rpl.SQLRunning = s.rpl.SQLRunning && strings.Contains(uq, "IO_THREAD")
+		}
+		return nil, nil
+	case strings.HasPrefix(uq, "START SLAVE"), strings.HasPrefix(uq, "START ALL SLAVES"), strings.HasPrefix(uq, "START REPLICA"):
+		if s.rpl == nil {
+			return nil, NewError(ER_BAD_SLAVE, "Misconfigured slave: MASTER_HOST was not set")
+		}
+		s.rpl.IORunning = s.rpl.IORunning || !strings.Contains(uq, "SQL_THREAD")
+		s.rpl.SQLRunning = s.rpl.SQLRunning || !strings.Contains(uq, "IO_THREAD")
+		return nil, nil
+	case strings.HasPrefix(uq, "RESET SLAVE"), strings.HasPrefix(uq, "RESET REPLICA"):
+		if s.rpl != nil && (s.rpl.IORunning || s.rpl.SQLRunning) {
+			return nil, NewError(ER_SLAVE_MUST_STOP, "This operation cannot be performed with a running slave")
+		}
+		if strings.Contains(uq, "ALL") {
+			s.rpl = nil
+		}
+		return nil, nil
+	case strings.HasPrefix(uq, "RESET MASTER"):
+		s.mdbBinlog = make(map[uint64]gtid.Gtid)
+		s.mysqlSet = make(gtid.MySQLSet)
+		s.binlogNum, s.binlogPos = 1, 4
+		return nil, nil
+	case strings.HasPrefix(uq, "FLUSH") && strings.HasSuffix(uq, "LOGS"):
+		s.binlogNum++
+		s.binlogPos = 4
+		return nil, nil
+	case uq == "START GROUP_REPLICATION":
+		if s.variables["GROUP_REPLICATION_GROUP_NAME"] == "" {
+			return nil, NewError(ER_UNKNOWN_ERROR, "The group_replication_group_name option is mandatory")
+		}
+		s.groupOnline = true
+		return nil, nil
+	case uq == "STOP GROUP_REPLICATION":
+		s.groupOnline = false
+		if s.topo.primary == s {
+			s.topo.primary = nil
+		}
+		s.variables["READ_ONLY"], s.variables["SUPER_READ_ONLY"] = "ON", "ON"
+		return nil, nil
+	case uq == "SHUTDOWN":
+		s.down = true
+		s.closeNetwork()
+		return nil, nil
+	}
+	for _, w := range []string{"INSERT", "UPDATE", "DELETE", "REPLACE", "CREATE", "DROP", "ALTER", "TRUNCATE"} {
+		if strings.HasPrefix(uq, w) {
+			s.commit()
+			return &Result{AffectedRows: 1}, nil
+		}
+	}
+	// SET SESSION, FLUSH TABLES, UNLOCK TABLES, KILL ...
+	return nil, nil
+}
+
+func (s *Server) getVersionComment() string {
+	if s.Flavor == FlavorMySQL {
+		return "MySQL Community Server - GPL"
+	}
+	return "mariadb.org binary distribution"
+}
+
+func getLike(q string) string {
+	if m := reLike.FindStringSubmatch(q); m != nil {
+		return strings.ToUpper(m[1])
+	}
+	return ""
+}
+
+func (s *Server) getVariableRows(like string) [][]interface{} {
+	vars := make(map[string]string)
+	for k, v := range s.variables {
+		vars[k] = v
+	}
+	if s.Flavor == FlavorMariaDB {
+		for _, k := range []string{"GTID_BINLOG_POS", "GTID_CURRENT_POS", "GTID_SLAVE_POS"} {
+			vars[k] = s.getVariable(k)
+		}
+	} else {
+		vars["GTID_EXECUTED"] = s.getVariable("GTID_EXECUTED")
+	}
+	return getRows(vars, like)
+}
+
+func (s *Server) getStatusRows(like string) [][]interface{} {
+	return getRows(s.status, like)
+}
+
+func getRows(m map[string]string, like string) [][]interface{} {
+	var keys []string
+	for k := range m {
+		if like == "" || k == like || (strings.HasSuffix(like, "%") && strings.HasPrefix(k, strings.TrimSuffix(like, "%"))) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var rows [][]interface{}
+	for _, k := range keys {
+		rows = append(rows, []interface{}{k, strings.ToUpper(m[k])})
+	}
+	return rows
+}
+
+func (s *Server) setGlobal(name string, value string) {
+	name = strings.ToUpper(name)
+	switch name {
+	case "GTID_SLAVE_POS":
+		s.mdbSlave = make(map[uint64]gtid.Gtid)
+		for _, g := range strings.Split(value, ",") {
+			if f := strings.Split(strings.TrimSpace(g), "-"); len(f) == 3 {
+				d, _ := strconv.ParseUint(f[0], 10, 64)
+				sid, _ := strconv.ParseUint(f[1], 10, 64)
+				seq, _ := strconv.ParseUint(f[2], 10, 64)
+				s.mdbSlave[d] = gtid.Gtid{DomainID: d, ServerID: sid, SeqNo: seq}
+			}
+		}
+		return
+	}
+	cur, ok := s.variables[name]
+	if ok && (cur == "ON" || cur == "OFF") {
+		switch value {
+		case "1", "on", "ON", "true":
+			value = "ON"
+		case "0", "off", "OFF", "false":
+			value = "OFF"
+		}
+	}
+	s.variables[name] = strings.ToUpper(value)
+}
+
+func (s *Server) changeMaster(q string) error {
+	m := reChange.FindStringSubmatch(q)
+	if m == nil {
+		return NewError(ER_PARSE_ERROR, "You have an error in your SQL syntax near "+q)
+	}
+	if s.rpl != nil && (s.rpl.IORunning || s.rpl.SQLRunning) {
+		return NewError(ER_SLAVE_MUST_STOP, "This operation cannot be performed with a running slave")
+	}
+	if s.rpl == nil {
+		s.rpl = &Replication{UseGtid: "No"}
+	}
+	for _, a := range reAssign.FindAllStringSubmatch(m[1], -1) {
+		v := strings.Trim(a[2], "'")
+		switch strings.ToUpper(a[1]) {
+		case "MASTER_HOST":
+			s.rpl.Host = v
+		case "MASTER_PORT":
+			s.rpl.Port = v
+		case "MASTER_USER":
+			s.rpl.User = v
+		case "MASTER_USE_GTID":
+			switch strings.ToUpper(v) {
+			case "SLAVE_POS":
+				s.rpl.UseGtid = "Slave_Pos"
+			case "CURRENT_POS":
+				s.rpl.UseGtid = "Current_Pos"
+			default:
+				s.rpl.UseGtid = "No"
+			}
+		case "MASTER_AUTO_POSITION":
+			if v == "1" {
+				s.rpl.UseGtid = "Auto_Position"
+			}
+		case "MASTER_LOG_FILE":
+			s.rpl.File = v
+		case "MASTER_LOG_POS":
+			s.rpl.Pos, _ = strconv.ParseUint(v, 10, 64)
+		case "MASTER_DELAY":
+			s.rpl.Delay, _ = strconv.ParseInt(v, 10, 64)
+		}
+	}
+	return nil
+}
+
+func (s *Server) getSlaveStatus(binary bool) (*Result, error) {
+	names := []string{"Connection_name", "Slave_SQL_State", "Slave_IO_State", "Master_Host", "Master_User", "Master_Port", "Connect_Retry",
+		"Master_Log_File", "Read_Master_Log_Pos", "Relay_Log_File", "Relay_Log_Pos", "Relay_Master_Log_File", "Slave_IO_Running", "Slave_SQL_Running",
+		"Last_Errno", "Last_Error", "Skip_Counter", "Exec_Master_Log_Pos", "Seconds_Behind_Master", "Last_IO_Errno", "Last_IO_Error",
+		"Last_SQL_Errno", "Last_SQL_Error", "Master_Server_Id", "Using_Gtid", "Gtid_IO_Pos", "Gtid_Slave_Pos", "Slave_Heartbeat_Period",
+		"Executed_Gtid_Set", "Retrieved_Gtid_Set", "Slave_SQL_Running_State", "Auto_Position"}
+	if s.rpl == nil {
+		return resultset(binary, names)
+	}
+	r := s.rpl
+	m := s.topo.getMaster(s)
+	io, ioErrno, ioError := "No", "0", ""
+	if r.IORunning {
+		io = "Yes"
+		if m == nil {
+			io, ioErrno, ioError = "Connecting", "2003", "error reconnecting to master '"+s.topo.User+"@"+r.Host+":"+r.Port+"' - retry-time: 60  maximum-retries: 86400  message: Can't connect to MySQL server on '"+r.Host+"' (111 \"Connection refused\")"
+		}
+	}
+	var delay interface{}
+	if io == "Yes" && r.SQLRunning {
+		delay = r.Delay
+	}
+	masterId := uint64(0)
+	if m != nil {
+		masterId = m.ServerId
+	}
+	autoPos := "0"
+	if r.UseGtid == "Auto_Position" {
+		autoPos = "1"
+	}
+	row := []interface{}{"", "", "", r.Host, r.User, r.Port, "60",
+		r.File, r.Pos, "relay-bin.000001", r.Pos, r.File, io, yesNo(r.SQLRunning),
+		"0", "", "0", r.Pos, delay, ioErrno, ioError,
+		"0", "", masterId, r.UseGtid, s.getMariaDBGtid(s.mdbSlave), s.getMariaDBGtid(s.mdbSlave), "3.000",
+		s.mysqlSet.String(), s.mysqlSet.String(), "Slave has read all relay log; waiting for more updates", autoPos}
+	return resultset(binary, names, row)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,136 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package mysqlsim
+
+import (
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+func connect(t *testing.T, s *Server) *sqlx.DB {
+	db, err := sqlx.Connect("mysql", "root:secret@tcp("+s.Addr()+")/?timeout=1s")
+	if err != nil {
+		t.Fatalf("Connect %s: %s", s.Addr(), err)
+	}
+	return db
+}
+
+func TestMariaDBReplication(t *testing.T) {
+	topo, err := NewMasterSlaves(FlavorMariaDB, "root", "secret", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topo.Close()
+	master, slave := topo.Servers[0], topo.Servers[1]
+	mdb := connect(t, master)
+	defer mdb.Close()
+	sdb := connect(t, slave)
+	defer sdb.Close()
+
+	version, _, err := dbhelper.GetDBVersion(mdb)
+	if err != nil || !version.IsMariaDB() {
+		t.Fatalf("Expected a MariaDB version got %v %s", version, err)
+	}
+	if _, err := mdb.Exec("INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	master.Write(2)
+	vars, _, err := dbhelper.GetVariables(sdb, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["GTID_CURRENT_POS"] != "0-1-3" || vars["GTID_SLAVE_POS"] != "0-1-3" || vars["READ_ONLY"] != "ON" {
+		t.Fatalf("Unexpected slave variables current %s slave %s read_only %s", vars["GTID_CURRENT_POS"], vars["GTID_SLAVE_POS"], vars["READ_ONLY"])
+	}
+	ss, _, err := dbhelper.GetAllSlavesStatus(sdb, version)
+	if err != nil || len(ss) != 1 {
+		t.Fatalf("Expected one replication source got %d %s", len(ss), err)
+	}
+	if ss[0].SlaveIORunning.String != "Yes" || ss[0].MasterPort.String != master.Port || ss[0].MasterServerID != 1 {
+		t.Fatalf("Unexpected slave status %+v", ss[0])
+	}
+
+	master.Stop()
+	ss, _, _ = dbhelper.GetAllSlavesStatus(sdb, version)
+	if ss[0].SlaveIORunning.String != "Connecting" || ss[0].LastIOErrno.String != "2003" || ss[0].SecondsBehindMaster.Valid {
+		t.Fatalf("Expected slave to lose its master got %+v", ss[0])
+	}
+	if err := mdb.Ping(); err == nil {
+		t.Fatal("Expected stopped master to be unreachable")
+	}
+}
+
+func TestChangeMaster(t *testing.T) {
+	topo, err := NewMasterSlaves(FlavorMySQL, "root", "secret", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topo.Close()
+	master, s1, s2 := topo.Servers[0], topo.Servers[1], topo.Servers[2]
+	master.Write(5)
+	topo.Partition(master, s2)
+	master.Write(1)
+	if s1.GetGtid() == s2.GetGtid() {
+		t.Fatalf("Expected partitioned slave to be late got %s", s2.GetGtid())
+	}
+	db := connect(t, s2)
+	defer db.Close()
+	for _, q := range []string{"STOP SLAVE", "CHANGE MASTER TO master_host='" + s1.Host + "', master_port=" + s1.Port + ", master_user='root', MASTER_AUTO_POSITION=1", "START SLAVE"} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %s", q, err)
+		}
+	}
+	topo.Sync()
+	if s2.GetGtid() != master.GetGtid() || s2.GetReplication().Port != s1.Port {
+		t.Fatalf("Expected slave to catch up from the relay got %s expected %s", s2.GetGtid(), master.GetGtid())
+	}
+	if _, err := db.Exec("RESET SLAVE ALL"); err == nil {
+		t.Fatal("Expected RESET SLAVE ALL to fail with running threads")
+	}
+}
+
+func TestGroupReplication(t *testing.T) {
+	topo, err := NewGroup("root", "secret", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topo.Close()
+	primary, s1, s2 := topo.Servers[0], topo.Servers[1], topo.Servers[2]
+	db := connect(t, s1)
+	defer db.Close()
+	primary.Write(2)
+	members, _, err := dbhelper.GetGroupReplicationMembers(db)
+	if err != nil || len(members) != 3 {
+		t.Fatalf("Expected 3 members got %d %s", len(members), err)
+	}
+	if members[0].MemberRole != "PRIMARY" || members[1].MemberState != "ONLINE" || s1.GetGtid() != primary.GetGtid() {
+		t.Fatalf("Unexpected group view %+v", members)
+	}
+	if _, err := dbhelper.SetGroupReplicationPrimary(db, s2.GetUUID()); err != nil {
+		t.Fatal(err)
+	}
+	if topo.GetGroupPrimary() != s2 || primary.GetVariable("SUPER_READ_ONLY") != "ON" {
+		t.Fatal("Expected the primary role to move")
+	}
+
+	// a member alone is a minority
+	s2.Stop()
+	topo.Partition(primary, s1)
+	members, _, _ = dbhelper.GetGroupReplicationMembers(db)
+	if topo.GetGroupPrimary() != nil || members[0].MemberState != "UNREACHABLE" || members[2].MemberState != "UNREACHABLE" {
+		t.Fatalf("Expected the group to lose its majority got %+v", members)
+	}
+	if _, err := dbhelper.SetGroupReplicationPrimary(db, s1.GetUUID()); err == nil {
+		t.Fatal("Expected set as primary to fail in a minority")
+	}
+}
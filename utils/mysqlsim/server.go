@@ -0,0 +1,363 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package mysqlsim
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	siddonmysql "github.com/siddontang/go-mysql/mysql"
+	siddon "github.com/siddontang/go-mysql/server"
+	"github.com/signal18/replication-manager/utils/gtid"
+)
+
+const (
+	FlavorMariaDB = "mariadb"
+	FlavorMySQL   = "mysql"
+)
+
+// Replication defines the replication source of a simulated server
+type Replication struct {
+	Host       string
+	Port       string
+	User       string
+	UseGtid    string
+	IORunning  bool
+	SQLRunning bool
+	// Delay is the reported lag in seconds, a delayed slave does not apply events
+	Delay int64
+	// file and position of the source applied by the slave
+	File string
+	Pos  uint64
+}
+
+// Server defines a simulated MariaDB or MySQL server, all state is protected by the topology lock
+type Server struct {
+	Flavor   string
+	ServerId uint64
+	Host     string
+	Port     string
+	Version  string
+
+	topo      *Topology
+	listener  net.Listener
+	conns     map[net.Conn]bool
+	variables map[string]string
+	status    map[string]string
+	// executed transactions, MariaDB keeps the last GTID of each domain
+	mdbBinlog map[uint64]gtid.Gtid
+	mdbSlave  map[uint64]gtid.Gtid
+	mysqlSet  gtid.MySQLSet
+	binlogNum int
+	binlogPos uint64
+	rpl       *Replication
+	down      bool
+	isolated  bool
+	// group replication is started on the member
+	groupOnline bool
+	// Queries logs the statements received that are not monitoring reads
+	Queries []string
+}
+
+func newServer(topo *Topology, flavor string, serverId uint64) *Server {
+	s := &Server{
+		Flavor:    flavor,
+		ServerId:  serverId,
+		topo:      topo,
+		conns:     make(map[net.Conn]bool),
+		mdbBinlog: make(map[uint64]gtid.Gtid),
+		mdbSlave:  make(map[uint64]gtid.Gtid),
+		mysqlSet:  make(gtid.MySQLSet),
+		binlogNum: 1,
+		binlogPos: 4,
+	}
+	s.Version = "10.5.9-MariaDB-log"
+	if flavor == FlavorMySQL {
+		s.Version = "8.0.25-log"
+	}
+	s.variables = map[string]string{
+		"SERVER_ID":                      strconv.FormatUint(serverId, 10),
+		"VERSION":                        s.Version,
+		"READ_ONLY":                      "OFF",
+		"LOG_BIN":                        "ON",
+		"LOG_SLAVE_UPDATES":              "ON",
+		"BINLOG_FORMAT":                  "ROW",
+		"BINLOG_ROW_IMAGE":               "FULL",
+		"SYNC_BINLOG":                    "1",
+		"INNODB_FLUSH_LOG_AT_TRX_COMMIT": "1",
+		"INNODB_CHECKSUM_ALGORITHM":      "CRC32",
+		"GTID_STRICT_MODE":               "ON",
+		"GTID_DOMAIN_ID":                 "0",
+		"LOG_OUTPUT":                     "FILE",
+		"SLOW_QUERY_LOG":                 "OFF",
+		"LONG_QUERY_TIME":                "10.000000",
+		"EVENT_SCHEDULER":                "OFF",
+		"MAX_CONNECTIONS":                "151",
+		"RELAY_LOG_SPACE_LIMIT":          "0",
+		"PERFORMANCE_SCHEMA":             "OFF",
+		"RPL_SEMI_SYNC_MASTER_ENABLED":   "OFF",
+		"RPL_SEMI_SYNC_SLAVE_ENABLED":    "OFF",
+		"HOSTNAME":                       "localhost",
+		"DATADIR":                        "/var/lib/mysql/",
+	}
+	if flavor == FlavorMySQL {
+		s.variables["GTID_MODE"] = "ON"
+		s.variables["ENFORCE_GTID_CONSISTENCY"] = "ON"
+		s.variables["SERVER_UUID"] = s.GetUUID()
+		s.variables["SUPER_READ_ONLY"] = "OFF"
+	}
+	s.status = map[string]string{
+		"UPTIME":                        "3600",
+		"THREADS_CONNECTED":             "1",
+		"THREADS_RUNNING":               "1",
+		"COM_SELECT":                    "0",
+		"QUESTIONS":                     "0",
+		"RPL_SEMI_SYNC_MASTER_STATUS":   "OFF",
+		"RPL_SEMI_SYNC_SLAVE_STATUS":    "OFF",
+		"RPL_SEMI_SYNC_MASTER_CLIENTS":  "0",
+		"WSREP_LOCAL_STATE":             "",
+		"INNODB_BUFFER_POOL_PAGES_DATA": "0",
+	}
+	return s
+}
+
+// GetUUID returns the source uuid of the transactions written on a MySQL server
+func (s *Server) GetUUID() string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.ServerId)
+}
+
+// Addr returns host:port of the server
+func (s *Server) Addr() string {
+	return s.Host + ":" + s.Port
+}
+
+func (s *Server) listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	s.Host, s.Port, _ = net.SplitHostPort(l.Addr().String())
+	go s.accept(l)
+	return nil
+}
+
+func (s *Server) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		s.topo.mu.Lock()
+		s.conns[conn] = true
+		s.topo.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.topo.mu.Lock()
+		delete(s.conns, conn)
+		s.topo.mu.Unlock()
+	}()
+	p := siddon.NewInMemoryProvider()
+	p.AddUser(s.topo.User, s.topo.Password)
+	c, err := siddon.NewCustomizedConn(conn, siddon.NewServer(s.Version, siddonmysql.DEFAULT_COLLATION_ID, siddonmysql.AUTH_NATIVE_PASSWORD, nil, nil), p, &handler{srv: s})
+	if err != nil {
+		return
+	}
+	for {
+		if err := c.HandleCommand(); err != nil {
+			return
+		}
+	}
+}
+
+// closeNetwork stops accepting new connections and drops the existing ones
+func (s *Server) closeNetwork() {
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Stop simulates a crash of the server, it is unreachable and stops replicating
+func (s *Server) Stop() {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	s.down = true
+	s.closeNetwork()
+}
+
+// Start restarts a stopped or isolated server on the same port, replication threads are stopped
+// like after a crash without skip-slave-start
+func (s *Server) Start() error {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	if s.down && s.rpl != nil {
+		s.rpl.IORunning = false
+		s.rpl.SQLRunning = false
+	}
+	// a crashed member rejoins the group on START GROUP_REPLICATION
+	if s.down {
+		s.groupOnline = false
+	}
+	s.down = false
+	s.isolated = false
+	if s.listener != nil {
+		return nil
+	}
+	return s.listen(s.Addr())
+}
+
+// Isolate makes the server unreachable to clients while it continues to replicate, to simulate
+// a network partition between the monitor and the server
+func (s *Server) Isolate() {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	s.isolated = true
+	s.closeNetwork()
+}
+
+// IsRunning returns false when the server is stopped
+func (s *Server) IsRunning() bool {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	return !s.down
+}
+
+// Write commits n transactions on the server and replicates them
+func (s *Server) Write(n int) {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.commit()
+	}
+	s.topo.replicate()
+}
+
+func (s *Server) commit() {
+	if s.Flavor == FlavorMariaDB {
+		domain, _ := strconv.ParseUint(s.variables["GTID_DOMAIN_ID"], 10, 64)
+		g := s.mdbBinlog[domain]
+		s.mdbBinlog[domain] = gtid.Gtid{DomainID: domain, ServerID: s.ServerId, SeqNo: g.SeqNo + 1}
+	} else {
+		uuid := strings.ToLower(s.GetUUID())
+		s.mysqlSet = s.mysqlSet.Union(gtid.MySQLSet{uuid: []gtid.Interval{{Start: s.mysqlSet.GetLastSeqNo(uuid) + 1, End: s.mysqlSet.GetLastSeqNo(uuid) + 1}}})
+	}
+	s.binlogPos += 100
+}
+
+// SetVariable changes a global variable
+func (s *Server) SetVariable(name string, value string) {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	s.variables[strings.ToUpper(name)] = strings.ToUpper(value)
+}
+
+// GetVariable returns a global variable
+func (s *Server) GetVariable(name string) string {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	return s.getVariable(name)
+}
+
+func (s *Server) getVariable(name string) string {
+	switch strings.ToUpper(name) {
+	case "GTID_BINLOG_POS", "GTID_CURRENT_POS":
+		return s.getMariaDBGtid(s.mdbBinlog)
+	case "GTID_SLAVE_POS":
+		return s.getMariaDBGtid(s.mdbSlave)
+	case "GTID_EXECUTED":
+		return s.mysqlSet.String()
+	}
+	return s.variables[strings.ToUpper(name)]
+}
+
+func (s *Server) getMariaDBGtid(m map[uint64]gtid.Gtid) string {
+	var domains []uint64
+	for d := range m {
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i] < domains[j] })
+	var l []string
+	for _, d := range domains {
+		g := m[d]
+		l = append(l, fmt.Sprintf("%d-%d-%d", g.DomainID, g.ServerID, g.SeqNo))
+	}
+	return strings.Join(l, ",")
+}
+
+// SetSemiSync enables semi-synchronous replication as master and as slave
+func (s *Server) SetSemiSync(master bool, slave bool) {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	s.variables["RPL_SEMI_SYNC_MASTER_ENABLED"] = onOff(master)
+	s.variables["RPL_SEMI_SYNC_SLAVE_ENABLED"] = onOff(slave)
+}
+
+// SetReplicationDelay freezes the slave and reports seconds of delay, 0 resumes apply
+func (s *Server) SetReplicationDelay(seconds int64) {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	if s.rpl != nil {
+		s.rpl.Delay = seconds
+	}
+}
+
+// GetReplication returns a copy of the replication source, nil when the server is not a slave
+func (s *Server) GetReplication() *Replication {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	if s.rpl == nil {
+		return nil
+	}
+	r := *s.rpl
+	return &r
+}
+
+// GetGtid returns the executed GTIDs as gtid_current_pos or gtid_executed
+func (s *Server) GetGtid() string {
+	if s.Flavor == FlavorMariaDB {
+		return s.GetVariable("GTID_CURRENT_POS")
+	}
+	return s.GetVariable("GTID_EXECUTED")
+}
+
+// ChangeMaster points the server to a master with replication threads running, to build
+// the initial topology
+func (s *Server) ChangeMaster(master *Server) {
+	s.topo.mu.Lock()
+	defer s.topo.mu.Unlock()
+	s.rpl = &Replication{Host: master.Host, Port: master.Port, User: s.topo.User, UseGtid: "Slave_Pos", IORunning: true, SQLRunning: true}
+	if s.Flavor == FlavorMySQL {
+		s.rpl.UseGtid = "No"
+	}
+	s.variables["READ_ONLY"] = "ON"
+}
+
+func (s *Server) binlogFile() string {
+	return fmt.Sprintf("mysql-bin.%06d", s.binlogNum)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
@@ -0,0 +1,173 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package mysqlsim simulates MariaDB and MySQL servers in process over the MySQL protocol,
+// with enough of the replication state to drive the monitor in unit tests
+package mysqlsim
+
+import (
+	"sync"
+)
+
+// Topology defines a set of simulated servers sharing a network, replication is applied
+// synchronously before each statement so tests are deterministic
+type Topology struct {
+	User     string
+	Password string
+	Servers  []*Server
+
+	mu   sync.Mutex
+	cuts map[[2]*Server]bool
+	// primary of the group replication members
+	primary *Server
+}
+
+// NewTopology returns an empty topology, clients authenticate with user and password
+func NewTopology(user string, password string) *Topology {
+	return &Topology{
+		User:     user,
+		Password: password,
+		cuts:     make(map[[2]*Server]bool),
+	}
+}
+
+// AddServer starts a new server listening on a random local port
+func (t *Topology) AddServer(flavor string) (*Server, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := newServer(t, flavor, uint64(len(t.Servers)+1))
+	if err := s.listen("127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	t.Servers = append(t.Servers, s)
+	return s, nil
+}
+
+// NewMasterSlaves starts a master with n slaves replicating from it
+func NewMasterSlaves(flavor string, user string, password string, n int) (*Topology, error) {
+	t := NewTopology(user, password)
+	master, err := t.AddServer(flavor)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		s, err := t.AddServer(flavor)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		s.ChangeMaster(master)
+	}
+	return t, nil
+}
+
+// GetServer returns the server listening on host and port
+func (t *Topology) GetServer(host string, port string) *Server {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.getServer(host, port)
+}
+
+func (t *Topology) getServer(host string, port string) *Server {
+	for _, s := range t.Servers {
+		if s.Port == port && (s.Host == host || host == "localhost") {
+			return s
+		}
+	}
+	return nil
+}
+
+// Partition cuts the replication link between two servers
+func (t *Topology) Partition(a *Server, b *Server) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cuts[[2]*Server{a, b}] = true
+	t.cuts[[2]*Server{b, a}] = true
+}
+
+// Heal restores the replication link between two servers
+func (t *Topology) Heal(a *Server, b *Server) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.cuts, [2]*Server{a, b})
+	delete(t.cuts, [2]*Server{b, a})
+}
+
+// Sync applies replication on all slaves
+func (t *Topology) Sync() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.replicate()
+}
+
+// Close stops all servers
+func (t *Topology) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.Servers {
+		s.down = true
+		s.closeNetwork()
+	}
+}
+
+// getMaster returns the source of a slave when the replication link is up
+func (t *Topology) getMaster(s *Server) *Server {
+	if s.rpl == nil || s.down || !s.rpl.IORunning {
+		return nil
+	}
+	m := t.getServer(s.rpl.Host, s.rpl.Port)
+	if m == nil || m.down || t.cuts[[2]*Server{s, m}] {
+		return nil
+	}
+	return m
+}
+
+// replicate copies the transactions of masters to slaves until the topology is stable, relays included
+func (t *Topology) replicate() {
+	t.replicateGroup()
+	for pass := 0; pass <= len(t.Servers); pass++ {
+		changed := false
+		for _, s := range t.Servers {
+			m := t.getMaster(s)
+			if m == nil || !s.rpl.SQLRunning || s.rpl.Delay > 0 {
+				continue
+			}
+			if s.Flavor == FlavorMariaDB {
+				for d, g := range m.mdbBinlog {
+					if s.mdbBinlog[d].SeqNo < g.SeqNo {
+						s.mdbBinlog[d] = g
+						s.binlogPos += 100
+						changed = true
+					}
+					s.mdbSlave[d] = s.mdbBinlog[d]
+				}
+			} else if !m.mysqlSet.IsSubsetOf(s.mysqlSet) {
+				s.mysqlSet = s.mysqlSet.Union(m.mysqlSet)
+				s.binlogPos += 100
+				changed = true
+			}
+			s.rpl.File = m.binlogFile()
+			s.rpl.Pos = m.binlogPos
+		}
+		if !changed {
+			break
+		}
+	}
+	for _, m := range t.Servers {
+		clients := 0
+		for _, s := range t.Servers {
+			if t.getMaster(s) == m && s.variables["RPL_SEMI_SYNC_SLAVE_ENABLED"] == "ON" {
+				clients++
+			}
+		}
+		m.status["RPL_SEMI_SYNC_MASTER_CLIENTS"] = itoa(clients)
+		m.status["RPL_SEMI_SYNC_MASTER_STATUS"] = onOff(m.variables["RPL_SEMI_SYNC_MASTER_ENABLED"] == "ON" && clients > 0)
+		m.status["RPL_SEMI_SYNC_SLAVE_STATUS"] = onOff(m.variables["RPL_SEMI_SYNC_SLAVE_ENABLED"] == "ON" && t.getMaster(m) != nil)
+	}
+}
@@ -43,3 +43,11 @@ func (tl *HttpLog) Shift(e HttpMessage) {
 	ns[0] = e
 	tl.Buffer = append(ns, tl.Buffer[0:tl.Len]...)
 }
+
+// GetBuffer returns the messages ordered from the newest one, Shift replaces the buffer
+// so the returned slice is not modified by later messages
+func (tl *HttpLog) GetBuffer() []HttpMessage {
+	tl.L.Lock()
+	defer tl.L.Unlock()
+	return tl.Buffer
+}
@@ -0,0 +1,457 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package sst implements the transfer channel of backups and logs between
+// replication-manager and the database hosts.
+//
+// Both ends authenticate with a certificate of the cluster CA. The sender opens the
+// transfer with a line "SST1 <task> <token>" and the receiver answers with the number
+// of bytes it already received, the sender resumes from there. Data follows in frames
+// of a 4 bytes big endian length and the payload, an empty frame ends the transfer
+// followed by the SHA-256 of the whole stream that the receiver verifies before
+// answering OK.
+//
+// The token is kept in the jobs table of the database sealed with the public key of
+// the cluster client certificate, only the hosts holding its key can open it.
+package sst
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	protocol  = "SST1"
+	frameSize = 64 * 1024
+)
+
+var (
+	ErrToken  = errors.New("SST invalid token")
+	ErrResume = errors.New("SST can not resume the stream beyond its window")
+)
+
+// NewToken returns a random one-time token
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SealToken encrypts token with the public key of the certificate in certFile
+func SealToken(certFile string, token string) (string, error) {
+	b, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return "", errors.New("SST no PEM certificate in " + certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("SST token sealing requires an RSA certificate")
+	}
+	sealed, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, []byte(token), []byte(protocol))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenToken decrypts a token of SealToken with the private key in keyFile
+func OpenToken(keyFile string, sealed string) (string, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return "", errors.New("SST no PEM key in " + keyFile)
+	}
+	var priv *rsa.PrivateKey
+	if priv, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return "", err
+		}
+		var ok bool
+		if priv, ok = key.(*rsa.PrivateKey); !ok {
+			return "", errors.New("SST token opening requires an RSA key")
+		}
+	}
+	b, err = base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	token, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, b, []byte(protocol))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+func loadCA(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("Failed to append CA PEM")
+	}
+	return pool, nil
+}
+
+// ServerConfig is the TLS configuration of the listening end, clients must present
+// a certificate of the CA
+func ServerConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	pool, err := loadCA(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientConfig is the TLS configuration of the connecting end. The server
+// certificate must be signed by the CA, its host name is not checked as the cluster
+// certificates only name the database hosts.
+func ClientConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	pool, err := loadCA(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("SST no server certificate")
+			}
+			opts := x509.VerifyOptions{Roots: pool, Intermediates: x509.NewCertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+			for _, c := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(c)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}, nil
+}
+
+// Transfer is the receiving end of a transfer, it outlives the connections so that
+// an interrupted sender can resume
+type Transfer struct {
+	Task     string
+	Token    string
+	w        io.Writer
+	hash     hash.Hash
+	received int64
+	mu       sync.Mutex
+}
+
+func NewTransfer(task string, token string, w io.Writer) *Transfer {
+	return &Transfer{Task: task, Token: token, w: w, hash: sha256.New()}
+}
+
+// Received returns the number of bytes written
+func (t *Transfer) Received() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.received
+}
+
+// Receive serves a sender connection, it returns true once the transfer is complete
+// and verified
+func (t *Transfer) Receive(conn io.ReadWriter) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != protocol || fields[1] != t.Task || subtle.ConstantTimeCompare([]byte(fields[2]), []byte(t.Token)) != 1 {
+		fmt.Fprintf(conn, "ERR %s\n", ErrToken)
+		return false, ErrToken
+	}
+	if _, err := fmt.Fprintf(conn, "%d\n", t.received); err != nil {
+		return false, err
+	}
+	buf := make([]byte, frameSize)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return false, err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n == 0 {
+			break
+		}
+		if n > frameSize {
+			return false, fmt.Errorf("SST frame of %d bytes", n)
+		}
+		// a frame is only written once complete so that the offset is exact on resume
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			return false, err
+		}
+		if _, err := t.w.Write(buf[:n]); err != nil {
+			fmt.Fprintf(conn, "ERR %s\n", err)
+			return false, err
+		}
+		t.hash.Write(buf[:n])
+		t.received += int64(n)
+	}
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, sum); err != nil {
+		return false, err
+	}
+	if !bytes.Equal(sum, t.hash.Sum(nil)) {
+		fmt.Fprintf(conn, "ERR checksum mismatch\n")
+		return false, errors.New("SST checksum mismatch")
+	}
+	_, err = fmt.Fprintf(conn, "OK\n")
+	return true, err
+}
+
+// Sender is the sending end of a transfer
+type Sender struct {
+	Task  string
+	Token string
+	// bytes per second, 0 is unlimited
+	Bandwidth int64
+	// connections retried when the transfer is interrupted
+	Retries int
+	// last bytes of a reader that can not seek kept in memory to resend the bytes
+	// lost in flight, DefaultWindow when 0
+	Window int
+	Dial   func() (io.ReadWriteCloser, error)
+	hash   hash.Hash
+	hashed int64
+	sent   int64
+	start  time.Time
+}
+
+// DefaultWindow covers the socket buffers of both ends
+const DefaultWindow = 512 * frameSize
+
+// Send streams r and resumes from the offset of the receiver when interrupted. A
+// reader that can not seek only resumes within the window of its last bytes.
+func (s *Sender) Send(r io.Reader) error {
+	if _, ok := r.(io.Seeker); !ok {
+		size := s.Window
+		if size <= 0 {
+			size = DefaultWindow
+		}
+		r = &window{r: r, max: size}
+	}
+	s.hash = sha256.New()
+	s.hashed = 0
+	s.sent = 0
+	s.start = time.Now()
+	var err error
+	for i := 0; i <= s.Retries; i++ {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		err = s.send(r)
+		if err == nil || err == ErrToken || err == ErrResume {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Sender) send(r io.Reader) error {
+	conn, err := s.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "%s %s %s\n", protocol, s.Task, s.Token); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "ERR") {
+		if strings.Contains(line, ErrToken.Error()) {
+			return ErrToken
+		}
+		return errors.New(line)
+	}
+	offset, err := strconv.ParseInt(line, 10, 64)
+	if err != nil {
+		return err
+	}
+	if offset != s.sent {
+		if err := s.seek(r, offset); err != nil {
+			return err
+		}
+	}
+	w := bufio.NewWriterSize(conn, frameSize+4)
+	buf := make([]byte, frameSize+4)
+	for {
+		n, rerr := r.Read(buf[4:])
+		if n > 0 {
+			// sent counts the bytes read so that a failed write is resent, resent
+			// bytes are already in the hash
+			if end := s.sent + int64(n); end > s.hashed {
+				s.hash.Write(buf[4+s.hashed-s.sent : n+4])
+				s.hashed = end
+			}
+			s.sent += int64(n)
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := w.Write(buf[:n+4]); err != nil {
+				return err
+			}
+			s.throttle()
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	binary.BigEndian.PutUint32(buf, 0)
+	w.Write(buf[:4])
+	w.Write(s.hash.Sum(nil))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(line) != "OK" {
+		return errors.New(strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// seek positions the reader at the offset of the receiver
+func (s *Sender) seek(r io.Reader, offset int64) error {
+	seeker, ok := r.(io.Seeker)
+	if !ok || offset > s.hashed {
+		return fmt.Errorf("SST can not resume a stream at %d after sending %d bytes", offset, s.sent)
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	s.sent = offset
+	return nil
+}
+
+// window makes the last bytes read of a stream seekable in a ring buffer growing up
+// to max bytes
+type window struct {
+	r    io.Reader
+	buf  []byte
+	max  int
+	size int64
+	pos  int64
+}
+
+func (w *window) Read(p []byte) (int, error) {
+	if w.pos < w.size {
+		i := int(w.pos % int64(w.max))
+		end := len(w.buf)
+		if left := w.size - w.pos; int64(end-i) > left {
+			end = i + int(left)
+		}
+		n := copy(p, w.buf[i:end])
+		w.pos += int64(n)
+		return n, nil
+	}
+	n, err := w.r.Read(p)
+	w.keep(p[:n])
+	w.pos = w.size
+	return n, err
+}
+
+func (w *window) keep(p []byte) {
+	for len(p) > 0 {
+		i := int(w.size % int64(w.max))
+		var n int
+		if i == len(w.buf) {
+			n = len(p)
+			if n > w.max-i {
+				n = w.max - i
+			}
+			w.buf = append(w.buf, p[:n]...)
+		} else {
+			n = copy(w.buf[i:], p)
+		}
+		p = p[n:]
+		w.size += int64(n)
+	}
+}
+
+func (w *window) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart || offset > w.size {
+		return w.pos, fmt.Errorf("SST can not seek a stream of %d bytes at %d", w.size, offset)
+	}
+	if offset < w.size-int64(len(w.buf)) {
+		return w.pos, ErrResume
+	}
+	w.pos = offset
+	return offset, nil
+}
+
+func (s *Sender) throttle() {
+	if s.Bandwidth <= 0 {
+		return
+	}
+	expected := time.Duration(float64(s.sent) / float64(s.Bandwidth) * float64(time.Second))
+	if wait := expected - time.Since(s.start); wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,280 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package sst
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCA writes a CA and a leaf certificate signed by it in dir
+func writeCA(t *testing.T, dir string, name string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + "CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, ca, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, leaf, ca, &leafKey.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKeyDer, _ := x509.MarshalECPrivateKey(leafKey)
+	ioutil.WriteFile(filepath.Join(dir, name+"-ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+"-cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDer}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: leafKeyDer}), 0600)
+}
+
+func tlsConfigs(t *testing.T, client string) (*tls.Config, *tls.Config) {
+	dir, err := ioutil.TempDir("", "sst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	writeCA(t, dir, "cluster")
+	if client != "cluster" {
+		writeCA(t, dir, client)
+	}
+	srv, err := ServerConfig(filepath.Join(dir, "cluster-ca.pem"), filepath.Join(dir, "cluster-cert.pem"), filepath.Join(dir, "cluster-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := ClientConfig(filepath.Join(dir, "cluster-ca.pem"), filepath.Join(dir, client+"-cert.pem"), filepath.Join(dir, client+"-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, cli
+}
+
+// serve accepts connections for a transfer until it completes
+func serve(l net.Listener, transfer *Transfer, done chan error) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		ok, err := transfer.Receive(conn)
+		conn.Close()
+		if ok || err == ErrToken {
+			done <- err
+			return
+		}
+	}
+}
+
+// failingConn drops the connection after writing limit bytes
+type failingConn struct {
+	net.Conn
+	limit int
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if c.limit <= 0 {
+		c.Conn.Close()
+		return 0, errors.New("connection dropped")
+	}
+	if len(b) > c.limit {
+		b = b[:c.limit]
+	}
+	n, err := c.Conn.Write(b)
+	c.limit -= n
+	return n, err
+}
+
+func TestTransferResume(t *testing.T) {
+	srvConf, cliConf := tlsConfigs(t, "cluster")
+	data := make([]byte, 3*frameSize+123)
+	rand.Read(data)
+
+	// a stream like the output of a backup tool resumes within its window
+	for name, in := range map[string]io.Reader{"file": bytes.NewReader(data), "stream": struct{ io.Reader }{bytes.NewReader(data)}} {
+		l, err := tls.Listen("tcp", "127.0.0.1:0", srvConf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		var out bytes.Buffer
+		transfer := NewTransfer("xtrabackup", "secret", &out)
+		done := make(chan error, 1)
+		go serve(l, transfer, done)
+
+		dials := 0
+		sender := &Sender{Task: "xtrabackup", Token: "secret", Retries: 2, Dial: func() (io.ReadWriteCloser, error) {
+			conn, err := tls.Dial("tcp", l.Addr().String(), cliConf)
+			if err != nil {
+				return nil, err
+			}
+			dials++
+			if dials == 1 {
+				return &failingConn{Conn: conn, limit: frameSize + 1000}, nil
+			}
+			return conn, nil
+		}}
+		if err := sender.Send(in); err != nil {
+			t.Fatal(name, err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(name, err)
+		}
+		if dials != 2 {
+			t.Errorf("Expected a resumed transfer of a %s, got %d connections", name, dials)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("Received %d bytes of a %s differ from the %d bytes sent", out.Len(), name, len(data))
+		}
+	}
+}
+
+func TestTransferBeyondWindow(t *testing.T) {
+	srvConf, cliConf := tlsConfigs(t, "cluster")
+	data := make([]byte, 3*frameSize+123)
+	rand.Read(data)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", srvConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	transfer := NewTransfer("xtrabackup", "secret", ioutil.Discard)
+	go serve(l, transfer, make(chan error, 1))
+
+	// the second frame is lost in flight and the window only keeps half of it
+	dials := 0
+	sender := &Sender{Task: "xtrabackup", Token: "secret", Retries: 2, Window: frameSize / 2, Dial: func() (io.ReadWriteCloser, error) {
+		conn, err := tls.Dial("tcp", l.Addr().String(), cliConf)
+		if err != nil {
+			return nil, err
+		}
+		dials++
+		if dials == 1 {
+			return &failingConn{Conn: conn, limit: frameSize + 1000}, nil
+		}
+		return conn, nil
+	}}
+	if err := sender.Send(struct{ io.Reader }{bytes.NewReader(data)}); err != ErrResume {
+		t.Errorf("Expected %s, got %v", ErrResume, err)
+	}
+	if dials != 2 {
+		t.Errorf("Expected no retry once the window is exceeded, got %d connections", dials)
+	}
+}
+
+func TestSealToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "client"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "client-cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "client-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+
+	token, _ := NewToken()
+	sealed, err := SealToken(filepath.Join(dir, "client-cert.pem"), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, token) || strings.Contains(sealed, "@") {
+		t.Errorf("Unexpected sealed token %s", sealed)
+	}
+	opened, err := OpenToken(filepath.Join(dir, "client-key.pem"), sealed)
+	if err != nil || opened != token {
+		t.Errorf("Expected token %s got %s %v", token, opened, err)
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ioutil.WriteFile(filepath.Join(dir, "other-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(other)}), 0600)
+	if _, err := OpenToken(filepath.Join(dir, "other-key.pem"), sealed); err == nil {
+		t.Error("Expected another key to fail opening the token")
+	}
+}
+
+func TestTransferRefused(t *testing.T) {
+	srvConf, cliConf := tlsConfigs(t, "cluster")
+	l, err := tls.Listen("tcp", "127.0.0.1:0", srvConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go serve(l, NewTransfer("error", "secret", &out), done)
+	sender := &Sender{Task: "error", Token: "guess", Retries: 2, Dial: func() (io.ReadWriteCloser, error) {
+		return tls.Dial("tcp", l.Addr().String(), cliConf)
+	}}
+	if err := sender.Send(bytes.NewReader([]byte("log"))); err != ErrToken {
+		t.Fatalf("Expected an invalid token got %v", err)
+	}
+	if err := <-done; err != ErrToken {
+		t.Fatalf("Expected the receiver to refuse the token got %v", err)
+	}
+
+	// a certificate of another CA is refused during the handshake
+	srvConf2, otherConf := tlsConfigs(t, "other")
+	l2, err := tls.Listen("tcp", "127.0.0.1:0", srvConf2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+	go func() {
+		conn, err := l2.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	sender = &Sender{Task: "error", Token: "secret", Dial: func() (io.ReadWriteCloser, error) {
+		return tls.Dial("tcp", l2.Addr().String(), otherConf)
+	}}
+	if err := sender.Send(bytes.NewReader([]byte("log"))); err == nil {
+		t.Fatal("Expected a certificate of another CA to be refused")
+	}
+}
@@ -328,6 +328,19 @@ func (SM *StateMachine) GetResolvedStates() []State {
 	return log
 }
 
+// GetNewStates returns the states opened since the last ticker
+func (SM *StateMachine) GetNewStates() []State {
+	var log []State
+	SM.Lock()
+	for key, state := range *SM.CurState {
+		if SM.OldState.Search(key) == false {
+			log = append(log, state)
+		}
+	}
+	SM.Unlock()
+	return log
+}
+
 func (SM *StateMachine) GetOpenStates() []State {
 	var log []State
 	SM.Lock()
@@ -0,0 +1,461 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package workload
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session replays the queries of a captured session on its own connection
+type Session interface {
+	Exec(ctx context.Context, db string, query string) error
+	Close() error
+}
+
+type Target interface {
+	NewSession(ctx context.Context) (Session, error)
+}
+
+type sqlTarget struct {
+	db   *sql.DB
+	init []string
+}
+
+// NewSQLTarget replays on a connection of db per session, the init statements run first
+// on every session
+func NewSQLTarget(db *sql.DB, init ...string) Target {
+	return &sqlTarget{db: db, init: init}
+}
+
+func (t *sqlTarget) NewSession(ctx context.Context) (Session, error) {
+	conn, err := t.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, query := range t.init {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &sqlSession{conn: conn}, nil
+}
+
+type sqlSession struct {
+	conn *sql.Conn
+	db   string
+}
+
+func (s *sqlSession) Exec(ctx context.Context, db string, query string) error {
+	if db != "" && db != s.db {
+		if _, err := s.conn.ExecContext(ctx, "USE `"+db+"`"); err != nil {
+			return err
+		}
+		s.db = db
+	}
+	// the rows of a select are read and discarded
+	_, err := s.conn.ExecContext(ctx, query)
+	return err
+}
+
+func (s *sqlSession) Close() error {
+	return s.conn.Close()
+}
+
+type Options struct {
+	// 1 replays at the captured pace, 2 twice faster, 0 as fast as possible
+	Speed float64
+	// only the queries not changing data are replayed
+	ReadOnly bool
+	// a session idle for longer closes its connection until its next query
+	IdleTimeout time.Duration
+}
+
+// DigestReport compares the latencies in milliseconds of a query digest on the
+// captured and the replay servers
+type DigestReport struct {
+	Digest        string  `json:"digest"`
+	DigestText    string  `json:"digestText"`
+	Count         int64   `json:"count"`
+	Skipped       int64   `json:"skipped"`
+	CaptureErrors int64   `json:"captureErrors"`
+	ReplayErrors  int64   `json:"replayErrors"`
+	LastError     string  `json:"lastError"`
+	CaptureAvg    float64 `json:"captureAvg"`
+	CaptureP95    float64 `json:"captureP95"`
+	CaptureMax    float64 `json:"captureMax"`
+	ReplayAvg     float64 `json:"replayAvg"`
+	ReplayP95     float64 `json:"replayP95"`
+	ReplayMax     float64 `json:"replayMax"`
+	ReplayTotal   float64 `json:"replayTotal"`
+	// replay average over capture average, above 1 the replay server is slower
+	Ratio float64 `json:"ratio"`
+}
+
+type Report struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Events   int64     `json:"events"`
+	Replayed int64     `json:"replayed"`
+	Skipped  int64     `json:"skipped"`
+	Errors   int64     `json:"errors"`
+	Sessions int64     `json:"sessions"`
+	// longest delay in milliseconds of a query on its scheduled time
+	MaxLag  float64        `json:"maxLag"`
+	Digests []DigestReport `json:"digests"`
+}
+
+type digestStats struct {
+	report  DigestReport
+	capture []float64
+	replay  []float64
+}
+
+type collector struct {
+	sync.Mutex
+	report  Report
+	digests map[string]*digestStats
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (c *collector) add(e *Event, skipped bool, latency time.Duration, lag time.Duration, err error) {
+	c.Lock()
+	defer c.Unlock()
+	s, ok := c.digests[e.Digest]
+	if !ok {
+		s = &digestStats{report: DigestReport{Digest: e.Digest, DigestText: digestText(e.Query)}}
+		c.digests[e.Digest] = s
+	}
+	s.report.Count++
+	c.report.Events++
+	if e.Errno != 0 {
+		s.report.CaptureErrors++
+	}
+	if skipped {
+		s.report.Skipped++
+		c.report.Skipped++
+		return
+	}
+	c.report.Replayed++
+	s.capture = append(s.capture, ms(e.Duration))
+	s.replay = append(s.replay, ms(latency))
+	if err != nil {
+		s.report.ReplayErrors++
+		s.report.LastError = err.Error()
+		c.report.Errors++
+	}
+	if l := ms(lag); l > c.report.MaxLag {
+		c.report.MaxLag = l
+	}
+}
+
+// summary returns the average, 95th percentile and maximum of values
+func summary(values []float64) (float64, float64, float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values)), values[(len(values)*95-1)/100], values[len(values)-1]
+}
+
+func (c *collector) finish() *Report {
+	c.Lock()
+	defer c.Unlock()
+	r := c.report
+	r.End = time.Now()
+	r.Digests = []DigestReport{}
+	for _, s := range c.digests {
+		d := s.report
+		d.CaptureAvg, d.CaptureP95, d.CaptureMax = summary(s.capture)
+		d.ReplayAvg, d.ReplayP95, d.ReplayMax = summary(s.replay)
+		d.ReplayTotal = d.ReplayAvg * float64(len(s.replay))
+		if d.CaptureAvg > 0 {
+			d.Ratio = d.ReplayAvg / d.CaptureAvg
+		}
+		r.Digests = append(r.Digests, d)
+	}
+	sort.Slice(r.Digests, func(i, j int) bool { return r.Digests[i].ReplayTotal > r.Digests[j].ReplayTotal })
+	return &r
+}
+
+// tokenize returns the lower case words and symbols of a statement, strings are
+// replaced by ?, comments are dropped but the content of executable comments is kept
+func tokenize(query string) []string {
+	var toks []string
+	q := strings.ToLower(query)
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case strings.HasPrefix(q[i:], "/*!"), strings.HasPrefix(q[i:], "/*m!"):
+			// /*!50110 KEY_BLOCK_SIZE=1024 */ is run by the server
+			i += strings.Index(q[i:], "!") + 1
+			for i < len(q) && q[i] >= '0' && q[i] <= '9' {
+				i++
+			}
+		case strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return toks
+			}
+			i += end + 4
+		case strings.HasPrefix(q[i:], "*/"):
+			i += 2
+		case c == '#' || strings.HasPrefix(q[i:], "-- "):
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				return toks
+			}
+			i += end
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(q) && q[j] != c {
+				if q[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			if c == '`' {
+				// a quoted identifier is never a keyword
+				toks = append(toks, q[i:j])
+			} else {
+				toks = append(toks, "?")
+			}
+			i = j + 1
+		case c == '_' || c == '$' || c == '@' || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c >= 0x80:
+			j := i
+			for j < len(q) && (q[j] == '_' || q[j] == '$' || q[j] == '@' || (q[j] >= 'a' && q[j] <= 'z') || (q[j] >= '0' && q[j] <= '9') || q[j] >= 0x80) {
+				j++
+			}
+			toks = append(toks, q[i:j])
+			i = j
+		default:
+			toks = append(toks, q[i:i+1])
+			i++
+		}
+	}
+	return toks
+}
+
+var readOnlyStatements = map[string]bool{"select": true, "show": true, "desc": true, "describe": true, "explain": true, "with": true, "set": true, "use": true, "help": true}
+
+// IsReadOnly returns true for a statement that changes neither data nor the
+// configuration of the server
+func IsReadOnly(query string) bool {
+	return isReadOnly(tokenize(query))
+}
+
+func isReadOnly(toks []string) bool {
+	for len(toks) > 0 && toks[0] == "(" {
+		toks = toks[1:]
+	}
+	if len(toks) == 0 || !readOnlyStatements[toks[0]] {
+		return false
+	}
+	for i, tok := range toks {
+		switch tok {
+		case ";":
+			if i < len(toks)-1 {
+				return false
+			}
+		case "outfile", "dumpfile":
+			if toks[i-1] == "into" {
+				return false
+			}
+		case "update":
+			if toks[i-1] == "for" && toks[0] != "set" {
+				return false
+			}
+		}
+	}
+	switch toks[0] {
+	case "set":
+		return isReadOnlySet(toks)
+	case "with":
+		// the statement after the common table expressions
+		depth := 0
+		for _, tok := range toks[1:] {
+			switch {
+			case tok == "(":
+				depth++
+			case tok == ")":
+				depth--
+			case depth == 0 && (tok == "select" || tok == "insert" || tok == "update" || tok == "delete" || tok == "replace" || tok == "table" || tok == "values"):
+				return tok == "select"
+			}
+		}
+		return false
+	case "explain", "desc", "describe":
+		// EXPLAIN ANALYZE runs the statement
+		for _, tok := range toks {
+			if tok == "insert" || tok == "update" || tok == "delete" || tok == "replace" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isReadOnlySet returns true when a SET only changes the session and keeps the
+// binary log and the access mode of the replay session
+func isReadOnlySet(toks []string) bool {
+	for i, tok := range toks {
+		switch strings.TrimPrefix(tok, "@@") {
+		case "global", "persist", "persist_only", "password", "role", "sql_log_bin", "transaction_read_only", "tx_read_only":
+			return false
+		case "write":
+			return false
+		case "for":
+			// SET STATEMENT var=value FOR statement
+			if toks[1] == "statement" {
+				return isReadOnly(toks[i+1:])
+			}
+		}
+	}
+	return true
+}
+
+// ChangesBinlog returns true for a statement changing sql_log_bin, a replay
+// must not undo the sql_log_bin=0 of its sessions
+func ChangesBinlog(query string) bool {
+	for _, tok := range tokenize(query) {
+		if strings.TrimPrefix(tok, "@@") == "sql_log_bin" {
+			return true
+		}
+	}
+	return false
+}
+
+type scheduled struct {
+	event *Event
+	at    time.Time
+}
+
+// Replay sends each captured event to its session at its offset divided by the
+// speed. Sessions run concurrently on their own connection so that the original
+// concurrency is kept, a session slower than the capture queues its queries.
+func Replay(ctx context.Context, r *Reader, target Target, opt Options) (*Report, error) {
+	if opt.IdleTimeout == 0 {
+		opt.IdleTimeout = 10 * time.Second
+	}
+	c := &collector{digests: make(map[string]*digestStats)}
+	c.report.Start = time.Now()
+	sessions := make(map[string]chan scheduled)
+	var wg sync.WaitGroup
+	var err error
+	for {
+		var e *Event
+		e, err = r.Next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		if e.Truncated || ChangesBinlog(e.Query) || (opt.ReadOnly && !IsReadOnly(e.Query)) {
+			c.add(e, true, 0, 0, nil)
+			continue
+		}
+		at := c.report.Start
+		if opt.Speed > 0 {
+			at = at.Add(time.Duration(float64(e.Offset) / opt.Speed))
+		}
+		if wait := time.Until(at); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		queue, ok := sessions[e.Session]
+		if !ok {
+			queue = make(chan scheduled, 1024)
+			sessions[e.Session] = queue
+			c.Lock()
+			c.report.Sessions++
+			c.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				replaySession(ctx, queue, target, opt, c)
+			}()
+		}
+		queue <- scheduled{event: e, at: at}
+	}
+	for _, queue := range sessions {
+		close(queue)
+	}
+	wg.Wait()
+	return c.finish(), err
+}
+
+func replaySession(ctx context.Context, queue chan scheduled, target Target, opt Options, c *collector) {
+	var session Session
+	defer func() {
+		if session != nil {
+			session.Close()
+		}
+	}()
+	idle := time.NewTimer(opt.IdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-idle.C:
+			// keep the number of connections close to the captured one
+			if session != nil {
+				session.Close()
+				session = nil
+			}
+			continue
+		case s, ok := <-queue:
+			if !ok {
+				return
+			}
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			if ctx.Err() != nil {
+				c.add(s.event, true, 0, 0, nil)
+				continue
+			}
+			var err error
+			if session == nil {
+				session, err = target.NewSession(ctx)
+			}
+			begin := time.Now()
+			if err == nil {
+				err = session.Exec(ctx, s.event.Db, s.event.Query)
+			}
+			c.add(s.event, false, time.Since(begin), begin.Sub(s.at), err)
+			idle.Reset(opt.IdleTimeout)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package workload records the query stream of a database server and replays it
+// against another one with the original sessions and timing.
+//
+// A capture is a gzip stream of JSON lines, one event per query in the order they
+// were captured.
+package workload
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/crypto"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/s18log"
+)
+
+const (
+	ConstSourcePFS     = "pfs"
+	ConstSourceSlowLog = "slowlog"
+)
+
+// Event is a query of a session, short keys keep the captures compact
+type Event struct {
+	// start of the query since the start of the capture
+	Offset  time.Duration `json:"o"`
+	Session string        `json:"s"`
+	Db      string        `json:"db,omitempty"`
+	Query   string        `json:"q"`
+	Digest  string        `json:"d"`
+	// execution time on the captured server
+	Duration time.Duration `json:"t"`
+	Errno    int           `json:"e,omitempty"`
+	// the query text was cut by the server and can not be replayed
+	Truncated bool `json:"x,omitempty"`
+}
+
+// Digest of a query as in the slow log, the fingerprint panics on some statements
+// that are then digested on their text
+func Digest(query string) (digest string) {
+	defer func() {
+		if recover() != nil {
+			digest = crypto.GetMD5Hash(query)
+		}
+	}()
+	return crypto.GetMD5Hash(dbhelper.GetQueryDigest(query))
+}
+
+func digestText(query string) (text string) {
+	defer func() {
+		if recover() != nil {
+			text = query
+		}
+	}()
+	return dbhelper.GetQueryDigest(query)
+}
+
+type Writer struct {
+	gz  *gzip.Writer
+	enc *json.Encoder
+}
+
+func NewWriter(w io.Writer) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{gz: gz, enc: json.NewEncoder(gz)}
+}
+
+func (w *Writer) Write(e *Event) error {
+	return w.enc.Encode(e)
+}
+
+// Close flushes the capture, it does not close the underlying writer
+func (w *Writer) Close() error {
+	return w.gz.Close()
+}
+
+type Reader struct {
+	gz  *gzip.Reader
+	dec *json.Decoder
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{gz: gz, dec: json.NewDecoder(gz)}, nil
+}
+
+// Next returns the next event, io.EOF at the end of the capture
+func (r *Reader) Next() (*Event, error) {
+	e := new(Event)
+	if err := r.dec.Decode(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+var slowLogTimeLayouts = []string{
+	time.RFC3339Nano,
+	"060102 15:04:05",
+	"2006-01-02 15:04:05 -0700 MST",
+}
+
+func parseSlowLogTime(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, layout := range slowLogTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FromSlowMessage converts a query of the slow log to an event of a capture started
+// at start, received is used when the message has no timestamp. Admin commands and
+// USE statements, kept as the schema of the next queries, are not events.
+func FromSlowMessage(m *s18log.SlowMessage, start time.Time, received time.Time) (*Event, bool) {
+	if m.Admin || m.Query == "" || strings.HasPrefix(strings.ToLower(m.Query), "use ") {
+		return nil, false
+	}
+	e := &Event{
+		Db:       m.Db,
+		Query:    strings.TrimSuffix(m.Query, ";"),
+		Digest:   m.Digest,
+		Duration: time.Duration(m.TimeMetrics["queryTime"] * float64(time.Second)),
+	}
+	if id, ok := m.NumberMetrics["threadId"]; ok {
+		e.Session = strconv.FormatUint(id, 10)
+	} else {
+		// MySQL does not parse the thread id of the slow log
+		e.Session = m.User + "@" + m.Host
+	}
+	ts, ok := parseSlowLogTime(m.Timestamp)
+	if !ok {
+		ts = received
+	}
+	if e.Offset = ts.Sub(start); e.Offset < 0 {
+		e.Offset = 0
+	}
+	return e, true
+}
@@ -0,0 +1,244 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package workload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/s18log"
+)
+
+func capture(t *testing.T, events []*Event) *Reader {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReadWrite(t *testing.T) {
+	events := []*Event{
+		{Offset: time.Millisecond, Session: "12", Db: "test", Query: "SELECT 1", Digest: Digest("SELECT 1"), Duration: 300 * time.Microsecond},
+		{Offset: 2 * time.Millisecond, Session: "13", Query: "INSERT INTO t VALUES(1)", Digest: Digest("INSERT INTO t VALUES(1)"), Errno: 1062, Truncated: true},
+	}
+	r := capture(t, events)
+	for _, expected := range events {
+		e, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e, expected) {
+			t.Errorf("Expected %+v got %+v", expected, e)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Expected EOF got %v", err)
+	}
+	if Digest("SELECT * FROM t WHERE id=1") != Digest("SELECT * FROM t WHERE id=2") {
+		t.Errorf("Expected the same digest for queries with different values")
+	}
+}
+
+func TestFromSlowMessage(t *testing.T) {
+	var sl s18log.SlowLog
+	m := s18log.NewSlowMessage()
+	for _, line := range []string{
+		"# User@Host: app[app] @ localhost []",
+		"# Thread_id: 42  Schema: shop  QC_hit: No",
+		"# Query_time: 0.250000  Lock_time: 0.000000  Rows_sent: 1  Rows_examined: 10",
+		"SET timestamp=1700000000;",
+		"SELECT * FROM orders WHERE id=3;",
+	} {
+		sl.ParseLine(line, m)
+	}
+	start := time.Unix(1700000000, 0).Add(-2 * time.Second)
+	e, ok := FromSlowMessage(m, start, time.Now())
+	if !ok {
+		t.Fatal("Expected an event")
+	}
+	if e.Session != "42" || e.Db != "shop" || e.Query != "SELECT * FROM orders WHERE id=3" || e.Offset != 2*time.Second || e.Duration != 250*time.Millisecond {
+		t.Errorf("Unexpected event %+v", e)
+	}
+
+	use := s18log.NewSlowMessage()
+	sl.ParseLine("use shop;", use)
+	if _, ok := FromSlowMessage(use, start, time.Now()); ok {
+		t.Errorf("Expected USE not to be an event")
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	for query, expected := range map[string]bool{
+		"SELECT 1":                                             true,
+		" (select a from t) union select 1":                    true,
+		"show tables":                                          true,
+		"SET NAMES utf8":                                       true,
+		"SET GLOBAL read_only=0":                               false,
+		"select * from t for update":                           false,
+		"UPDATE t SET a=1":                                     false,
+		"insert into t values(1)":                              false,
+		"SET @@GLOBAL.read_only=0":                             false,
+		"SET PERSIST max_connections=1":                        false,
+		"set  global read_only=0":                              false,
+		"SET sql_log_bin=1":                                    false,
+		"SET @@session.sql_log_bin=1":                          false,
+		"SET SESSION TRANSACTION READ WRITE":                   false,
+		"SET STATEMENT max_statement_time=1 FOR SELECT 1":      true,
+		"SET STATEMENT max_statement_time=1 FOR DELETE FROM t": false,
+		"SET @a='global'":                                      true,
+		"SELECT a INTO OUTFILE '/tmp/t' FROM t":                false,
+		"select a from t into dumpfile '/tmp/t'":               false,
+		"SELECT a INTO @a FROM t":                              true,
+		"WITH d AS (SELECT 1) DELETE FROM t":                   false,
+		"WITH d AS (SELECT a FROM t) SELECT * FROM d":          true,
+		"/*!40101 SET GLOBAL read_only=0 */":                   false,
+		"SELECT 1; DELETE FROM t":                              false,
+		"EXPLAIN ANALYZE DELETE FROM t":                        false,
+		"SELECT `global` FROM t":                               true,
+	} {
+		if IsReadOnly(query) != expected {
+			t.Errorf("IsReadOnly(%q) expected %t", query, expected)
+		}
+	}
+}
+
+type fakeTarget struct {
+	sync.Mutex
+	sessions int
+	open     int
+	maxOpen  int
+	queries  []string
+	start    time.Time
+	times    map[string]time.Duration
+}
+
+type fakeSession struct {
+	target *fakeTarget
+	db     string
+}
+
+func (t *fakeTarget) NewSession(ctx context.Context) (Session, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.sessions++
+	t.open++
+	if t.open > t.maxOpen {
+		t.maxOpen = t.open
+	}
+	return &fakeSession{target: t}, nil
+}
+
+func (s *fakeSession) Exec(ctx context.Context, db string, query string) error {
+	t := s.target
+	t.Lock()
+	t.queries = append(t.queries, db+":"+query)
+	t.times[query] = time.Since(t.start)
+	t.Unlock()
+	if strings.HasPrefix(query, "SLEEP") {
+		time.Sleep(30 * time.Millisecond)
+	}
+	if strings.HasPrefix(query, "FAIL") {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (s *fakeSession) Close() error {
+	s.target.Lock()
+	s.target.open--
+	s.target.Unlock()
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	events := []*Event{
+		{Offset: 0, Session: "1", Db: "a", Query: "SLEEP 1", Digest: "sleep", Duration: 10 * time.Millisecond},
+		{Offset: 0, Session: "2", Db: "b", Query: "SELECT 1", Digest: "select", Duration: time.Millisecond},
+		{Offset: 20 * time.Millisecond, Session: "3", Query: "UPDATE t SET a=1", Digest: "update"},
+		{Offset: 40 * time.Millisecond, Session: "2", Db: "b", Query: "FAIL 1", Digest: "fail"},
+		{Offset: 50 * time.Millisecond, Session: "3", Query: "SELECT 2", Digest: "select", Duration: 3 * time.Millisecond, Truncated: true},
+	}
+	target := &fakeTarget{start: time.Now(), times: make(map[string]time.Duration)}
+	report, err := Replay(context.Background(), capture(t, events), target, Options{Speed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 5 || report.Replayed != 4 || report.Skipped != 1 || report.Errors != 1 || report.Sessions != 3 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	// the third session starts while the first one is still running
+	if target.maxOpen != 3 || target.open != 0 {
+		t.Errorf("Expected 3 concurrent sessions all closed, got %d open %d %v %d", target.maxOpen, target.open, target.queries, target.sessions)
+	}
+	if target.times["SELECT 1"] > 20*time.Millisecond || target.times["FAIL 1"] < 40*time.Millisecond {
+		t.Errorf("Unexpected replay timing %v", target.times)
+	}
+	digests := make(map[string]DigestReport)
+	for _, d := range report.Digests {
+		digests[d.Digest] = d
+	}
+	if d := digests["sleep"]; d.Count != 1 || d.ReplayAvg < 30 || d.CaptureAvg != 10 || d.Ratio < 3 {
+		t.Errorf("Unexpected sleep digest %+v", d)
+	}
+	if d := digests["select"]; d.Count != 2 || d.Skipped != 1 {
+		t.Errorf("Unexpected select digest %+v", d)
+	}
+	if d := digests["fail"]; d.ReplayErrors != 1 || d.LastError != "failed" {
+		t.Errorf("Unexpected fail digest %+v", d)
+	}
+	if report.Digests[0].Digest != "sleep" {
+		t.Errorf("Expected the digests sorted on replay time, got %s first", report.Digests[0].Digest)
+	}
+
+	target = &fakeTarget{start: time.Now(), times: make(map[string]time.Duration)}
+	report, _ = Replay(context.Background(), capture(t, events), target, Options{ReadOnly: true})
+	if report.Replayed != 1 || !reflect.DeepEqual(target.queries, []string{"b:SELECT 1"}) {
+		t.Errorf("Expected writes skipped in read only, replayed %v", target.queries)
+	}
+
+	// the binary log stays disabled for the writes of a replay
+	events = []*Event{
+		{Offset: 0, Session: "1", Query: "SET sql_log_bin=1", Digest: "set"},
+		{Offset: 0, Session: "1", Query: "INSERT INTO t VALUES (1)", Digest: "insert"},
+	}
+	target = &fakeTarget{start: time.Now(), times: make(map[string]time.Duration)}
+	report, _ = Replay(context.Background(), capture(t, events), target, Options{})
+	if report.Skipped != 1 || !reflect.DeepEqual(target.queries, []string{":INSERT INTO t VALUES (1)"}) {
+		t.Errorf("Expected sql_log_bin skipped, replayed %v", target.queries)
+	}
+}
+
+func TestReplayIdleSession(t *testing.T) {
+	events := []*Event{
+		{Offset: 0, Session: "1", Query: "SELECT 1", Digest: "select"},
+		{Offset: 60 * time.Millisecond, Session: "1", Query: "SELECT 2", Digest: "select"},
+	}
+	target := &fakeTarget{start: time.Now(), times: make(map[string]time.Duration)}
+	Replay(context.Background(), capture(t, events), target, Options{Speed: 1, IdleTimeout: 20 * time.Millisecond})
+	if target.sessions != 2 || target.open != 0 {
+		t.Errorf("Expected the idle session to reconnect, got %d sessions %d open", target.sessions, target.open)
+	}
+}
@@ -0,0 +1,215 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package xbstream reads and writes the xbstream archives of xtrabackup and
+// mariabackup.
+//
+// A chunk is the magic XBSTCK01, a flags byte, a type byte, the path length on 4
+// bytes and the path. Payload chunks follow with the payload length and offset on 8
+// bytes, the CRC32 of the payload on 4 bytes and the payload. All integers are little
+// endian. An EOF chunk ends each file.
+package xbstream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	ChunkPayload byte = 'P'
+	ChunkEOF     byte = 'E'
+
+	// CheckpointsFile holds the LSN range of a backup
+	CheckpointsFile = "xtrabackup_checkpoints"
+
+	maxPathLen    = 512
+	maxPayloadLen = 1 << 30
+)
+
+var magic = []byte("XBSTCK01")
+
+type Chunk struct {
+	Flags   byte
+	Type    byte
+	Path    string
+	Offset  uint64
+	Payload []byte
+}
+
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 1<<20)}
+}
+
+// Next returns the next chunk, io.EOF at the end of the archive
+func (x *Reader) Next() (*Chunk, error) {
+	var head [14]byte
+	if _, err := io.ReadFull(x.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("xbstream truncated chunk header")
+		}
+		return nil, err
+	}
+	if !bytes.Equal(head[:8], magic) {
+		return nil, errors.New("xbstream wrong chunk magic")
+	}
+	c := &Chunk{Flags: head[8], Type: head[9]}
+	pathLen := binary.LittleEndian.Uint32(head[10:])
+	if pathLen > maxPathLen {
+		return nil, fmt.Errorf("xbstream path of %d bytes", pathLen)
+	}
+	path := make([]byte, pathLen)
+	if _, err := io.ReadFull(x.r, path); err != nil {
+		return nil, err
+	}
+	c.Path = string(path)
+	switch c.Type {
+	case ChunkEOF:
+		return c, nil
+	case ChunkPayload:
+	default:
+		return nil, fmt.Errorf("xbstream unsupported chunk type %q for %s", c.Type, c.Path)
+	}
+	var pay [20]byte
+	if _, err := io.ReadFull(x.r, pay[:]); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint64(pay[:8])
+	c.Offset = binary.LittleEndian.Uint64(pay[8:16])
+	sum := binary.LittleEndian.Uint32(pay[16:])
+	if length > maxPayloadLen {
+		return nil, fmt.Errorf("xbstream payload of %d bytes for %s", length, c.Path)
+	}
+	c.Payload = make([]byte, length)
+	if _, err := io.ReadFull(x.r, c.Payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(c.Payload) != sum {
+		return nil, fmt.Errorf("xbstream checksum mismatch for %s at %d", c.Path, c.Offset)
+	}
+	return c, nil
+}
+
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (x *Writer) Write(c *Chunk) error {
+	buf := make([]byte, 0, 14+len(c.Path)+20)
+	buf = append(buf, magic...)
+	buf = append(buf, c.Flags, c.Type)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(c.Path)))
+	buf = append(buf, c.Path...)
+	if c.Type == ChunkPayload {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(c.Payload)))
+		buf = binary.LittleEndian.AppendUint64(buf, c.Offset)
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(c.Payload))
+	}
+	if _, err := x.w.Write(buf); err != nil {
+		return err
+	}
+	if c.Type == ChunkPayload {
+		_, err := x.w.Write(c.Payload)
+		return err
+	}
+	return nil
+}
+
+// Copy writes the chunks of r to w with prefix prepended to their path
+func Copy(w *Writer, r *Reader, prefix string) error {
+	for {
+		c, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.Path = prefix + c.Path
+		if err := w.Write(c); err != nil {
+			return err
+		}
+	}
+}
+
+// Checkpoints is the content of xtrabackup_checkpoints
+type Checkpoints struct {
+	BackupType string `json:"backupType"`
+	FromLSN    uint64 `json:"fromLsn"`
+	ToLSN      uint64 `json:"toLsn"`
+	LastLSN    uint64 `json:"lastLsn"`
+}
+
+func ParseCheckpoints(data []byte) (Checkpoints, error) {
+	var cp Checkpoints
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+		var err error
+		switch key {
+		case "backup_type":
+			cp.BackupType = value
+		case "from_lsn":
+			cp.FromLSN, err = strconv.ParseUint(value, 10, 64)
+		case "to_lsn":
+			cp.ToLSN, err = strconv.ParseUint(value, 10, 64)
+			found = true
+		case "last_lsn":
+			cp.LastLSN, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return cp, fmt.Errorf("xbstream checkpoints %s: %s", key, err)
+		}
+	}
+	if !found {
+		return cp, errors.New("xbstream checkpoints without to_lsn")
+	}
+	return cp, nil
+}
+
+// ScanCheckpoints reads an archive to its end and returns its checkpoints
+func ScanCheckpoints(r io.Reader) (Checkpoints, error) {
+	x := NewReader(r)
+	var data []byte
+	for {
+		c, err := x.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Checkpoints{}, err
+		}
+		if c.Type == ChunkPayload && c.Path == CheckpointsFile {
+			data = append(data, c.Payload...)
+		}
+	}
+	if data == nil {
+		return Checkpoints{}, errors.New("xbstream archive without " + CheckpointsFile)
+	}
+	return ParseCheckpoints(data)
+}
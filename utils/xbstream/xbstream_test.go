@@ -0,0 +1,113 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package xbstream
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func archive(t *testing.T, chunks []*Chunk) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, c := range chunks {
+		if err := w.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, data []byte) []*Chunk {
+	var chunks []*Chunk
+	r := NewReader(bytes.NewReader(data))
+	for {
+		c, err := r.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+var checkpoints = []byte("backup_type = incremental\nfrom_lsn = 1626007\nto_lsn = 1627345\nlast_lsn = 1627354\ncompact = 0\n")
+
+func backupChunks() []*Chunk {
+	return []*Chunk{
+		{Type: ChunkPayload, Path: "ibdata1.delta", Payload: []byte("delta")},
+		{Type: ChunkPayload, Path: "ibdata1.delta", Offset: 5, Payload: []byte("pages")},
+		{Type: ChunkEOF, Path: "ibdata1.delta"},
+		{Type: ChunkPayload, Path: CheckpointsFile, Payload: checkpoints},
+		{Type: ChunkEOF, Path: CheckpointsFile},
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	chunks := backupChunks()
+	data := archive(t, chunks)
+	got := readAll(t, data)
+	if len(got) != len(chunks) {
+		t.Fatalf("Expected %d chunks got %d", len(chunks), len(got))
+	}
+	for i := range chunks {
+		if got[i].Payload == nil {
+			got[i].Payload = chunks[i].Payload
+		}
+		if !reflect.DeepEqual(got[i], chunks[i]) {
+			t.Errorf("Chunk %d: expected %+v got %+v", i, chunks[i], got[i])
+		}
+	}
+
+	// a corrupted payload fails on its checksum
+	corrupted := bytes.Replace(data, []byte("pages"), []byte("pagez"), 1)
+	r := NewReader(bytes.NewReader(corrupted))
+	r.Next()
+	if _, err := r.Next(); err == nil {
+		t.Errorf("Expected a checksum error")
+	}
+	if _, err := NewReader(bytes.NewReader(data[:len(data)-3])).Next(); err != nil {
+		t.Errorf("Unexpected error on the first chunk of a truncated archive %s", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := Copy(w, NewReader(bytes.NewReader(archive(t, backupChunks()))), "incremental/001/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range readAll(t, buf.Bytes()) {
+		if c.Path != "incremental/001/ibdata1.delta" && c.Path != "incremental/001/"+CheckpointsFile {
+			t.Errorf("Unexpected path %s", c.Path)
+		}
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	cp, err := ScanCheckpoints(bytes.NewReader(archive(t, backupChunks())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Checkpoints{BackupType: "incremental", FromLSN: 1626007, ToLSN: 1627345, LastLSN: 1627354}
+	if cp != expected {
+		t.Errorf("Expected %+v got %+v", expected, cp)
+	}
+	if _, err := ScanCheckpoints(bytes.NewReader(archive(t, backupChunks()[:3]))); err == nil {
+		t.Errorf("Expected an error on an archive without checkpoints")
+	}
+	if _, err := ParseCheckpoints([]byte("to_lsn = abc\n")); err == nil {
+		t.Errorf("Expected an error on a wrong LSN")
+	}
+}